@@ -0,0 +1,134 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+
+	"github.com/elide-dev/elide-task-driver/driver"
+)
+
+// Admin CLI for a running Elide driver plugin. It talks to the plugin's admin
+// API (enabled with admin_socket in the plugin config) to export and import
+// driver state snapshots, and to inspect per-alloc execution counts.
+//
+//	admin -socket /tmp/elide-driver-admin.sock export -file state.json
+//	admin -socket /tmp/elide-driver-admin.sock import -file state.json
+//	admin -socket /tmp/elide-driver-admin.sock allocs
+func main() {
+	socketPath := flag.String("socket", "/tmp/elide-driver-admin.sock", "path to the plugin admin socket")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [-socket path] <export|import> -file path | allocs\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	cmd := flag.NewFlagSet(flag.Arg(0), flag.ExitOnError)
+	file := cmd.String("file", "elide-driver-snapshot.json", "snapshot file")
+	cmd.Parse(flag.Args()[1:])
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", *socketPath)
+			},
+		},
+	}
+
+	switch flag.Arg(0) {
+	case "export":
+		resp, err := client.Get("http://admin/v1/snapshot")
+		if err != nil {
+			log.Fatalf("Export failed: %v", err)
+		}
+		defer resp.Body.Close()
+		body := readBody(resp)
+
+		var snap driver.Snapshot
+		if err := json.Unmarshal(body, &snap); err != nil {
+			log.Fatalf("Export failed: invalid snapshot: %v", err)
+		}
+		if err := driver.WriteSnapshotFile(*file, &snap); err != nil {
+			log.Fatalf("Export failed: %v", err)
+		}
+		fmt.Printf("✓ Exported session %q with %d task(s) to %s\n", snap.SessionID, len(snap.Tasks), *file)
+
+	case "import":
+		snap, err := driver.ReadSnapshotFile(*file)
+		if err != nil {
+			log.Fatalf("Import failed: %v", err)
+		}
+		data, err := json.Marshal(snap)
+		if err != nil {
+			log.Fatalf("Import failed: %v", err)
+		}
+		req, err := http.NewRequest(http.MethodPut, "http://admin/v1/snapshot", bytes.NewReader(data))
+		if err != nil {
+			log.Fatalf("Import failed: %v", err)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			log.Fatalf("Import failed: %v", err)
+		}
+		defer resp.Body.Close()
+
+		var result struct {
+			Restored int    `json:"restored"`
+			Error    string `json:"error"`
+		}
+		if err := json.Unmarshal(readBody(resp), &result); err != nil {
+			log.Fatalf("Import failed: invalid response: %v", err)
+		}
+		if result.Error != "" {
+			log.Fatalf("Import partially failed (%d task(s) restored): %s", result.Restored, result.Error)
+		}
+		fmt.Printf("✓ Imported %d task(s) from %s\n", result.Restored, *file)
+
+	case "allocs":
+		resp, err := client.Get("http://admin/v1/debug/allocs")
+		if err != nil {
+			log.Fatalf("Listing allocs failed: %v", err)
+		}
+		defer resp.Body.Close()
+
+		var allocs []*driver.AllocUsage
+		if err := json.Unmarshal(readBody(resp), &allocs); err != nil {
+			log.Fatalf("Listing allocs failed: invalid response: %v", err)
+		}
+		fmt.Printf("%-36s  %-24s  %10s  %7s  %6s\n", "ALLOC", "JOB", "EXECUTIONS", "RUNNING", "FAILED")
+		for _, a := range allocs {
+			fmt.Printf("%-36s  %-24s  %10d  %7d  %6d\n", a.AllocID, a.Namespace+"/"+a.JobID, a.Executions, a.Running, a.Failed)
+		}
+
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
+
+// readBody returns the response body, exiting on transport or HTTP errors.
+func readBody(resp *http.Response) []byte {
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		log.Fatalf("Failed to read response: %v", err)
+	}
+	if resp.StatusCode >= 400 && resp.StatusCode != http.StatusConflict {
+		log.Fatalf("Admin API returned %s: %s", resp.Status, bytes.TrimSpace(body))
+	}
+	return body
+}
@@ -0,0 +1,51 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	pb "github.com/elide-dev/elide-task-driver/proto/gen/go/elide/daemon/v1alpha1"
+	"github.com/elide-dev/elide-task-driver/tests/conformance"
+)
+
+func runConformance(args []string) error {
+	defaults := conformance.DefaultOptions()
+
+	flags := flag.NewFlagSet("conformance", flag.ExitOnError)
+	socketPath := flags.String("socket", "", "Unix socket of the daemon")
+	address := flags.String("address", "", "TCP address of the daemon (used if -socket is empty)")
+	language := flags.String("language", defaults.Language, "language of the test snippets")
+	code := flags.String("code", defaults.Code, "snippet that completes successfully and prints to stdout")
+	longCode := flags.String("long-code", defaults.LongRunningCode, "snippet that runs long enough to be cancelled")
+	timeout := flags.Duration("timeout", defaults.CompletionTimeout, "maximum time to wait for an execution to complete")
+	flags.Parse(args)
+
+	if *socketPath == "" && *address == "" {
+		*socketPath = "/tmp/elide-daemon.sock"
+	}
+
+	conn, err := conformance.Dial(*socketPath, *address)
+	if err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+	defer conn.Close()
+
+	opts := defaults
+	opts.Language = *language
+	opts.Code = *code
+	opts.LongRunningCode = *longCode
+	opts.CompletionTimeout = *timeout
+
+	report := conformance.Run(context.Background(), pb.NewExecutionApiClient(conn), opts)
+	report.Print(os.Stdout)
+	if !report.Passed() {
+		return errors.New("daemon failed required checks")
+	}
+	return nil
+}
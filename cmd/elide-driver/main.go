@@ -0,0 +1,268 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// Developer tooling for the Elide task driver.
+//
+//	elide-driver dev [-job examples/hello-python.nomad]
+//	elide-driver conformance [-socket /tmp/elide-daemon.sock | -address host:port]
+//	elide-driver session <plan|apply> [-socket /tmp/elide-driver-admin.sock]
+//
+// The dev command builds the plugin and the stubbed daemon, starts both
+// together with a `nomad agent -dev` pointed at the plugin, submits a sample
+// job and keeps everything running until interrupted.
+//
+// The conformance command runs the protocol conformance suite against a
+// daemon and exits non-zero if a required check fails.
+//
+// The session commands compare the running plugin's session with its config
+// through the plugin's admin API (admin_socket): plan prints what would
+// change, apply re-creates the session to match.
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	switch os.Args[1] {
+	case "dev":
+		if err := runDev(os.Args[2:]); err != nil {
+			log.Fatalf("dev: %v", err)
+		}
+	case "conformance":
+		if err := runConformance(os.Args[2:]); err != nil {
+			log.Fatalf("conformance: %v", err)
+		}
+	case "session":
+		if err := runSession(os.Args[2:]); err != nil {
+			log.Fatalf("session: %v", err)
+		}
+	default:
+		usage()
+	}
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s <dev|conformance|session> [options]\n", filepath.Base(os.Args[0]))
+	os.Exit(2)
+}
+
+// devAgentConfig is the Nomad agent config written for the dev harness
+const devAgentConfig = `# Generated by elide-driver dev
+plugin_dir = %q
+
+plugin "elide" {
+  config {
+    daemon_socket = %q
+
+    session_config {
+      context_pool_size  = 10
+      enabled_languages  = ["python", "javascript", "typescript"]
+      enabled_intrinsics = ["io", "env"]
+      memory_limit_mb    = 512
+      enable_ai          = false
+    }
+  }
+}
+`
+
+func runDev(args []string) error {
+	flags := flag.NewFlagSet("dev", flag.ExitOnError)
+	root := flags.String("root", ".", "path to the elide-task-driver checkout")
+	job := flags.String("job", "examples/hello-python.nomad", "job to submit once the driver is healthy (empty to skip)")
+	nomadBin := flags.String("nomad", "nomad", "nomad binary")
+	nomadAddr := flags.String("nomad-addr", "http://127.0.0.1:4646", "address of the dev Nomad agent")
+	keep := flags.Bool("keep-dir", false, "keep the temporary directory on exit")
+	flags.Parse(args)
+
+	if _, err := exec.LookPath(*nomadBin); err != nil {
+		return fmt.Errorf("nomad binary not found: %w", err)
+	}
+
+	dir, err := os.MkdirTemp("", "elide-dev-")
+	if err != nil {
+		return fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	if *keep {
+		log.Printf("Working directory: %s", dir)
+	} else {
+		defer os.RemoveAll(dir)
+	}
+
+	pluginDir := filepath.Join(dir, "plugins")
+	socketPath := filepath.Join(dir, "elide-daemon.sock")
+	stubBin := filepath.Join(dir, "elide-stub-daemon")
+	configPath := filepath.Join(dir, "agent.hcl")
+
+	// Build the plugin (named after the driver so Nomad finds it) and the stub
+	log.Println("Building plugin and stubbed daemon...")
+	if err := goBuild(*root, filepath.Join(pluginDir, "elide"), "."); err != nil {
+		return err
+	}
+	if err := goBuild(*root, stubBin, "./cmd/server"); err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(configPath, []byte(fmt.Sprintf(devAgentConfig, pluginDir, socketPath)), 0644); err != nil {
+		return fmt.Errorf("failed to write agent config: %w", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Start the stubbed daemon and wait for its socket
+	stub := exec.Command(stubBin)
+	stub.Env = append(os.Environ(), "ELIDE_DAEMON_SOCKET="+socketPath)
+	if err := startLogged(stub, filepath.Join(dir, "stub-daemon.log")); err != nil {
+		return fmt.Errorf("failed to start stubbed daemon: %w", err)
+	}
+	defer terminate(stub)
+
+	if err := waitFor(ctx, 10*time.Second, func() bool {
+		_, err := os.Stat(socketPath)
+		return err == nil
+	}); err != nil {
+		return fmt.Errorf("stubbed daemon did not create %s: %w", socketPath, err)
+	}
+	log.Printf("✓ Stubbed daemon listening on %s", socketPath)
+
+	// Start Nomad in dev mode and wait for the driver to become healthy
+	nomad := exec.Command(*nomadBin, "agent", "-dev", "-config="+configPath)
+	if err := startLogged(nomad, filepath.Join(dir, "nomad.log")); err != nil {
+		return fmt.Errorf("failed to start nomad: %w", err)
+	}
+	defer terminate(nomad)
+
+	if err := waitFor(ctx, 60*time.Second, func() bool {
+		return driverHealthy(*nomadAddr)
+	}); err != nil {
+		return fmt.Errorf("elide driver did not become healthy (see %s): %w", filepath.Join(dir, "nomad.log"), err)
+	}
+	log.Printf("✓ Nomad dev agent running at %s with the elide driver healthy", *nomadAddr)
+
+	if *job != "" {
+		run := exec.CommandContext(ctx, *nomadBin, "job", "run", "-address="+*nomadAddr, filepath.Join(*root, *job))
+		run.Stdout, run.Stderr = os.Stdout, os.Stderr
+		if err := run.Run(); err != nil {
+			log.Printf("Job submission failed: %v", err)
+		}
+	}
+
+	log.Printf("Logs are in %s. Press Ctrl-C to stop.", dir)
+	<-ctx.Done()
+	log.Println("Shutting down...")
+	return nil
+}
+
+// goBuild builds a package of the checkout at root into out.
+func goBuild(root string, out string, pkg string) error {
+	cmd := exec.Command("go", "build", "-o", out, pkg)
+	cmd.Dir = root
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to build %s: %w", pkg, err)
+	}
+	return nil
+}
+
+// startLogged starts cmd with its output redirected to logPath.
+func startLogged(cmd *exec.Cmd, logPath string) error {
+	f, err := os.Create(logPath)
+	if err != nil {
+		return err
+	}
+	cmd.Stdout, cmd.Stderr = f, f
+	return cmd.Start()
+}
+
+// terminate asks a process to exit and kills it if it does not.
+func terminate(cmd *exec.Cmd) {
+	if cmd.Process == nil {
+		return
+	}
+	cmd.Process.Signal(syscall.SIGTERM)
+
+	done := make(chan struct{})
+	go func() {
+		cmd.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		cmd.Process.Kill()
+	}
+}
+
+// waitFor polls cond until it returns true, the timeout expires or ctx is done.
+func waitFor(ctx context.Context, timeout time.Duration, cond func() bool) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		if cond() {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// driverHealthy reports whether the local Nomad node fingerprinted the elide
+// driver as healthy.
+func driverHealthy(nomadAddr string) bool {
+	client := &http.Client{Timeout: 2 * time.Second}
+
+	resp, err := client.Get(nomadAddr + "/v1/agent/self")
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+
+	var self struct {
+		Stats struct {
+			Client struct {
+				NodeID string `json:"node_id"`
+			} `json:"client"`
+		} `json:"stats"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&self); err != nil || self.Stats.Client.NodeID == "" {
+		return false
+	}
+
+	nodeResp, err := client.Get(nomadAddr + "/v1/node/" + self.Stats.Client.NodeID)
+	if err != nil {
+		return false
+	}
+	defer nodeResp.Body.Close()
+
+	var node struct {
+		Drivers map[string]struct {
+			Healthy bool
+		}
+	}
+	if err := json.NewDecoder(nodeResp.Body).Decode(&node); err != nil {
+		return false
+	}
+	return node.Drivers["elide"].Healthy
+}
@@ -0,0 +1,110 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/elide-dev/elide-task-driver/driver"
+)
+
+// runSession plans or applies the reconciliation of the plugin's default
+// session with its config, through the plugin's admin API.
+func runSession(args []string) error {
+	if len(args) < 1 || (args[0] != "plan" && args[0] != "apply") {
+		return errors.New("usage: session <plan|apply> [-socket path]")
+	}
+
+	flags := flag.NewFlagSet("session "+args[0], flag.ExitOnError)
+	socketPath := flags.String("socket", "/tmp/elide-driver-admin.sock", "path to the plugin admin socket")
+	flags.Parse(args[1:])
+
+	client := &http.Client{
+		Timeout: 30 * time.Second,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", *socketPath)
+			},
+		},
+	}
+
+	var resp *http.Response
+	var err error
+	if args[0] == "plan" {
+		resp, err = client.Get("http://admin/v1/session/plan")
+	} else {
+		resp, err = client.Post("http://admin/v1/session/apply", "application/json", nil)
+	}
+	if err != nil {
+		return fmt.Errorf("admin API unreachable: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		var failure struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(body, &failure) == nil && failure.Error != "" {
+			return errors.New(failure.Error)
+		}
+		return fmt.Errorf("admin API returned %s: %s", resp.Status, bytes.TrimSpace(body))
+	}
+
+	var plan driver.SessionPlan
+	if err := json.Unmarshal(body, &plan); err != nil {
+		return fmt.Errorf("invalid response: %w", err)
+	}
+	printSessionPlan(&plan, args[0] == "apply")
+	return nil
+}
+
+// printSessionPlan prints a session plan as a diff of the session
+// configuration.
+func printSessionPlan(plan *driver.SessionPlan, applied bool) {
+	out := os.Stdout
+	switch plan.Action {
+	case driver.PlanNoop:
+		fmt.Fprintf(out, "No changes. Session %s matches the plugin config.\n", plan.SessionID)
+		return
+	case driver.PlanCreate:
+		if plan.SessionID == "" {
+			fmt.Fprintln(out, "+ session (none yet)")
+		} else {
+			fmt.Fprintf(out, "+ session (replaces %s)\n", plan.SessionID)
+		}
+	case driver.PlanReplace:
+		fmt.Fprintf(out, "-/+ session %s (must be replaced)\n", plan.SessionID)
+	}
+
+	for _, c := range plan.Changes {
+		switch c.Op {
+		case driver.ChangeAdd:
+			fmt.Fprintf(out, "    + %s: %q\n", c.Field, c.Desired)
+		case driver.ChangeRemove:
+			fmt.Fprintf(out, "    - %s: %q\n", c.Field, c.Current)
+		default:
+			fmt.Fprintf(out, "    ~ %s: %s -> %s\n", c.Field, c.Current, c.Desired)
+		}
+	}
+
+	if applied {
+		fmt.Fprintf(out, "\nApplied: %d change(s), new tasks run in session %s.\n", len(plan.Changes), plan.NewSessionID)
+		return
+	}
+	fmt.Fprintf(out, "\nPlan: %d change(s). Running tasks finish in the current session; run `session apply` to execute it.\n", len(plan.Changes))
+}
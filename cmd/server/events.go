@@ -0,0 +1,98 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/elide-dev/elide-task-driver/proto/gen/go/elide/daemon/v1alpha1"
+)
+
+// eventBufferSize is how many events a slow subscriber may fall behind
+// before further events are dropped for it
+const eventBufferSize = 64
+
+// eventBus fans session lifecycle events out to WatchSessionEvents streams.
+type eventBus struct {
+	mu sync.Mutex
+	// subscribers maps each stream's channel to the session it watches
+	// ("" for all sessions)
+	subscribers map[chan *pb.SessionEvent]string
+}
+
+// subscribe registers a stream for the events of a session (or all sessions).
+func (b *eventBus) subscribe(sessionID string) chan *pb.SessionEvent {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.subscribers == nil {
+		b.subscribers = map[chan *pb.SessionEvent]string{}
+	}
+	events := make(chan *pb.SessionEvent, eventBufferSize)
+	b.subscribers[events] = sessionID
+	return events
+}
+
+// unsubscribe removes a stream registered with subscribe.
+func (b *eventBus) unsubscribe(events chan *pb.SessionEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.subscribers, events)
+}
+
+// publish delivers an event to the streams watching its session without
+// blocking; subscribers that fell too far behind miss it.
+func (b *eventBus) publish(eventType pb.SessionEventType, sessionID string, executionID string, message string, details map[string]string) {
+	event := &pb.SessionEvent{
+		Type:        eventType,
+		SessionId:   sessionID,
+		ExecutionId: executionID,
+		Timestamp:   time.Now().UnixMilli(),
+		Message:     message,
+		Details:     details,
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for events, watched := range b.subscribers {
+		if watched != "" && watched != sessionID {
+			continue
+		}
+		select {
+		case events <- event:
+		default:
+			log.Printf("Dropped %s event for a slow subscriber", eventType)
+		}
+	}
+}
+
+// WatchSessionEvents streams session lifecycle events until the client
+// cancels
+func (s *stubbedServer) WatchSessionEvents(req *pb.WatchSessionEventsRequest, stream pb.ExecutionApi_WatchSessionEventsServer) error {
+	if s.noEvents {
+		return status.Error(codes.Unimplemented, "method WatchSessionEvents not implemented")
+	}
+
+	events := s.events.subscribe(req.SessionId)
+	defer s.events.unsubscribe(events)
+	log.Printf("Watching session events (session: %q)", req.SessionId)
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case event := <-events:
+			if err := stream.Send(event); err != nil {
+				return err
+			}
+		}
+	}
+}
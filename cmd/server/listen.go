@@ -0,0 +1,109 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// endpoint is where the stub listens: a Unix socket or a TCP address
+type endpoint struct {
+	network string
+	address string
+}
+
+// parseEndpoint parses a -listen value: "tcp://host:port", "unix:///path" or
+// a bare socket path.
+func parseEndpoint(value string) (endpoint, error) {
+	switch {
+	case strings.HasPrefix(value, "tcp://"):
+		address := strings.TrimPrefix(value, "tcp://")
+		if _, _, err := net.SplitHostPort(address); err != nil {
+			return endpoint{}, fmt.Errorf("invalid TCP address %q: %w", address, err)
+		}
+		return endpoint{network: "tcp", address: address}, nil
+	case strings.HasPrefix(value, "unix://"):
+		value = strings.TrimPrefix(value, "unix://")
+	case strings.Contains(value, "://"):
+		return endpoint{}, fmt.Errorf("unsupported listen address %q (must be tcp://host:port or unix:///path)", value)
+	}
+	if value == "" {
+		return endpoint{}, errors.New("empty socket path")
+	}
+	return endpoint{network: "unix", address: value}, nil
+}
+
+func (e endpoint) String() string {
+	if e.network == "tcp" {
+		return "tcp://" + e.address
+	}
+	return e.address
+}
+
+// listen listens on the endpoint, replacing a stale Unix socket and making
+// it accessible to every user.
+func (e endpoint) listen() (net.Listener, error) {
+	if e.network == "unix" {
+		os.Remove(e.address)
+	}
+	lis, err := net.Listen(e.network, e.address)
+	if err != nil {
+		return nil, err
+	}
+	if e.network == "unix" {
+		os.Chmod(e.address, 0666)
+	}
+	return lis, nil
+}
+
+// close removes the Unix socket of the endpoint.
+func (e endpoint) close() {
+	if e.network == "unix" {
+		os.Remove(e.address)
+	}
+}
+
+// serverTLS returns the transport credentials of the stub: TLS with the
+// certificate and key, and mutual TLS if clients must present a certificate
+// signed by the client CA. It returns nil if no certificate is given.
+func serverTLS(certFile string, keyFile string, clientCAFile string) (grpc.ServerOption, error) {
+	if certFile == "" && keyFile == "" {
+		if clientCAFile != "" {
+			return nil, errors.New("-tls-client-ca requires -tls-cert and -tls-key")
+		}
+		return nil, nil
+	}
+	if certFile == "" || keyFile == "" {
+		return nil, errors.New("-tls-cert and -tls-key must be specified together")
+	}
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if clientCAFile != "" {
+		pem, err := os.ReadFile(clientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client CA: %w", err)
+		}
+		config.ClientCAs = x509.NewCertPool()
+		if !config.ClientCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in client CA %s", clientCAFile)
+		}
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return grpc.Creds(credentials.NewTLS(config)), nil
+}
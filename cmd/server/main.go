@@ -5,20 +5,44 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"log"
-	"net"
 	"os"
 	"os/signal"
+	"slices"
+	"strconv"
 	"sync"
 	"syscall"
 	"time"
+	"unicode/utf8"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 
 	pb "github.com/elide-dev/elide-task-driver/proto/gen/go/elide/daemon/v1alpha1"
 )
 
+const (
+	// maxRecvMsgBytes is the largest request the stub accepts
+	maxRecvMsgBytes = 4 << 20
+
+	// maxCodeBytes is the largest code payload the stub advertises, leaving
+	// room in the request for env vars and args
+	maxCodeBytes = maxRecvMsgBytes - 256<<10
+
+	// stubMaxMemoryMB is the session memory the stub advertises
+	stubMaxMemoryMB = 4096
+
+	// stubMemoryEnvVar in an execution's environment overrides the memory
+	// it is simulated to use, in MB
+	stubMemoryEnvVar = "ELIDE_STUB_MEMORY_MB"
+)
+
 // Stubbed server implementation for testing
 type stubbedServer struct {
 	pb.UnimplementedExecutionApiServer
@@ -26,6 +50,56 @@ type stubbedServer struct {
 	mu       sync.RWMutex
 	sessions map[string]*Session
 	executions map[string]*Execution
+
+	// maxExecutions sheds load above this many running executions (0 = unlimited)
+	maxExecutions int
+	// retryAfter is the backoff hint sent with shed requests
+	retryAfter time.Duration
+	// noWatch rejects WatchExecution as unimplemented, like daemons
+	// without status streaming
+	noWatch bool
+	// noRunAs reports no support for running executions as another user
+	noRunAs bool
+	// executionMemoryMB is the memory every simulated execution uses;
+	// executions with a lower memory limit are killed as OOM
+	executionMemoryMB int
+	// noEvents rejects WatchSessionEvents as unimplemented, like daemons
+	// without session events
+	noEvents bool
+	// noSignals reports no support for signalling executions
+	noSignals bool
+	// mounts reports support for mounting task directories into executions
+	mounts bool
+	// noOutputOffsets ignores include_output and the output offsets of
+	// status requests, like daemons predating them
+	noOutputOffsets bool
+	// noExecInSession rejects ExecInSession as unimplemented, like daemons
+	// predating it
+	noExecInSession bool
+	// noWorkspaces rejects UploadWorkspace as unimplemented, like daemons
+	// predating multi-file snippets
+	noWorkspaces bool
+	// noStdin rejects OpenStdin as unimplemented, like daemons predating
+	// streamed stdin
+	noStdin bool
+	// noPortBindings rejects executions requesting ports, like daemons
+	// predating port bindings
+	noPortBindings bool
+	// noOutputOrder ignores include_output_order, like daemons predating
+	// output segments
+	noOutputOrder bool
+	// queueExecutions queues executions beyond the context pool of their
+	// session until a context is free, instead of rejecting them
+	queueExecutions bool
+	// binaryOutput appends binary bytes to the stdout of completed
+	// executions, like snippets writing binary artifacts to stdout
+	binaryOutput bool
+
+	// simulation configures how executions behave
+	simulation *simulation
+
+	// events publishes session lifecycle events to WatchSessionEvents
+	events eventBus
 }
 
 type Session struct {
@@ -33,6 +107,20 @@ type Session struct {
 	Status    pb.SessionStatus
 	Config    *pb.SessionConfiguration
 	CreatedAt int64
+	Values    map[string]string
+
+	// Workspaces holds the uploaded workspaces, by ID, as file contents by
+	// path
+	Workspaces map[string]map[string][]byte
+}
+
+// terminatingSignals end an execution when signalled, with exit code 128 plus
+// the signal number
+var terminatingSignals = map[string]int32{
+	"SIGINT":  2,
+	"SIGQUIT": 3,
+	"SIGKILL": 9,
+	"SIGTERM": 15,
 }
 
 type Execution struct {
@@ -44,35 +132,133 @@ type Execution struct {
 	Stdout    string
 	Stderr    string
 	Error     string
+	Message   string
+	Result    string
 	CreatedAt time.Time
+
+	CancellationReason pb.CancellationReason
+	CancelledBy        string
+	CancelToken        string // token of the request that cancelled it
+
+	// Signals delivered while running, echoed into the output on completion
+	Signals []string
+
+	// Whether stdin is streamed with OpenStdin, and the stdin received,
+	// echoed into the output on completion
+	StdinOpen bool
+	Stdin     []byte
+
+	// Ports bound for the execution while it runs
+	Ports []*pb.PortBinding
+
+	// The code run, its language and whether its output is discarded
+	code          string
+	language      string
+	discardOutput bool
+
+	// Segments records the writes to stdout and stderr in order
+	Segments []*pb.OutputSegment
+
+	// plan is how the execution behaves, drawn from the simulation
+	plan executionPlan
+	// cancelPending is set while a cancellation waits out the simulated
+	// cancel delay
+	cancelPending bool
 }
 
 func main() {
-	// Default to Unix socket, can override with env var
+	// Default to Unix socket, can override with env var or -listen
 	socketPath := os.Getenv("ELIDE_DAEMON_SOCKET")
 	if socketPath == "" {
 		socketPath = "/tmp/elide-daemon.sock"
 	}
+	listenAddr := flag.String("listen", socketPath, "where to listen: tcp://host:port, unix:///path or a socket path")
+	tlsCert := flag.String("tls-cert", "", "PEM server certificate, serving TLS")
+	tlsKey := flag.String("tls-key", "", "PEM key of -tls-cert")
+	tlsClientCA := flag.String("tls-client-ca", "", "PEM CA bundle client certificates must be signed by, requiring mutual TLS")
+	flag.Parse()
 
-	// Remove existing socket if present
-	os.Remove(socketPath)
+	endpoint, err := parseEndpoint(*listenAddr)
+	if err != nil {
+		log.Fatalf("invalid -listen: %v", err)
+	}
+	creds, err := serverTLS(*tlsCert, *tlsKey, *tlsClientCA)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	// Create Unix socket listener
-	lis, err := net.Listen("unix", socketPath)
+	lis, err := endpoint.listen()
 	if err != nil {
 		log.Fatalf("failed to listen: %v", err)
 	}
 
-	// Set socket permissions
-	os.Chmod(socketPath, 0666)
+	// Optional load shedding: reject executions above a running count with
+	// ResourceExhausted and a retry-after hint
+	maxExecutions, err := envInt("ELIDE_STUB_MAX_EXECUTIONS", 0)
+	if err != nil {
+		log.Fatalf("invalid ELIDE_STUB_MAX_EXECUTIONS: %v", err)
+	}
+	retryAfterSeconds, err := envInt("ELIDE_STUB_RETRY_AFTER", 1)
+	if err != nil {
+		log.Fatalf("invalid ELIDE_STUB_RETRY_AFTER: %v", err)
+	}
 
-	grpcServer := grpc.NewServer()
+	// Simulated memory use of each execution, checked against memory limits
+	executionMemoryMB, err := envInt("ELIDE_STUB_EXECUTION_MEMORY_MB", 64)
+	if err != nil {
+		log.Fatalf("invalid ELIDE_STUB_EXECUTION_MEMORY_MB: %v", err)
+	}
+
+	// How executions behave: their run times, failures and output
+	simulation, profile, err := loadSimulation()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	serverOpts := []grpc.ServerOption{grpc.MaxRecvMsgSize(maxRecvMsgBytes)}
+	if creds != nil {
+		serverOpts = append(serverOpts, creds)
+	}
+	grpcServer := grpc.NewServer(serverOpts...)
 	pb.RegisterExecutionApiServer(grpcServer, &stubbedServer{
-		sessions:   make(map[string]*Session),
-		executions: make(map[string]*Execution),
+		sessions:      make(map[string]*Session),
+		executions:    make(map[string]*Execution),
+		maxExecutions: maxExecutions,
+		retryAfter:    time.Duration(retryAfterSeconds) * time.Second,
+		noWatch:       os.Getenv("ELIDE_STUB_NO_WATCH") != "",
+		noRunAs:       os.Getenv("ELIDE_STUB_NO_RUN_AS") != "",
+		noEvents:      os.Getenv("ELIDE_STUB_NO_EVENTS") != "",
+		noSignals:     os.Getenv("ELIDE_STUB_NO_SIGNALS") != "",
+		mounts:        os.Getenv("ELIDE_STUB_MOUNTS") != "",
+
+		noOutputOffsets: os.Getenv("ELIDE_STUB_NO_OUTPUT_OFFSETS") != "",
+		noExecInSession: os.Getenv("ELIDE_STUB_NO_EXEC_IN_SESSION") != "",
+		noWorkspaces:    os.Getenv("ELIDE_STUB_NO_WORKSPACES") != "",
+		noStdin:         os.Getenv("ELIDE_STUB_NO_STDIN") != "",
+		noPortBindings:  os.Getenv("ELIDE_STUB_NO_PORT_BINDINGS") != "",
+		noOutputOrder:   os.Getenv("ELIDE_STUB_NO_OUTPUT_ORDER") != "",
+		binaryOutput:    os.Getenv("ELIDE_STUB_BINARY_OUTPUT") != "",
+		queueExecutions: os.Getenv("ELIDE_STUB_QUEUE") != "",
+
+		executionMemoryMB: executionMemoryMB,
+		simulation:        simulation,
 	})
+	log.Printf("Simulating executions with the %s profile (%s)", profile, simulation)
+	if maxExecutions > 0 {
+		log.Printf("Shedding load above %d running executions (retry after %ds)", maxExecutions, retryAfterSeconds)
+	}
 
-	log.Printf("Stubbed Elide daemon server listening on %s", socketPath)
+	// Enable server reflection so tools like grpcurl can discover the API
+	reflection.Register(grpcServer)
+
+	switch {
+	case *tlsClientCA != "":
+		log.Printf("Stubbed Elide daemon server listening on %s (mutual TLS)", endpoint)
+	case creds != nil:
+		log.Printf("Stubbed Elide daemon server listening on %s (TLS)", endpoint)
+	default:
+		log.Printf("Stubbed Elide daemon server listening on %s", endpoint)
+	}
 
 	// Handle graceful shutdown
 	c := make(chan os.Signal, 1)
@@ -81,7 +267,7 @@ func main() {
 		<-c
 		log.Println("Shutting down server...")
 		grpcServer.GracefulStop()
-		os.Remove(socketPath)
+		endpoint.close()
 		os.Exit(0)
 	}()
 
@@ -90,20 +276,87 @@ func main() {
 	}
 }
 
+// envInt reads an integer from the environment, returning def if unset.
+func envInt(name string, def int) (int, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return def, nil
+	}
+	return strconv.Atoi(value)
+}
+
+// sessionExecutions counts the executions of a session that have not
+// completed, each holding one of its contexts. Callers must hold s.mu.
+func (s *stubbedServer) sessionExecutions(sessionID string) int {
+	running := 0
+	for _, exec := range s.executions {
+		if exec.SessionID == sessionID && !exec.Complete {
+			running++
+		}
+	}
+	return running
+}
+
+// queueStatus returns the position of a queued execution in its session's
+// queue, from 1, and the executions it waits for: those queued before it and
+// those running in the session. Both are 0 unless it is queued. Callers must
+// hold s.mu.
+func (s *stubbedServer) queueStatus(exec *Execution) (position uint32, ahead uint32) {
+	if exec.Status != pb.ExecutionStatus_EXECUTION_STATUS_QUEUED {
+		return 0, 0
+	}
+	position = 1
+	for _, other := range s.executions {
+		if other == exec || other.SessionID != exec.SessionID || other.Complete {
+			continue
+		}
+		switch {
+		case other.Status == pb.ExecutionStatus_EXECUTION_STATUS_RUNNING:
+			ahead++
+		case other.CreatedAt.Before(exec.CreatedAt) || (other.CreatedAt.Equal(exec.CreatedAt) && other.ID < exec.ID):
+			position++
+			ahead++
+		}
+	}
+	return position, ahead
+}
+
+// runningExecutions counts executions that have not completed. Callers must
+// hold s.mu.
+func (s *stubbedServer) runningExecutions() int {
+	running := 0
+	for _, exec := range s.executions {
+		if !exec.Complete {
+			running++
+		}
+	}
+	return running
+}
+
 // CreateSession creates a new session with mocked response
 func (s *stubbedServer) CreateSession(ctx context.Context, req *pb.CreateSessionRequest) (*pb.CreateSessionResponse, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	if _, ok := s.sessions[req.SessionId]; ok {
+		return nil, status.Errorf(codes.AlreadyExists, "session already exists: %s", req.SessionId)
+	}
 	session := &Session{
 		ID:        req.SessionId,
 		Status:    pb.SessionStatus_SESSION_STATUS_ACTIVE,
 		Config:    req.Config,
 		CreatedAt: time.Now().Unix(),
+		Values:    make(map[string]string),
+
+		Workspaces: make(map[string]map[string][]byte),
 	}
 	s.sessions[req.SessionId] = session
 
-	log.Printf("Created session: %s", req.SessionId)
+	log.Printf("Created session: %s (tags: %v)", req.SessionId, req.Config.GetTags())
+	s.events.publish(pb.SessionEventType_SESSION_EVENT_TYPE_SESSION_CREATED, req.SessionId, "", "session created", nil)
+	for _, snippet := range req.Config.GetInitSnippets() {
+		log.Printf("  Init snippet (%s, %d bytes)", snippet.Language, len(snippet.Code))
+	}
 
 	return &pb.CreateSessionResponse{
 		SessionId: session.ID,
@@ -119,7 +372,7 @@ func (s *stubbedServer) GetSession(ctx context.Context, req *pb.GetSessionReques
 
 	session, ok := s.sessions[req.SessionId]
 	if !ok {
-		return nil, fmt.Errorf("session not found: %s", req.SessionId)
+		return nil, status.Errorf(codes.NotFound, "session not found: %s", req.SessionId)
 	}
 
 	return &pb.GetSessionResponse{
@@ -137,24 +390,188 @@ func (s *stubbedServer) DeleteSession(ctx context.Context, req *pb.DeleteSession
 
 	_, ok := s.sessions[req.SessionId]
 	if !ok {
-		return nil, fmt.Errorf("session not found: %s", req.SessionId)
+		return nil, status.Errorf(codes.NotFound, "session not found: %s", req.SessionId)
 	}
 
 	delete(s.sessions, req.SessionId)
 	log.Printf("Deleted session: %s", req.SessionId)
+	s.events.publish(pb.SessionEventType_SESSION_EVENT_TYPE_SESSION_CLOSED, req.SessionId, "", "session closed", nil)
 
 	return &pb.DeleteSessionResponse{Success: true}, nil
 }
 
-// ExecuteSnippet executes a snippet with mocked response
+// RecycleContexts pretends to replace the interpreter contexts of a session;
+// contexts running an execution count as pending
+func (s *stubbedServer) RecycleContexts(ctx context.Context, req *pb.RecycleContextsRequest) (*pb.RecycleContextsResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[req.SessionId]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "session not found: %s", req.SessionId)
+	}
+
+	var pending uint32
+	for _, exec := range s.executions {
+		if exec.SessionID == req.SessionId && !exec.Complete {
+			pending++
+		}
+	}
+	pending = min(pending, session.Config.GetContextPoolSize())
+	recycled := session.Config.GetContextPoolSize() - pending
+	log.Printf("Recycled contexts of session: %s (%d recycled, %d pending)", req.SessionId, recycled, pending)
+	s.events.publish(pb.SessionEventType_SESSION_EVENT_TYPE_CONTEXTS_RECYCLED, req.SessionId, "",
+		fmt.Sprintf("%d contexts recycled, %d pending", recycled, pending),
+		map[string]string{"recycled": strconv.Itoa(int(recycled)), "pending": strconv.Itoa(int(pending))})
+
+	return &pb.RecycleContextsResponse{Recycled: recycled, Pending: pending}, nil
+}
+
+// PutSessionValue stores a value in the session key-value store
+func (s *stubbedServer) PutSessionValue(ctx context.Context, req *pb.PutSessionValueRequest) (*pb.PutSessionValueResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[req.SessionId]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "session not found: %s", req.SessionId)
+	}
+	if req.Key == "" {
+		return nil, status.Error(codes.InvalidArgument, "key must not be empty")
+	}
+
+	session.Values[req.Key] = req.Value
+	log.Printf("Stored session value: %s in session: %s", req.Key, req.SessionId)
+
+	return &pb.PutSessionValueResponse{Success: true}, nil
+}
+
+// GetSessionValue reads a value from the session key-value store
+func (s *stubbedServer) GetSessionValue(ctx context.Context, req *pb.GetSessionValueRequest) (*pb.GetSessionValueResponse, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	session, ok := s.sessions[req.SessionId]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "session not found: %s", req.SessionId)
+	}
+
+	value, found := session.Values[req.Key]
+	return &pb.GetSessionValueResponse{Found: found, Value: value}, nil
+}
+
+// ExecuteSnippet executes a snippet with mocked response, waiting up to
+// completion_wait_ms for it to complete to report it complete right away
 func (s *stubbedServer) ExecuteSnippet(ctx context.Context, req *pb.ExecuteSnippetRequest) (*pb.ExecuteSnippetResponse, error) {
+	resp, err := s.executeSnippet(ctx, req)
+	if err != nil || req.CompletionWaitMs == 0 || req.Stdin {
+		return resp, err
+	}
+	resp.Completion = s.awaitCompletion(ctx, req.ExecutionId, time.Duration(req.CompletionWaitMs)*time.Millisecond)
+	if resp.Completion != nil {
+		resp.Status = resp.Completion.Status
+		log.Printf("  Completed within %dms", req.CompletionWaitMs)
+	}
+	return resp, nil
+}
+
+// awaitCompletion waits up to wait for an execution to complete, returning
+// its final status with all of its output, or nil if it still runs.
+func (s *stubbedServer) awaitCompletion(ctx context.Context, executionID string, wait time.Duration) *pb.GetExecutionStatusResponse {
+	ctx, cancel := context.WithTimeout(ctx, wait)
+	defer cancel()
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		s.mu.RLock()
+		exec, ok := s.executions[executionID]
+		var resp *pb.GetExecutionStatusResponse
+		if ok && exec.Complete {
+			resp = executionStatus(exec)
+			if !s.noOutputOrder {
+				resp.OutputSegments = segmentsFrom(exec, 0, 0)
+			}
+			encodeRawOutput(resp)
+		}
+		s.mu.RUnlock()
+		if resp != nil || !ok {
+			return resp
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// executeSnippet starts an execution of a snippet
+func (s *stubbedServer) executeSnippet(ctx context.Context, req *pb.ExecuteSnippetRequest) (*pb.ExecuteSnippetResponse, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	// Verify session exists
-	_, ok := s.sessions[req.SessionId]
+	session, ok := s.sessions[req.SessionId]
 	if !ok {
-		return nil, fmt.Errorf("session not found: %s", req.SessionId)
+		return nil, status.Errorf(codes.NotFound, "session not found: %s", req.SessionId)
+	}
+
+	// Shed load when over capacity, telling the client when to retry
+	if s.maxExecutions > 0 && s.runningExecutions() >= s.maxExecutions {
+		grpc.SetTrailer(ctx, metadata.Pairs(
+			"retry-after", strconv.Itoa(int(s.retryAfter.Seconds())),
+		))
+		log.Printf("Shedding execution: %s (%d running)", req.ExecutionId, s.maxExecutions)
+		return nil, status.Errorf(codes.ResourceExhausted, "daemon overloaded: %d executions running", s.maxExecutions)
+	}
+
+	// Only the session's languages can run, on one of its contexts
+	if languages := session.Config.GetEnabledLanguages(); len(languages) > 0 && !slices.Contains(languages, req.Language) {
+		return nil, status.Errorf(codes.InvalidArgument, "language not enabled in session: %s (enabled: %v)", req.Language, languages)
+	}
+	queued := false
+	if poolSize := int(session.Config.GetContextPoolSize()); poolSize > 0 && s.sessionExecutions(req.SessionId) >= poolSize {
+		if !s.queueExecutions {
+			grpc.SetTrailer(ctx, metadata.Pairs(
+				"retry-after", strconv.Itoa(int(s.retryAfter.Seconds())),
+			))
+			log.Printf("Rejecting execution: %s (context pool of session %s exhausted)", req.ExecutionId, req.SessionId)
+			return nil, status.Errorf(codes.ResourceExhausted, "context pool exhausted: %d executions running in session", poolSize)
+		}
+		queued = true
+	}
+
+	// An execution can claim to use more memory than others, to fail on its
+	// memory limit
+	memoryMB := s.executionMemoryMB
+	if value, ok := req.Env[stubMemoryEnvVar]; ok {
+		var err error
+		if memoryMB, err = strconv.Atoi(value); err != nil || memoryMB < 0 {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid %s %q", stubMemoryEnvVar, value)
+		}
+	}
+
+	// Per-execution intrinsics must be a subset of the session's
+	if req.Limits != nil {
+		for _, intrinsic := range req.Limits.Intrinsics {
+			if !slices.Contains(session.Config.GetEnabledIntrinsics(), intrinsic) {
+				return nil, status.Errorf(codes.InvalidArgument, "intrinsic not enabled in session: %s", intrinsic)
+			}
+		}
+	}
+
+	// Executions of a workspace run its entrypoint
+	code := req.Code
+	if req.Workspace != nil {
+		var err error
+		if code, err = session.workspaceCode(req.Workspace); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.bindPorts(req.Ports); err != nil {
+		return nil, err
 	}
 
 	// Create execution with mocked status
@@ -163,36 +580,201 @@ func (s *stubbedServer) ExecuteSnippet(ctx context.Context, req *pb.ExecuteSnipp
 		SessionID: req.SessionId,
 		Status:    pb.ExecutionStatus_EXECUTION_STATUS_RUNNING,
 		Complete:  false,
+		Message:   "running",
 		CreatedAt: time.Now(),
+		StdinOpen: req.Stdin,
+		Ports:     req.Ports,
+
+		code:          code,
+		language:      req.Language,
+		discardOutput: req.DiscardOutput,
+		plan:          s.simulation.plan(),
+	}
+	if queued {
+		exec.Status = pb.ExecutionStatus_EXECUTION_STATUS_QUEUED
+		exec.Message = "waiting for a context"
 	}
 	s.executions[req.ExecutionId] = exec
 
+	// Per-execution overrides take precedence over limits and the session
+	memoryLimitMB := int(session.Config.GetMemoryLimitMb())
+	if limit := req.Limits.GetMemoryLimitMb(); limit > 0 {
+		memoryLimitMB = int(limit)
+	}
+	var timeout time.Duration
+	if overrides := req.Overrides; overrides != nil {
+		if overrides.MemoryLimitMb != nil {
+			memoryLimitMB = int(overrides.GetMemoryLimitMb())
+		}
+		timeout = time.Duration(overrides.GetTimeoutMs()) * time.Millisecond
+	}
+	oom := memoryLimitMB > 0 && memoryMB > memoryLimitMB
+
 	// Simulate async execution completion
-	go s.simulateExecution(exec, req.Code, req.Language)
+	go s.simulateExecution(exec, oom, timeout)
 
-	log.Printf("Started execution: %s in session: %s", req.ExecutionId, req.SessionId)
+	log.Printf("Started execution: %s in session: %s (limits: %v, interpreter args: %v, tags: %v)", req.ExecutionId, req.SessionId, req.Limits, req.InterpreterArgs, req.Tags)
+	if req.Overrides != nil {
+		log.Printf("  Overrides: %v", req.Overrides)
+	}
+	s.events.publish(pb.SessionEventType_SESSION_EVENT_TYPE_EXECUTION_STARTED, req.SessionId, req.ExecutionId, "execution started",
+		map[string]string{"language": req.Language})
+	if req.Topology != nil {
+		log.Printf("  Topology hints: %v", req.Topology)
+	}
+	if req.RunAs != "" {
+		log.Printf("  Running as user: %s", req.RunAs)
+	}
+	for _, mount := range req.Mounts {
+		log.Printf("  Mount: %s -> %s (read-only: %t)", mount.HostPath, mount.TaskPath, mount.ReadOnly)
+	}
+	if req.Workspace != nil {
+		log.Printf("  Workspace: %s (entrypoint: %s)", req.Workspace.WorkspaceId, req.Workspace.Entrypoint)
+	}
+	for _, port := range req.Ports {
+		log.Printf("  Port: %s %s -> %d", port.Label, hostAddr(port), port.Port)
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok && len(md.Get("traceparent")) > 0 {
+		log.Printf("  Trace: %s", md.Get("traceparent")[0])
+	}
 
 	return &pb.ExecuteSnippetResponse{
 		ExecutionId: exec.ID,
 		SessionId:  exec.SessionID,
 		Status:     exec.Status,
+		QueuedAt:   exec.CreatedAt.UnixMilli(),
+		Ports:      exec.Ports,
 	}, nil
 }
 
-// simulateExecution simulates snippet execution with mocked results
-func (s *stubbedServer) simulateExecution(exec *Execution, code string, language string) {
-	// Simulate execution time
-	time.Sleep(2 * time.Second)
+// simulateExecution simulates snippet execution with mocked results, as
+// planned by the simulation. An execution over its memory limit is killed as
+// OOM after producing its first output; one outliving its timeout is
+// cancelled.
+func (s *stubbedServer) simulateExecution(exec *Execution, oom bool, timeout time.Duration) {
+	if !s.awaitContext(exec) {
+		return
+	}
+	started := time.Now()
+
+	// Simulate execution time, reporting the output as it is produced
+	plan := exec.plan
+	header := fmt.Sprintf("Mocked output for %s snippet:\n", exec.language)
+	step := plan.duration / time.Duration(plan.chunks)
+	for i := range plan.chunks {
+		s.mu.Lock()
+		if !exec.Complete {
+			exec.writeStdout(plan.output(header, exec.code, i))
+			if plan.stderr {
+				exec.writeStderr(fmt.Sprintf("warning: simulated diagnostic %d of %d\n", i+1, plan.chunks))
+			}
+		}
+		s.mu.Unlock()
+		if oom {
+			s.terminate(exec, pb.CancellationReason_CANCELLATION_REASON_OOM, "memory limit exceeded")
+			return
+		}
+		if remaining := time.Until(started.Add(timeout)); timeout > 0 && remaining < step {
+			time.Sleep(remaining)
+			s.terminate(exec, pb.CancellationReason_CANCELLATION_REASON_TIMEOUT, fmt.Sprintf("timeout of %s exceeded", timeout))
+			return
+		}
+		time.Sleep(step)
+	}
 
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	// Mock successful execution
+	// A cancelled execution stays cancelled
+	if exec.Complete {
+		return
+	}
+	s.complete(exec)
+}
+
+// awaitContext blocks a queued execution until it is first in its session's
+// queue and one of the session's contexts is free, then runs it. It returns
+// false if the execution completed while queued, i.e. was cancelled.
+func (s *stubbedServer) awaitContext(exec *Execution) bool {
+	for {
+		s.mu.Lock()
+		if exec.Complete {
+			s.mu.Unlock()
+			return false
+		}
+		position, ahead := s.queueStatus(exec)
+		var poolSize uint32
+		if session, ok := s.sessions[exec.SessionID]; ok {
+			poolSize = session.Config.GetContextPoolSize()
+		}
+		if position == 0 || poolSize == 0 || (position == 1 && ahead < poolSize) {
+			if position > 0 {
+				exec.Status = pb.ExecutionStatus_EXECUTION_STATUS_RUNNING
+				exec.Message = "running"
+				log.Printf("Dequeued execution: %s in session: %s", exec.ID, exec.SessionID)
+			}
+			s.mu.Unlock()
+			return true
+		}
+		s.mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// complete ends a running execution as planned: it fails, or completes with
+// the planned exit code. Callers must hold s.mu.
+func (s *stubbedServer) complete(exec *Execution) {
+	exec.Complete = true
+	stdout := fmt.Sprintf("Mocked output for %s snippet:\n", exec.language) + exec.code
+	for _, signal := range exec.Signals {
+		stdout += fmt.Sprintf("\nReceived signal: %s", signal)
+	}
+	if exec.StdinOpen {
+		stdout += fmt.Sprintf("\nRead %d bytes from stdin:\n%s", len(exec.Stdin), exec.Stdin)
+	}
+	if s.binaryOutput {
+		stdout += "\n" + stubBinaryOutput
+	}
+	exec.writeStdout(stdout)
+
+	if exec.plan.fail {
+		exec.Status = pb.ExecutionStatus_EXECUTION_STATUS_FAILED
+		exec.ExitCode = 1
+		exec.Message = "failed"
+		exec.Error = fmt.Sprintf("simulated failure of %s snippet", exec.language)
+		exec.writeStderr("error: " + exec.Error + "\n")
+		s.events.publish(pb.SessionEventType_SESSION_EVENT_TYPE_EXECUTION_FAILED, exec.SessionID, exec.ID, exec.Error,
+			map[string]string{"exit_code": "1"})
+		return
+	}
+
 	exec.Status = pb.ExecutionStatus_EXECUTION_STATUS_COMPLETED
+	exec.ExitCode = exec.plan.exitCode
+	exec.Message = "completed"
+	exec.Result = fmt.Sprintf(`{"language":%q,"code_bytes":%d}`, exec.language, len(exec.code))
+	s.events.publish(pb.SessionEventType_SESSION_EVENT_TYPE_EXECUTION_COMPLETED, exec.SessionID, exec.ID, "execution completed",
+		map[string]string{"exit_code": strconv.Itoa(int(exec.ExitCode))})
+}
+
+// terminate cancels a running execution on the daemon's own initiative
+func (s *stubbedServer) terminate(exec *Execution, reason pb.CancellationReason, message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if exec.Complete {
+		return
+	}
+	exec.Status = pb.ExecutionStatus_EXECUTION_STATUS_CANCELLED
 	exec.Complete = true
-	exec.ExitCode = 0
-	exec.Stdout = fmt.Sprintf("Mocked output for %s snippet:\n%s", language, code)
-	exec.Stderr = ""
+	exec.ExitCode = -1
+	exec.Error = message
+	exec.Message = "cancelled by daemon"
+	exec.CancellationReason = reason
+	exec.CancelledBy = "daemon"
+	s.events.publish(pb.SessionEventType_SESSION_EVENT_TYPE_EXECUTION_CANCELLED, exec.SessionID, exec.ID, message,
+		map[string]string{"reason": reason.String(), "initiator": exec.CancelledBy})
+
+	log.Printf("Terminated execution: %s (reason: %s, %s)", exec.ID, reason, message)
 }
 
 // GetExecutionStatus retrieves execution status
@@ -202,9 +784,145 @@ func (s *stubbedServer) GetExecutionStatus(ctx context.Context, req *pb.GetExecu
 
 	exec, ok := s.executions[req.ExecutionId]
 	if !ok {
-		return nil, fmt.Errorf("execution not found: %s", req.ExecutionId)
+		return nil, status.Errorf(codes.NotFound, "execution not found: %s", req.ExecutionId)
+	}
+
+	resp := executionStatus(exec)
+	resp.QueuePosition, resp.ExecutionsAhead = s.queueStatus(exec)
+	omitted := false
+	if !s.noOutputOffsets {
+		if req.IncludeOutput != nil && !*req.IncludeOutput {
+			resp.Stdout, resp.Stderr = "", ""
+			omitted = true
+		}
+		resp.Stdout = outputFrom(resp.Stdout, req.StdoutOffset)
+		resp.Stderr = outputFrom(resp.Stderr, req.StderrOffset)
+		if req.IncludeOutputOrder && !s.noOutputOrder && !omitted {
+			resp.OutputSegments = segmentsFrom(exec, req.StdoutOffset, req.StderrOffset)
+		}
+	} else if req.IncludeOutputOrder && !s.noOutputOrder {
+		resp.OutputSegments = segmentsFrom(exec, 0, 0)
+	}
+	encodeRawOutput(resp)
+	return resp, nil
+}
+
+// stubBinaryOutput is what executions write to stdout with
+// ELIDE_STUB_BINARY_OUTPUT: the PNG signature, which is not valid UTF-8
+const stubBinaryOutput = "\x89PNG\r\n\x1a\n"
+
+// encodeRawOutput moves output that is not valid UTF-8 to the raw output
+// fields, as proto strings must hold UTF-8.
+func encodeRawOutput(resp *pb.GetExecutionStatusResponse) {
+	if !utf8.ValidString(resp.Stdout) {
+		resp.StdoutRaw, resp.Stdout = []byte(resp.Stdout), ""
+	}
+	if !utf8.ValidString(resp.Stderr) {
+		resp.StderrRaw, resp.Stderr = []byte(resp.Stderr), ""
+	}
+}
+
+// writeStdout extends the stdout of an execution to output, which starts
+// with the stdout written so far, recording the write. Callers must hold
+// s.mu.
+func (exec *Execution) writeStdout(output string) {
+	if exec.discardOutput || len(output) <= len(exec.Stdout) {
+		return
+	}
+	exec.recordWrite(pb.OutputStream_OUTPUT_STREAM_STDOUT, len(exec.Stdout), len(output)-len(exec.Stdout))
+	exec.Stdout = output
+}
+
+// writeStderr appends output to the stderr of an execution, recording the
+// write. Callers must hold s.mu.
+func (exec *Execution) writeStderr(output string) {
+	if exec.discardOutput || output == "" {
+		return
+	}
+	exec.recordWrite(pb.OutputStream_OUTPUT_STREAM_STDERR, len(exec.Stderr), len(output))
+	exec.Stderr += output
+}
+
+func (exec *Execution) recordWrite(stream pb.OutputStream, offset int, length int) {
+	exec.Segments = append(exec.Segments, &pb.OutputSegment{
+		Sequence: uint64(len(exec.Segments) + 1),
+		Stream:   stream,
+		Offset:   uint64(offset),
+		Length:   uint64(length),
+	})
+}
+
+// segmentsFrom returns the segments of an execution's output past the given
+// offsets. Callers must hold s.mu.
+func segmentsFrom(exec *Execution, stdoutOffset uint64, stderrOffset uint64) []*pb.OutputSegment {
+	var segments []*pb.OutputSegment
+	for _, segment := range exec.Segments {
+		offset := stdoutOffset
+		if segment.Stream == pb.OutputStream_OUTPUT_STREAM_STDERR {
+			offset = stderrOffset
+		}
+		if segment.Offset+segment.Length > offset {
+			segments = append(segments, segment)
+		}
+	}
+	return segments
+}
+
+// outputFrom returns the part of output from a byte offset on.
+func outputFrom(output string, offset uint64) string {
+	if offset >= uint64(len(output)) {
+		return ""
+	}
+	return output[offset:]
+}
+
+// WatchExecution streams the status of an execution until it completes
+func (s *stubbedServer) WatchExecution(req *pb.WatchExecutionRequest, stream pb.ExecutionApi_WatchExecutionServer) error {
+	if s.noWatch {
+		return status.Error(codes.Unimplemented, "method WatchExecution not implemented")
+	}
+
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	var last *pb.GetExecutionStatusResponse
+	for {
+		s.mu.RLock()
+		exec, ok := s.executions[req.ExecutionId]
+		var current *pb.GetExecutionStatusResponse
+		if ok {
+			current = executionStatus(exec)
+			current.QueuePosition, current.ExecutionsAhead = s.queueStatus(exec)
+			if req.IncludeOutputOrder && !s.noOutputOrder {
+				current.OutputSegments = segmentsFrom(exec, 0, 0)
+			}
+			encodeRawOutput(current)
+		}
+		s.mu.RUnlock()
+		if !ok {
+			return status.Errorf(codes.NotFound, "execution not found: %s", req.ExecutionId)
+		}
+
+		if last == nil || !proto.Equal(current, last) {
+			if err := stream.Send(current); err != nil {
+				return err
+			}
+			last = current
+		}
+		if current.Complete {
+			return nil
+		}
+
+		select {
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		case <-ticker.C:
+		}
 	}
+}
 
+// executionStatus returns the status of an execution. Callers must hold mu.
+func executionStatus(exec *Execution) *pb.GetExecutionStatusResponse {
 	return &pb.GetExecutionStatusResponse{
 		ExecutionId: exec.ID,
 		SessionId:   exec.SessionID,
@@ -214,7 +932,13 @@ func (s *stubbedServer) GetExecutionStatus(ctx context.Context, req *pb.GetExecu
 		Stdout:     exec.Stdout,
 		Stderr:     exec.Stderr,
 		Error:      exec.Error,
-	}, nil
+		QueuedAt:   exec.CreatedAt.UnixMilli(),
+		Message:    exec.Message,
+		Result:     exec.Result,
+
+		CancellationReason: exec.CancellationReason,
+		CancelledBy:        exec.CancelledBy,
+	}
 }
 
 // CancelExecution cancels a running execution
@@ -224,27 +948,164 @@ func (s *stubbedServer) CancelExecution(ctx context.Context, req *pb.CancelExecu
 
 	exec, ok := s.executions[req.ExecutionId]
 	if !ok {
-		return nil, fmt.Errorf("execution not found: %s", req.ExecutionId)
+		return nil, status.Errorf(codes.NotFound, "execution not found: %s", req.ExecutionId)
 	}
 
-	if exec.Complete {
+	// A retry of the request that cancelled the execution is acknowledged
+	// again; any other request finds nothing left to cancel
+	if exec.Complete || exec.cancelPending {
+		return &pb.CancelExecutionResponse{Success: req.CancelToken != "" && req.CancelToken == exec.CancelToken}, nil
+	}
+
+	// An execution racing its cancellation completes before it lands
+	if exec.plan.cancelRace {
+		s.complete(exec)
+		log.Printf("Execution completed before cancellation: %s (initiator: %s)", req.ExecutionId, req.Initiator)
 		return &pb.CancelExecutionResponse{Success: false}, nil
 	}
 
+	exec.CancelToken = req.CancelToken
+	if delay := s.simulation.cancelDelay; delay > 0 {
+		// The cancellation is acknowledged, but the execution only stops
+		// once the delay passed, unless it completes or is killed first
+		exec.cancelPending = true
+		log.Printf("Cancelling execution: %s in %s (reason: %s, initiator: %s)", req.ExecutionId, delay, req.Reason, req.Initiator)
+		time.AfterFunc(delay, func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			if !exec.Complete {
+				s.cancel(exec, req.Reason, req.Initiator)
+			}
+		})
+		return &pb.CancelExecutionResponse{Success: true}, nil
+	}
+	s.cancel(exec, req.Reason, req.Initiator)
+
+	return &pb.CancelExecutionResponse{Success: true}, nil
+}
+
+// cancel ends a running execution cancelled by a client. Callers must hold
+// s.mu.
+func (s *stubbedServer) cancel(exec *Execution, reason pb.CancellationReason, initiator string) {
 	exec.Status = pb.ExecutionStatus_EXECUTION_STATUS_CANCELLED
 	exec.Complete = true
 	exec.ExitCode = -1
+	exec.Message = "cancelled by client"
+	exec.CancellationReason = reason
+	exec.CancelledBy = initiator
 
-	log.Printf("Cancelled execution: %s", req.ExecutionId)
+	log.Printf("Cancelled execution: %s (reason: %s, initiator: %s)", exec.ID, reason, initiator)
+	s.events.publish(pb.SessionEventType_SESSION_EVENT_TYPE_EXECUTION_CANCELLED, exec.SessionID, exec.ID, "execution cancelled",
+		map[string]string{"reason": reason.String(), "initiator": initiator})
+}
 
-	return &pb.CancelExecutionResponse{Success: true}, nil
+// KillExecution force-kills a running execution
+func (s *stubbedServer) KillExecution(ctx context.Context, req *pb.KillExecutionRequest) (*pb.KillExecutionResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	exec, ok := s.executions[req.ExecutionId]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "execution not found: %s", req.ExecutionId)
+	}
+	if exec.Complete {
+		return &pb.KillExecutionResponse{Success: false}, nil
+	}
+
+	exec.Status = pb.ExecutionStatus_EXECUTION_STATUS_CANCELLED
+	exec.Complete = true
+	exec.ExitCode = -1
+	exec.Message = "killed by client"
+	exec.CancelledBy = req.Initiator
+
+	log.Printf("Killed execution: %s (initiator: %s)", req.ExecutionId, req.Initiator)
+	s.events.publish(pb.SessionEventType_SESSION_EVENT_TYPE_EXECUTION_CANCELLED, exec.SessionID, exec.ID, "execution killed",
+		map[string]string{"initiator": req.Initiator, "killed": "true"})
+
+	return &pb.KillExecutionResponse{Success: true}, nil
+}
+
+// SignalExecution records a signal delivered to a running execution
+func (s *stubbedServer) SignalExecution(ctx context.Context, req *pb.SignalExecutionRequest) (*pb.SignalExecutionResponse, error) {
+	signal := req.PosixSignal
+	if req.NamedSignal != "" {
+		signal = req.NamedSignal
+	}
+	if signal == "" || (req.PosixSignal != "" && req.NamedSignal != "") {
+		return nil, status.Error(codes.InvalidArgument, "exactly one of posix_signal and named_signal must be set")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	exec, ok := s.executions[req.ExecutionId]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "execution not found: %s", req.ExecutionId)
+	}
+
+	if exec.Complete {
+		return &pb.SignalExecutionResponse{Delivered: false}, nil
+	}
+
+	exec.Signals = append(exec.Signals, signal)
+	log.Printf("Signalled execution: %s (signal: %s, named: %t)", req.ExecutionId, signal, req.NamedSignal != "")
+	s.events.publish(pb.SessionEventType_SESSION_EVENT_TYPE_EXECUTION_SIGNALLED, exec.SessionID, exec.ID, "received "+signal,
+		map[string]string{"signal": signal})
+
+	// Terminating signals end the execution, as the default handler would
+	if signo, ok := terminatingSignals[req.PosixSignal]; ok {
+		exec.Status = pb.ExecutionStatus_EXECUTION_STATUS_FAILED
+		exec.Complete = true
+		exec.ExitCode = 128 + signo
+		exec.Message = "terminated by " + req.PosixSignal
+		exec.Error = exec.Message
+		s.events.publish(pb.SessionEventType_SESSION_EVENT_TYPE_EXECUTION_FAILED, exec.SessionID, exec.ID, exec.Message,
+			map[string]string{"exit_code": strconv.Itoa(int(exec.ExitCode))})
+	}
+
+	return &pb.SignalExecutionResponse{Delivered: true}, nil
+}
+
+// ExecInSession runs an ad-hoc snippet in a session with mocked results
+func (s *stubbedServer) ExecInSession(ctx context.Context, req *pb.ExecInSessionRequest) (*pb.ExecInSessionResponse, error) {
+	if s.noExecInSession {
+		return nil, status.Error(codes.Unimplemented, "method ExecInSession not implemented")
+	}
+
+	s.mu.RLock()
+	_, ok := s.sessions[req.SessionId]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "session not found: %s", req.SessionId)
+	}
+
+	log.Printf("Exec in session: %s (language: %s, run as: %q)", req.SessionId, req.Language, req.RunAs)
+	return &pb.ExecInSessionResponse{
+		ExitCode: 0,
+		Stdout:   fmt.Sprintf("Mocked output for %s snippet:\n%s", req.Language, req.Code),
+	}, nil
 }
 
 // Health checks daemon health
 func (s *stubbedServer) Health(ctx context.Context, req *pb.HealthRequest) (*pb.HealthResponse, error) {
 	return &pb.HealthResponse{
-		Healthy: true,
-		Version: "stubbed-v0.1.0",
+		Healthy:      true,
+		Version:      "stubbed-v0.1.0",
+		MaxCodeBytes: maxCodeBytes,
+
+		SupportsRunAs:   !s.noRunAs,
+		SupportsSignals: !s.noSignals,
+		SupportsMounts:  s.mounts,
+
+		SupportsOutputOffsets: !s.noOutputOffsets,
+		SupportsWorkspaces:    !s.noWorkspaces,
+		SupportsStdin:         !s.noStdin,
+		SupportsPortBindings:  !s.noPortBindings,
+		SupportsOutputOrder:   !s.noOutputOrder,
+
+		Languages:   []string{"python", "javascript", "typescript"},
+		MaxMemoryMb: stubMaxMemoryMB,
+		Intrinsics:  []string{"env", "io", "net"},
 	}, nil
 }
 
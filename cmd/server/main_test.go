@@ -0,0 +1,142 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"math/rand"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	pb "github.com/elide-dev/elide-task-driver/proto/gen/go/elide/daemon/v1alpha1"
+)
+
+// serveStub serves s in-process, with executions running for runTime, and
+// returns a client of it with a session created from config.
+func serveStub(t *testing.T, s *stubbedServer, runTime time.Duration, config *pb.SessionConfiguration) pb.ExecutionApiClient {
+	s.sessions = make(map[string]*Session)
+	s.executions = make(map[string]*Execution)
+	s.retryAfter = time.Second
+	s.simulation = &simulation{
+		simulationProfile: simulationProfile{latency: latency{kind: "fixed", a: runTime}, chunks: 1},
+		rng:               rand.New(rand.NewSource(1)),
+	}
+
+	lis := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	pb.RegisterExecutionApiServer(server, s)
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///stub",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	client := pb.NewExecutionApiClient(conn)
+	_, err = client.CreateSession(context.Background(), &pb.CreateSessionRequest{SessionId: "session-1", Config: config})
+	require.NoError(t, err)
+	return client
+}
+
+func TestExecuteSnippet_SessionLanguages(t *testing.T) {
+	client := serveStub(t, &stubbedServer{}, time.Millisecond, &pb.SessionConfiguration{EnabledLanguages: []string{"python"}})
+
+	_, err := client.ExecuteSnippet(context.Background(), &pb.ExecuteSnippetRequest{
+		SessionId: "session-1", ExecutionId: "exec-1", Language: "javascript", Code: "1",
+	})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.ErrorContains(t, err, "language not enabled in session: javascript")
+
+	_, err = client.ExecuteSnippet(context.Background(), &pb.ExecuteSnippetRequest{
+		SessionId: "session-1", ExecutionId: "exec-2", Language: "python", Code: "1",
+	})
+	assert.NoError(t, err)
+}
+
+func TestExecuteSnippet_ContextPoolSize(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		queue bool
+	}{
+		{name: "rejected"},
+		{name: "queued", queue: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			client := serveStub(t, &stubbedServer{queueExecutions: tt.queue}, time.Minute, &pb.SessionConfiguration{ContextPoolSize: 1})
+
+			resp, err := client.ExecuteSnippet(context.Background(), &pb.ExecuteSnippetRequest{
+				SessionId: "session-1", ExecutionId: "exec-1", Language: "python", Code: "1",
+			})
+			require.NoError(t, err)
+			assert.Equal(t, pb.ExecutionStatus_EXECUTION_STATUS_RUNNING, resp.Status)
+
+			// The second execution finds the session's only context taken
+			var trailer metadata.MD
+			resp, err = client.ExecuteSnippet(context.Background(), &pb.ExecuteSnippetRequest{
+				SessionId: "session-1", ExecutionId: "exec-2", Language: "python", Code: "1",
+			}, grpc.Trailer(&trailer))
+			if tt.queue {
+				require.NoError(t, err)
+				assert.Equal(t, pb.ExecutionStatus_EXECUTION_STATUS_QUEUED, resp.Status)
+				return
+			}
+			assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+			assert.ErrorContains(t, err, "context pool exhausted")
+			assert.Equal(t, []string{"1"}, trailer.Get("retry-after"))
+		})
+	}
+}
+
+func TestExecuteSnippet_MemoryLimit(t *testing.T) {
+	client := serveStub(t, &stubbedServer{executionMemoryMB: 64}, time.Millisecond, &pb.SessionConfiguration{MemoryLimitMb: 128})
+
+	// execute submits an execution using memoryMB (the default if empty)
+	// and returns its final status
+	execute := func(id string, memoryMB string, limits *pb.ExecutionLimits) *pb.GetExecutionStatusResponse {
+		req := &pb.ExecuteSnippetRequest{SessionId: "session-1", ExecutionId: id, Language: "python", Code: "1", Limits: limits}
+		if memoryMB != "" {
+			req.Env = map[string]string{stubMemoryEnvVar: memoryMB}
+		}
+		_, err := client.ExecuteSnippet(context.Background(), req)
+		require.NoError(t, err)
+
+		var resp *pb.GetExecutionStatusResponse
+		require.Eventually(t, func() bool {
+			resp, err = client.GetExecutionStatus(context.Background(), &pb.GetExecutionStatusRequest{SessionId: "session-1", ExecutionId: id})
+			return err == nil && resp.Complete
+		}, 5*time.Second, 5*time.Millisecond)
+		return resp
+	}
+
+	resp := execute("within", "", nil)
+	assert.Equal(t, pb.ExecutionStatus_EXECUTION_STATUS_COMPLETED, resp.Status)
+
+	// An execution over the session's limit is accepted, then killed
+	resp = execute("over", "256", nil)
+	assert.Equal(t, pb.ExecutionStatus_EXECUTION_STATUS_CANCELLED, resp.Status)
+	assert.Equal(t, pb.CancellationReason_CANCELLATION_REASON_OOM, resp.CancellationReason)
+	assert.Equal(t, "memory limit exceeded", resp.Error)
+
+	// The execution's own limit takes precedence over the session's
+	resp = execute("raised", "256", &pb.ExecutionLimits{MemoryLimitMb: 512})
+	assert.Equal(t, pb.ExecutionStatus_EXECUTION_STATUS_COMPLETED, resp.Status)
+
+	_, err := client.ExecuteSnippet(context.Background(), &pb.ExecuteSnippetRequest{
+		SessionId: "session-1", ExecutionId: "invalid", Language: "python", Code: "1",
+		Env: map[string]string{stubMemoryEnvVar: "lots"},
+	})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
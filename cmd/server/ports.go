@@ -0,0 +1,47 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"net"
+	"strconv"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/elide-dev/elide-task-driver/proto/gen/go/elide/daemon/v1alpha1"
+)
+
+// bindPorts checks that the ports requested for an execution are free, i.e.
+// not bound by another running execution. The stub does not listen on them;
+// it reports the bindings as requested.
+func (s *stubbedServer) bindPorts(ports []*pb.PortBinding) error {
+	if len(ports) == 0 {
+		return nil
+	}
+	if s.noPortBindings {
+		return status.Error(codes.InvalidArgument, "port bindings not supported")
+	}
+
+	bound := map[string]string{}
+	for _, exec := range s.executions {
+		if exec.Complete {
+			continue
+		}
+		for _, port := range exec.Ports {
+			bound[hostAddr(port)] = exec.ID
+		}
+	}
+	for _, port := range ports {
+		if owner, ok := bound[hostAddr(port)]; ok {
+			return status.Errorf(codes.FailedPrecondition, "port %s (%s) is already bound by execution %s", port.Label, hostAddr(port), owner)
+		}
+	}
+	return nil
+}
+
+// hostAddr returns the host address of a port binding
+func hostAddr(port *pb.PortBinding) string {
+	return net.JoinHostPort(port.HostIp, strconv.Itoa(int(port.HostPort)))
+}
@@ -0,0 +1,276 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// latency is a distribution of execution run times
+type latency struct {
+	// kind is "fixed", "uniform", "normal" or "exponential"
+	kind string
+	// a is the fixed time, the minimum, the mean or the mean
+	a time.Duration
+	// b is the maximum of uniform, or the standard deviation of normal
+	// latencies
+	b time.Duration
+}
+
+// parseLatency parses a latency distribution: a duration ("2s"),
+// "uniform:<min>-<max>", "normal:<mean>,<stddev>" or "exponential:<mean>".
+func parseLatency(value string) (latency, error) {
+	kind, params, ok := strings.Cut(value, ":")
+	if !ok {
+		d, err := time.ParseDuration(value)
+		if err != nil || d < 0 {
+			return latency{}, fmt.Errorf("invalid latency %q", value)
+		}
+		return latency{kind: "fixed", a: d}, nil
+	}
+
+	var first, second string
+	switch kind {
+	case "uniform":
+		first, second, ok = strings.Cut(params, "-")
+	case "normal":
+		first, second, ok = strings.Cut(params, ",")
+	case "exponential":
+		first, ok = params, true
+	default:
+		return latency{}, fmt.Errorf("unknown latency distribution %q (must be uniform, normal or exponential)", kind)
+	}
+	if !ok {
+		return latency{}, fmt.Errorf("invalid %s latency %q", kind, params)
+	}
+	l := latency{kind: kind}
+	var err error
+	if l.a, err = time.ParseDuration(first); err != nil || l.a < 0 {
+		return latency{}, fmt.Errorf("invalid %s latency %q", kind, params)
+	}
+	if second != "" {
+		if l.b, err = time.ParseDuration(second); err != nil || l.b < 0 {
+			return latency{}, fmt.Errorf("invalid %s latency %q", kind, params)
+		}
+	}
+	if kind == "uniform" && l.b < l.a {
+		return latency{}, fmt.Errorf("invalid uniform latency %q: maximum below minimum", params)
+	}
+	return l, nil
+}
+
+// sample draws a run time. Normal latencies are cut off at zero, and
+// exponential ones at ten times their mean.
+func (l latency) sample(rng *rand.Rand) time.Duration {
+	switch l.kind {
+	case "uniform":
+		return l.a + time.Duration(rng.Int63n(int64(l.b-l.a)+1))
+	case "normal":
+		return max(0, l.a+time.Duration(rng.NormFloat64()*float64(l.b)))
+	case "exponential":
+		return min(10*l.a, time.Duration(rng.ExpFloat64()*float64(l.a)))
+	}
+	return l.a
+}
+
+func (l latency) String() string {
+	switch l.kind {
+	case "uniform":
+		return fmt.Sprintf("uniform:%s-%s", l.a, l.b)
+	case "normal":
+		return fmt.Sprintf("normal:%s,%s", l.a, l.b)
+	case "exponential":
+		return fmt.Sprintf("exponential:%s", l.a)
+	}
+	return l.a.String()
+}
+
+// simulationProfile configures how executions behave, so driver tests can
+// exercise slow, failing and racing executions rather than the same quick
+// success.
+type simulationProfile struct {
+	// latency is the distribution of run times
+	latency latency
+	// chunks is how many updates stdout is streamed in over the run time
+	chunks int
+	// failureRate is the fraction of executions that fail with an error
+	failureRate float64
+	// exitCodeRate is the fraction of executions that complete with
+	// exitCode rather than 0
+	exitCodeRate float64
+	exitCode     int32
+	// stderr makes executions write diagnostics to stderr as they run
+	stderr bool
+	// cancelRaceRate is the fraction of executions that complete just as
+	// they are cancelled, so the cancellation finds nothing to cancel
+	cancelRaceRate float64
+	// cancelDelay is how long a cancelled execution keeps running before
+	// the cancellation takes effect, unless it is killed first
+	cancelDelay time.Duration
+}
+
+// simulation draws the plans of executions from a profile
+type simulation struct {
+	simulationProfile
+
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+// simulationProfiles are the presets of ELIDE_STUB_PROFILE
+var simulationProfiles = map[string]simulationProfile{
+	// default completes every execution after 2 seconds, as the stub
+	// always did
+	"default": {
+		latency: latency{kind: "fixed", a: 2 * time.Second},
+		chunks:  2,
+	},
+	// realistic varies run times and has the occasional failure and
+	// nonzero exit
+	"realistic": {
+		latency:      latency{kind: "uniform", a: 500 * time.Millisecond, b: 5 * time.Second},
+		chunks:       5,
+		failureRate:  0.05,
+		exitCodeRate: 0.1,
+		exitCode:     1,
+		stderr:       true,
+	},
+	// flaky fails often, and races cancellations
+	"flaky": {
+		latency:        latency{kind: "exponential", a: time.Second},
+		chunks:         3,
+		failureRate:    0.25,
+		exitCodeRate:   0.25,
+		exitCode:       1,
+		stderr:         true,
+		cancelRaceRate: 0.5,
+	},
+	// slow runs for a long time, trickling its output
+	"slow": {
+		latency: latency{kind: "uniform", a: 10 * time.Second, b: 30 * time.Second},
+		chunks:  30,
+		stderr:  true,
+	},
+}
+
+// loadSimulation reads the simulation from the environment: the
+// ELIDE_STUB_PROFILE preset, overridden by any of the individual settings.
+func loadSimulation() (*simulation, string, error) {
+	profile := os.Getenv("ELIDE_STUB_PROFILE")
+	if profile == "" {
+		profile = "default"
+	}
+	preset, ok := simulationProfiles[profile]
+	if !ok {
+		names := make([]string, 0, len(simulationProfiles))
+		for name := range simulationProfiles {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return nil, "", fmt.Errorf("unknown ELIDE_STUB_PROFILE %q (must be one of %s)", profile, strings.Join(names, ", "))
+	}
+	sim := &simulation{simulationProfile: preset}
+
+	var err error
+	if value := os.Getenv("ELIDE_STUB_LATENCY"); value != "" {
+		if sim.latency, err = parseLatency(value); err != nil {
+			return nil, "", fmt.Errorf("invalid ELIDE_STUB_LATENCY: %w", err)
+		}
+	}
+	if sim.chunks, err = envInt("ELIDE_STUB_OUTPUT_CHUNKS", sim.chunks); err != nil || sim.chunks < 1 {
+		return nil, "", fmt.Errorf("invalid ELIDE_STUB_OUTPUT_CHUNKS: must be a positive integer")
+	}
+	for name, rate := range map[string]*float64{
+		"ELIDE_STUB_FAILURE_RATE":     &sim.failureRate,
+		"ELIDE_STUB_EXIT_CODE_RATE":   &sim.exitCodeRate,
+		"ELIDE_STUB_CANCEL_RACE_RATE": &sim.cancelRaceRate,
+	} {
+		if value := os.Getenv(name); value != "" {
+			if *rate, err = strconv.ParseFloat(value, 64); err != nil || *rate < 0 || *rate > 1 {
+				return nil, "", fmt.Errorf("invalid %s %q: must be between 0 and 1", name, value)
+			}
+		}
+	}
+	exitCode, err := envInt("ELIDE_STUB_EXIT_CODE", int(sim.exitCode))
+	if err != nil || exitCode < 0 || exitCode > math.MaxInt32 {
+		return nil, "", fmt.Errorf("invalid ELIDE_STUB_EXIT_CODE")
+	}
+	sim.exitCode = int32(exitCode)
+	if sim.exitCodeRate > 0 && sim.exitCode == 0 {
+		sim.exitCode = 1
+	}
+	if value := os.Getenv("ELIDE_STUB_STDERR"); value != "" {
+		if sim.stderr, err = strconv.ParseBool(value); err != nil {
+			return nil, "", fmt.Errorf("invalid ELIDE_STUB_STDERR %q", value)
+		}
+	}
+	if value := os.Getenv("ELIDE_STUB_CANCEL_DELAY"); value != "" {
+		if sim.cancelDelay, err = time.ParseDuration(value); err != nil || sim.cancelDelay < 0 {
+			return nil, "", fmt.Errorf("invalid ELIDE_STUB_CANCEL_DELAY %q", value)
+		}
+	}
+
+	seed := time.Now().UnixNano()
+	if value := os.Getenv("ELIDE_STUB_SEED"); value != "" {
+		if seed, err = strconv.ParseInt(value, 10, 64); err != nil {
+			return nil, "", fmt.Errorf("invalid ELIDE_STUB_SEED %q", value)
+		}
+	}
+	sim.rng = rand.New(rand.NewSource(seed))
+	return sim, profile, nil
+}
+
+func (s *simulation) String() string {
+	return fmt.Sprintf("latency %s, %d output chunks, failure rate %g, exit code %d rate %g, stderr %t, cancel race rate %g, cancel delay %s",
+		s.latency, s.chunks, s.failureRate, s.exitCode, s.exitCodeRate, s.stderr, s.cancelRaceRate, s.cancelDelay)
+}
+
+// executionPlan is how one execution behaves, drawn from the simulation
+type executionPlan struct {
+	duration time.Duration
+	chunks   int
+	stderr   bool
+	// fail makes the execution fail rather than complete
+	fail bool
+	// exitCode is the exit code of an execution that completes
+	exitCode int32
+	// cancelRace makes the execution complete as it is cancelled
+	cancelRace bool
+}
+
+// plan draws the behavior of an execution.
+func (s *simulation) plan() executionPlan {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	plan := executionPlan{
+		duration:   s.latency.sample(s.rng),
+		chunks:     s.chunks,
+		stderr:     s.stderr,
+		fail:       s.rng.Float64() < s.failureRate,
+		cancelRace: s.rng.Float64() < s.cancelRaceRate,
+	}
+	if !plan.fail && s.rng.Float64() < s.exitCodeRate {
+		plan.exitCode = s.exitCode
+	}
+	return plan
+}
+
+// output is the stdout of an execution after chunk i of its plan: the
+// header, followed by a growing share of the code's lines.
+func (p executionPlan) output(header string, code string, i int) string {
+	if p.chunks <= 1 {
+		return header + code
+	}
+	lines := strings.SplitAfter(code, "\n")
+	return header + strings.Join(lines[:len(lines)*i/(p.chunks-1)], "")
+}
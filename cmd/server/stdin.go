@@ -0,0 +1,57 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"errors"
+	"io"
+	"log"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/elide-dev/elide-task-driver/proto/gen/go/elide/daemon/v1alpha1"
+)
+
+// OpenStdin buffers the stdin of an execution submitted with stdin set; the
+// execution echoes it into its output on completion
+func (s *stubbedServer) OpenStdin(stream grpc.ClientStreamingServer[pb.OpenStdinRequest, pb.OpenStdinResponse]) error {
+	if s.noStdin {
+		return status.Error(codes.Unimplemented, "stdin not supported")
+	}
+
+	var sessionID, executionID string
+	var data []byte
+	for {
+		req, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if executionID == "" {
+			sessionID, executionID = req.SessionId, req.ExecutionId
+			if executionID == "" {
+				return status.Error(codes.InvalidArgument, "first message must set session_id and execution_id")
+			}
+		}
+		data = append(data, req.Data...)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	exec, ok := s.executions[executionID]
+	if !ok || exec.SessionID != sessionID {
+		return status.Errorf(codes.NotFound, "execution not found: %s", executionID)
+	}
+	if !exec.StdinOpen {
+		return status.Errorf(codes.FailedPrecondition, "execution %s was not submitted with stdin", executionID)
+	}
+	exec.Stdin = append(exec.Stdin, data...)
+
+	log.Printf("Received stdin of execution: %s (%d bytes)", executionID, len(data))
+	return stream.SendAndClose(&pb.OpenStdinResponse{BytesReceived: uint64(len(data))})
+}
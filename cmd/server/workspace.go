@@ -0,0 +1,73 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"errors"
+	"io"
+	"log"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/elide-dev/elide-task-driver/proto/gen/go/elide/daemon/v1alpha1"
+)
+
+// UploadWorkspace stores the uploaded files of a workspace in memory,
+// replacing an earlier upload to the same workspace
+func (s *stubbedServer) UploadWorkspace(stream grpc.ClientStreamingServer[pb.UploadWorkspaceRequest, pb.UploadWorkspaceResponse]) error {
+	if s.noWorkspaces {
+		return status.Error(codes.Unimplemented, "workspaces not supported")
+	}
+
+	var sessionID, workspaceID string
+	files := map[string][]byte{}
+	var total uint64
+	for {
+		req, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if sessionID == "" {
+			sessionID, workspaceID = req.SessionId, req.WorkspaceId
+			if workspaceID == "" {
+				return status.Error(codes.InvalidArgument, "first message must set session_id and workspace_id")
+			}
+		}
+		files[req.Path] = append(files[req.Path], req.Data...)
+		total += uint64(len(req.Data))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session, ok := s.sessions[sessionID]
+	if !ok {
+		return status.Errorf(codes.NotFound, "session not found: %s", sessionID)
+	}
+	session.Workspaces[workspaceID] = files
+
+	log.Printf("Uploaded workspace: %s to session: %s (%d files, %d bytes)", workspaceID, sessionID, len(files), total)
+	return stream.SendAndClose(&pb.UploadWorkspaceResponse{
+		WorkspaceId: workspaceID,
+		FileCount:   uint32(len(files)),
+		TotalBytes:  total,
+	})
+}
+
+// workspaceCode returns the code of a workspace's entrypoint
+func (session *Session) workspaceCode(ref *pb.WorkspaceRef) (string, error) {
+	files, ok := session.Workspaces[ref.WorkspaceId]
+	if !ok {
+		return "", status.Errorf(codes.NotFound, "workspace not found: %s", ref.WorkspaceId)
+	}
+	code, ok := files[ref.Entrypoint]
+	if !ok {
+		return "", status.Errorf(codes.NotFound, "entrypoint not found in workspace %s: %s", ref.WorkspaceId, ref.Entrypoint)
+	}
+	return string(code), nil
+}
@@ -0,0 +1,159 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+
+	pb "github.com/elide-dev/elide-task-driver/proto/gen/go/elide/daemon/v1alpha1"
+)
+
+// describe lists the services and methods the server exposes through gRPC
+// reflection and compares the ExecutionApi against the client's compiled
+// proto to detect drift.
+func describe(socketPath string) error {
+	dialer := func(ctx context.Context, addr string) (net.Conn, error) {
+		return net.Dial("unix", addr)
+	}
+	conn, err := grpc.Dial(
+		socketPath,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithContextDialer(dialer),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	stream, err := reflectionpb.NewServerReflectionClient(conn).ServerReflectionInfo(ctx)
+	if err != nil {
+		return fmt.Errorf("reflection not available: %w", err)
+	}
+	defer stream.CloseSend()
+
+	resp, err := reflect(stream, &reflectionpb.ServerReflectionRequest{
+		MessageRequest: &reflectionpb.ServerReflectionRequest_ListServices{},
+	})
+	if err != nil {
+		return err
+	}
+
+	var services []string
+	for _, svc := range resp.GetListServicesResponse().GetService() {
+		services = append(services, svc.GetName())
+	}
+	sort.Strings(services)
+
+	localService := pb.File_elide_daemon_v1alpha1_execution_api_proto.Services().Get(0)
+	var remoteMethods map[string]string
+
+	for _, name := range services {
+		fmt.Printf("%s\n", name)
+
+		resp, err := reflect(stream, &reflectionpb.ServerReflectionRequest{
+			MessageRequest: &reflectionpb.ServerReflectionRequest_FileContainingSymbol{FileContainingSymbol: name},
+		})
+		if err != nil {
+			return err
+		}
+
+		methods := serviceMethods(resp.GetFileDescriptorResponse().GetFileDescriptorProto(), name)
+		names := make([]string, 0, len(methods))
+		for method := range methods {
+			names = append(names, method)
+		}
+		sort.Strings(names)
+		for _, method := range names {
+			fmt.Printf("  rpc %s%s\n", method, methods[method])
+		}
+
+		if name == string(localService.FullName()) {
+			remoteMethods = methods
+		}
+	}
+
+	// Compare against the proto compiled into this client
+	fmt.Println()
+	if remoteMethods == nil {
+		return fmt.Errorf("server does not expose %s", localService.FullName())
+	}
+
+	var drift []string
+	localMethods := localService.Methods()
+	for i := 0; i < localMethods.Len(); i++ {
+		m := localMethods.Get(i)
+		signature := fmt.Sprintf("(%s) returns (%s)", m.Input().FullName(), m.Output().FullName())
+		remote, ok := remoteMethods[string(m.Name())]
+		switch {
+		case !ok:
+			drift = append(drift, fmt.Sprintf("%s: missing on server", m.Name()))
+		case remote != signature:
+			drift = append(drift, fmt.Sprintf("%s: server has %s, client expects %s", m.Name(), remote, signature))
+		}
+		delete(remoteMethods, string(m.Name()))
+	}
+	for method := range remoteMethods {
+		drift = append(drift, fmt.Sprintf("%s: unknown to client", method))
+	}
+
+	if len(drift) == 0 {
+		fmt.Printf("✓ %s matches the client proto\n", localService.FullName())
+		return nil
+	}
+	sort.Strings(drift)
+	fmt.Printf("✗ %s differs from the client proto:\n  %s\n", localService.FullName(), strings.Join(drift, "\n  "))
+	return fmt.Errorf("proto drift detected")
+}
+
+// reflect sends one reflection request and returns its response.
+func reflect(stream reflectionpb.ServerReflection_ServerReflectionInfoClient, req *reflectionpb.ServerReflectionRequest) (*reflectionpb.ServerReflectionResponse, error) {
+	if err := stream.Send(req); err != nil {
+		return nil, fmt.Errorf("reflection request failed: %w", err)
+	}
+	resp, err := stream.Recv()
+	if err != nil {
+		return nil, fmt.Errorf("reflection response failed: %w", err)
+	}
+	if errResp := resp.GetErrorResponse(); errResp != nil {
+		return nil, fmt.Errorf("reflection error: %s", errResp.GetErrorMessage())
+	}
+	return resp, nil
+}
+
+// serviceMethods returns the method signatures of a service, keyed by method
+// name, from serialized file descriptors.
+func serviceMethods(files [][]byte, service string) map[string]string {
+	methods := map[string]string{}
+	for _, raw := range files {
+		var fd descriptorpb.FileDescriptorProto
+		if err := proto.Unmarshal(raw, &fd); err != nil {
+			continue
+		}
+		for _, svc := range fd.GetService() {
+			if fd.GetPackage()+"."+svc.GetName() != service {
+				continue
+			}
+			for _, m := range svc.GetMethod() {
+				methods[m.GetName()] = fmt.Sprintf("(%s) returns (%s)",
+					strings.TrimPrefix(m.GetInputType(), "."),
+					strings.TrimPrefix(m.GetOutputType(), "."))
+			}
+		}
+	}
+	return methods
+}
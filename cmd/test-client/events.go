@@ -0,0 +1,74 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	pb "github.com/elide-dev/elide-task-driver/proto/gen/go/elide/daemon/v1alpha1"
+)
+
+// events prints the daemon's session lifecycle events as they happen, until
+// interrupted or the stream ends.
+func events(socketPath string, args []string) error {
+	flags := flag.NewFlagSet("events", flag.ExitOnError)
+	sessionID := flags.String("session", "", "session to watch (default all sessions)")
+	flags.StringVar(&socketPath, "socket", socketPath, "daemon socket path")
+	flags.Parse(args)
+
+	dialer := func(ctx context.Context, addr string) (net.Conn, error) {
+		return net.Dial("unix", addr)
+	}
+	conn, err := grpc.Dial(
+		socketPath,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithContextDialer(dialer),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+	defer conn.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	stream, err := pb.NewExecutionApiClient(conn).WatchSessionEvents(ctx, &pb.WatchSessionEventsRequest{SessionId: *sessionID})
+	if err != nil {
+		return err
+	}
+	for {
+		event, err := stream.Recv()
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		target := event.SessionId
+		if event.ExecutionId != "" {
+			target += "/" + event.ExecutionId
+		}
+		details := make([]string, 0, len(event.Details))
+		for key, value := range event.Details {
+			details = append(details, key+"="+value)
+		}
+		sort.Strings(details)
+		fmt.Printf("%s %-20s %s: %s %s\n",
+			time.UnixMilli(event.Timestamp).Format("15:04:05.000"),
+			strings.ToLower(strings.TrimPrefix(event.Type.String(), "SESSION_EVENT_TYPE_")),
+			target, event.Message, strings.Join(details, " "))
+	}
+}
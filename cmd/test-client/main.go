@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"log"
 	"net"
+	"os"
 	"time"
 
 	"google.golang.org/grpc"
@@ -19,7 +20,31 @@ import (
 func main() {
 	// Connect to stubbed server
 	socketPath := "/tmp/elide-daemon.sock"
-	
+
+	// describe lists the services exposed by the server via reflection
+	if len(os.Args) > 1 && os.Args[1] == "describe" {
+		if err := describe(socketPath); err != nil {
+			log.Fatalf("Describe failed: %v", err)
+		}
+		return
+	}
+
+	// soak runs randomized operations and checks the daemon's invariants
+	if len(os.Args) > 1 && os.Args[1] == "soak" {
+		if err := soak(socketPath, os.Args[2:]); err != nil {
+			log.Fatalf("Soak failed: %v", err)
+		}
+		return
+	}
+
+	// events prints the daemon's session lifecycle events as they happen
+	if len(os.Args) > 1 && os.Args[1] == "events" {
+		if err := events(socketPath, os.Args[2:]); err != nil {
+			log.Fatalf("Events failed: %v", err)
+		}
+		return
+	}
+
 	dialer := func(ctx context.Context, addr string) (net.Conn, error) {
 		return net.Dial("unix", addr)
 	}
@@ -42,7 +67,7 @@ func main() {
 	if err != nil {
 		log.Fatalf("Health check failed: %v", err)
 	}
-	fmt.Printf("✓ Health: %v (version: %s)\n\n", healthResp.Healthy, healthResp.Version)
+	fmt.Printf("✓ Health: %v (version: %s, max code: %d bytes)\n\n", healthResp.Healthy, healthResp.Version, healthResp.MaxCodeBytes)
 
 	// Test CreateSession
 	fmt.Println("Testing CreateSession...")
@@ -0,0 +1,518 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"math/rand"
+	"net"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/elide-dev/elide-task-driver/proto/gen/go/elide/daemon/v1alpha1"
+)
+
+// soakSnippets are the snippets executed by the soak test
+var soakSnippets = []struct {
+	language string
+	code     string
+}{
+	{"python", "print('soak')"},
+	{"python", "import json\nprint(json.dumps({'soak': True}))"},
+	{"javascript", "console.log('soak')"},
+}
+
+// soak runs randomized session and execution operations against the daemon
+// for a set duration and checks the daemon keeps its protocol invariants:
+//   - an accepted execution stays known to the daemon until its session is
+//     deleted, and completes eventually
+//   - the status of an execution only moves forward (queued, running, then a
+//     terminal status), and a completed execution never changes again
+//   - an execution the daemon reported cancelled ends cancelled
+//
+// It returns an error if any invariant was violated.
+func soak(socketPath string, args []string) error {
+	flags := flag.NewFlagSet("soak", flag.ExitOnError)
+	duration := flags.Duration("duration", time.Minute, "how long to run operations")
+	workers := flags.Int("workers", 8, "number of concurrent workers")
+	interval := flags.Duration("interval", 50*time.Millisecond, "pause between the operations of a worker")
+	maxSessions := flags.Int("sessions", 4, "maximum number of sessions open at once")
+	drain := flags.Duration("drain", 30*time.Second, "how long to wait for executions to complete at the end")
+	seed := flags.Int64("seed", time.Now().UnixNano(), "seed of the operation sequence of each worker")
+	flags.StringVar(&socketPath, "socket", socketPath, "daemon socket path")
+	flags.Parse(args)
+
+	dialer := func(ctx context.Context, addr string) (net.Conn, error) {
+		return net.Dial("unix", addr)
+	}
+	conn, err := grpc.Dial(
+		socketPath,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithContextDialer(dialer),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+	defer conn.Close()
+
+	s := &soakRun{
+		client:      pb.NewExecutionApiClient(conn),
+		prefix:      fmt.Sprintf("soak-%d", time.Now().Unix()),
+		maxSessions: *maxSessions,
+		sessions:    make(map[string]*soakSession),
+		ops:         make(map[string]int),
+		errors:      make(map[string]int),
+	}
+	fmt.Printf("Soaking %s for %s with %d workers (seed %d)...\n", socketPath, *duration, *workers, *seed)
+
+	deadline := time.Now().Add(*duration)
+	var wg sync.WaitGroup
+	for i := 0; i < *workers; i++ {
+		wg.Add(1)
+		go func(rng *rand.Rand) {
+			defer wg.Done()
+			for time.Now().Before(deadline) {
+				s.step(rng)
+				time.Sleep(*interval)
+			}
+		}(rand.New(rand.NewSource(*seed + int64(i))))
+	}
+	wg.Wait()
+
+	fmt.Printf("Draining executions (up to %s)...\n", *drain)
+	s.drain(*drain)
+	s.cleanup()
+	return s.report()
+}
+
+// soakRun is the state of a soak test, as the client knows it.
+type soakRun struct {
+	client      pb.ExecutionApiClient
+	prefix      string
+	maxSessions int
+	nextID      atomic.Int64
+
+	mu         sync.Mutex
+	sessions   map[string]*soakSession
+	ops        map[string]int
+	errors     map[string]int
+	violations []string
+}
+
+// soakSession is a session created by the soak test. Operations on its
+// executions hold lock for reading; deleting the session holds it for
+// writing, so an execution that vanishes while its session exists is lost.
+type soakSession struct {
+	id   string
+	lock sync.RWMutex
+
+	mu         sync.Mutex
+	executions map[string]*soakExecution
+}
+
+// soakExecution is an execution accepted by the daemon. lock serializes
+// status checks so observations are recorded in the order they were made.
+type soakExecution struct {
+	id   string
+	lock sync.Mutex
+
+	status    pb.ExecutionStatus
+	complete  bool
+	exitCode  int32
+	cancelled bool // The daemon accepted a cancellation
+}
+
+// step runs one random operation.
+func (s *soakRun) step(rng *rand.Rand) {
+	switch n := rng.Intn(100); {
+	case n < 5:
+		s.createSession()
+	case n < 40:
+		s.execute(rng)
+	case n < 85:
+		s.checkStatus(rng)
+	case n < 99:
+		s.cancel(rng)
+	default:
+		s.deleteSession(rng)
+	}
+}
+
+// createSession creates a session unless maxSessions are open.
+func (s *soakRun) createSession() {
+	s.mu.Lock()
+	if len(s.sessions) >= s.maxSessions {
+		s.mu.Unlock()
+		return
+	}
+	session := &soakSession{
+		id:         fmt.Sprintf("%s-session-%d", s.prefix, s.nextID.Add(1)),
+		executions: make(map[string]*soakExecution),
+	}
+	// Hold the session until the daemon created it
+	session.lock.Lock()
+	defer session.lock.Unlock()
+	s.sessions[session.id] = session
+	s.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err := s.client.CreateSession(ctx, &pb.CreateSessionRequest{
+		SessionId: session.id,
+		Config: &pb.SessionConfiguration{
+			ContextPoolSize:  2,
+			EnabledLanguages: []string{"python", "javascript"},
+			MemoryLimitMb:    256,
+		},
+	})
+	if !s.record("CreateSession", err) {
+		s.mu.Lock()
+		delete(s.sessions, session.id)
+		s.mu.Unlock()
+	}
+}
+
+// execute submits a random snippet in a random session.
+func (s *soakRun) execute(rng *rand.Rand) {
+	session := s.pickSession(rng)
+	if session == nil {
+		s.createSession()
+		return
+	}
+	defer session.lock.RUnlock()
+
+	snippet := soakSnippets[rng.Intn(len(soakSnippets))]
+	exec := &soakExecution{id: fmt.Sprintf("%s-exec-%d", s.prefix, s.nextID.Add(1))}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	resp, err := s.client.ExecuteSnippet(ctx, &pb.ExecuteSnippetRequest{
+		SessionId:   session.id,
+		ExecutionId: exec.id,
+		Code:        snippet.code,
+		Language:    snippet.language,
+	})
+	if !s.record("ExecuteSnippet", err) {
+		return
+	}
+	if resp.ExecutionId != exec.id {
+		s.violation("execution %s accepted as %q", exec.id, resp.ExecutionId)
+		return
+	}
+	exec.status = resp.Status
+
+	session.mu.Lock()
+	session.executions[exec.id] = exec
+	session.mu.Unlock()
+}
+
+// checkStatus gets the status of a random execution and checks it against
+// the previous one.
+func (s *soakRun) checkStatus(rng *rand.Rand) {
+	session, exec := s.pickExecution(rng)
+	if exec == nil {
+		return
+	}
+	defer session.lock.RUnlock()
+	s.refreshStatus(session, exec)
+}
+
+// refreshStatus gets the status of an execution and checks the invariants.
+// Callers must hold the session's lock for reading.
+func (s *soakRun) refreshStatus(session *soakSession, exec *soakExecution) {
+	exec.lock.Lock()
+	defer exec.lock.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	resp, err := s.client.GetExecutionStatus(ctx, &pb.GetExecutionStatusRequest{
+		SessionId:   session.id,
+		ExecutionId: exec.id,
+	})
+	if !s.record("GetExecutionStatus", err) {
+		switch status.Code(err) {
+		case codes.Unavailable, codes.DeadlineExceeded, codes.Canceled:
+		default:
+			s.violation("execution %s lost in live session %s: %v", exec.id, session.id, err)
+		}
+		return
+	}
+
+	switch {
+	case exec.complete && (resp.Status != exec.status || !resp.Complete || resp.ExitCode != exec.exitCode):
+		s.violation("completed execution %s changed from %s (exit %d) to %s (complete: %t, exit %d)",
+			exec.id, exec.status, exec.exitCode, resp.Status, resp.Complete, resp.ExitCode)
+	case statusRank(resp.Status) < statusRank(exec.status):
+		s.violation("execution %s went back from %s to %s", exec.id, exec.status, resp.Status)
+	case resp.Complete != (statusRank(resp.Status) == statusRank(pb.ExecutionStatus_EXECUTION_STATUS_COMPLETED)):
+		s.violation("execution %s reported %s with complete = %t", exec.id, resp.Status, resp.Complete)
+	case resp.Complete && exec.cancelled && resp.Status != pb.ExecutionStatus_EXECUTION_STATUS_CANCELLED:
+		s.violation("cancelled execution %s completed as %s", exec.id, resp.Status)
+	}
+	exec.status, exec.complete, exec.exitCode = resp.Status, resp.Complete, resp.ExitCode
+}
+
+// cancel cancels a random execution.
+func (s *soakRun) cancel(rng *rand.Rand) {
+	session, exec := s.pickExecution(rng)
+	if exec == nil {
+		return
+	}
+	defer session.lock.RUnlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	resp, err := s.client.CancelExecution(ctx, &pb.CancelExecutionRequest{
+		SessionId:   session.id,
+		ExecutionId: exec.id,
+		Reason:      pb.CancellationReason_CANCELLATION_REASON_USER_STOP,
+		Initiator:   "soak",
+	})
+	if !s.record("CancelExecution", err) || !resp.Success {
+		return
+	}
+
+	exec.lock.Lock()
+	defer exec.lock.Unlock()
+	if exec.complete && exec.status != pb.ExecutionStatus_EXECUTION_STATUS_CANCELLED {
+		s.violation("execution %s cancelled after it completed as %s", exec.id, exec.status)
+	}
+	exec.cancelled = true
+}
+
+// deleteSession deletes a random session once no operation uses it.
+func (s *soakRun) deleteSession(rng *rand.Rand) {
+	session := s.pickSession(rng)
+	if session == nil {
+		return
+	}
+	session.lock.RUnlock()
+
+	s.mu.Lock()
+	if _, ok := s.sessions[session.id]; !ok {
+		s.mu.Unlock()
+		return
+	}
+	delete(s.sessions, session.id)
+	s.mu.Unlock()
+
+	session.lock.Lock()
+	defer session.lock.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err := s.client.DeleteSession(ctx, &pb.DeleteSessionRequest{SessionId: session.id})
+	s.record("DeleteSession", err)
+}
+
+// drain waits for the executions of the remaining sessions to complete and
+// reports those that do not, then checks all of them once more, as completed
+// executions must not change.
+func (s *soakRun) drain(timeout time.Duration) {
+	deadline := time.Now().Add(timeout)
+	for {
+		pending := 0
+		for _, session := range s.liveSessions() {
+			session.lock.RLock()
+			for _, exec := range session.pendingExecutions() {
+				s.refreshStatus(session, exec)
+				if !exec.complete {
+					pending++
+				}
+			}
+			session.lock.RUnlock()
+		}
+		if pending == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			for _, session := range s.liveSessions() {
+				for _, exec := range session.pendingExecutions() {
+					s.violation("execution %s never completed (last status %s)", exec.id, exec.status)
+				}
+			}
+			return
+		}
+		time.Sleep(250 * time.Millisecond)
+	}
+
+	for _, session := range s.liveSessions() {
+		session.lock.RLock()
+		for _, exec := range session.allExecutions() {
+			s.refreshStatus(session, exec)
+		}
+		session.lock.RUnlock()
+	}
+}
+
+// cleanup deletes the remaining sessions.
+func (s *soakRun) cleanup() {
+	for _, session := range s.liveSessions() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		_, err := s.client.DeleteSession(ctx, &pb.DeleteSessionRequest{SessionId: session.id})
+		cancel()
+		s.record("DeleteSession", err)
+	}
+}
+
+// report prints the operation counts and violations.
+func (s *soakRun) report() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fmt.Println("\nOperations:")
+	for _, op := range sortedKeys(s.ops) {
+		fmt.Printf("  %-20s %6d (%d errors)\n", op, s.ops[op], s.errors[op])
+	}
+	for _, key := range sortedKeys(s.errors) {
+		if _, ok := s.ops[key]; !ok {
+			fmt.Printf("  %-40s %6d\n", key, s.errors[key])
+		}
+	}
+
+	if len(s.violations) > 0 {
+		fmt.Printf("\n✗ %d invariant violations:\n", len(s.violations))
+		for _, violation := range s.violations {
+			fmt.Printf("  %s\n", violation)
+		}
+		return fmt.Errorf("%d invariant violations", len(s.violations))
+	}
+	fmt.Println("\n✅ No invariant violations")
+	return nil
+}
+
+// record counts an operation and its error, returning whether it succeeded.
+// An overloaded daemon shedding executions is expected and not an error.
+func (s *soakRun) record(op string, err error) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.ops[op]++
+	if err == nil {
+		return true
+	}
+	if status.Code(err) != codes.ResourceExhausted {
+		s.errors[op]++
+		s.errors[op+" "+status.Code(err).String()]++
+	}
+	return false
+}
+
+// violation records a broken invariant.
+func (s *soakRun) violation(format string, args ...interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	msg := fmt.Sprintf(format, args...)
+	s.violations = append(s.violations, msg)
+	fmt.Printf("✗ %s\n", msg)
+}
+
+// pickSession returns a random live session, locked for reading, or nil.
+func (s *soakRun) pickSession(rng *rand.Rand) *soakSession {
+	sessions := s.liveSessions()
+	if len(sessions) == 0 {
+		return nil
+	}
+	session := sessions[rng.Intn(len(sessions))]
+	session.lock.RLock()
+
+	// The session may have been deleted while waiting for the lock
+	s.mu.Lock()
+	_, ok := s.sessions[session.id]
+	s.mu.Unlock()
+	if !ok {
+		session.lock.RUnlock()
+		return nil
+	}
+	return session
+}
+
+// pickExecution returns a random execution of a random live session, whose
+// lock is held for reading, or nil.
+func (s *soakRun) pickExecution(rng *rand.Rand) (*soakSession, *soakExecution) {
+	session := s.pickSession(rng)
+	if session == nil {
+		return nil, nil
+	}
+	executions := session.allExecutions()
+	if len(executions) == 0 {
+		session.lock.RUnlock()
+		return nil, nil
+	}
+	return session, executions[rng.Intn(len(executions))]
+}
+
+// liveSessions returns the sessions not deleted, sorted by ID.
+func (s *soakRun) liveSessions() []*soakSession {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sessions := make([]*soakSession, 0, len(s.sessions))
+	for _, session := range s.sessions {
+		sessions = append(sessions, session)
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].id < sessions[j].id })
+	return sessions
+}
+
+// allExecutions returns the executions of the session.
+func (session *soakSession) allExecutions() []*soakExecution {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+
+	executions := make([]*soakExecution, 0, len(session.executions))
+	for _, exec := range session.executions {
+		executions = append(executions, exec)
+	}
+	return executions
+}
+
+// pendingExecutions returns the executions of the session not known to have
+// completed.
+func (session *soakSession) pendingExecutions() []*soakExecution {
+	var pending []*soakExecution
+	for _, exec := range session.allExecutions() {
+		exec.lock.Lock()
+		complete := exec.complete
+		exec.lock.Unlock()
+		if !complete {
+			pending = append(pending, exec)
+		}
+	}
+	return pending
+}
+
+// statusRank orders execution statuses; terminal statuses share the highest
+// rank.
+func statusRank(s pb.ExecutionStatus) int {
+	switch s {
+	case pb.ExecutionStatus_EXECUTION_STATUS_QUEUED:
+		return 1
+	case pb.ExecutionStatus_EXECUTION_STATUS_RUNNING:
+		return 2
+	case pb.ExecutionStatus_EXECUTION_STATUS_COMPLETED,
+		pb.ExecutionStatus_EXECUTION_STATUS_FAILED,
+		pb.ExecutionStatus_EXECUTION_STATUS_CANCELLED:
+		return 3
+	}
+	return 0
+}
+
+// sortedKeys returns the keys of a map in order.
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,107 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"time"
+)
+
+const (
+	// adminSnapshotPath is the admin API path used to export (GET) and
+	// import (PUT) driver state snapshots
+	adminSnapshotPath = "/v1/snapshot"
+)
+
+// adminServer serves the plugin's admin API over a Unix socket. It is only
+// started when admin_socket is set in the plugin config.
+type adminServer struct {
+	socketPath string
+	server     *http.Server
+}
+
+// startAdminServer starts serving the admin API on the given Unix socket.
+func (d *ElideDriverPlugin) startAdminServer(socketPath string) (*adminServer, error) {
+	// Remove a stale socket left behind by a previous instance
+	if err := os.Remove(socketPath); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to remove stale admin socket: %w", err)
+	}
+
+	lis, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on admin socket: %w", err)
+	}
+	if err := os.Chmod(socketPath, 0600); err != nil {
+		lis.Close()
+		return nil, fmt.Errorf("failed to set admin socket permissions: %w", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(adminSnapshotPath, d.handleAdminSnapshot)
+	mux.HandleFunc("/v1/metrics", d.handleMetrics)
+	mux.HandleFunc(healthzPath, d.handleHealthz)
+	mux.HandleFunc(readyzPath, d.handleReadyz)
+	mux.HandleFunc(adminAllocsPath, d.handleAdminAllocs)
+	mux.HandleFunc(adminSessionPlanPath, d.handleAdminSessionPlan)
+	mux.HandleFunc(adminSessionApplyPath, d.handleAdminSessionApply)
+
+	s := &adminServer{
+		socketPath: socketPath,
+		server:     &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second},
+	}
+	go func() {
+		if err := s.server.Serve(lis); err != nil && err != http.ErrServerClosed {
+			d.logger.Error("admin server stopped", "error", err)
+		}
+	}()
+
+	d.logger.Info("admin API listening", "socket", socketPath)
+	return s, nil
+}
+
+// Close stops the admin server and removes its socket.
+func (s *adminServer) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := s.server.Shutdown(ctx)
+	os.Remove(s.socketPath)
+	return err
+}
+
+// handleAdminSnapshot exports the driver state on GET and restores a
+// previously exported snapshot on PUT.
+func (d *ElideDriverPlugin) handleAdminSnapshot(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeAdminJSON(w, http.StatusOK, d.Snapshot())
+	case http.MethodPut, http.MethodPost:
+		var snap Snapshot
+		if err := json.NewDecoder(r.Body).Decode(&snap); err != nil {
+			writeAdminJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("invalid snapshot: %v", err)})
+			return
+		}
+		restored, err := d.RestoreSnapshot(&snap)
+		resp := map[string]interface{}{"restored": restored}
+		status := http.StatusOK
+		if err != nil {
+			resp["error"] = err.Error()
+			status = http.StatusConflict
+		}
+		writeAdminJSON(w, status, resp)
+	default:
+		w.Header().Set("Allow", "GET, PUT")
+		writeAdminJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+	}
+}
+
+func writeAdminJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,332 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	nstructs "github.com/hashicorp/nomad/nomad/structs"
+	"github.com/hashicorp/nomad/plugins/drivers"
+)
+
+// executionSlots limits the executions the driver submits to the daemon at
+// once, overall and in each session, whose context pool is finite. A task
+// takes a slot of its session first, then one of the node.
+type executionSlots struct {
+	// global limits the executions of all sessions
+	global *admissionController
+
+	// perSession is the limit of each session (0 = unlimited)
+	perSession int
+
+	// mu syncs access to sessions
+	mu sync.Mutex
+
+	// sessions holds the controller of each session with holders or
+	// waiters, dropped once it has neither
+	sessions map[string]*sessionSlots
+}
+
+// sessionSlots is the controller of one session and the number of tasks
+// holding or waiting for one of its slots.
+type sessionSlots struct {
+	controller *admissionController
+	users      int
+}
+
+func newExecutionSlots(limit int, perSession int) *executionSlots {
+	return &executionSlots{
+		global:     newAdmissionController(limit),
+		perSession: perSession,
+		sessions:   map[string]*sessionSlots{},
+	}
+}
+
+// Acquire blocks until a slot of the session and of the node is available
+// for the given job, or ctx is done. queued (may be nil) is called once if
+// the caller has to wait. The returned release function gives both slots
+// back and is safe to call more than once.
+func (e *executionSlots) Acquire(ctx context.Context, sessionID string, key string, queued func()) (func(), error) {
+	var once sync.Once
+	notify := func() {
+		if queued != nil {
+			once.Do(queued)
+		}
+	}
+
+	session := e.session(sessionID)
+	releaseSession, err := session.controller.Acquire(ctx, key, notify)
+	if err != nil {
+		e.leave(sessionID)
+		return nil, err
+	}
+	releaseGlobal, err := e.global.Acquire(ctx, key, notify)
+	if err != nil {
+		releaseSession()
+		e.leave(sessionID)
+		return nil, err
+	}
+	return e.releaseFunc(sessionID, releaseSession, releaseGlobal), nil
+}
+
+// Adopt takes slots without waiting, used for executions that are already
+// running on the daemon (e.g. recovered tasks).
+func (e *executionSlots) Adopt(sessionID string) func() {
+	releaseSession := e.session(sessionID).controller.Adopt()
+	return e.releaseFunc(sessionID, releaseSession, e.global.Adopt())
+}
+
+// session returns the controller of a session, counting the caller as one of
+// its users.
+func (e *executionSlots) session(sessionID string) *sessionSlots {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	session, ok := e.sessions[sessionID]
+	if !ok {
+		session = &sessionSlots{controller: newAdmissionController(e.perSession)}
+		e.sessions[sessionID] = session
+	}
+	session.users++
+	return session
+}
+
+// leave stops counting a user of a session, dropping its controller once
+// unused.
+func (e *executionSlots) leave(sessionID string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	session, ok := e.sessions[sessionID]
+	if !ok {
+		return
+	}
+	session.users--
+	if session.users <= 0 {
+		delete(e.sessions, sessionID)
+	}
+}
+
+func (e *executionSlots) releaseFunc(sessionID string, releaseSession func(), releaseGlobal func()) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			releaseGlobal()
+			releaseSession()
+			e.leave(sessionID)
+		})
+	}
+}
+
+// admissionController limits the number of executions the driver submits to
+// the daemon concurrently. Tasks waiting for a slot are queued per job and the
+// queues are serviced round-robin, so a single job dispatching thousands of
+// executions cannot starve other jobs on the node.
+type admissionController struct {
+	// mu syncs access to all fields below
+	mu sync.Mutex
+
+	// limit is the maximum number of concurrent executions (0 = unlimited)
+	limit int
+
+	// active is the number of slots currently held
+	active int
+
+	// queues holds the waiters of each job, in arrival order
+	queues map[string][]*admissionWaiter
+
+	// order is the round-robin ring of jobs that have waiters
+	order []string
+
+	// next is the index in order of the job to service next
+	next int
+}
+
+// admissionWaiter is a task blocked waiting for an execution slot.
+type admissionWaiter struct {
+	ready   chan struct{}
+	granted bool
+}
+
+func newAdmissionController(limit int) *admissionController {
+	return &admissionController{
+		limit:  limit,
+		queues: map[string][]*admissionWaiter{},
+	}
+}
+
+// admissionKey returns the key tasks are grouped by for fairness. Dispatched
+// and periodic child jobs are grouped under their parent job.
+func admissionKey(cfg *drivers.TaskConfig) string {
+	jobID := cfg.JobID
+	if cfg.ParentJobID != "" {
+		jobID = cfg.ParentJobID
+	}
+	return cfg.Namespace + "/" + jobID
+}
+
+// Acquire blocks until an execution slot is available for the given job or ctx
+// is done. queued (may be nil) is called if the caller has to wait. The
+// returned release function gives the slot back and is safe to call more
+// than once.
+func (a *admissionController) Acquire(ctx context.Context, key string, queued func()) (func(), error) {
+	a.mu.Lock()
+	if a.limit <= 0 {
+		a.mu.Unlock()
+		return func() {}, nil
+	}
+	if a.active < a.limit && len(a.order) == 0 {
+		a.active++
+		a.mu.Unlock()
+		return a.releaseFunc(), nil
+	}
+
+	w := &admissionWaiter{ready: make(chan struct{})}
+	if len(a.queues[key]) == 0 {
+		a.order = append(a.order, key)
+	}
+	a.queues[key] = append(a.queues[key], w)
+	a.mu.Unlock()
+
+	if queued != nil {
+		queued()
+	}
+
+	select {
+	case <-w.ready:
+		return a.releaseFunc(), nil
+	case <-ctx.Done():
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		if w.granted {
+			// The slot was handed over while we were giving up.
+			a.active--
+			a.dispatchLocked()
+		} else {
+			a.removeLocked(key, w)
+		}
+		return nil, ctx.Err()
+	}
+}
+
+// Adopt takes a slot without waiting, used for executions that are already
+// running on the daemon (e.g. recovered tasks).
+func (a *admissionController) Adopt() func() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.limit <= 0 {
+		return func() {}
+	}
+	a.active++
+	return a.releaseFunc()
+}
+
+func (a *admissionController) releaseFunc() func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			a.mu.Lock()
+			defer a.mu.Unlock()
+			a.active--
+			a.dispatchLocked()
+		})
+	}
+}
+
+// dispatchLocked hands free slots to queued waiters, taking one waiter from
+// each job in turn. Callers must hold a.mu.
+func (a *admissionController) dispatchLocked() {
+	for a.active < a.limit && len(a.order) > 0 {
+		if a.next >= len(a.order) {
+			a.next = 0
+		}
+		key := a.order[a.next]
+		queue := a.queues[key]
+
+		w := queue[0]
+		if len(queue) == 1 {
+			delete(a.queues, key)
+			a.order = append(a.order[:a.next], a.order[a.next+1:]...)
+		} else {
+			a.queues[key] = queue[1:]
+			a.next++
+		}
+
+		a.active++
+		w.granted = true
+		close(w.ready)
+	}
+}
+
+// removeLocked drops a waiter that gave up before being granted a slot.
+// Callers must hold a.mu.
+func (a *admissionController) removeLocked(key string, w *admissionWaiter) {
+	queue := a.queues[key]
+	for i, qw := range queue {
+		if qw != w {
+			continue
+		}
+		queue = append(queue[:i], queue[i+1:]...)
+		break
+	}
+	if len(queue) > 0 {
+		a.queues[key] = queue
+		return
+	}
+
+	delete(a.queues, key)
+	for i, k := range a.order {
+		if k != key {
+			continue
+		}
+		a.order = append(a.order[:i], a.order[i+1:]...)
+		if i < a.next {
+			a.next--
+		}
+		break
+	}
+}
+
+// metricSlotTimeouts counts tasks that gave up waiting for an execution slot
+const metricSlotTimeouts = "execution_slot_timeouts_total"
+
+// acquireSlot waits for an execution slot for a task in a session. A task
+// that has to wait gets a task event saying so, and fails to start with a
+// recoverable error once queue_timeout passes.
+func (d *ElideDriverPlugin) acquireSlot(cfg *drivers.TaskConfig, sessionID string) (func(), error) {
+	ctx := d.ctx
+	queueTimeout, _ := ParseDuration("queue_timeout", d.config.QueueTimeout)
+	if queueTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, queueTimeout)
+		defer cancel()
+	}
+
+	var queuedAt time.Time
+	release, err := d.admission.Acquire(ctx, sessionID, admissionKey(cfg), func() {
+		queuedAt = time.Now()
+		d.logger.Debug("waiting for execution slot", "task_id", cfg.ID, "session_id", sessionID)
+		d.eventer.EmitEvent(&drivers.TaskEvent{
+			TaskID:      cfg.ID,
+			AllocID:     cfg.AllocID,
+			TaskName:    cfg.Name,
+			Timestamp:   queuedAt,
+			Message:     "Waiting for execution slot",
+			Annotations: map[string]string{"session_id": sessionID},
+		})
+	})
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) && d.ctx.Err() == nil {
+			d.metrics.IncrCounter(metricSlotTimeouts, "Tasks that gave up waiting for an execution slot after queue_timeout.")
+			return nil, nstructs.NewRecoverableError(fmt.Errorf("timed out after %s waiting for an execution slot", queueTimeout), true)
+		}
+		return nil, fmt.Errorf("failed to acquire execution slot: %w", err)
+	}
+	if !queuedAt.IsZero() {
+		d.logger.Debug("acquired execution slot", "task_id", cfg.ID, "waited", time.Since(queuedAt))
+	}
+	return release, nil
+}
@@ -0,0 +1,274 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/nomad/plugins/drivers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/elide-dev/elide-task-driver/proto/gen/go/elide/daemon/v1alpha1"
+)
+
+// tryAcquire takes slots of a session if they are free, without waiting: a
+// caller that would be queued gives up instead.
+func tryAcquire(e *executionSlots, sessionID string, key string) (func(), bool) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	release, err := e.Acquire(ctx, sessionID, key, cancel)
+	return release, err == nil
+}
+
+// acquireQueued starts acquiring a slot for a job in the background and
+// returns once the caller is queued. The release function of the granted
+// slot is sent on granted, or nil if ctx ended first.
+func acquireQueued(t *testing.T, ctx context.Context, a *admissionController, key string, granted chan<- func()) {
+	t.Helper()
+	queued := make(chan struct{})
+	go func() {
+		release, err := a.Acquire(ctx, key, func() { close(queued) })
+		if err != nil {
+			release = nil
+		}
+		granted <- release
+	}()
+	select {
+	case <-queued:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("acquiring a slot for %s was not queued", key)
+	}
+}
+
+func TestExecutionSlots_Limits(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		limit      int
+		perSession int
+		sessions   []string
+		want       []bool
+	}{
+		{
+			name:     "unlimited",
+			sessions: []string{"a", "a", "b"},
+			want:     []bool{true, true, true},
+		},
+		{
+			name:       "per-session limit",
+			perSession: 1,
+			sessions:   []string{"a", "a", "b", "b"},
+			want:       []bool{true, false, true, false},
+		},
+		{
+			name:     "node limit",
+			limit:    2,
+			sessions: []string{"a", "b", "c"},
+			want:     []bool{true, true, false},
+		},
+		{
+			name:       "session limit reached before the node's",
+			limit:      3,
+			perSession: 2,
+			sessions:   []string{"a", "a", "a", "b", "b"},
+			want:       []bool{true, true, false, true, false},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			e := newExecutionSlots(tc.limit, tc.perSession)
+			var releases []func()
+			for i, sessionID := range tc.sessions {
+				release, ok := tryAcquire(e, sessionID, "default/job")
+				assert.Equal(t, tc.want[i], ok, "acquire %d in session %s", i, sessionID)
+				if ok {
+					releases = append(releases, release)
+				}
+			}
+
+			for _, release := range releases {
+				release()
+				release()
+			}
+			assert.Empty(t, e.sessions, "controllers of unused sessions are dropped")
+			assert.Zero(t, e.global.active)
+		})
+	}
+}
+
+func TestAdmissionController_RoundRobin(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		arrivals []string
+		want     []string
+	}{
+		{
+			name:     "single job in arrival order",
+			arrivals: []string{"a", "a", "a"},
+			want:     []string{"a1", "a2", "a3"},
+		},
+		{
+			name:     "one waiter of each job in turn",
+			arrivals: []string{"a", "a", "a", "b", "c"},
+			want:     []string{"a1", "b1", "c1", "a2", "a3"},
+		},
+		{
+			name:     "interleaved jobs",
+			arrivals: []string{"a", "b", "b", "a", "b"},
+			want:     []string{"a1", "b1", "a2", "b2", "b3"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			a := newAdmissionController(1)
+			release, err := a.Acquire(context.Background(), "holder", nil)
+			require.NoError(t, err)
+
+			// Waiters are numbered per job, and each reports its grant on a
+			// channel of its own
+			granted := map[string]chan func(){}
+			count := map[string]int{}
+			for _, key := range tc.arrivals {
+				count[key]++
+				name := fmt.Sprintf("%s%d", key, count[key])
+				granted[name] = make(chan func(), 1)
+				acquireQueued(t, context.Background(), a, key, granted[name])
+			}
+
+			for _, name := range tc.want {
+				release()
+				select {
+				case release = <-granted[name]:
+					require.NotNil(t, release, name)
+				case <-time.After(5 * time.Second):
+					t.Fatalf("%s was not granted the next slot", name)
+				}
+			}
+			release()
+			assert.Zero(t, a.active)
+			assert.Empty(t, a.order)
+		})
+	}
+}
+
+func TestAdmissionController_CancelQueued(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		arrivals  []string
+		cancelled int
+		want      []int
+	}{
+		{
+			name:      "only waiter",
+			arrivals:  []string{"a"},
+			cancelled: 0,
+		},
+		{
+			name:      "head of its job's queue",
+			arrivals:  []string{"a", "a", "b"},
+			cancelled: 0,
+			want:      []int{1, 2},
+		},
+		{
+			name:      "job of its own",
+			arrivals:  []string{"a", "b", "c"},
+			cancelled: 1,
+			want:      []int{0, 2},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			a := newAdmissionController(1)
+			release, err := a.Acquire(context.Background(), "holder", nil)
+			require.NoError(t, err)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			granted := make([]chan func(), len(tc.arrivals))
+			for i, key := range tc.arrivals {
+				granted[i] = make(chan func(), 1)
+				waiterCtx := context.Background()
+				if i == tc.cancelled {
+					waiterCtx = ctx
+				}
+				acquireQueued(t, waiterCtx, a, key, granted[i])
+			}
+
+			cancel()
+			assert.Nil(t, <-granted[tc.cancelled], "a cancelled waiter gets no slot")
+			a.mu.Lock()
+			waiting := 0
+			for _, queue := range a.queues {
+				waiting += len(queue)
+			}
+			a.mu.Unlock()
+			assert.Equal(t, len(tc.arrivals)-1, waiting, "the cancelled waiter leaves its queue")
+
+			for _, i := range tc.want {
+				release()
+				select {
+				case release = <-granted[i]:
+					require.NotNil(t, release, i)
+				case <-time.After(5 * time.Second):
+					t.Fatalf("waiter %d was not granted the next slot", i)
+				}
+			}
+			release()
+			assert.Zero(t, a.active, "the cancelled waiter's turn holds no slot")
+			assert.Empty(t, a.order)
+		})
+	}
+}
+
+// failingSubmitClient fails every execution submitted through it.
+type failingSubmitClient struct {
+	sessionDeletingClient
+}
+
+func (c *failingSubmitClient) CreateSession(ctx context.Context, sessionID string, config *pb.SessionConfiguration) (*pb.CreateSessionResponse, error) {
+	return &pb.CreateSessionResponse{SessionId: sessionID, CreatedAt: time.Now().Unix()}, nil
+}
+
+func (c *failingSubmitClient) ExecuteSnippet(ctx context.Context, req ExecuteSnippetRequest) (*pb.ExecuteSnippetResponse, error) {
+	return nil, status.Error(codes.InvalidArgument, "syntax error")
+}
+
+func TestStartTask_ReleasesSlotWhenSubmitFails(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		limit      int
+		perSession int
+	}{
+		{name: "node limit", limit: 1},
+		{name: "per-session limit", perSession: 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			d := NewPlugin(hclog.NewNullLogger()).(*ElideDriverPlugin)
+			defer d.Shutdown()
+			d.daemonClient = &failingSubmitClient{}
+			d.admission = newExecutionSlots(tc.limit, tc.perSession)
+
+			for i := range 2 {
+				cfg := &drivers.TaskConfig{
+					ID:        fmt.Sprintf("task-%d", i),
+					JobID:     "job",
+					Namespace: "default",
+					AllocDir:  t.TempDir(),
+				}
+				require.NoError(t, cfg.EncodeConcreteDriverConfig(&TaskConfig{Language: "python", Code: "print(1)"}))
+
+				// The second task would wait for the first's slot forever
+				// if the failed submission kept it
+				_, _, err := d.StartTask(cfg)
+				require.ErrorContains(t, err, "syntax error")
+			}
+
+			assert.Empty(t, d.admission.sessions)
+			assert.Zero(t, d.admission.global.active)
+		})
+	}
+}
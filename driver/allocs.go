@@ -0,0 +1,188 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/nomad/plugins/drivers"
+)
+
+const (
+	// adminAllocsPath is the admin API path reporting per-alloc execution
+	// counts
+	adminAllocsPath = "/v1/debug/allocs"
+
+	// allocUsageRetention is how long the driver remembers the execution
+	// count of an alloc after its last task was destroyed
+	allocUsageRetention = time.Hour
+)
+
+// Tags attached to sessions and executions, so a daemon-side quota system can
+// attribute usage to the node and alloc it came from
+const (
+	tagAllocID        = "alloc_id"
+	tagNodeID         = "node_id"
+	tagNamespace      = "namespace"
+	tagJobID          = "job_id"
+	tagHost           = "host"
+	tagSessionProfile = "session_profile"
+)
+
+// AllocUsage is the driver's local view of the executions of an alloc.
+type AllocUsage struct {
+	AllocID   string `json:"alloc_id"`
+	NodeID    string `json:"node_id,omitempty"`
+	Namespace string `json:"namespace"`
+	JobID     string `json:"job_id"`
+
+	// Executions counts the executions submitted (or recovered) for the
+	// alloc since the driver started, including those of destroyed tasks
+	Executions int `json:"executions"`
+
+	// Tasks, Running and Failed describe the alloc's tracked tasks
+	Tasks   int `json:"tasks"`
+	Running int `json:"running"`
+	Failed  int `json:"failed"`
+
+	LastStarted time.Time `json:"last_started"`
+
+	// codeHashes maps task names to the hash of the code of their first
+	// execution in the alloc
+	codeHashes map[string]string
+}
+
+// allocTracker aggregates execution counts per alloc.
+type allocTracker struct {
+	mu     sync.Mutex
+	allocs map[string]*AllocUsage
+}
+
+func newAllocTracker() *allocTracker {
+	return &allocTracker{allocs: map[string]*AllocUsage{}}
+}
+
+// started counts an execution of the task's alloc, and records its code hash
+// if it is the task's first execution in the alloc.
+func (t *allocTracker) started(cfg *drivers.TaskConfig, codeHash string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	usage, ok := t.allocs[cfg.AllocID]
+	if !ok {
+		usage = &AllocUsage{
+			AllocID:   cfg.AllocID,
+			NodeID:    cfg.NodeID,
+			Namespace: cfg.Namespace,
+			JobID:     cfg.JobID,
+
+			codeHashes: map[string]string{},
+		}
+		t.allocs[cfg.AllocID] = usage
+	}
+	usage.Executions++
+	usage.LastStarted = time.Now()
+	if _, ok := usage.codeHashes[cfg.Name]; !ok && codeHash != "" {
+		usage.codeHashes[cfg.Name] = codeHash
+	}
+}
+
+// originalCodeHash returns the code hash of the task's first execution in its
+// alloc, or "" if the task has not run before.
+func (t *allocTracker) originalCodeHash(cfg *drivers.TaskConfig) string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if usage, ok := t.allocs[cfg.AllocID]; ok {
+		return usage.codeHashes[cfg.Name]
+	}
+	return ""
+}
+
+// snapshot returns the usage of every alloc, sorted by alloc ID, with task
+// counts taken from the task store. Allocs without tasks are forgotten once
+// allocUsageRetention has passed since their last execution started.
+func (t *allocTracker) snapshot(tasks *taskStore) []*AllocUsage {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	usages := make(map[string]*AllocUsage, len(t.allocs))
+	for id, usage := range t.allocs {
+		u := *usage
+		usages[id] = &u
+	}
+	for _, h := range tasks.List() {
+		u, ok := usages[h.taskConfig.AllocID]
+		if !ok {
+			continue
+		}
+		u.Tasks++
+		h.stateLock.RLock()
+		if h.exitResult == nil {
+			u.Running++
+		} else if !h.exitResult.Successful() {
+			u.Failed++
+		}
+		h.stateLock.RUnlock()
+	}
+
+	result := make([]*AllocUsage, 0, len(usages))
+	for id, u := range usages {
+		if u.Tasks == 0 && time.Since(u.LastStarted) > allocUsageRetention {
+			delete(t.allocs, id)
+			continue
+		}
+		result = append(result, u)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].AllocID < result[j].AllocID })
+	return result
+}
+
+// AllocUsage returns the driver's per-alloc execution counts.
+func (d *ElideDriverPlugin) AllocUsage() []*AllocUsage {
+	return d.allocs.snapshot(d.tasks)
+}
+
+// handleAdminAllocs reports the per-alloc execution counts.
+func (d *ElideDriverPlugin) handleAdminAllocs(w http.ResponseWriter, r *http.Request) {
+	writeAdminJSON(w, http.StatusOK, d.AllocUsage())
+}
+
+// executionTags returns the tags an execution is submitted with.
+func executionTags(cfg *drivers.TaskConfig) map[string]string {
+	tags := map[string]string{}
+	setTag(tags, tagAllocID, cfg.AllocID)
+	setTag(tags, tagNodeID, cfg.NodeID)
+	setTag(tags, tagNamespace, cfg.Namespace)
+	setTag(tags, tagJobID, cfg.JobID)
+	return tags
+}
+
+// sessionTags returns the tags sessions are created with. Nomad only tells
+// drivers the node ID with each task, so sessions created before the first
+// task only carry the host name.
+func (d *ElideDriverPlugin) sessionTags() map[string]string {
+	tags := map[string]string{}
+	setTag(tags, tagHost, hostname())
+	if nodeID, _ := d.nodeID.Load().(string); nodeID != "" {
+		tags[tagNodeID] = nodeID
+	}
+	return tags
+}
+
+// observeNode records the node ID Nomad passes with each task.
+func (d *ElideDriverPlugin) observeNode(cfg *drivers.TaskConfig) {
+	if cfg.NodeID != "" {
+		d.nodeID.Store(cfg.NodeID)
+	}
+}
+
+func setTag(tags map[string]string, name string, value string) {
+	if value != "" {
+		tags[name] = value
+	}
+}
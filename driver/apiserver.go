@@ -0,0 +1,426 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/hashicorp/nomad/helper/pluginutils/hclspecutils"
+	"github.com/hashicorp/nomad/helper/pluginutils/hclutils"
+	"github.com/hashicorp/nomad/helper/uuid"
+	nstructs "github.com/hashicorp/nomad/nomad/structs"
+	"github.com/hashicorp/nomad/plugins/drivers"
+
+	pb "github.com/elide-dev/elide-task-driver/proto/gen/go/elide/daemon/v1alpha1"
+)
+
+const (
+	// apiExecutionsPath is the collection of executions submitted through
+	// the standalone API
+	apiExecutionsPath = "/v1/executions"
+
+	// apiMaxRequestBytes bounds the size of a submission
+	apiMaxRequestBytes = 8 << 20
+
+	// apiNamespace and apiJobID group API submissions for admission control
+	apiNamespace = "api"
+	apiJobID     = "api"
+
+	// initiatorAPI is recorded as the initiator of cancellations requested
+	// through the standalone API
+	initiatorAPI = "api"
+
+	// defaultAPIMaxRetained is how many finished executions the API keeps
+	// for inspection by default
+	defaultAPIMaxRetained = 1000
+)
+
+// APIServer exposes the driver's execution logic (submit, status, cancel)
+// over HTTP for callers on the node that do not go through Nomad. It reuses
+// the plugin's daemon client, sessions, admission control and status polling;
+// each submission is run as a task with its own directory under dataDir.
+type APIServer struct {
+	plugin  *ElideDriverPlugin
+	dataDir string
+	opts    APIServerOptions
+	server  *http.Server
+
+	// taskSpec decodes submitted task configs with the job spec's defaults
+	taskSpec hcldec.Spec
+
+	// pruneLock serializes forgetting finished executions
+	pruneLock sync.Mutex
+}
+
+// APIServerOptions configures access to the standalone API and how long it
+// keeps executions.
+type APIServerOptions struct {
+	// Token is the bearer token requests must carry, other than health
+	// checks; required to listen beyond loopback (see ListenAPI)
+	Token string
+
+	// MaxRetained caps the finished executions kept for inspection: beyond
+	// it the oldest are forgotten as by DELETE (defaultAPIMaxRetained if
+	// zero)
+	MaxRetained int
+}
+
+// apiSubmitRequest is the body of an execution submission. Config takes the
+// same options as the task config block of a job spec.
+type apiSubmitRequest struct {
+	Name   string                 `json:"name"`
+	Config map[string]interface{} `json:"config"`
+}
+
+// apiExecution is the API view of an execution.
+type apiExecution struct {
+	ID          string            `json:"id"`
+	Name        string            `json:"name"`
+	State       string            `json:"state"`
+	StartedAt   time.Time         `json:"started_at"`
+	CompletedAt *time.Time        `json:"completed_at,omitempty"`
+	ExitCode    *int              `json:"exit_code,omitempty"`
+	Error       string            `json:"error,omitempty"`
+	Attributes  map[string]string `json:"attributes"`
+}
+
+// NewAPIServer returns an API server running executions on the plugin, which
+// must already be configured.
+func NewAPIServer(plugin *ElideDriverPlugin, dataDir string, opts APIServerOptions) (*APIServer, error) {
+	taskSpec, diags := hclspecutils.Convert(taskConfigSpec)
+	if diags.HasErrors() {
+		return nil, fmt.Errorf("failed to convert task config spec: %s", diags.Error())
+	}
+	if opts.MaxRetained < 0 {
+		return nil, fmt.Errorf("invalid max retained executions %d: cannot be negative", opts.MaxRetained)
+	}
+	if opts.MaxRetained == 0 {
+		opts.MaxRetained = defaultAPIMaxRetained
+	}
+
+	s := &APIServer{
+		plugin:   plugin,
+		dataDir:  dataDir,
+		opts:     opts,
+		taskSpec: taskSpec,
+	}
+	s.server = &http.Server{Handler: s.Handler(), ReadHeaderTimeout: 10 * time.Second}
+	return s, nil
+}
+
+// Handler returns the HTTP handler serving the API.
+func (s *APIServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST "+apiExecutionsPath, s.handleSubmit)
+	mux.HandleFunc("GET "+apiExecutionsPath, s.handleList)
+	mux.HandleFunc("GET "+apiExecutionsPath+"/{id}", s.handleGet)
+	mux.HandleFunc("DELETE "+apiExecutionsPath+"/{id}", s.handleDelete)
+	mux.HandleFunc("POST "+apiExecutionsPath+"/{id}/cancel", s.handleCancel)
+	mux.HandleFunc("GET "+apiExecutionsPath+"/{id}/{stream}", s.handleOutput)
+	mux.HandleFunc("GET /v1/metrics", s.plugin.handleMetrics)
+	mux.HandleFunc("GET "+healthzPath, s.plugin.handleHealthz)
+	mux.HandleFunc("GET "+readyzPath, s.plugin.handleReadyz)
+	mux.HandleFunc("GET "+adminAllocsPath, s.plugin.handleAdminAllocs)
+	if s.opts.Token == "" {
+		return mux
+	}
+	return s.authenticate(mux)
+}
+
+// authenticate requires the bearer token on requests other than health
+// checks, so supervisors and load balancers can probe the server.
+func (s *APIServer) authenticate(next http.Handler) http.Handler {
+	want := []byte("Bearer " + s.opts.Token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != healthzPath && r.URL.Path != readyzPath &&
+			subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), want) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="elide"`)
+			writeAdminJSON(w, http.StatusUnauthorized, map[string]string{"error": "missing or invalid bearer token"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ListenAPI listens on a Unix socket if address is a path, or on TCP
+// otherwise. The API runs code on the node, so TCP addresses beyond
+// loopback are refused unless requests are authenticated with a token.
+func ListenAPI(address string, authenticated bool) (net.Listener, error) {
+	if !strings.HasPrefix(address, "/") && !strings.HasPrefix(address, ".") {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return nil, fmt.Errorf("invalid listen address %q: %w", address, err)
+		}
+		if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) && !authenticated {
+			return nil, fmt.Errorf("refusing to serve the API on %q without a token: listen on a Unix socket or a loopback address, or set a token", address)
+		}
+		return net.Listen("tcp", address)
+	}
+
+	// Remove a stale socket left behind by a previous instance
+	if err := os.Remove(address); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	lis, err := net.Listen("unix", address)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(address, 0600); err != nil {
+		lis.Close()
+		return nil, err
+	}
+	return lis, nil
+}
+
+// Serve serves the API on lis until Close is called.
+func (s *APIServer) Serve(lis net.Listener) error {
+	s.plugin.logger.Info("standalone API listening", "address", lis.Addr().String())
+	if err := s.server.Serve(lis); err != nil && err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+// Close stops accepting requests. Running executions are left to the
+// plugin's Shutdown.
+func (s *APIServer) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return s.server.Shutdown(ctx)
+}
+
+// handleSubmit starts an execution and returns its initial status.
+func (s *APIServer) handleSubmit(w http.ResponseWriter, r *http.Request) {
+	var req apiSubmitRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, apiMaxRequestBytes)).Decode(&req); err != nil {
+		writeAdminJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("invalid request: %v", err)})
+		return
+	}
+	if req.Name == "" {
+		req.Name = "snippet"
+	}
+	if !isProfileName(req.Name) {
+		writeAdminJSON(w, http.StatusBadRequest, map[string]string{"error": "name may only contain letters, digits, '-' and '_'"})
+		return
+	}
+
+	cfg, err := s.taskConfig(req)
+	if err != nil {
+		writeAdminJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	if _, _, err := s.plugin.StartTask(cfg); err != nil {
+		os.RemoveAll(cfg.AllocDir)
+		status := http.StatusBadRequest
+		if nstructs.IsRecoverable(err) {
+			status = http.StatusServiceUnavailable
+		}
+		writeAdminJSON(w, status, map[string]string{"error": err.Error()})
+		return
+	}
+
+	// Poll the execution to completion; the result stays on the task handle
+	// until the execution is deleted or pruned
+	ch, err := s.plugin.WaitTask(s.plugin.ctx, cfg.ID)
+	if err == nil {
+		go func() {
+			for range ch {
+			}
+			s.pruneFinished()
+		}()
+	}
+
+	s.writeExecution(w, http.StatusCreated, cfg.ID)
+}
+
+// taskConfig builds the Nomad task config of a submission, decoding its
+// config with the same defaults and validation as a job spec.
+func (s *APIServer) taskConfig(req apiSubmitRequest) (*drivers.TaskConfig, error) {
+	if req.Config == nil {
+		req.Config = map[string]interface{}{}
+	}
+	value, _, errs := hclutils.ParseHclInterface(req.Config, s.taskSpec, nil)
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("invalid config: %w", errors.Join(errs...))
+	}
+
+	id := uuid.Generate()
+	cfg := &drivers.TaskConfig{
+		ID:        id,
+		JobName:   apiJobID,
+		JobID:     apiJobID,
+		Namespace: apiNamespace,
+		Name:      req.Name,
+		AllocID:   id,
+		AllocDir:  filepath.Join(s.dataDir, id),
+	}
+	if err := cfg.EncodeDriverConfig(value); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+
+	// Output shipped to the "log" streams lands in files served by the API
+	taskDir := cfg.TaskDir()
+	if err := os.MkdirAll(taskDir.LocalDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create task directory: %w", err)
+	}
+	cfg.StdoutPath = filepath.Join(taskDir.Dir, "stdout")
+	cfg.StderrPath = filepath.Join(taskDir.Dir, "stderr")
+	for _, path := range []string{cfg.StdoutPath, cfg.StderrPath} {
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			return nil, fmt.Errorf("failed to create output file: %w", err)
+		}
+	}
+	return cfg, nil
+}
+
+// handleList returns all executions known to the server.
+func (s *APIServer) handleList(w http.ResponseWriter, r *http.Request) {
+	executions := []*apiExecution{}
+	for _, h := range s.plugin.tasks.List() {
+		executions = append(executions, newAPIExecution(h.TaskStatus()))
+	}
+	writeAdminJSON(w, http.StatusOK, executions)
+}
+
+// handleGet returns the status of an execution.
+func (s *APIServer) handleGet(w http.ResponseWriter, r *http.Request) {
+	s.writeExecution(w, http.StatusOK, r.PathValue("id"))
+}
+
+// handleCancel cancels a running execution.
+func (s *APIServer) handleCancel(w http.ResponseWriter, r *http.Request) {
+	handle, ok := s.plugin.tasks.Get(r.PathValue("id"))
+	if !ok {
+		writeAdminJSON(w, http.StatusNotFound, map[string]string{"error": drivers.ErrTaskNotFound.Error()})
+		return
+	}
+
+	ctx, cancel := s.plugin.withTimeout(r.Context(), s.plugin.cancelTimeout())
+	defer cancel()
+	err := s.plugin.cancelExecution(ctx, handle, pb.CancellationReason_CANCELLATION_REASON_USER_STOP, initiatorAPI, nil)
+	if err != nil {
+		writeAdminJSON(w, http.StatusBadGateway, map[string]string{"error": fmt.Sprintf("failed to cancel execution: %v", err)})
+		return
+	}
+	s.writeExecution(w, http.StatusAccepted, handle.taskConfig.ID)
+}
+
+// handleDelete forgets a finished execution and removes its directory.
+func (s *APIServer) handleDelete(w http.ResponseWriter, r *http.Request) {
+	handle, ok := s.plugin.tasks.Get(r.PathValue("id"))
+	if !ok {
+		writeAdminJSON(w, http.StatusNotFound, map[string]string{"error": drivers.ErrTaskNotFound.Error()})
+		return
+	}
+	if handle.IsRunning() {
+		writeAdminJSON(w, http.StatusConflict, map[string]string{"error": "execution is still running; cancel it first"})
+		return
+	}
+
+	if err := s.forget(handle); err != nil {
+		writeAdminJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// forget destroys a finished execution's task and removes its directory.
+func (s *APIServer) forget(handle *taskHandle) error {
+	if err := s.plugin.DestroyTask(handle.taskConfig.ID, false); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(handle.taskConfig.AllocDir); err != nil {
+		s.plugin.logger.Warn("failed to remove execution directory", "task_id", handle.taskConfig.ID, "error", err)
+	}
+	return nil
+}
+
+// pruneFinished forgets the executions that finished first while more than
+// MaxRetained finished executions are kept.
+func (s *APIServer) pruneFinished() {
+	s.pruneLock.Lock()
+	defer s.pruneLock.Unlock()
+
+	var finished []*taskHandle
+	for _, h := range s.plugin.tasks.List() {
+		if !h.IsRunning() {
+			finished = append(finished, h)
+		}
+	}
+	if len(finished) <= s.opts.MaxRetained {
+		return
+	}
+	slices.SortFunc(finished, func(a, b *taskHandle) int {
+		return a.TaskStatus().CompletedAt.Compare(b.TaskStatus().CompletedAt)
+	})
+	for _, h := range finished[:len(finished)-s.opts.MaxRetained] {
+		if err := s.forget(h); err != nil {
+			s.plugin.logger.Warn("failed to prune finished execution", "task_id", h.taskConfig.ID, "error", err)
+		}
+	}
+}
+
+// handleOutput serves the stdout or stderr of an execution.
+func (s *APIServer) handleOutput(w http.ResponseWriter, r *http.Request) {
+	handle, ok := s.plugin.tasks.Get(r.PathValue("id"))
+	if !ok {
+		writeAdminJSON(w, http.StatusNotFound, map[string]string{"error": drivers.ErrTaskNotFound.Error()})
+		return
+	}
+
+	var path string
+	switch r.PathValue("stream") {
+	case "stdout":
+		path = handle.taskConfig.StdoutPath
+	case "stderr":
+		path = handle.taskConfig.StderrPath
+	default:
+		writeAdminJSON(w, http.StatusNotFound, map[string]string{"error": "unknown output stream"})
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	http.ServeFile(w, r, path)
+}
+
+// writeExecution writes the status of the execution with the given ID.
+func (s *APIServer) writeExecution(w http.ResponseWriter, status int, id string) {
+	taskStatus, err := s.plugin.InspectTask(id)
+	if err != nil {
+		writeAdminJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+		return
+	}
+	writeAdminJSON(w, status, newAPIExecution(taskStatus))
+}
+
+func newAPIExecution(status *drivers.TaskStatus) *apiExecution {
+	exec := &apiExecution{
+		ID:         status.ID,
+		Name:       status.Name,
+		State:      string(status.State),
+		StartedAt:  status.StartedAt,
+		Attributes: status.DriverAttributes,
+	}
+	if result := status.ExitResult; result != nil {
+		exec.CompletedAt = &status.CompletedAt
+		exec.ExitCode = &result.ExitCode
+		if result.Err != nil {
+			exec.Error = result.Err.Error()
+		}
+	}
+	return exec
+}
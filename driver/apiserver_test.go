@@ -0,0 +1,51 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/nomad/plugins/drivers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIServer_PrunesOldestFinishedExecutions(t *testing.T) {
+	d := NewPlugin(hclog.NewNullLogger()).(*ElideDriverPlugin)
+	defer d.Shutdown()
+	dataDir := t.TempDir()
+	s, err := NewAPIServer(d, dataDir, APIServerOptions{MaxRetained: 2})
+	require.NoError(t, err)
+
+	completed := time.Now()
+	addTask := func(id string, finished time.Duration) {
+		cfg := &drivers.TaskConfig{ID: id, AllocDir: filepath.Join(dataDir, id)}
+		require.NoError(t, os.MkdirAll(cfg.AllocDir, 0755))
+		h := &taskHandle{taskConfig: cfg, logger: d.logger}
+		if finished > 0 {
+			h.exitResult = &drivers.ExitResult{}
+			h.completedAt = completed.Add(finished)
+		}
+		d.tasks.Set(id, h)
+	}
+	for i := 1; i <= 4; i++ {
+		addTask(fmt.Sprintf("finished-%d", i), time.Duration(i)*time.Second)
+	}
+	addTask("running", 0)
+
+	s.pruneFinished()
+
+	var kept []string
+	for _, h := range d.tasks.List() {
+		kept = append(kept, h.taskConfig.ID)
+	}
+	assert.ElementsMatch(t, []string{"finished-3", "finished-4", "running"}, kept)
+	assert.NoDirExists(t, filepath.Join(dataDir, "finished-1"))
+	assert.DirExists(t, filepath.Join(dataDir, "finished-4"))
+}
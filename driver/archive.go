@@ -0,0 +1,264 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/hashicorp/go-hclog"
+
+	pb "github.com/elide-dev/elide-task-driver/proto/gen/go/elide/daemon/v1alpha1"
+)
+
+const (
+	// archiveUploadTimeout bounds the upload of all objects of one execution
+	archiveUploadTimeout = 60 * time.Second
+)
+
+// archiveResult is the result object stored alongside the output streams.
+type archiveResult struct {
+	TaskID      string    `json:"task_id"`
+	AllocID     string    `json:"alloc_id"`
+	TaskName    string    `json:"task_name"`
+	ExecutionID string    `json:"execution_id"`
+	SessionID   string    `json:"session_id"`
+	Status      string    `json:"status"`
+	ExitCode    int32     `json:"exit_code"`
+	Error       string    `json:"error,omitempty"`
+	Result      string    `json:"result,omitempty"`
+	StartedAt   time.Time `json:"started_at"`
+	CompletedAt time.Time `json:"completed_at"`
+}
+
+// outputArchiver uploads execution output to object storage.
+type outputArchiver struct {
+	config    OutputArchiveConfig
+	endpoint  *url.URL
+	accessKey string
+	secretKey string
+	client    *http.Client
+	logger    hclog.Logger
+}
+
+// newOutputArchiver validates the archive config and loads its credentials.
+func newOutputArchiver(config OutputArchiveConfig, logger hclog.Logger) (*outputArchiver, error) {
+	if config.Endpoint == "" {
+		return nil, fmt.Errorf("output_archive: endpoint must be specified")
+	}
+	endpoint, err := url.Parse(config.Endpoint)
+	if err != nil || endpoint.Host == "" {
+		return nil, fmt.Errorf("output_archive: invalid endpoint %q", config.Endpoint)
+	}
+	if config.Region == "" {
+		config.Region = "us-east-1"
+	}
+
+	accessKey, secretKey, err := readArchiveCredentials(config.CredentialsFile)
+	if err != nil {
+		return nil, fmt.Errorf("output_archive: %w", err)
+	}
+
+	return &outputArchiver{
+		config:    config,
+		endpoint:  endpoint,
+		accessKey: accessKey,
+		secretKey: secretKey,
+		client:    &http.Client{Timeout: archiveUploadTimeout},
+		logger:    logger.Named("archive"),
+	}, nil
+}
+
+// readArchiveCredentials reads the access and secret key from an AWS-style
+// credentials file. Section headers are ignored; the first value wins.
+func readArchiveCredentials(path string) (string, string, error) {
+	if path == "" {
+		return "", "", fmt.Errorf("credentials_file must be specified")
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to open credentials file: %w", err)
+	}
+	defer f.Close()
+
+	var accessKey, secretKey string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), "=")
+		if !ok {
+			continue
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		switch {
+		case key == "aws_access_key_id" && accessKey == "":
+			accessKey = value
+		case key == "aws_secret_access_key" && secretKey == "":
+			secretKey = value
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", "", fmt.Errorf("failed to read credentials file: %w", err)
+	}
+	if accessKey == "" || secretKey == "" {
+		return "", "", fmt.Errorf("credentials file %q must set aws_access_key_id and aws_secret_access_key", path)
+	}
+	return accessKey, secretKey, nil
+}
+
+// Archive uploads stdout, stderr and the execution result of a completed task.
+// Objects are stored under <prefix><alloc_id>/<task_name>/.
+func (a *outputArchiver) Archive(ctx context.Context, h *taskHandle, status *pb.GetExecutionStatusResponse) error {
+	h.stateLock.RLock()
+	result := archiveResult{
+		TaskID:      h.taskConfig.ID,
+		AllocID:     h.taskConfig.AllocID,
+		TaskName:    h.taskConfig.Name,
+		ExecutionID: h.executionId,
+		SessionID:   h.sessionId,
+		Status:      status.Status.String(),
+		ExitCode:    status.ExitCode,
+		Error:       status.Error,
+		Result:      status.Result,
+		StartedAt:   h.startedAt,
+		CompletedAt: h.completedAt,
+	}
+	h.stateLock.RUnlock()
+
+	resultJSON, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode result: %w", err)
+	}
+
+	keyPrefix := a.config.Prefix + path.Join(result.AllocID, result.TaskName) + "/"
+	objects := []struct {
+		name        string
+		contentType string
+		body        []byte
+	}{
+		{"stdout", outputContentType(status.Stdout), []byte(status.Stdout)},
+		{"stderr", outputContentType(status.Stderr), []byte(status.Stderr)},
+		{"result.json", "application/json", resultJSON},
+	}
+
+	for _, obj := range objects {
+		if err := a.put(ctx, keyPrefix+obj.name, obj.contentType, obj.body); err != nil {
+			return fmt.Errorf("failed to upload %s: %w", obj.name, err)
+		}
+	}
+
+	a.logger.Debug("archived execution output", "task_id", result.TaskID, "bucket", a.config.Bucket, "prefix", keyPrefix)
+	return nil
+}
+
+// outputContentType returns the content type of archived output: text,
+// unless the execution wrote binary output.
+func outputContentType(output string) string {
+	if !utf8.ValidString(output) {
+		return "application/octet-stream"
+	}
+	return "text/plain; charset=utf-8"
+}
+
+// put uploads a single object using a path-style URL and AWS Signature V4.
+func (a *outputArchiver) put(ctx context.Context, key, contentType string, body []byte) error {
+	u := *a.endpoint
+	u.Path = "/" + a.config.Bucket + "/" + key
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	a.sign(req, body, time.Now().UTC())
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("object store returned %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+// archiveSignedHeaders are the headers signed on every upload.
+var archiveSignedHeaders = []string{"content-type", "host", "x-amz-content-sha256", "x-amz-date"}
+
+// sign adds AWS Signature Version 4 headers to an S3 request.
+func (a *outputArchiver) sign(req *http.Request, body []byte, now time.Time) {
+	payloadHash := sha256Hex(body)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	signV4(req, payloadHash, a.accessKey, a.secretKey, a.config.Region, "s3", archiveSignedHeaders, now)
+}
+
+// signV4 sets the X-Amz-Date and Authorization headers of a request signed
+// with AWS Signature Version 4. signedHeaders are lowercase header names in
+// sorted order; the host is taken from the request URL. Query strings are
+// signed as is, so they must already be in canonical form.
+func signV4(req *http.Request, payloadHash, accessKey, secretKey, region, service string, signedHeaders []string, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	req.Header.Set("Host", req.URL.Host)
+	req.Header.Set("X-Amz-Date", amzDate)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range signedHeaders {
+		value := req.Header.Get(name)
+		if name == "host" {
+			value = req.URL.Host
+		}
+		canonicalHeaders.WriteString(name + ":" + strings.Join(strings.Fields(value), " ") + "\n")
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		strings.Join(signedHeaders, ";"),
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+secretKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKey, scope, strings.Join(signedHeaders, ";"), signature,
+	))
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,143 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/nomad/plugins/drivers"
+
+	pb "github.com/elide-dev/elide-task-driver/proto/gen/go/elide/daemon/v1alpha1"
+)
+
+// trackedExecutions is the number of executions a driver instance should be
+// able to track concurrently.
+const trackedExecutions = 10000
+
+// benchDaemonClient answers status polls with a fixed running status.
+type benchDaemonClient struct {
+	DaemonClient
+	status *pb.GetExecutionStatusResponse
+}
+
+func (c *benchDaemonClient) GetExecutionStatus(ctx context.Context, sessionID string, executionID string, output OutputRequest) (*pb.GetExecutionStatusResponse, error) {
+	return c.status, nil
+}
+
+func newBenchPlugin() *ElideDriverPlugin {
+	d := NewPlugin(hclog.NewNullLogger()).(*ElideDriverPlugin)
+	d.daemonClient = &benchDaemonClient{
+		status: &pb.GetExecutionStatusResponse{
+			Status:   pb.ExecutionStatus_EXECUTION_STATUS_RUNNING,
+			Message:  "running",
+			QueuedAt: time.Now().UnixMilli(),
+		},
+	}
+	return d
+}
+
+func newBenchHandle(id string) *taskHandle {
+	return &taskHandle{
+		executionId: id,
+		sessionId:   "bench-session",
+		taskConfig:  &drivers.TaskConfig{ID: id, Name: "bench"},
+		startedAt:   time.Now(),
+		logger:      hclog.NewNullLogger(),
+	}
+}
+
+func BenchmarkPollExecution(b *testing.B) {
+	d := newBenchPlugin()
+	handle := newBenchHandle("exec-1")
+	ctx := context.Background()
+	var lastScratchCheck time.Time
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, done := d.pollExecution(ctx, handle, &lastScratchCheck); done {
+			b.Fatal("execution unexpectedly completed")
+		}
+	}
+}
+
+func BenchmarkUpdateStatus(b *testing.B) {
+	handle := newBenchHandle("exec-1")
+	queuedAt := time.Now().UnixMilli()
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		handle.updateStatus(pb.ExecutionStatus_EXECUTION_STATUS_RUNNING, "running", queuedAt)
+	}
+}
+
+func BenchmarkTaskStatus(b *testing.B) {
+	handle := newBenchHandle("exec-1")
+	handle.updateStatus(pb.ExecutionStatus_EXECUTION_STATUS_RUNNING, "running", time.Now().UnixMilli())
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = handle.TaskStatus()
+	}
+}
+
+func BenchmarkTaskStoreGet(b *testing.B) {
+	store := newTaskStore()
+	ids := make([]string, trackedExecutions)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("task-%d", i)
+		store.Set(ids[i], newBenchHandle(ids[i]))
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			if _, ok := store.Get(ids[i%len(ids)]); !ok {
+				b.Fatal("missing task")
+			}
+			i++
+		}
+	})
+}
+
+func BenchmarkTaskStoreSetDelete(b *testing.B) {
+	store := newTaskStore()
+	for i := 0; i < trackedExecutions; i++ {
+		id := fmt.Sprintf("task-%d", i)
+		store.Set(id, newBenchHandle(id))
+	}
+	handle := newBenchHandle("churn")
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		store.Set("churn", handle)
+		store.Delete("churn")
+	}
+}
+
+// BenchmarkPollTrackedExecutions polls every tracked execution once per
+// iteration, i.e. one poll interval's worth of work for a full driver.
+func BenchmarkPollTrackedExecutions(b *testing.B) {
+	d := newBenchPlugin()
+	handles := make([]*taskHandle, trackedExecutions)
+	checks := make([]time.Time, trackedExecutions)
+	for i := range handles {
+		handles[i] = newBenchHandle(fmt.Sprintf("exec-%d", i))
+	}
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for j, handle := range handles {
+			d.pollExecution(ctx, handle, &checks[j])
+		}
+	}
+}
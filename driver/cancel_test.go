@@ -0,0 +1,143 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/nomad/plugins/drivers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/elide-dev/elide-task-driver/proto/gen/go/elide/daemon/v1alpha1"
+)
+
+// cancelRequest is a cancel request received by scriptedCancelClient.
+type cancelRequest struct {
+	reason    pb.CancellationReason
+	initiator string
+	token     string
+}
+
+// scriptedCancelResult is the answer to one cancel request.
+type scriptedCancelResult struct {
+	cancelled bool
+	err       error
+}
+
+// scriptedCancelClient answers cancel requests with results in turn and
+// records them.
+type scriptedCancelClient struct {
+	sessionDeletingClient
+
+	results  []scriptedCancelResult
+	requests []cancelRequest
+}
+
+func (c *scriptedCancelClient) CancelExecution(ctx context.Context, sessionID string, executionID string, reason pb.CancellationReason, initiator string, token string) (bool, error) {
+	c.requests = append(c.requests, cancelRequest{reason: reason, initiator: initiator, token: token})
+	result := c.results[0]
+	c.results = c.results[1:]
+	return result.cancelled, result.err
+}
+
+func newCancelHandle() *taskHandle {
+	return &taskHandle{
+		taskConfig:  &drivers.TaskConfig{ID: "task-1"},
+		executionId: "exec-1",
+		sessionId:   "session-1",
+		logger:      hclog.NewNullLogger(),
+	}
+}
+
+func newCancelPlugin(t *testing.T, results ...scriptedCancelResult) (*ElideDriverPlugin, *scriptedCancelClient) {
+	d := NewPlugin(hclog.NewNullLogger()).(*ElideDriverPlugin)
+	t.Cleanup(d.Shutdown)
+	client := &scriptedCancelClient{results: results}
+	d.daemonClient = client
+	return d, client
+}
+
+func TestCancelExecution_NothingToCancel(t *testing.T) {
+	// The daemon client reports executions that completed, and those the
+	// daemon does not know (NotFound), as not cancelled
+	d, client := newCancelPlugin(t, scriptedCancelResult{cancelled: false}, scriptedCancelResult{cancelled: true})
+	h := newCancelHandle()
+
+	err := d.cancelExecution(context.Background(), h, pb.CancellationReason_CANCELLATION_REASON_TIMEOUT, initiatorDriver, errors.New("deadline passed"))
+	require.NoError(t, err, "cancelling a stopped execution is not an error")
+	assert.False(t, h.isCancelled(), "the execution's own exit is reported, not a cancellation")
+
+	// A later cancellation is recorded as if the first never happened
+	require.NoError(t, d.cancelExecution(context.Background(), h, pb.CancellationReason_CANCELLATION_REASON_USER_STOP, initiatorNomad, nil))
+	assert.True(t, h.isCancelled())
+	assert.Equal(t, pb.CancellationReason_CANCELLATION_REASON_USER_STOP, h.cancelReason)
+	assert.Equal(t, CancelToken("exec-1", pb.CancellationReason_CANCELLATION_REASON_USER_STOP, initiatorNomad), client.requests[1].token)
+}
+
+func TestCancelExecution_RetryIsIdempotent(t *testing.T) {
+	d, client := newCancelPlugin(t,
+		scriptedCancelResult{err: status.Error(codes.Unavailable, "connection reset")},
+		scriptedCancelResult{cancelled: true},
+		scriptedCancelResult{cancelled: false},
+	)
+	h := newCancelHandle()
+
+	err := d.cancelExecution(context.Background(), h, pb.CancellationReason_CANCELLATION_REASON_USER_STOP, initiatorNomad, nil)
+	require.Error(t, err)
+	assert.True(t, h.isCancelled(), "a failed cancel request keeps the cancellation to retry")
+
+	// Retries, whatever asked for them, repeat the recorded cancellation
+	require.NoError(t, d.cancelExecution(context.Background(), h, pb.CancellationReason_CANCELLATION_REASON_TIMEOUT, initiatorDriver, nil))
+	require.NoError(t, d.cancelExecution(context.Background(), h, pb.CancellationReason_CANCELLATION_REASON_QUOTA, initiatorDriver, nil))
+	assert.True(t, h.isCancelled(), "a retry finding the execution stopped keeps the recorded cancellation")
+	assert.Equal(t, pb.CancellationReason_CANCELLATION_REASON_USER_STOP, h.cancelReason)
+
+	want := cancelRequest{
+		reason:    pb.CancellationReason_CANCELLATION_REASON_USER_STOP,
+		initiator: initiatorNomad,
+		token:     CancelToken("exec-1", pb.CancellationReason_CANCELLATION_REASON_USER_STOP, initiatorNomad),
+	}
+	assert.Equal(t, []cancelRequest{want, want, want}, client.requests)
+}
+
+// cancelAPI answers CancelExecution with a fixed response.
+type cancelAPI struct {
+	pb.ExecutionApiClient
+
+	resp *pb.CancelExecutionResponse
+	err  error
+}
+
+func (c *cancelAPI) CancelExecution(ctx context.Context, in *pb.CancelExecutionRequest, opts ...grpc.CallOption) (*pb.CancelExecutionResponse, error) {
+	return c.resp, c.err
+}
+
+func TestDaemonClient_CancelExecution(t *testing.T) {
+	tests := []struct {
+		name      string
+		api       *cancelAPI
+		cancelled bool
+		err       bool
+	}{
+		{name: "running", api: &cancelAPI{resp: &pb.CancelExecutionResponse{Success: true}}, cancelled: true},
+		{name: "completed", api: &cancelAPI{resp: &pb.CancelExecutionResponse{Success: false}}},
+		{name: "unknown", api: &cancelAPI{err: status.Error(codes.NotFound, "execution not found")}},
+		{name: "unreachable", api: &cancelAPI{err: status.Error(codes.Unavailable, "connection refused")}, err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &elideDaemonClient{executionClient: tt.api}
+			cancelled, err := client.CancelExecution(context.Background(), "session-1", "exec-1", pb.CancellationReason_CANCELLATION_REASON_USER_STOP, initiatorNomad, "token")
+			assert.Equal(t, tt.err, err != nil, "error: %v", err)
+			assert.Equal(t, tt.cancelled, cancelled)
+		})
+	}
+}
@@ -0,0 +1,129 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/nomad/plugins/drivers"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// defaultCancelGrace is how long the daemon has to confirm a cancelled
+	// execution stopped if cancel_grace is unset
+	defaultCancelGrace = 10 * time.Second
+
+	// metricCancelEscalations counts cancelled executions that had to be
+	// force-killed, by result
+	metricCancelEscalations = "cancel_escalations_total"
+)
+
+// confirmCancelled waits for the daemon to confirm that the cancelled
+// executions of a stopped task stopped. Executions still running after
+// cancel_grace are force-killed, and given cancel_grace again to stop. It
+// fails if any execution could not be confirmed stopped, so that StopTask
+// does not report a task stopped that still runs.
+func (d *ElideDriverPlugin) confirmCancelled(ctx context.Context, handle *taskHandle, executionIDs []string) error {
+	grace := durationOr(defaultCancelGrace, d.config.CancelGrace)
+	running := d.awaitStopped(ctx, handle.sessionId, executionIDs, grace)
+	if len(running) == 0 {
+		return nil
+	}
+
+	handle.logger.Warn("execution did not stop after cancellation, force-killing it", "executions", running, "grace", grace)
+	trace.SpanFromContext(ctx).AddEvent("force-killing execution")
+	d.eventer.EmitEvent(&drivers.TaskEvent{
+		TaskID:    handle.taskConfig.ID,
+		AllocID:   handle.taskConfig.AllocID,
+		TaskName:  handle.taskConfig.Name,
+		Timestamp: time.Now(),
+		Message:   fmt.Sprintf("Execution did not stop within %s of cancellation, force-killing it", grace),
+		Annotations: map[string]string{
+			"cancel_grace": grace.String(),
+		},
+	})
+
+	for _, executionID := range running {
+		killCtx, cancel := d.withTimeout(ctx, d.cancelTimeout())
+		_, err := d.daemonClient.KillExecution(killCtx, handle.sessionId, executionID, initiatorNomad)
+		cancel()
+		if status.Code(err) == codes.Unimplemented {
+			d.metrics.IncrCounter(metricCancelEscalations, "Cancelled executions force-killed as the daemon did not confirm they stopped, by result.", "result", "failed")
+			return fmt.Errorf("execution %s did not stop within %s of cancellation, and the daemon does not support force-killing executions", executionID, grace)
+		}
+		if err != nil {
+			d.metrics.IncrCounter(metricCancelEscalations, "Cancelled executions force-killed as the daemon did not confirm they stopped, by result.", "result", "failed")
+			return fmt.Errorf("failed to force-kill execution %s: %w", executionID, err)
+		}
+	}
+
+	if running = d.awaitStopped(ctx, handle.sessionId, running, grace); len(running) > 0 {
+		d.metrics.IncrCounter(metricCancelEscalations, "Cancelled executions force-killed as the daemon did not confirm they stopped, by result.", "result", "failed")
+		return fmt.Errorf("executions %s still running %s after force-kill", strings.Join(running, ", "), grace)
+	}
+	d.metrics.IncrCounter(metricCancelEscalations, "Cancelled executions force-killed as the daemon did not confirm they stopped, by result.", "result", "killed")
+	handle.logger.Info("force-killed execution")
+	return nil
+}
+
+// awaitStopped waits up to grace for the daemon to report executions
+// complete, returning those still running.
+func (d *ElideDriverPlugin) awaitStopped(ctx context.Context, sessionID string, executionIDs []string, grace time.Duration) []string {
+	ctx, cancel := context.WithTimeout(ctx, grace)
+	defer cancel()
+
+	var running []string
+	for _, executionID := range executionIDs {
+		if !d.awaitExecutionStopped(ctx, sessionID, executionID) {
+			running = append(running, executionID)
+		}
+	}
+	return running
+}
+
+// awaitExecutionStopped waits until the daemon reports an execution
+// complete, or forgot it, following its status stream, or polling if the
+// daemon does not support streaming or the stream breaks. It returns false
+// if ctx ended first.
+func (d *ElideDriverPlugin) awaitExecutionStopped(ctx context.Context, sessionID string, executionID string) bool {
+	if !d.watchUnsupported.Load() {
+		if stream, err := d.daemonClient.WatchExecution(ctx, sessionID, executionID, false); err == nil {
+			for {
+				resp, err := stream.Recv()
+				if err != nil {
+					break
+				}
+				if resp.Complete {
+					return true
+				}
+			}
+		}
+	}
+
+	ticker := time.NewTicker(stopCheckInterval)
+	defer ticker.Stop()
+	for {
+		statusCtx, cancel := d.withTimeout(ctx, d.statusTimeout())
+		resp, err := d.daemonClient.GetExecutionStatus(statusCtx, sessionID, executionID, OutputRequest{Omit: true})
+		cancel()
+		switch {
+		case status.Code(err) == codes.NotFound:
+			return true
+		case err == nil && resp.Complete:
+			return true
+		}
+
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+		}
+	}
+}
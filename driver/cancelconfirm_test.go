@@ -0,0 +1,87 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/elide-dev/elide-task-driver/proto/gen/go/elide/daemon/v1alpha1"
+)
+
+// stubbornDaemonClient keeps reporting its execution running after it was
+// cancelled. A force-kill stops it, unless killErr is set or ignoreKill is.
+type stubbornDaemonClient struct {
+	stoppableDaemonClient
+
+	killErr    error
+	ignoreKill bool
+	kills      []string
+}
+
+func (c *stubbornDaemonClient) CancelExecution(ctx context.Context, sessionID string, executionID string, reason pb.CancellationReason, initiator string, token string) (bool, error) {
+	c.stopMu.Lock()
+	defer c.stopMu.Unlock()
+	c.cancels = append(c.cancels, stopCall{name: cancellationReasonName(reason)})
+	return true, nil
+}
+
+func (c *stubbornDaemonClient) KillExecution(ctx context.Context, sessionID string, executionID string, initiator string) (bool, error) {
+	c.stopMu.Lock()
+	defer c.stopMu.Unlock()
+	c.kills = append(c.kills, executionID)
+	if c.killErr != nil {
+		return false, c.killErr
+	}
+	c.stopped = !c.ignoreKill
+	return true, nil
+}
+
+func TestStopTask_ForceKillsExecutionStillRunning(t *testing.T) {
+	tests := []struct {
+		name       string
+		killErr    error
+		ignoreKill bool
+		err        string
+		result     string
+	}{
+		{name: "killed", result: "killed"},
+		{
+			name:       "still running after kill",
+			ignoreKill: true,
+			err:        "executions exec-1 still running 50ms after force-kill",
+			result:     "failed",
+		},
+		{
+			name:    "daemon cannot kill",
+			killErr: status.Error(codes.Unimplemented, "unknown method KillExecution"),
+			err:     "the daemon does not support force-killing executions",
+			result:  "failed",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &stubbornDaemonClient{killErr: tt.killErr, ignoreKill: tt.ignoreKill}
+			d, _ := newStopPlugin(t, client, false)
+			d.config.CancelGrace = "50ms"
+
+			err := d.StopTask("task-1", 0, "")
+			if tt.err == "" {
+				require.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tt.err)
+			}
+
+			_, cancels := client.calls()
+			assert.Len(t, cancels, 1)
+			assert.Equal(t, []string{"exec-1"}, client.kills, "the execution still running after cancel_grace is force-killed")
+			assert.Contains(t, scrape(t, d), `elide_driver_cancel_escalations_total{result="`+tt.result+`"} 1`)
+		})
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/hashicorp/nomad/plugins/drivers"
+	"github.com/hashicorp/nomad/plugins/shared/structs"
+
+	pb "github.com/elide-dev/elide-task-driver/proto/gen/go/elide/daemon/v1alpha1"
+)
+
+// Where the task's directories appear to executions with mount support,
+// matching the paths Nomad reports to tasks of image-isolated drivers
+const (
+	mountAllocDir   = "/alloc"
+	mountLocalDir   = "/local"
+	mountSecretsDir = "/secrets"
+	mountScratchDir = "/scratch"
+)
+
+// Capabilities returns the features supported by the driver. They follow the
+// plugin config and the features the daemon reported in its last health
+// check, so Nomad never offers what the node cannot deliver:
+//   - signals only if the daemon can signal executions
+//   - exec only if enable_exec is set
+//   - image filesystem isolation if the daemon mounts the task's directories
+//     into its executions, none otherwise
+//   - host networking, and group networking for allocs with a bridge or CNI
+//     network, whose addresses are passed to executions in their env
+func (d *ElideDriverPlugin) Capabilities() (*drivers.Capabilities, error) {
+	fsIsolation := drivers.FSIsolationNone
+	if d.supportsMounts.Load() {
+		fsIsolation = drivers.FSIsolationImage
+	}
+	return &drivers.Capabilities{
+		SendSignals: d.supportsSignals.Load(),
+		Exec:        d.config.EnableExec,
+		FSIsolation: fsIsolation,
+		NetIsolationModes: []drivers.NetIsolationMode{
+			drivers.NetIsolationModeHost,
+			drivers.NetIsolationModeGroup,
+		},
+	}, nil
+}
+
+// negotiateFeatures records the optional features the daemon supports.
+func (d *ElideDriverPlugin) negotiateFeatures(health *pb.HealthResponse) {
+	if old := d.supportsSignals.Swap(health.GetSupportsSignals()); old != health.GetSupportsSignals() {
+		d.logger.Debug("negotiated signal support", "supported", health.GetSupportsSignals())
+	}
+	if old := d.supportsMounts.Swap(health.GetSupportsMounts()); old != health.GetSupportsMounts() {
+		d.logger.Debug("negotiated mount support", "supported", health.GetSupportsMounts())
+	}
+	if old := d.supportsOutputOffsets.Swap(health.GetSupportsOutputOffsets()); old != health.GetSupportsOutputOffsets() {
+		d.logger.Debug("negotiated output offset support", "supported", health.GetSupportsOutputOffsets())
+	}
+	if old := d.supportsWorkspaces.Swap(health.GetSupportsWorkspaces()); old != health.GetSupportsWorkspaces() {
+		d.logger.Debug("negotiated workspace support", "supported", health.GetSupportsWorkspaces())
+	}
+	if old := d.supportsStdin.Swap(health.GetSupportsStdin()); old != health.GetSupportsStdin() {
+		d.logger.Debug("negotiated stdin support", "supported", health.GetSupportsStdin())
+	}
+	if old := d.supportsPortBindings.Swap(health.GetSupportsPortBindings()); old != health.GetSupportsPortBindings() {
+		d.logger.Debug("negotiated port binding support", "supported", health.GetSupportsPortBindings())
+	}
+	if old := d.supportsOutputOrder.Swap(health.GetSupportsOutputOrder()); old != health.GetSupportsOutputOrder() {
+		d.logger.Debug("negotiated output order support", "supported", health.GetSupportsOutputOrder())
+	}
+}
+
+// DaemonAttributes advertises what the daemon reported in its health check,
+// so jobs can constrain on it, e.g. ${driver.elide.languages} set_contains
+// "python":
+//   - driver.elide.version: the daemon's version
+//   - driver.elide.languages: the languages it runs, sorted and
+//     comma-separated, and driver.elide.language.<name> = true for each
+//   - driver.elide.max_memory_mb: the memory it may give its sessions
+//   - driver.elide.intrinsics: the intrinsics it can expose, sorted and
+//     comma-separated
+//
+// Values the daemon does not report are left out.
+func DaemonAttributes(fp *drivers.Fingerprint, health *pb.HealthResponse) {
+	if version := health.GetVersion(); version != "" {
+		fp.Attributes["driver.elide.version"] = structs.NewStringAttribute(version)
+	}
+	if languages := health.GetLanguages(); len(languages) > 0 {
+		fp.Attributes["driver.elide.languages"] = structs.NewStringAttribute(sortedList(languages))
+		for _, language := range languages {
+			fp.Attributes["driver.elide.language."+language] = structs.NewBoolAttribute(true)
+		}
+	}
+	if memory := health.GetMaxMemoryMb(); memory > 0 {
+		fp.Attributes["driver.elide.max_memory_mb"] = structs.NewIntAttribute(int64(memory), "")
+	}
+	if intrinsics := health.GetIntrinsics(); len(intrinsics) > 0 {
+		fp.Attributes["driver.elide.intrinsics"] = structs.NewStringAttribute(sortedList(intrinsics))
+	}
+}
+
+// sortedList joins values sorted with commas, the list form of Nomad's
+// set_contains constraints.
+func sortedList(values []string) string {
+	values = slices.Clone(values)
+	slices.Sort(values)
+	return strings.Join(slices.Compact(values), ",")
+}
+
+// taskMounts returns the mounts of a task's execution: its alloc, local and
+// secrets directories, its scratch directory (if any) and the volumes Nomad
+// mounted for it. It returns nil if the daemon does not support mounts, in
+// which case executions see the host's paths.
+func (d *ElideDriverPlugin) taskMounts(cfg *drivers.TaskConfig, scratchDir string) []*pb.Mount {
+	if !d.supportsMounts.Load() {
+		return nil
+	}
+
+	taskDir := cfg.TaskDir()
+	mounts := []*pb.Mount{
+		{HostPath: taskDir.SharedAllocDir, TaskPath: mountAllocDir},
+		{HostPath: taskDir.LocalDir, TaskPath: mountLocalDir},
+		{HostPath: taskDir.SecretsDir, TaskPath: mountSecretsDir},
+	}
+	if scratchDir != "" {
+		mounts = append(mounts, &pb.Mount{HostPath: scratchDir, TaskPath: mountScratchDir})
+	}
+	for _, mount := range cfg.Mounts {
+		mounts = append(mounts, &pb.Mount{HostPath: mount.HostPath, TaskPath: mount.TaskPath, ReadOnly: mount.Readonly})
+	}
+	return mounts
+}
@@ -4,16 +4,29 @@
 package driver
 
 import (
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
 
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/hashicorp/nomad/helper/pluginutils/hclspecutils"
+	"github.com/hashicorp/nomad/plugins/base"
 	"github.com/hashicorp/nomad/plugins/shared/hclspec"
+	"github.com/zclconf/go-cty/cty/msgpack"
 )
 
 var (
 	// configSpec is the HCL specification for the driver plugin configuration
 	// This is set at the Nomad agent level (plugin stanza)
 	configSpec = hclspec.NewObject(map[string]*hclspec.Spec{
-		// Path to Elide daemon binary (if driver should start it)
+		// Path to Elide daemon binary (started if daemon_supervisor is enabled)
 		"elide_binary": hclspec.NewDefault(
 			hclspec.NewAttr("elide_binary", "string", false),
 			hclspec.NewLiteral(`"/usr/local/bin/elide"`),
@@ -23,8 +36,375 @@ var (
 			hclspec.NewAttr("daemon_socket", "string", false),
 			hclspec.NewLiteral(`"/tmp/elide-daemon.sock"`),
 		),
-		// TCP address for Elide daemon (alternative to Unix socket)
+		// TCP address for Elide daemon (alternative to Unix socket, which it
+		// takes precedence over)
 		"daemon_address": hclspec.NewAttr("daemon_address", "string", false),
+		// Version constraint the daemon must satisfy, e.g. ">= 1.2, < 2.0"
+		// (unset = any version)
+		"required_daemon_version": hclspec.NewAttr("required_daemon_version", "string", false),
+		// Consecutive failed daemon health checks before the driver is
+		// reported unhealthy (1 = on the first failure)
+		"health_failure_threshold": hclspec.NewDefault(
+			hclspec.NewAttr("health_failure_threshold", "number", false),
+			hclspec.NewLiteral("3"),
+		),
+		// Consul service of the Elide daemon, e.g. a Nomad system job
+		// (alternative to daemon_socket and daemon_address)
+		"daemon_consul_service": hclspec.NewAttr("daemon_consul_service", "string", false),
+		// How daemon_consul_service is looked up
+		"daemon_consul": hclspec.NewBlock("daemon_consul", false, hclspec.NewObject(map[string]*hclspec.Spec{
+			"address": hclspec.NewDefault(
+				hclspec.NewAttr("address", "string", false),
+				hclspec.NewLiteral(`"http://127.0.0.1:8500"`),
+			),
+			"datacenter": hclspec.NewAttr("datacenter", "string", false),
+			"tag":        hclspec.NewAttr("tag", "string", false),
+			"token":      hclspec.NewAttr("token", "string", false),
+			// "http" (health API) or "dns" (SRV records)
+			"lookup": hclspec.NewDefault(
+				hclspec.NewAttr("lookup", "string", false),
+				hclspec.NewLiteral(`"http"`),
+			),
+			// Consul DNS server for "dns" lookups (empty = system resolver)
+			"dns_server": hclspec.NewAttr("dns_server", "string", false),
+			"domain": hclspec.NewDefault(
+				hclspec.NewAttr("domain", "string", false),
+				hclspec.NewLiteral(`"consul"`),
+			),
+		})),
+		// Start the daemon from elide_binary when daemon_socket is absent and
+		// restart it when it exits
+		"daemon_supervisor": hclspec.NewBlock("daemon_supervisor", false, hclspec.NewObject(map[string]*hclspec.Spec{
+			"enabled": hclspec.NewDefault(
+				hclspec.NewAttr("enabled", "bool", false),
+				hclspec.NewLiteral("false"),
+			),
+			// Daemon arguments (default: daemon --socket <daemon_socket>)
+			"args": hclspec.NewAttr("args", "list(string)", false),
+			// File receiving the daemon's output (default: <daemon_socket>.log)
+			"log_file": hclspec.NewAttr("log_file", "string", false),
+			// How long to wait for a started daemon to create its socket
+			"startup_timeout": hclspec.NewDefault(
+				hclspec.NewAttr("startup_timeout", "string", false),
+				hclspec.NewLiteral(`"10s"`),
+			),
+			// Upper bound of the backoff between restarts
+			"max_restart_delay": hclspec.NewDefault(
+				hclspec.NewAttr("max_restart_delay", "string", false),
+				hclspec.NewLiteral(`"1m"`),
+			),
+		})),
+		// TLS for the connection to the daemon; the files are watched so
+		// rotated certificates are picked up without a restart
+		"daemon_tls": hclspec.NewBlock("daemon_tls", false, hclspec.NewObject(map[string]*hclspec.Spec{
+			"ca_file":     hclspec.NewAttr("ca_file", "string", false),
+			"cert_file":   hclspec.NewAttr("cert_file", "string", false),
+			"key_file":    hclspec.NewAttr("key_file", "string", false),
+			"server_name": hclspec.NewAttr("server_name", "string", false),
+			// How often the files are checked for rotation
+			"reload_interval": hclspec.NewDefault(
+				hclspec.NewAttr("reload_interval", "string", false),
+				hclspec.NewLiteral(`"1m"`),
+			),
+		})),
+		// Additional daemons on the node (e.g. a GPU variant) whose health is
+		// published as driver.elide.endpoint.<name>.* node attributes
+		"daemon_endpoint": hclspec.NewBlockMap("daemon_endpoint", []string{"name"}, hclspec.NewObject(map[string]*hclspec.Spec{
+			"socket":  hclspec.NewAttr("socket", "string", false),
+			"address": hclspec.NewAttr("address", "string", false),
+		})),
+		// TCP address serving driver metrics at /metrics for Prometheus; disabled if empty
+		"metrics_address": hclspec.NewAttr("metrics_address", "string", false),
+		// Labels attached to per-task metrics: "namespace", "job", "task_group", "language"
+		"metrics_task_labels": hclspec.NewDefault(
+			hclspec.NewAttr("metrics_task_labels", "list(string)", false),
+			hclspec.NewLiteral(`["language"]`),
+		),
+		// Series kept per metric; new series beyond it are dropped
+		"metrics_max_series": hclspec.NewDefault(
+			hclspec.NewAttr("metrics_max_series", "number", false),
+			hclspec.NewLiteral("1000"),
+		),
+		// Unix socket for the plugin admin API (snapshot export/import); disabled if empty
+		"admin_socket": hclspec.NewAttr("admin_socket", "string", false),
+		// Upload execution output to S3-compatible object storage on completion
+		"output_archive": hclspec.NewBlock("output_archive", false, hclspec.NewObject(map[string]*hclspec.Spec{
+			"endpoint": hclspec.NewAttr("endpoint", "string", true),
+			"region": hclspec.NewDefault(
+				hclspec.NewAttr("region", "string", false),
+				hclspec.NewLiteral(`"us-east-1"`),
+			),
+			"bucket":           hclspec.NewAttr("bucket", "string", true),
+			"prefix":           hclspec.NewAttr("prefix", "string", false),
+			"credentials_file": hclspec.NewAttr("credentials_file", "string", true),
+		})),
+		// Mint a short-lived credential for each task from its workload identity
+		"credential_exchange": hclspec.NewBlock("credential_exchange", false, hclspec.NewObject(map[string]*hclspec.Spec{
+			"endpoint": hclspec.NewAttr("endpoint", "string", true),
+			// Workload identity exchanged ("" = the task's default identity)
+			"identity": hclspec.NewAttr("identity", "string", false),
+			"audience": hclspec.NewAttr("audience", "string", false),
+			// Env var the credential is injected as
+			"env": hclspec.NewDefault(
+				hclspec.NewAttr("env", "string", false),
+				hclspec.NewLiteral(`"ELIDE_CREDENTIAL"`),
+			),
+			"timeout": hclspec.NewDefault(
+				hclspec.NewAttr("timeout", "string", false),
+				hclspec.NewLiteral(`"10s"`),
+			),
+		})),
+		// Allow script symlinks that resolve outside the task directory
+		"follow_symlinks": hclspec.NewDefault(
+			hclspec.NewAttr("follow_symlinks", "bool", false),
+			hclspec.NewLiteral("false"),
+		),
+		// Inject the execution, session and alloc IDs into each execution's
+		// environment so logs can be correlated with driver and daemon records
+		"correlation_env": hclspec.NewDefault(
+			hclspec.NewAttr("correlation_env", "bool", false),
+			hclspec.NewLiteral("true"),
+		),
+		// Prefix of the injected execution and session ID variables
+		"correlation_env_prefix": hclspec.NewDefault(
+			hclspec.NewAttr("correlation_env_prefix", "string", false),
+			hclspec.NewLiteral(`"ELIDE_"`),
+		),
+		// OS users tasks may run as with Nomad's user stanza (empty = none)
+		"allowed_users": hclspec.NewAttr("allowed_users", "list(string)", false),
+		// Largest execution result kept inline in task attributes; larger
+		// results are written to a file in the task directory
+		"max_result_bytes": hclspec.NewDefault(
+			hclspec.NewAttr("max_result_bytes", "number", false),
+			hclspec.NewLiteral("4096"),
+		),
+		// Default execution timeout as a duration string ("" = no timeout)
+		"execution_timeout": hclspec.NewAttr("execution_timeout", "string", false),
+		// Default IANA time zone (TZ) and POSIX locale (LANG) of executions
+		// whose task sets neither elide_opts nor env ("" = the daemon's)
+		"default_timezone": hclspec.NewAttr("default_timezone", "string", false),
+		"default_locale":   hclspec.NewAttr("default_locale", "string", false),
+		// Default status polling interval as a duration string
+		"poll_interval": hclspec.NewDefault(
+			hclspec.NewAttr("poll_interval", "string", false),
+			hclspec.NewLiteral(`"1s"`),
+		),
+		// Deadline of ExecuteSnippet RPCs, e.g. longer for slow daemons or
+		// large code payloads
+		"submit_timeout": hclspec.NewDefault(
+			hclspec.NewAttr("submit_timeout", "string", false),
+			hclspec.NewLiteral(`"10s"`),
+		),
+		// How long the daemon may hold ExecuteSnippet responses for the
+		// execution to complete, reporting short snippets complete without a
+		// status round trip ("0s" = respond right away)
+		"completion_wait": hclspec.NewDefault(
+			hclspec.NewAttr("completion_wait", "string", false),
+			hclspec.NewLiteral(`"100ms"`),
+		),
+		// Deadline of status polls and other short RPCs
+		"status_timeout": hclspec.NewDefault(
+			hclspec.NewAttr("status_timeout", "string", false),
+			hclspec.NewLiteral(`"5s"`),
+		),
+		// Deadline of cancellations initiated by the driver
+		"cancel_timeout": hclspec.NewDefault(
+			hclspec.NewAttr("cancel_timeout", "string", false),
+			hclspec.NewLiteral(`"5s"`),
+		),
+		// How long the daemon has to confirm a cancelled execution stopped
+		// before it is force-killed
+		"cancel_grace": hclspec.NewDefault(
+			hclspec.NewAttr("cancel_grace", "string", false),
+			hclspec.NewLiteral(`"10s"`),
+		),
+		// How long a task may live, queued or running, before it is killed
+		// and failed whatever its execution does (unset = no limit)
+		"max_task_lifetime": hclspec.NewAttr("max_task_lifetime", "string", false),
+		// What StartTask does while the daemon is unreachable: "fail" the
+		// task, or "wait" for the daemon with the submission queued in
+		// state_dir
+		"daemon_loss_policy": hclspec.NewDefault(
+			hclspec.NewAttr("daemon_loss_policy", "string", false),
+			hclspec.NewLiteral(`"fail"`),
+		),
+		// How long a queued submission waits for the daemon
+		"daemon_loss_timeout": hclspec.NewDefault(
+			hclspec.NewAttr("daemon_loss_timeout", "string", false),
+			hclspec.NewLiteral(`"15m"`),
+		),
+		// What RecoverTask does with tasks whose session the daemon lost:
+		// mark them "lost", or "resubmit" idempotent tasks in a new session
+		"recover_policy": hclspec.NewDefault(
+			hclspec.NewAttr("recover_policy", "string", false),
+			hclspec.NewLiteral(`"lost"`),
+		),
+		// Directory the driver keeps state in across plugin restarts
+		"state_dir": hclspec.NewAttr("state_dir", "string", false),
+		// What a restarted driver does with executions recorded in
+		// state_dir whose task Nomad did not recover: "cancel" or "adopt"
+		"orphan_policy": hclspec.NewDefault(
+			hclspec.NewAttr("orphan_policy", "string", false),
+			hclspec.NewLiteral(`"cancel"`),
+		),
+		// How long after startup Nomad has to recover tasks before their
+		// executions are orphans
+		"orphan_grace": hclspec.NewDefault(
+			hclspec.NewAttr("orphan_grace", "string", false),
+			hclspec.NewLiteral(`"1m"`),
+		),
+		// Fraction of daemon RPCs logged at debug level (0 to 1). Typed as a
+		// string because fractional numbers are msgpack-encoded as strings;
+		// HCL numbers convert to it.
+		"rpc_log_sample_rate": hclspec.NewDefault(
+			hclspec.NewAttr("rpc_log_sample_rate", "string", false),
+			hclspec.NewLiteral(`"0.1"`),
+		),
+		// Daemon RPCs slower than this duration are logged as warnings ("" = disabled)
+		"rpc_slow_threshold": hclspec.NewDefault(
+			hclspec.NewAttr("rpc_slow_threshold", "string", false),
+			hclspec.NewLiteral(`"1s"`),
+		),
+		// Repeats of a daemon error are logged once per this window, then
+		// summarized ("" = every occurrence is logged)
+		"daemon_log_dedup_window": hclspec.NewDefault(
+			hclspec.NewAttr("daemon_log_dedup_window", "string", false),
+			hclspec.NewLiteral(`"60s"`),
+		),
+		// OTLP/gRPC collector StartTask, WaitTask, StopTask and daemon RPC
+		// spans are exported to, e.g. "http://localhost:4317" ("" = disabled)
+		"otel_endpoint": hclspec.NewAttr("otel_endpoint", "string", false),
+		// Allow `nomad alloc exec` into tasks, running commands as snippets
+		// in the task's session
+		"enable_exec": hclspec.NewDefault(
+			hclspec.NewAttr("enable_exec", "bool", false),
+			hclspec.NewLiteral("false"),
+		),
+		// Consume the daemon's session event stream to enrich task events
+		"session_events": hclspec.NewDefault(
+			hclspec.NewAttr("session_events", "bool", false),
+			hclspec.NewLiteral("false"),
+		),
+		// Session granularity: "client" shares sessions between all tasks
+		// of the client, "alloc" and "task" give each alloc or task its own
+		"session_per": hclspec.NewDefault(
+			hclspec.NewAttr("session_per", "string", false),
+			hclspec.NewLiteral(`"client"`),
+		),
+		// Maximum number of executions submitted to the daemon at once (0 = unlimited).
+		// Tasks beyond the limit wait in per-job queues serviced round-robin.
+		"max_concurrent_executions": hclspec.NewDefault(
+			hclspec.NewAttr("max_concurrent_executions", "number", false),
+			hclspec.NewLiteral("0"),
+		),
+		// Maximum number of executions submitted to each session at once (0 = unlimited)
+		"max_concurrent_executions_per_session": hclspec.NewDefault(
+			hclspec.NewAttr("max_concurrent_executions_per_session", "number", false),
+			hclspec.NewLiteral("0"),
+		),
+		// How long a task waits for an execution slot before failing to start
+		// (duration string; empty = no limit)
+		"queue_timeout": hclspec.NewAttr("queue_timeout", "string", false),
+		// How opening a session is retried, e.g. while the daemon starts up
+		"session_retry": hclspec.NewBlock("session_retry", false, hclspec.NewObject(map[string]*hclspec.Spec{
+			// Create/get rounds before giving up
+			"attempts": hclspec.NewDefault(
+				hclspec.NewAttr("attempts", "number", false),
+				hclspec.NewLiteral("5"),
+			),
+			// Delay after the first failed round, growing linearly
+			"base_delay": hclspec.NewDefault(
+				hclspec.NewAttr("base_delay", "string", false),
+				hclspec.NewLiteral(`"200ms"`),
+			),
+			// Largest random delay added to each wait
+			"jitter": hclspec.NewAttr("jitter", "string", false),
+		})),
+		// Retries of daemon RPCs failing with a transient error, per RPC
+		// name, or "default" for every RPC without a block of its own
+		"rpc_retry": hclspec.NewBlockMap("rpc_retry", []string{"rpc"}, hclspec.NewObject(map[string]*hclspec.Spec{
+			// Calls made before giving up, including the first
+			"max_attempts": hclspec.NewAttr("max_attempts", "number", false),
+			// Delay before the first retry, doubling with each retry
+			"initial_backoff": hclspec.NewAttr("initial_backoff", "string", false),
+			// Largest delay between retries
+			"max_backoff": hclspec.NewAttr("max_backoff", "string", false),
+			// gRPC status codes retried, e.g. ["UNAVAILABLE"]
+			"retryable_codes": hclspec.NewAttr("retryable_codes", "list(string)", false),
+		})),
+		// Replace a session's contexts once a task keeps failing in it
+		"restart_guard": hclspec.NewBlock("restart_guard", false, hclspec.NewObject(map[string]*hclspec.Spec{
+			// Failed runs of a task in one session within window that
+			// trigger the guard (0 = disabled)
+			"max_restarts": hclspec.NewDefault(
+				hclspec.NewAttr("max_restarts", "number", false),
+				hclspec.NewLiteral("0"),
+			),
+			// Period failures are counted in, as a duration string
+			"window": hclspec.NewDefault(
+				hclspec.NewAttr("window", "string", false),
+				hclspec.NewLiteral(`"5m"`),
+			),
+			// "recycle" the session's contexts or "rotate" to new sessions
+			"action": hclspec.NewDefault(
+				hclspec.NewAttr("action", "string", false),
+				hclspec.NewLiteral(`"recycle"`),
+			),
+		})),
+		// Forensic bundles written to state_dir when a session is lost or the
+		// daemon becomes unreachable
+		"forensics": hclspec.NewBlock("forensics", false, hclspec.NewObject(map[string]*hclspec.Spec{
+			// Status responses per task, fingerprints, health and connection
+			// events kept for bundles (-1 = disabled)
+			"history": hclspec.NewDefault(
+				hclspec.NewAttr("history", "number", false),
+				hclspec.NewLiteral("20"),
+			),
+			// Bundles kept in state_dir, oldest removed first
+			"max_bundles": hclspec.NewDefault(
+				hclspec.NewAttr("max_bundles", "number", false),
+				hclspec.NewLiteral("10"),
+			),
+		})),
+		// YAML or JSON file describing the session configuration, init code,
+		// warm scripts and language defaults; watched for changes
+		"session_manifest": hclspec.NewAttr("session_manifest", "string", false),
+		// Named sandbox profiles selectable with elide_opts.profile. Profiles
+		// defined here add to or replace the built-in presets.
+		"profile": hclspec.NewBlockMap("profile", []string{"name"}, hclspec.NewObject(map[string]*hclspec.Spec{
+			// Intrinsics available to executions (subset of enabled_intrinsics)
+			"intrinsics": hclspec.NewAttr("intrinsics", "list(string)", false),
+			// Memory limit in MB (0 = session limit)
+			"memory_limit_mb": hclspec.NewAttr("memory_limit_mb", "number", false),
+			// Default execution timeout as a duration string
+			"timeout": hclspec.NewAttr("timeout", "string", false),
+		})),
+		// Additional sessions with their own resource configuration, selected
+		// per task with elide_opts.session_profile (e.g. small/medium/large)
+		"session_profile": hclspec.NewBlockMap("session_profile", []string{"name"}, hclspec.NewObject(map[string]*hclspec.Spec{
+			// Session memory limit in MB
+			"memory_limit_mb": hclspec.NewAttr("memory_limit_mb", "number", true),
+			// Context pool size (0 = session_config's)
+			"context_pool_size": hclspec.NewAttr("context_pool_size", "number", false),
+		})),
+		// Defaults merged into every execution of a language
+		"language_defaults": hclspec.NewBlock("language_defaults", false, hclspec.NewObject(map[string]*hclspec.Spec{
+			"python":     languageDefaultsSpec("python"),
+			"javascript": languageDefaultsSpec("javascript"),
+			"typescript": languageDefaultsSpec("typescript"),
+		})),
+		// Modules snippets may not import, checked by a static scan before
+		// submission
+		"denied_modules": hclspec.NewBlock("denied_modules", false, hclspec.NewObject(map[string]*hclspec.Spec{
+			"python":     hclspec.NewAttr("python", "list(string)", false),
+			"javascript": hclspec.NewAttr("javascript", "list(string)", false),
+			"typescript": hclspec.NewAttr("typescript", "list(string)", false),
+			// "reject" fails the task, "warn" logs and emits a task event
+			"policy": hclspec.NewDefault(
+				hclspec.NewAttr("policy", "string", false),
+				hclspec.NewLiteral(`"reject"`),
+			),
+		})),
 		// Session configuration (one per Nomad client)
 		"session_config": hclspec.NewBlock("session_config", false, hclspec.NewObject(map[string]*hclspec.Spec{
 			"context_pool_size": hclspec.NewDefault(
@@ -55,39 +435,437 @@ var (
 	taskConfigSpec = hclspec.NewObject(map[string]*hclspec.Spec{
 		// Path to script file (relative to task directory) - optional since 'code' can be used instead
 		"script": hclspec.NewAttr("script", "string", false),
+		// Where script is resolved: "task" (the task directory) or "artifact" (local/, then downloads/)
+		"script_source": hclspec.NewDefault(
+			hclspec.NewAttr("script_source", "string", false),
+			hclspec.NewLiteral(`"task"`),
+		),
 		// Inline code (alternative to script file)
 		"code": hclspec.NewAttr("code", "string", false),
-		// Language: "python", "javascript", "typescript"
-		"language": hclspec.NewDefault(
-			hclspec.NewAttr("language", "string", false),
-			hclspec.NewLiteral(`"python"`),
+		// OCI artifact holding the script or bundle (alternative to script/code)
+		"code_oci_ref": hclspec.NewAttr("code_oci_ref", "string", false),
+		// Entrypoint file inside an OCI bundle artifact
+		"code_oci_entrypoint": hclspec.NewAttr("code_oci_entrypoint", "string", false),
+		// File to run from the uploaded files directory (alternative to script/code/code_oci_ref)
+		"entrypoint": hclspec.NewAttr("entrypoint", "string", false),
+		// Directory uploaded to the daemon for entrypoint, relative to the task directory (default "local")
+		"files": hclspec.NewAttr("files", "string", false),
+		// File streamed to each execution's stdin, relative to the task directory or "alloc/" for the shared alloc dir
+		"stdin_path": hclspec.NewAttr("stdin_path", "string", false),
+		// Inline content streamed to each execution's stdin (alternative to stdin_path)
+		"stdin_data": hclspec.NewAttr("stdin_data", "string", false),
+		// Labels of the group network's ports the snippet serves on
+		"ports": hclspec.NewAttr("ports", "list(string)", false),
+		// Advertise services at the address the daemon reports for the ports instead of the host's
+		"expose": hclspec.NewDefault(
+			hclspec.NewAttr("expose", "bool", false),
+			hclspec.NewLiteral("false"),
 		),
+		// Language: "python", "javascript", "typescript" (inferred from the
+		// script's extension if unset, python for inline code)
+		"language": hclspec.NewAttr("language", "string", false),
 		// Arguments to pass to script
 		"args": hclspec.NewAttr("args", "list(string)", false),
+		// Substitute ${env.NAME} and ${NOMAD_*} from the task's environment into code, args and args_matrix
+		"interpolate": hclspec.NewDefault(
+			hclspec.NewAttr("interpolate", "bool", false),
+			hclspec.NewLiteral("false"),
+		),
+		// Arg sets to run the script with, one execution per entry (alternative to args)
+		"args_matrix": hclspec.NewAttr("args_matrix", "list(list(string))", false),
+		// How args_matrix exit codes aggregate: "all-success" or "any-success"
+		"matrix_policy": hclspec.NewDefault(
+			hclspec.NewAttr("matrix_policy", "string", false),
+			hclspec.NewLiteral(`"all-success"`),
+		),
 		// Environment variables
 		"env": hclspec.NewAttr("env", "map(string)", false),
-		// Elide-specific options (reserved for future use)
-		// NOTE: These are currently defined but NOT USED. They are reserved for when
-		// the daemon API supports per-task configuration overrides. Currently, all
-		// tasks use the session-level configuration defined in the driver config.
-		// See API_QUESTIONS.md for details on when this will be supported.
+		// Create a per-execution scratch directory, exposed as ELIDE_SCRATCH_DIR
+		"scratch_dir": hclspec.NewDefault(
+			hclspec.NewAttr("scratch_dir", "bool", false),
+			hclspec.NewLiteral("false"),
+		),
+		// Size quota of the scratch directory in MB (0 = unlimited)
+		"scratch_quota_mb": hclspec.NewDefault(
+			hclspec.NewAttr("scratch_quota_mb", "number", false),
+			hclspec.NewLiteral("100"),
+		),
+		// Status polling interval as a duration string (overrides the plugin's poll_interval)
+		"poll_interval": hclspec.NewAttr("poll_interval", "string", false),
+		// Env vars populated from the session KV store at start (env var -> key)
+		"imports": hclspec.NewAttr("imports", "map(string)", false),
+		// Session KV keys set from the same-named fields of the JSON result
+		"exports": hclspec.NewAttr("exports", "list(string)", false),
+		// Dotenv-format file (relative to the task directory) merged into env
+		"env_file": hclspec.NewAttr("env_file", "string", false),
+		// Normalize env var names before submission: "" (as-is), "upper" or "lower"
+		"env_case": hclspec.NewAttr("env_case", "string", false),
+		// Where execution output goes: "discard", "log", "file" or "both"
+		"output_mode": hclspec.NewDefault(
+			hclspec.NewAttr("output_mode", "string", false),
+			hclspec.NewLiteral(`"log"`),
+		),
+		// Interleave stdout and stderr in the stdout log in the order they were written
+		"combine_output": hclspec.NewDefault(
+			hclspec.NewAttr("combine_output", "bool", false),
+			hclspec.NewLiteral("false"),
+		),
+		// How log streams ship output that is not valid UTF-8: "base64", "raw" or "file"
+		"binary_output": hclspec.NewDefault(
+			hclspec.NewAttr("binary_output", "string", false),
+			hclspec.NewLiteral(`"base64"`),
+		),
+		// JSON Schema the execution's result must match: inline, or a file
+		// relative to the task directory
+		"result_schema": hclspec.NewAttr("result_schema", "string", false),
+		// What a result not matching result_schema does: "fail" the task or "warn"
+		"result_schema_policy": hclspec.NewDefault(
+			hclspec.NewAttr("result_schema_policy", "string", false),
+			hclspec.NewLiteral(`"fail"`),
+		),
+		// Refuse to restart the task if its code changed since its first run
+		"immutable_code": hclspec.NewDefault(
+			hclspec.NewAttr("immutable_code", "bool", false),
+			hclspec.NewLiteral("false"),
+		),
+		// The task may run again from the start if its session is lost, see
+		// the plugin's recover_policy
+		"idempotent": hclspec.NewDefault(
+			hclspec.NewAttr("idempotent", "bool", false),
+			hclspec.NewLiteral("false"),
+		),
+		// Elide-specific options, sent to the daemon as per-execution
+		// overrides of the session configuration
 		"elide_opts": hclspec.NewBlock("elide_opts", false, hclspec.NewObject(map[string]*hclspec.Spec{
-			// Memory limit in MB (per-task override - not yet supported)
+			// Memory limit in MB (overrides the session's and the profile's)
 			"memory_limit": hclspec.NewAttr("memory_limit", "number", false),
-			// Enable AI features (per-task override - not yet supported)
+			// Enable AI features for the execution
 			"enable_ai": hclspec.NewAttr("enable_ai", "bool", false),
-			// Timeout in seconds (not yet supported by daemon API)
-			"timeout": hclspec.NewAttr("timeout", "number", false),
+			// Execution timeout as a duration string, e.g. "30s" or "5m"
+			// (enforced by the driver and the daemon, overrides the plugin's
+			// execution_timeout)
+			"timeout": hclspec.NewAttr("timeout", "string", false),
+			// Sandbox profile: "pure-compute", "io-allowed", "network-allowed"
+			// or a profile defined in the plugin config
+			"profile": hclspec.NewAttr("profile", "string", false),
+			// Session profile defined in the plugin config; the execution runs
+			// in that profile's session instead of the default one
+			"session_profile": hclspec.NewAttr("session_profile", "string", false),
+			// NUMA node to pin the execution's threads to on large hosts
+			// (see the driver.elide.numa.* node attributes)
+			"numa_node": hclspec.NewAttr("numa_node", "number", false),
+			// IANA time zone of the execution, e.g. "Europe/Berlin", set as TZ
+			// (overrides env and the plugin's default_timezone)
+			"timezone": hclspec.NewAttr("timezone", "string", false),
+			// POSIX locale of the execution, e.g. "de_DE.UTF-8", set as LANG
+			// (overrides env and the plugin's default_locale)
+			"locale": hclspec.NewAttr("locale", "string", false),
+			// W3C traceparent of a distributed trace the execution joins,
+			// e.g. one started by the job's dispatcher (default: the
+			// TRACEPARENT or NOMAD_META_TRACEPARENT env var)
+			"trace_parent": hclspec.NewAttr("trace_parent", "string", false),
 		})),
 	})
 )
 
+// languageDefaultsSpec is the HCL specification for the defaults of one
+// language in the language_defaults block.
+func languageDefaultsSpec(language string) *hclspec.Spec {
+	return hclspec.NewBlock(language, false, hclspec.NewObject(map[string]*hclspec.Spec{
+		// Options passed to the language interpreter
+		"interpreter_args": hclspec.NewAttr("interpreter_args", "list(string)", false),
+		// Env vars set unless the task sets them itself
+		"env": hclspec.NewAttr("env", "map(string)", false),
+	}))
+}
+
+const (
+	// maxEnvVars is the maximum number of env vars a task may pass
+	maxEnvVars = 1024
+
+	// maxEnvPayloadBytes is the maximum combined size of env names and values
+	maxEnvPayloadBytes = 128 * 1024
+)
+
 // Config is the driver configuration set by the Nomad agent
 type Config struct {
 	ElideBinary   string        `codec:"elide_binary"`
 	DaemonSocket  string        `codec:"daemon_socket"`
 	DaemonAddress string        `codec:"daemon_address"`
 	SessionConfig SessionConfig `codec:"session_config"`
+
+	// DaemonConsulService is the Consul service of the daemon; it takes
+	// precedence over DaemonSocket if set
+	DaemonConsulService string `codec:"daemon_consul_service"`
+
+	// RequiredDaemonVersion is a version constraint the daemon's reported
+	// version must satisfy for the driver to use it
+	RequiredDaemonVersion string `codec:"required_daemon_version"`
+
+	// HealthFailureThreshold is how many health checks in a row the daemon
+	// must fail before the driver reports it down (0 = the default)
+	HealthFailureThreshold int `codec:"health_failure_threshold"`
+
+	// DaemonConsul configures the lookup of DaemonConsulService
+	DaemonConsul DaemonConsulConfig `codec:"daemon_consul"`
+
+	// DaemonSupervisor starts and restarts the daemon from ElideBinary
+	DaemonSupervisor DaemonSupervisorConfig `codec:"daemon_supervisor"`
+
+	// DaemonTLS secures the connection to the daemon with (mutual) TLS
+	DaemonTLS DaemonTLSConfig `codec:"daemon_tls"`
+
+	// DaemonEndpoints are additional daemons whose health is published
+	DaemonEndpoints map[string]DaemonEndpointConfig `codec:"daemon_endpoint"`
+
+	// MetricsAddress is the TCP address serving /metrics (disabled if empty)
+	MetricsAddress string `codec:"metrics_address"`
+
+	// MetricsTaskLabels are the labels attached to per-task metrics
+	MetricsTaskLabels []string `codec:"metrics_task_labels"`
+
+	// MetricsMaxSeries caps the series of each metric (0 = default)
+	MetricsMaxSeries int `codec:"metrics_max_series"`
+
+	// AdminSocket is the Unix socket the admin API listens on (disabled if empty)
+	AdminSocket string `codec:"admin_socket"`
+
+	// FollowSymlinks allows script symlinks resolving outside the task dir
+	FollowSymlinks bool `codec:"follow_symlinks"`
+
+	// CorrelationEnv injects the execution, session and alloc IDs into the
+	// environment of executions
+	CorrelationEnv bool `codec:"correlation_env"`
+
+	// CorrelationEnvPrefix prefixes the injected execution and session ID
+	// variables (e.g. "ELIDE_" for ELIDE_EXECUTION_ID)
+	CorrelationEnvPrefix string `codec:"correlation_env_prefix"`
+
+	// AllowedUsers are the OS users tasks may run as (Nomad's user stanza)
+	AllowedUsers []string `codec:"allowed_users"`
+
+	// ExecutionTimeout is the default execution timeout (duration string)
+	ExecutionTimeout string `codec:"execution_timeout"`
+
+	// DefaultTimezone and DefaultLocale are the time zone and locale of
+	// executions not setting their own ("" = the daemon's)
+	DefaultTimezone string `codec:"default_timezone"`
+	DefaultLocale   string `codec:"default_locale"`
+
+	// PollInterval is the default status polling interval (duration string)
+	PollInterval string `codec:"poll_interval"`
+
+	// SubmitTimeout is the deadline of ExecuteSnippet RPCs (duration string)
+	SubmitTimeout string `codec:"submit_timeout"`
+
+	// CompletionWait is how long the daemon may hold ExecuteSnippet
+	// responses for the execution to complete (duration string)
+	CompletionWait string `codec:"completion_wait"`
+
+	// StatusTimeout is the deadline of status polls and other short RPCs
+	// (duration string)
+	StatusTimeout string `codec:"status_timeout"`
+
+	// CancelTimeout is the deadline of cancellations initiated by the
+	// driver (duration string)
+	CancelTimeout string `codec:"cancel_timeout"`
+
+	// CancelGrace is how long the daemon has to confirm that an execution
+	// cancelled on stop stopped before it is force-killed (duration string)
+	CancelGrace string `codec:"cancel_grace"`
+
+	// MaxTaskLifetime is how long a task may live before it is killed and
+	// failed, regardless of its execution's status (duration string)
+	MaxTaskLifetime string `codec:"max_task_lifetime"`
+
+	// DaemonLossPolicy is what StartTask does while the daemon is
+	// unreachable: "fail" or "wait"
+	DaemonLossPolicy string `codec:"daemon_loss_policy"`
+
+	// DaemonLossTimeout bounds how long a queued submission waits for the
+	// daemon (duration string)
+	DaemonLossTimeout string `codec:"daemon_loss_timeout"`
+
+	// RecoverPolicy is what RecoverTask does with tasks whose session the
+	// daemon lost: "lost" or "resubmit"
+	RecoverPolicy string `codec:"recover_policy"`
+
+	// StateDir is where the driver keeps state across plugin restarts, such
+	// as submissions queued during a daemon outage
+	StateDir string `codec:"state_dir"`
+
+	// OrphanPolicy is what a restarted driver does with executions whose
+	// task Nomad did not recover: "cancel" or "adopt"
+	OrphanPolicy string `codec:"orphan_policy"`
+
+	// OrphanGrace is how long after startup Nomad has to recover tasks
+	// before their executions are orphans (duration string)
+	OrphanGrace string `codec:"orphan_grace"`
+
+	// RPCLogSampleRate is the fraction of daemon RPCs logged at debug level
+	// (decimal string, "" = none)
+	RPCLogSampleRate string `codec:"rpc_log_sample_rate"`
+
+	// RPCSlowThreshold is the duration above which daemon RPCs are logged
+	// as warnings (duration string, "" = disabled)
+	RPCSlowThreshold string `codec:"rpc_slow_threshold"`
+
+	// DaemonLogDedupWindow is how long repeats of a warning about the same
+	// daemon error are suppressed (duration string, "" = disabled)
+	DaemonLogDedupWindow string `codec:"daemon_log_dedup_window"`
+
+	// OtelEndpoint is the URL of the OTLP/gRPC collector traces are exported
+	// to ("" = tracing disabled)
+	OtelEndpoint string `codec:"otel_endpoint"`
+
+	// MaxResultBytes is the largest result kept inline in task attributes
+	MaxResultBytes int `codec:"max_result_bytes"`
+
+	// MaxConcurrentExecutions limits in-flight executions (0 = unlimited)
+	MaxConcurrentExecutions int `codec:"max_concurrent_executions"`
+
+	// MaxConcurrentExecutionsPerSession limits in-flight executions of each
+	// session (0 = unlimited)
+	MaxConcurrentExecutionsPerSession int `codec:"max_concurrent_executions_per_session"`
+
+	// QueueTimeout bounds the wait for an execution slot (duration string,
+	// empty = no limit)
+	QueueTimeout string `codec:"queue_timeout"`
+
+	// EnableExec allows exec into tasks (run as snippets in their session)
+	EnableExec bool `codec:"enable_exec"`
+
+	// SessionEvents consumes the daemon's session event stream
+	SessionEvents bool `codec:"session_events"`
+
+	// SessionPer is the session granularity: "client", "alloc" or "task"
+	SessionPer string `codec:"session_per"`
+
+	// SessionManifest is the path of the session manifest (optional)
+	SessionManifest string `codec:"session_manifest"`
+
+	// SessionRetry is the retry policy for opening sessions
+	SessionRetry SessionRetryConfig `codec:"session_retry"`
+
+	// RPCRetry configures the retries of daemon RPCs, by RPC name or
+	// "default"
+	RPCRetry map[string]RPCRetryConfig `codec:"rpc_retry"`
+
+	// RestartGuard replaces a session's contexts once a task keeps failing
+	RestartGuard RestartGuardConfig `codec:"restart_guard"`
+
+	// Forensics configures the bundles captured when a session is lost or
+	// the daemon becomes unreachable
+	Forensics ForensicsConfig `codec:"forensics"`
+
+	// OutputArchive uploads execution output to object storage (optional)
+	OutputArchive OutputArchiveConfig `codec:"output_archive"`
+
+	// CredentialExchange mints a short-lived credential for each task from
+	// its workload identity (optional)
+	CredentialExchange CredentialExchangeConfig `codec:"credential_exchange"`
+
+	// Profiles are the sandbox profiles defined in the plugin config
+	Profiles map[string]ProfileConfig `codec:"profile"`
+
+	// SessionProfiles are additional sessions selectable per task
+	SessionProfiles map[string]SessionProfileConfig `codec:"session_profile"`
+
+	// LanguageDefaults are merged into every execution of a language
+	LanguageDefaults LanguageDefaultsConfig `codec:"language_defaults"`
+
+	// DeniedModules are modules snippets may not import
+	DeniedModules DeniedModulesConfig `codec:"denied_modules"`
+}
+
+// LanguageDefaultsConfig holds the execution defaults of each language.
+type LanguageDefaultsConfig struct {
+	Python     LanguageDefaults `codec:"python"`
+	JavaScript LanguageDefaults `codec:"javascript"`
+	TypeScript LanguageDefaults `codec:"typescript"`
+}
+
+// LanguageDefaults are platform-wide runtime settings for one language.
+type LanguageDefaults struct {
+	// InterpreterArgs are options for the language interpreter
+	InterpreterArgs []string `codec:"interpreter_args"`
+	// Env vars set unless the task sets them itself
+	Env map[string]string `codec:"env"`
+}
+
+// For returns the defaults of the given language.
+func (c *LanguageDefaultsConfig) For(language string) LanguageDefaults {
+	switch language {
+	case "python":
+		return c.Python
+	case "javascript":
+		return c.JavaScript
+	case "typescript":
+		return c.TypeScript
+	}
+	return LanguageDefaults{}
+}
+
+// MergeEnv adds the default env vars that env does not already set.
+func (l *LanguageDefaults) MergeEnv(env map[string]string) {
+	for name, value := range l.Env {
+		if _, ok := env[name]; !ok {
+			env[name] = value
+		}
+	}
+}
+
+// ProfileConfig is a named sandbox preset: the intrinsics an execution may
+// use and its resource defaults.
+type ProfileConfig struct {
+	// Intrinsics available to the execution (empty = none)
+	Intrinsics []string `codec:"intrinsics"`
+	// MemoryLimitMB caps execution memory (0 = session limit)
+	MemoryLimitMB int `codec:"memory_limit_mb"`
+	// Timeout is the default execution timeout (duration string)
+	Timeout string `codec:"timeout"`
+}
+
+// DeniedModulesConfig lists the modules snippets of each language may not
+// import and what happens when one does.
+type DeniedModulesConfig struct {
+	Python     []string `codec:"python"`
+	JavaScript []string `codec:"javascript"`
+	TypeScript []string `codec:"typescript"`
+	// Policy is "reject" (fail the task) or "warn" (log and emit an event)
+	Policy string `codec:"policy"`
+}
+
+// SessionProfileConfig is a named session configuration. The driver keeps one
+// session per profile, overriding the resources of session_config.
+type SessionProfileConfig struct {
+	// MemoryLimitMB is the session memory limit
+	MemoryLimitMB int `codec:"memory_limit_mb"`
+	// ContextPoolSize overrides session_config's pool size (0 = inherit)
+	ContextPoolSize int `codec:"context_pool_size"`
+}
+
+// OutputArchiveConfig configures archival of execution output to an
+// S3-compatible object store (AWS S3, or GCS through its XML interoperability
+// API with HMAC keys).
+type OutputArchiveConfig struct {
+	// Endpoint is the object store URL, e.g. https://s3.us-east-1.amazonaws.com
+	// or https://storage.googleapis.com
+	Endpoint string `codec:"endpoint"`
+	// Region used for request signing ("auto" for GCS)
+	Region string `codec:"region"`
+	// Bucket receiving the archived objects
+	Bucket string `codec:"bucket"`
+	// Prefix prepended to every object key
+	Prefix string `codec:"prefix"`
+	// CredentialsFile is an AWS-style credentials file holding
+	// aws_access_key_id and aws_secret_access_key
+	CredentialsFile string `codec:"credentials_file"`
+}
+
+// Enabled reports whether output archival is configured.
+func (c *OutputArchiveConfig) Enabled() bool {
+	return c != nil && c.Bucket != ""
 }
 
 // SessionConfig is the session configuration
@@ -99,55 +877,595 @@ type SessionConfig struct {
 	EnableAI         bool     `codec:"enable_ai"`
 }
 
+// intrinsics returns the session's enabled intrinsics, applying the default
+// when none are configured.
+func (c *SessionConfig) intrinsics() []string {
+	if len(c.EnabledIntrinsics) == 0 {
+		return []string{"io", "env"}
+	}
+	return c.EnabledIntrinsics
+}
+
 // TaskConfig is the per-task configuration
 type TaskConfig struct {
 	// Script path (relative to task directory)
 	Script string `codec:"script"`
+	// Where script is resolved: "task" or "artifact"
+	ScriptSource string `codec:"script_source"`
 	// Inline code (alternative to script)
 	Code string `codec:"code"`
-	// Language: python, javascript, typescript
+	// OCI artifact reference (alternative to script and code)
+	CodeOCIRef string `codec:"code_oci_ref"`
+	// Entrypoint inside the OCI artifact (defaults to its annotation)
+	CodeOCIEntrypoint string `codec:"code_oci_entrypoint"`
+	// File to run, relative to Files (alternative to script, code and
+	// code_oci_ref)
+	Entrypoint string `codec:"entrypoint"`
+	// Directory uploaded for Entrypoint, relative to the task directory
+	Files string `codec:"files"`
+	// File streamed to the executions' stdin
+	StdinPath string `codec:"stdin_path"`
+	// Inline content streamed to the executions' stdin
+	StdinData string `codec:"stdin_data"`
+	// Labels of the allocated ports the snippet serves on
+	Ports []string `codec:"ports"`
+	// Advertise services at the daemon's address for the ports
+	Expose bool `codec:"expose"`
+	// Language: python, javascript, typescript (inferred if empty)
 	Language string `codec:"language"`
 	// Arguments to pass to script
 	Args []string `codec:"args"`
+	// Arg sets to run the script with, one execution per entry
+	ArgsMatrix [][]string `codec:"args_matrix"`
+	// Substitute the task's environment into code, args and args_matrix
+	Interpolate bool `codec:"interpolate"`
+	// Exit code aggregation of args_matrix: all-success or any-success
+	MatrixPolicy string `codec:"matrix_policy"`
 	// Environment variables
 	Env map[string]string `codec:"env"`
+	// Env var name normalization: "" (as-is), "upper" or "lower"
+	EnvCase string `codec:"env_case"`
+	// Dotenv file in the task directory merged into the env
+	EnvFile string `codec:"env_file"`
+	// Create a per-execution scratch directory
+	ScratchDir bool `codec:"scratch_dir"`
+	// Scratch directory size quota in MB (0 = unlimited)
+	ScratchQuotaMB int `codec:"scratch_quota_mb"`
+	// Status polling interval (duration string)
+	PollInterval string `codec:"poll_interval"`
+	// Env vars populated from the session KV store at start (env var -> key)
+	Imports map[string]string `codec:"imports"`
+	// Session KV keys set from the top-level fields of the execution result
+	Exports []string `codec:"exports"`
+	// Output handling: discard, log, file or both
+	OutputMode string `codec:"output_mode"`
+	// Interleave stdout and stderr in the stdout log as written
+	CombineOutput bool `codec:"combine_output"`
+	// Binary output handling in the log streams: base64, raw or file
+	BinaryOutput string `codec:"binary_output"`
+	// JSON Schema of the result, inline or a file in the task directory
+	ResultSchema string `codec:"result_schema"`
+	// Result schema mismatch handling: fail or warn
+	ResultSchemaPolicy string `codec:"result_schema_policy"`
+	// Refuse restarts whose code differs from the first run
+	ImmutableCode bool `codec:"immutable_code"`
+	// Safe to run again from the start after its session was lost
+	Idempotent bool `codec:"idempotent"`
 	// Elide-specific options
 	ElideOpts ElideOptions `codec:"elide_opts"`
 }
 
-// ElideOptions contains Elide-specific per-task configuration
-// NOTE: These fields are currently RESERVED FOR FUTURE USE and are not applied.
-// All tasks currently use session-level configuration from the driver config.
-// These will be used when the daemon API supports per-task overrides.
+// ElideOptions contains Elide-specific per-task configuration. MemoryLimit,
+// EnableAI and Timeout are sent to the daemon as per-execution overrides of
+// the session configuration (Timeout is enforced by the driver as well),
+// Profile selects a sandbox profile and SessionProfile the session the
+// execution runs in. Timezone and Locale are set in the execution's env and
+// sent as overrides too.
 type ElideOptions struct {
-	MemoryLimit int  `codec:"memory_limit"` // Per-task memory limit (not yet supported)
-	EnableAI    bool `codec:"enable_ai"`    // Per-task AI enable (not yet supported)
-	Timeout     int  `codec:"timeout"`      // Execution timeout in seconds (not yet supported)
+	MemoryLimit int    `codec:"memory_limit"` // Memory limit in MB (0 = session or profile limit)
+	EnableAI    bool   `codec:"enable_ai"`    // Enable AI features for the execution
+	Timeout     string `codec:"timeout"`      // Execution timeout, e.g. "30s" or "5m"
+	Profile     string `codec:"profile"`      // Sandbox profile name
+
+	SessionProfile string `codec:"session_profile"` // Session profile name
+	NumaNode       *int   `codec:"numa_node"`       // NUMA node to pin to (nil = unpinned)
+
+	Timezone string `codec:"timezone"` // IANA time zone, set as TZ
+	Locale   string `codec:"locale"`   // POSIX locale, set as LANG
+
+	TraceParent string `codec:"trace_parent"` // W3C traceparent of the trace to join
 }
 
-// Validate checks if the task configuration is valid
+// Validate checks if the task configuration is valid. It reports every
+// problem found, each as a FieldError naming the option, in one error.
 func (tc *TaskConfig) Validate() error {
-	if tc.Script == "" && tc.Code == "" {
-		return fmt.Errorf("either 'script' or 'code' must be specified")
+	return tc.validate(nil)
+}
+
+// ValidateFor is Validate, also checking that the task's language is among
+// the enabled ones.
+func (tc *TaskConfig) ValidateFor(enabledLanguages []string) error {
+	return tc.validate(enabledLanguages)
+}
+
+func (tc *TaskConfig) validate(enabledLanguages []string) error {
+	var errs validationErrors
+
+	var sources []string
+	for _, source := range []struct{ field, value string }{
+		{"script", tc.Script}, {"code", tc.Code}, {"code_oci_ref", tc.CodeOCIRef}, {"entrypoint", tc.Entrypoint},
+	} {
+		if source.value != "" {
+			sources = append(sources, source.field)
+		}
 	}
-	if tc.Script != "" && tc.Code != "" {
-		return fmt.Errorf("cannot specify both 'script' and 'code'")
+	if len(sources) == 0 {
+		errs.addf("code", "one of 'script', 'code', 'code_oci_ref' or 'entrypoint' must be specified")
 	}
-	// Basic language validation - actual validation against session config happens in driver
-	if tc.Language == "" {
-		return fmt.Errorf("language must be specified")
+	if len(sources) > 1 {
+		errs.addf(strings.Join(sources, ", "), "only one of 'script', 'code', 'code_oci_ref' or 'entrypoint' may be specified")
 	}
-	return nil
+	errs.add("script_source", tc.validateScriptSource())
+	if tc.CodeOCIEntrypoint != "" && tc.CodeOCIRef == "" {
+		errs.addf("code_oci_entrypoint", "'code_oci_entrypoint' requires 'code_oci_ref'")
+	}
+	if tc.Files != "" && tc.Entrypoint == "" {
+		errs.addf("files", "'files' requires 'entrypoint'")
+	}
+	if tc.Entrypoint != "" {
+		errs.add("entrypoint", validateWorkspacePath("entrypoint", tc.Entrypoint))
+		errs.add("files", validateWorkspacePath("files", tc.WorkspaceDir()))
+	}
+	if tc.StdinPath != "" && tc.StdinData != "" {
+		errs.addf("stdin_data", "only one of 'stdin_path' or 'stdin_data' may be specified")
+	}
+	if tc.StdinPath != "" {
+		errs.add("stdin_path", validateWorkspacePath("stdin_path", tc.StdinPath))
+	}
+	errs.add("ports", tc.validatePorts())
+	errs.add("result_schema", tc.validateResultSchema())
+	if tc.ScratchQuotaMB < 0 {
+		errs.addf("scratch_quota_mb", "scratch_quota_mb cannot be negative")
+	}
+	if _, err := ParseDuration("elide_opts.timeout", tc.ElideOpts.Timeout); err != nil {
+		errs.add("elide_opts.timeout", err)
+	}
+	if tc.ElideOpts.MemoryLimit < 0 {
+		errs.addf("elide_opts.memory_limit", "elide_opts.memory_limit cannot be negative")
+	}
+	if node := tc.ElideOpts.NumaNode; node != nil && *node < 0 {
+		errs.addf("elide_opts.numa_node", "elide_opts.numa_node cannot be negative")
+	}
+	errs.add("elide_opts.timezone", ValidateTimezone("elide_opts.timezone", tc.ElideOpts.Timezone))
+	errs.add("elide_opts.locale", ValidateLocale("elide_opts.locale", tc.ElideOpts.Locale))
+	errs.add("elide_opts.trace_parent", tc.validateTraceParent())
+	if _, err := ParseDuration("poll_interval", tc.PollInterval); err != nil {
+		errs.add("poll_interval", err)
+	}
+	errs.add("env", tc.validateEnv())
+	errs.add("imports", tc.validateSessionValues())
+	errs.add("output_mode", validateOutputMode(tc.OutputMode))
+	errs.add("binary_output", validateBinaryOutput(tc.BinaryOutput))
+	errs.add("args_matrix", tc.validateArgsMatrix())
+	// Basic language validation - actual validation against session config
+	// happens in the driver, or with ValidateFor
+	if language, err := tc.InferLanguage(); err != nil {
+		errs.add("language", err)
+	} else if enabledLanguages != nil {
+		errs.add("language", validateLanguage(language, enabledLanguages))
+	}
+	return errs.err()
 }
 
 // ValidateLanguage checks if the requested language is enabled in the session configuration
 func (tc *TaskConfig) ValidateLanguage(enabledLanguages []string) error {
+	return validateLanguage(tc.Language, enabledLanguages)
+}
+
+func validateLanguage(language string, enabledLanguages []string) error {
 	for _, lang := range enabledLanguages {
-		if tc.Language == lang {
+		if language == lang {
 			return nil
 		}
 	}
-	return fmt.Errorf("language %q not enabled in session (enabled: %v)", tc.Language, enabledLanguages)
+	return fmt.Errorf("language %q not enabled in session (enabled: %v)", language, enabledLanguages)
 }
 
+// validateEnv rejects env var names the daemon cannot apply and enforces the
+// env payload size limits.
+func (tc *TaskConfig) validateEnv() error {
+	switch tc.EnvCase {
+	case "", "upper", "lower":
+	default:
+		return &FieldError{Field: "env_case", Err: fmt.Errorf("invalid env_case %q (must be \"upper\" or \"lower\")", tc.EnvCase)}
+	}
+
+	if err := validateEnvSize(tc.Env); err != nil {
+		return err
+	}
+
+	for key, value := range tc.Env {
+		if err := validateEnvName(key); err != nil {
+			return err
+		}
+		if strings.ContainsRune(value, 0) {
+			return fmt.Errorf("env var %q value cannot contain NUL characters", key)
+		}
+	}
+	return nil
+}
+
+// validateEnvSize enforces the env payload size limits on env.
+func validateEnvSize(env map[string]string) error {
+	if len(env) > maxEnvVars {
+		return fmt.Errorf("too many env vars: %d (limit %d)", len(env), maxEnvVars)
+	}
+
+	size := 0
+	for key, value := range env {
+		size += len(key) + len(value)
+	}
+	if size > maxEnvPayloadBytes {
+		return fmt.Errorf("env payload is %d bytes (limit %d)", size, maxEnvPayloadBytes)
+	}
+	return nil
+}
+
+// ValidateCodeSize checks that code fits in the code payload limit agreed
+// with the daemon (published as the driver.elide.max_code_kb attribute).
+func ValidateCodeSize(code string, limit int64) error {
+	if limit > 0 && int64(len(code)) > limit {
+		return fmt.Errorf("code is %d KB, exceeding the daemon's limit of %d KB (driver.elide.max_code_kb)", (len(code)+1023)>>10, limit>>10)
+	}
+	return nil
+}
 
+// validateEnvName checks that name can be passed as an env var name.
+func validateEnvName(name string) error {
+	if name == "" {
+		return fmt.Errorf("env var name cannot be empty")
+	}
+	if strings.ContainsRune(name, '=') {
+		return fmt.Errorf("env var name %q cannot contain '='", name)
+	}
+	if strings.IndexFunc(name, func(r rune) bool { return unicode.IsSpace(r) || r == 0 }) >= 0 {
+		return fmt.Errorf("env var name %q cannot contain whitespace or NUL characters", name)
+	}
+	return nil
+}
+
+// validateSessionValues checks the task's session KV imports and exports.
+func (tc *TaskConfig) validateSessionValues() error {
+	for name, key := range tc.Imports {
+		if err := validateEnvName(name); err != nil {
+			return &FieldError{Field: "imports", Err: fmt.Errorf("invalid imports: %w", err)}
+		}
+		if key == "" {
+			return &FieldError{Field: "imports", Err: fmt.Errorf("invalid imports: key for env var %q cannot be empty", name)}
+		}
+		if _, ok := tc.Env[name]; ok {
+			return &FieldError{Field: "imports", Err: fmt.Errorf("invalid imports: env var %q is also set in env", name)}
+		}
+	}
+
+	seen := make(map[string]bool, len(tc.Exports))
+	for _, key := range tc.Exports {
+		if key == "" {
+			return &FieldError{Field: "exports", Err: fmt.Errorf("invalid exports: key cannot be empty")}
+		}
+		if seen[key] {
+			return &FieldError{Field: "exports", Err: fmt.Errorf("invalid exports: duplicate key %q", key)}
+		}
+		seen[key] = true
+	}
+	return nil
+}
+
+// NormalizedEnv returns a copy of the task env with names normalized
+// according to env_case. It fails if normalization makes two names collide.
+func (tc *TaskConfig) NormalizedEnv() (map[string]string, error) {
+	normalize := func(name string) string { return name }
+	switch tc.EnvCase {
+	case "upper":
+		normalize = strings.ToUpper
+	case "lower":
+		normalize = strings.ToLower
+	}
+
+	env := make(map[string]string, len(tc.Env))
+	origin := make(map[string]string, len(tc.Env))
+	for key, value := range tc.Env {
+		name := normalize(key)
+		if other, ok := origin[name]; ok {
+			return nil, fmt.Errorf("env vars %q and %q collide after %s-casing", other, key, tc.EnvCase)
+		}
+		origin[name] = key
+		env[name] = value
+	}
+	return env, nil
+}
+
+// ScriptPath resolves the script file inside the task directory, or with
+// script_source = "artifact" inside the first artifact directory holding it.
+// Paths that escape the task directory are rejected; symlinks are resolved
+// and, unless followSymlinks is set, must also stay inside the task directory.
+func (tc *TaskConfig) ScriptPath(taskDir string, followSymlinks bool) (string, error) {
+	if tc.ScriptSource == scriptSourceArtifact {
+		return tc.artifactScriptPath(taskDir, followSymlinks)
+	}
+	return resolveTaskFile(taskDir, "script", tc.Script, followSymlinks)
+}
+
+// EnvFilePath resolves env_file inside the task directory, with the same
+// containment rules as ScriptPath.
+func (tc *TaskConfig) EnvFilePath(taskDir string, followSymlinks bool) (string, error) {
+	return resolveTaskFile(taskDir, "env_file", tc.EnvFile, followSymlinks)
+}
+
+// resolveTaskFile resolves name, given by the task option, inside the task
+// directory. Symlinks are resolved and must stay inside the task directory
+// unless followSymlinks is set.
+func resolveTaskFile(taskDir string, option string, name string, followSymlinks bool) (string, error) {
+	baseDir := filepath.Clean(taskDir)
+	path := filepath.Clean(filepath.Join(baseDir, name))
+	if !strings.HasPrefix(path, baseDir+string(os.PathSeparator)) {
+		return "", fmt.Errorf("%s path %q escapes task directory", option, name)
+	}
+
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", fmt.Errorf("%s file %q not found in task directory", option, name)
+		}
+		return "", fmt.Errorf("failed to resolve %s path %q: %w", option, name, err)
+	}
+	if followSymlinks {
+		return resolved, nil
+	}
+
+	// The task directory itself may live behind a symlink
+	resolvedBase, err := filepath.EvalSymlinks(baseDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve task directory: %w", err)
+	}
+	if !strings.HasPrefix(resolved, resolvedBase+string(os.PathSeparator)) {
+		return "", fmt.Errorf("%s %q is a symlink to %q outside the task directory (set follow_symlinks = true in the plugin config to allow)", option, name, resolved)
+	}
+	return resolved, nil
+}
+
+// Validate checks the plugin configuration.
+func (c *Config) Validate() error {
+	if _, err := ParseDuration("execution_timeout", c.ExecutionTimeout); err != nil {
+		return err
+	}
+	if err := ValidateTimezone("default_timezone", c.DefaultTimezone); err != nil {
+		return err
+	}
+	if err := ValidateLocale("default_locale", c.DefaultLocale); err != nil {
+		return err
+	}
+	if _, err := ParseDuration("poll_interval", c.PollInterval); err != nil {
+		return err
+	}
+	if c.MaxConcurrentExecutions < 0 {
+		return fmt.Errorf("max_concurrent_executions cannot be negative")
+	}
+	if c.MaxConcurrentExecutionsPerSession < 0 {
+		return fmt.Errorf("max_concurrent_executions_per_session cannot be negative")
+	}
+	if c.HealthFailureThreshold < 0 {
+		return fmt.Errorf("health_failure_threshold cannot be negative")
+	}
+	for _, option := range [][2]string{{"submit_timeout", c.SubmitTimeout}, {"status_timeout", c.StatusTimeout}, {"cancel_timeout", c.CancelTimeout}, {"cancel_grace", c.CancelGrace}, {"queue_timeout", c.QueueTimeout}, {"max_task_lifetime", c.MaxTaskLifetime}} {
+		timeout, err := ParseDuration(option[0], option[1])
+		if err != nil {
+			return err
+		}
+		if option[1] != "" && timeout == 0 {
+			return fmt.Errorf("invalid %s %q: must be positive", option[0], option[1])
+		}
+	}
+	if err := c.validateCompletionWait(); err != nil {
+		return err
+	}
+	if err := c.validateDaemonLoss(); err != nil {
+		return err
+	}
+	if err := c.validateRecoverPolicy(); err != nil {
+		return err
+	}
+	if err := c.validateOrphanPolicy(); err != nil {
+		return err
+	}
+	if _, err := c.rpcLogSampleRate(); err != nil {
+		return err
+	}
+	if _, err := ParseDuration("rpc_slow_threshold", c.RPCSlowThreshold); err != nil {
+		return err
+	}
+	if _, err := ParseDuration("daemon_log_dedup_window", c.DaemonLogDedupWindow); err != nil {
+		return err
+	}
+	if err := c.validateOtelEndpoint(); err != nil {
+		return err
+	}
+	if err := c.validateMetricsLabels(); err != nil {
+		return err
+	}
+	if err := validateCorrelationEnvPrefix(c.CorrelationEnvPrefix); err != nil {
+		return err
+	}
+	for _, user := range c.AllowedUsers {
+		if user == "" || strings.ContainsFunc(user, unicode.IsSpace) {
+			return fmt.Errorf("invalid allowed_users entry %q", user)
+		}
+	}
+	if _, err := c.daemonVersionConstraint(); err != nil {
+		return err
+	}
+	if c.DaemonConsulService != "" && c.DaemonAddress != "" {
+		return fmt.Errorf("only one of 'daemon_address' or 'daemon_consul_service' may be specified")
+	}
+	if err := c.DaemonSupervisor.Validate(); err != nil {
+		return fmt.Errorf("daemon_supervisor: %w", err)
+	}
+	if c.DaemonSupervisor.Enabled && (c.DaemonAddress != "" || c.DaemonConsulService != "") {
+		return fmt.Errorf("daemon_supervisor requires 'daemon_socket', not 'daemon_address' or 'daemon_consul_service'")
+	}
+	if c.DaemonSupervisor.Enabled && c.ElideBinary == "" {
+		return fmt.Errorf("daemon_supervisor requires 'elide_binary'")
+	}
+	if err := c.DaemonConsul.Validate(); err != nil {
+		return fmt.Errorf("daemon_consul: %w", err)
+	}
+	if err := c.DaemonTLS.Validate(); err != nil {
+		return fmt.Errorf("daemon_tls: %w", err)
+	}
+	for name, endpoint := range c.DaemonEndpoints {
+		if err := endpoint.Validate(name); err != nil {
+			return fmt.Errorf("daemon_endpoint %q: %w", name, err)
+		}
+	}
+	if _, err := c.SessionRetry.Policy(); err != nil {
+		return fmt.Errorf("session_retry: %w", err)
+	}
+	if _, err := c.RPCRetryPolicies(); err != nil {
+		return err
+	}
+	switch c.SessionPer {
+	case "", sessionPerClient, sessionPerAlloc, sessionPerTask:
+	default:
+		return fmt.Errorf("invalid session_per %q (must be %q, %q or %q)", c.SessionPer, sessionPerClient, sessionPerAlloc, sessionPerTask)
+	}
+	if err := c.RestartGuard.Validate(); err != nil {
+		return fmt.Errorf("restart_guard: %w", err)
+	}
+	if err := c.Forensics.Validate(); err != nil {
+		return fmt.Errorf("forensics: %w", err)
+	}
+	if err := c.CredentialExchange.Validate(); err != nil {
+		return fmt.Errorf("credential_exchange: %w", err)
+	}
+	for name, profile := range c.Profiles {
+		if err := profile.Validate(c.SessionConfig.intrinsics()); err != nil {
+			return fmt.Errorf("profile %q: %w", name, err)
+		}
+	}
+	if err := c.DeniedModules.Validate(); err != nil {
+		return fmt.Errorf("denied_modules: %w", err)
+	}
+	for name, profile := range c.SessionProfiles {
+		if err := profile.Validate(name); err != nil {
+			return fmt.Errorf("session_profile %q: %w", name, err)
+		}
+	}
+	for _, language := range []string{"python", "javascript", "typescript"} {
+		defaults := c.LanguageDefaults.For(language)
+		for name := range defaults.Env {
+			if err := validateEnvName(name); err != nil {
+				return fmt.Errorf("language_defaults.%s: %w", language, err)
+			}
+		}
+	}
+	return nil
+}
+
+// validateCompletionWait checks that completion_wait leaves ExecuteSnippet
+// time to respond within submit_timeout.
+func (c *Config) validateCompletionWait() error {
+	wait, err := ParseDuration("completion_wait", c.CompletionWait)
+	if err != nil {
+		return err
+	}
+	if submitTimeout := durationOr(defaultSubmitTimeout, c.SubmitTimeout); wait >= submitTimeout {
+		return fmt.Errorf("invalid completion_wait %q: must be shorter than submit_timeout (%s)", c.CompletionWait, submitTimeout)
+	}
+	return nil
+}
+
+// rpcLogSampleRate parses rpc_log_sample_rate.
+func (c *Config) rpcLogSampleRate() (float64, error) {
+	if c.RPCLogSampleRate == "" {
+		return 0, nil
+	}
+	rate, err := strconv.ParseFloat(c.RPCLogSampleRate, 64)
+	if err != nil || rate < 0 || rate > 1 {
+		return 0, fmt.Errorf("invalid rpc_log_sample_rate %q: must be a number between 0 and 1", c.RPCLogSampleRate)
+	}
+	return rate, nil
+}
+
+// ParseDuration parses a duration option such as "30s" or "5m". An empty
+// value means unset and yields zero; bare integers are read as seconds for
+// compatibility with older numeric options.
+func ParseDuration(field string, value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		seconds, convErr := strconv.Atoi(value)
+		if convErr != nil {
+			return 0, fmt.Errorf("invalid %s %q: must be a duration such as \"30s\" or \"5m\"", field, value)
+		}
+		d = time.Duration(seconds) * time.Second
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("invalid %s %q: cannot be negative", field, value)
+	}
+	return d, nil
+}
+
+// durationOr returns the first non-zero duration of the given options,
+// ignoring options that do not parse (they are rejected during validation).
+func durationOr(fallback time.Duration, options ...string) time.Duration {
+	for _, option := range options {
+		if d, err := ParseDuration("", option); err == nil && d > 0 {
+			return d
+		}
+	}
+	return fallback
+}
+
+// LoadConfigFile reads a plugin config file for running the driver outside
+// of Nomad. The file holds the body of the plugin's config block, in HCL or
+// (with a .json extension) JSON; an empty path yields the default config.
+func LoadConfigFile(path string) (*base.Config, error) {
+	spec, diags := hclspecutils.Convert(configSpec)
+	if diags.HasErrors() {
+		return nil, fmt.Errorf("failed to convert config spec: %s", diags.Error())
+	}
+
+	var src []byte
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config file: %w", err)
+		}
+		src = data
+	}
+
+	parser := hclparse.NewParser()
+	var file *hcl.File
+	if filepath.Ext(path) == ".json" {
+		file, diags = parser.ParseJSON(src, path)
+	} else {
+		file, diags = parser.ParseHCL(src, path)
+	}
+	if diags.HasErrors() {
+		return nil, fmt.Errorf("invalid config file: %s", diags.Error())
+	}
+
+	value, diags := hcldec.Decode(file.Body, spec, nil)
+	if diags.HasErrors() {
+		return nil, fmt.Errorf("invalid config file: %s", diags.Error())
+	}
+
+	data, err := msgpack.Marshal(value, value.Type())
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode config: %w", err)
+	}
+	return &base.Config{PluginConfig: data}, nil
+}
@@ -0,0 +1,60 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"fmt"
+	"maps"
+)
+
+// Correlation env vars injected into executions; the execution and session
+// IDs are prefixed with correlation_env_prefix
+const (
+	correlationExecutionID = "EXECUTION_ID"
+	correlationSessionID   = "SESSION_ID"
+	correlationAllocID     = "NOMAD_ALLOC_ID"
+)
+
+// validateCorrelationEnvPrefix checks correlation_env_prefix: it must be
+// usable at the start of an env var name in any shell.
+func validateCorrelationEnvPrefix(prefix string) error {
+	for i, r := range prefix {
+		switch {
+		case r >= 'A' && r <= 'Z', r >= 'a' && r <= 'z', r == '_':
+		case r >= '0' && r <= '9' && i > 0:
+		default:
+			return fmt.Errorf("invalid correlation_env_prefix %q: only letters, digits and '_' are allowed, and it cannot start with a digit", prefix)
+		}
+	}
+	return nil
+}
+
+// CorrelationEnv returns the env vars identifying an execution, so that logs
+// and external calls of the snippet can be correlated with driver and daemon
+// records.
+func CorrelationEnv(prefix, executionID, sessionID, allocID string) map[string]string {
+	env := map[string]string{
+		prefix + correlationExecutionID: executionID,
+		prefix + correlationSessionID:   sessionID,
+	}
+	if allocID != "" {
+		env[correlationAllocID] = allocID
+	}
+	return env
+}
+
+// executionEnv returns the environment an execution is submitted with: the
+// task's, plus the correlation env vars unless correlation_env is off. The
+// correlation vars take precedence so they can be trusted.
+func (d *ElideDriverPlugin) executionEnv(env map[string]string, executionID, sessionID, allocID string) map[string]string {
+	if !d.config.CorrelationEnv {
+		return env
+	}
+	merged := maps.Clone(env)
+	if merged == nil {
+		merged = map[string]string{}
+	}
+	maps.Copy(merged, CorrelationEnv(d.config.CorrelationEnvPrefix, executionID, sessionID, allocID))
+	return merged
+}
@@ -0,0 +1,244 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/nomad/plugins/drivers"
+)
+
+const (
+	// defaultCredentialEnv is the env var receiving a minted credential if
+	// credential_exchange.env is unset
+	defaultCredentialEnv = "ELIDE_CREDENTIAL"
+
+	// defaultCredentialExchangeTimeout bounds one token exchange if
+	// credential_exchange.timeout is unset
+	defaultCredentialExchangeTimeout = 10 * time.Second
+
+	// RFC 8693 token exchange parameters
+	tokenExchangeGrantType = "urn:ietf:params:oauth:grant-type:token-exchange"
+	tokenTypeJWT           = "urn:ietf:params:oauth:token-type:jwt"
+
+	// metricCredentialMints counts token exchanges, by result
+	metricCredentialMints = "credential_mints_total"
+)
+
+// identityNamePattern matches the names of Nomad workload identities
+var identityNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// CredentialExchangeConfig configures minting a short-lived credential for
+// each task by exchanging its Nomad workload identity token at an RFC 8693
+// token exchange endpoint.
+type CredentialExchangeConfig struct {
+	// Endpoint is the token exchange URL
+	Endpoint string `codec:"endpoint"`
+	// Identity is the name of the workload identity exchanged ("" = the
+	// task's default identity)
+	Identity string `codec:"identity"`
+	// Audience requested for the credential (optional)
+	Audience string `codec:"audience"`
+	// Env is the env var the credential is injected as
+	Env string `codec:"env"`
+	// Timeout bounds one exchange (duration string)
+	Timeout string `codec:"timeout"`
+}
+
+// Enabled reports whether credential minting is configured.
+func (c *CredentialExchangeConfig) Enabled() bool {
+	return c != nil && c.Endpoint != ""
+}
+
+// Validate checks the credential_exchange block.
+func (c *CredentialExchangeConfig) Validate() error {
+	if !c.Enabled() {
+		return nil
+	}
+	u, err := url.Parse(c.Endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid endpoint %q (must be an http:// or https:// URL)", c.Endpoint)
+	}
+	if c.Identity != "" && !identityNamePattern.MatchString(c.Identity) {
+		return fmt.Errorf("invalid identity %q", c.Identity)
+	}
+	if c.Env != "" {
+		if err := validateEnvName(c.Env); err != nil {
+			return err
+		}
+	}
+	timeout, err := ParseDuration("timeout", c.Timeout)
+	if err != nil {
+		return err
+	}
+	if c.Timeout != "" && timeout == 0 {
+		return fmt.Errorf("invalid timeout %q: must be positive", c.Timeout)
+	}
+	return nil
+}
+
+// Credential is a short-lived credential minted for a task.
+type Credential struct {
+	// Token is the credential itself
+	Token string
+	// ExpiresAt is when it expires, zero if the endpoint did not say
+	ExpiresAt time.Time
+}
+
+// CredentialMinter exchanges tasks' workload identity tokens for short-lived
+// credentials, so snippets can call internal APIs without long-lived secrets
+// in job specs.
+type CredentialMinter struct {
+	config CredentialExchangeConfig
+	client *http.Client
+	logger hclog.Logger
+}
+
+// NewCredentialMinter returns a minter for a validated credential_exchange
+// config.
+func NewCredentialMinter(config CredentialExchangeConfig, logger hclog.Logger) *CredentialMinter {
+	if config.Env == "" {
+		config.Env = defaultCredentialEnv
+	}
+	timeout, _ := ParseDuration("timeout", config.Timeout)
+	if timeout == 0 {
+		timeout = defaultCredentialExchangeTimeout
+	}
+	return &CredentialMinter{
+		config: config,
+		client: &http.Client{Timeout: timeout},
+		logger: logger.Named("credentials"),
+	}
+}
+
+// Mint exchanges the task's workload identity token for a credential. It
+// returns nil if the task has no such identity.
+func (m *CredentialMinter) Mint(ctx context.Context, cfg *drivers.TaskConfig) (*Credential, error) {
+	token, err := WorkloadIdentityToken(cfg, m.config.Identity)
+	if err != nil {
+		return nil, err
+	}
+	if token == "" {
+		m.logger.Debug("task has no workload identity token, not minting a credential", "task_id", cfg.ID, "identity", m.config.Identity)
+		return nil, nil
+	}
+
+	form := url.Values{
+		"grant_type":           {tokenExchangeGrantType},
+		"subject_token":        {token},
+		"subject_token_type":   {tokenTypeJWT},
+		"requested_token_type": {"urn:ietf:params:oauth:token-type:access_token"},
+	}
+	if m.config.Audience != "" {
+		form.Set("audience", m.config.Audience)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.config.Endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("token exchange failed: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err != nil {
+		return nil, fmt.Errorf("token exchange failed: %w", err)
+	}
+
+	var result struct {
+		AccessToken      string `json:"access_token"`
+		ExpiresIn        int64  `json:"expires_in"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if resp.StatusCode/100 != 2 {
+		if json.Unmarshal(body, &result) == nil && result.Error != "" {
+			return nil, fmt.Errorf("token exchange returned %s: %s", resp.Status, strings.TrimSpace(result.Error+" "+result.ErrorDescription))
+		}
+		return nil, fmt.Errorf("token exchange returned %s: %s", resp.Status, bytes.TrimSpace(body))
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("invalid token exchange response: %w", err)
+	}
+	if result.AccessToken == "" {
+		return nil, fmt.Errorf("invalid token exchange response: no access_token")
+	}
+
+	credential := &Credential{Token: result.AccessToken}
+	if result.ExpiresIn > 0 {
+		credential.ExpiresAt = time.Now().Add(time.Duration(result.ExpiresIn) * time.Second).UTC()
+	}
+	m.logger.Debug("minted credential", "task_id", cfg.ID, "expires_at", credential.ExpiresAt)
+	return credential, nil
+}
+
+// Apply injects a credential into an execution's env as the configured env
+// var, and its expiry as <env>_EXPIRES_AT (RFC 3339) if known.
+func (m *CredentialMinter) Apply(env map[string]string, credential *Credential) {
+	env[m.config.Env] = credential.Token
+	if !credential.ExpiresAt.IsZero() {
+		env[m.config.Env+"_EXPIRES_AT"] = credential.ExpiresAt.Format(time.RFC3339)
+	}
+}
+
+// WorkloadIdentityToken returns the task's token for a workload identity
+// ("" = the default identity), from the env var Nomad sets with env = true
+// or the file it writes to the secrets directory with file = true. It
+// returns "" if the task has neither.
+func WorkloadIdentityToken(cfg *drivers.TaskConfig, identity string) (string, error) {
+	envVar, file := "NOMAD_TOKEN", "nomad_token"
+	if identity != "" {
+		envVar, file = "NOMAD_TOKEN_"+identity, "nomad_"+identity+".jwt"
+	}
+	if token := cfg.Env[envVar]; token != "" {
+		return token, nil
+	}
+	if cfg.AllocDir == "" {
+		return "", nil
+	}
+	data, err := os.ReadFile(filepath.Join(cfg.TaskDir().SecretsDir, file))
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read workload identity token: %w", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// mintCredential mints and injects the task's credential if
+// credential_exchange is configured.
+func (d *ElideDriverPlugin) mintCredential(ctx context.Context, cfg *drivers.TaskConfig, env map[string]string) error {
+	if d.credentials == nil {
+		return nil
+	}
+	credential, err := d.credentials.Mint(ctx, cfg)
+	if err != nil {
+		d.metrics.IncrCounter(metricCredentialMints, "Credentials minted from workload identities, by result.", "result", "failed")
+		return fmt.Errorf("failed to mint credential: %w", err)
+	}
+	if credential == nil {
+		return nil
+	}
+	d.metrics.IncrCounter(metricCredentialMints, "Credentials minted from workload identities, by result.", "result", "minted")
+	d.credentials.Apply(env, credential)
+	return nil
+}
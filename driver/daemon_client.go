@@ -5,11 +5,20 @@ package driver
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"net"
+	"slices"
+	"sync"
+	"time"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/backoff"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 
 	pb "github.com/elide-dev/elide-task-driver/proto/gen/go/elide/daemon/v1alpha1"
 )
@@ -20,31 +29,129 @@ type DaemonClient interface {
 	CreateSession(ctx context.Context, sessionID string, config *pb.SessionConfiguration) (*pb.CreateSessionResponse, error)
 	GetSession(ctx context.Context, sessionID string) (*pb.GetSessionResponse, error)
 	DeleteSession(ctx context.Context, sessionID string) error
+	RecycleContexts(ctx context.Context, sessionID string) (*pb.RecycleContextsResponse, error)
 
 	// Execution within Session
-	ExecuteSnippet(ctx context.Context, sessionID string, executionID string, code string, language string, env map[string]string, args []string) (*pb.ExecuteSnippetResponse, error)
-	GetExecutionStatus(ctx context.Context, sessionID string, executionID string) (*pb.GetExecutionStatusResponse, error)
-	CancelExecution(ctx context.Context, sessionID string, executionID string) error
+	ExecuteSnippet(ctx context.Context, req ExecuteSnippetRequest) (*pb.ExecuteSnippetResponse, error)
+	UploadWorkspace(ctx context.Context, sessionID string, workspaceID string, files []WorkspaceFile) (*pb.UploadWorkspaceResponse, error)
+	OpenStdin(ctx context.Context, sessionID string, executionID string, stdin io.Reader) (*pb.OpenStdinResponse, error)
+	GetExecutionStatus(ctx context.Context, sessionID string, executionID string, output OutputRequest) (*pb.GetExecutionStatusResponse, error)
+	WatchExecution(ctx context.Context, sessionID string, executionID string, order bool) (StatusStream, error)
+	CancelExecution(ctx context.Context, sessionID string, executionID string, reason pb.CancellationReason, initiator string, token string) (cancelled bool, err error)
+	KillExecution(ctx context.Context, sessionID string, executionID string, initiator string) (killed bool, err error)
+	SignalExecution(ctx context.Context, sessionID string, executionID string, signal string, named bool) (delivered bool, err error)
+	ExecInSession(ctx context.Context, sessionID string, code string, language string, env map[string]string, timeout time.Duration, runAs string, mounts []*pb.Mount, tags map[string]string) (*pb.ExecInSessionResponse, error)
+
+	// Lifecycle events of sessions and executions
+	WatchSessionEvents(ctx context.Context, sessionID string) (SessionEventStream, error)
+
+	// Session key-value store
+	PutSessionValue(ctx context.Context, sessionID string, key string, value string) error
+	GetSessionValue(ctx context.Context, sessionID string, key string) (value string, found bool, err error)
 
 	// Health check
-	Health(ctx context.Context) error
+	Health(ctx context.Context) (*pb.HealthResponse, error)
+
+	// Reconnect makes the connection re-dial the daemon right away instead
+	// of when its backoff expires, e.g. once a restarted daemon is back
+	Reconnect()
 
 	// Close closes the connection to the daemon
 	Close() error
 }
 
+// StatusStream receives the status updates of a watched execution. Recv
+// returns io.EOF after the status that completes the execution.
+type StatusStream interface {
+	Recv() (*pb.GetExecutionStatusResponse, error)
+}
+
+// OutputRequest selects the output carried by an execution status. The zero
+// value requests all output produced so far.
+type OutputRequest struct {
+	// Omit leaves stdout and stderr out of the status
+	Omit bool
+
+	// StdoutOffset and StderrOffset skip output received already
+	StdoutOffset uint64
+	StderrOffset uint64
+
+	// Order requests the order the output was written in
+	Order bool
+}
+
+// ExecuteSnippetRequest is an execution to start in a session. Fields left
+// unset take the daemon's defaults.
+type ExecuteSnippetRequest struct {
+	SessionID   string
+	ExecutionID string
+
+	Code            string
+	Language        string
+	Env             map[string]string
+	Args            []string
+	InterpreterArgs []string
+
+	Limits    *pb.ExecutionLimits
+	Overrides *pb.ExecutionOverrides
+	Topology  *pb.TopologyHints
+
+	// RunAs is the user the execution runs as
+	RunAs     string
+	Mounts    []*pb.Mount
+	Workspace *pb.WorkspaceRef
+
+	// Stdin keeps the execution's stdin open for OpenStdin
+	Stdin bool
+	Ports []*pb.PortBinding
+
+	// DiscardOutput has the daemon drop the execution's output
+	DiscardOutput bool
+	Tags          map[string]string
+
+	// CompletionWait is how long the daemon may hold the response for the
+	// execution to complete (0 responds right away)
+	CompletionWait time.Duration
+}
+
+// SessionEventStream receives session lifecycle events. It has no end; Recv
+// fails once the stream's context is cancelled.
+type SessionEventStream interface {
+	Recv() (*pb.SessionEvent, error)
+}
+
 // elideDaemonClient is the implementation of DaemonClient
 type elideDaemonClient struct {
+	// mu guards conn and executionClient, which redial replaces
+	mu              sync.RWMutex
 	conn            *grpc.ClientConn
 	executionClient pb.ExecutionApiClient
 	sessionID       string // Cached session ID for this client
+
+	// target and opts are what the connection was dialled with
+	target string
+	opts   []grpc.DialOption
+}
+
+// connectParams bound the backoff of the connection re-dialling the daemon
+// by the driver's own reconnection backoff, instead of gRPC's default of up
+// to two minutes
+var connectParams = grpc.ConnectParams{
+	Backoff: backoff.Config{
+		BaseDelay:  reconnectBaseDelay,
+		Multiplier: 2,
+		Jitter:     0.2,
+		MaxDelay:   reconnectMaxDelay,
+	},
+	MinConnectTimeout: reconnectProbeTimeout,
 }
 
 // NewDaemonClient creates a new client connected to the Elide daemon
-// It supports both Unix socket and TCP connections
-func NewDaemonClient(socketPath string, tcpAddress string) (DaemonClient, error) {
-	var conn *grpc.ClientConn
-	var err error
+// It supports both Unix socket and TCP connections; opts are added to the
+// dial options (e.g. interceptors)
+func NewDaemonClient(socketPath string, tcpAddress string, opts ...grpc.DialOption) (DaemonClient, error) {
+	var target string
+	var dialOpts []grpc.DialOption
 
 	if socketPath != "" {
 		// Connect via Unix socket
@@ -52,36 +159,72 @@ func NewDaemonClient(socketPath string, tcpAddress string) (DaemonClient, error)
 			return net.Dial("unix", addr)
 		}
 
-		conn, err = grpc.Dial(
-			socketPath,
+		target = socketPath
+		dialOpts = append([]grpc.DialOption{
 			grpc.WithTransportCredentials(insecure.NewCredentials()),
 			grpc.WithContextDialer(dialer),
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to connect via Unix socket: %w", err)
-		}
+			grpc.WithConnectParams(connectParams),
+		}, opts...)
 	} else if tcpAddress != "" {
 		// Connect via TCP
-		conn, err = grpc.Dial(
-			tcpAddress,
+		target = tcpAddress
+		dialOpts = append([]grpc.DialOption{
 			grpc.WithTransportCredentials(insecure.NewCredentials()),
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to connect via TCP: %w", err)
-		}
+			grpc.WithConnectParams(connectParams),
+		}, opts...)
 	} else {
 		return nil, fmt.Errorf("either socket_path or tcp_address must be specified")
 	}
 
+	conn, err := grpc.Dial(target, dialOpts...)
+	if err != nil {
+		if socketPath != "" {
+			return nil, fmt.Errorf("failed to connect via Unix socket: %w", err)
+		}
+		return nil, fmt.Errorf("failed to connect via TCP: %w", err)
+	}
+
 	return &elideDaemonClient{
 		conn:            conn,
 		executionClient: pb.NewExecutionApiClient(conn),
+		target:          target,
+		opts:            dialOpts,
 	}, nil
 }
 
+// api returns the client of the current connection
+func (c *elideDaemonClient) api() pb.ExecutionApiClient {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.executionClient
+}
+
+// redial dials the daemon again with extra dial options, such as rotated TLS
+// credentials, and switches to the new connection once it answered a health
+// check, so no call sees a connection that is not ready. The previous
+// connection is returned so calls and streams in flight on it can finish
+// before the caller closes it.
+func (c *elideDaemonClient) redial(ctx context.Context, opts ...grpc.DialOption) (*grpc.ClientConn, error) {
+	conn, err := grpc.Dial(c.target, append(slices.Clone(c.opts), opts...)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial daemon: %w", err)
+	}
+	client := pb.NewExecutionApiClient(conn)
+	if _, err := client.Health(ctx, &pb.HealthRequest{}, grpc.WaitForReady(true)); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("health check on new connection failed: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	old := c.conn
+	c.conn, c.executionClient = conn, client
+	return old, nil
+}
+
 // CreateSession creates a new session with the given configuration
 func (c *elideDaemonClient) CreateSession(ctx context.Context, sessionID string, config *pb.SessionConfiguration) (*pb.CreateSessionResponse, error) {
-	resp, err := c.executionClient.CreateSession(ctx, &pb.CreateSessionRequest{
+	resp, err := c.api().CreateSession(ctx, &pb.CreateSessionRequest{
 		SessionId: sessionID,
 		Config:    config,
 	})
@@ -94,7 +237,7 @@ func (c *elideDaemonClient) CreateSession(ctx context.Context, sessionID string,
 
 // GetSession retrieves session information
 func (c *elideDaemonClient) GetSession(ctx context.Context, sessionID string) (*pb.GetSessionResponse, error) {
-	resp, err := c.executionClient.GetSession(ctx, &pb.GetSessionRequest{
+	resp, err := c.api().GetSession(ctx, &pb.GetSessionRequest{
 		SessionId: sessionID,
 	})
 	if err != nil {
@@ -105,7 +248,7 @@ func (c *elideDaemonClient) GetSession(ctx context.Context, sessionID string) (*
 
 // DeleteSession closes and cleans up a session
 func (c *elideDaemonClient) DeleteSession(ctx context.Context, sessionID string) error {
-	_, err := c.executionClient.DeleteSession(ctx, &pb.DeleteSessionRequest{
+	_, err := c.api().DeleteSession(ctx, &pb.DeleteSessionRequest{
 		SessionId: sessionID,
 	})
 	if err != nil {
@@ -117,57 +260,305 @@ func (c *elideDaemonClient) DeleteSession(ctx context.Context, sessionID string)
 	return nil
 }
 
-// ExecuteSnippet executes a code snippet within a session
-func (c *elideDaemonClient) ExecuteSnippet(ctx context.Context, sessionID string, executionID string, code string, language string, env map[string]string, args []string) (*pb.ExecuteSnippetResponse, error) {
-	resp, err := c.executionClient.ExecuteSnippet(ctx, &pb.ExecuteSnippetRequest{
-		SessionId:   sessionID,
-		ExecutionId: executionID,
-		Code:        code,
-		Language:    language,
-		Env:         env,
-		Args:        args,
+// RecycleContexts replaces the interpreter contexts of a session
+func (c *elideDaemonClient) RecycleContexts(ctx context.Context, sessionID string) (*pb.RecycleContextsResponse, error) {
+	resp, err := c.api().RecycleContexts(ctx, &pb.RecycleContextsRequest{
+		SessionId: sessionID,
 	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to recycle contexts: %w", err)
+	}
+	return resp, nil
+}
+
+// ExecuteSnippet executes a code snippet within a session,
+// and returns a *DaemonOverloadedError if the daemon sheds the request.
+func (c *elideDaemonClient) ExecuteSnippet(ctx context.Context, req ExecuteSnippetRequest) (*pb.ExecuteSnippetResponse, error) {
+	var trailer metadata.MD
+	resp, err := c.api().ExecuteSnippet(ctx, &pb.ExecuteSnippetRequest{
+		SessionId:       req.SessionID,
+		ExecutionId:     req.ExecutionID,
+		Code:            req.Code,
+		Language:        req.Language,
+		Env:             req.Env,
+		Args:            req.Args,
+		InterpreterArgs: req.InterpreterArgs,
+		Limits:          req.Limits,
+		Overrides:       req.Overrides,
+		Topology:        req.Topology,
+		RunAs:           req.RunAs,
+		Mounts:          req.Mounts,
+		Workspace:       req.Workspace,
+		Stdin:           req.Stdin,
+		Ports:           req.Ports,
+		DiscardOutput:   req.DiscardOutput,
+		Tags:            req.Tags,
+
+		CompletionWaitMs: uint32(req.CompletionWait.Milliseconds()),
+	}, grpc.Trailer(&trailer))
+	if status.Code(err) == codes.ResourceExhausted {
+		return nil, &DaemonOverloadedError{RetryAfter: ParseRetryAfter(trailer), Err: err}
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute snippet: %w", err)
 	}
+	if resp.Completion != nil {
+		decodeRawOutput(resp.Completion)
+	}
+	return resp, nil
+}
+
+// UploadWorkspace streams the files of a workspace to the daemon
+func (c *elideDaemonClient) UploadWorkspace(ctx context.Context, sessionID string, workspaceID string, files []WorkspaceFile) (*pb.UploadWorkspaceResponse, error) {
+	stream, err := c.api().UploadWorkspace(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload workspace: %w", err)
+	}
+	// Send fails with io.EOF once the daemon ended the stream; its error
+	// is then returned by CloseAndRecv
+	if err := sendWorkspace(stream.Send, sessionID, workspaceID, files); err != nil && !errors.Is(err, io.EOF) {
+		stream.CloseSend()
+		return nil, fmt.Errorf("failed to upload workspace: %w", err)
+	}
+	resp, err := stream.CloseAndRecv()
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload workspace: %w", err)
+	}
 	return resp, nil
 }
 
+// OpenStdin streams the stdin of an execution submitted with stdin set
+func (c *elideDaemonClient) OpenStdin(ctx context.Context, sessionID string, executionID string, stdin io.Reader) (*pb.OpenStdinResponse, error) {
+	stream, err := c.api().OpenStdin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open stdin: %w", err)
+	}
+	// As with workspaces, the daemon's error is returned by CloseAndRecv
+	if err := sendStdin(stream.Send, sessionID, executionID, stdin); err != nil && !errors.Is(err, io.EOF) {
+		stream.CloseSend()
+		return nil, fmt.Errorf("failed to open stdin: %w", err)
+	}
+	resp, err := stream.CloseAndRecv()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open stdin: %w", err)
+	}
+	return resp, nil
+}
+
+// statusRequests pools GetExecutionStatus requests, by far the most frequent
+// RPC (one per tracked execution per poll interval). gRPC has marshaled the
+// request by the time the call returns, so it can be reused right away.
+var statusRequests = sync.Pool{
+	New: func() interface{} { return new(pb.GetExecutionStatusRequest) },
+}
+
+// excludeOutput is the include_output value of status requests omitting
+// output, shared so polls do not allocate it (it is only ever read)
+var excludeOutput = false
+
 // GetExecutionStatus gets the current status of an execution
-func (c *elideDaemonClient) GetExecutionStatus(ctx context.Context, sessionID string, executionID string) (*pb.GetExecutionStatusResponse, error) {
-	resp, err := c.executionClient.GetExecutionStatus(ctx, &pb.GetExecutionStatusRequest{
+func (c *elideDaemonClient) GetExecutionStatus(ctx context.Context, sessionID string, executionID string, output OutputRequest) (*pb.GetExecutionStatusResponse, error) {
+	req := statusRequests.Get().(*pb.GetExecutionStatusRequest)
+	req.SessionId = sessionID
+	req.ExecutionId = executionID
+	if output.Omit {
+		req.IncludeOutput = &excludeOutput
+	}
+	req.StdoutOffset = output.StdoutOffset
+	req.StderrOffset = output.StderrOffset
+	req.IncludeOutputOrder = output.Order
+
+	resp, err := c.api().GetExecutionStatus(ctx, req)
+	req.Reset()
+	statusRequests.Put(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get execution status: %w", err)
+	}
+	decodeRawOutput(resp)
+	return resp, nil
+}
+
+// WatchExecution subscribes to the status of an execution, with the order of
+// its output if order is set; the stream ends when ctx is cancelled
+func (c *elideDaemonClient) WatchExecution(ctx context.Context, sessionID string, executionID string, order bool) (StatusStream, error) {
+	stream, err := c.api().WatchExecution(ctx, &pb.WatchExecutionRequest{
+		SessionId:          sessionID,
+		ExecutionId:        executionID,
+		IncludeOutputOrder: order,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to watch execution: %w", err)
+	}
+	return rawOutputStream{stream}, nil
+}
+
+// rawOutputStream is a status stream decoding raw output like
+// GetExecutionStatus
+type rawOutputStream struct {
+	pb.ExecutionApi_WatchExecutionClient
+}
+
+func (s rawOutputStream) Recv() (*pb.GetExecutionStatusResponse, error) {
+	resp, err := s.ExecutionApi_WatchExecutionClient.Recv()
+	if err != nil {
+		return nil, err
+	}
+	decodeRawOutput(resp)
+	return resp, nil
+}
+
+// decodeRawOutput moves output the daemon sent as bytes, because it is not
+// valid UTF-8, into the status's stdout and stderr. The driver treats those
+// as bytes, so binary output is shipped unchanged up to the log streams.
+func decodeRawOutput(resp *pb.GetExecutionStatusResponse) {
+	if len(resp.StdoutRaw) > 0 {
+		resp.Stdout, resp.StdoutRaw = string(resp.StdoutRaw), nil
+	}
+	if len(resp.StderrRaw) > 0 {
+		resp.Stderr, resp.StderrRaw = string(resp.StderrRaw), nil
+	}
+}
+
+// WatchSessionEvents subscribes to the lifecycle events of a session, or of
+// all sessions if sessionID is empty; the stream ends when ctx is cancelled
+func (c *elideDaemonClient) WatchSessionEvents(ctx context.Context, sessionID string) (SessionEventStream, error) {
+	stream, err := c.api().WatchSessionEvents(ctx, &pb.WatchSessionEventsRequest{
+		SessionId: sessionID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to watch session events: %w", err)
+	}
+	return stream, nil
+}
+
+// CancelExecution cancels a running execution. It reports whether the
+// execution was cancelled: an execution that already completed, or that the
+// daemon does not know, has nothing left to cancel and is not an error.
+func (c *elideDaemonClient) CancelExecution(ctx context.Context, sessionID string, executionID string, reason pb.CancellationReason, initiator string, token string) (bool, error) {
+	resp, err := c.api().CancelExecution(ctx, &pb.CancelExecutionRequest{
 		SessionId:   sessionID,
 		ExecutionId: executionID,
+		Reason:      reason,
+		Initiator:   initiator,
+		CancelToken: token,
 	})
+	if status.Code(err) == codes.NotFound {
+		return false, nil
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to get execution status: %w", err)
+		return false, fmt.Errorf("failed to cancel execution: %w", err)
 	}
-	return resp, nil
+	return resp.Success, nil
 }
 
-// CancelExecution cancels a running execution
-func (c *elideDaemonClient) CancelExecution(ctx context.Context, sessionID string, executionID string) error {
-	_, err := c.executionClient.CancelExecution(ctx, &pb.CancelExecutionRequest{
+// KillExecution force-kills an execution that did not stop after being
+// cancelled. It reports whether the execution was killed: one that already
+// completed, or that the daemon does not know, is not an error.
+func (c *elideDaemonClient) KillExecution(ctx context.Context, sessionID string, executionID string, initiator string) (bool, error) {
+	resp, err := c.api().KillExecution(ctx, &pb.KillExecutionRequest{
 		SessionId:   sessionID,
 		ExecutionId: executionID,
+		Initiator:   initiator,
 	})
+	if status.Code(err) == codes.NotFound {
+		return false, nil
+	}
 	if err != nil {
-		return fmt.Errorf("failed to cancel execution: %w", err)
+		return false, fmt.Errorf("failed to kill execution: %w", err)
+	}
+	return resp.Success, nil
+}
+
+// SignalExecution delivers a POSIX signal, or a named application signal if
+// named is set, to a running execution
+func (c *elideDaemonClient) SignalExecution(ctx context.Context, sessionID string, executionID string, signal string, named bool) (bool, error) {
+	req := &pb.SignalExecutionRequest{
+		SessionId:   sessionID,
+		ExecutionId: executionID,
+	}
+	if named {
+		req.NamedSignal = signal
+	} else {
+		req.PosixSignal = signal
+	}
+	resp, err := c.api().SignalExecution(ctx, req)
+	if err != nil {
+		return false, fmt.Errorf("failed to signal execution: %w", err)
+	}
+	return resp.Delivered, nil
+}
+
+// ExecInSession runs an ad-hoc snippet in a session and waits for its output
+func (c *elideDaemonClient) ExecInSession(ctx context.Context, sessionID string, code string, language string, env map[string]string, timeout time.Duration, runAs string, mounts []*pb.Mount, tags map[string]string) (*pb.ExecInSessionResponse, error) {
+	resp, err := c.api().ExecInSession(ctx, &pb.ExecInSessionRequest{
+		SessionId: sessionID,
+		Code:      code,
+		Language:  language,
+		Env:       env,
+		TimeoutMs: uint64(timeout.Milliseconds()),
+		RunAs:     runAs,
+		Mounts:    mounts,
+		Tags:      tags,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to exec in session: %w", err)
+	}
+	if len(resp.StdoutRaw) > 0 {
+		resp.Stdout, resp.StdoutRaw = string(resp.StdoutRaw), nil
+	}
+	if len(resp.StderrRaw) > 0 {
+		resp.Stderr, resp.StderrRaw = string(resp.StderrRaw), nil
+	}
+	return resp, nil
+}
+
+// PutSessionValue stores a value in the session key-value store
+func (c *elideDaemonClient) PutSessionValue(ctx context.Context, sessionID string, key string, value string) error {
+	_, err := c.api().PutSessionValue(ctx, &pb.PutSessionValueRequest{
+		SessionId: sessionID,
+		Key:       key,
+		Value:     value,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to put session value: %w", err)
 	}
 	return nil
 }
 
+// GetSessionValue reads a value from the session key-value store
+func (c *elideDaemonClient) GetSessionValue(ctx context.Context, sessionID string, key string) (string, bool, error) {
+	resp, err := c.api().GetSessionValue(ctx, &pb.GetSessionValueRequest{
+		SessionId: sessionID,
+		Key:       key,
+	})
+	if err != nil {
+		return "", false, fmt.Errorf("failed to get session value: %w", err)
+	}
+	return resp.Value, resp.Found, nil
+}
+
 // Health checks if the daemon is healthy
-func (c *elideDaemonClient) Health(ctx context.Context) error {
-	_, err := c.executionClient.Health(ctx, &pb.HealthRequest{})
+func (c *elideDaemonClient) Health(ctx context.Context) (*pb.HealthResponse, error) {
+	resp, err := c.api().Health(ctx, &pb.HealthRequest{})
 	if err != nil {
-		return fmt.Errorf("health check failed: %w", err)
+		return nil, fmt.Errorf("health check failed: %w", err)
+	}
+	return resp, nil
+}
+
+// Reconnect resets the connection's backoff, so it re-dials the daemon now
+func (c *elideDaemonClient) Reconnect() {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.conn != nil {
+		c.conn.ResetConnectBackoff()
 	}
-	return nil
 }
 
 // Close closes the connection to the daemon
 func (c *elideDaemonClient) Close() error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 	if c.conn != nil {
 		return c.conn.Close()
 	}
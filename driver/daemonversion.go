@@ -0,0 +1,61 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/go-version"
+
+	pb "github.com/elide-dev/elide-task-driver/proto/gen/go/elide/daemon/v1alpha1"
+)
+
+// daemonVersionConstraint parses required_daemon_version, returning nil if
+// it is unset.
+func (c *Config) daemonVersionConstraint() (version.Constraints, error) {
+	if c.RequiredDaemonVersion == "" {
+		return nil, nil
+	}
+	constraints, err := version.NewConstraint(c.RequiredDaemonVersion)
+	if err != nil {
+		return nil, fmt.Errorf("invalid required_daemon_version %q: %w", c.RequiredDaemonVersion, err)
+	}
+	return constraints, nil
+}
+
+// CheckDaemonVersion checks the version a daemon reported in its health check
+// against a required_daemon_version constraint, such as ">= 1.2, < 2.0". Any
+// version passes an empty constraint; a daemon that reports no version, or
+// one that is not a version number, fails any other.
+func CheckDaemonVersion(constraint string, reported string) error {
+	constraints, err := (&Config{RequiredDaemonVersion: constraint}).daemonVersionConstraint()
+	if err != nil || constraints == nil {
+		return err
+	}
+	if reported == "" {
+		return fmt.Errorf("daemon reported no version, required_daemon_version is %q", constraint)
+	}
+	v, err := version.NewVersion(reported)
+	if err != nil {
+		return fmt.Errorf("daemon version %q is not a version number, required_daemon_version is %q", reported, constraint)
+	}
+	if !constraints.Check(v) {
+		return fmt.Errorf("daemon version %s does not satisfy required_daemon_version %q", reported, constraint)
+	}
+	return nil
+}
+
+// checkDaemonVersion checks the daemon's version against
+// required_daemon_version, logging when the outcome changes.
+func (d *ElideDriverPlugin) checkDaemonVersion(health *pb.HealthResponse) error {
+	err := CheckDaemonVersion(d.config.RequiredDaemonVersion, health.GetVersion())
+	if rejected := err != nil; d.daemonVersionRejected.Swap(rejected) != rejected {
+		if rejected {
+			d.logger.Error("refusing to use incompatible daemon", "error", err)
+		} else {
+			d.logger.Info("daemon version is compatible again", "version", health.GetVersion())
+		}
+	}
+	return err
+}
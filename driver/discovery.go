@@ -0,0 +1,343 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/resolver"
+)
+
+// Ways of looking up the daemon service in Consul
+const (
+	// consulLookupHTTP queries the health endpoint of the Consul HTTP API
+	consulLookupHTTP = "http"
+
+	// consulLookupDNS queries SRV records of the Consul DNS interface
+	consulLookupDNS = "dns"
+)
+
+const (
+	// discoveryScheme is the gRPC target scheme of daemons discovered through
+	// Consul
+	discoveryScheme = "elide-consul"
+
+	// discoveryLookupTimeout bounds a single lookup of the daemon service
+	discoveryLookupTimeout = 5 * time.Second
+
+	// discoveryRefreshInterval is how often the daemon service is looked up
+	// again while its connection is healthy
+	discoveryRefreshInterval = 30 * time.Second
+
+	// discoveryMinInterval rate-limits lookups triggered by connection
+	// failures
+	discoveryMinInterval = time.Second
+
+	// consulTokenEnv is the environment variable holding the Consul ACL token
+	// if the daemon_consul block sets none
+	consulTokenEnv = "CONSUL_HTTP_TOKEN"
+)
+
+// DaemonConsulConfig is the daemon_consul block of the plugin config: how the
+// daemon_consul_service is looked up.
+type DaemonConsulConfig struct {
+	// Address of the Consul HTTP API, e.g. http://127.0.0.1:8500
+	Address string `codec:"address"`
+	// Datacenter to query (empty = the agent's)
+	Datacenter string `codec:"datacenter"`
+	// Tag the service instances must have (optional)
+	Tag string `codec:"tag"`
+	// Token is the ACL token for the HTTP API (empty = $CONSUL_HTTP_TOKEN)
+	Token string `codec:"token"`
+	// Lookup is "http" (health API) or "dns" (SRV records)
+	Lookup string `codec:"lookup"`
+	// DNSServer is the Consul DNS server for "dns" lookups, e.g.
+	// 127.0.0.1:8600 (empty = system resolver)
+	DNSServer string `codec:"dns_server"`
+	// Domain is the Consul DNS domain
+	Domain string `codec:"domain"`
+}
+
+// Validate checks the daemon_consul block.
+func (c *DaemonConsulConfig) Validate() error {
+	switch c.Lookup {
+	case "", consulLookupHTTP, consulLookupDNS:
+	default:
+		return fmt.Errorf("invalid lookup %q (must be %q or %q)", c.Lookup, consulLookupHTTP, consulLookupDNS)
+	}
+	if c.DNSServer != "" {
+		if _, _, err := net.SplitHostPort(c.DNSServer); err != nil {
+			return fmt.Errorf("invalid dns_server %q: %w", c.DNSServer, err)
+		}
+	}
+	return nil
+}
+
+// Resolve returns the addresses (host:port) of the healthy instances of the
+// service, nearest to the local Consul agent first.
+func (c *DaemonConsulConfig) Resolve(ctx context.Context, service string) ([]string, error) {
+	var addrs []string
+	var err error
+	if c.Lookup == consulLookupDNS {
+		addrs, err = c.resolveDNS(ctx, service)
+	} else {
+		addrs, err = c.resolveHTTP(ctx, service)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up service %q in Consul: %w", service, err)
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("no healthy instances of service %q in Consul", service)
+	}
+	return addrs, nil
+}
+
+// consulServiceEntry is the part of a Consul health API entry the driver
+// uses.
+type consulServiceEntry struct {
+	Node struct {
+		Address string
+	}
+	Service struct {
+		Address string
+		Port    int
+	}
+}
+
+// resolveHTTP looks up the service with the Consul health API.
+func (c *DaemonConsulConfig) resolveHTTP(ctx context.Context, service string) ([]string, error) {
+	address := c.Address
+	if address == "" {
+		address = "http://127.0.0.1:8500"
+	} else if !strings.Contains(address, "://") {
+		address = "http://" + address
+	}
+
+	query := url.Values{"passing": {"true"}, "near": {"_agent"}}
+	if c.Datacenter != "" {
+		query.Set("dc", c.Datacenter)
+	}
+	if c.Tag != "" {
+		query.Set("tag", c.Tag)
+	}
+	endpoint := strings.TrimSuffix(address, "/") + "/v1/health/service/" + url.PathEscape(service) + "?" + query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	token := c.Token
+	if token == "" {
+		token = os.Getenv(consulTokenEnv)
+	}
+	if token != "" {
+		req.Header.Set("X-Consul-Token", token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("consul returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var entries []consulServiceEntry
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		return nil, fmt.Errorf("invalid consul response: %w", err)
+	}
+	addrs := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		host := entry.Service.Address
+		if host == "" {
+			host = entry.Node.Address
+		}
+		if host == "" || entry.Service.Port == 0 {
+			continue
+		}
+		addrs = append(addrs, net.JoinHostPort(host, strconv.Itoa(entry.Service.Port)))
+	}
+	return addrs, nil
+}
+
+// resolveDNS looks up the service with SRV records of the Consul DNS
+// interface, e.g. [tag.]elide-daemon.service[.dc].consul.
+func (c *DaemonConsulConfig) resolveDNS(ctx context.Context, service string) ([]string, error) {
+	r := net.DefaultResolver
+	if c.DNSServer != "" {
+		r = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network string, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, network, c.DNSServer)
+			},
+		}
+	}
+
+	name := service + ".service."
+	if c.Tag != "" {
+		name = c.Tag + "." + name
+	}
+	if c.Datacenter != "" {
+		name += c.Datacenter + "."
+	}
+	domain := c.Domain
+	if domain == "" {
+		domain = "consul"
+	}
+	name += strings.Trim(domain, ".")
+
+	_, records, err := r.LookupSRV(ctx, "", "", name)
+	if err != nil {
+		return nil, err
+	}
+	addrs := make([]string, 0, len(records))
+	for _, record := range records {
+		hosts, err := r.LookupHost(ctx, record.Target)
+		if err != nil || len(hosts) == 0 {
+			continue
+		}
+		addrs = append(addrs, net.JoinHostPort(hosts[0], strconv.Itoa(int(record.Port))))
+	}
+	return addrs, nil
+}
+
+// newDaemonClient connects to the daemon at the configured endpoint: the
+// Consul service if daemon_consul_service is set, else the address if
+// daemon_address is set, else the socket.
+func (d *ElideDriverPlugin) newDaemonClient() (DaemonClient, error) {
+	retryPolicies, _ := d.config.RPCRetryPolicies()
+	opts := []grpc.DialOption{d.rpcLogger().dialOption(), newRPCRetrier(retryPolicies, d.metrics, d.logger).dialOption(), d.reconnect.dialOption(), d.forensics.dialOption()}
+	opts = append(opts, d.tracing.dialOptions()...)
+	if d.config.DaemonTLS.Enabled() {
+		tlsOpt, sum, err := d.tlsDialOption()
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, tlsOpt)
+		d.tlsLoaded = sum
+	}
+	service := d.config.DaemonConsulService
+	if service == "" {
+		// daemon_socket has a default, so it only applies without an address
+		socket := d.config.DaemonSocket
+		if d.config.DaemonAddress != "" {
+			socket = ""
+		}
+		return NewDaemonClient(socket, d.config.DaemonAddress, opts...)
+	}
+
+	consul := d.config.DaemonConsul
+	builder := &discoveryResolverBuilder{
+		resolve: func(ctx context.Context) ([]string, error) {
+			return consul.Resolve(ctx, service)
+		},
+		logger: d.logger.With("daemon_service", service),
+	}
+	opts = append(opts, grpc.WithResolvers(builder))
+	return NewDaemonClient("", discoveryScheme+":///"+service, opts...)
+}
+
+// discoveryResolverBuilder builds gRPC resolvers that look the daemon up in
+// Consul. gRPC asks the resolver to resolve again whenever the connection
+// fails, so a daemon rescheduled to another address is found again.
+type discoveryResolverBuilder struct {
+	resolve func(ctx context.Context) ([]string, error)
+	logger  hclog.Logger
+}
+
+func (b *discoveryResolverBuilder) Scheme() string {
+	return discoveryScheme
+}
+
+func (b *discoveryResolverBuilder) Build(target resolver.Target, cc resolver.ClientConn, opts resolver.BuildOptions) (resolver.Resolver, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &discoveryResolver{
+		resolve:    b.resolve,
+		cc:         cc,
+		ctx:        ctx,
+		cancel:     cancel,
+		resolveNow: make(chan struct{}, 1),
+		logger:     b.logger,
+	}
+	go r.watch()
+	return r, nil
+}
+
+// discoveryResolver looks the daemon up when built, when gRPC reports a
+// connection failure, and every discoveryRefreshInterval.
+type discoveryResolver struct {
+	resolve    func(ctx context.Context) ([]string, error)
+	cc         resolver.ClientConn
+	ctx        context.Context
+	cancel     context.CancelFunc
+	resolveNow chan struct{}
+	logger     hclog.Logger
+}
+
+func (r *discoveryResolver) ResolveNow(resolver.ResolveNowOptions) {
+	select {
+	case r.resolveNow <- struct{}{}:
+	default:
+	}
+}
+
+func (r *discoveryResolver) Close() {
+	r.cancel()
+}
+
+func (r *discoveryResolver) watch() {
+	var last []string
+	for {
+		ctx, cancel := context.WithTimeout(r.ctx, discoveryLookupTimeout)
+		addrs, err := r.resolve(ctx)
+		cancel()
+		switch {
+		case r.ctx.Err() != nil:
+			return
+		case err != nil:
+			r.logger.Warn("failed to resolve daemon", "error", err)
+			r.cc.ReportError(err)
+		default:
+			if !slices.Equal(addrs, last) {
+				r.logger.Info("resolved daemon", "addresses", addrs)
+				last = addrs
+			}
+			state := resolver.State{Addresses: make([]resolver.Address, len(addrs))}
+			for i, addr := range addrs {
+				state.Addresses[i] = resolver.Address{Addr: addr}
+			}
+			if err := r.cc.UpdateState(state); err != nil {
+				r.logger.Debug("daemon addresses rejected", "error", err)
+			}
+		}
+
+		select {
+		case <-r.ctx.Done():
+			return
+		case <-time.After(discoveryMinInterval):
+		}
+		select {
+		case <-r.ctx.Done():
+			return
+		case <-r.resolveNow:
+		case <-time.After(discoveryRefreshInterval):
+		}
+	}
+}
@@ -0,0 +1,61 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/nomad/plugins/drivers"
+)
+
+// metricCodeChanged counts task restarts whose code differs from the task's
+// first run in its alloc
+const metricCodeChanged = "code_changed_total"
+
+// hashCode returns the hex SHA-256 of a task's code.
+func hashCode(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+// checkCodeDrift compares the code of a restarted task with the code of its
+// first run in the alloc. A script file or OCI tag can change between
+// restarts; the change is made visible with a task event, or refused if the
+// task sets immutable_code.
+func (d *ElideDriverPlugin) checkCodeDrift(cfg *drivers.TaskConfig, codeHash string, immutable bool) error {
+	original := d.allocs.originalCodeHash(cfg)
+	if original == "" || original == codeHash {
+		return nil
+	}
+
+	d.metrics.IncrCounter(metricCodeChanged, "Task restarts whose code changed since the first run.")
+	if immutable {
+		return fmt.Errorf("code changed since the task's first run (sha256 %s, now %s) and immutable_code is set", shortHash(original), shortHash(codeHash))
+	}
+
+	d.logger.Warn("code changed since the task's first run", "task_id", cfg.ID, "original_hash", original, "code_hash", codeHash)
+	d.eventer.EmitEvent(&drivers.TaskEvent{
+		TaskID:    cfg.ID,
+		AllocID:   cfg.AllocID,
+		TaskName:  cfg.Name,
+		Timestamp: time.Now(),
+		Message:   fmt.Sprintf("Code changed since the task's first run (sha256 %s, now %s)", shortHash(original), shortHash(codeHash)),
+		Annotations: map[string]string{
+			"original_code_hash": original,
+			"code_hash":          codeHash,
+		},
+	})
+	return nil
+}
+
+// shortHash abbreviates a code hash for messages.
+func shortHash(hash string) string {
+	if len(hash) > 12 {
+		return hash[:12]
+	}
+	return hash
+}
@@ -5,20 +5,25 @@ package driver
 
 import (
 	"context"
+	"crypto/sha256"
 	"errors"
 	"fmt"
+	"maps"
 	"os"
 	"path/filepath"
-	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/hashicorp/go-hclog"
 	"github.com/hashicorp/nomad/drivers/shared/eventer"
+	nstructs "github.com/hashicorp/nomad/nomad/structs"
 	"github.com/hashicorp/nomad/plugins/base"
 	"github.com/hashicorp/nomad/plugins/drivers"
 	"github.com/hashicorp/nomad/plugins/shared/hclspec"
 	"github.com/hashicorp/nomad/plugins/shared/structs"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 
 	pb "github.com/elide-dev/elide-task-driver/proto/gen/go/elide/daemon/v1alpha1"
 )
@@ -36,14 +41,32 @@ const (
 	fingerprintPeriod = 30 * time.Second
 
 	// taskHandleVersion is the version of task handle which this plugin sets
-	// and understands how to decode
-	taskHandleVersion = 1
+	// and understands how to decode. Version 2 handles wrap the task state in
+	// a versioned envelope (see EncodeTaskState); version 1 handles are still
+	// recovered
+	taskHandleVersion = 2
 
-	// executeSnippetTimeout is the default timeout for ExecuteSnippet RPCs.
-	executeSnippetTimeout = 10 * time.Second
+	// defaultSubmitTimeout is the default timeout for ExecuteSnippet RPCs.
+	defaultSubmitTimeout = 10 * time.Second
 
-	// statusRequestTimeout is the default timeout for status polling RPCs.
-	statusRequestTimeout = 5 * time.Second
+	// defaultMaxCodeBytes is the code payload limit assumed when the daemon
+	// does not report one: the default gRPC message limit less room for
+	// env vars and args.
+	defaultMaxCodeBytes = 4<<20 - 256<<10
+
+	// defaultStatusTimeout is the default timeout for status polling RPCs.
+	defaultStatusTimeout = 5 * time.Second
+
+	// defaultCancelTimeout is the default timeout for CancelExecution RPCs
+	// initiated by the driver.
+	defaultCancelTimeout = 5 * time.Second
+
+	// initiatorDriver and initiatorNomad identify who cancelled an execution.
+	initiatorDriver = "driver"
+	initiatorNomad  = "nomad"
+
+	// defaultPollInterval is the default interval between status polls.
+	defaultPollInterval = 1 * time.Second
 )
 
 var (
@@ -54,16 +77,6 @@ var (
 		PluginVersion:     pluginVersion,
 		Name:              pluginName,
 	}
-
-	// capabilities indicates what optional features this driver supports
-	capabilities = &drivers.Capabilities{
-		SendSignals: false,
-		Exec:        false, // Not implementing exec for MVP
-		FSIsolation: drivers.FSIsolationNone,
-		NetIsolationModes: []drivers.NetIsolationMode{
-			drivers.NetIsolationModeHost,
-		},
-	}
 )
 
 // ElideDriverPlugin is the Nomad task driver plugin for Elide runtime
@@ -89,12 +102,150 @@ type ElideDriverPlugin struct {
 	// sessionLock serializes session initialization.
 	sessionLock sync.Mutex
 
+	// maxCodeBytes is the largest code payload agreed with the daemon
+	maxCodeBytes atomic.Int64
+
+	// supportsRunAs is whether the daemon can run executions as another user
+	supportsRunAs atomic.Bool
+
+	// supportsSignals and supportsMounts record whether the daemon can
+	// signal executions and mount the task's directories into them
+	supportsSignals atomic.Bool
+	supportsMounts  atomic.Bool
+
+	// supportsOutputOffsets is whether status polls can leave output out or
+	// return only the output past given offsets
+	supportsOutputOffsets atomic.Bool
+
+	// supportsWorkspaces is whether the daemon accepts workspace uploads
+	// for entrypoint tasks
+	supportsWorkspaces atomic.Bool
+
+	// supportsStdin is whether executions can be streamed a stdin
+	supportsStdin atomic.Bool
+
+	// supportsPortBindings is whether the daemon routes allocated ports to
+	// executions
+	supportsPortBindings atomic.Bool
+
+	// supportsOutputOrder is whether statuses can report the order stdout
+	// and stderr were written in, for tasks combining their output
+	supportsOutputOrder atomic.Bool
+
+	// daemonVersionRejected is whether the daemon's version last failed
+	// required_daemon_version, to log only when that changes
+	daemonVersionRejected atomic.Bool
+
+	// health debounces the daemon's health checks, and
+	// lastHealthyFingerprint is the fingerprint of its last passed check,
+	// reported while it fails fewer checks than the threshold
+	health                 *HealthEvaluator
+	lastHealthyFingerprint atomic.Pointer[drivers.Fingerprint]
+
+	// sessionCreatedAt is when the current session was created or adopted
+	sessionCreatedAt time.Time
+
+	// profileSessions maps session profile names to their session IDs
+	profileSessions map[string]string
+
+	// retiredSessions are sessions replaced after a session manifest change
+	// or rotation, deleted once no running task uses them
+	retiredSessions []string
+
+	// sessionGeneration counts session rotations, so rotated sessions get
+	// new IDs
+	sessionGeneration int
+
+	// restarts counts recent task failures for the restart guard
+	restarts *restartGuard
+
+	// forensics keeps the recent history captured in forensic bundles
+	forensics *forensicsRecorder
+
+	// logThrottle deduplicates warnings about repeated daemon errors
+	logThrottle *LogThrottle
+
+	// workers tracks the liveness of the background goroutines
+	workers *WorkerMonitor
+
+	// state records sessions and executions in state_dir (nil without it);
+	// reconcileOnce reconciles the executions a previous instance left once
+	state         *StateStore
+	reconcileOnce sync.Once
+
+	// tracing exports spans of tasks and daemon RPCs (no-op without
+	// otel_endpoint)
+	tracing *tracer
+
+	// sessionEventsOnce starts following session events once
+	sessionEventsOnce sync.Once
+
+	// supervisor starts and restarts the daemon if daemon_supervisor is
+	// enabled (nil otherwise)
+	supervisor *daemonSupervisor
+
+	// endpoints are clients of the daemon_endpoint daemons, by name
+	endpoints map[string]DaemonClient
+
+	// tlsLoaded is the checksum of the daemon_tls files the daemon client
+	// was last dialled with; tlsWatchOnce starts watching them for rotation
+	tlsLoaded    [sha256.Size]byte
+	tlsWatchOnce sync.Once
+
+	// scoped tracks the sessions created per alloc or per task
+	// (session_per); guarded by sessionLock
+	scoped *scopedSessions
+
+	// manifest is the session manifest, if session_manifest is configured
+	manifest atomic.Pointer[SessionManifest]
+
+	// nodeID is the ID of the Nomad node, learned from the tasks it runs
+	nodeID atomic.Value
+
+	// allocs aggregates execution counts per alloc
+	allocs *allocTracker
+
+	// metrics holds the driver's own metrics
+	metrics *metricsRegistry
+
+	// metricsSrv serves metrics if metrics_address is configured
+	metricsSrv *metricsServer
+
+	// admission limits concurrent executions submitted to the daemon
+	admission *executionSlots
+
+	// admin serves the admin API if admin_socket is configured
+	admin *adminServer
+
+	// archiver uploads execution output if output_archive is configured
+	archiver *outputArchiver
+
+	// credentials mints task credentials if credential_exchange is configured
+	credentials *CredentialMinter
+
 	// ctx is the context for the driver
 	ctx context.Context
 
 	// signalShutdown is called when the driver is shutting down
 	signalShutdown context.CancelFunc
 
+	// lifecycle stops new tasks from starting once shutdown begins
+	lifecycle lifecycle
+
+	// watchUnsupported is set once the daemon rejected WatchExecution, so
+	// later tasks poll right away
+	watchUnsupported atomic.Bool
+
+	// execInSessionUnsupported is set once the daemon rejected ExecInSession,
+	// so exec commands run as executions right away
+	execInSessionUnsupported atomic.Bool
+
+	// reconnect pauses pollers while the daemon is unreachable
+	reconnect *reconnectManager
+
+	// faults injects failures for chaos testing (nil unless enabled)
+	faults *faultInjector
+
 	// logger will log to the Nomad agent
 	logger hclog.Logger
 }
@@ -102,16 +253,39 @@ type ElideDriverPlugin struct {
 // NewPlugin returns a new Elide driver plugin
 func NewPlugin(logger hclog.Logger) drivers.DriverPlugin {
 	ctx, cancel := context.WithCancel(context.Background())
-	logger = logger.Named(pluginName)
 
-	return &ElideDriverPlugin{
-		eventer:        eventer.NewEventer(ctx, logger),
-		config:         &Config{},
-		tasks:          newTaskStore(),
-		ctx:            ctx,
-		signalShutdown: cancel,
-		logger:         logger,
+	// Repeated daemon errors are logged once per window across all tasks
+	logger = logger.Named(pluginName)
+	logThrottle := NewLogThrottle(logger, defaultLogDedupWindow)
+	logger = logThrottle.Wrap(logger)
+
+	d := &ElideDriverPlugin{
+		eventer:         eventer.NewEventer(ctx, logger),
+		config:          &Config{},
+		tasks:           newTaskStore(),
+		profileSessions: map[string]string{},
+		allocs:          newAllocTracker(),
+		restarts:        newRestartGuard(),
+		forensics:       newForensicsRecorder(defaultForensicsHistory),
+		scoped:          newScopedSessions(),
+		admission:       newExecutionSlots(0, 0),
+		health:          NewHealthEvaluator(defaultHealthFailureThreshold),
+		metrics:         newMetricsRegistry(logger),
+		ctx:             ctx,
+		signalShutdown:  cancel,
+		faults:          loadFaultInjector(logger),
+		logThrottle:     logThrottle,
+		workers:         NewWorkerMonitor(logger),
+		logger:          logger,
 	}
+	d.tracing, _ = newTracer("", logger)
+	logThrottle.onSuppressed = func(class string) {
+		d.metrics.IncrCounter(metricSuppressedLogs, "Warnings and errors suppressed as repeats of a daemon error, by gRPC code.", "code", class)
+	}
+	go d.workers.Run(workerLogThrottle, logThrottleFlushInterval, func(w *WorkerHeartbeat) { logThrottle.run(ctx, w) })
+	d.maxCodeBytes.Store(defaultMaxCodeBytes)
+	d.reconnect = newReconnectManager(ctx, d.probeDaemon, reconnectHooks{down: d.daemonDown, up: d.daemonReconnected}, d.metrics, logger)
+	return d
 }
 
 // PluginInfo returns information describing the plugin.
@@ -133,24 +307,97 @@ func (d *ElideDriverPlugin) SetConfig(cfg *base.Config) error {
 		}
 	}
 
+	if err := config.Validate(); err != nil {
+		return fmt.Errorf("invalid plugin config: %w", err)
+	}
+
 	// Save the configuration to the plugin
 	d.config = &config
+	d.admission = newExecutionSlots(config.MaxConcurrentExecutions, config.MaxConcurrentExecutionsPerSession)
+	d.health = NewHealthEvaluator(config.healthFailureThreshold())
+	dedupWindow, _ := ParseDuration("daemon_log_dedup_window", config.DaemonLogDedupWindow)
+	d.logThrottle.SetWindow(dedupWindow)
+	d.metrics.SetMaxSeries(config.metricsMaxSeries())
+	d.forensics = newForensicsRecorder(config.Forensics.history())
+
+	// Set up tracing before the daemon client its interceptors are dialed with
+	tracing, err := newTracer(config.OtelEndpoint, d.logger)
+	if err != nil {
+		return err
+	}
+	if err := d.tracing.close(); err != nil {
+		d.logger.Warn("failed to flush traces", "error", err)
+	}
+	d.tracing = tracing
 
 	// Save the Nomad agent configuration
 	if cfg.AgentConfig != nil {
 		d.nomadConfig = cfg.AgentConfig.Driver
 	}
 
+	// Set up output archival if configured
+	if d.config.OutputArchive.Enabled() {
+		archiver, err := newOutputArchiver(d.config.OutputArchive, d.logger)
+		if err != nil {
+			return err
+		}
+		d.archiver = archiver
+	}
+
+	// Set up credential minting if configured
+	if d.config.CredentialExchange.Enabled() {
+		d.credentials = NewCredentialMinter(d.config.CredentialExchange, d.logger)
+	}
+
+	// Record sessions and executions for crash recovery if state_dir is set
+	d.openStateStore()
+
+	// Load the session manifest before the session is created from it
+	if d.config.SessionManifest != "" && d.manifest.Load() == nil {
+		data, err := os.ReadFile(d.config.SessionManifest)
+		if err != nil {
+			return fmt.Errorf("failed to read session manifest: %w", err)
+		}
+		manifest, err := ParseSessionManifest(data, isJSONManifest(d.config.SessionManifest))
+		if err != nil {
+			return err
+		}
+		d.manifest.Store(manifest)
+		path := d.config.SessionManifest
+		go d.workers.Run(workerManifestWatcher, manifestPollInterval, func(w *WorkerHeartbeat) { d.watchManifest(w, path, data) })
+	}
+
+	// Start the daemon unless one already serves the socket
+	if d.config.DaemonSupervisor.Enabled && d.supervisor == nil {
+		d.supervisor = newDaemonSupervisor(d.config, d.metrics, d.logger)
+		d.supervisor.start(d.ctx)
+	}
+
 	// Initialize gRPC client to Elide daemon
-	client, err := NewDaemonClient(d.config.DaemonSocket, d.config.DaemonAddress)
+	client, err := d.newDaemonClient()
 	if err != nil {
 		return fmt.Errorf("failed to connect to Elide daemon: %w", err)
 	}
 	d.daemonClient = client
+	if d.config.DaemonTLS.Enabled() {
+		d.tlsWatchOnce.Do(func() { go d.watchTLS() })
+	}
 
-	// Check daemon health
-	if err := d.daemonClient.Health(context.Background()); err != nil {
+	// Connect to the additional daemons whose health is published
+	if d.endpoints == nil {
+		if err := d.connectEndpoints(); err != nil {
+			return err
+		}
+	}
+
+	// Check daemon health and negotiate the code payload limit
+	if health, err := d.daemonClient.Health(context.Background()); err != nil {
 		d.logger.Warn("daemon health check failed", "error", err)
+	} else {
+		d.negotiateCodeLimit(health)
+		d.negotiateRunAs(health)
+		d.negotiateFeatures(health)
+		d.metrics.SetGauge(metricDaemonUp, "Whether the daemon is reachable.", 1)
 	}
 
 	// Ensure session exists (one session per Nomad client)
@@ -160,6 +407,34 @@ func (d *ElideDriverPlugin) SetConfig(cfg *base.Config) error {
 		d.logger.Warn("failed to initialize session (daemon may not be running yet)", "error", err)
 	}
 
+	// Cancel or adopt the executions a crashed instance left behind
+	if d.state != nil {
+		d.reconcileOnce.Do(func() { go d.workers.Run(workerOrphanReconciler, 0, func(*WorkerHeartbeat) { d.reconcileOrphans() }) })
+	}
+
+	// Follow the daemon's session events if requested
+	if d.config.SessionEvents {
+		d.sessionEventsOnce.Do(func() { go d.workers.Run(workerSessionEvents, 0, func(*WorkerHeartbeat) { d.followSessionEvents() }) })
+	}
+
+	// Start the metrics endpoint if requested
+	if d.config.MetricsAddress != "" && d.metricsSrv == nil {
+		srv, err := d.startMetricsServer(d.config.MetricsAddress)
+		if err != nil {
+			return fmt.Errorf("failed to start metrics endpoint: %w", err)
+		}
+		d.metricsSrv = srv
+	}
+
+	// Start the admin API if requested
+	if d.config.AdminSocket != "" && d.admin == nil {
+		admin, err := d.startAdminServer(d.config.AdminSocket)
+		if err != nil {
+			return fmt.Errorf("failed to start admin API: %w", err)
+		}
+		d.admin = admin
+	}
+
 	return nil
 }
 
@@ -168,11 +443,6 @@ func (d *ElideDriverPlugin) TaskConfigSchema() (*hclspec.Spec, error) {
 	return taskConfigSpec, nil
 }
 
-// Capabilities returns the features supported by the driver.
-func (d *ElideDriverPlugin) Capabilities() (*drivers.Capabilities, error) {
-	return capabilities, nil
-}
-
 // Fingerprint returns a channel that will be used to send health information
 // and other driver specific node attributes.
 func (d *ElideDriverPlugin) Fingerprint(ctx context.Context) (<-chan *drivers.Fingerprint, error) {
@@ -185,6 +455,10 @@ func (d *ElideDriverPlugin) Fingerprint(ctx context.Context) (<-chan *drivers.Fi
 func (d *ElideDriverPlugin) handleFingerprint(ctx context.Context, ch chan<- *drivers.Fingerprint) {
 	defer close(ch)
 
+	// Fingerprinting also keeps the session alive
+	w := d.workers.Start(workerSessionKeepalive, fingerprintPeriod)
+	defer w.Done()
+
 	// Nomad expects the initial fingerprint to be sent immediately
 	ticker := time.NewTimer(0)
 	for {
@@ -195,7 +469,10 @@ func (d *ElideDriverPlugin) handleFingerprint(ctx context.Context, ch chan<- *dr
 			return
 		case <-ticker.C:
 			ticker.Reset(fingerprintPeriod)
-			ch <- d.buildFingerprint()
+			fp := d.buildFingerprint()
+			w.Beat()
+			d.forensics.recordFingerprint(fp)
+			ch <- fp
 		}
 	}
 }
@@ -208,33 +485,66 @@ func (d *ElideDriverPlugin) buildFingerprint() *drivers.Fingerprint {
 		HealthDescription: drivers.DriverHealthy,
 	}
 
-	// Check if Elide daemon is available/running
-	socketPath := d.config.DaemonSocket
-	if socketPath == "" {
-		socketPath = "/tmp/elide-daemon.sock"
+	// Report the supervision state even while the daemon is down
+	if d.supervisor != nil {
+		fp.Attributes["driver.elide.daemon_supervised"] = structs.NewBoolAttribute(true)
+		d.supervisor.fingerprintAttributes(fp)
 	}
 
-	// Check if socket exists
-	if _, err := os.Stat(socketPath); err != nil {
-		fp.Health = drivers.HealthStateUndetected
-		fp.HealthDescription = fmt.Sprintf("daemon socket not found: %s", socketPath)
-		return fp
+	// Check if Elide daemon is available/running
+	socketPath := d.config.socketPath()
+
+	// Check if socket exists; a daemon found through Consul has none
+	if _, err := os.Stat(socketPath); err != nil && d.config.DaemonConsulService == "" {
+		return d.unreachableFingerprint(fp, drivers.HealthStateUndetected, fmt.Errorf("daemon socket not found: %s", socketPath))
 	}
 
 	// If daemon client is available, check health
 	if d.daemonClient != nil {
-		if err := d.daemonClient.Health(context.Background()); err != nil {
-			fp.Health = drivers.HealthStateUnhealthy
-			fp.HealthDescription = fmt.Sprintf("daemon health check failed: %v", err)
+		health, err := d.daemonClient.Health(context.Background())
+		if err != nil {
+			return d.unreachableFingerprint(fp, drivers.HealthStateUnhealthy, fmt.Errorf("daemon health check failed: %w", err))
+		}
+		// An incompatible daemon is not used at all, as if it were absent
+		if err := d.checkDaemonVersion(health); err != nil {
+			fp.Health = drivers.HealthStateUndetected
+			fp.HealthDescription = err.Error()
+			healthAttributes(fp, d.health.Incompatible(err))
+			d.lastHealthyFingerprint.Store(nil)
 			return fp
 		}
+		d.negotiateCodeLimit(health)
+		d.negotiateRunAs(health)
+		d.negotiateFeatures(health)
+		DaemonAttributes(fp, health)
+		d.endpointAttributes(fp, health)
 	}
 
+	// Keep the session alive and detect sessions lost on the daemon side
+	d.checkSession()
+
 	// Report driver as available
 	fp.Attributes["driver.elide.available"] = structs.NewBoolAttribute(true)
 	if d.sessionID != "" {
 		fp.Attributes["driver.elide.session_id"] = structs.NewStringAttribute(d.sessionID)
 	}
+	if m := d.manifest.Load(); m != nil {
+		fp.Attributes["driver.elide.session_manifest_revision"] = structs.NewStringAttribute(m.revision)
+	}
+	fp.Attributes["driver.elide.max_code_kb"] = structs.NewIntAttribute(d.maxCodeBytes.Load()>>10, "")
+	fp.Attributes["driver.elide.run_as"] = structs.NewBoolAttribute(d.supportsRunAs.Load())
+	fp.Attributes["driver.elide.signals"] = structs.NewBoolAttribute(d.supportsSignals.Load())
+	fp.Attributes["driver.elide.mounts"] = structs.NewBoolAttribute(d.supportsMounts.Load())
+	fp.Attributes["driver.elide.workspaces"] = structs.NewBoolAttribute(d.supportsWorkspaces.Load())
+	fp.Attributes["driver.elide.stdin"] = structs.NewBoolAttribute(d.supportsStdin.Load())
+	fp.Attributes["driver.elide.port_bindings"] = structs.NewBoolAttribute(d.supportsPortBindings.Load())
+	fp.Attributes["driver.elide.session_per"] = structs.NewStringAttribute(d.sessionPer())
+	d.sessionProfileAttributes(fp)
+	d.sessionMemoryAttributes(fp)
+	numaAttributes(fp, hostNUMANodes())
+	workerAttributes(fp, d.workers.Health(time.Now()))
+	healthAttributes(fp, d.health.Passed())
+	d.lastHealthyFingerprint.Store(fp)
 
 	return fp
 }
@@ -242,99 +552,472 @@ func (d *ElideDriverPlugin) buildFingerprint() *drivers.Fingerprint {
 // StartTask returns a task handle and a driver network if necessary.
 // This will be simplified to a gRPC call once daemon API is available.
 func (d *ElideDriverPlugin) StartTask(cfg *drivers.TaskConfig) (*drivers.TaskHandle, *drivers.DriverNetwork, error) {
+	if !d.lifecycle.enter(&d.lifecycle.starts) {
+		return nil, nil, nstructs.NewRecoverableError(errShuttingDown, true)
+	}
+	defer d.lifecycle.starts.Done()
+
 	if _, ok := d.tasks.Get(cfg.ID); ok {
 		return nil, nil, fmt.Errorf("task with ID %q already started", cfg.ID)
 	}
 
+	// With daemon_loss_policy = "wait", a task the daemon cannot be reached
+	// for is queued until it returns
+	h, driverState, err := d.startTask(cfg, "")
+	var lost *daemonLostError
+	if errors.As(err, &lost) {
+		h, driverState, err = d.queueTask(cfg, lost)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Store handle and return
+	handle := drivers.NewTaskHandle(taskHandleVersion)
+	handle.Config = cfg
+	handle.DriverState, err = EncodeTaskState(driverState)
+	if err != nil {
+		if h.releaseSlot != nil {
+			h.releaseSlot()
+		}
+		if err := h.removeScratchDir(); err != nil {
+			d.logger.Warn("failed to remove scratch dir", "task_id", cfg.ID, "error", err)
+		}
+		d.releaseScopedSession(cfg.ID)
+		return nil, nil, fmt.Errorf("failed to set driver state: %w", err)
+	}
+	d.tasks.Set(cfg.ID, h)
+	if h.queue != nil {
+		go d.submitQueued(h)
+	}
+	return handle, h.network, nil
+}
+
+// startTask validates a task and submits its execution, returning its handle
+// and the state to recover it from. queuedHash is the code hash recorded when
+// the task was queued, which the code must still match, or empty for tasks
+// not submitted from the queue. A task that could not be submitted because
+// the daemon is unreachable fails with a daemonLostError if it may be queued.
+// Each attempt is traced as a StartTask span, the root of the task's trace,
+// or a child of the span of the distributed trace the task joins.
+func (d *ElideDriverPlugin) startTask(cfg *drivers.TaskConfig, queuedHash string) (*taskHandle, *TaskState, error) {
+	ctx, span := d.tracing.start(taskTraceContext(context.Background(), cfg), "StartTask", taskAttributes(cfg)...)
+	span.SetAttributes(attribute.Bool("elide.queued", queuedHash != ""))
+	h, driverState, err := d.submitTask(ctx, cfg, queuedHash)
+	// A session the daemon lost since the last keepalive check, e.g. to a
+	// restart, is re-created and the task submitted again, once
+	var notFound *sessionNotFoundError
+	if errors.As(err, &notFound) && d.dropUnknownSession(ctx, notFound.sessionID) {
+		span.AddEvent("re-creating lost session", trace.WithAttributes(attribute.String("elide.session_id", notFound.sessionID)))
+		h, driverState, err = d.submitTask(ctx, cfg, queuedHash)
+	}
+	if err == nil {
+		span.SetAttributes(
+			attribute.String("elide.session_id", h.sessionId),
+			attribute.String("elide.execution_id", h.executionId),
+		)
+		h.traceContext = span.SpanContext()
+		driverState.TraceParent = TraceParent(h.traceContext)
+		d.recordExecutions(h)
+	}
+	endSpan(span, err)
+	return h, driverState, err
+}
+
+// submitTask does the work of startTask, with daemon RPCs traced as children
+// of the span in ctx.
+func (d *ElideDriverPlugin) submitTask(ctx context.Context, cfg *drivers.TaskConfig, queuedHash string) (*taskHandle, *TaskState, error) {
 	var taskConfig TaskConfig
 	if err := cfg.DecodeDriverConfig(&taskConfig); err != nil {
 		return nil, nil, fmt.Errorf("failed to decode driver config: %w", err)
 	}
 
-	if err := taskConfig.Validate(); err != nil {
-		return nil, nil, fmt.Errorf("invalid task config: %w", err)
-	}
-
-	// Validate language against session's enabled languages
-	enabledLanguages := d.config.SessionConfig.EnabledLanguages
+	// Validate the config, and the language against the session's enabled
+	// languages, reporting all problems at once
+	enabledLanguages := d.sessionConfig().EnabledLanguages
 	if len(enabledLanguages) == 0 {
 		enabledLanguages = []string{"python", "javascript", "typescript"} // defaults
 	}
-	if err := taskConfig.ValidateLanguage(enabledLanguages); err != nil {
-		return nil, nil, fmt.Errorf("language validation failed: %w", err)
+	if err := taskConfig.ValidateFor(enabledLanguages); err != nil {
+		return nil, nil, fmt.Errorf("invalid task config: %w", err)
 	}
+	// Validated above, so inference cannot fail
+	taskConfig.Language, _ = taskConfig.InferLanguage()
 
-	d.logger.Info("starting task", "task_id", cfg.ID, "language", taskConfig.Language)
+	profile, err := d.resolveProfile(&taskConfig)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid task config: %w", err)
+	}
+	if name := taskConfig.ElideOpts.SessionProfile; name != "" {
+		if _, ok := d.config.SessionProfiles[name]; !ok {
+			return nil, nil, fmt.Errorf("invalid task config: unknown session profile %q", name)
+		}
+	}
+	var limits *pb.ExecutionLimits
+	var profileTimeout string
+	if profile != nil {
+		limits = profile.limits()
+		profileTimeout = profile.Timeout
+	}
+	topology, err := topologyHints(cfg, &taskConfig, hostNUMANodes())
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid task config: %w", err)
+	}
+	if err := d.checkRunAs(cfg.User); err != nil {
+		return nil, nil, fmt.Errorf("invalid task config: %w", err)
+	}
 
-	// Ensure session exists before starting task
-	if err := d.ensureSession(context.Background()); err != nil {
-		return nil, nil, fmt.Errorf("failed to ensure session: %w", err)
+	// Substitute the task's runtime environment, such as secrets rendered
+	// by templates, into its inline code and args
+	if taskConfig.Interpolate {
+		if err := InterpolateTask(&taskConfig, cfg.Env); err != nil {
+			return nil, nil, err
+		}
 	}
 
-	// Read script code (either from file or use inline code)
+	// Read script code (either from file or use inline code), or collect
+	// the files of an entrypoint task
 	var code string
-	var err error
+	var workspace *Workspace
 	if taskConfig.Code != "" {
 		code = taskConfig.Code
 	} else if taskConfig.Script != "" {
-		baseDir := filepath.Clean(cfg.TaskDir().Dir)
-		scriptPath := filepath.Clean(filepath.Join(baseDir, taskConfig.Script))
-		if !strings.HasPrefix(scriptPath, baseDir+string(os.PathSeparator)) && scriptPath != baseDir {
-			return nil, nil, fmt.Errorf("script path %q escapes task directory", taskConfig.Script)
+		scriptPath, err := taskConfig.ScriptPath(cfg.TaskDir().Dir, d.config.FollowSymlinks)
+		if err != nil {
+			return nil, nil, err
 		}
 		codeBytes, err := os.ReadFile(scriptPath)
 		if err != nil {
 			return nil, nil, fmt.Errorf("failed to read script file: %w", err)
 		}
 		code = string(codeBytes)
+	} else if taskConfig.CodeOCIRef != "" {
+		destDir := filepath.Join(cfg.TaskDir().Dir, ociArtifactDir)
+		pullCtx, pullCancel := d.withTimeout(d.ctx, ociPullTimeout)
+		code, err = fetchOCISnippet(pullCtx, taskConfig.CodeOCIRef, taskConfig.CodeOCIEntrypoint, destDir)
+		pullCancel()
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to pull OCI artifact: %w", err)
+		}
+	} else if taskConfig.Entrypoint != "" {
+		if !d.supportsWorkspaces.Load() {
+			return nil, nil, errors.New("invalid task config: entrypoint requires a daemon supporting workspace uploads")
+		}
+		workspace, err = CollectWorkspace(cfg.TaskDir().Dir, taskConfig.WorkspaceDir(), taskConfig.Entrypoint, taskConfig.Language, d.config.FollowSymlinks)
+		if err != nil {
+			return nil, nil, err
+		}
 	} else {
-		return nil, nil, fmt.Errorf("either 'script' or 'code' must be specified")
+		return nil, nil, fmt.Errorf("one of 'script', 'code', 'code_oci_ref' or 'entrypoint' must be specified")
 	}
 
+	if taskConfig.HasStdin() && !d.supportsStdin.Load() {
+		return nil, nil, errors.New("invalid task config: stdin_path and stdin_data require a daemon supporting stdin")
+	}
+	stdin, err := TaskStdin(&taskConfig, cfg.TaskDir().Dir, cfg.TaskDir().SharedAllocDir, d.config.FollowSymlinks)
+	if err != nil {
+		return nil, nil, err
+	}
+	resultSchema, err := LoadResultSchema(&taskConfig, cfg.TaskDir().Dir, d.config.FollowSymlinks)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(taskConfig.Ports) > 0 && !d.supportsPortBindings.Load() {
+		return nil, nil, errors.New("invalid task config: ports require a daemon supporting port bindings")
+	}
+	ports, err := PortBindings(cfg, taskConfig.Ports)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid task config: %w", err)
+	}
+
+	if err := ValidateCodeSize(code, d.maxCodeBytes.Load()); err != nil {
+		return nil, nil, fmt.Errorf("invalid task config: %w", err)
+	}
+	// The sources of a workspace are checked as a whole, and a change to
+	// any of its files is code drift
+	scanned, codeHash := code, hashCode(code)
+	if workspace != nil {
+		scanned, codeHash = workspace.Sources, workspace.Hash
+	}
+	if err := d.checkDeniedModules(cfg, taskConfig.Language, scanned); err != nil {
+		return nil, nil, fmt.Errorf("invalid task config: %w", err)
+	}
+	if err := d.checkCodeDrift(cfg, codeHash, taskConfig.ImmutableCode); err != nil {
+		return nil, nil, err
+	}
+	if queuedHash != "" && codeHash != queuedHash {
+		return nil, nil, fmt.Errorf("code changed while the task was queued (queued %s, now %s)", queuedHash, codeHash)
+	}
+
+	// Daemon errors of tasks that may be queued become daemonLostErrors
+	var sessionID string
+	lost := func(err error) error {
+		if queuedHash == "" && d.config.waitsOnDaemonLoss() && isUnavailable(err) {
+			return &daemonLostError{codeHash: codeHash, err: err}
+		}
+		return sessionNotFound(sessionID, err)
+	}
+	if queuedHash == "" && d.config.waitsOnDaemonLoss() && d.reconnect.isDown() {
+		return nil, nil, &daemonLostError{codeHash: codeHash, err: errors.New("daemon unreachable")}
+	}
+
+	d.logger.Info("starting task", "task_id", cfg.ID, "language", taskConfig.Language)
+
+	d.observeNode(cfg)
+
+	// Ensure session exists before starting task; the default session also
+	// hosts the session KV store for tasks running in profile sessions
+	if err := d.ensureSession(ctx); err != nil {
+		return nil, nil, lost(fmt.Errorf("failed to ensure session: %w", err))
+	}
+	sessionID, err = d.sessionFor(ctx, cfg, taskConfig.ElideOpts.SessionProfile)
+	if err != nil {
+		return nil, nil, lost(fmt.Errorf("failed to ensure session: %w", err))
+	}
+
+	// A task that fails to start gives up its use of a per-alloc or
+	// per-task session
+	started := false
+	defer func() {
+		if !started {
+			d.releaseScopedSession(cfg.ID)
+		}
+	}()
+
+	env, err := taskConfig.NormalizedEnv()
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid task config: %w", err)
+	}
+
+	// Merge the env file; vars set in env take precedence
+	if taskConfig.EnvFile != "" {
+		fileEnv, err := taskConfig.LoadEnvFile(cfg.TaskDir().Dir, d.config.FollowSymlinks)
+		if err != nil {
+			return nil, nil, err
+		}
+		for name, value := range fileEnv {
+			if _, ok := env[name]; !ok {
+				env[name] = value
+			}
+		}
+	}
+
+	// Apply platform-wide defaults for the language
+	languageDefaults := d.languageDefaults(taskConfig.Language)
+	languageDefaults.MergeEnv(env)
+
+	// Set the time zone and locale, which the daemon is told as well
+	timezone, locale := ApplyLocale(env, taskConfig.ElideOpts, d.config.DefaultTimezone, d.config.DefaultLocale)
+
+	// Populate imports from values exported by earlier tasks in the alloc
+	if len(taskConfig.Imports) > 0 {
+		importCtx, importCancel := d.withTimeout(withSpan(d.ctx, ctx), d.statusTimeout())
+		err = d.importSessionValues(importCtx, cfg.AllocID, taskConfig.Imports, env)
+		importCancel()
+		if err != nil {
+			return nil, nil, lost(err)
+		}
+	}
+
+	// Exchange the task's workload identity for a short-lived credential
+	if err := d.mintCredential(ctx, cfg, env); err != nil {
+		return nil, nil, err
+	}
+
+	var scratchDir string
+	if taskConfig.ScratchDir {
+		scratchDir, err = createScratchDir(cfg.TaskDir().Dir, cfg.User)
+		if err != nil {
+			return nil, nil, err
+		}
+		// A task that fails to start is never destroyed
+		defer func() {
+			if !started {
+				if err := os.RemoveAll(scratchDir); err != nil {
+					d.logger.Warn("failed to remove scratch dir", "task_id", cfg.ID, "error", err)
+				}
+			}
+		}()
+		env[scratchEnvVar] = scratchDir
+	}
+
+	// Executions with mount support see the task's directories at the paths
+	// Nomad reports to image-isolated tasks
+	mounts := d.taskMounts(cfg, scratchDir)
+	if mounts != nil && scratchDir != "" {
+		env[scratchEnvVar] = mountScratchDir
+	}
+
+	intrinsics := d.sessionConfig().intrinsics()
+	if profile != nil {
+		intrinsics = profile.Intrinsics
+	}
+	d.mergeNetworkEnv(cfg, env, intrinsics)
+	maps.Copy(env, portEnv(ports))
+
+	// The limits apply to the env the execution gets, not only the task's own
+	if err := validateEnvSize(env); err != nil {
+		return nil, nil, fmt.Errorf("task env is too large once env_file, language defaults, imports and credentials are merged: %w", err)
+	}
+
+	// Wait for an execution slot before submitting to the daemon
+	releaseSlot, err := d.acquireSlot(cfg, sessionID)
+	if err != nil {
+		return nil, nil, err
+	}
+	trace.SpanFromContext(ctx).AddEvent("execution slot acquired")
+
 	// Call ExecuteSnippet gRPC within session
-	execCtx, cancel := d.withTimeout(context.Background(), executeSnippetTimeout)
+	execCtx, cancel := d.withTimeout(ctx, d.submitTimeout())
 	defer cancel()
 
-	resp, err := d.daemonClient.ExecuteSnippet(
-		execCtx,
-		d.sessionID,
-		cfg.ID,
-		code,
-		taskConfig.Language,
-		taskConfig.Env,
-		taskConfig.Args,
-	)
-	if err != nil {
+	if err := d.faults.delaySubmit(execCtx); err != nil {
+		releaseSlot()
 		return nil, nil, fmt.Errorf("failed to execute snippet: %w", err)
 	}
 
-	// Create task handle
-	handle := drivers.NewTaskHandle(taskHandleVersion)
-	handle.Config = cfg
+	// The daemon enforces the task's overrides too, including the timeout the
+	// driver enforces below
+	timeout := durationOr(0, taskConfig.ElideOpts.Timeout, profileTimeout, d.config.ExecutionTimeout)
+	overrides := executionOverrides(taskConfig.ElideOpts, timeout, timezone, locale)
+
+	// An entrypoint task's files are uploaded once, for all its executions
+	var workspaceRef *pb.WorkspaceRef
+	if workspace != nil {
+		workspaceRef, err = d.uploadWorkspace(execCtx, sessionID, workspace, taskConfig.Entrypoint)
+		if err != nil {
+			releaseSlot()
+			return nil, nil, lost(err)
+		}
+	}
+
+	// The code of a workspace task is the whole workspace
+	codeBytes := int64(len(code))
+	if workspace != nil {
+		for _, file := range workspace.Files {
+			codeBytes += file.Size
+		}
+	}
+
+	// Short snippets are reported complete in the response if the daemon may
+	// wait for them; not those waiting for stdin, nor args_matrix entries
+	completionWait := durationOr(0, d.config.CompletionWait)
+	if stdin != nil || len(taskConfig.ArgsMatrix) > 0 {
+		completionWait = 0
+	}
+
+	// An overloaded daemon sheds the request with a backoff hint; retry until
+	// the submit timeout, then let Nomad reschedule the task
+	submit := func(executionID string, args []string) (*pb.ExecuteSnippetResponse, error) {
+		for {
+			resp, err := d.daemonClient.ExecuteSnippet(withOutgoingTraceContext(execCtx), ExecuteSnippetRequest{
+				SessionID:       sessionID,
+				ExecutionID:     executionID,
+				Code:            code,
+				Language:        taskConfig.Language,
+				Env:             d.executionEnv(env, executionID, sessionID, cfg.AllocID),
+				Args:            args,
+				InterpreterArgs: languageDefaults.InterpreterArgs,
+				Limits:          limits,
+				Overrides:       overrides,
+				Topology:        topology,
+				RunAs:           cfg.User,
+				Mounts:          mounts,
+				Workspace:       workspaceRef,
+				Stdin:           stdin != nil,
+				Ports:           ports,
+				DiscardOutput:   taskConfig.OutputMode == outputModeDiscard,
+				Tags:            executionTags(cfg),
+				CompletionWait:  completionWait,
+			})
+
+			var overloaded *DaemonOverloadedError
+			if !errors.As(err, &overloaded) {
+				if err != nil {
+					return nil, fmt.Errorf("failed to execute snippet: %w", err)
+				}
+				if stdin != nil {
+					if err := d.streamStdin(execCtx, sessionID, resp.ExecutionId, stdin); err != nil {
+						// The execution would wait for stdin without a task
+						// following it, holding a context of the session
+						d.cancelUnstarted(sessionID, resp.ExecutionId)
+						return nil, err
+					}
+				}
+				d.recordSubmission(taskConfig.Language, codeBytes)
+				return resp, nil
+			}
+			d.metrics.IncrCounter(metricDaemonOverloaded, "ExecuteSnippet calls shed by an overloaded daemon.")
+			d.logger.Debug("daemon overloaded, retrying execution", "task_id", cfg.ID, "retry_after", overloaded.RetryAfter)
+			if !waitOverloaded(execCtx, overloaded) {
+				return nil, nstructs.NewRecoverableError(fmt.Errorf("failed to execute snippet: %w", err), true)
+			}
+		}
+	}
+
+	// An args_matrix task submits one execution per entry, sharing the slot
+	var resp *pb.ExecuteSnippetResponse
+	var matrix []*MatrixEntry
+	if len(taskConfig.ArgsMatrix) > 0 {
+		matrix, resp, err = d.submitMatrix(execCtx, sessionID, cfg.ID, taskConfig.ArgsMatrix, submit)
+	} else {
+		resp, err = submit(cfg.ID, taskConfig.Args)
+	}
+	if err != nil {
+		releaseSlot()
+		return nil, nil, lost(err)
+	}
 
+	// Create task handle
 	h := &taskHandle{
 		executionId: resp.ExecutionId,
-		sessionId:   d.sessionID,
+		sessionId:   sessionID,
 		taskConfig:  cfg,
 		startedAt:   time.Now(),
-		status:      resp.Status.String(),
 		logger:      d.logger.With("task_id", cfg.ID),
-	}
+		releaseSlot: releaseSlot,
 
-	// Store handle and return
-	driverState := TaskState{
-		ExecutionId: resp.ExecutionId,
-		SessionId:   d.sessionID,
-		TaskConfig:  cfg,
-		StartedAt:   h.startedAt,
+		language:       taskConfig.Language,
+		profile:        taskConfig.ElideOpts.Profile,
+		sessionProfile: taskConfig.ElideOpts.SessionProfile,
+		sessionScope:   d.sessionScope(cfg, taskConfig.ElideOpts.SessionProfile),
+		exports:        taskConfig.Exports,
+		outputMode:     taskConfig.OutputMode,
+		codeHash:       codeHash,
+		matrix:         matrix,
+		matrixPolicy:   taskConfig.MatrixPolicy,
+		scratchDir:     scratchDir,
+		scratchQuota:   int64(taskConfig.ScratchQuotaMB) << 20,
+
+		memoryReservation: MemoryReservationMB(cfg, taskConfig.ElideOpts, profile),
+		network:           DriverNetworkFor(ports, resp, taskConfig.Expose),
+
+		resultSchema:       resultSchema,
+		resultSchemaPolicy: taskConfig.ResultSchemaPolicy,
+
+		pollInterval: durationOr(defaultPollInterval, taskConfig.PollInterval, d.config.PollInterval),
+
+		completion: resp.Completion,
 	}
-	if err := handle.SetDriverState(&driverState); err != nil {
-		return nil, nil, fmt.Errorf("failed to set driver state: %w", err)
+	if resp.Completion != nil {
+		trace.SpanFromContext(ctx).AddEvent("execution completed at submit")
 	}
-	d.tasks.Set(cfg.ID, h)
+	h.logs.binaryOutput = taskConfig.BinaryOutput
+	h.logs.combined = d.combineOutput(h, &taskConfig)
+	if timeout > 0 {
+		h.deadline = h.startedAt.Add(timeout)
+	}
+	h.updateStatus(resp.Status, "", resp.QueuedAt)
+
+	driverState := h.taskState()
+	d.allocs.started(cfg, codeHash)
+	d.recordTaskStart(h)
 
-	d.logger.Info("task started", "task_id", cfg.ID, "execution_id", resp.ExecutionId, "session_id", d.sessionID)
-	return handle, nil, nil
+	started = true
+
+	d.logger.Info("task started", "task_id", cfg.ID, "execution_id", resp.ExecutionId, "session_id", sessionID)
+	return h, driverState, nil
 }
 
 // RecoverTask recreates the in-memory state of a task from a TaskHandle.
@@ -347,52 +1030,169 @@ func (d *ElideDriverPlugin) RecoverTask(handle *drivers.TaskHandle) error {
 		return nil
 	}
 
-	var taskState TaskState
-	if err := handle.GetDriverState(&taskState); err != nil {
+	if err := d.faults.recoveryError(); err != nil {
+		return err
+	}
+
+	taskState, err := DecodeTaskState(handle.Version, handle.DriverState)
+	if err != nil {
 		return fmt.Errorf("failed to decode task state from handle: %w", err)
 	}
+	// The persisted config lacks the task's driver config, which a recovered
+	// task that is submitted again needs
+	if handle.Config != nil {
+		taskState.TaskConfig = handle.Config
+	}
+
+	if taskState.Queued {
+		return d.recoverQueuedTask(taskState)
+	}
+	return d.recoverTaskState(taskState)
+}
 
+// recoverTaskState rebuilds a task handle from its persisted state by
+// querying the daemon for the execution status.
+func (d *ElideDriverPlugin) recoverTaskState(taskState *TaskState) error {
 	// Ensure daemon client is connected
 	if d.daemonClient == nil {
-		client, err := NewDaemonClient(d.config.DaemonSocket, d.config.DaemonAddress)
+		client, err := d.newDaemonClient()
 		if err != nil {
 			return fmt.Errorf("failed to reconnect to daemon: %w", err)
 		}
 		d.daemonClient = client
-		d.sessionID = taskState.SessionId
+		if taskState.SessionProfile == "" && taskState.SessionScope == "" {
+			d.sessionID = taskState.SessionId
+		}
+	}
+	if taskState.SessionScope != "" {
+		d.adoptScopedSession(taskState.SessionScope, taskState.SessionId, taskState.TaskConfig.ID)
+	} else if taskState.SessionProfile != "" {
+		d.adoptProfileSession(taskState.SessionProfile, taskState.SessionId)
 	}
 
-	// Check execution status
-	statusCtx, cancel := d.withTimeout(context.Background(), statusRequestTimeout)
-	statusResp, err := d.daemonClient.GetExecutionStatus(statusCtx, taskState.SessionId, taskState.ExecutionId)
-	cancel()
+	// Check execution status. A task whose session the daemon lost, as to a
+	// daemon restart, is recovered as recover_policy says
+	statusResp, err := d.recoverExecutionStatus(taskState)
 	if err != nil {
+		if lost, recreated, reason := d.recoveredSessionLost(taskState); lost {
+			if d.resubmitted(taskState) {
+				return d.recoverQueuedTask(taskState)
+			}
+			return d.recoverLostSession(taskState, recreated, reason)
+		}
 		return fmt.Errorf("failed to get execution status: %w", err)
 	}
 
-	// Recreate handle
+	h := d.recoveredHandle(taskState)
+	h.updateStatus(statusResp.Status, statusResp.Message, statusResp.QueuedAt)
+	h.updateQueue(statusResp)
+
+	// If execution is complete, set exit result; otherwise it keeps holding
+	// an execution slot. An args_matrix task is complete once its watcher has
+	// polled every execution
+	if !statusResp.Complete || h.matrix != nil {
+		h.releaseSlot = d.admission.Adopt(h.sessionId)
+	}
+	if statusResp.Complete && h.matrix == nil {
+		if err := h.recordResult(statusResp.Result, d.config.MaxResultBytes); err != nil {
+			h.logger.Warn("failed to record execution result", "error", err)
+		}
+		result := h.completionResult(statusResp)
+		if result.Successful() {
+			result.Err = d.checkResultSchema(h, h.executionId, statusResp.Result)
+		}
+		h.SetCompleted(result)
+	}
+
+	d.tasks.Set(taskState.TaskConfig.ID, h)
+	if !statusResp.Complete {
+		d.recordExecutions(h)
+	}
+	d.observeNode(taskState.TaskConfig)
+	d.allocs.started(taskState.TaskConfig, taskState.CodeHash)
+	return nil
+}
+
+// recoveredHandle recreates the handle of a task from its persisted state.
+func (d *ElideDriverPlugin) recoveredHandle(taskState *TaskState) *taskHandle {
 	h := &taskHandle{
 		executionId: taskState.ExecutionId,
 		sessionId:   taskState.SessionId,
 		taskConfig:  taskState.TaskConfig,
 		startedAt:   taskState.StartedAt,
-		status:      statusResp.Status.String(),
 		logger:      d.logger.With("task_id", taskState.TaskConfig.ID),
+
+		language:       taskState.Language,
+		profile:        taskState.Profile,
+		sessionProfile: taskState.SessionProfile,
+		sessionScope:   taskState.SessionScope,
+		exports:        taskState.Exports,
+		outputMode:     taskState.OutputMode,
+		codeHash:       taskState.CodeHash,
+		matrix:         taskState.Matrix,
+		matrixPolicy:   taskState.MatrixPolicy,
+		scratchDir:     taskState.ScratchDir,
+		scratchQuota:   int64(taskState.ScratchQuotaMB) << 20,
+
+		memoryReservation: taskState.MemoryReservationMB,
+
+		deadline:     taskState.Deadline,
+		pollInterval: taskState.PollInterval,
 	}
+	if h.pollInterval <= 0 {
+		h.pollInterval = durationOr(defaultPollInterval, d.config.PollInterval)
+	}
+	h.logs.binaryOutput = taskState.BinaryOutput
+	h.logs.combined = taskState.CombineOutput
+	h.traceContext = ParseTraceParent(taskState.TraceParent)
+	if taskState.ResultSchema != "" {
+		schema, err := CompileResultSchema(taskState.ResultSchema)
+		if err != nil {
+			h.logger.Warn("failed to compile result_schema of recovered task, results are not validated", "error", err)
+		}
+		h.resultSchema, h.resultSchemaPolicy = schema, taskState.ResultSchemaPolicy
+	}
+	return h
+}
+
+// recoverExecutionStatus fetches the status of a recovered execution. While
+// the daemon is unreachable it waits for reconnection, up to recoverTimeout.
+func (d *ElideDriverPlugin) recoverExecutionStatus(taskState *TaskState) (*pb.GetExecutionStatusResponse, error) {
+	// The status RPC joins the trace of the task's start
+	ctx := trace.ContextWithRemoteSpanContext(d.ctx, ParseTraceParent(taskState.TraceParent))
+	ctx, cancel := d.withTimeout(ctx, recoverTimeout)
+	defer cancel()
 
-	// If execution is complete, set exit result
-	if statusResp.Complete {
-		result := &drivers.ExitResult{
-			ExitCode: int(statusResp.ExitCode),
+	for {
+		if err := d.reconnect.wait(ctx); err != nil {
+			return nil, fmt.Errorf("daemon unavailable: %w", err)
 		}
-		if statusResp.Error != "" {
-			result.Err = errors.New(statusResp.Error)
+
+		statusCtx, statusCancel := d.withTimeout(ctx, d.statusTimeout())
+		statusResp, err := d.daemonClient.GetExecutionStatus(statusCtx, taskState.SessionId, taskState.ExecutionId, OutputRequest{Omit: true})
+		statusCancel()
+		if err == nil || !isUnavailable(err) {
+			return statusResp, err
 		}
-		h.SetCompleted(result)
+		d.reconnect.markDown(err)
 	}
+}
 
-	d.tasks.Set(taskState.TaskConfig.ID, h)
-	return nil
+// rpcLogger returns the daemon RPC logger configured in the plugin config.
+func (d *ElideDriverPlugin) rpcLogger() *rpcLogger {
+	sampleRate, _ := d.config.rpcLogSampleRate()
+	slowThreshold, _ := ParseDuration("rpc_slow_threshold", d.config.RPCSlowThreshold)
+	return newRPCLogger(sampleRate, slowThreshold, d.logger)
+}
+
+// probeDaemon checks whether the daemon is reachable.
+func (d *ElideDriverPlugin) probeDaemon(ctx context.Context) error {
+	if d.daemonClient == nil {
+		return errors.New("daemon client not initialized")
+	}
+	d.daemonClient.Reconnect()
+	_, err := d.daemonClient.Health(ctx)
+	return err
 }
 
 // WaitTask returns a channel used to notify Nomad when a task exits.
@@ -402,72 +1202,359 @@ func (d *ElideDriverPlugin) WaitTask(ctx context.Context, taskID string) (<-chan
 		return nil, drivers.ErrTaskNotFound
 	}
 
+	if !d.lifecycle.enter(&d.lifecycle.watchers) {
+		return nil, errShuttingDown
+	}
+
 	ch := make(chan *drivers.ExitResult)
-	go d.handleWait(ctx, handle, ch)
+	go func() {
+		defer d.lifecycle.watchers.Done()
+		d.handleWait(ctx, handle, ch)
+	}()
 	return ch, nil
 }
 
 func (d *ElideDriverPlugin) handleWait(ctx context.Context, handle *taskHandle, ch chan *drivers.ExitResult) {
 	defer close(ch)
 
-	ticker := time.NewTicker(1 * time.Second)
+	// The wait ends early once the task exceeds max_task_lifetime, which
+	// then fails it
+	lifetimeCtx, cancel := d.lifetimeContext(ctx, handle)
+	defer cancel()
+
+	// A queued task is followed once it was submitted; one given up on
+	// reports why
+	if handle.queue != nil {
+		submitted := d.awaitQueued(lifetimeCtx, handle)
+		if submitted == nil && lifetimeExpired(ctx, lifetimeCtx) {
+			submitted = d.stopQueued(handle)
+			if submitted == nil {
+				result := d.expireTask(ctx, handle)
+				d.recordTaskExit(handle, result)
+				ch <- result
+				return
+			}
+		}
+		if submitted == nil {
+			handle.stateLock.RLock()
+			result := handle.exitResult
+			handle.stateLock.RUnlock()
+			if result != nil {
+				d.recordTaskExit(handle, result)
+				ch <- result
+			}
+			return
+		}
+		handle = submitted
+	}
+
+	// A recovered task whose session was lost already has its exit result
+	if handle.sessionLost {
+		handle.stateLock.RLock()
+		result := handle.exitResult
+		handle.stateLock.RUnlock()
+		d.recordTaskExit(handle, result)
+		ch <- result
+		return
+	}
+
+	// The wait is traced as a WaitTask span in the task's trace, parent of
+	// its status RPCs
+	ctx, span := d.tracing.start(taskContext(ctx, handle), "WaitTask", taskAttributes(handle.taskConfig)...)
+	span.SetAttributes(attribute.String("elide.execution_id", handle.executionId))
+	var result *drivers.ExitResult
+	defer func() {
+		endWaitSpan(span, result)
+		if result != nil {
+			d.recordTaskExit(handle, result)
+		}
+	}()
+
+	result = d.followExecution(withSpan(lifetimeCtx, ctx), handle)
+	// A status RPC cut off by the lifetime deadline is not what failed the
+	// task
+	if lifetimeExpired(ctx, lifetimeCtx) && handle.IsRunning() {
+		result = d.expireTask(ctx, handle)
+	}
+	if result != nil {
+		ch <- result
+	}
+}
+
+// followExecution follows a submitted task's execution until the task is
+// done, returning its exit result, or nil if ctx ended first.
+func (d *ElideDriverPlugin) followExecution(ctx context.Context, handle *taskHandle) *drivers.ExitResult {
+	pollInterval := handle.pollInterval
+	if pollInterval <= 0 {
+		pollInterval = defaultPollInterval
+	}
+
+	// The poller also ships the task's output
+	w := d.workers.Start(workerStatusPoller+"/"+handle.taskConfig.ID, pollInterval)
+	defer w.Done()
+
+	var lastScratchCheck time.Time
+
+	// An execution that completed before ExecuteSnippet responded needs no
+	// following
+	handle.stateLock.Lock()
+	completion := handle.completion
+	handle.completion = nil
+	handle.stateLock.Unlock()
+	if completion != nil {
+		result, _ := d.applyStatus(handle, completion, OutputRequest{Order: handle.logs.combined}, &lastScratchCheck)
+		return result
+	}
+
+	// Follow the execution over a status stream; poll if the daemon does not
+	// support streaming or the stream ends early
+	if handle.matrix == nil && !d.watchUnsupported.Load() {
+		if result, done := d.watchExecution(ctx, handle, w, &lastScratchCheck, pollInterval); done {
+			return result
+		}
+	}
+	trace.SpanFromContext(ctx).AddEvent("polling status")
+
+	ticker := time.NewTicker(pollInterval)
 	defer ticker.Stop()
 
 	for {
 		select {
 		case <-ctx.Done():
-			return
+			return nil
 		case <-d.ctx.Done():
-			return
+			return nil
 		case <-ticker.C:
-			statusCtx, cancel := d.withTimeout(ctx, statusRequestTimeout)
-			statusResp, err := d.daemonClient.GetExecutionStatus(statusCtx, handle.sessionId, handle.executionId)
-			cancel()
-			if err != nil {
-				ch <- &drivers.ExitResult{
-					Err: fmt.Errorf("failed to get execution status: %w", err),
-				}
-				return
+			d.beatPoller(w)
+			if result, done := d.pollExecution(ctx, handle, &lastScratchCheck); done {
+				return result
 			}
+		}
+	}
+}
 
-			// Update handle status
-			handle.stateLock.Lock()
-			handle.status = statusResp.Status.String()
-			handle.stateLock.Unlock()
+// pollExecution polls the status of an execution once and enforces the
+// driver's limits on it. It returns the task's exit result and true once the
+// task is done. This runs every poll interval for every tracked execution, so
+// it should stay free of avoidable allocations.
+func (d *ElideDriverPlugin) pollExecution(ctx context.Context, handle *taskHandle, lastScratchCheck *time.Time) (*drivers.ExitResult, bool) {
+	// Wait out daemon outages instead of failing the task
+	if err := d.reconnect.wait(ctx); err != nil {
+		return nil, false
+	}
+	if handle.matrix != nil {
+		return d.pollMatrix(ctx, handle, lastScratchCheck)
+	}
 
-			if statusResp.Complete {
-				result := &drivers.ExitResult{
-					ExitCode: int(statusResp.ExitCode),
-				}
-				if statusResp.Error != "" {
-					result.Err = errors.New(statusResp.Error)
-				}
-				handle.SetCompleted(result)
-				ch <- result
-				return
+	output := d.statusOutput(handle)
+	statusResp, err := d.pollStatus(ctx, handle.sessionId, handle.executionId, output)
+	if err != nil && isUnavailable(err) {
+		d.reconnect.markDown(err)
+		return nil, false
+	}
+	if err != nil {
+		return &drivers.ExitResult{
+			Err: fmt.Errorf("failed to get execution status: %w", err),
+		}, true
+	}
+
+	if d.faults.shouldDropStatus() {
+		handle.logger.Debug("dropping status update (fault injection)")
+		return nil, false
+	}
+	return d.applyStatus(handle, statusResp, output, lastScratchCheck)
+}
+
+// applyStatus records a status update of an execution and enforces the
+// driver's limits on it. output is the output the status was requested with;
+// a completed execution's status must carry all of it. It returns the task's
+// exit result and true once the execution completed.
+func (d *ElideDriverPlugin) applyStatus(handle *taskHandle, statusResp *pb.GetExecutionStatusResponse, output OutputRequest, lastScratchCheck *time.Time) (*drivers.ExitResult, bool) {
+	// Update handle status
+	handle.updateStatus(statusResp.Status, statusResp.Message, statusResp.QueuedAt)
+	handle.updateQueue(statusResp)
+	d.forensics.recordStatus(handle.taskConfig.ID, statusResp)
+
+	if !statusResp.Complete {
+		if err := handle.shipRunningOutput(statusResp, output); err != nil {
+			handle.logger.Warn("failed to ship execution output", "error", err)
+		}
+		d.enforceLimits(handle, lastScratchCheck)
+		return nil, false
+	}
+
+	result := handle.completionResult(statusResp)
+	if result.Successful() {
+		result.Err = d.checkResultSchema(handle, handle.executionId, statusResp.Result)
+	}
+	if result.Successful() {
+		exportCtx, exportCancel := d.withTimeout(d.ctx, d.statusTimeout())
+		if err := d.exportSessionValues(exportCtx, handle, statusResp.Result); err != nil {
+			result.Err = fmt.Errorf("failed to export session values: %w", err)
+		}
+		exportCancel()
+	}
+	if err := handle.recordResult(statusResp.Result, d.config.MaxResultBytes); err != nil {
+		handle.logger.Warn("failed to record execution result", "error", err)
+	}
+	if err := handle.shipOutput(statusResp.Stdout, statusResp.Stderr, statusResp.OutputSegments); err != nil {
+		handle.logger.Warn("failed to ship execution output", "error", err)
+	}
+	handle.SetCompleted(result)
+	go d.guardRestarts(handle, result)
+	if d.archiver != nil {
+		go d.archiveOutput(handle, statusResp)
+	}
+	return result, true
+}
+
+// enforceLimits cancels a running task past its deadline and periodically
+// checks its scratch directory quota.
+func (d *ElideDriverPlugin) enforceLimits(handle *taskHandle, lastScratchCheck *time.Time) {
+	if !handle.deadline.IsZero() && time.Now().After(handle.deadline) && !handle.isCancelled() {
+		cancelCtx, cancel := d.withTimeout(d.ctx, d.cancelTimeout())
+		_ = d.cancelExecution(cancelCtx, handle, pb.CancellationReason_CANCELLATION_REASON_TIMEOUT, initiatorDriver,
+			fmt.Errorf("execution exceeded its timeout of %s", handle.deadline.Sub(handle.startedAt)))
+		cancel()
+	}
+
+	if handle.scratchDir != "" && time.Since(*lastScratchCheck) >= scratchCheckInterval {
+		*lastScratchCheck = time.Now()
+		d.enforceScratchQuota(handle)
+	}
+}
+
+// archiveOutput uploads the output of a completed execution to object storage.
+func (d *ElideDriverPlugin) archiveOutput(handle *taskHandle, status *pb.GetExecutionStatusResponse) {
+	ctx, cancel := d.withTimeout(d.ctx, archiveUploadTimeout)
+	defer cancel()
+
+	if err := d.archiver.Archive(ctx, handle, status); err != nil {
+		handle.logger.Warn("failed to archive execution output", "error", err)
+	}
+}
+
+// cancelExecution cancels an execution and records why and who initiated it.
+// The first recorded cancellation is reported as the task's exit error and
+// emitted as a task event; later ones only repeat the cancel request, with the
+// same cancel token so the daemon acknowledges them. Executions that already
+// completed, or that the daemon no longer knows, count as stopped: if none
+// was left to cancel, the recorded cancellation is dropped so the task exits
+// with the status the execution completed with.
+func (d *ElideDriverPlugin) cancelExecution(ctx context.Context, handle *taskHandle, reason pb.CancellationReason, initiator string, cause error) error {
+	recorded := handle.markCancelled(reason, initiator, cause)
+	if recorded {
+		message := fmt.Sprintf("Cancelling execution (%s, initiated by %s)", cancellationReasonName(reason), initiator)
+		if cause != nil {
+			message = fmt.Sprintf("%s: %v", message, cause)
+		}
+
+		handle.logger.Warn("cancelling execution", "reason", cancellationReasonName(reason), "initiator", initiator, "cause", cause)
+		d.eventer.EmitEvent(&drivers.TaskEvent{
+			TaskID:    handle.taskConfig.ID,
+			AllocID:   handle.taskConfig.AllocID,
+			TaskName:  handle.taskConfig.Name,
+			Timestamp: time.Now(),
+			Message:   message,
+			Annotations: map[string]string{
+				"cancel_reason": cancellationReasonName(reason),
+				"cancelled_by":  initiator,
+			},
+		})
+	}
+
+	// Report the recorded cancellation, which may predate this request
+	handle.stateLock.RLock()
+	reason, initiator = handle.cancelReason, handle.cancelledBy
+	handle.stateLock.RUnlock()
+
+	var firstErr error
+	anyCancelled := false
+	for _, executionID := range handle.executionIDs() {
+		cancelled, err := d.daemonClient.CancelExecution(ctx, handle.sessionId, executionID, reason, initiator, CancelToken(executionID, reason, initiator))
+		if err != nil {
+			handle.logger.Warn("failed to cancel execution", "execution_id", executionID, "error", err)
+			if firstErr == nil {
+				firstErr = err
 			}
+			continue
 		}
+		if !cancelled {
+			handle.logger.Debug("execution already stopped, nothing to cancel", "execution_id", executionID)
+		}
+		anyCancelled = anyCancelled || cancelled
+	}
+	if recorded && !anyCancelled && firstErr == nil {
+		handle.clearCancelled()
 	}
+	return firstErr
 }
 
-// StopTask stops a running task with the given signal and within the timeout window.
+// cancelUnstarted cancels an execution submitted for a task that then failed
+// to start, so that it does not hold capacity of the session with no task
+// following it.
+func (d *ElideDriverPlugin) cancelUnstarted(sessionID string, executionID string) {
+	ctx, cancel := d.withTimeout(d.ctx, d.cancelTimeout())
+	defer cancel()
+
+	reason := pb.CancellationReason_CANCELLATION_REASON_UNSPECIFIED
+	if _, err := d.daemonClient.CancelExecution(ctx, sessionID, executionID, reason, initiatorDriver, CancelToken(executionID, reason, initiatorDriver)); err != nil {
+		d.logger.Warn("failed to cancel execution of a task that failed to start", "execution_id", executionID, "error", err)
+	}
+}
+
+// StopTask stops a running task: its executions are sent the kill signal and
+// given the timeout window to exit, then cancelled.
 func (d *ElideDriverPlugin) StopTask(taskID string, timeout time.Duration, signal string) error {
 	handle, ok := d.tasks.Get(taskID)
 	if !ok {
 		return drivers.ErrTaskNotFound
 	}
 
-	_ = signal // TODO: Forward signal to execution if daemon supports it
+	if handle.queue != nil && handle.IsRunning() {
+		if submitted := d.stopQueued(handle); submitted != nil {
+			return d.StopTask(taskID, timeout, signal)
+		}
+		return nil
+	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
-	defer cancel()
+	handle.stateLock.Lock()
+	handle.stopping = true
+	handle.stateLock.Unlock()
+
+	if !handle.IsRunning() {
+		return nil
+	}
+
+	spanCtx, span := d.tracing.start(taskContext(context.Background(), handle), "StopTask", taskAttributes(handle.taskConfig)...)
+	span.SetAttributes(attribute.String("elide.kill_signal", signal), attribute.String("elide.kill_timeout", timeout.String()))
+	err := d.stopTask(spanCtx, handle, timeout, signal)
+	endSpan(span, err)
+	return err
+}
 
-	err := d.daemonClient.CancelExecution(ctx, handle.sessionId, handle.executionId)
+// stopTask signals a running task and cancels its executions if they do not
+// exit in time, force-killing those the daemon does not confirm stopped, with
+// the cancellation traced as a child of the span in ctx.
+func (d *ElideDriverPlugin) stopTask(ctx context.Context, handle *taskHandle, timeout time.Duration, signal string) error {
+	cause := d.stopWithSignal(handle, timeout, signal)
+	if cause == nil || !handle.IsRunning() {
+		d.metrics.IncrCounter(metricTaskStops, "Tasks stopped by Nomad, by whether they exited or were cancelled.", "result", stopResultExited)
+		return nil
+	}
+	d.metrics.IncrCounter(metricTaskStops, "Tasks stopped by Nomad, by whether they exited or were cancelled.", "result", stopResultCancelled)
+	trace.SpanFromContext(ctx).AddEvent("cancelling execution", trace.WithAttributes(attribute.String("elide.cancel_cause", cause.Error())))
+
+	executionIDs := handle.executionIDs()
+	cancelCtx, cancel := d.withTimeout(ctx, d.cancelTimeout())
+	err := d.cancelExecution(cancelCtx, handle, pb.CancellationReason_CANCELLATION_REASON_USER_STOP, initiatorNomad, cause)
+	cancel()
 	if err != nil {
 		return fmt.Errorf("failed to cancel execution: %w", err)
 	}
 
-	return nil
+	// The task is only reported stopped once the daemon confirms it
+	return d.confirmCancelled(ctx, handle, executionIDs)
 }
 
 // DestroyTask cleans up and removes a task that has terminated.
@@ -480,13 +1567,25 @@ func (d *ElideDriverPlugin) DestroyTask(taskID string, force bool) error {
 	if handle.IsRunning() && !force {
 		return errors.New("cannot destroy running task")
 	}
+	if handle.queue != nil {
+		handle.queue.cancel()
+	}
+	d.removeQueueEntry(taskID)
+	d.forgetExecutions(taskID)
+	d.forensics.forgetTask(taskID)
 
-	_ = handle // TODO: Use once daemon API is available for cleanup
+	if handle.releaseSlot != nil {
+		handle.releaseSlot()
+	}
 
-	// TODO: Cleanup any resources if needed
-	// The daemon should handle cleanup automatically when execution completes
+	if err := handle.removeScratchDir(); err != nil {
+		d.logger.Warn("failed to remove scratch dir", "task_id", taskID, "error", err)
+	}
+	handle.logs.close()
 
 	d.tasks.Delete(taskID)
+	d.workers.Remove(workerStatusPoller + "/" + taskID)
+	d.releaseScopedSession(taskID)
 	return nil
 }
 
@@ -528,43 +1627,81 @@ func (d *ElideDriverPlugin) TaskEvents(ctx context.Context) (<-chan *drivers.Tas
 	return d.eventer.TaskEvents(ctx)
 }
 
-// SignalTask forwards a signal to a task.
-func (d *ElideDriverPlugin) SignalTask(taskID string, signal string) error {
-	_, ok := d.tasks.Get(taskID)
-	if !ok {
-		return drivers.ErrTaskNotFound
-	}
+// Shutdown is called when the driver is being shut down and should
+// clean up any resources, including closing the session with the daemon.
+//
+// Shutdown first stops accepting new tasks, then stops the driver's own
+// endpoints, drains in-flight StartTask calls and task watchers, and only
+// then deletes the session, so no execution is submitted during teardown.
+func (d *ElideDriverPlugin) Shutdown() {
+	d.shutdown(false)
+}
 
-	_ = signal // TODO: Use once daemon API is available
+// Detach shuts the driver down like Shutdown, but leaves the sessions of
+// tasks still running to the next plugin instance, which recovers the tasks.
+// It is used when the plugin process is stopped, e.g. by the Nomad client
+// restarting, rather than the tasks.
+func (d *ElideDriverPlugin) Detach() {
+	d.shutdown(true)
+}
 
-	// TODO: Once daemon API is available, forward signal via CancelExecution:
-	// The signal could be passed as a parameter to cancellation
+// shutdown does the work of Shutdown and Detach; keepLive leaves the sessions
+// of live tasks in place.
+func (d *ElideDriverPlugin) shutdown(keepLive bool) {
+	if !d.lifecycle.beginShutdown() {
+		return
+	}
+	d.logger.Info("shutting down elide driver", "keep_live_sessions", keepLive)
 
-	d.logger.Warn("signal forwarding not yet implemented", "task_id", taskID, "signal", signal)
-	return nil
-}
+	// Stop the metrics endpoint
+	if d.metricsSrv != nil {
+		if err := d.metricsSrv.Close(); err != nil {
+			d.logger.Warn("failed to stop metrics endpoint", "error", err)
+		}
+	}
 
-// ExecTask returns the result of executing the given command inside a task.
-// This is not supported for MVP.
-func (d *ElideDriverPlugin) ExecTask(taskID string, cmd []string, timeout time.Duration) (*drivers.ExecTaskResult, error) {
-	return nil, errors.New("exec not supported")
-}
+	// Stop the admin API
+	if d.admin != nil {
+		if err := d.admin.Close(); err != nil {
+			d.logger.Warn("failed to stop admin API", "error", err)
+		}
+	}
 
-// Shutdown is called when the driver is being shut down and should
-// clean up any resources, including closing the session with the daemon.
-func (d *ElideDriverPlugin) Shutdown() {
-	d.logger.Info("shutting down elide driver")
+	// Signal shutdown to all goroutines, unblocking tasks waiting for an
+	// execution slot and stopping watchers, then wait for them to finish
+	d.signalShutdown()
+	if !d.lifecycle.drain(shutdownDrainTimeout) {
+		d.logger.Warn("timed out draining tasks before shutdown", "timeout", shutdownDrainTimeout)
+	}
 
-	// Clean up session with daemon
-	if d.sessionID != "" && d.daemonClient != nil {
+	// Clean up sessions with daemon
+	var keep map[string]bool
+	if keepLive {
+		keep = d.sessionsInUse()
+	}
+	if d.daemonClient != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		d.deleteProfileSessions(ctx, keep)
+		d.deleteScopedSessions(ctx, keep)
+
+		d.sessionLock.Lock()
+		d.deleteRetiredSessions(ctx)
+		d.sessionLock.Unlock()
+	}
+	if keep[d.sessionID] {
+		d.logger.Info("leaving session of live tasks to the next plugin instance", "session_id", d.sessionID)
+	} else if d.sessionID != "" && d.daemonClient != nil {
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
 
 		d.logger.Info("deleting session", "session_id", d.sessionID)
 		if err := d.daemonClient.DeleteSession(ctx, d.sessionID); err != nil {
 			d.logger.Warn("failed to delete session on shutdown", "error", err, "session_id", d.sessionID)
+			d.recordSessionError(err)
 		} else {
 			d.logger.Info("session deleted successfully", "session_id", d.sessionID)
+			d.recordSessionDeleted()
 		}
 	}
 
@@ -574,9 +1711,28 @@ func (d *ElideDriverPlugin) Shutdown() {
 			d.logger.Warn("failed to close daemon client", "error", err)
 		}
 	}
+	d.closeEndpoints()
 
-	// Signal shutdown to all goroutines
-	d.signalShutdown()
+	// Flush the spans of the last tasks
+	if err := d.tracing.close(); err != nil {
+		d.logger.Warn("failed to flush traces", "error", err)
+	}
+}
+
+// submitTimeout bounds ExecuteSnippet RPCs.
+func (d *ElideDriverPlugin) submitTimeout() time.Duration {
+	return durationOr(defaultSubmitTimeout, d.config.SubmitTimeout)
+}
+
+// statusTimeout bounds status polls and other short RPCs.
+func (d *ElideDriverPlugin) statusTimeout() time.Duration {
+	return durationOr(defaultStatusTimeout, d.config.StatusTimeout)
+}
+
+// cancelTimeout bounds cancellations initiated by the driver or the API
+// server; Nomad's kill timeout bounds those of StopTask.
+func (d *ElideDriverPlugin) cancelTimeout() time.Duration {
+	return durationOr(defaultCancelTimeout, d.config.CancelTimeout)
 }
 
 func (d *ElideDriverPlugin) withTimeout(parent context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
@@ -606,70 +1762,95 @@ func (d *ElideDriverPlugin) ensureSession(ctx context.Context) error {
 	if d.sessionID != "" {
 		return nil
 	}
+	return d.createSession(ctx)
+}
+
+// createSession opens the default session. Callers must hold sessionLock.
+func (d *ElideDriverPlugin) createSession(ctx context.Context) error {
+	session, err := d.openSession(ctx, d.generateSessionID(), d.buildSessionConfig())
+	if err != nil {
+		return err
+	}
 
-	sessionID := d.generateSessionID()
-	sessionConfig := d.buildSessionConfig()
+	d.sessionID = session.ID
+	d.recordSession(d.sessionID)
+	if session.Created {
+		d.recordSessionCreated(d.sessionID)
+		go d.warmSession(d.sessionID)
+	} else {
+		d.recordSessionAdopted(d.sessionID, session.CreatedAt)
+	}
+	return nil
+}
 
-	const attempts = 5
-	baseDelay := 200 * time.Millisecond
-	var lastErr error
+// openSession opens a session with the configured retry policy. Callers must
+// hold sessionLock.
+func (d *ElideDriverPlugin) openSession(ctx context.Context, sessionID string, sessionConfig *pb.SessionConfiguration) (*OpenedSession, error) {
+	policy, _ := d.config.SessionRetry.Policy()
+	session, err := OpenSession(ctx, d.daemonClient, sessionID, sessionConfig, policy, d.logger)
+	if err != nil {
+		d.recordSessionError(err)
+		return nil, err
+	}
+	return session, nil
+}
 
-	for i := 0; i < attempts; i++ {
-		if ctx.Err() != nil {
-			return ctx.Err()
-		}
+// checkSession verifies the current session is still active on the daemon.
+// A session the daemon reports as closed or errored is forgotten so that the
+// next task re-creates it.
+func (d *ElideDriverPlugin) checkSession() {
+	d.sessionLock.Lock()
+	defer d.sessionLock.Unlock()
 
-		createCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
-		resp, err := d.daemonClient.CreateSession(createCtx, sessionID, sessionConfig)
-		cancel()
-		if err == nil && resp != nil {
-			d.sessionID = resp.SessionId
-			d.logger.Info("created session", "session_id", d.sessionID, "attempt", i+1)
-			return nil
-		}
-		if err != nil {
-			lastErr = err
-		}
+	d.checkProfileSessions()
+	d.checkScopedSessions()
+	d.deleteRetiredSessions(d.ctx)
 
-		getCtx, getCancel := context.WithTimeout(ctx, 3*time.Second)
-		getResp, getErr := d.daemonClient.GetSession(getCtx, sessionID)
-		getCancel()
-		if getErr == nil && getResp != nil {
-			d.sessionID = getResp.SessionId
-			d.logger.Info("reusing existing session", "session_id", d.sessionID)
-			return nil
-		}
+	if d.sessionID == "" {
+		return
+	}
 
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		case <-time.After(time.Duration(i+1) * baseDelay):
-		}
+	ctx, cancel := context.WithTimeout(d.ctx, 3*time.Second)
+	resp, err := d.daemonClient.GetSession(ctx, d.sessionID)
+	cancel()
+	if lost, reason := sessionLost(d.sessionID, resp, err); lost {
+		err = reason
+		d.logger.Warn("session lost, it will be re-created", "session_id", d.sessionID, "reason", reason)
+		d.sessionLostForensics(d.sessionID, reason)
+		d.sessionID = ""
 	}
+	if err != nil {
+		d.recordSessionKeepaliveFailure(err)
+	}
+}
 
-	if lastErr != nil {
-		return fmt.Errorf("failed to create session after retries: %w", lastErr)
+// negotiateCodeLimit records the code payload limit reported by the daemon,
+// falling back to the default gRPC message limit if it reports none.
+func (d *ElideDriverPlugin) negotiateCodeLimit(health *pb.HealthResponse) {
+	limit := int64(defaultMaxCodeBytes)
+	if reported := health.GetMaxCodeBytes(); reported > 0 {
+		limit = int64(reported)
+	}
+	if old := d.maxCodeBytes.Swap(limit); old != limit {
+		d.logger.Debug("negotiated max code payload", "bytes", limit)
 	}
-	return errors.New("failed to create or reuse session: unknown error")
 }
 
 func (d *ElideDriverPlugin) buildSessionConfig() *pb.SessionConfiguration {
-	contextPoolSize := d.config.SessionConfig.ContextPoolSize
+	sessionConfig := d.sessionConfig()
+	contextPoolSize := sessionConfig.ContextPoolSize
 	if contextPoolSize == 0 {
 		contextPoolSize = 10
 	}
 
-	enabledLanguages := d.config.SessionConfig.EnabledLanguages
+	enabledLanguages := sessionConfig.EnabledLanguages
 	if len(enabledLanguages) == 0 {
 		enabledLanguages = []string{"python", "javascript", "typescript"}
 	}
 
-	enabledIntrinsics := d.config.SessionConfig.EnabledIntrinsics
-	if len(enabledIntrinsics) == 0 {
-		enabledIntrinsics = []string{"io", "env"}
-	}
+	enabledIntrinsics := sessionConfig.intrinsics()
 
-	memoryLimitMB := d.config.SessionConfig.MemoryLimitMB
+	memoryLimitMB := sessionConfig.MemoryLimitMB
 	if memoryLimitMB == 0 {
 		memoryLimitMB = 512
 	}
@@ -679,7 +1860,9 @@ func (d *ElideDriverPlugin) buildSessionConfig() *pb.SessionConfiguration {
 		EnabledLanguages:  enabledLanguages,
 		EnabledIntrinsics: enabledIntrinsics,
 		MemoryLimitMb:     uint64(memoryLimitMB),
-		EnableAi:          d.config.SessionConfig.EnableAI,
+		EnableAi:          sessionConfig.EnableAI,
+		Tags:              d.sessionTags(),
+		InitSnippets:      d.initSnippets(),
 	}
 }
 
@@ -688,10 +1871,24 @@ func (d *ElideDriverPlugin) generateSessionID() string {
 		return d.sessionID
 	}
 
+	// Sessions of a session manifest are named after its revision, so a
+	// changed manifest gets a new session while tasks drain from the old one;
+	// rotated sessions likewise get the rotation's generation
+	sessionID := fmt.Sprintf("nomad-%s", hostname())
+	if m := d.manifest.Load(); m != nil {
+		sessionID += "-" + m.revision
+	}
+	if d.sessionGeneration > 0 {
+		sessionID += fmt.Sprintf("-g%d", d.sessionGeneration)
+	}
+	return sessionID
+}
+
+// hostname returns the host name of the node, or "unknown".
+func hostname() string {
 	hostname, err := os.Hostname()
 	if err != nil || hostname == "" {
-		hostname = "unknown"
+		return "unknown"
 	}
-
-	return fmt.Sprintf("nomad-%s", hostname)
+	return hostname
 }
@@ -0,0 +1,122 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/nomad/plugins/drivers"
+	"github.com/hashicorp/nomad/plugins/shared/structs"
+	"google.golang.org/grpc"
+
+	pb "github.com/elide-dev/elide-task-driver/proto/gen/go/elide/daemon/v1alpha1"
+)
+
+const (
+	// endpointAttributePrefix prefixes the node attributes reporting the
+	// health of each daemon endpoint
+	endpointAttributePrefix = "driver.elide.endpoint."
+
+	// defaultEndpointName names the daemon the driver runs tasks on among
+	// the daemon endpoints
+	defaultEndpointName = "default"
+
+	// endpointHealthTimeout bounds the health check of one endpoint
+	endpointHealthTimeout = 2 * time.Second
+)
+
+// DaemonEndpointConfig is a daemon_endpoint block of the plugin config: an
+// additional daemon on the node, e.g. a GPU variant, whose health is published
+// so jobs can constrain on it.
+type DaemonEndpointConfig struct {
+	// Socket is the daemon's Unix socket
+	Socket string `codec:"socket"`
+	// Address is the daemon's TCP address (alternative to Socket)
+	Address string `codec:"address"`
+}
+
+// Validate checks a daemon_endpoint block.
+func (c *DaemonEndpointConfig) Validate(name string) error {
+	if !isProfileName(name) {
+		return fmt.Errorf("name may only contain letters, digits, '-' and '_'")
+	}
+	if name == defaultEndpointName {
+		return fmt.Errorf("name %q is reserved for the daemon tasks run on", defaultEndpointName)
+	}
+	if (c.Socket == "") == (c.Address == "") {
+		return fmt.Errorf("exactly one of 'socket' or 'address' must be specified")
+	}
+	return nil
+}
+
+// connectEndpoints connects to the configured daemon endpoints. Connections
+// are lazy, so endpoints that are down are reported unhealthy until they come
+// up.
+func (d *ElideDriverPlugin) connectEndpoints() error {
+	clients := make(map[string]DaemonClient, len(d.config.DaemonEndpoints))
+	for name, endpoint := range d.config.DaemonEndpoints {
+		opts := append([]grpc.DialOption{d.rpcLogger().dialOption()}, d.tracing.dialOptions()...)
+		client, err := NewDaemonClient(endpoint.Socket, endpoint.Address, opts...)
+		if err != nil {
+			return fmt.Errorf("daemon_endpoint %q: %w", name, err)
+		}
+		clients[name] = client
+	}
+	d.endpoints = clients
+	return nil
+}
+
+// endpointAttributes publishes the health of each daemon endpoint, and of the
+// daemon tasks run on as endpoint "default", when endpoints are configured:
+// driver.elide.endpoint.<name>.healthy and, for healthy endpoints, .version.
+// health is the default daemon's health check.
+func (d *ElideDriverPlugin) endpointAttributes(fp *drivers.Fingerprint, health *pb.HealthResponse) {
+	if len(d.endpoints) == 0 {
+		return
+	}
+
+	setEndpointAttributes(fp, defaultEndpointName, health, nil)
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, client := range d.endpoints {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(d.ctx, endpointHealthTimeout)
+			health, err := client.Health(ctx)
+			cancel()
+			if err != nil {
+				d.logger.Debug("daemon endpoint health check failed", "endpoint", name, "error", err)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			setEndpointAttributes(fp, name, health, err)
+		}()
+	}
+	wg.Wait()
+}
+
+// setEndpointAttributes sets the attributes of one endpoint from its health
+// check.
+func setEndpointAttributes(fp *drivers.Fingerprint, name string, health *pb.HealthResponse, err error) {
+	healthy := err == nil && health.GetHealthy()
+	fp.Attributes[endpointAttributePrefix+name+".healthy"] = structs.NewBoolAttribute(healthy)
+	if healthy && health.Version != "" {
+		fp.Attributes[endpointAttributePrefix+name+".version"] = structs.NewStringAttribute(health.Version)
+	}
+}
+
+// closeEndpoints closes the connections to the daemon endpoints.
+func (d *ElideDriverPlugin) closeEndpoints() {
+	for name, client := range d.endpoints {
+		if err := client.Close(); err != nil {
+			d.logger.Warn("failed to close daemon endpoint client", "endpoint", name, "error", err)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/nomad/plugins/drivers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStartTask_LimitsMergedEnv(t *testing.T) {
+	// vars returns n env vars named prefix_<i>
+	vars := func(prefix string, n int) map[string]string {
+		env := make(map[string]string, n)
+		for i := range n {
+			env[fmt.Sprintf("%s_%d", prefix, i)] = "1"
+		}
+		return env
+	}
+
+	for _, tt := range []struct {
+		name     string
+		env      map[string]string
+		envFile  map[string]string
+		defaults map[string]string
+		err      string
+	}{
+		{
+			name:    "count",
+			env:     vars("TASK", maxEnvVars/2+1),
+			envFile: vars("FILE", maxEnvVars/2+1),
+			err:     fmt.Sprintf("too many env vars: %d (limit %d)", maxEnvVars+2, maxEnvVars),
+		},
+		{
+			name:     "payload",
+			env:      map[string]string{"TASK": strings.Repeat("x", maxEnvPayloadBytes*2/3)},
+			defaults: map[string]string{"DEFAULT": strings.Repeat("x", maxEnvPayloadBytes*2/3)},
+			err:      fmt.Sprintf("(limit %d)", maxEnvPayloadBytes),
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewPlugin(hclog.NewNullLogger()).(*ElideDriverPlugin)
+			t.Cleanup(d.Shutdown)
+			d.daemonClient = &failingSubmitClient{}
+			d.config.LanguageDefaults.Python.Env = tt.defaults
+
+			cfg := &drivers.TaskConfig{ID: "task-1", JobID: "job", Namespace: "default", AllocDir: t.TempDir()}
+			taskConfig := &TaskConfig{Language: "python", Code: "print(1)", Env: tt.env}
+			if tt.envFile != nil {
+				var lines []string
+				for name, value := range tt.envFile {
+					lines = append(lines, name+"="+value)
+				}
+				require.NoError(t, os.WriteFile(filepath.Join(cfg.TaskDir().Dir, ".env"), []byte(strings.Join(lines, "\n")), 0o644))
+				taskConfig.EnvFile = ".env"
+			}
+			require.NoError(t, cfg.EncodeConcreteDriverConfig(taskConfig))
+
+			// Each part is within the limits; the task fails before it is
+			// submitted, without holding a slot
+			_, _, err := d.StartTask(cfg)
+			require.ErrorContains(t, err, "task env is too large once env_file, language defaults, imports and credentials are merged")
+			assert.ErrorContains(t, err, tt.err)
+			assert.Zero(t, d.admission.global.active)
+		})
+	}
+}
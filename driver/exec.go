@@ -0,0 +1,182 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/nomad/plugins/drivers"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/elide-dev/elide-task-driver/proto/gen/go/elide/daemon/v1alpha1"
+)
+
+const (
+	// execPollInterval is the interval between status polls of exec commands
+	// run as executions, which are short-lived and often on the path of
+	// script health checks
+	execPollInterval = 100 * time.Millisecond
+
+	// defaultExecTimeout bounds exec commands that come without a timeout,
+	// such as those of `nomad alloc exec`
+	defaultExecTimeout = time.Minute
+)
+
+// ExecTask runs a command inside a task (e.g. a script check) when exec is
+// enabled. The command is joined into a snippet of the task's language and
+// run in the task's session, with the task's environment, user and mounts; it
+// is killed if it does not complete within the timeout.
+func (d *ElideDriverPlugin) ExecTask(taskID string, cmd []string, timeout time.Duration) (*drivers.ExecTaskResult, error) {
+	if timeout <= 0 {
+		timeout = d.statusTimeout()
+	}
+	ctx, cancel := context.WithTimeout(d.ctx, timeout)
+	defer cancel()
+
+	return d.execInTask(ctx, taskID, cmd, timeout)
+}
+
+// ExecTaskStreaming runs a command inside a task for `nomad alloc exec`, like
+// ExecTask. Commands run non-interactively: stdin and the terminal size are
+// ignored, and the output is written once the command completes.
+func (d *ElideDriverPlugin) ExecTaskStreaming(ctx context.Context, taskID string, opts *drivers.ExecOptions) (*drivers.ExitResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultExecTimeout)
+	defer cancel()
+
+	result, err := d.execInTask(ctx, taskID, opts.Command, defaultExecTimeout)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := opts.Stdout.Write(result.Stdout); err != nil {
+		return nil, fmt.Errorf("failed to write exec output: %w", err)
+	}
+	if _, err := opts.Stderr.Write(result.Stderr); err != nil {
+		return nil, fmt.Errorf("failed to write exec output: %w", err)
+	}
+	return result.ExitResult, nil
+}
+
+// execInTask runs a command in a task's session with ExecInSession, or as an
+// execution of the session if the daemon does not support it.
+func (d *ElideDriverPlugin) execInTask(ctx context.Context, taskID string, cmd []string, timeout time.Duration) (*drivers.ExecTaskResult, error) {
+	if !d.config.EnableExec {
+		return nil, errors.New("exec is disabled (enable_exec = false)")
+	}
+	if len(cmd) == 0 {
+		return nil, errors.New("exec command is empty")
+	}
+	handle, ok := d.tasks.Get(taskID)
+	if !ok {
+		return nil, drivers.ErrTaskNotFound
+	}
+	if handle.queue != nil {
+		return nil, errTaskQueued
+	}
+	if d.daemonClient == nil {
+		return nil, errors.New("daemon client not initialized")
+	}
+
+	var taskConfig TaskConfig
+	if err := handle.taskConfig.DecodeDriverConfig(&taskConfig); err != nil {
+		return nil, fmt.Errorf("failed to decode task config: %w", err)
+	}
+	language, err := taskConfig.InferLanguage()
+	if err != nil {
+		return nil, fmt.Errorf("invalid task config: %w", err)
+	}
+
+	handle.stateLock.RLock()
+	sessionID := handle.sessionId
+	handle.stateLock.RUnlock()
+	code := strings.Join(cmd, " ")
+
+	if !d.execInSessionUnsupported.Load() {
+		resp, err := d.daemonClient.ExecInSession(
+			ctx,
+			sessionID,
+			code,
+			language,
+			handle.taskConfig.Env,
+			timeout,
+			handle.taskConfig.User,
+			d.taskMounts(handle.taskConfig, ""),
+			executionTags(handle.taskConfig),
+		)
+		switch {
+		case status.Code(err) == codes.Unimplemented:
+			if d.execInSessionUnsupported.CompareAndSwap(false, true) {
+				d.logger.Info("daemon does not support ExecInSession, running exec commands as executions instead")
+			}
+		case err != nil:
+			return nil, fmt.Errorf("failed to execute command: %w", err)
+		case resp.TimedOut:
+			return nil, fmt.Errorf("exec command did not complete within %s", timeout)
+		default:
+			result := &drivers.ExecTaskResult{
+				Stdout:     []byte(resp.Stdout),
+				Stderr:     []byte(resp.Stderr),
+				ExitResult: &drivers.ExitResult{ExitCode: int(resp.ExitCode)},
+			}
+			if resp.Error != "" {
+				result.ExitResult.Err = errors.New(resp.Error)
+			}
+			return result, nil
+		}
+	}
+
+	return d.execAsExecution(ctx, handle, sessionID, code, language, timeout)
+}
+
+// execAsExecution runs an exec command as an execution of the task's session
+// and polls it until it completes, cancelling it once ctx ends.
+func (d *ElideDriverPlugin) execAsExecution(ctx context.Context, handle *taskHandle, sessionID, code, language string, timeout time.Duration) (*drivers.ExecTaskResult, error) {
+	executionID := fmt.Sprintf("%s-exec-%d", handle.taskConfig.ID, time.Now().UnixNano())
+
+	_, err := d.daemonClient.ExecuteSnippet(ctx, ExecuteSnippetRequest{
+		SessionID:   sessionID,
+		ExecutionID: executionID,
+		Code:        code,
+		Language:    language,
+		Env:         handle.taskConfig.Env,
+		RunAs:       handle.taskConfig.User,
+		Mounts:      d.taskMounts(handle.taskConfig, ""),
+		Tags:        executionTags(handle.taskConfig),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute command: %w", err)
+	}
+
+	ticker := time.NewTicker(execPollInterval)
+	defer ticker.Stop()
+	for {
+		statusResp, err := d.pollStatus(ctx, sessionID, executionID, OutputRequest{Omit: d.supportsOutputOffsets.Load()})
+		if err == nil && statusResp.Complete {
+			return &drivers.ExecTaskResult{
+				Stdout:     []byte(statusResp.Stdout),
+				Stderr:     []byte(statusResp.Stderr),
+				ExitResult: &drivers.ExitResult{ExitCode: int(statusResp.ExitCode)},
+			}, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			cancelCtx, cancelCancel := d.withTimeout(d.ctx, d.cancelTimeout())
+			reason := pb.CancellationReason_CANCELLATION_REASON_TIMEOUT
+			if _, err := d.daemonClient.CancelExecution(cancelCtx, sessionID, executionID, reason, initiatorDriver, CancelToken(executionID, reason, initiatorDriver)); err != nil {
+				handle.logger.Warn("failed to cancel timed out exec command", "execution_id", executionID, "error", err)
+			}
+			cancelCancel()
+			if err != nil {
+				return nil, fmt.Errorf("exec command did not complete within %s: %w", timeout, err)
+			}
+			return nil, fmt.Errorf("exec command did not complete within %s", timeout)
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+)
+
+const (
+	// faultsEnvVar is the environment variable read by chaos builds to
+	// configure fault injection, e.g.
+	// ELIDE_DRIVER_FAULTS="drop_status=0.25,submit_delay=5s,fail_recovery=true"
+	faultsEnvVar = "ELIDE_DRIVER_FAULTS"
+)
+
+// errInjectedFault is returned by operations failed on purpose by the fault
+// injector.
+var errInjectedFault = errors.New("injected fault")
+
+// faultInjector deliberately degrades driver behavior so operators can run
+// game days against Nomad. It is only active in binaries built with the
+// "chaos" build tag and when faultsEnvVar is set; otherwise it is nil and
+// every hook is a no-op.
+type faultInjector struct {
+	// dropStatus is the probability (0..1) that a status poll result is
+	// discarded instead of being applied to the task handle.
+	dropStatus float64
+
+	// submitDelay is added before every ExecuteSnippet call.
+	submitDelay time.Duration
+
+	// failRecovery makes every RecoverTask call fail.
+	failRecovery bool
+}
+
+// loadFaultInjector returns the configured fault injector, or nil if fault
+// injection is disabled.
+func loadFaultInjector(logger hclog.Logger) *faultInjector {
+	if !faultInjectionEnabled {
+		return nil
+	}
+
+	spec := os.Getenv(faultsEnvVar)
+	if spec == "" {
+		return nil
+	}
+
+	f, err := parseFaultSpec(spec)
+	if err != nil {
+		logger.Error("ignoring invalid fault injection spec", "env", faultsEnvVar, "error", err)
+		return nil
+	}
+
+	logger.Warn("fault injection enabled", "drop_status", f.dropStatus, "submit_delay", f.submitDelay, "fail_recovery", f.failRecovery)
+	return f
+}
+
+// parseFaultSpec parses a comma separated list of key=value fault settings.
+func parseFaultSpec(spec string) (*faultInjector, error) {
+	f := &faultInjector{}
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("fault %q must be in key=value form", part)
+		}
+
+		var err error
+		switch key {
+		case "drop_status":
+			f.dropStatus, err = strconv.ParseFloat(value, 64)
+			if err == nil && (f.dropStatus < 0 || f.dropStatus > 1) {
+				err = errors.New("must be between 0 and 1")
+			}
+		case "submit_delay":
+			f.submitDelay, err = time.ParseDuration(value)
+		case "fail_recovery":
+			f.failRecovery, err = strconv.ParseBool(value)
+		default:
+			return nil, fmt.Errorf("unknown fault %q", key)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for fault %q: %w", key, err)
+		}
+	}
+	return f, nil
+}
+
+// shouldDropStatus reports whether the current status update should be
+// discarded.
+func (f *faultInjector) shouldDropStatus() bool {
+	return f != nil && f.dropStatus > 0 && rand.Float64() < f.dropStatus
+}
+
+// delaySubmit blocks for the configured submit delay or until ctx is done.
+func (f *faultInjector) delaySubmit(ctx context.Context) error {
+	if f == nil || f.submitDelay <= 0 {
+		return nil
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(f.submitDelay):
+		return nil
+	}
+}
+
+// recoveryError returns an error if task recovery should fail.
+func (f *faultInjector) recoveryError() error {
+	if f != nil && f.failRecovery {
+		return fmt.Errorf("task recovery failed: %w", errInjectedFault)
+	}
+	return nil
+}
@@ -0,0 +1,9 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build chaos
+
+package driver
+
+// faultInjectionEnabled allows ELIDE_DRIVER_FAULTS to take effect.
+const faultInjectionEnabled = true
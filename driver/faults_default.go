@@ -0,0 +1,10 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !chaos
+
+package driver
+
+// faultInjectionEnabled is false in regular builds so that fault injection
+// can never be turned on in production by environment alone.
+const faultInjectionEnabled = false
@@ -0,0 +1,28 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !chaos
+
+package driver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadFaultInjector_DisabledByDefault(t *testing.T) {
+	t.Setenv(faultsEnvVar, "drop_status=1,submit_delay=1h,fail_recovery=true")
+
+	f := loadFaultInjector(hclog.NewNullLogger())
+	assert.Nil(t, f, "regular builds ignore the fault spec")
+
+	// Every hook of the disabled injector is a no-op
+	assert.False(t, f.shouldDropStatus())
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.NoError(t, f.delaySubmit(ctx))
+	assert.NoError(t, f.recoveryError())
+}
@@ -0,0 +1,61 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFaultSpec(t *testing.T) {
+	tests := []struct {
+		spec string
+		want faultInjector
+	}{
+		{"", faultInjector{}},
+		{"drop_status=0.25", faultInjector{dropStatus: 0.25}},
+		{"drop_status=0", faultInjector{}},
+		{"drop_status=1", faultInjector{dropStatus: 1}},
+		{"submit_delay=5s", faultInjector{submitDelay: 5 * time.Second}},
+		{"fail_recovery=true", faultInjector{failRecovery: true}},
+		{
+			"drop_status=0.5, submit_delay=250ms ,fail_recovery=1,",
+			faultInjector{dropStatus: 0.5, submitDelay: 250 * time.Millisecond, failRecovery: true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			f, err := parseFaultSpec(tt.spec)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, *f)
+		})
+	}
+}
+
+func TestParseFaultSpec_Invalid(t *testing.T) {
+	tests := []struct {
+		spec string
+		err  string
+	}{
+		{"drop_status", "key=value form"},
+		{"drop_status=0.1,submit_delay", "key=value form"},
+		{"drop_everything=true", `unknown fault "drop_everything"`},
+		{"drop_status=often", `invalid value for fault "drop_status"`},
+		{"submit_delay=5", `invalid value for fault "submit_delay"`},
+		{"fail_recovery=maybe", `invalid value for fault "fail_recovery"`},
+
+		// Rates are probabilities
+		{"drop_status=-0.1", "must be between 0 and 1"},
+		{"drop_status=1.5", "must be between 0 and 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			_, err := parseFaultSpec(tt.spec)
+			assert.ErrorContains(t, err, tt.err)
+		})
+	}
+}
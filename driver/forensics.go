@@ -0,0 +1,420 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/nomad/plugins/drivers"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/stats"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	pb "github.com/elide-dev/elide-task-driver/proto/gen/go/elide/daemon/v1alpha1"
+)
+
+const (
+	// forensicsDirName is the directory of state_dir holding forensic bundles
+	forensicsDirName = "forensics"
+
+	// defaultForensicsHistory is how many entries of each kind are kept for
+	// forensic bundles if forensics.history is unset
+	defaultForensicsHistory = 20
+
+	// defaultForensicsMaxBundles is how many bundles are kept in state_dir if
+	// forensics.max_bundles is unset
+	defaultForensicsMaxBundles = 10
+
+	// forensicsMinInterval spaces out bundles, so one incident, such as a
+	// daemon crash followed by the loss of its sessions, yields one bundle
+	forensicsMinInterval = time.Minute
+
+	// metricForensicBundles counts forensic bundles written, by trigger
+	metricForensicBundles = "forensic_bundles_total"
+)
+
+// Triggers of a forensic capture
+const (
+	forensicsTriggerDaemonDown  = "daemon_unreachable"
+	forensicsTriggerSessionLost = "session_lost"
+)
+
+// ForensicsConfig configures the forensic bundles captured when a session is
+// lost or the daemon becomes unreachable. Bundles are written to state_dir.
+type ForensicsConfig struct {
+	// History is how many status responses per task, fingerprints, health
+	// events and connection events are kept (0 = default, -1 = disabled)
+	History int `codec:"history"`
+	// MaxBundles is how many bundles are kept, oldest removed first
+	MaxBundles int `codec:"max_bundles"`
+}
+
+// Validate checks the forensics block.
+func (c *ForensicsConfig) Validate() error {
+	if c.History < -1 {
+		return fmt.Errorf("invalid history %d (must be -1 to disable, or at least 0)", c.History)
+	}
+	if c.MaxBundles < 0 {
+		return fmt.Errorf("max_bundles cannot be negative")
+	}
+	return nil
+}
+
+// history returns how many entries of each kind are kept, 0 if disabled.
+func (c *ForensicsConfig) history() int {
+	switch {
+	case c.History < 0:
+		return 0
+	case c.History == 0:
+		return defaultForensicsHistory
+	}
+	return c.History
+}
+
+// maxBundles returns how many bundles are kept.
+func (c *ForensicsConfig) maxBundles() int {
+	if c.MaxBundles == 0 {
+		return defaultForensicsMaxBundles
+	}
+	return c.MaxBundles
+}
+
+// forensicStatus is a status response of an execution as kept for forensic
+// bundles, without its output.
+type forensicStatus struct {
+	At     time.Time       `json:"at"`
+	Status json.RawMessage `json:"status"`
+}
+
+// forensicFingerprint is a fingerprint the driver reported to Nomad.
+type forensicFingerprint struct {
+	At                time.Time         `json:"at"`
+	Health            string            `json:"health"`
+	HealthDescription string            `json:"health_description"`
+	Attributes        map[string]string `json:"attributes"`
+}
+
+// forensicEvent is an entry of the daemon health timeline or of the daemon
+// connection's state transitions.
+type forensicEvent struct {
+	At     time.Time `json:"at"`
+	Event  string    `json:"event"`
+	Detail string    `json:"detail,omitempty"`
+}
+
+// forensicsRecorder keeps the recent history a forensic bundle is made of,
+// each kind in a ring of the configured size.
+type forensicsRecorder struct {
+	mu      sync.Mutex
+	history int
+
+	statuses     map[string][]forensicStatus
+	fingerprints []forensicFingerprint
+	health       []forensicEvent
+	connection   []forensicEvent
+
+	// lastHealth is the health of the last fingerprint, to record changes
+	lastHealth drivers.HealthState
+
+	// lastCapture is when the last bundle was written
+	lastCapture time.Time
+}
+
+func newForensicsRecorder(history int) *forensicsRecorder {
+	return &forensicsRecorder{history: history, statuses: map[string][]forensicStatus{}}
+}
+
+// appendRing appends entry to ring, dropping its oldest entries beyond size.
+func appendRing[T any](ring []T, entry T, size int) []T {
+	ring = append(ring, entry)
+	if len(ring) > size {
+		ring = slices.Delete(ring, 0, len(ring)-size)
+	}
+	return ring
+}
+
+// recordStatus keeps a status response of a task's execution, without its
+// output.
+func (r *forensicsRecorder) recordStatus(taskID string, statusResp *pb.GetExecutionStatusResponse) {
+	if r.history == 0 {
+		return
+	}
+	stripped := proto.Clone(statusResp).(*pb.GetExecutionStatusResponse)
+	stripped.Stdout, stripped.Stderr = "", ""
+	stripped.StdoutRaw, stripped.StderrRaw = nil, nil
+	data, err := protojson.MarshalOptions{UseProtoNames: true}.Marshal(stripped)
+	if err != nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.statuses[taskID] = appendRing(r.statuses[taskID], forensicStatus{At: time.Now(), Status: data}, r.history)
+}
+
+// forgetTask drops the status history of a destroyed task.
+func (r *forensicsRecorder) forgetTask(taskID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.statuses, taskID)
+}
+
+// recordFingerprint keeps a fingerprint and adds changes of the driver's
+// health to the health timeline.
+func (r *forensicsRecorder) recordFingerprint(fp *drivers.Fingerprint) {
+	if r.history == 0 {
+		return
+	}
+	attributes := make(map[string]string, len(fp.Attributes))
+	for name, attr := range fp.Attributes {
+		attributes[name] = attr.GoString()
+	}
+	now := time.Now()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.fingerprints = appendRing(r.fingerprints, forensicFingerprint{
+		At:                now,
+		Health:            string(fp.Health),
+		HealthDescription: fp.HealthDescription,
+		Attributes:        attributes,
+	}, r.history)
+	if fp.Health != r.lastHealth {
+		r.lastHealth = fp.Health
+		r.health = appendRing(r.health, forensicEvent{At: now, Event: string(fp.Health), Detail: fp.HealthDescription}, r.history)
+	}
+}
+
+// recordHealth adds an event to the daemon health timeline.
+func (r *forensicsRecorder) recordHealth(event string, detail string) {
+	if r.history == 0 {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.health = appendRing(r.health, forensicEvent{At: time.Now(), Event: event, Detail: detail}, r.history)
+}
+
+// recordConnection adds a state transition of the daemon connection.
+func (r *forensicsRecorder) recordConnection(event string, detail string) {
+	if r.history == 0 {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.connection = appendRing(r.connection, forensicEvent{At: time.Now(), Event: event, Detail: detail}, r.history)
+}
+
+// dialOption returns the dial option recording when the daemon connection's
+// transports are established and torn down.
+func (r *forensicsRecorder) dialOption() grpc.DialOption {
+	return grpc.WithStatsHandler(&forensicsStatsHandler{recorder: r})
+}
+
+// forensicsStatsHandler records the connection events of a gRPC client.
+type forensicsStatsHandler struct {
+	recorder *forensicsRecorder
+}
+
+func (h *forensicsStatsHandler) TagRPC(ctx context.Context, _ *stats.RPCTagInfo) context.Context {
+	return ctx
+}
+
+func (h *forensicsStatsHandler) HandleRPC(context.Context, stats.RPCStats) {}
+
+func (h *forensicsStatsHandler) TagConn(ctx context.Context, info *stats.ConnTagInfo) context.Context {
+	if info.RemoteAddr == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, forensicsConnKey{}, info.RemoteAddr.String())
+}
+
+func (h *forensicsStatsHandler) HandleConn(ctx context.Context, s stats.ConnStats) {
+	addr, _ := ctx.Value(forensicsConnKey{}).(string)
+	switch s.(type) {
+	case *stats.ConnBegin:
+		h.recorder.recordConnection("connected", addr)
+	case *stats.ConnEnd:
+		h.recorder.recordConnection("disconnected", addr)
+	}
+}
+
+// forensicsConnKey is the context key of a connection's remote address
+type forensicsConnKey struct{}
+
+// forensicManifest describes what triggered a forensic bundle.
+type forensicManifest struct {
+	Trigger    string    `json:"trigger"`
+	Reason     string    `json:"reason"`
+	SessionID  string    `json:"session_id,omitempty"`
+	CapturedAt time.Time `json:"captured_at"`
+	Tasks      []string  `json:"tasks"`
+}
+
+// ForensicBundleName returns the file name of a bundle captured at the given
+// time, which sorts bundles by age.
+func ForensicBundleName(trigger string, at time.Time) string {
+	return fmt.Sprintf("%s-%s.tar.gz", at.UTC().Format("20060102T150405.000Z"), trigger)
+}
+
+// capture writes a forensic bundle of the recorded history to dir and returns
+// its path. It returns "" without writing if a bundle was written less than
+// forensicsMinInterval ago.
+func (r *forensicsRecorder) capture(dir string, manifest forensicManifest) (string, error) {
+	r.mu.Lock()
+	if r.history == 0 || (!r.lastCapture.IsZero() && manifest.CapturedAt.Sub(r.lastCapture) < forensicsMinInterval) {
+		r.mu.Unlock()
+		return "", nil
+	}
+	r.lastCapture = manifest.CapturedAt
+
+	files := map[string]any{
+		"fingerprints.json": slices.Clone(r.fingerprints),
+		"health.json":       slices.Clone(r.health),
+		"connection.json":   slices.Clone(r.connection),
+	}
+	for taskID, statuses := range r.statuses {
+		manifest.Tasks = append(manifest.Tasks, taskID)
+		files["status/"+strings.ReplaceAll(taskID, "/", "_")+".json"] = slices.Clone(statuses)
+	}
+	r.mu.Unlock()
+
+	slices.Sort(manifest.Tasks)
+	files["manifest.json"] = manifest
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create forensics directory: %w", err)
+	}
+	path := filepath.Join(dir, ForensicBundleName(manifest.Trigger, manifest.CapturedAt))
+	if err := writeForensicBundle(path+".tmp", files, manifest.CapturedAt); err != nil {
+		os.Remove(path + ".tmp")
+		return "", err
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return "", fmt.Errorf("failed to write forensic bundle: %w", err)
+	}
+	return path, nil
+}
+
+// writeForensicBundle writes files, encoded as JSON, to a gzipped tarball.
+func writeForensicBundle(path string, files map[string]any, modTime time.Time) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create forensic bundle: %w", err)
+	}
+	defer f.Close()
+
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		data, err := json.MarshalIndent(files[name], "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode %s: %w", name, err)
+		}
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0600, Size: int64(len(data)), ModTime: modTime}); err != nil {
+			return fmt.Errorf("failed to write forensic bundle: %w", err)
+		}
+		if _, err := tw.Write(data); err != nil {
+			return fmt.Errorf("failed to write forensic bundle: %w", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to write forensic bundle: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("failed to write forensic bundle: %w", err)
+	}
+	return f.Close()
+}
+
+// PruneForensicBundles removes the oldest bundles in dir beyond keep.
+func PruneForensicBundles(dir string, keep int) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	var bundles []string
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && strings.HasSuffix(entry.Name(), ".tar.gz") {
+			bundles = append(bundles, entry.Name())
+		}
+	}
+	slices.Sort(bundles)
+	for _, name := range bundles[:max(len(bundles)-keep, 0)] {
+		if err := os.Remove(filepath.Join(dir, name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// captureForensics writes a forensic bundle to <state_dir>/forensics after a
+// session was lost or the daemon became unreachable, and tells the affected
+// running tasks where it is: the tasks of sessionID, or all of them if it is
+// empty. Nothing is captured without state_dir.
+func (d *ElideDriverPlugin) captureForensics(trigger string, sessionID string, reason error) {
+	if d.config.StateDir == "" {
+		return
+	}
+	dir := filepath.Join(d.config.StateDir, forensicsDirName)
+	path, err := d.forensics.capture(dir, forensicManifest{
+		Trigger:    trigger,
+		Reason:     reason.Error(),
+		SessionID:  sessionID,
+		CapturedAt: time.Now(),
+	})
+	if err != nil {
+		d.logger.Warn("failed to capture forensic bundle", "trigger", trigger, "error", err)
+		return
+	}
+	if path == "" {
+		d.logger.Debug("forensic bundle captured recently, skipping", "trigger", trigger)
+		return
+	}
+	if err := PruneForensicBundles(dir, d.config.Forensics.maxBundles()); err != nil {
+		d.logger.Warn("failed to prune forensic bundles", "error", err)
+	}
+
+	d.logger.Warn("captured forensic bundle", "trigger", trigger, "session_id", sessionID, "path", path)
+	d.metrics.IncrCounter(metricForensicBundles, "Forensic bundles captured, by trigger.", "trigger", trigger)
+	for _, h := range d.tasks.List() {
+		if !h.IsRunning() || (sessionID != "" && h.sessionId != sessionID) {
+			continue
+		}
+		d.eventer.EmitEvent(&drivers.TaskEvent{
+			TaskID:    h.taskConfig.ID,
+			AllocID:   h.taskConfig.AllocID,
+			TaskName:  h.taskConfig.Name,
+			Timestamp: time.Now(),
+			Message:   fmt.Sprintf("Captured forensic bundle after %s: %s", strings.ReplaceAll(trigger, "_", " "), path),
+			Annotations: map[string]string{
+				"forensic_bundle": path,
+				"trigger":         trigger,
+			},
+		})
+	}
+}
+
+// sessionLostForensics records the loss of a session in the health timeline
+// and captures a forensic bundle for the tasks that ran in it.
+func (d *ElideDriverPlugin) sessionLostForensics(sessionID string, reason error) {
+	d.forensics.recordHealth(forensicsTriggerSessionLost, fmt.Sprintf("%s: %v", sessionID, reason))
+	d.captureForensics(forensicsTriggerSessionLost, sessionID, reason)
+}
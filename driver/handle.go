@@ -4,11 +4,18 @@
 package driver
 
 import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/hashicorp/go-hclog"
 	"github.com/hashicorp/nomad/plugins/drivers"
+	"go.opentelemetry.io/otel/trace"
+
+	pb "github.com/elide-dev/elide-task-driver/proto/gen/go/elide/daemon/v1alpha1"
 )
 
 // taskHandle stores runtime information for a running task
@@ -24,8 +31,103 @@ type taskHandle struct {
 
 	// Execution tracking
 	executionId string // Execution ID from Elide daemon
-	sessionId  string // Session ID (one per Nomad client)
-	status     string // Current execution status (running, completed, failed)
+	sessionId   string // Session ID (one per Nomad client)
+	status      string // Current execution status (running, completed, failed)
+
+	// Span context of the task's StartTask span, the parent of its WaitTask
+	// and StopTask spans (invalid without tracing)
+	traceContext trace.SpanContext
+
+	// Daemon-side view of the execution, reported verbatim
+	statusCode    pb.ExecutionStatus
+	daemonStatus  string    // Raw execution status from the daemon
+	daemonMessage string    // Progress/status detail from the daemon
+	queuedAt      time.Time // When the daemon queued the execution
+
+	// Where a queued execution waits in its session: its position in the
+	// queue and the executions it waits for (0 while not queued)
+	queuePosition   uint32
+	executionsAhead uint32
+
+	// language is the language the execution runs
+	language string
+
+	// profile is the sandbox profile the execution runs under
+	profile string
+
+	// sessionProfile is the session profile whose session runs the execution
+	// (empty for the default session)
+	sessionProfile string
+
+	// sessionScope is the alloc or task scope of the session running the
+	// execution when session_per is "alloc" or "task"
+	sessionScope string
+
+	// exports are the session KV keys set from the result on completion
+	exports []string
+
+	// outputMode is where the execution's output is shipped on completion
+	outputMode string
+
+	// logs ships output to the task's log streams while the execution runs
+	logs logShipper
+
+	// codeHash is the SHA-256 of the code the execution was submitted with
+	codeHash string
+
+	// matrix holds the executions of an args_matrix task (nil otherwise);
+	// executionId is then the first entry's
+	matrix       []*MatrixEntry
+	matrixPolicy string
+
+	// releaseSlot returns the admission slot held by this task
+	releaseSlot func()
+
+	// queue is set for a task queued while the daemon was unreachable; the
+	// handle is replaced by the submitted task's once its execution starts
+	queue *queuedSubmission
+
+	// Driver-enforced limits
+	deadline     time.Time     // Execution deadline (zero = none)
+	pollInterval time.Duration // Status polling interval
+
+	// stopping is set once Nomad asked to stop the task
+	stopping bool
+
+	// Why the execution was cancelled and by whom (unset if it was not)
+	cancelReason pb.CancellationReason
+	cancelledBy  string
+	cancelErr    error // Reported as the task's exit error
+
+	// Scratch directory of the execution (empty if not requested)
+	scratchDir   string
+	scratchQuota int64 // Quota in bytes (0 = unlimited)
+
+	// memoryReservation is the memory each execution reserves in its
+	// session, in MB
+	memoryReservation int
+
+	// network is where the execution serves its ports, returned to Nomad
+	// for service registration (nil without ports)
+	network *drivers.DriverNetwork
+
+	// resultSchema is the result_schema results are validated against, and
+	// resultSchemaPolicy what a mismatch does (nil and empty without one)
+	resultSchema       *ResultSchema
+	resultSchemaPolicy string
+
+	// completion is the final status of an execution the daemon reported
+	// complete in its ExecuteSnippet response, until WaitTask applies it
+	completion *pb.GetExecutionStatusResponse
+
+	// sessionLost is set on a recovered task whose session the daemon lost,
+	// taking its execution with it: there is nothing left to follow
+	sessionLost bool
+
+	// Execution result, inline or spilled to a file if too large
+	result     string
+	resultFile string
+	resultSize int
 }
 
 // TaskStatus returns the current status of the task
@@ -33,28 +135,258 @@ func (h *taskHandle) TaskStatus() *drivers.TaskStatus {
 	h.stateLock.RLock()
 	defer h.stateLock.RUnlock()
 
-	state := drivers.TaskStateUnknown
-	if h.exitResult == nil {
-		state = drivers.TaskStateRunning
-	} else if h.exitResult.ExitCode == 0 {
-		state = drivers.TaskStateExited
-	} else {
+	// Nomad only distinguishes running from exited tasks; whether the
+	// execution completed, failed or was cancelled is told by its exit result
+	state := drivers.TaskStateRunning
+	if h.exitResult != nil {
 		state = drivers.TaskStateExited
 	}
 
 	return &drivers.TaskStatus{
-		ID:          h.taskConfig.ID,
-		Name:        h.taskConfig.Name,
-		State:       state,
+		ID:               h.taskConfig.ID,
+		Name:             h.taskConfig.Name,
+		State:            state,
+		StartedAt:        h.startedAt,
+		CompletedAt:      h.completedAt,
+		ExitResult:       h.exitResult,
+		DriverAttributes: h.driverAttributes(),
+	}
+}
+
+// driverAttributes returns the execution details reported with the task
+// status. Callers must hold stateLock.
+func (h *taskHandle) driverAttributes() map[string]string {
+	attrs := map[string]string{
+		"execution_id":   h.executionId,
+		"session_id":     h.sessionId,
+		"status":         h.status,
+		"daemon_status":  h.daemonStatus,
+		"daemon_message": h.daemonMessage,
+	}
+	if h.profile != "" {
+		attrs["profile"] = h.profile
+	}
+	if h.sessionProfile != "" {
+		attrs["session_profile"] = h.sessionProfile
+	}
+	h.matrixAttributes(attrs)
+	if h.cancelErr != nil {
+		attrs["cancel_reason"] = cancellationReasonName(h.cancelReason)
+		attrs["cancelled_by"] = h.cancelledBy
+	}
+	if !h.queuedAt.IsZero() {
+		attrs["queued_at"] = h.queuedAt.Format(time.RFC3339Nano)
+	}
+	if h.queuePosition > 0 {
+		attrs["queue_position"] = strconv.FormatUint(uint64(h.queuePosition), 10)
+		attrs["executions_ahead"] = strconv.FormatUint(uint64(h.executionsAhead), 10)
+	}
+	if h.resultSize > 0 {
+		attrs["result_bytes"] = strconv.Itoa(h.resultSize)
+		if h.resultFile != "" {
+			attrs["result_file"] = h.resultFile
+		} else {
+			attrs["result"] = h.result
+		}
+	}
+	return attrs
+}
+
+// taskState returns the state recoverTaskState rebuilds the handle from, as
+// encoded in the task handle and snapshots. Callers must hold stateLock.
+func (h *taskHandle) taskState() *TaskState {
+	state := &TaskState{
+		TaskConfig:  h.taskConfig,
 		StartedAt:   h.startedAt,
-		CompletedAt: h.completedAt,
-		ExitResult:  h.exitResult,
-		DriverAttributes: map[string]string{
-			"execution_id": h.executionId,
-			"session_id":   h.sessionId,
-			"status":       h.status,
-		},
+		ExecutionId: h.executionId,
+		SessionId:   h.sessionId,
+		TraceParent: TraceParent(h.traceContext),
+
+		Language:       h.language,
+		Profile:        h.profile,
+		SessionProfile: h.sessionProfile,
+		SessionScope:   h.sessionScope,
+		Exports:        h.exports,
+		OutputMode:     h.outputMode,
+		BinaryOutput:   h.logs.binaryOutput,
+		CombineOutput:  h.logs.combined,
+		CodeHash:       h.codeHash,
+		Matrix:         h.matrixCopy(),
+		MatrixPolicy:   h.matrixPolicy,
+		Deadline:       h.deadline,
+		PollInterval:   h.pollInterval,
+
+		ScratchDir:     h.scratchDir,
+		ScratchQuotaMB: int(h.scratchQuota >> 20),
+
+		ResultSchemaPolicy: h.resultSchemaPolicy,
+
+		MemoryReservationMB: h.memoryReservation,
+
+		Queued: h.queue != nil,
+	}
+	if h.resultSchema != nil {
+		state.ResultSchema = h.resultSchema.Source
+	}
+	return state
+}
+
+// updateStatus records the daemon's view of the execution.
+func (h *taskHandle) updateStatus(status pb.ExecutionStatus, message string, queuedAtMillis int64) {
+	h.stateLock.Lock()
+	defer h.stateLock.Unlock()
+
+	// Status names are only rebuilt when the status changes, not every poll
+	if status != h.statusCode || h.status == "" {
+		h.statusCode = status
+		h.status = executionStatusName(status)
+		h.daemonStatus = status.String()
 	}
+	h.daemonMessage = message
+	if queuedAtMillis > 0 {
+		h.queuedAt = time.UnixMilli(queuedAtMillis)
+	}
+}
+
+// updateQueue records where the execution waits in its session's queue, as
+// reported with a status; both are 0 once it left the queue.
+func (h *taskHandle) updateQueue(statusResp *pb.GetExecutionStatusResponse) {
+	h.stateLock.Lock()
+	defer h.stateLock.Unlock()
+	if statusResp.Status != pb.ExecutionStatus_EXECUTION_STATUS_QUEUED {
+		h.queuePosition, h.executionsAhead = 0, 0
+		return
+	}
+	h.queuePosition, h.executionsAhead = statusResp.QueuePosition, statusResp.ExecutionsAhead
+}
+
+// executionStatusName returns the short, lower-case name of an execution
+// status, e.g. "running" for EXECUTION_STATUS_RUNNING.
+func executionStatusName(status pb.ExecutionStatus) string {
+	return strings.ToLower(strings.TrimPrefix(status.String(), "EXECUTION_STATUS_"))
+}
+
+// cancellationReasonName returns the short, lowercase name of a reason, e.g.
+// "user_stop" for CANCELLATION_REASON_USER_STOP.
+func cancellationReasonName(reason pb.CancellationReason) string {
+	return strings.ToLower(strings.TrimPrefix(reason.String(), "CANCELLATION_REASON_"))
+}
+
+// markCancelled records why the execution is being cancelled and by whom.
+// Only the first cancellation is kept; it returns false if one was already
+// recorded.
+func (h *taskHandle) markCancelled(reason pb.CancellationReason, initiator string, cause error) bool {
+	h.stateLock.Lock()
+	defer h.stateLock.Unlock()
+
+	if h.cancelErr != nil {
+		return false
+	}
+	h.cancelReason = reason
+	h.cancelledBy = initiator
+	if cause != nil {
+		h.cancelErr = fmt.Errorf("execution cancelled (%s, initiated by %s): %w", cancellationReasonName(reason), initiator, cause)
+	} else {
+		h.cancelErr = fmt.Errorf("execution cancelled (%s, initiated by %s)", cancellationReasonName(reason), initiator)
+	}
+	return true
+}
+
+// clearCancelled drops the recorded cancellation, when the cancel request
+// found nothing to cancel.
+func (h *taskHandle) clearCancelled() {
+	h.stateLock.Lock()
+	defer h.stateLock.Unlock()
+
+	h.cancelErr = nil
+	h.cancelReason = pb.CancellationReason_CANCELLATION_REASON_UNSPECIFIED
+	h.cancelledBy = ""
+}
+
+// CancelToken returns the idempotency token of a cancel request. It depends
+// only on the execution and the recorded cancellation, so every retry of a
+// cancellation, including after a plugin restart, carries the same token.
+func CancelToken(executionID string, reason pb.CancellationReason, initiator string) string {
+	return executionID + "/" + cancellationReasonName(reason) + "/" + initiator
+}
+
+// isCancelled reports whether a cancellation has been recorded.
+func (h *taskHandle) isCancelled() bool {
+	h.stateLock.RLock()
+	defer h.stateLock.RUnlock()
+	return h.cancelErr != nil
+}
+
+// exitError returns the error to report for a completed execution. A
+// cancellation, whether requested by the driver or reported by the daemon
+// (e.g. OOM), takes precedence over the daemon's error message.
+func (h *taskHandle) exitError(status *pb.GetExecutionStatusResponse) error {
+	var daemonErr error
+	if status.Error != "" {
+		daemonErr = errors.New(status.Error)
+	}
+
+	if reason := status.GetCancellationReason(); reason != pb.CancellationReason_CANCELLATION_REASON_UNSPECIFIED {
+		initiator := status.GetCancelledBy()
+		if initiator == "" {
+			initiator = "daemon"
+		}
+		h.markCancelled(reason, initiator, daemonErr)
+	}
+
+	h.stateLock.RLock()
+	defer h.stateLock.RUnlock()
+	if h.cancelErr != nil {
+		return h.cancelErr
+	}
+	return daemonErr
+}
+
+// completionResult returns the exit result of a completed execution.
+func (h *taskHandle) completionResult(status *pb.GetExecutionStatusResponse) *drivers.ExitResult {
+	result := ExitResultFor(status.Status, status.ExitCode, h.exitError(status))
+	result.OOMKilled = h.oomKilled()
+	return result
+}
+
+// ExitResultFor maps the final status of an execution to a Nomad exit result,
+// so that only executions the daemon reports as completed can succeed:
+//
+//   - COMPLETED reports the exit code and error as they are.
+//   - FAILED always fails: a zero exit code becomes 1, and a missing error
+//     "execution failed".
+//   - CANCELLED always fails with the cancellation error, or "execution
+//     cancelled" if none was recorded.
+//   - Any other status fails with an error naming it.
+//
+// Exit codes above 128 of failed or cancelled executions are reported as the
+// signal that terminated them, as shells do.
+func ExitResultFor(status pb.ExecutionStatus, exitCode int32, err error) *drivers.ExitResult {
+	result := &drivers.ExitResult{ExitCode: int(exitCode), Err: err}
+	switch status {
+	case pb.ExecutionStatus_EXECUTION_STATUS_COMPLETED:
+		return result
+	case pb.ExecutionStatus_EXECUTION_STATUS_FAILED:
+		if result.ExitCode == 0 {
+			result.ExitCode = 1
+		}
+		if result.Err == nil {
+			result.Err = errors.New("execution failed")
+		}
+	case pb.ExecutionStatus_EXECUTION_STATUS_CANCELLED:
+		if result.Err == nil {
+			result.Err = errors.New("execution cancelled")
+		}
+	default:
+		if result.Err == nil {
+			result.Err = fmt.Errorf("execution completed with status %s", executionStatusName(status))
+		}
+		return result
+	}
+	if exitCode > 128 && exitCode < 128+65 {
+		result.Signal = int(exitCode - 128)
+	}
+	return result
 }
 
 // IsRunning returns whether the task is currently running
@@ -71,10 +403,13 @@ func (h *taskHandle) SetCompleted(result *drivers.ExitResult) {
 
 	h.exitResult = result
 	h.completedAt = time.Now()
+
+	if h.releaseSlot != nil {
+		h.releaseSlot()
+	}
 }
 
 // TODO: Once daemon API is available, add methods for:
 // - Polling execution status from daemon
 // - Updating status based on daemon responses
 // - Handling cancellation
-
@@ -0,0 +1,109 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/nomad/plugins/drivers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/elide-dev/elide-task-driver/proto/gen/go/elide/daemon/v1alpha1"
+)
+
+// statusReportingClient reports the status it is set to, and is unreachable
+// while down is set.
+type statusReportingClient struct {
+	flakyDaemonClient
+
+	status atomic.Pointer[pb.GetExecutionStatusResponse]
+}
+
+func (c *statusReportingClient) GetExecutionStatus(ctx context.Context, sessionID string, executionID string, output OutputRequest) (*pb.GetExecutionStatusResponse, error) {
+	if c.down.Load() {
+		return nil, status.Error(codes.Unavailable, "connection refused")
+	}
+	return c.status.Load(), nil
+}
+
+func TestDriverAttributes_FollowStatusAcrossReconnection(t *testing.T) {
+	d := NewPlugin(hclog.NewNullLogger()).(*ElideDriverPlugin)
+	t.Cleanup(d.Shutdown)
+	client := &statusReportingClient{}
+	d.daemonClient = client
+	d.sessionID = "session-1"
+
+	h := d.recoveredHandle(&TaskState{
+		TaskConfig:  &drivers.TaskConfig{ID: "task-1"},
+		ExecutionId: "exec-1",
+		SessionId:   "session-1",
+		StartedAt:   time.Now(),
+	})
+	d.tasks.Set("task-1", h)
+
+	// attributes returns the daemon attributes of both InspectTask and
+	// TaskStatus, which must agree
+	attributes := func() map[string]string {
+		inspected, err := d.InspectTask("task-1")
+		require.NoError(t, err)
+		assert.Equal(t, inspected.DriverAttributes, h.TaskStatus().DriverAttributes)
+		attrs := map[string]string{}
+		for _, key := range []string{"daemon_status", "daemon_message", "queued_at"} {
+			if value, ok := inspected.DriverAttributes[key]; ok {
+				attrs[key] = value
+			}
+		}
+		return attrs
+	}
+	var lastScratchCheck time.Time
+	poll := func() {
+		result, done := d.pollExecution(context.Background(), h, &lastScratchCheck)
+		require.False(t, done)
+		require.Nil(t, result)
+	}
+
+	queuedAt := time.Now().Add(-time.Minute).Truncate(time.Millisecond)
+	client.status.Store(&pb.GetExecutionStatusResponse{
+		ExecutionId: "exec-1",
+		Status:      pb.ExecutionStatus_EXECUTION_STATUS_QUEUED,
+		Message:     "waiting for a context",
+		QueuedAt:    queuedAt.UnixMilli(),
+	})
+	poll()
+	queued := map[string]string{
+		"daemon_status":  "EXECUTION_STATUS_QUEUED",
+		"daemon_message": "waiting for a context",
+		"queued_at":      queuedAt.Format(time.RFC3339Nano),
+	}
+	assert.Equal(t, queued, attributes())
+
+	// While the daemon is unreachable the last status it reported stays
+	client.down.Store(true)
+	poll()
+	require.True(t, d.reconnect.isDown())
+	assert.Equal(t, queued, attributes())
+
+	// Once it is back, the attributes follow its statuses again; queued_at
+	// is kept by statuses that no longer report it
+	client.status.Store(&pb.GetExecutionStatusResponse{
+		ExecutionId: "exec-1",
+		Status:      pb.ExecutionStatus_EXECUTION_STATUS_RUNNING,
+		Message:     "started",
+	})
+	client.down.Store(false)
+	require.Eventually(t, func() bool { return !d.reconnect.isDown() }, 5*time.Second, 10*time.Millisecond)
+	poll()
+	assert.Equal(t, map[string]string{
+		"daemon_status":  "EXECUTION_STATUS_RUNNING",
+		"daemon_message": "started",
+		"queued_at":      queuedAt.Format(time.RFC3339Nano),
+	}, attributes())
+}
@@ -0,0 +1,193 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"fmt"
+	"maps"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/nomad/plugins/drivers"
+	"github.com/hashicorp/nomad/plugins/shared/structs"
+)
+
+const (
+	// defaultHealthFailureThreshold is how many consecutive health checks
+	// the daemon must fail before it is reported down
+	defaultHealthFailureThreshold = 3
+
+	// readyzPath serves whether the daemon can take executions
+	readyzPath = "/readyz"
+)
+
+// Daemon health phases, reported as the driver.elide.daemon_health attribute
+const (
+	// DaemonHealthy: the daemon passed its last health check
+	DaemonHealthy = "healthy"
+
+	// DaemonStarting: the daemon has not passed a check yet, or its
+	// supervisor is starting it
+	DaemonStarting = "starting"
+
+	// DaemonFlapping: the daemon failed its last checks, but fewer than the
+	// threshold in a row; it is still reported healthy
+	DaemonFlapping = "flapping"
+
+	// DaemonDead: the daemon failed the threshold of checks in a row
+	DaemonDead = "dead"
+
+	// DaemonIncompatible: the daemon is reachable but its version does not
+	// satisfy required_daemon_version
+	DaemonIncompatible = "incompatible"
+)
+
+// healthFailureThreshold returns health_failure_threshold, with its default
+// applied.
+func (c *Config) healthFailureThreshold() int {
+	if c.HealthFailureThreshold == 0 {
+		return defaultHealthFailureThreshold
+	}
+	return c.HealthFailureThreshold
+}
+
+// DaemonHealth is the daemon's health as of its last check.
+type DaemonHealth struct {
+	Phase               string    `json:"phase"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	LastError           string    `json:"last_error,omitempty"`
+	LastErrorAt         time.Time `json:"last_error_at,omitzero"`
+}
+
+// Ready reports whether the daemon can take executions: it is healthy, or
+// only failed fewer checks than the threshold.
+func (h DaemonHealth) Ready() bool {
+	return h.Phase == DaemonHealthy || h.Phase == DaemonFlapping
+}
+
+// HealthEvaluator debounces the daemon's health checks, so a transient blip
+// of its socket does not flip the node's driver health: the daemon is only
+// reported down after failing threshold checks in a row. A daemon that never
+// passed a check, or that its supervisor is starting, is starting rather
+// than down.
+type HealthEvaluator struct {
+	threshold int
+
+	mu          sync.Mutex
+	passedCheck bool
+	health      DaemonHealth
+}
+
+// NewHealthEvaluator returns an evaluator reporting the daemon down after
+// threshold consecutive failed checks (1 on the first).
+func NewHealthEvaluator(threshold int) *HealthEvaluator {
+	return &HealthEvaluator{
+		threshold: max(threshold, 1),
+		health:    DaemonHealth{Phase: DaemonStarting},
+	}
+}
+
+// Passed records a passed health check.
+func (e *HealthEvaluator) Passed() DaemonHealth {
+	return e.reachable(DaemonHealthy, nil)
+}
+
+// Incompatible records a daemon that passed its health check but cannot be
+// used, with the reason why.
+func (e *HealthEvaluator) Incompatible(err error) DaemonHealth {
+	return e.reachable(DaemonIncompatible, err)
+}
+
+func (e *HealthEvaluator) reachable(phase string, err error) DaemonHealth {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.passedCheck = true
+	e.health.Phase = phase
+	e.health.ConsecutiveFailures = 0
+	if err != nil {
+		e.recordErrorLocked(err)
+	}
+	return e.health
+}
+
+// Failed records a failed health check. starting is whether the daemon is
+// known to be starting, e.g. by its supervisor.
+func (e *HealthEvaluator) Failed(err error, starting bool) DaemonHealth {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.health.ConsecutiveFailures++
+	e.recordErrorLocked(err)
+	switch {
+	case starting || (!e.passedCheck && e.health.ConsecutiveFailures < e.threshold):
+		e.health.Phase = DaemonStarting
+	case e.health.ConsecutiveFailures < e.threshold:
+		e.health.Phase = DaemonFlapping
+	default:
+		e.health.Phase = DaemonDead
+	}
+	return e.health
+}
+
+func (e *HealthEvaluator) recordErrorLocked(err error) {
+	e.health.LastError = err.Error()
+	e.health.LastErrorAt = time.Now().UTC()
+}
+
+// Current returns the daemon's health as of its last check.
+func (e *HealthEvaluator) Current() DaemonHealth {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.health
+}
+
+// healthAttributes reports the daemon's health as node attributes.
+func healthAttributes(fp *drivers.Fingerprint, health DaemonHealth) {
+	fp.Attributes["driver.elide.daemon_health"] = structs.NewStringAttribute(health.Phase)
+	fp.Attributes["driver.elide.daemon_health_failures"] = structs.NewIntAttribute(int64(health.ConsecutiveFailures), "")
+	if health.LastError != "" {
+		fp.Attributes["driver.elide.daemon_last_error"] = structs.NewStringAttribute(health.LastError)
+	}
+}
+
+// unreachableFingerprint returns the fingerprint of a daemon that failed its
+// health check with err, which would be reported with the given health
+// state. A daemon that only failed fewer checks than the threshold keeps its
+// last healthy fingerprint; one that is starting is undetected.
+func (d *ElideDriverPlugin) unreachableFingerprint(fp *drivers.Fingerprint, state drivers.HealthState, err error) *drivers.Fingerprint {
+	health := d.health.Failed(err, d.supervisor != nil && d.supervisor.starting())
+	switch last := d.lastHealthyFingerprint.Load(); {
+	case health.Phase == DaemonFlapping && last != nil:
+		attributes := maps.Clone(fp.Attributes)
+		fp = &drivers.Fingerprint{
+			Attributes:        maps.Clone(last.Attributes),
+			Health:            last.Health,
+			HealthDescription: fmt.Sprintf("%s (%d of %d health checks failed in a row: %v)", last.HealthDescription, health.ConsecutiveFailures, d.health.threshold, err),
+		}
+		maps.Copy(fp.Attributes, attributes)
+	case health.Phase == DaemonStarting:
+		fp.Health = drivers.HealthStateUndetected
+		fp.HealthDescription = fmt.Sprintf("daemon starting: %v", err)
+	default:
+		fp.Health = state
+		fp.HealthDescription = fmt.Sprintf("daemon down after %d failed health checks: %v", health.ConsecutiveFailures, err)
+	}
+	if d.supervisor != nil && fp.Health != drivers.HealthStateHealthy {
+		fp.HealthDescription += " (" + d.supervisor.describe() + ")"
+	}
+	healthAttributes(fp, health)
+	return fp
+}
+
+// handleReadyz serves whether the daemon can take executions, as of its last
+// health check: 200 if so, 503 if it is starting, down or incompatible.
+// /healthz reports the liveness of the plugin itself.
+func (d *ElideDriverPlugin) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	health := d.health.Current()
+	status := http.StatusOK
+	if !health.Ready() {
+		status = http.StatusServiceUnavailable
+	}
+	writeAdminJSON(w, status, health)
+}
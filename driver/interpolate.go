@@ -0,0 +1,81 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// interpolationPattern matches the variables interpolate substitutes,
+// ${env.NAME} and ${NOMAD_NAME}, and their escaped forms $${env.NAME} and
+// $${NOMAD_NAME}. Any other ${...}, such as a JavaScript template literal,
+// is left alone.
+var interpolationPattern = regexp.MustCompile(`\$?\$\{(?:env\.([A-Za-z_][A-Za-z0-9_]*)|(NOMAD_[A-Za-z0-9_]+))\}`)
+
+// InterpolateTask substitutes the task's runtime environment into its inline
+// code, args and args_matrix entries: ${env.NAME} is the value of NAME, and
+// ${NOMAD_NAME} a shorthand for ${env.NOMAD_NAME}. A variable the
+// environment does not set is an error, as is substituting more than
+// maxEnvPayloadBytes in total. $${...} escapes a variable, yielding ${...}.
+// Scripts and other code read from files are not interpolated.
+func InterpolateTask(tc *TaskConfig, env map[string]string) error {
+	i := &interpolator{env: env}
+	var err error
+	if tc.Code, err = i.interpolate("code", tc.Code); err != nil {
+		return err
+	}
+	for n, arg := range tc.Args {
+		if tc.Args[n], err = i.interpolate(fmt.Sprintf("args[%d]", n), arg); err != nil {
+			return err
+		}
+	}
+	for n, args := range tc.ArgsMatrix {
+		for m, arg := range args {
+			if args[m], err = i.interpolate(fmt.Sprintf("args_matrix[%d][%d]", n, m), arg); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// interpolator substitutes variables, counting the bytes substituted across
+// all fields of a task.
+type interpolator struct {
+	env         map[string]string
+	substituted int
+}
+
+// interpolate substitutes the variables in the value of a task config field.
+func (i *interpolator) interpolate(field string, value string) (string, error) {
+	if !strings.Contains(value, "${") {
+		return value, nil
+	}
+
+	var err error
+	result := interpolationPattern.ReplaceAllStringFunc(value, func(match string) string {
+		if err != nil {
+			return match
+		}
+		if strings.HasPrefix(match, "$$") {
+			return match[1:]
+		}
+		groups := interpolationPattern.FindStringSubmatch(match)
+		name := groups[1] + groups[2]
+		substitute, ok := i.env[name]
+		if !ok {
+			err = fmt.Errorf("failed to interpolate %s: %s is not set in the task's environment", field, match)
+			return match
+		}
+		i.substituted += len(substitute)
+		if i.substituted > maxEnvPayloadBytes {
+			err = fmt.Errorf("failed to interpolate %s: substituted variables exceed %d bytes", field, maxEnvPayloadBytes)
+			return match
+		}
+		return substitute
+	})
+	return result, err
+}
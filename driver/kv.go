@@ -0,0 +1,67 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// sessionValueKey scopes a task's KV key to its allocation, so tasks in the
+// same alloc share values while other allocs on the node cannot see them.
+func sessionValueKey(allocID string, key string) string {
+	return allocID + "/" + key
+}
+
+// importSessionValues reads the task's imports from the session KV store and
+// adds them to env. A missing key fails the task.
+func (d *ElideDriverPlugin) importSessionValues(ctx context.Context, allocID string, imports map[string]string, env map[string]string) error {
+	for name, key := range imports {
+		value, found, err := d.daemonClient.GetSessionValue(ctx, d.sessionID, sessionValueKey(allocID, key))
+		if err != nil {
+			return fmt.Errorf("failed to import %q: %w", key, err)
+		}
+		if !found {
+			return fmt.Errorf("failed to import %q: no value exported by an earlier task", key)
+		}
+		env[name] = value
+	}
+	return nil
+}
+
+// exportSessionValues stores the task's exports in the session KV store of the
+// default session, which serves tasks in every session profile. Each
+// key is set from the same-named top-level field of the JSON result; strings
+// are stored as-is and other values in their JSON encoding.
+func (d *ElideDriverPlugin) exportSessionValues(ctx context.Context, handle *taskHandle, result string) error {
+	if len(handle.exports) == 0 {
+		return nil
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(result), &fields); err != nil {
+		return fmt.Errorf("result is not a JSON object: %w", err)
+	}
+
+	for _, key := range handle.exports {
+		raw, ok := fields[key]
+		if !ok {
+			return fmt.Errorf("result has no field %q", key)
+		}
+
+		value := string(bytes.TrimSpace(raw))
+		var str string
+		if err := json.Unmarshal(raw, &str); err == nil {
+			value = str
+		}
+
+		err := d.daemonClient.PutSessionValue(ctx, d.sessionID, sessionValueKey(handle.taskConfig.AllocID, key), value)
+		if err != nil {
+			return fmt.Errorf("failed to export %q: %w", key, err)
+		}
+	}
+	return nil
+}
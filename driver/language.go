@@ -0,0 +1,62 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"fmt"
+	"path"
+	"sort"
+)
+
+// defaultLanguage is the language of inline code and of tasks whose source
+// has no file name to infer it from
+const defaultLanguage = "python"
+
+// languagesByExtension maps each source file extension to the languages
+// using it, inverted from languageExtensions
+var languagesByExtension = func() map[string][]string {
+	byExt := make(map[string][]string)
+	for language, exts := range languageExtensions {
+		for _, ext := range exts {
+			byExt[ext] = append(byExt[ext], language)
+		}
+	}
+	for _, languages := range byExt {
+		sort.Strings(languages)
+	}
+	return byExt
+}()
+
+// InferLanguage returns the task's language: language if set, otherwise
+// inferred from the extension of the script, entrypoint or
+// code_oci_entrypoint, and python for inline code. It fails if the extension
+// is unknown or used by more than one language.
+func (tc *TaskConfig) InferLanguage() (string, error) {
+	if tc.Language != "" {
+		return tc.Language, nil
+	}
+	for _, source := range []struct{ field, value string }{
+		{"script", tc.Script}, {"entrypoint", tc.Entrypoint}, {"code_oci_entrypoint", tc.CodeOCIEntrypoint},
+	} {
+		if source.value != "" {
+			return languageOfFile(source.field, source.value)
+		}
+	}
+	return defaultLanguage, nil
+}
+
+// languageOfFile infers the language of a source file from its extension.
+func languageOfFile(field string, file string) (string, error) {
+	ext := path.Ext(file)
+	languages := languagesByExtension[ext]
+	switch {
+	case ext == "":
+		return "", fmt.Errorf("cannot infer language of %s %q without a file extension; set language", field, file)
+	case len(languages) == 0:
+		return "", fmt.Errorf("cannot infer language of %s %q: unknown extension %q; set language", field, file, ext)
+	case len(languages) > 1:
+		return "", fmt.Errorf("cannot infer language of %s %q: extension %q is used by %v; set language", field, file, ext, languages)
+	}
+	return languages[0], nil
+}
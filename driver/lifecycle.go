@@ -0,0 +1,87 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	// shutdownDrainTimeout bounds how long Shutdown waits for in-flight
+	// StartTask calls and task watchers before tearing down the session.
+	shutdownDrainTimeout = 10 * time.Second
+)
+
+// errShuttingDown is returned for calls that arrive after Shutdown began.
+var errShuttingDown = errors.New("driver shutting down")
+
+// lifecycle tracks whether the driver accepts new work and which calls are
+// still in flight, so Shutdown can stop admitting tasks before it drains
+// watchers and deletes the session.
+type lifecycle struct {
+	// mu syncs shuttingDown with registrations on the wait groups
+	mu sync.Mutex
+
+	// shuttingDown is set once Shutdown has begun
+	shuttingDown bool
+
+	// starts tracks in-flight StartTask calls
+	starts sync.WaitGroup
+
+	// watchers tracks running handleWait goroutines
+	watchers sync.WaitGroup
+}
+
+// enter registers an in-flight call on wg. It returns false if the driver is
+// shutting down, in which case the caller must not proceed.
+func (l *lifecycle) enter(wg *sync.WaitGroup) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.shuttingDown {
+		return false
+	}
+	wg.Add(1)
+	return true
+}
+
+// beginShutdown stops admitting new calls. It returns false if shutdown had
+// already begun.
+func (l *lifecycle) beginShutdown() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.shuttingDown {
+		return false
+	}
+	l.shuttingDown = true
+	return true
+}
+
+// isShuttingDown reports whether Shutdown has begun.
+func (l *lifecycle) isShuttingDown() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.shuttingDown
+}
+
+// drain waits for in-flight calls to finish, up to timeout. It returns false
+// if the timeout elapsed first. It must only be called after beginShutdown.
+func (l *lifecycle) drain(timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		l.starts.Wait()
+		l.watchers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/nomad/plugins/drivers"
+	"go.opentelemetry.io/otel/trace"
+
+	pb "github.com/elide-dev/elide-task-driver/proto/gen/go/elide/daemon/v1alpha1"
+)
+
+// metricTaskLifetimeExpirations counts tasks failed for exceeding
+// max_task_lifetime
+const metricTaskLifetimeExpirations = "task_lifetime_expirations_total"
+
+// lifetimeContext returns a context of ctx that ends when the task exceeds
+// max_task_lifetime, counted from when it was started or queued.
+func (d *ElideDriverPlugin) lifetimeContext(ctx context.Context, handle *taskHandle) (context.Context, context.CancelFunc) {
+	lifetime := durationOr(0, d.config.MaxTaskLifetime)
+	if lifetime == 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithDeadline(ctx, handle.startedAt.Add(lifetime))
+}
+
+// lifetimeExpired reports whether a wait ended because the task exceeded
+// max_task_lifetime, rather than because the wait itself ended.
+func lifetimeExpired(ctx context.Context, lifetimeCtx context.Context) bool {
+	return ctx.Err() == nil && errors.Is(lifetimeCtx.Err(), context.DeadlineExceeded)
+}
+
+// expireTask fails a task that exceeded max_task_lifetime, whatever its
+// execution is doing: queued, running, or unknown to a wedged daemon. Its
+// executions are cancelled, and force-killed in the background if the daemon
+// does not confirm they stopped, but the task fails without waiting for that.
+func (d *ElideDriverPlugin) expireTask(ctx context.Context, handle *taskHandle) *drivers.ExitResult {
+	lifetime := durationOr(0, d.config.MaxTaskLifetime)
+	cause := fmt.Errorf("task exceeded its max_task_lifetime of %s", lifetime)
+	trace.SpanFromContext(ctx).AddEvent("task lifetime exceeded")
+	d.metrics.IncrCounter(metricTaskLifetimeExpirations, "Tasks failed for exceeding max_task_lifetime.")
+
+	if handle.queue == nil {
+		executionIDs := handle.executionIDs()
+		cancelCtx, cancel := d.withTimeout(d.ctx, d.cancelTimeout())
+		err := d.cancelExecution(cancelCtx, handle, pb.CancellationReason_CANCELLATION_REASON_TIMEOUT, initiatorDriver, cause)
+		cancel()
+		if err != nil {
+			handle.logger.Warn("failed to cancel execution of expired task", "error", err)
+		}
+		go func() {
+			if err := d.confirmCancelled(d.ctx, handle, executionIDs); err != nil {
+				handle.logger.Error("execution of expired task may still be running", "error", err)
+			}
+		}()
+	} else {
+		handle.logger.Warn("task exceeded its lifetime before it was submitted", "max_task_lifetime", lifetime)
+		d.eventer.EmitEvent(&drivers.TaskEvent{
+			TaskID:    handle.taskConfig.ID,
+			AllocID:   handle.taskConfig.AllocID,
+			TaskName:  handle.taskConfig.Name,
+			Timestamp: time.Now(),
+			Message:   fmt.Sprintf("Task exceeded its max_task_lifetime of %s before it was submitted", lifetime),
+			Annotations: map[string]string{
+				"max_task_lifetime": lifetime.String(),
+			},
+		})
+	}
+
+	result := &drivers.ExitResult{Err: cause}
+	handle.SetCompleted(result)
+	return result
+}
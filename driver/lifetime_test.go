@@ -0,0 +1,52 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/nomad/plugins/drivers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWaitTask_ExpiresTaskOutlivingMaxLifetime(t *testing.T) {
+	d := NewPlugin(hclog.NewNullLogger()).(*ElideDriverPlugin)
+	t.Cleanup(d.Shutdown)
+	client := &stoppableDaemonClient{}
+	d.daemonClient = client
+	d.sessionID = "session-1"
+	d.config.MaxTaskLifetime = "200ms"
+
+	start := time.Now()
+	d.tasks.Set("task-1", d.recoveredHandle(&TaskState{
+		TaskConfig:   &drivers.TaskConfig{ID: "task-1"},
+		ExecutionId:  "exec-1",
+		SessionId:    "session-1",
+		StartedAt:    start,
+		PollInterval: 10 * time.Millisecond,
+	}))
+	ch, err := d.WaitTask(context.Background(), "task-1")
+	require.NoError(t, err)
+
+	var result *drivers.ExitResult
+	select {
+	case result = <-ch:
+	case <-time.After(5 * time.Second):
+		t.Fatal("task outliving max_task_lifetime did not exit")
+	}
+	require.NotNil(t, result)
+	assert.EqualError(t, result.Err, "task exceeded its max_task_lifetime of 200ms")
+	assert.GreaterOrEqual(t, time.Since(start), 200*time.Millisecond)
+
+	// The execution is cancelled by the driver, as timed out
+	_, cancels := client.calls()
+	require.Len(t, cancels, 1)
+	assert.Equal(t, "timeout", cancels[0].name)
+	assert.Equal(t, initiatorDriver, client.initiator)
+	assert.Contains(t, scrape(t, d), "elide_driver_task_lifetime_expirations_total 1")
+}
@@ -0,0 +1,70 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	// Time zones are validated against the embedded database, since the
+	// node's may be missing or differ from the daemon's
+	_ "time/tzdata"
+)
+
+// Env vars carrying an execution's time zone and locale
+const (
+	timezoneEnvVar = "TZ"
+	localeEnvVar   = "LANG"
+)
+
+// localePattern matches POSIX locale names: language[_territory][.codeset]
+// [@modifier], C or POSIX
+var localePattern = regexp.MustCompile(`^(?:[a-zA-Z]{2,8}(?:_[a-zA-Z0-9]{2,3})?|C|POSIX)(?:\.[a-zA-Z0-9-]+)?(?:@[a-zA-Z0-9]+)?$`)
+
+// ValidateTimezone checks that a time zone is a known IANA name, such as
+// "UTC" or "America/New_York".
+func ValidateTimezone(option string, timezone string) error {
+	if timezone == "" {
+		return nil
+	}
+	if timezone == "Local" {
+		return fmt.Errorf("invalid %s %q: must be an IANA time zone name", option, timezone)
+	}
+	if _, err := time.LoadLocation(timezone); err != nil {
+		return fmt.Errorf("invalid %s %q: unknown time zone", option, timezone)
+	}
+	return nil
+}
+
+// ValidateLocale checks that a locale is a POSIX locale name, such as
+// "en_US.UTF-8" or "C.UTF-8".
+func ValidateLocale(option string, locale string) error {
+	if locale != "" && !localePattern.MatchString(locale) {
+		return fmt.Errorf("invalid %s %q: must be a POSIX locale name such as \"en_US.UTF-8\"", option, locale)
+	}
+	return nil
+}
+
+// ApplyLocale sets an execution's time zone and locale in its environment as
+// TZ and LANG. The task's elide_opts take precedence over the task's env,
+// which takes precedence over the plugin defaults. It returns the time zone
+// and locale the execution runs with, empty if unset.
+func ApplyLocale(env map[string]string, opts ElideOptions, defaultTimezone string, defaultLocale string) (timezone string, locale string) {
+	applyLocaleVar(env, timezoneEnvVar, opts.Timezone, defaultTimezone)
+	applyLocaleVar(env, localeEnvVar, opts.Locale, defaultLocale)
+	return env[timezoneEnvVar], env[localeEnvVar]
+}
+
+// applyLocaleVar sets an env var to the task's value, or to the plugin's
+// default if the env does not set it.
+func applyLocaleVar(env map[string]string, name string, value string, defaultValue string) {
+	if value != "" {
+		env[name] = value
+		return
+	}
+	if _, ok := env[name]; !ok && defaultValue != "" {
+		env[name] = defaultValue
+	}
+}
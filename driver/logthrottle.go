@@ -0,0 +1,224 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// defaultLogDedupWindow is how long repeats of a daemon error are
+	// suppressed if daemon_log_dedup_window is unset
+	defaultLogDedupWindow = time.Minute
+
+	// logThrottleFlushInterval is how often summaries of suppressed errors
+	// whose window ended are logged
+	logThrottleFlushInterval = 5 * time.Second
+
+	// metricSuppressedLogs counts warnings and errors suppressed as repeats
+	// of a daemon error
+	metricSuppressedLogs = "suppressed_daemon_logs_total"
+)
+
+// LogThrottle deduplicates warnings and errors about daemon failures. When
+// the daemon misbehaves, every task polling it logs the same failure every
+// poll; the throttle logs the first occurrence of each message and error
+// class, counts the repeats during the window, then logs one summary such
+// as "suppressed 240 similar errors in last 1m0s". Logs not about a daemon
+// error are passed through.
+type LogThrottle struct {
+	mu      sync.Mutex
+	window  time.Duration
+	entries map[logThrottleKey]*logThrottleEntry
+
+	// logger logs the summaries, without the args of any one occurrence
+	logger hclog.Logger
+
+	// onSuppressed is called for each suppressed log (may be nil)
+	onSuppressed func(class string)
+}
+
+type logThrottleKey struct {
+	level hclog.Level
+	msg   string
+	class string
+}
+
+type logThrottleEntry struct {
+	since      time.Time
+	suppressed int
+}
+
+// NewLogThrottle returns a throttle suppressing repeats for window (0 =
+// disabled), logging summaries to logger.
+func NewLogThrottle(logger hclog.Logger, window time.Duration) *LogThrottle {
+	return &LogThrottle{
+		window:  window,
+		entries: make(map[logThrottleKey]*logThrottleEntry),
+		logger:  logger,
+	}
+}
+
+// SetWindow changes the window, taking effect for errors first logged after.
+func (t *LogThrottle) SetWindow(window time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.window = window
+}
+
+// Wrap returns a logger whose warnings and errors, and those of the loggers
+// derived from it, go through the throttle.
+func (t *LogThrottle) Wrap(logger hclog.Logger) hclog.Logger {
+	return &throttledLogger{Logger: logger, throttle: t}
+}
+
+// allow reports whether a log is logged, counting it if not.
+func (t *LogThrottle) allow(level hclog.Level, msg string, args []interface{}) bool {
+	class := daemonErrorClass(args)
+	if class == "" {
+		return true
+	}
+
+	t.mu.Lock()
+	if t.window <= 0 {
+		t.mu.Unlock()
+		return true
+	}
+	key := logThrottleKey{level: level, msg: msg, class: class}
+	now := time.Now()
+	entry, ok := t.entries[key]
+	if ok && now.Sub(entry.since) < t.window {
+		entry.suppressed++
+		t.mu.Unlock()
+		if t.onSuppressed != nil {
+			t.onSuppressed(class)
+		}
+		return false
+	}
+	t.entries[key] = &logThrottleEntry{since: now}
+	window := t.window
+	t.mu.Unlock()
+
+	// A repeat after the window starts a new one, summarizing the last
+	if ok && entry.suppressed > 0 {
+		t.logSuppressed(key, entry.suppressed, window)
+	}
+	return true
+}
+
+// Flush logs the summaries of suppressed errors whose window ended by now
+// and forgets them.
+func (t *LogThrottle) Flush(now time.Time) {
+	type summary struct {
+		key   logThrottleKey
+		entry *logThrottleEntry
+	}
+	var summaries []summary
+
+	t.mu.Lock()
+	window := t.window
+	for key, entry := range t.entries {
+		if now.Sub(entry.since) < window {
+			continue
+		}
+		delete(t.entries, key)
+		if entry.suppressed > 0 {
+			summaries = append(summaries, summary{key, entry})
+		}
+	}
+	t.mu.Unlock()
+
+	for _, s := range summaries {
+		t.logSuppressed(s.key, s.entry.suppressed, window)
+	}
+}
+
+// run flushes summaries periodically until ctx is done.
+func (t *LogThrottle) run(ctx context.Context, w *WorkerHeartbeat) {
+	ticker := time.NewTicker(logThrottleFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			t.Flush(now)
+			w.Beat()
+		}
+	}
+}
+
+func (t *LogThrottle) logSuppressed(key logThrottleKey, count int, window time.Duration) {
+	t.logger.Log(key.level, fmt.Sprintf("suppressed %d similar errors in last %s", count, window), "message", key.msg, "code", key.class)
+}
+
+// daemonErrorClass returns the class of the daemon error among a log's
+// args: the gRPC status code of its "error" arg. It returns "" for logs
+// without an error from the daemon.
+func daemonErrorClass(args []interface{}) string {
+	for i := 0; i+1 < len(args); i += 2 {
+		if key, ok := args[i].(string); !ok || key != "error" {
+			continue
+		}
+		return ErrorClass(args[i+1])
+	}
+	return ""
+}
+
+// ErrorClass returns the gRPC status code of an error returned by a daemon
+// RPC, wrapped or not, and "" for other values.
+func ErrorClass(value interface{}) string {
+	err, ok := value.(error)
+	if !ok || err == nil {
+		return ""
+	}
+	st, ok := status.FromError(err)
+	if !ok {
+		return ""
+	}
+	return st.Code().String()
+}
+
+// throttledLogger is an hclog.Logger whose warnings and errors go through a
+// LogThrottle.
+type throttledLogger struct {
+	hclog.Logger
+	throttle *LogThrottle
+}
+
+func (l *throttledLogger) Log(level hclog.Level, msg string, args ...interface{}) {
+	if level < hclog.Warn || l.throttle.allow(level, msg, args) {
+		l.Logger.Log(level, msg, args...)
+	}
+}
+
+func (l *throttledLogger) Warn(msg string, args ...interface{}) {
+	if l.throttle.allow(hclog.Warn, msg, args) {
+		l.Logger.Warn(msg, args...)
+	}
+}
+
+func (l *throttledLogger) Error(msg string, args ...interface{}) {
+	if l.throttle.allow(hclog.Error, msg, args) {
+		l.Logger.Error(msg, args...)
+	}
+}
+
+func (l *throttledLogger) With(args ...interface{}) hclog.Logger {
+	return l.throttle.Wrap(l.Logger.With(args...))
+}
+
+func (l *throttledLogger) Named(name string) hclog.Logger {
+	return l.throttle.Wrap(l.Logger.Named(name))
+}
+
+func (l *throttledLogger) ResetNamed(name string) hclog.Logger {
+	return l.throttle.Wrap(l.Logger.ResetNamed(name))
+}
@@ -0,0 +1,391 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	pb "github.com/elide-dev/elide-task-driver/proto/gen/go/elide/daemon/v1alpha1"
+)
+
+const (
+	// manifestPollInterval is how often the session manifest is checked for
+	// changes
+	manifestPollInterval = 10 * time.Second
+
+	// maxManifestBytes bounds the size of the session manifest
+	maxManifestBytes = 1 << 20
+)
+
+// Session manifest metric names
+const (
+	metricManifestReloads = "session_manifest_reloads_total"
+	metricManifestErrors  = "session_manifest_errors_total"
+)
+
+// manifestLanguages are the languages a manifest may configure.
+var manifestLanguages = []string{"python", "javascript", "typescript"}
+
+// SessionManifest is the declarative session policy read from the file at
+// session_manifest. Its session section replaces session_config and its
+// language defaults replace those of the plugin config for the languages it
+// lists.
+type SessionManifest struct {
+	// Session is the session configuration (nil = session_config)
+	Session *ManifestSessionConfig `json:"session" yaml:"session"`
+
+	// Init is code run in each context of the session before it serves
+	// executions
+	Init []ManifestSnippet `json:"init" yaml:"init"`
+
+	// Warm are snippets the driver executes in each new session, e.g. to
+	// populate caches before the first task arrives
+	Warm []ManifestSnippet `json:"warm" yaml:"warm"`
+
+	// LanguageDefaults are merged into every execution of a language
+	LanguageDefaults map[string]ManifestLanguageDefaults `json:"language_defaults" yaml:"language_defaults"`
+
+	// revision identifies the session-level content (session and init);
+	// sessions are re-created when it changes
+	revision string
+}
+
+// ManifestSessionConfig is the session section of a session manifest.
+type ManifestSessionConfig struct {
+	ContextPoolSize   int      `json:"context_pool_size" yaml:"context_pool_size"`
+	EnabledLanguages  []string `json:"enabled_languages" yaml:"enabled_languages"`
+	EnabledIntrinsics []string `json:"enabled_intrinsics" yaml:"enabled_intrinsics"`
+	MemoryLimitMB     int      `json:"memory_limit_mb" yaml:"memory_limit_mb"`
+	EnableAI          bool     `json:"enable_ai" yaml:"enable_ai"`
+}
+
+// ManifestSnippet is an init or warm snippet of a session manifest.
+type ManifestSnippet struct {
+	Language string `json:"language" yaml:"language"`
+	Code     string `json:"code" yaml:"code"`
+}
+
+// ManifestLanguageDefaults are the defaults of one language in a session
+// manifest.
+type ManifestLanguageDefaults struct {
+	InterpreterArgs []string          `json:"interpreter_args" yaml:"interpreter_args"`
+	Env             map[string]string `json:"env" yaml:"env"`
+}
+
+// LoadSessionManifest reads and validates a session manifest. Files ending in
+// .json are read as JSON, anything else as YAML; unknown fields are rejected
+// in both.
+func LoadSessionManifest(path string) (*SessionManifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read session manifest: %w", err)
+	}
+	return ParseSessionManifest(data, isJSONManifest(path))
+}
+
+// isJSONManifest reports whether the manifest at path is JSON.
+func isJSONManifest(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".json")
+}
+
+// ParseSessionManifest parses and validates a session manifest in JSON or
+// YAML.
+func ParseSessionManifest(data []byte, isJSON bool) (*SessionManifest, error) {
+	if len(data) > maxManifestBytes {
+		return nil, fmt.Errorf("session manifest is larger than %d bytes", maxManifestBytes)
+	}
+
+	var m SessionManifest
+	if isJSON {
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&m); err != nil {
+			return nil, fmt.Errorf("invalid session manifest: %w", err)
+		}
+	} else {
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(&m); err != nil && err != io.EOF {
+			return nil, fmt.Errorf("invalid session manifest: %w", err)
+		}
+	}
+	if err := m.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid session manifest: %w", err)
+	}
+
+	revision, _ := json.Marshal(struct {
+		Session *ManifestSessionConfig
+		Init    []ManifestSnippet
+	}{m.Session, m.Init})
+	sum := sha256.Sum256(revision)
+	m.revision = hex.EncodeToString(sum[:4])
+	return &m, nil
+}
+
+// Validate checks the manifest.
+func (m *SessionManifest) Validate() error {
+	if s := m.Session; s != nil {
+		if s.ContextPoolSize < 0 {
+			return fmt.Errorf("session.context_pool_size cannot be negative")
+		}
+		if s.MemoryLimitMB < 0 {
+			return fmt.Errorf("session.memory_limit_mb cannot be negative")
+		}
+		for _, language := range s.EnabledLanguages {
+			if !isManifestLanguage(language) {
+				return fmt.Errorf("session.enabled_languages: unknown language %q", language)
+			}
+		}
+	}
+	for field, snippets := range map[string][]ManifestSnippet{"init": m.Init, "warm": m.Warm} {
+		for i, snippet := range snippets {
+			if !isManifestLanguage(snippet.Language) {
+				return fmt.Errorf("%s[%d]: unknown language %q", field, i, snippet.Language)
+			}
+			if strings.TrimSpace(snippet.Code) == "" {
+				return fmt.Errorf("%s[%d]: code must not be empty", field, i)
+			}
+		}
+	}
+	for language, defaults := range m.LanguageDefaults {
+		if !isManifestLanguage(language) {
+			return fmt.Errorf("language_defaults: unknown language %q", language)
+		}
+		for name := range defaults.Env {
+			if err := validateEnvName(name); err != nil {
+				return fmt.Errorf("language_defaults.%s: %w", language, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Revision identifies the session-level content of the manifest.
+func (m *SessionManifest) Revision() string {
+	return m.revision
+}
+
+func isManifestLanguage(language string) bool {
+	for _, l := range manifestLanguages {
+		if l == language {
+			return true
+		}
+	}
+	return false
+}
+
+// sessionConfig returns the effective session configuration: the session
+// manifest's, if it has a session section, or session_config.
+func (d *ElideDriverPlugin) sessionConfig() *SessionConfig {
+	if m := d.manifest.Load(); m != nil && m.Session != nil {
+		return &SessionConfig{
+			ContextPoolSize:   m.Session.ContextPoolSize,
+			EnabledLanguages:  m.Session.EnabledLanguages,
+			EnabledIntrinsics: m.Session.EnabledIntrinsics,
+			MemoryLimitMB:     m.Session.MemoryLimitMB,
+			EnableAI:          m.Session.EnableAI,
+		}
+	}
+	return &d.config.SessionConfig
+}
+
+// languageDefaults returns the defaults of a language: the session
+// manifest's, if it lists the language, or the plugin config's.
+func (d *ElideDriverPlugin) languageDefaults(language string) LanguageDefaults {
+	if m := d.manifest.Load(); m != nil {
+		if defaults, ok := m.LanguageDefaults[language]; ok {
+			return LanguageDefaults{InterpreterArgs: defaults.InterpreterArgs, Env: defaults.Env}
+		}
+	}
+	return d.config.LanguageDefaults.For(language)
+}
+
+// initSnippets returns the init snippets of the session manifest.
+func (d *ElideDriverPlugin) initSnippets() []*pb.InitSnippet {
+	m := d.manifest.Load()
+	if m == nil {
+		return nil
+	}
+	snippets := make([]*pb.InitSnippet, len(m.Init))
+	for i, snippet := range m.Init {
+		snippets[i] = &pb.InitSnippet{Language: snippet.Language, Code: snippet.Code}
+	}
+	return snippets
+}
+
+// watchManifest reloads the session manifest whenever its content changes.
+// An invalid manifest is reported and ignored; the previous one stays in
+// effect.
+func (d *ElideDriverPlugin) watchManifest(w *WorkerHeartbeat, path string, loaded []byte) {
+	last := sha256.Sum256(loaded)
+	ticker := time.NewTicker(manifestPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-d.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		w.Beat()
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			d.logger.Warn("failed to read session manifest", "path", path, "error", err)
+			d.metrics.IncrCounter(metricManifestErrors, "Session manifest reloads that failed.")
+			continue
+		}
+		sum := sha256.Sum256(data)
+		if sum == last {
+			continue
+		}
+		last = sum
+
+		manifest, err := ParseSessionManifest(data, isJSONManifest(path))
+		if err != nil {
+			d.logger.Error("ignoring changed session manifest", "path", path, "error", err)
+			d.metrics.IncrCounter(metricManifestErrors, "Session manifest reloads that failed.")
+			continue
+		}
+		d.logger.Info("session manifest changed", "path", path, "revision", manifest.revision)
+		d.metrics.IncrCounter(metricManifestReloads, "Session manifest changes applied.")
+		d.applyManifest(manifest)
+	}
+}
+
+// applyManifest reconciles the sessions with a changed manifest. If the
+// session configuration or init code changed, the current sessions are
+// retired: new tasks run in a new session while running tasks finish in the
+// old one, which is deleted once they are done. If only the warm scripts
+// changed, they are run in the current session.
+func (d *ElideDriverPlugin) applyManifest(manifest *SessionManifest) {
+	old := d.manifest.Swap(manifest)
+	if old != nil && old.revision == manifest.revision {
+		if !reflect.DeepEqual(old.Warm, manifest.Warm) {
+			d.sessionLock.Lock()
+			sessionID := d.sessionID
+			d.sessionLock.Unlock()
+			if sessionID != "" {
+				go d.warmSession(sessionID)
+			}
+		}
+		return
+	}
+
+	d.sessionLock.Lock()
+	defer d.sessionLock.Unlock()
+
+	d.retireSessions("manifest change")
+	if d.daemonClient != nil {
+		if err := d.createSession(d.ctx); err != nil {
+			d.logger.Warn("failed to create session for the changed manifest; the next task retries", "error", err)
+		}
+	}
+}
+
+// retireSessions retires the default and profile sessions: new tasks run in
+// new sessions, while running tasks finish in the retired ones. Callers must
+// hold sessionLock.
+func (d *ElideDriverPlugin) retireSessions(reason string) {
+	if d.sessionID != "" {
+		d.logger.Info("retiring session", "session_id", d.sessionID, "reason", reason)
+		d.retiredSessions = append(d.retiredSessions, d.sessionID)
+		d.restarts.forgetSession(d.sessionID)
+		d.sessionID = ""
+	}
+	for name, sessionID := range d.profileSessions {
+		d.logger.Info("retiring session", "session_profile", name, "session_id", sessionID, "reason", reason)
+		d.retiredSessions = append(d.retiredSessions, sessionID)
+		d.restarts.forgetSession(sessionID)
+		delete(d.profileSessions, name)
+	}
+}
+
+// deleteRetiredSessions deletes retired sessions no running task uses any
+// more. Callers must hold sessionLock.
+func (d *ElideDriverPlugin) deleteRetiredSessions(ctx context.Context) {
+	if len(d.retiredSessions) == 0 || d.daemonClient == nil {
+		return
+	}
+
+	inUse := d.sessionsInUse()
+	remaining := d.retiredSessions[:0]
+	for _, sessionID := range d.retiredSessions {
+		if inUse[sessionID] {
+			remaining = append(remaining, sessionID)
+			continue
+		}
+		deleteCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+		err := d.daemonClient.DeleteSession(deleteCtx, sessionID)
+		cancel()
+		if err != nil {
+			d.logger.Warn("failed to delete retired session", "session_id", sessionID, "error", err)
+			d.recordSessionError(err)
+			remaining = append(remaining, sessionID)
+			continue
+		}
+		d.logger.Info("deleted retired session", "session_id", sessionID)
+		d.recordOtherSessionDeleted()
+	}
+	d.retiredSessions = remaining
+}
+
+// sessionsInUse returns the sessions running executions of live tasks.
+func (d *ElideDriverPlugin) sessionsInUse() map[string]bool {
+	inUse := map[string]bool{}
+	for _, h := range d.tasks.List() {
+		h.stateLock.RLock()
+		if h.exitResult == nil && h.sessionId != "" {
+			inUse[h.sessionId] = true
+		}
+		h.stateLock.RUnlock()
+	}
+	return inUse
+}
+
+// warmSession runs the manifest's warm scripts in a session. The scripts are
+// submitted without waiting for them; failures are logged.
+func (d *ElideDriverPlugin) warmSession(sessionID string) {
+	m := d.manifest.Load()
+	if m == nil || d.daemonClient == nil {
+		return
+	}
+	for i, snippet := range m.Warm {
+		executionID := fmt.Sprintf("%s-warm-%d-%d", sessionID, time.Now().Unix(), i)
+		defaults := d.languageDefaults(snippet.Language)
+		env := map[string]string{}
+		defaults.MergeEnv(env)
+
+		ctx, cancel := d.withTimeout(d.ctx, d.submitTimeout())
+		_, err := d.daemonClient.ExecuteSnippet(ctx, ExecuteSnippetRequest{
+			SessionID:       sessionID,
+			ExecutionID:     executionID,
+			Code:            snippet.Code,
+			Language:        snippet.Language,
+			Env:             env,
+			InterpreterArgs: defaults.InterpreterArgs,
+			DiscardOutput:   true,
+			Tags:            map[string]string{tagHost: hostname()},
+		})
+		cancel()
+		if err != nil {
+			d.logger.Warn("failed to submit warm script", "session_id", sessionID, "index", i, "error", err)
+			continue
+		}
+		d.logger.Debug("submitted warm script", "session_id", sessionID, "execution_id", executionID)
+	}
+}
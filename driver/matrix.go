@@ -0,0 +1,296 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/nomad/plugins/drivers"
+
+	pb "github.com/elide-dev/elide-task-driver/proto/gen/go/elide/daemon/v1alpha1"
+)
+
+// Policies aggregating the exit codes of an args_matrix task
+const (
+	// matrixPolicyAllSuccess succeeds if every execution succeeds
+	matrixPolicyAllSuccess = "all-success"
+
+	// matrixPolicyAnySuccess succeeds if at least one execution succeeds
+	matrixPolicyAnySuccess = "any-success"
+)
+
+const (
+	// maxMatrixEntries bounds the executions a single task may submit
+	maxMatrixEntries = 1000
+
+	// matrixManifestFileName is the per-entry result manifest, written to
+	// the task's local directory once every execution completed
+	matrixManifestFileName = "local/elide-matrix.json"
+)
+
+// MatrixEntry is one execution of a task with args_matrix.
+type MatrixEntry struct {
+	Index       int      `json:"index"`
+	Args        []string `json:"args"`
+	ExecutionId string   `json:"execution_id"`
+
+	// Outcome, set once the execution completed
+	Complete bool   `json:"complete"`
+	Status   string `json:"status,omitempty"`
+	ExitCode int    `json:"exit_code"`
+	Error    string `json:"error,omitempty"`
+	Result   string `json:"result,omitempty"`
+
+	// Output held until every execution completed
+	stdout string
+	stderr string
+}
+
+// failed reports whether a completed entry failed.
+func (e *MatrixEntry) failed() bool {
+	return e.Error != "" || e.ExitCode != 0
+}
+
+// matrixManifest is the content of the result manifest.
+type matrixManifest struct {
+	Policy   string         `json:"policy"`
+	ExitCode int            `json:"exit_code"`
+	Error    string         `json:"error,omitempty"`
+	Entries  []*MatrixEntry `json:"entries"`
+}
+
+// validateArgsMatrix checks the args_matrix options of a task config.
+func (tc *TaskConfig) validateArgsMatrix() error {
+	switch tc.MatrixPolicy {
+	case "", matrixPolicyAllSuccess, matrixPolicyAnySuccess:
+	default:
+		return &FieldError{Field: "matrix_policy", Err: fmt.Errorf("invalid matrix_policy %q (must be %q or %q)", tc.MatrixPolicy, matrixPolicyAllSuccess, matrixPolicyAnySuccess)}
+	}
+	if len(tc.ArgsMatrix) == 0 {
+		return nil
+	}
+	if len(tc.ArgsMatrix) > maxMatrixEntries {
+		return fmt.Errorf("args_matrix has %d entries (limit %d)", len(tc.ArgsMatrix), maxMatrixEntries)
+	}
+	if len(tc.Args) > 0 {
+		return fmt.Errorf("only one of 'args' or 'args_matrix' may be specified")
+	}
+	if len(tc.Exports) > 0 {
+		return fmt.Errorf("'exports' cannot be used with 'args_matrix'")
+	}
+	if len(tc.Ports) > 0 {
+		return fmt.Errorf("'ports' cannot be used with 'args_matrix'")
+	}
+	if tc.CombineOutput {
+		return fmt.Errorf("'combine_output' cannot be used with 'args_matrix'")
+	}
+	return nil
+}
+
+// matrixExecutionID returns the execution ID of an args_matrix entry.
+func matrixExecutionID(taskID string, index int) string {
+	return taskID + "-" + strconv.Itoa(index)
+}
+
+// submitMatrix submits one execution per args_matrix entry. If a submission
+// fails, the executions already submitted are cancelled.
+func (d *ElideDriverPlugin) submitMatrix(ctx context.Context, sessionID string, taskID string, argsMatrix [][]string,
+	submit func(executionID string, args []string) (*pb.ExecuteSnippetResponse, error)) ([]*MatrixEntry, *pb.ExecuteSnippetResponse, error) {
+	entries := make([]*MatrixEntry, 0, len(argsMatrix))
+	var first *pb.ExecuteSnippetResponse
+	for i, args := range argsMatrix {
+		resp, err := submit(matrixExecutionID(taskID, i), args)
+		if err != nil {
+			for _, entry := range entries {
+				d.cancelUnstarted(sessionID, entry.ExecutionId)
+			}
+			return nil, nil, fmt.Errorf("args_matrix entry %d: %w", i, err)
+		}
+		if first == nil {
+			first = resp
+		}
+		entries = append(entries, &MatrixEntry{Index: i, Args: args, ExecutionId: resp.ExecutionId})
+	}
+	return entries, first, nil
+}
+
+// pollMatrix polls the pending executions of an args_matrix task once. It
+// returns the task's exit result and true once every execution completed.
+func (d *ElideDriverPlugin) pollMatrix(ctx context.Context, handle *taskHandle, lastScratchCheck *time.Time) (*drivers.ExitResult, bool) {
+	pending := 0
+	status := pb.ExecutionStatus_EXECUTION_STATUS_COMPLETED
+	for _, entry := range handle.matrix {
+		if entry.Complete {
+			continue
+		}
+
+		// Output is only kept once an execution completed
+		statusResp, err := d.pollStatus(ctx, handle.sessionId, entry.ExecutionId, OutputRequest{Omit: d.supportsOutputOffsets.Load()})
+		if err != nil && isUnavailable(err) {
+			d.reconnect.markDown(err)
+			return nil, false
+		}
+
+		handle.stateLock.Lock()
+		switch {
+		case err != nil:
+			entry.Complete = true
+			entry.Error = fmt.Sprintf("failed to get execution status: %v", err)
+		case !statusResp.Complete:
+			pending++
+			if statusResp.Status == pb.ExecutionStatus_EXECUTION_STATUS_RUNNING || status != pb.ExecutionStatus_EXECUTION_STATUS_RUNNING {
+				status = statusResp.Status
+			}
+		default:
+			entry.Complete = true
+			entry.Status = executionStatusName(statusResp.Status)
+			entry.ExitCode = int(statusResp.ExitCode)
+			entry.Error = statusResp.Error
+			entry.Result = statusResp.Result
+			entry.stdout, entry.stderr = statusResp.Stdout, statusResp.Stderr
+			if !entry.failed() {
+				if err := d.checkResultSchema(handle, entry.ExecutionId, entry.Result); err != nil {
+					entry.Error = err.Error()
+				}
+			}
+		}
+		handle.stateLock.Unlock()
+	}
+
+	if pending > 0 {
+		handle.updateStatus(status, fmt.Sprintf("%d of %d executions pending", pending, len(handle.matrix)), 0)
+		d.enforceLimits(handle, lastScratchCheck)
+		return nil, false
+	}
+
+	result := handle.matrixResult()
+	handle.updateStatus(pb.ExecutionStatus_EXECUTION_STATUS_COMPLETED, "", 0)
+	if err := handle.writeMatrixManifest(result); err != nil {
+		handle.logger.Warn("failed to write matrix manifest", "error", err)
+	}
+
+	var stdout, stderr strings.Builder
+	for _, entry := range handle.matrix {
+		stdout.WriteString(entry.stdout)
+		stderr.WriteString(entry.stderr)
+	}
+	if err := handle.shipOutput(stdout.String(), stderr.String(), nil); err != nil {
+		handle.logger.Warn("failed to ship execution output", "error", err)
+	}
+	handle.SetCompleted(result)
+	go d.guardRestarts(handle, result)
+	return result, true
+}
+
+// matrixResult aggregates the outcomes of the executions per the task's
+// matrix policy. A cancellation takes precedence.
+func (h *taskHandle) matrixResult() *drivers.ExitResult {
+	h.stateLock.RLock()
+	defer h.stateLock.RUnlock()
+
+	var failed []*MatrixEntry
+	for _, entry := range h.matrix {
+		if entry.failed() {
+			failed = append(failed, entry)
+		}
+	}
+
+	result := &drivers.ExitResult{}
+	if len(failed) > 0 {
+		result.ExitCode = failed[0].ExitCode
+		if result.ExitCode == 0 {
+			result.ExitCode = 1
+		}
+	}
+
+	switch {
+	case h.cancelErr != nil:
+		result.Err = h.cancelErr
+	case h.matrixPolicy == matrixPolicyAnySuccess && len(failed) < len(h.matrix):
+		result.ExitCode = 0
+	case h.matrixPolicy == matrixPolicyAnySuccess && len(failed) > 0:
+		result.Err = fmt.Errorf("all %d matrix executions failed (first: entry %d)", len(h.matrix), failed[0].Index)
+	case len(failed) > 0:
+		result.Err = fmt.Errorf("%d of %d matrix executions failed (first: entry %d)", len(failed), len(h.matrix), failed[0].Index)
+	}
+	return result
+}
+
+// writeMatrixManifest writes the per-entry result manifest.
+func (h *taskHandle) writeMatrixManifest(result *drivers.ExitResult) error {
+	h.stateLock.RLock()
+	manifest := matrixManifest{
+		Policy:   h.matrixPolicy,
+		ExitCode: result.ExitCode,
+		Entries:  h.matrix,
+	}
+	if result.Err != nil {
+		manifest.Error = result.Err.Error()
+	}
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	h.stateLock.RUnlock()
+	if err != nil {
+		return err
+	}
+	return writeOutputFile(filepath.Join(h.taskConfig.TaskDir().Dir, matrixManifestFileName), string(data))
+}
+
+// executionIDs returns the executions of the task still to be cancelled on a
+// stop: every pending args_matrix execution, or the task's single execution.
+func (h *taskHandle) executionIDs() []string {
+	h.stateLock.RLock()
+	defer h.stateLock.RUnlock()
+
+	if h.matrix == nil {
+		return []string{h.executionId}
+	}
+	var ids []string
+	for _, entry := range h.matrix {
+		if !entry.Complete {
+			ids = append(ids, entry.ExecutionId)
+		}
+	}
+	return ids
+}
+
+// matrixCopy returns a copy of the args_matrix entries that is safe to read
+// while the task is being polled. Callers must hold stateLock.
+func (h *taskHandle) matrixCopy() []*MatrixEntry {
+	if h.matrix == nil {
+		return nil
+	}
+	entries := make([]*MatrixEntry, len(h.matrix))
+	for i, entry := range h.matrix {
+		e := *entry
+		entries[i] = &e
+	}
+	return entries
+}
+
+// matrixAttributes adds the progress of an args_matrix task to its driver
+// attributes. Callers must hold stateLock.
+func (h *taskHandle) matrixAttributes(attrs map[string]string) {
+	if h.matrix == nil {
+		return
+	}
+	complete, failed := 0, 0
+	for _, entry := range h.matrix {
+		if entry.Complete {
+			complete++
+			if entry.failed() {
+				failed++
+			}
+		}
+	}
+	attrs["matrix_entries"] = strconv.Itoa(len(h.matrix))
+	attrs["matrix_complete"] = strconv.Itoa(complete)
+	attrs["matrix_failed"] = strconv.Itoa(failed)
+	attrs["matrix_policy"] = h.matrixPolicy
+}
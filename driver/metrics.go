@@ -0,0 +1,504 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/nomad/plugins/drivers"
+)
+
+const (
+	// metricsPrefix is prepended to every metric exported by the driver
+	metricsPrefix = "elide_driver_"
+
+	// defaultMetricsMaxSeries caps the series of each metric if
+	// metrics_max_series is unset
+	defaultMetricsMaxSeries = 1000
+
+	// metricDroppedSeries counts series dropped by the series cap, by metric
+	metricDroppedSeries = "metrics_dropped_series_total"
+)
+
+// metricType is the Prometheus type of a metric family
+type metricType string
+
+const (
+	metricTypeCounter   metricType = "counter"
+	metricTypeGauge     metricType = "gauge"
+	metricTypeHistogram metricType = "histogram"
+)
+
+// metricFamily is a named metric and its labelled series
+type metricFamily struct {
+	name   string
+	help   string
+	typ    metricType
+	series map[string]float64
+
+	// buckets are the upper bounds of a histogram's buckets, and histograms
+	// its series; a histogram's series values are the sums of its
+	// observations
+	buckets    []float64
+	histograms map[string]*histogram
+
+	// dropping is set once the family dropped a series for exceeding
+	// metrics_max_series
+	dropping bool
+}
+
+// histogram is one series of a histogram: its observations per bucket, not
+// cumulative, and in total
+type histogram struct {
+	counts []uint64
+	count  uint64
+}
+
+// metricsRegistry is a minimal in-process metrics registry exported in the
+// Prometheus text format. The plugin runs out of process from the Nomad agent,
+// so its metrics cannot flow through Nomad's own telemetry.
+type metricsRegistry struct {
+	mu       sync.Mutex
+	families map[string]*metricFamily
+
+	// maxSeries caps the series of each family (0 = unlimited); new series
+	// beyond it are dropped and counted in metricDroppedSeries
+	maxSeries int
+
+	logger hclog.Logger
+}
+
+func newMetricsRegistry(logger hclog.Logger) *metricsRegistry {
+	return &metricsRegistry{families: map[string]*metricFamily{}, logger: logger}
+}
+
+// SetMaxSeries changes the series cap of each family. Series already
+// exported are kept.
+func (m *metricsRegistry) SetMaxSeries(maxSeries int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.maxSeries = maxSeries
+}
+
+// admit reports whether a family may hold a series, counting it as dropped if
+// not. It also reports whether this is the family's first dropped series.
+// Callers must hold m.mu.
+func (m *metricsRegistry) admit(f *metricFamily, key string) (ok bool, firstDrop bool) {
+	if _, exists := f.series[key]; exists || m.maxSeries <= 0 || len(f.series) < m.maxSeries || f.name == metricDroppedSeries {
+		return true, false
+	}
+	dropped := m.family(metricDroppedSeries, metricTypeCounter, "Metric series dropped for exceeding metrics_max_series, by metric.")
+	dropped.series[formatLabels([]string{"metric", metricsPrefix + f.name})]++
+	firstDrop = !f.dropping
+	f.dropping = true
+	return false, firstDrop
+}
+
+// warnDropping logs that a family reached the series cap.
+func (m *metricsRegistry) warnDropping(name string, maxSeries int) {
+	m.logger.Warn("metric reached metrics_max_series, dropping new series", "metric", metricsPrefix+name, "max_series", maxSeries)
+}
+
+// family returns the named family, creating it on first use. Callers must
+// hold m.mu.
+func (m *metricsRegistry) family(name string, typ metricType, help string) *metricFamily {
+	f, ok := m.families[name]
+	if !ok {
+		f = &metricFamily{name: name, help: help, typ: typ, series: map[string]float64{}}
+		m.families[name] = f
+	}
+	return f
+}
+
+// IncrCounter adds one to a counter. Labels are given as name/value pairs.
+func (m *metricsRegistry) IncrCounter(name string, help string, labels ...string) {
+	m.mu.Lock()
+	f, key := m.family(name, metricTypeCounter, help), formatLabels(labels)
+	ok, firstDrop := m.admit(f, key)
+	if ok {
+		f.series[key]++
+	}
+	maxSeries := m.maxSeries
+	m.mu.Unlock()
+
+	if firstDrop {
+		m.warnDropping(name, maxSeries)
+	}
+}
+
+// ObserveHistogram records an observation in a histogram with the given
+// bucket upper bounds, which must be sorted and the same on every call.
+func (m *metricsRegistry) ObserveHistogram(name string, help string, buckets []float64, value float64, labels ...string) {
+	m.mu.Lock()
+	f, key := m.family(name, metricTypeHistogram, help), formatLabels(labels)
+	ok, firstDrop := m.admit(f, key)
+	if ok {
+		if f.histograms == nil {
+			f.buckets, f.histograms = buckets, map[string]*histogram{}
+		}
+		h, exists := f.histograms[key]
+		if !exists {
+			h = &histogram{counts: make([]uint64, len(f.buckets))}
+			f.histograms[key] = h
+		}
+		if i, _ := slices.BinarySearch(f.buckets, value); i < len(f.buckets) {
+			h.counts[i]++
+		}
+		h.count++
+		f.series[key] += value
+	}
+	maxSeries := m.maxSeries
+	m.mu.Unlock()
+
+	if firstDrop {
+		m.warnDropping(name, maxSeries)
+	}
+}
+
+// SetGauge sets a gauge to the given value.
+func (m *metricsRegistry) SetGauge(name string, help string, value float64, labels ...string) {
+	m.mu.Lock()
+	f, key := m.family(name, metricTypeGauge, help), formatLabels(labels)
+	ok, firstDrop := m.admit(f, key)
+	if ok {
+		f.series[key] = value
+	}
+	maxSeries := m.maxSeries
+	m.mu.Unlock()
+
+	if firstDrop {
+		m.warnDropping(name, maxSeries)
+	}
+}
+
+// ReplaceGauge sets a gauge and drops all its other series. It is used for
+// info-style gauges that must only ever carry the current label values.
+func (m *metricsRegistry) ReplaceGauge(name string, help string, value float64, labels ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f := m.family(name, metricTypeGauge, help)
+	f.series = map[string]float64{formatLabels(labels): value}
+}
+
+// ResetGauge drops all series of a gauge.
+func (m *metricsRegistry) ResetGauge(name string, help string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.family(name, metricTypeGauge, help).series = map[string]float64{}
+}
+
+// WritePrometheus writes all metrics in the Prometheus text exposition format.
+func (m *metricsRegistry) WritePrometheus(w io.Writer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	names := make([]string, 0, len(m.families))
+	for name := range m.families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		f := m.families[name]
+		if _, err := fmt.Fprintf(w, "# HELP %s%s %s\n# TYPE %s%s %s\n", metricsPrefix, f.name, f.help, metricsPrefix, f.name, f.typ); err != nil {
+			return err
+		}
+
+		keys := make([]string, 0, len(f.series))
+		for k := range f.series {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			value := strconv.FormatFloat(f.series[k], 'g', -1, 64)
+			if h := f.histograms[k]; h != nil {
+				if err := writeHistogram(w, f, k, h, value); err != nil {
+					return err
+				}
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "%s%s%s %s\n", metricsPrefix, f.name, k, value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// writeHistogram writes a histogram series as its cumulative buckets, sum
+// and count.
+func writeHistogram(w io.Writer, f *metricFamily, key string, h *histogram, sum string) error {
+	var cumulative uint64
+	for i, bound := range f.buckets {
+		cumulative += h.counts[i]
+		le := withLabel(key, "le", strconv.FormatFloat(bound, 'f', -1, 64))
+		if _, err := fmt.Fprintf(w, "%s%s_bucket%s %d\n", metricsPrefix, f.name, le, cumulative); err != nil {
+			return err
+		}
+	}
+	name := metricsPrefix + f.name
+	_, err := fmt.Fprintf(w, "%s_bucket%s %d\n%s_sum%s %s\n%s_count%s %d\n",
+		name, withLabel(key, "le", "+Inf"), h.count, name, key, sum, name, key, h.count)
+	return err
+}
+
+// withLabel adds a label to a label set rendered by formatLabels.
+func withLabel(key string, name string, value string) string {
+	label := name + "=" + strconv.Quote(value)
+	if key == "" {
+		return "{" + label + "}"
+	}
+	return strings.TrimSuffix(key, "}") + "," + label + "}"
+}
+
+// formatLabels renders name/value pairs as a sorted Prometheus label set.
+func formatLabels(labels []string) string {
+	if len(labels) < 2 {
+		return ""
+	}
+
+	pairs := make([]string, 0, len(labels)/2)
+	for i := 0; i+1 < len(labels); i += 2 {
+		pairs = append(pairs, labels[i]+"="+strconv.Quote(labels[i+1]))
+	}
+	sort.Strings(pairs)
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// metricsServer serves the driver metrics for Prometheus scraping. It is only
+// started when metrics_address is set in the plugin config.
+type metricsServer struct {
+	server *http.Server
+}
+
+// startMetricsServer starts serving /metrics on the given TCP address.
+func (d *ElideDriverPlugin) startMetricsServer(address string) (*metricsServer, error) {
+	lis, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on metrics address: %w", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", d.handleMetrics)
+	mux.HandleFunc(healthzPath, d.handleHealthz)
+	mux.HandleFunc(readyzPath, d.handleReadyz)
+
+	s := &metricsServer{
+		server: &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second},
+	}
+	go func() {
+		if err := s.server.Serve(lis); err != nil && err != http.ErrServerClosed {
+			d.logger.Error("metrics server stopped", "error", err)
+		}
+	}()
+
+	d.logger.Info("metrics listening", "address", lis.Addr().String())
+	return s, nil
+}
+
+// Close stops the metrics server.
+func (s *metricsServer) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return s.server.Shutdown(ctx)
+}
+
+// handleMetrics writes the current metrics in the Prometheus text format.
+func (d *ElideDriverPlugin) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	d.refreshSessionMetrics()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if err := d.metrics.WritePrometheus(w); err != nil {
+		d.logger.Debug("failed to write metrics", "error", err)
+	}
+}
+
+// Session lifecycle metric names
+const (
+	metricSessionCreations         = "session_creations_total"
+	metricSessionRecreations       = "session_recreations_total"
+	metricSessionDeletions         = "session_deletions_total"
+	metricSessionKeepaliveFailures = "session_keepalive_failures_total"
+	metricSessionInfo              = "session_info"
+	metricSessionAge               = "session_age_seconds"
+	metricSessionLastError         = "session_last_error"
+)
+
+// recordSessionCreated counts a session creation. Creations after the first
+// one mean the driver lost its session and had to create it again.
+func (d *ElideDriverPlugin) recordSessionCreated(sessionID string) {
+	if d.sessionCreatedAt.IsZero() {
+		d.metrics.IncrCounter(metricSessionCreations, "Sessions created by the driver.")
+	} else {
+		d.metrics.IncrCounter(metricSessionRecreations, "Sessions re-created after the previous session was lost.")
+	}
+	d.sessionCreatedAt = time.Now()
+	d.metrics.ReplaceGauge(metricSessionInfo, "Current session of the driver.", 1, "session_id", sessionID)
+}
+
+// recordSessionAdopted records an existing session the driver took over as
+// its current session.
+func (d *ElideDriverPlugin) recordSessionAdopted(sessionID string, createdAt time.Time) {
+	d.sessionCreatedAt = createdAt
+	d.metrics.ReplaceGauge(metricSessionInfo, "Current session of the driver.", 1, "session_id", sessionID)
+}
+
+// recordOtherSessionCreated counts the creation of a session other than the
+// driver's current one: a session profile's, or a per-alloc or per-task
+// session. Such sessions are not reported by session_info.
+func (d *ElideDriverPlugin) recordOtherSessionCreated() {
+	d.metrics.IncrCounter(metricSessionCreations, "Sessions created by the driver.")
+}
+
+// recordSessionDeleted counts a deletion of the driver's current session.
+func (d *ElideDriverPlugin) recordSessionDeleted() {
+	d.recordOtherSessionDeleted()
+	d.metrics.ResetGauge(metricSessionInfo, "Current session of the driver.")
+}
+
+// recordOtherSessionDeleted counts the deletion of a session other than the
+// driver's current one, e.g. a session profile's or a retired session.
+func (d *ElideDriverPlugin) recordOtherSessionDeleted() {
+	d.metrics.IncrCounter(metricSessionDeletions, "Sessions deleted by the driver.")
+}
+
+// recordSessionKeepaliveFailure counts a failed session keepalive check.
+func (d *ElideDriverPlugin) recordSessionKeepaliveFailure(err error) {
+	d.metrics.IncrCounter(metricSessionKeepaliveFailures, "Failed session keepalive checks.")
+	d.recordSessionError(err)
+}
+
+// recordSessionError records the most recent session error as an info-style
+// gauge whose value is the Unix time of the error.
+func (d *ElideDriverPlugin) recordSessionError(err error) {
+	d.metrics.ReplaceGauge(metricSessionLastError, "Most recent session error; value is its Unix timestamp.", float64(time.Now().Unix()), "error", err.Error())
+}
+
+// refreshSessionMetrics updates metrics derived from the current time.
+func (d *ElideDriverPlugin) refreshSessionMetrics() {
+	d.sessionLock.Lock()
+	createdAt, sessionID := d.sessionCreatedAt, d.sessionID
+	d.sessionLock.Unlock()
+
+	age := 0.0
+	if sessionID != "" && !createdAt.IsZero() {
+		age = time.Since(createdAt).Seconds()
+	}
+	d.metrics.SetGauge(metricSessionAge, "Age of the current session in seconds.", age)
+}
+
+// Labels metrics_task_labels may attach to per-task metrics. Each multiplies
+// the metrics' series by the number of its values on the node.
+const (
+	taskLabelNamespace = "namespace"
+	taskLabelJob       = "job"
+	taskLabelTaskGroup = "task_group"
+	taskLabelLanguage  = "language"
+)
+
+// taskLabelNames are the labels metrics_task_labels may list
+var taskLabelNames = []string{taskLabelNamespace, taskLabelJob, taskLabelTaskGroup, taskLabelLanguage}
+
+// Per-task metric names
+const (
+	metricTaskStarts = "task_starts_total"
+	metricTaskExits  = "task_exits_total"
+
+	// metricExecutionCodeBytes is the size of the code of submitted
+	// executions, by language
+	metricExecutionCodeBytes = "execution_code_bytes"
+)
+
+// codeSizeBuckets are the bucket bounds of metricExecutionCodeBytes: 256
+// bytes to 4 MB, by factors of four
+var codeSizeBuckets = []float64{256, 1 << 10, 4 << 10, 16 << 10, 64 << 10, 256 << 10, 1 << 20, 4 << 20}
+
+// validateMetricsLabels checks metrics_task_labels and metrics_max_series.
+func (c *Config) validateMetricsLabels() error {
+	seen := map[string]bool{}
+	for _, label := range c.MetricsTaskLabels {
+		if !slices.Contains(taskLabelNames, label) {
+			return fmt.Errorf("invalid metrics_task_labels entry %q (must be one of %s)", label, strings.Join(taskLabelNames, ", "))
+		}
+		if seen[label] {
+			return fmt.Errorf("duplicate metrics_task_labels entry %q", label)
+		}
+		seen[label] = true
+	}
+	if c.MetricsMaxSeries < 0 {
+		return fmt.Errorf("metrics_max_series cannot be negative")
+	}
+	return nil
+}
+
+// metricsMaxSeries returns the series cap of each metric.
+func (c *Config) metricsMaxSeries() int {
+	if c.MetricsMaxSeries == 0 {
+		return defaultMetricsMaxSeries
+	}
+	return c.MetricsMaxSeries
+}
+
+// TaskMetricLabels returns the label name/value pairs of a task's per-task
+// metrics: the labels listed, in order, with the task's values.
+func TaskMetricLabels(cfg *drivers.TaskConfig, language string, labels []string) []string {
+	pairs := make([]string, 0, 2*len(labels))
+	for _, label := range labels {
+		var value string
+		switch label {
+		case taskLabelNamespace:
+			value = cfg.Namespace
+		case taskLabelJob:
+			value = cfg.JobName
+		case taskLabelTaskGroup:
+			value = cfg.TaskGroupName
+		case taskLabelLanguage:
+			value = language
+		default:
+			continue
+		}
+		pairs = append(pairs, label, value)
+	}
+	return pairs
+}
+
+// taskExitResult classifies a task's exit for metricTaskExits.
+func taskExitResult(result *drivers.ExitResult) string {
+	switch {
+	case result.Successful():
+		return "success"
+	case result.OOMKilled:
+		return "oom"
+	}
+	return "failure"
+}
+
+// recordTaskStart counts a started task.
+func (d *ElideDriverPlugin) recordTaskStart(h *taskHandle) {
+	d.metrics.IncrCounter(metricTaskStarts, "Tasks started, by the labels of metrics_task_labels.", TaskMetricLabels(h.taskConfig, h.language, d.config.MetricsTaskLabels)...)
+}
+
+// recordSubmission records the code size and language of a submitted
+// execution. The histogram's count doubles as the executions submitted per
+// language.
+func (d *ElideDriverPlugin) recordSubmission(language string, codeBytes int64) {
+	d.metrics.ObserveHistogram(metricExecutionCodeBytes, "Size of the code of submitted executions in bytes, by language.", codeSizeBuckets, float64(codeBytes), "language", language)
+}
+
+// recordTaskExit counts a task's exit by its result.
+func (d *ElideDriverPlugin) recordTaskExit(h *taskHandle, result *drivers.ExitResult) {
+	labels := append([]string{"result", taskExitResult(result)}, TaskMetricLabels(h.taskConfig, h.language, d.config.MetricsTaskLabels)...)
+	d.metrics.IncrCounter(metricTaskExits, "Tasks exited, by result and the labels of metrics_task_labels.", labels...)
+}
@@ -0,0 +1,41 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetricsRegistry_WriteHistogram(t *testing.T) {
+	m := newMetricsRegistry(hclog.NewNullLogger())
+	buckets := []float64{1, 5, 10}
+	// An observation on a bound falls in that bound's bucket
+	for _, value := range []float64{0.5, 3, 5, 20} {
+		m.ObserveHistogram("code_bytes", "Size of code.", buckets, value, "language", "python")
+	}
+	m.ObserveHistogram("code_bytes", "Size of code.", buckets, 7)
+
+	var buf strings.Builder
+	require.NoError(t, m.WritePrometheus(&buf))
+	assert.Equal(t, `# HELP elide_driver_code_bytes Size of code.
+# TYPE elide_driver_code_bytes histogram
+elide_driver_code_bytes_bucket{le="1"} 0
+elide_driver_code_bytes_bucket{le="5"} 0
+elide_driver_code_bytes_bucket{le="10"} 1
+elide_driver_code_bytes_bucket{le="+Inf"} 1
+elide_driver_code_bytes_sum 7
+elide_driver_code_bytes_count 1
+elide_driver_code_bytes_bucket{language="python",le="1"} 1
+elide_driver_code_bytes_bucket{language="python",le="5"} 3
+elide_driver_code_bytes_bucket{language="python",le="10"} 3
+elide_driver_code_bytes_bucket{language="python",le="+Inf"} 4
+elide_driver_code_bytes_sum{language="python"} 28.5
+elide_driver_code_bytes_count{language="python"} 4
+`, buf.String())
+}
@@ -0,0 +1,156 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/nomad/plugins/drivers"
+)
+
+// Policies for snippets importing denied modules
+const (
+	// deniedModulesReject fails the task before it reaches the daemon
+	deniedModulesReject = "reject"
+
+	// deniedModulesWarn logs and emits a task event, then runs the task
+	deniedModulesWarn = "warn"
+)
+
+var (
+	// pythonImportPatterns match `import a, b.c`, `from a import b`,
+	// `__import__("a")` and `importlib.import_module("a")`
+	pythonImportPatterns = []*regexp.Regexp{
+		regexp.MustCompile(`(?m)^[ \t]*import[ \t]+([\w.]+(?:[ \t]*(?:as[ \t]+\w+)?[ \t]*,[ \t]*[\w.]+)*)`),
+		regexp.MustCompile(`(?m)^[ \t]*from[ \t]+([\w.]+)[ \t]+import\b`),
+		regexp.MustCompile(`__import__\(\s*['"]([\w.]+)['"]`),
+		regexp.MustCompile(`import_module\(\s*['"]([\w.]+)['"]`),
+	}
+
+	// pythonImportListSeparator splits the module list of an import statement
+	pythonImportListSeparator = regexp.MustCompile(`[ \t]*,[ \t]*`)
+
+	// jsImportPatterns match `require("a")`, `import x from "a"`,
+	// `import "a"`, `import("a")` and `export ... from "a"`
+	jsImportPatterns = []*regexp.Regexp{
+		regexp.MustCompile(`\brequire\(\s*['"]([^'"]+)['"]\s*\)`),
+		regexp.MustCompile(`\b(?:import|export)\b[^'";]*?\bfrom\s*['"]([^'"]+)['"]`),
+		regexp.MustCompile(`(?m)^[ \t]*import\s*['"]([^'"]+)['"]`),
+		regexp.MustCompile(`\bimport\(\s*['"]([^'"]+)['"]\s*\)`),
+	}
+)
+
+// For returns the modules snippets of the given language may not import.
+func (c *DeniedModulesConfig) For(language string) []string {
+	switch language {
+	case "python":
+		return c.Python
+	case "javascript":
+		return c.JavaScript
+	case "typescript":
+		return c.TypeScript
+	}
+	return nil
+}
+
+// Validate checks the denied modules policy.
+func (c *DeniedModulesConfig) Validate() error {
+	switch c.Policy {
+	case "", deniedModulesReject, deniedModulesWarn:
+		return nil
+	}
+	return fmt.Errorf("invalid policy %q (must be %q or %q)", c.Policy, deniedModulesReject, deniedModulesWarn)
+}
+
+// ScanImports returns the modules a snippet imports, as found by a quick
+// static scan of its source. The scan sees literal import statements only;
+// it is a defense-in-depth check, not a sandbox.
+func ScanImports(language string, code string) []string {
+	seen := map[string]bool{}
+	var modules []string
+	add := func(module string) {
+		if module != "" && !seen[module] {
+			seen[module] = true
+			modules = append(modules, module)
+		}
+	}
+
+	switch language {
+	case "python":
+		for i, pattern := range pythonImportPatterns {
+			for _, match := range pattern.FindAllStringSubmatch(code, -1) {
+				if i > 0 {
+					add(match[1])
+					continue
+				}
+				for _, item := range pythonImportListSeparator.Split(match[1], -1) {
+					add(strings.Fields(item)[0])
+				}
+			}
+		}
+	case "javascript", "typescript":
+		for _, pattern := range jsImportPatterns {
+			for _, match := range pattern.FindAllStringSubmatch(code, -1) {
+				add(strings.TrimPrefix(match[1], "node:"))
+			}
+		}
+	}
+	return modules
+}
+
+// DeniedImports returns the denied modules a snippet imports, sorted. A
+// denied module also covers its submodules ("os" denies "os.path" in Python,
+// "fs" denies "fs/promises" in JavaScript).
+func DeniedImports(language string, code string, denied []string) []string {
+	if len(denied) == 0 {
+		return nil
+	}
+
+	separator := "/"
+	if language == "python" {
+		separator = "."
+	}
+
+	var found []string
+	for _, module := range ScanImports(language, code) {
+		for _, deniedModule := range denied {
+			if module == deniedModule || strings.HasPrefix(module, deniedModule+separator) {
+				found = append(found, module)
+				break
+			}
+		}
+	}
+	sort.Strings(found)
+	return found
+}
+
+// checkDeniedModules applies the denied modules policy to a task's code. It
+// returns an error if the task must be rejected.
+func (d *ElideDriverPlugin) checkDeniedModules(cfg *drivers.TaskConfig, language string, code string) error {
+	found := DeniedImports(language, code, d.config.DeniedModules.For(language))
+	if len(found) == 0 {
+		return nil
+	}
+
+	modules := strings.Join(found, ", ")
+	if d.config.DeniedModules.Policy == deniedModulesWarn {
+		d.logger.Warn("code imports denied modules", "task_id", cfg.ID, "modules", modules)
+		d.eventer.EmitEvent(&drivers.TaskEvent{
+			TaskID:    cfg.ID,
+			AllocID:   cfg.AllocID,
+			TaskName:  cfg.Name,
+			Timestamp: time.Now(),
+			Message:   fmt.Sprintf("Code imports denied modules: %s", modules),
+			Annotations: map[string]string{
+				"denied_modules": modules,
+			},
+		})
+		return nil
+	}
+	return fmt.Errorf("code imports denied modules: %s", modules)
+}
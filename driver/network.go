@@ -0,0 +1,80 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/nomad/plugins/drivers"
+)
+
+// netIntrinsic is the intrinsic giving executions network access
+const netIntrinsic = "net"
+
+// networkEnvPrefixes are the env vars Nomad sets for an alloc's allocated
+// ports: their host address and, in a bridge or CNI network, the address
+// inside the alloc's network namespace
+var networkEnvPrefixes = []string{
+	"NOMAD_IP_",
+	"NOMAD_PORT_",
+	"NOMAD_ADDR_",
+	"NOMAD_HOST_IP_",
+	"NOMAD_HOST_PORT_",
+	"NOMAD_HOST_ADDR_",
+	"NOMAD_ALLOC_IP_",
+	"NOMAD_ALLOC_PORT_",
+	"NOMAD_ALLOC_ADDR_",
+	"NOMAD_ALLOC_INTERFACE_",
+}
+
+// IsNetworked reports whether a task's alloc has a bridge or CNI network,
+// i.e. a network namespace of its own.
+func IsNetworked(cfg *drivers.TaskConfig) bool {
+	return cfg.NetworkIsolation != nil && cfg.NetworkIsolation.Mode == drivers.NetIsolationModeGroup
+}
+
+// NetworkEnv returns the env vars Nomad set for the allocated IPs and ports
+// of a task in a bridge or CNI network, or nil for other tasks. The driver
+// does not otherwise pass Nomad's env to executions.
+func NetworkEnv(cfg *drivers.TaskConfig) map[string]string {
+	if !IsNetworked(cfg) {
+		return nil
+	}
+	env := map[string]string{}
+	for name, value := range cfg.Env {
+		if slices.ContainsFunc(networkEnvPrefixes, func(prefix string) bool { return strings.HasPrefix(name, prefix) }) {
+			env[name] = value
+		}
+	}
+	return env
+}
+
+// mergeNetworkEnv adds the network env vars of a task in a bridge or CNI
+// network to env, taking precedence so the allocated addresses can be
+// trusted, and warns if the execution lacks the net intrinsic to use them.
+// intrinsics are the execution's: its sandbox profile's, else the session's.
+func (d *ElideDriverPlugin) mergeNetworkEnv(cfg *drivers.TaskConfig, env map[string]string, intrinsics []string) {
+	if !IsNetworked(cfg) {
+		return
+	}
+	maps.Copy(env, NetworkEnv(cfg))
+
+	if slices.Contains(intrinsics, netIntrinsic) {
+		return
+	}
+	d.logger.Warn("task runs in a bridge or CNI network without the net intrinsic", "task_id", cfg.ID)
+	d.eventer.EmitEvent(&drivers.TaskEvent{
+		TaskID:    cfg.ID,
+		AllocID:   cfg.AllocID,
+		TaskName:  cfg.Name,
+		Timestamp: time.Now(),
+		Message:   "Alloc has a bridge or CNI network, but the execution lacks the net intrinsic and cannot use it; enable net in session_config.enabled_intrinsics or use a profile that allows it",
+		Annotations: map[string]string{
+			"missing_intrinsic": netIntrinsic,
+		},
+	})
+}
@@ -0,0 +1,140 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/nomad/plugins/drivers"
+	"github.com/hashicorp/nomad/plugins/shared/structs"
+
+	pb "github.com/elide-dev/elide-task-driver/proto/gen/go/elide/daemon/v1alpha1"
+)
+
+// sysNodePath is where Linux describes the NUMA nodes of the host
+const sysNodePath = "/sys/devices/system/node"
+
+// hostNUMANodes reads the NUMA topology of the host once; it does not change
+// while the host is up.
+var hostNUMANodes = sync.OnceValue(func() map[int]string {
+	nodes, err := NUMANodes(sysNodePath)
+	if err != nil {
+		return nil
+	}
+	return nodes
+})
+
+// NUMANodes returns the CPU list (e.g. "0-15,32-47") of each NUMA node
+// described under root, normally /sys/devices/system/node. Hosts without NUMA
+// support have no nodes.
+func NUMANodes(root string) (map[int]string, error) {
+	dirs, err := filepath.Glob(filepath.Join(root, "node[0-9]*"))
+	if err != nil {
+		return nil, err
+	}
+	nodes := make(map[int]string, len(dirs))
+	for _, dir := range dirs {
+		id, err := strconv.Atoi(strings.TrimPrefix(filepath.Base(dir), "node"))
+		if err != nil {
+			continue
+		}
+		cpulist, err := os.ReadFile(filepath.Join(dir, "cpulist"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CPUs of NUMA node %d: %w", id, err)
+		}
+		nodes[id] = strings.TrimSpace(string(cpulist))
+	}
+	return nodes, nil
+}
+
+// ParseCPUList parses a Linux CPU list such as "0-3,8,10-11" into the sorted
+// CPU ids it contains.
+func ParseCPUList(cpulist string) ([]int, error) {
+	var cpus []int
+	for _, part := range strings.Split(strings.TrimSpace(cpulist), ",") {
+		if part == "" {
+			continue
+		}
+		first, last, isRange := strings.Cut(part, "-")
+		start, err := strconv.Atoi(first)
+		if err != nil || start < 0 {
+			return nil, fmt.Errorf("invalid CPU list %q", cpulist)
+		}
+		end := start
+		if isRange {
+			if end, err = strconv.Atoi(last); err != nil || end < start {
+				return nil, fmt.Errorf("invalid CPU list %q", cpulist)
+			}
+		}
+		for cpu := start; cpu <= end; cpu++ {
+			cpus = append(cpus, cpu)
+		}
+	}
+	slices.Sort(cpus)
+	return slices.Compact(cpus), nil
+}
+
+// numaAttributes adds the NUMA nodes of the host to the fingerprint, so jobs
+// can constrain their placement to hosts with the nodes they pin to.
+func numaAttributes(fp *drivers.Fingerprint, nodes map[int]string) {
+	if len(nodes) == 0 {
+		return
+	}
+	ids := make([]int, 0, len(nodes))
+	for id, cpulist := range nodes {
+		ids = append(ids, id)
+		fp.Attributes[fmt.Sprintf("driver.elide.numa.node%d.cpus", id)] = structs.NewStringAttribute(cpulist)
+	}
+	slices.Sort(ids)
+	names := make([]string, len(ids))
+	for i, id := range ids {
+		names[i] = strconv.Itoa(id)
+	}
+	fp.Attributes["driver.elide.numa.node_count"] = structs.NewIntAttribute(int64(len(ids)), "")
+	fp.Attributes["driver.elide.numa.nodes"] = structs.NewStringAttribute(strings.Join(names, ","))
+}
+
+// topologyHints returns where the daemon should pin the task's execution: the
+// task's elide_opts.numa_node and the cores Nomad reserved for the task. With
+// reserved cores but no numa_node, the node holding all of the cores (if any)
+// is hinted. Nil means no hints.
+func topologyHints(cfg *drivers.TaskConfig, taskConfig *TaskConfig, nodes map[int]string) (*pb.TopologyHints, error) {
+	var hints pb.TopologyHints
+	if cfg.Resources != nil && cfg.Resources.NomadResources != nil {
+		for _, core := range cfg.Resources.NomadResources.Cpu.ReservedCores {
+			hints.Cpus = append(hints.Cpus, uint32(core))
+		}
+	}
+
+	if node := taskConfig.ElideOpts.NumaNode; node != nil {
+		if _, ok := nodes[*node]; !ok && len(nodes) > 0 {
+			return nil, fmt.Errorf("elide_opts.numa_node %d does not exist on this host", *node)
+		}
+		numaNode := uint32(*node)
+		hints.NumaNode = &numaNode
+	} else if len(hints.Cpus) > 0 {
+		for id, cpulist := range nodes {
+			cpus, err := ParseCPUList(cpulist)
+			if err != nil {
+				continue
+			}
+			if !slices.ContainsFunc(hints.Cpus, func(cpu uint32) bool { return !slices.Contains(cpus, int(cpu)) }) {
+				numaNode := uint32(id)
+				hints.NumaNode = &numaNode
+				break
+			}
+		}
+	}
+
+	if hints.NumaNode == nil && len(hints.Cpus) == 0 {
+		return nil, nil
+	}
+	return &hints, nil
+}
@@ -0,0 +1,263 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/registry/remote"
+	"oras.land/oras-go/v2/registry/remote/auth"
+	"oras.land/oras-go/v2/registry/remote/credentials"
+	"oras.land/oras-go/v2/registry/remote/retry"
+)
+
+const (
+	// ociPullTimeout bounds pulling a snippet artifact from a registry
+	ociPullTimeout = 60 * time.Second
+
+	// maxOCIArtifactSize is the maximum size of the single layer of a snippet
+	// artifact. Snippet artifacts are meant to be small scripts or bundles.
+	maxOCIArtifactSize = 10 << 20
+
+	// ociEntrypointAnnotation names the entrypoint file inside a bundle layer
+	ociEntrypointAnnotation = "dev.elide.snippet.entrypoint"
+
+	// ociArtifactDir is where artifacts are unpacked, relative to the task
+	// directory
+	ociArtifactDir = "local/oci"
+)
+
+// fetchOCISnippet pulls a single-layer OCI artifact and returns the code of
+// its entrypoint. The layer is either the script itself or a tar (optionally
+// gzipped) bundle, which is unpacked into destDir. Manifest and layer digests
+// are verified while fetching.
+func fetchOCISnippet(ctx context.Context, ref string, entrypoint string, destDir string) (string, error) {
+	repo, err := remote.NewRepository(ref)
+	if err != nil {
+		return "", fmt.Errorf("invalid OCI reference %q: %w", ref, err)
+	}
+
+	credStore, err := credentials.NewStoreFromDocker(credentials.StoreOptions{})
+	if err != nil {
+		return "", fmt.Errorf("failed to load registry credentials: %w", err)
+	}
+	repo.Client = &auth.Client{
+		Client:     retry.DefaultClient,
+		Cache:      auth.NewCache(),
+		Credential: credentials.Credential(credStore),
+	}
+	return pullOCISnippet(ctx, repo, ref, entrypoint, destDir)
+}
+
+// pullOCISnippet pulls the artifact at ref from repo as fetchOCISnippet
+// does.
+func pullOCISnippet(ctx context.Context, repo *remote.Repository, ref string, entrypoint string, destDir string) (string, error) {
+	manifestDesc, manifestBytes, err := oras.FetchBytes(ctx, repo, ref, oras.DefaultFetchBytesOptions)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch manifest for %q: %w", ref, err)
+	}
+	if manifestDesc.MediaType != ocispec.MediaTypeImageManifest {
+		return "", fmt.Errorf("unsupported manifest media type %q", manifestDesc.MediaType)
+	}
+
+	var manifest ocispec.Manifest
+	if err := json.Unmarshal(manifestBytes, &manifest); err != nil {
+		return "", fmt.Errorf("failed to decode manifest: %w", err)
+	}
+	if len(manifest.Layers) != 1 {
+		return "", fmt.Errorf("OCI artifact must have exactly one layer, found %d", len(manifest.Layers))
+	}
+
+	layer := manifest.Layers[0]
+	if layer.Size > maxOCIArtifactSize {
+		return "", fmt.Errorf("OCI artifact layer is %d bytes, exceeds limit of %d bytes", layer.Size, maxOCIArtifactSize)
+	}
+
+	// FetchAll verifies the layer against its size and digest
+	data, err := content.FetchAll(ctx, repo, layer)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch layer %s: %w", layer.Digest, err)
+	}
+
+	if entrypoint == "" {
+		entrypoint = manifest.Annotations[ociEntrypointAnnotation]
+	}
+	if entrypoint == "" {
+		entrypoint = layer.Annotations[ociEntrypointAnnotation]
+	}
+
+	switch layer.MediaType {
+	case ocispec.MediaTypeImageLayer, ocispec.MediaTypeImageLayerGzip:
+		if entrypoint == "" {
+			return "", fmt.Errorf("bundle artifact requires an entrypoint (set code_oci_entrypoint or the %q annotation)", ociEntrypointAnnotation)
+		}
+		if err := unpackOCIBundle(data, layer.MediaType == ocispec.MediaTypeImageLayerGzip, destDir); err != nil {
+			return "", err
+		}
+	default:
+		// The layer is the script itself
+		name := layer.Annotations[ocispec.AnnotationTitle]
+		if name == "" {
+			name = "snippet"
+		}
+		if entrypoint == "" {
+			entrypoint = name
+		}
+		if entrypoint != name {
+			return "", fmt.Errorf("entrypoint %q not found in artifact (contains %q)", entrypoint, name)
+		}
+		if err := writeContained(destDir, name, bytes.NewReader(data), 0644); err != nil {
+			return "", err
+		}
+	}
+
+	entrypointPath, err := containedPath(destDir, entrypoint)
+	if err != nil {
+		return "", err
+	}
+	f, err := os.OpenFile(entrypointPath, os.O_RDONLY|syscall.O_NOFOLLOW, 0)
+	if err != nil {
+		return "", fmt.Errorf("failed to read entrypoint %q: %w", entrypoint, err)
+	}
+	defer f.Close()
+	code, err := io.ReadAll(f)
+	if err != nil {
+		return "", fmt.Errorf("failed to read entrypoint %q: %w", entrypoint, err)
+	}
+	return string(code), nil
+}
+
+// unpackOCIBundle extracts a tar bundle into destDir. Only regular files and
+// directories are extracted; entries escaping destDir are rejected, as are
+// bundles over 4*maxOCIArtifactSize bytes once decompressed.
+func unpackOCIBundle(data []byte, gzipped bool, destDir string) error {
+	var r io.Reader = bytes.NewReader(data)
+	if gzipped {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return fmt.Errorf("failed to decompress bundle: %w", err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	// Bound decompressed size to guard against decompression bombs
+	limit := int64(4 * maxOCIArtifactSize)
+	lr := &io.LimitedReader{R: r, N: limit + 1}
+	tr := tar.NewReader(lr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return bundleReadError(lr, limit, err)
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			dir, err := containedPath(destDir, hdr.Name)
+			if err != nil {
+				return err
+			}
+			if err := mkdirContained(destDir, dir); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := writeContained(destDir, hdr.Name, tr, 0644); err != nil {
+				return bundleReadError(lr, limit, err)
+			}
+		}
+	}
+}
+
+// bundleReadError reports err, or that the bundle exceeds limit if lr ran
+// past it.
+func bundleReadError(lr *io.LimitedReader, limit int64, err error) error {
+	if lr.N <= 0 {
+		return fmt.Errorf("bundle exceeds %d bytes decompressed", limit)
+	}
+	return fmt.Errorf("failed to read bundle: %w", err)
+}
+
+// containedPath joins name onto baseDir, rejecting absolute paths and paths
+// that escape it.
+func containedPath(baseDir string, name string) (string, error) {
+	if filepath.IsAbs(name) {
+		return "", fmt.Errorf("path %q is absolute", name)
+	}
+	baseDir = filepath.Clean(baseDir)
+	p := filepath.Clean(filepath.Join(baseDir, name))
+	if !strings.HasPrefix(p, baseDir+string(os.PathSeparator)) {
+		return "", fmt.Errorf("path %q escapes artifact directory", name)
+	}
+	return p, nil
+}
+
+// mkdirContained creates dir and its missing parents up from baseDir,
+// refusing any that is a symlink, which could point out of baseDir.
+func mkdirContained(baseDir string, dir string) error {
+	baseDir = filepath.Clean(baseDir)
+	rel, err := filepath.Rel(baseDir, dir)
+	if err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	p := baseDir
+	for _, part := range append([]string{"."}, strings.Split(rel, string(os.PathSeparator))...) {
+		p = filepath.Join(p, part)
+		info, err := os.Lstat(p)
+		if os.IsNotExist(err) {
+			// The parents of baseDir are the task's own
+			if err := os.MkdirAll(p, 0755); err != nil {
+				return fmt.Errorf("failed to create directory: %w", err)
+			}
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to create directory: %w", err)
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return fmt.Errorf("artifact directory %q is a symlink", p)
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("artifact path %q is not a directory", p)
+		}
+	}
+	return nil
+}
+
+// writeContained writes r to name inside baseDir, creating parent
+// directories. Neither the file nor its parents may be symlinks.
+func writeContained(baseDir string, name string, r io.Reader, mode os.FileMode) error {
+	p, err := containedPath(baseDir, name)
+	if err != nil {
+		return err
+	}
+	if err := mkdirContained(baseDir, filepath.Dir(p)); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(p, os.O_CREATE|os.O_WRONLY|os.O_TRUNC|syscall.O_NOFOLLOW, mode)
+	if err != nil {
+		return fmt.Errorf("failed to create %q: %w", name, err)
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write %q: %w", name, err)
+	}
+	return f.Close()
+}
@@ -0,0 +1,254 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	specs "github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"oras.land/oras-go/v2/registry/remote"
+)
+
+// tarEntry is an entry of a bundle built by tarBundle.
+type tarEntry struct {
+	name    string
+	content string
+	dir     bool
+	size    int64 // of zeroes, if content is empty
+}
+
+// tarBundle returns a tar of entries, gzipped if set.
+func tarBundle(t *testing.T, gzipped bool, entries ...tarEntry) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	var w io.Writer = &buf
+	var gz *gzip.Writer
+	if gzipped {
+		gz = gzip.NewWriter(&buf)
+		w = gz
+	}
+	tw := tar.NewWriter(w)
+	for _, e := range entries {
+		if e.dir {
+			require.NoError(t, tw.WriteHeader(&tar.Header{Name: e.name, Typeflag: tar.TypeDir, Mode: 0755}))
+			continue
+		}
+		size := int64(len(e.content))
+		if e.content == "" {
+			size = e.size
+		}
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: e.name, Typeflag: tar.TypeReg, Mode: 0644, Size: size}))
+		if e.content != "" {
+			_, err := tw.Write([]byte(e.content))
+			require.NoError(t, err)
+		} else {
+			_, err := io.CopyN(tw, zeroReader{}, size)
+			require.NoError(t, err)
+		}
+	}
+	require.NoError(t, tw.Close())
+	if gz != nil {
+		require.NoError(t, gz.Close())
+	}
+	return buf.Bytes()
+}
+
+// zeroReader reads zeroes forever.
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
+
+func TestUnpackOCIBundle(t *testing.T) {
+	dest := filepath.Join(t.TempDir(), "local", "oci")
+	data := tarBundle(t, true,
+		tarEntry{name: "lib/", dir: true},
+		tarEntry{name: "lib/util.py", content: "def f(): pass"},
+		tarEntry{name: "main.py", content: "print(1)"},
+		tarEntry{name: "nested/deep/x.py", content: "x = 1"},
+	)
+	require.NoError(t, unpackOCIBundle(data, true, dest))
+
+	for name, content := range map[string]string{"lib/util.py": "def f(): pass", "main.py": "print(1)", "nested/deep/x.py": "x = 1"} {
+		got, err := os.ReadFile(filepath.Join(dest, name))
+		require.NoError(t, err)
+		assert.Equal(t, content, string(got))
+	}
+}
+
+func TestUnpackOCIBundle_RejectsEscapingEntries(t *testing.T) {
+	for name, entry := range map[string]string{
+		"parent":          "../evil.py",
+		"nested parent":   "lib/../../evil.py",
+		"absolute":        "/evil.py",
+		"absolute nested": "/tmp/evil.py",
+	} {
+		t.Run(name, func(t *testing.T) {
+			root := t.TempDir()
+			dest := filepath.Join(root, "oci")
+			err := unpackOCIBundle(tarBundle(t, false, tarEntry{name: entry, content: "evil"}), false, dest)
+			assert.Error(t, err)
+			_, statErr := os.Stat(filepath.Join(root, "evil.py"))
+			assert.True(t, os.IsNotExist(statErr))
+		})
+	}
+}
+
+func TestUnpackOCIBundle_RefusesSymlinks(t *testing.T) {
+	outside := t.TempDir()
+	target := filepath.Join(outside, "target.py")
+	require.NoError(t, os.WriteFile(target, []byte("original"), 0644))
+
+	tests := []struct {
+		name  string
+		link  string
+		to    string
+		entry string
+	}{
+		{name: "parent directory", link: "lib", to: outside, entry: "lib/evil.py"},
+		{name: "directory entry", link: "lib", to: outside, entry: "lib/"},
+		{name: "file", link: "main.py", to: target, entry: "main.py"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dest := t.TempDir()
+			require.NoError(t, os.Symlink(tt.to, filepath.Join(dest, tt.link)))
+
+			entry := tarEntry{name: tt.entry, content: "evil"}
+			if strings.HasSuffix(tt.entry, "/") {
+				entry = tarEntry{name: tt.entry + "sub/", dir: true}
+			}
+			assert.Error(t, unpackOCIBundle(tarBundle(t, false, entry), false, dest))
+
+			got, err := os.ReadFile(target)
+			require.NoError(t, err)
+			assert.Equal(t, "original", string(got))
+			_, err = os.Lstat(filepath.Join(outside, "evil.py"))
+			assert.True(t, os.IsNotExist(err))
+			_, err = os.Lstat(filepath.Join(outside, "sub"))
+			assert.True(t, os.IsNotExist(err))
+		})
+	}
+}
+
+func TestUnpackOCIBundle_GzipBomb(t *testing.T) {
+	data := tarBundle(t, true, tarEntry{name: "bomb", size: 4*maxOCIArtifactSize + 1})
+	require.Less(t, len(data), maxOCIArtifactSize, "the bomb fits the layer limit")
+
+	err := unpackOCIBundle(data, true, t.TempDir())
+	assert.ErrorContains(t, err, "bundle exceeds 41943040 bytes decompressed")
+}
+
+// testRegistry serves a single-layer artifact as snippets:v1.
+func testRegistry(t *testing.T, layer ocispec.Descriptor, blob []byte, annotations map[string]string) *remote.Repository {
+	t.Helper()
+	layer.Digest, layer.Size = digest.FromBytes(blob), int64(len(blob))
+	manifest, err := json.Marshal(ocispec.Manifest{
+		Versioned:   specs.Versioned{SchemaVersion: 2},
+		MediaType:   ocispec.MediaTypeImageManifest,
+		Config:      ocispec.DescriptorEmptyJSON,
+		Layers:      []ocispec.Descriptor{layer},
+		Annotations: annotations,
+	})
+	require.NoError(t, err)
+
+	serve := func(w http.ResponseWriter, r *http.Request, mediaType string, body []byte) {
+		w.Header().Set("Content-Type", mediaType)
+		w.Header().Set("Docker-Content-Digest", digest.FromBytes(body).String())
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		if r.Method != http.MethodHead {
+			w.Write(body)
+		}
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/snippets/manifests/v1", "/v2/snippets/manifests/" + digest.FromBytes(manifest).String():
+			serve(w, r, ocispec.MediaTypeImageManifest, manifest)
+		case "/v2/snippets/blobs/" + layer.Digest.String():
+			serve(w, r, "application/octet-stream", blob)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	repo, err := remote.NewRepository(strings.TrimPrefix(server.URL, "http://") + "/snippets")
+	require.NoError(t, err)
+	repo.PlainHTTP = true
+	return repo
+}
+
+func TestPullOCISnippet_Entrypoint(t *testing.T) {
+	bundle := tarBundle(t, true,
+		tarEntry{name: "main.py", content: "print('main')"},
+		tarEntry{name: "other.py", content: "print('other')"},
+	)
+	gzipLayer := ocispec.Descriptor{MediaType: ocispec.MediaTypeImageLayerGzip}
+	annotated := map[string]string{ociEntrypointAnnotation: "main.py"}
+
+	tests := []struct {
+		name        string
+		layer       ocispec.Descriptor
+		blob        []byte
+		annotations map[string]string
+		entrypoint  string
+		code        string
+		err         string
+	}{
+		{name: "manifest annotation", layer: gzipLayer, blob: bundle, annotations: annotated, code: "print('main')"},
+		{name: "option over annotation", layer: gzipLayer, blob: bundle, annotations: annotated, entrypoint: "other.py", code: "print('other')"},
+		{
+			name:  "layer annotation",
+			layer: ocispec.Descriptor{MediaType: ocispec.MediaTypeImageLayerGzip, Annotations: map[string]string{ociEntrypointAnnotation: "other.py"}},
+			blob:  bundle,
+			code:  "print('other')",
+		},
+		{name: "bundle without entrypoint", layer: gzipLayer, blob: bundle, err: "bundle artifact requires an entrypoint"},
+		{name: "entrypoint missing from bundle", layer: gzipLayer, blob: bundle, entrypoint: "missing.py", err: `failed to read entrypoint "missing.py"`},
+		{name: "entrypoint escaping bundle", layer: gzipLayer, blob: bundle, entrypoint: "../main.py", err: "escapes artifact directory"},
+		{
+			name:  "script layer",
+			layer: ocispec.Descriptor{MediaType: "application/vnd.elide.snippet.v1", Annotations: map[string]string{ocispec.AnnotationTitle: "hello.py"}},
+			blob:  []byte("print('hello')"),
+			code:  "print('hello')",
+		},
+		{
+			name:       "script layer of another name",
+			layer:      ocispec.Descriptor{MediaType: "application/vnd.elide.snippet.v1", Annotations: map[string]string{ocispec.AnnotationTitle: "hello.py"}},
+			blob:       []byte("print('hello')"),
+			entrypoint: "main.py",
+			err:        `entrypoint "main.py" not found in artifact (contains "hello.py")`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := testRegistry(t, tt.layer, tt.blob, tt.annotations)
+			code, err := pullOCISnippet(context.Background(), repo, repo.Reference.String()+":v1", tt.entrypoint, filepath.Join(t.TempDir(), "oci"))
+			if tt.err != "" {
+				assert.ErrorContains(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.code, code)
+		})
+	}
+}
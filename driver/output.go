@@ -0,0 +1,455 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"cmp"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/hashicorp/nomad/client/lib/fifo"
+	"github.com/hashicorp/nomad/plugins/drivers"
+
+	pb "github.com/elide-dev/elide-task-driver/proto/gen/go/elide/daemon/v1alpha1"
+)
+
+// Output modes for the output_mode task option
+const (
+	// outputModeDiscard drops output; the daemon is told not to capture it
+	outputModeDiscard = "discard"
+
+	// outputModeLog ships output to the task's Nomad log streams
+	outputModeLog = "log"
+
+	// outputModeFile writes output to files in the task directory
+	outputModeFile = "file"
+
+	// outputModeBoth ships output to the log streams and to files
+	outputModeBoth = "both"
+)
+
+// Binary output modes for the binary_output task option, applied to log
+// streams once they carry output that is not valid UTF-8
+const (
+	// binaryOutputBase64 ships the binary output base64-encoded, one line per
+	// chunk received from the daemon
+	binaryOutputBase64 = "base64"
+
+	// binaryOutputRaw ships the binary output unchanged
+	binaryOutputRaw = "raw"
+
+	// binaryOutputFile writes the binary output to a file in the task
+	// directory instead of the log stream
+	binaryOutputFile = "file"
+)
+
+const (
+	// stdoutFileName and stderrFileName are where output is written in the
+	// file and both modes, relative to the task directory
+	stdoutFileName = "local/elide-stdout"
+	stderrFileName = "local/elide-stderr"
+
+	// binaryFileSuffix is appended to the output file names for the binary
+	// output of log streams in binary_output "file" mode
+	binaryFileSuffix = ".bin"
+)
+
+// validateOutputMode checks an output_mode value.
+func validateOutputMode(mode string) error {
+	switch mode {
+	case "", outputModeDiscard, outputModeLog, outputModeFile, outputModeBoth:
+		return nil
+	}
+	return fmt.Errorf("invalid output_mode %q (must be %q, %q, %q or %q)",
+		mode, outputModeDiscard, outputModeLog, outputModeFile, outputModeBoth)
+}
+
+// validateBinaryOutput checks a binary_output value.
+func validateBinaryOutput(mode string) error {
+	switch mode {
+	case "", binaryOutputBase64, binaryOutputRaw, binaryOutputFile:
+		return nil
+	}
+	return fmt.Errorf("invalid binary_output %q (must be %q, %q or %q)",
+		mode, binaryOutputBase64, binaryOutputRaw, binaryOutputFile)
+}
+
+// shipOutput delivers the output of a completed execution according to the
+// task's output mode. Output already shipped to the log streams while the
+// execution ran is not shipped again. segments is the order the output was
+// written in, for tasks combining their output.
+func (h *taskHandle) shipOutput(stdout string, stderr string, segments []*pb.OutputSegment) error {
+	mode := h.outputMode
+	if mode == "" {
+		mode = outputModeLog
+	}
+
+	if mode == outputModeLog || mode == outputModeBoth {
+		err := h.logs.ship(h.taskConfig, stdout, stderr, segments)
+		h.logs.close()
+		if err != nil {
+			return err
+		}
+	}
+
+	if mode == outputModeFile || mode == outputModeBoth {
+		dir := h.taskConfig.TaskDir().Dir
+		if err := writeOutputFile(filepath.Join(dir, stdoutFileName), stdout); err != nil {
+			return fmt.Errorf("failed to write stdout file: %w", err)
+		}
+		if err := writeOutputFile(filepath.Join(dir, stderrFileName), stderr); err != nil {
+			return fmt.Errorf("failed to write stderr file: %w", err)
+		}
+	}
+	return nil
+}
+
+// combineOutput reports whether a starting task's log streams combine its
+// output. Without a daemon reporting the order of output, stdout and stderr
+// are shipped to their own streams, with a task event saying so.
+func (d *ElideDriverPlugin) combineOutput(h *taskHandle, tc *TaskConfig) bool {
+	if !tc.CombineOutput {
+		return false
+	}
+	if d.supportsOutputOrder.Load() {
+		return true
+	}
+	h.logger.Warn("daemon does not report the order of output, not combining stdout and stderr")
+	d.eventer.EmitEvent(&drivers.TaskEvent{
+		TaskID:    h.taskConfig.ID,
+		AllocID:   h.taskConfig.AllocID,
+		TaskName:  h.taskConfig.Name,
+		Timestamp: time.Now(),
+		Message:   "Daemon does not report the order of output, stdout and stderr are logged separately",
+	})
+	return false
+}
+
+// shipRunningOutput ships the output a running execution produced so far to
+// the task's log streams, so `nomad alloc logs -f` follows it as it runs.
+// output is the output the status was requested with.
+func (h *taskHandle) shipRunningOutput(status *pb.GetExecutionStatusResponse, output OutputRequest) error {
+	if !h.shipsRunningOutput() || output.Omit {
+		return nil
+	}
+	return h.logs.shipFrom(h.taskConfig, output.StdoutOffset, status.Stdout, output.StderrOffset, status.Stderr, status.OutputSegments, false)
+}
+
+// shipsRunningOutput reports whether the task ships output while its
+// execution runs.
+func (h *taskHandle) shipsRunningOutput() bool {
+	return h.outputMode == "" || h.outputMode == outputModeLog || h.outputMode == outputModeBoth
+}
+
+// statusOutput returns the output to request with a status poll of a running
+// task. Daemons supporting output offsets send only the output the task has
+// not shipped yet, and none to tasks that only need it on completion; others
+// send all output with every status.
+func (d *ElideDriverPlugin) statusOutput(h *taskHandle) OutputRequest {
+	if !d.supportsOutputOffsets.Load() {
+		return OutputRequest{Order: h.logs.combined}
+	}
+	if !h.shipsRunningOutput() {
+		return OutputRequest{Omit: true}
+	}
+	stdout, stderr := h.logs.offsets()
+	return OutputRequest{StdoutOffset: stdout, StderrOffset: stderr, Order: h.logs.combined}
+}
+
+// pollStatus polls the status of an execution with the given output. The
+// status of a completed execution is fetched again with all of its output
+// unless that was requested already, so it can be delivered in full.
+func (d *ElideDriverPlugin) pollStatus(ctx context.Context, sessionID string, executionID string, output OutputRequest) (*pb.GetExecutionStatusResponse, error) {
+	statusCtx, cancel := d.withTimeout(ctx, d.statusTimeout())
+	defer cancel()
+
+	statusResp, err := d.daemonClient.GetExecutionStatus(statusCtx, sessionID, executionID, output)
+	all := OutputRequest{Order: output.Order}
+	if err != nil || !statusResp.Complete || output == all {
+		return statusResp, err
+	}
+	return d.daemonClient.GetExecutionStatus(statusCtx, sessionID, executionID, all)
+}
+
+// logShipper writes execution output to the log FIFOs Nomad's log collector
+// reads from. The daemon reports the output produced so far, from the start or
+// from the requested offsets, so only the part not yet shipped is written. The
+// FIFOs stay open until the execution completes or the task is destroyed.
+//
+// A stream turns binary once its output is not valid UTF-8: a marker line
+// says so, and the rest of its output is shipped according to binaryOutput.
+//
+// Combined output goes to the stdout log only, stdout and stderr interleaved
+// in the order the daemon reports they were written in.
+type logShipper struct {
+	mu     sync.Mutex
+	stdout logStream
+	stderr logStream
+
+	// binaryOutput is the task's binary_output mode ("" = base64)
+	binaryOutput string
+
+	// combined is set for tasks with combine_output run by a daemon
+	// reporting the order of their output
+	combined bool
+}
+
+// ship writes the new part of the final output to the log streams.
+func (l *logShipper) ship(cfg *drivers.TaskConfig, stdout string, stderr string, segments []*pb.OutputSegment) error {
+	return l.shipFrom(cfg, 0, stdout, 0, stderr, segments, true)
+}
+
+// shipFrom writes the new part of output starting at the given offsets to the
+// log streams. Unless the output is final, a multi-byte character cut off at
+// its end is held back until the rest of it arrives, and so is combined
+// output the daemon did not report the order of yet.
+func (l *logShipper) shipFrom(cfg *drivers.TaskConfig, stdoutOffset uint64, stdout string, stderrOffset uint64, stderr string, segments []*pb.OutputSegment, final bool) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	dir := cfg.TaskDir().Dir
+	stdoutBinary := binaryTarget{mode: l.binaryOutput, stream: "stdout", dir: dir, file: stdoutFileName + binaryFileSuffix}
+	stderrBinary := binaryTarget{mode: l.binaryOutput, stream: "stderr", dir: dir, file: stderrFileName + binaryFileSuffix}
+	if l.combined {
+		l.stderr.out = &l.stdout
+		for _, write := range OrderOutput(stdoutOffset, stdout, stderrOffset, stderr, segments, final) {
+			stream, binary := &l.stdout, stdoutBinary
+			if write.Stream == pb.OutputStream_OUTPUT_STREAM_STDERR {
+				stream, binary = &l.stderr, stderrBinary
+			}
+			if err := stream.ship(cfg.StdoutPath, int(write.Offset), write.Data, final, binary); err != nil {
+				return fmt.Errorf("failed to write combined log: %w", err)
+			}
+		}
+		return nil
+	}
+
+	if err := l.stdout.ship(cfg.StdoutPath, int(stdoutOffset), stdout, final, stdoutBinary); err != nil {
+		return fmt.Errorf("failed to write stdout log: %w", err)
+	}
+	if err := l.stderr.ship(cfg.StderrPath, int(stderrOffset), stderr, final, stderrBinary); err != nil {
+		return fmt.Errorf("failed to write stderr log: %w", err)
+	}
+	return nil
+}
+
+// OutputWrite is a write of an execution to one of its output streams.
+type OutputWrite struct {
+	Stream pb.OutputStream
+	// Offset is the byte offset of Data in the stream
+	Offset uint64
+	Data   string
+}
+
+// OrderOutput interleaves the stdout and stderr carried by a status, each
+// starting at its offset, in the order the daemon reported they were
+// written in: the part of each segment the status carries, by sequence
+// number. Output no segment covers is left out, unless the output is final,
+// in which case it follows the segments, stdout first.
+func OrderOutput(stdoutOffset uint64, stdout string, stderrOffset uint64, stderr string, segments []*pb.OutputSegment, final bool) []OutputWrite {
+	segments = slices.Clone(segments)
+	slices.SortFunc(segments, func(a, b *pb.OutputSegment) int {
+		return cmp.Compare(a.GetSequence(), b.GetSequence())
+	})
+
+	writes := make([]OutputWrite, 0, len(segments)+2)
+	for _, segment := range segments {
+		offset, output := stdoutOffset, stdout
+		switch segment.GetStream() {
+		case pb.OutputStream_OUTPUT_STREAM_STDOUT:
+		case pb.OutputStream_OUTPUT_STREAM_STDERR:
+			offset, output = stderrOffset, stderr
+		default:
+			continue
+		}
+		start := max(segment.GetOffset(), offset)
+		end := min(segment.GetOffset()+segment.GetLength(), offset+uint64(len(output)))
+		if start >= end {
+			continue
+		}
+		writes = append(writes, OutputWrite{Stream: segment.GetStream(), Offset: start, Data: output[start-offset : end-offset]})
+	}
+
+	if final {
+		writes = append(writes,
+			OutputWrite{Stream: pb.OutputStream_OUTPUT_STREAM_STDOUT, Offset: stdoutOffset, Data: stdout},
+			OutputWrite{Stream: pb.OutputStream_OUTPUT_STREAM_STDERR, Offset: stderrOffset, Data: stderr},
+		)
+	}
+	return writes
+}
+
+// offsets returns how much stdout and stderr was shipped.
+func (l *logShipper) offsets() (stdout uint64, stderr uint64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return uint64(l.stdout.shipped), uint64(l.stderr.shipped)
+}
+
+// close closes the log streams.
+func (l *logShipper) close() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.stdout.close()
+	l.stderr.close()
+}
+
+// logStream is one log FIFO and how much output was written to it.
+type logStream struct {
+	w       io.WriteCloser
+	shipped int
+
+	// binary is set once the stream carried output that is not valid UTF-8
+	binary bool
+
+	// out is the stream whose FIFO the output is written to instead, such
+	// as stdout's for combined output
+	out *logStream
+}
+
+// binaryTarget says how a log stream ships binary output: the
+// binary_output mode, the stream's name and, for the "file" mode, the task
+// directory and the file relative to it.
+type binaryTarget struct {
+	mode   string
+	stream string
+	dir    string
+	file   string
+}
+
+// ship writes the part of output past what was shipped already, output
+// starting at offset in the stream. It is a no-op if Nomad gave no path or
+// there is no new output, and if output starts past what was shipped.
+func (s *logStream) ship(path string, offset int, output string, final bool, binary binaryTarget) error {
+	if path == "" || offset+len(output) <= s.shipped || offset > s.shipped {
+		return nil
+	}
+	chunk := output[s.shipped-offset:]
+
+	turned := false
+	if !s.binary {
+		text := chunk
+		if !final {
+			text = chunk[:completeRunesLen(chunk)]
+		}
+		if utf8.ValidString(text) {
+			return s.write(path, text, len(text))
+		}
+		s.binary, turned = true, true
+		if err := s.write(path, binaryMarker(binary), 0); err != nil {
+			return err
+		}
+	}
+
+	switch binary.mode {
+	case binaryOutputRaw:
+		return s.write(path, chunk, len(chunk))
+	case binaryOutputFile:
+		if err := appendBinaryFile(filepath.Join(binary.dir, binary.file), chunk, turned); err != nil {
+			return err
+		}
+		s.shipped += len(chunk)
+		return nil
+	default:
+		return s.write(path, base64.StdEncoding.EncodeToString([]byte(chunk))+"\n", len(chunk))
+	}
+}
+
+// write writes data to the FIFO, opening it if needed, and counts shipped of
+// the stream's output as shipped once data was written in full.
+func (s *logStream) write(path string, data string, shipped int) error {
+	if data == "" {
+		return nil
+	}
+	fifoStream := s
+	if s.out != nil {
+		fifoStream = s.out
+	}
+	if fifoStream.w == nil {
+		w, err := fifo.OpenWriter(path)
+		if err != nil {
+			return err
+		}
+		fifoStream.w = w
+	}
+
+	if _, err := io.WriteString(fifoStream.w, data); err != nil {
+		return err
+	}
+	s.shipped += shipped
+	return nil
+}
+
+// close closes the FIFO; output shipped later reopens it.
+func (s *logStream) close() {
+	if s.w != nil {
+		s.w.Close()
+		s.w = nil
+	}
+}
+
+// binaryMarker returns the line marking where a log stream turned binary.
+func binaryMarker(binary binaryTarget) string {
+	switch binary.mode {
+	case binaryOutputRaw:
+		return fmt.Sprintf("\n[elide] binary %s follows as raw bytes\n", binary.stream)
+	case binaryOutputFile:
+		return fmt.Sprintf("\n[elide] binary %s written to %s in the task directory\n", binary.stream, binary.file)
+	default:
+		return fmt.Sprintf("\n[elide] binary %s follows base64-encoded, one line per chunk\n", binary.stream)
+	}
+}
+
+// completeRunesLen returns the length of output without a multi-byte
+// character cut off at its end.
+func completeRunesLen(output string) int {
+	for i := len(output) - 1; i >= 0 && i >= len(output)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(output[i]) {
+			if utf8.FullRuneInString(output[i:]) {
+				return len(output)
+			}
+			return i
+		}
+	}
+	return len(output)
+}
+
+// appendBinaryFile appends binary output to a file, replacing the file's
+// previous contents if truncate is set.
+func appendBinaryFile(path string, output string, truncate bool) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	flags := os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	if truncate {
+		flags |= os.O_TRUNC
+	}
+	f, err := os.OpenFile(path, flags, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(output); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// writeOutputFile writes output to a file, replacing any previous contents.
+func writeOutputFile(path string, output string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(output), 0644)
+}
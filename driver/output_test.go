@@ -0,0 +1,113 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/nomad/plugins/drivers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	pb "github.com/elide-dev/elide-task-driver/proto/gen/go/elide/daemon/v1alpha1"
+)
+
+// outputStatus returns the status of an execution that wrote stdout and
+// stderr so far, with the output requested: from the requested offsets, or
+// all of it.
+func outputStatus(stdout string, stderr string, output OutputRequest, complete bool) *pb.GetExecutionStatusResponse {
+	status := &pb.GetExecutionStatusResponse{
+		Status: pb.ExecutionStatus_EXECUTION_STATUS_RUNNING,
+		Stdout: stdout[output.StdoutOffset:],
+		Stderr: stderr[output.StderrOffset:],
+	}
+	if complete {
+		status.Status, status.Complete = pb.ExecutionStatus_EXECUTION_STATUS_COMPLETED, true
+		status.Stdout, status.Stderr = stdout, stderr
+	}
+	return status
+}
+
+func TestApplyStatus_ShipsRunningOutput(t *testing.T) {
+	polls := []struct{ stdout, stderr string }{
+		{"", ""},
+		{"a\n", ""},
+		{"a\nb\n", "warn 1\n"},
+		{"a\nb\n", "warn 1\n"},
+		// A character cut off at the end of a poll is held back
+		{"a\nb\nc \xc3", "warn 1\n"},
+		{"a\nb\nc \xc3\xa9\n", "warn 1\nwarn 2\n"},
+	}
+	final := struct{ stdout, stderr string }{"a\nb\nc \xc3\xa9\nd\n", "warn 1\nwarn 2\n"}
+
+	for _, offsets := range []bool{true, false} {
+		name := "full output"
+		if offsets {
+			name = "output offsets"
+		}
+		t.Run(name, func(t *testing.T) {
+			d := NewPlugin(hclog.NewNullLogger()).(*ElideDriverPlugin)
+			t.Cleanup(d.Shutdown)
+			d.supportsOutputOffsets.Store(offsets)
+
+			dir := t.TempDir()
+			cfg := &drivers.TaskConfig{
+				ID:         "task-1",
+				Name:       "task",
+				AllocDir:   dir,
+				StdoutPath: filepath.Join(dir, "stdout"),
+				StderrPath: filepath.Join(dir, "stderr"),
+			}
+			// Nomad's log FIFOs exist before the task starts
+			require.NoError(t, os.WriteFile(cfg.StdoutPath, nil, 0644))
+			require.NoError(t, os.WriteFile(cfg.StderrPath, nil, 0644))
+			h := d.recoveredHandle(&TaskState{TaskConfig: cfg, ExecutionId: "exec-1", SessionId: "session-1", StartedAt: time.Now()})
+			t.Cleanup(h.logs.close)
+
+			logs := func() (string, string) {
+				stdout, err := os.ReadFile(cfg.StdoutPath)
+				require.NoError(t, err)
+				stderr, err := os.ReadFile(cfg.StderrPath)
+				require.NoError(t, err)
+				return string(stdout), string(stderr)
+			}
+
+			var lastScratchCheck time.Time
+			for i, poll := range polls {
+				output := d.statusOutput(h)
+				if offsets {
+					// Only the output not shipped yet is requested
+					stdout, stderr := logs()
+					assert.Equal(t, OutputRequest{StdoutOffset: uint64(len(stdout)), StderrOffset: uint64(len(stderr))}, output, "poll %d", i)
+				} else {
+					assert.Equal(t, OutputRequest{}, output, "poll %d", i)
+				}
+				result, done := d.applyStatus(h, outputStatus(poll.stdout, poll.stderr, output, false), output, &lastScratchCheck)
+				require.False(t, done)
+				require.Nil(t, result)
+
+				stdout, stderr := logs()
+				want := poll.stdout
+				if i == 4 {
+					want = "a\nb\nc "
+				}
+				assert.Equal(t, want, stdout, "stdout after poll %d", i)
+				assert.Equal(t, poll.stderr, stderr, "stderr after poll %d", i)
+			}
+
+			// The final status carries all output; only the rest is shipped
+			output := OutputRequest{}
+			result, done := d.applyStatus(h, outputStatus(final.stdout, final.stderr, output, true), output, &lastScratchCheck)
+			require.True(t, done)
+			assert.True(t, result.Successful())
+			stdout, stderr := logs()
+			assert.Equal(t, final.stdout, stdout)
+			assert.Equal(t, final.stderr, stderr)
+		})
+	}
+}
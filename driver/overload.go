@@ -0,0 +1,89 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	// retryAfterMetadataKey is the trailer the daemon sets on ResourceExhausted
+	// responses to say how long to back off, in whole seconds
+	retryAfterMetadataKey = "retry-after"
+
+	// defaultOverloadRetryAfter is the backoff used when an overloaded daemon
+	// sends no retry-after hint
+	defaultOverloadRetryAfter = time.Second
+
+	// maxOverloadRetryAfter caps the backoff requested by the daemon
+	maxOverloadRetryAfter = 5 * time.Second
+)
+
+// Daemon overload metric names
+const (
+	metricDaemonOverloaded = "daemon_overloaded_total"
+)
+
+// DaemonOverloadedError is returned when the daemon sheds a request with
+// ResourceExhausted. RetryAfter is the daemon's backoff hint, or zero if it
+// sent none.
+type DaemonOverloadedError struct {
+	RetryAfter time.Duration
+	Err        error
+}
+
+func (e *DaemonOverloadedError) Error() string {
+	if e.RetryAfter > 0 {
+		return fmt.Sprintf("daemon overloaded (retry after %s): %v", e.RetryAfter, e.Err)
+	}
+	return fmt.Sprintf("daemon overloaded: %v", e.Err)
+}
+
+func (e *DaemonOverloadedError) Unwrap() error {
+	return e.Err
+}
+
+// ParseRetryAfter returns the backoff hint from a response trailer, or zero if
+// it is missing or malformed.
+func ParseRetryAfter(md metadata.MD) time.Duration {
+	values := md.Get(retryAfterMetadataKey)
+	if len(values) == 0 {
+		return 0
+	}
+	seconds, err := strconv.Atoi(values[0])
+	if err != nil || seconds <= 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// overloadBackoff returns how long to wait before retrying a shed request.
+func overloadBackoff(err *DaemonOverloadedError) time.Duration {
+	delay := err.RetryAfter
+	if delay <= 0 {
+		delay = defaultOverloadRetryAfter
+	}
+	return jitter(min(delay, maxOverloadRetryAfter))
+}
+
+// waitOverloaded sleeps for the backoff requested by an overloaded daemon. It
+// returns false if ctx ends first, meaning there is no time left to retry.
+func waitOverloaded(ctx context.Context, err *DaemonOverloadedError) bool {
+	delay := overloadBackoff(err)
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+		return false
+	}
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(delay):
+		return true
+	}
+}
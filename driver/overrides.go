@@ -0,0 +1,49 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"time"
+
+	pb "github.com/elide-dev/elide-task-driver/proto/gen/go/elide/daemon/v1alpha1"
+)
+
+// executionOverrides returns the per-task overrides of the session
+// configuration sent with an execution: the task's elide_opts memory_limit
+// and enable_ai, the timeout the driver enforces on it, and the time zone and
+// locale it runs with. Nil means none.
+func executionOverrides(opts ElideOptions, timeout time.Duration, timezone string, locale string) *pb.ExecutionOverrides {
+	var overrides pb.ExecutionOverrides
+	if opts.MemoryLimit > 0 {
+		memoryLimitMB := uint32(opts.MemoryLimit)
+		overrides.MemoryLimitMb = &memoryLimitMB
+	}
+	if opts.EnableAI {
+		overrides.EnableAi = &opts.EnableAI
+	}
+	if timeout > 0 {
+		timeoutMS := uint64(timeout.Milliseconds())
+		overrides.TimeoutMs = &timeoutMS
+	}
+	if timezone != "" {
+		overrides.Timezone = &timezone
+	}
+	if locale != "" {
+		overrides.Locale = &locale
+	}
+
+	if overrides.MemoryLimitMb == nil && overrides.EnableAi == nil && overrides.TimeoutMs == nil &&
+		overrides.Timezone == nil && overrides.Locale == nil {
+		return nil
+	}
+	return &overrides
+}
+
+// oomKilled reports whether the execution was cancelled for exceeding its
+// memory limit.
+func (h *taskHandle) oomKilled() bool {
+	h.stateLock.RLock()
+	defer h.stateLock.RUnlock()
+	return h.cancelErr != nil && h.cancelReason == pb.CancellationReason_CANCELLATION_REASON_OOM
+}
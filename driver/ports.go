@@ -0,0 +1,102 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/hashicorp/nomad/plugins/drivers"
+
+	pb "github.com/elide-dev/elide-task-driver/proto/gen/go/elide/daemon/v1alpha1"
+)
+
+// portEnvPrefix is the env var telling an execution the port to listen on
+// for a label, as Nomad names it
+const portEnvPrefix = "NOMAD_PORT_"
+
+// validatePorts checks the ports and expose options of a task config.
+func (tc *TaskConfig) validatePorts() error {
+	seen := make(map[string]bool, len(tc.Ports))
+	for _, label := range tc.Ports {
+		if label == "" {
+			return fmt.Errorf("ports cannot contain an empty label")
+		}
+		if seen[label] {
+			return fmt.Errorf("port %q is listed twice in ports", label)
+		}
+		seen[label] = true
+	}
+	if tc.Expose && len(tc.Ports) == 0 {
+		return &FieldError{Field: "expose", Err: fmt.Errorf("'expose' requires 'ports'")}
+	}
+	return nil
+}
+
+// PortBindings returns the bindings of the ports a task serves on: each label
+// must be a port allocated to the task's group. The execution listens on the
+// port's "to", or on the allocated port itself if unset.
+func PortBindings(cfg *drivers.TaskConfig, labels []string) ([]*pb.PortBinding, error) {
+	if len(labels) == 0 {
+		return nil, nil
+	}
+	var allocated map[string]*pb.PortBinding
+	if cfg.Resources != nil && cfg.Resources.Ports != nil {
+		allocated = make(map[string]*pb.PortBinding, len(*cfg.Resources.Ports))
+		for _, port := range *cfg.Resources.Ports {
+			listen := port.To
+			if listen <= 0 {
+				listen = port.Value
+			}
+			allocated[port.Label] = &pb.PortBinding{
+				Label:    port.Label,
+				HostIp:   port.HostIP,
+				HostPort: uint32(port.Value),
+				Port:     uint32(listen),
+			}
+		}
+	}
+
+	bindings := make([]*pb.PortBinding, 0, len(labels))
+	for _, label := range labels {
+		binding, ok := allocated[label]
+		if !ok {
+			return nil, fmt.Errorf("port %q is not allocated to the task's group network", label)
+		}
+		bindings = append(bindings, binding)
+	}
+	return bindings, nil
+}
+
+// DriverNetworkFor returns the network Nomad registers a task's services
+// with: the ports the execution listens on, by label, and the address the
+// daemon reported for them. With expose set, services advertise that
+// address instead of the host's. It returns nil for tasks without ports.
+func DriverNetworkFor(bindings []*pb.PortBinding, resp *pb.ExecuteSnippetResponse, expose bool) *drivers.DriverNetwork {
+	if len(bindings) == 0 {
+		return nil
+	}
+	if len(resp.GetPorts()) > 0 {
+		bindings = resp.GetPorts()
+	}
+	network := &drivers.DriverNetwork{
+		PortMap:       make(map[string]int, len(bindings)),
+		IP:            resp.GetIp(),
+		AutoAdvertise: expose && resp.GetIp() != "",
+	}
+	for _, binding := range bindings {
+		network.PortMap[binding.Label] = int(binding.Port)
+	}
+	return network
+}
+
+// portEnv returns the env vars telling an execution the port to listen on
+// for each label.
+func portEnv(bindings []*pb.PortBinding) map[string]string {
+	env := make(map[string]string, len(bindings))
+	for _, binding := range bindings {
+		env[portEnvPrefix+binding.Label] = strconv.Itoa(int(binding.Port))
+	}
+	return env
+}
@@ -0,0 +1,88 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"fmt"
+	"strings"
+
+	pb "github.com/elide-dev/elide-task-driver/proto/gen/go/elide/daemon/v1alpha1"
+)
+
+// builtinProfiles are the sandbox presets available without any plugin
+// configuration. Profiles defined in the plugin config take precedence.
+var builtinProfiles = map[string]ProfileConfig{
+	// No intrinsics: the snippet can only compute on its inputs
+	"pure-compute": {},
+	// File and environment access
+	"io-allowed": {Intrinsics: []string{"io", "env"}},
+	// File, environment and network access
+	"network-allowed": {Intrinsics: []string{"io", "env", "net"}},
+}
+
+// Profile returns the named sandbox profile, preferring profiles defined in
+// the plugin config over the built-in presets.
+func (c *Config) Profile(name string) (ProfileConfig, bool) {
+	if profile, ok := c.Profiles[name]; ok {
+		return profile, true
+	}
+	profile, ok := builtinProfiles[name]
+	return profile, ok
+}
+
+// Validate checks the profile against the session's enabled intrinsics.
+func (p *ProfileConfig) Validate(enabledIntrinsics []string) error {
+	if p.MemoryLimitMB < 0 {
+		return fmt.Errorf("memory_limit_mb cannot be negative")
+	}
+	if _, err := ParseDuration("timeout", p.Timeout); err != nil {
+		return err
+	}
+	if missing := p.missingIntrinsics(enabledIntrinsics); len(missing) > 0 {
+		return fmt.Errorf("intrinsics not enabled in session_config: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// missingIntrinsics returns the profile's intrinsics that are not enabled.
+func (p *ProfileConfig) missingIntrinsics(enabledIntrinsics []string) []string {
+	enabled := make(map[string]bool, len(enabledIntrinsics))
+	for _, intrinsic := range enabledIntrinsics {
+		enabled[intrinsic] = true
+	}
+
+	var missing []string
+	for _, intrinsic := range p.Intrinsics {
+		if !enabled[intrinsic] {
+			missing = append(missing, intrinsic)
+		}
+	}
+	return missing
+}
+
+// limits converts the profile to per-execution limits for the daemon.
+func (p *ProfileConfig) limits() *pb.ExecutionLimits {
+	return &pb.ExecutionLimits{
+		Intrinsics:    append([]string{}, p.Intrinsics...),
+		MemoryLimitMb: int32(p.MemoryLimitMB),
+	}
+}
+
+// resolveProfile looks up the task's sandbox profile. It returns a nil
+// profile if the task does not select one.
+func (d *ElideDriverPlugin) resolveProfile(taskConfig *TaskConfig) (*ProfileConfig, error) {
+	name := taskConfig.ElideOpts.Profile
+	if name == "" {
+		return nil, nil
+	}
+
+	profile, ok := d.config.Profile(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown sandbox profile %q", name)
+	}
+	if err := profile.Validate(d.sessionConfig().intrinsics()); err != nil {
+		return nil, fmt.Errorf("sandbox profile %q: %w", name, err)
+	}
+	return &profile, nil
+}
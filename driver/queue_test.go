@@ -0,0 +1,107 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/nomad/plugins/drivers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	pb "github.com/elide-dev/elide-task-driver/proto/gen/go/elide/daemon/v1alpha1"
+)
+
+// queueingDaemonClient runs one execution of a session at a time and queues
+// the others in submission order.
+type queueingDaemonClient struct {
+	DaemonClient
+
+	mu sync.Mutex
+
+	// pending holds the executions that have not completed, the running one
+	// first
+	pending []string
+}
+
+func (c *queueingDaemonClient) GetExecutionStatus(ctx context.Context, sessionID string, executionID string, output OutputRequest) (*pb.GetExecutionStatusResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch i := slices.Index(c.pending, executionID); {
+	case i < 0:
+		return &pb.GetExecutionStatusResponse{ExecutionId: executionID, Status: pb.ExecutionStatus_EXECUTION_STATUS_COMPLETED, Complete: true}, nil
+	case i == 0:
+		return &pb.GetExecutionStatusResponse{ExecutionId: executionID, Status: pb.ExecutionStatus_EXECUTION_STATUS_RUNNING}, nil
+	default:
+		return &pb.GetExecutionStatusResponse{
+			ExecutionId:     executionID,
+			Status:          pb.ExecutionStatus_EXECUTION_STATUS_QUEUED,
+			Message:         "waiting for a context",
+			QueuePosition:   uint32(i),
+			ExecutionsAhead: uint32(i),
+		}, nil
+	}
+}
+
+// complete completes the running execution, running the next one.
+func (c *queueingDaemonClient) complete() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pending = c.pending[1:]
+}
+
+func TestInspectTask_QueuePosition(t *testing.T) {
+	d := NewPlugin(hclog.NewNullLogger()).(*ElideDriverPlugin)
+	client := &queueingDaemonClient{}
+	d.daemonClient = client
+
+	var handles []*taskHandle
+	for i := 1; i <= 4; i++ {
+		id := fmt.Sprintf("task-%d", i)
+		client.pending = append(client.pending, "exec-"+id)
+		h := d.recoveredHandle(&TaskState{
+			TaskConfig:  &drivers.TaskConfig{ID: id},
+			ExecutionId: "exec-" + id,
+			SessionId:   "shared",
+			StartedAt:   time.Now(),
+		})
+		d.tasks.Set(id, h)
+		handles = append(handles, h)
+	}
+
+	// queueAttributes polls every task and returns the queue_position and
+	// executions_ahead each reports, empty for tasks not queued
+	queueAttributes := func() [][2]string {
+		var attrs [][2]string
+		for _, h := range handles {
+			var lastScratchCheck time.Time
+			d.pollExecution(context.Background(), h, &lastScratchCheck)
+			status, err := d.InspectTask(h.taskConfig.ID)
+			require.NoError(t, err)
+			attrs = append(attrs, [2]string{status.DriverAttributes["queue_position"], status.DriverAttributes["executions_ahead"]})
+		}
+		return attrs
+	}
+
+	assert.Equal(t, [][2]string{{"", ""}, {"1", "1"}, {"2", "2"}, {"3", "3"}}, queueAttributes())
+	status, err := d.InspectTask("task-2")
+	require.NoError(t, err)
+	assert.Equal(t, pb.ExecutionStatus_EXECUTION_STATUS_QUEUED.String(), status.DriverAttributes["daemon_status"])
+	assert.Equal(t, "waiting for a context", status.DriverAttributes["daemon_message"])
+
+	// Completing the head runs the next execution and moves the others up
+	client.complete()
+	assert.Equal(t, [][2]string{{"", ""}, {"", ""}, {"1", "1"}, {"2", "2"}}, queueAttributes())
+	assert.False(t, handles[0].IsRunning())
+
+	client.complete()
+	client.complete()
+	assert.Equal(t, [][2]string{{"", ""}, {"", ""}, {"", ""}, {"", ""}}, queueAttributes())
+}
@@ -0,0 +1,241 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/nomad/plugins/drivers"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// reconnectBaseDelay is the initial delay between reconnection probes
+	reconnectBaseDelay = 500 * time.Millisecond
+
+	// reconnectMaxDelay caps the delay between reconnection probes
+	reconnectMaxDelay = 30 * time.Second
+
+	// reconnectProbeTimeout bounds a single reconnection probe
+	reconnectProbeTimeout = 5 * time.Second
+)
+
+// recoverTimeout bounds how long RecoverTask waits for the daemon; tests
+// shorten it
+var recoverTimeout = 30 * time.Second
+
+// Daemon connectivity metric names
+const (
+	metricDaemonUp      = "daemon_up"
+	metricDaemonOutages = "daemon_outages_total"
+)
+
+// reconnectManager centralizes reconnection to the daemon. When any RPC or
+// poller finds the daemon unreachable it trips a shared "daemon down" latch; a
+// single goroutine then probes the daemon with jittered exponential backoff
+// while all pollers wait on the latch, instead of hundreds of tasks retrying
+// at once.
+type reconnectManager struct {
+	// mu syncs access to down
+	mu sync.Mutex
+
+	// down is closed when the daemon is reachable again (nil while it is up)
+	down chan struct{}
+
+	// probe checks whether the daemon is reachable
+	probe func(ctx context.Context) error
+
+	// hooks are told when the daemon goes down and comes back
+	hooks reconnectHooks
+
+	// ctx stops reconnection when the driver shuts down
+	ctx context.Context
+
+	metrics *metricsRegistry
+	logger  hclog.Logger
+}
+
+// reconnectHooks are called from the reconnection goroutine as the daemon's
+// reachability changes; either may be nil.
+type reconnectHooks struct {
+	// down is called when the daemon became unreachable
+	down func(err error)
+
+	// up is called once the daemon answered a probe again, after attempts
+	// probes and an outage of the given length
+	up func(attempts int, outage time.Duration)
+}
+
+func newReconnectManager(ctx context.Context, probe func(ctx context.Context) error, hooks reconnectHooks, metrics *metricsRegistry, logger hclog.Logger) *reconnectManager {
+	return &reconnectManager{
+		probe:   probe,
+		hooks:   hooks,
+		ctx:     ctx,
+		metrics: metrics,
+		logger:  logger,
+	}
+}
+
+// markDown trips the latch after err showed the daemon is unreachable and
+// starts reconnecting, unless reconnection is already in progress.
+func (m *reconnectManager) markDown(err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.down != nil {
+		return
+	}
+	m.down = make(chan struct{})
+
+	m.logger.Warn("daemon unreachable, pausing status polling until it recovers", "error", err)
+	m.metrics.IncrCounter(metricDaemonOutages, "Times the daemon became unreachable.")
+	m.metrics.SetGauge(metricDaemonUp, "Whether the daemon is reachable.", 0)
+	go m.reconnect(m.down, err)
+}
+
+// reconnect probes the daemon until it answers, then releases the latch.
+func (m *reconnectManager) reconnect(down chan struct{}, cause error) {
+	if m.hooks.down != nil {
+		m.hooks.down(cause)
+	}
+
+	start := time.Now()
+	delay := reconnectBaseDelay
+	for attempt := 1; ; attempt++ {
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-time.After(jitter(delay)):
+		}
+
+		ctx, cancel := context.WithTimeout(m.ctx, reconnectProbeTimeout)
+		err := m.probe(ctx)
+		cancel()
+		if err == nil {
+			m.mu.Lock()
+			m.down = nil
+			close(down)
+			m.mu.Unlock()
+
+			m.logger.Info("daemon reachable again", "attempts", attempt)
+			m.metrics.SetGauge(metricDaemonUp, "Whether the daemon is reachable.", 1)
+			if m.hooks.up != nil {
+				m.hooks.up(attempt, time.Since(start))
+			}
+			return
+		}
+
+		m.logger.Debug("daemon still unreachable", "attempt", attempt, "error", err)
+		delay = min(delay*2, reconnectMaxDelay)
+	}
+}
+
+// wait blocks while the daemon is down. It returns nil once the daemon is
+// reachable, or an error if ctx ends or the driver shuts down first.
+func (m *reconnectManager) wait(ctx context.Context) error {
+	m.mu.Lock()
+	down := m.down
+	m.mu.Unlock()
+
+	if down == nil {
+		return nil
+	}
+
+	select {
+	case <-down:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-m.ctx.Done():
+		return m.ctx.Err()
+	}
+}
+
+// isDown reports whether the daemon is currently considered unreachable.
+func (m *reconnectManager) isDown() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.down != nil
+}
+
+// dialOption returns the dial option tripping the latch whenever an RPC finds
+// the daemon unreachable, so outages are noticed by whichever call hits them
+// first rather than only by status pollers.
+func (m *reconnectManager) dialOption() grpc.DialOption {
+	return grpc.WithChainUnaryInterceptor(m.intercept)
+}
+
+func (m *reconnectManager) intercept(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	err := invoker(ctx, method, req, reply, cc, opts...)
+	if isUnavailable(err) {
+		m.markDown(err)
+	}
+	return err
+}
+
+// daemonDown captures a forensic bundle and tells every running task that
+// the daemon became unreachable and the driver is reconnecting.
+func (d *ElideDriverPlugin) daemonDown(err error) {
+	d.forensics.recordHealth("daemon_unreachable", err.Error())
+	d.captureForensics(forensicsTriggerDaemonDown, "", err)
+	d.emitReconnectEvent(fmt.Sprintf("Daemon unreachable, reconnecting: %v", err), map[string]string{
+		"daemon_connection": "lost",
+	})
+}
+
+// daemonReconnected re-establishes the sessions a restarted daemon no longer
+// knows, then tells every running task the daemon is back. Executions the
+// daemon lost are not resubmitted; their tasks fail when their status is
+// next polled and Nomad restarts them per their restart policy.
+func (d *ElideDriverPlugin) daemonReconnected(attempts int, outage time.Duration) {
+	d.forensics.recordHealth("daemon_reachable", fmt.Sprintf("after %d attempts (%s outage)", attempts, outage.Round(time.Millisecond)))
+	d.checkSession()
+	if err := d.ensureSession(d.ctx); err != nil {
+		d.logger.Warn("failed to re-establish session after reconnecting to the daemon", "error", err)
+	}
+
+	d.emitReconnectEvent(fmt.Sprintf("Reconnected to daemon after %d attempts (%s outage)", attempts, outage.Round(time.Millisecond)), map[string]string{
+		"daemon_connection":  "restored",
+		"reconnect_attempts": strconv.Itoa(attempts),
+		"outage":             outage.Round(time.Millisecond).String(),
+	})
+}
+
+// emitReconnectEvent emits a task event to every task that is still running.
+func (d *ElideDriverPlugin) emitReconnectEvent(message string, annotations map[string]string) {
+	for _, h := range d.tasks.List() {
+		if !h.IsRunning() {
+			continue
+		}
+		d.eventer.EmitEvent(&drivers.TaskEvent{
+			TaskID:      h.taskConfig.ID,
+			AllocID:     h.taskConfig.AllocID,
+			TaskName:    h.taskConfig.Name,
+			Timestamp:   time.Now(),
+			Message:     message,
+			Annotations: annotations,
+		})
+	}
+}
+
+// jitter returns a random duration in [d/2, d], so pollers released by the
+// same outage do not hit the daemon in lockstep.
+func jitter(d time.Duration) time.Duration {
+	half := int64(d / 2)
+	return time.Duration(half + rand.Int63n(half+1))
+}
+
+// isUnavailable reports whether err means the daemon could not be reached, as
+// opposed to the daemon rejecting the request.
+func isUnavailable(err error) bool {
+	return status.Code(err) == codes.Unavailable
+}
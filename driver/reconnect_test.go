@@ -0,0 +1,137 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/nomad/plugins/drivers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/elide-dev/elide-task-driver/proto/gen/go/elide/daemon/v1alpha1"
+)
+
+// flakyDaemonClient is unreachable while down is set, and reports a
+// completed execution otherwise.
+type flakyDaemonClient struct {
+	sessionDeletingClient
+
+	down         atomic.Bool
+	statusCalls  atomic.Int32
+	healthProbes atomic.Int32
+}
+
+func (c *flakyDaemonClient) Health(ctx context.Context) (*pb.HealthResponse, error) {
+	c.healthProbes.Add(1)
+	if c.down.Load() {
+		return nil, status.Error(codes.Unavailable, "connection refused")
+	}
+	return &pb.HealthResponse{}, nil
+}
+
+func (c *flakyDaemonClient) CreateSession(ctx context.Context, sessionID string, config *pb.SessionConfiguration) (*pb.CreateSessionResponse, error) {
+	return &pb.CreateSessionResponse{SessionId: sessionID, CreatedAt: time.Now().Unix()}, nil
+}
+
+func (c *flakyDaemonClient) Reconnect() {}
+
+func (c *flakyDaemonClient) GetExecutionStatus(ctx context.Context, sessionID string, executionID string, output OutputRequest) (*pb.GetExecutionStatusResponse, error) {
+	c.statusCalls.Add(1)
+	if c.down.Load() {
+		return nil, status.Error(codes.Unavailable, "connection refused")
+	}
+	return &pb.GetExecutionStatusResponse{
+		ExecutionId: executionID,
+		Status:      pb.ExecutionStatus_EXECUTION_STATUS_COMPLETED,
+		Complete:    true,
+	}, nil
+}
+
+func (c *flakyDaemonClient) GetSession(ctx context.Context, sessionID string) (*pb.GetSessionResponse, error) {
+	if c.down.Load() {
+		return nil, status.Error(codes.Unavailable, "connection refused")
+	}
+	return &pb.GetSessionResponse{SessionId: sessionID}, nil
+}
+
+func newFlakyDaemonPlugin(t *testing.T) (*ElideDriverPlugin, *flakyDaemonClient, *taskHandle) {
+	d := NewPlugin(hclog.NewNullLogger()).(*ElideDriverPlugin)
+	t.Cleanup(d.Shutdown)
+	client := &flakyDaemonClient{}
+	client.down.Store(true)
+	d.daemonClient = client
+	d.sessionID = "session-1"
+
+	h := d.recoveredHandle(&TaskState{
+		TaskConfig:  &drivers.TaskConfig{ID: "task-1"},
+		ExecutionId: "exec-1",
+		SessionId:   "session-1",
+		StartedAt:   time.Now(),
+	})
+	d.tasks.Set("task-1", h)
+	return d, client, h
+}
+
+func TestReconnect_TasksWaitOutOutage(t *testing.T) {
+	d, client, h := newFlakyDaemonPlugin(t)
+	var lastScratchCheck time.Time
+
+	// The first poll finding the daemon unreachable trips the latch
+	result, done := d.pollExecution(context.Background(), h, &lastScratchCheck)
+	assert.False(t, done, "a task is not failed because the daemon is unreachable")
+	assert.Nil(t, result)
+	require.True(t, d.reconnect.isDown())
+
+	// Polls wait on the latch instead of calling the daemon
+	calls := client.statusCalls.Load()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	result, done = d.pollExecution(ctx, h, &lastScratchCheck)
+	cancel()
+	assert.False(t, done)
+	assert.Nil(t, result)
+	assert.Equal(t, calls, client.statusCalls.Load(), "pollers wait out the outage")
+	assert.True(t, h.IsRunning())
+
+	// Once a probe finds the daemon back, polling resumes
+	client.down.Store(false)
+	require.Eventually(t, func() bool { return !d.reconnect.isDown() }, 5*time.Second, 10*time.Millisecond)
+	assert.Positive(t, client.healthProbes.Load())
+
+	result, done = d.pollExecution(context.Background(), h, &lastScratchCheck)
+	require.True(t, done)
+	require.NotNil(t, result)
+	assert.NoError(t, result.Err)
+	assert.Zero(t, result.ExitCode)
+}
+
+func TestReconnect_RecoveryFailsAfterGraceWindow(t *testing.T) {
+	defer func(timeout time.Duration) { recoverTimeout = timeout }(recoverTimeout)
+	recoverTimeout = 2 * time.Second
+
+	d, client, h := newFlakyDaemonPlugin(t)
+	state := h.taskState()
+	d.tasks.Delete("task-1")
+
+	// Within the window, recovery waits for the daemon to return
+	d.reconnect.markDown(status.Error(codes.Unavailable, "connection refused"))
+	time.AfterFunc(100*time.Millisecond, func() { client.down.Store(false) })
+	statusResp, err := d.recoverExecutionStatus(state)
+	require.NoError(t, err)
+	assert.True(t, statusResp.Complete)
+
+	// A daemon that stays down past the window fails the recovery
+	client.down.Store(true)
+	d.reconnect.markDown(status.Error(codes.Unavailable, "connection refused"))
+	start := time.Now()
+	_, err = d.recoverExecutionStatus(state)
+	assert.ErrorContains(t, err, "daemon unavailable")
+	assert.GreaterOrEqual(t, time.Since(start), recoverTimeout)
+}
@@ -0,0 +1,171 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/nomad/plugins/drivers"
+
+	pb "github.com/elide-dev/elide-task-driver/proto/gen/go/elide/daemon/v1alpha1"
+)
+
+// Values of recover_policy
+const (
+	// recoverPolicyLost marks recovered tasks whose session was lost as
+	// exited with an error
+	recoverPolicyLost = "lost"
+
+	// recoverPolicyResubmit submits recovered idempotent tasks whose session
+	// was lost again, in a new session; other tasks are marked lost
+	recoverPolicyResubmit = "resubmit"
+)
+
+// metricRecoveredSessionLosses counts recovered tasks whose session was lost,
+// by result
+const metricRecoveredSessionLosses = "recovered_session_losses_total"
+
+// validateRecoverPolicy checks recover_policy.
+func (c *Config) validateRecoverPolicy() error {
+	switch c.RecoverPolicy {
+	case "", recoverPolicyLost, recoverPolicyResubmit:
+	default:
+		return fmt.Errorf("invalid recover_policy %q (must be %q or %q)", c.RecoverPolicy, recoverPolicyLost, recoverPolicyResubmit)
+	}
+	if c.RecoverPolicy == recoverPolicyResubmit && c.StateDir == "" {
+		return fmt.Errorf("recover_policy %q requires 'state_dir'", recoverPolicyResubmit)
+	}
+	return nil
+}
+
+// ResubmitsOnRecovery reports whether a recovered task whose session was lost
+// is submitted again under the given recover_policy: only tasks marked
+// idempotent are, as their execution starts over from the beginning.
+func ResubmitsOnRecovery(policy string, tc *TaskConfig) bool {
+	return policy == recoverPolicyResubmit && tc.Idempotent
+}
+
+// LostSessionResult is the exit result of a recovered task whose session the
+// daemon lost, taking its execution with it.
+func LostSessionResult(sessionID string, executionID string, reason error) *drivers.ExitResult {
+	return &drivers.ExitResult{
+		Err: fmt.Errorf("session %s was lost (%v), likely to a daemon restart; execution %s cannot be recovered", sessionID, reason, executionID),
+	}
+}
+
+// SessionRecreated reports whether a session was created after a task
+// started, as when a restarted daemon lost the task's session and the driver
+// opened it again under the same ID: the task's execution is then gone.
+func SessionRecreated(resp *pb.GetSessionResponse, startedAt time.Time) bool {
+	return resp.GetCreatedAt() > 0 && time.Unix(resp.GetCreatedAt(), 0).After(startedAt)
+}
+
+// recoveredSessionLost checks, after the status of a recovered execution
+// could not be fetched, whether the execution's session is gone or was
+// re-created since the task started.
+func (d *ElideDriverPlugin) recoveredSessionLost(taskState *TaskState) (lost bool, recreated bool, reason error) {
+	ctx, cancel := d.withTimeout(d.ctx, d.statusTimeout())
+	defer cancel()
+	resp, err := d.daemonClient.GetSession(ctx, taskState.SessionId)
+	if lost, reason := sessionLost(taskState.SessionId, resp, err); lost || err != nil {
+		return lost, false, reason
+	}
+	if SessionRecreated(resp, taskState.StartedAt) {
+		return true, true, fmt.Errorf("session %s was re-created at %s", taskState.SessionId, time.Unix(resp.CreatedAt, 0).UTC().Format(time.RFC3339))
+	}
+	return false, false, nil
+}
+
+// forgetLostSession forgets the lost session of a recovered task, wherever it
+// was adopted, so the next task using it re-creates it. The default session
+// is re-created right away.
+func (d *ElideDriverPlugin) forgetLostSession(taskState *TaskState) {
+	d.sessionLock.Lock()
+	defer d.sessionLock.Unlock()
+
+	sessionID := taskState.SessionId
+	switch {
+	case taskState.SessionScope != "":
+		if d.scoped.sessions[taskState.SessionScope] == sessionID {
+			delete(d.scoped.sessions, taskState.SessionScope)
+		}
+	case taskState.SessionProfile != "":
+		if d.profileSessions[taskState.SessionProfile] == sessionID {
+			delete(d.profileSessions, taskState.SessionProfile)
+		}
+	case d.sessionID == sessionID:
+		d.sessionID = ""
+		ctx, cancel := d.withTimeout(d.ctx, d.statusTimeout())
+		defer cancel()
+		if err := d.createSession(ctx); err != nil {
+			d.logger.Warn("failed to re-create lost session, the next task will retry", "session_id", sessionID, "error", err)
+		}
+	}
+	d.restarts.forgetSession(sessionID)
+}
+
+// recoverLostSession recovers a task whose session the daemon lost, as
+// recover_policy says: an idempotent task is queued for submission in a new
+// session, recorded in state_dir since Nomad keeps the task's old state, and
+// any other task exits with LostSessionResult. A session that is gone is
+// forgotten; one already re-created is kept.
+func (d *ElideDriverPlugin) recoverLostSession(taskState *TaskState, recreated bool, reason error) error {
+	cfg := taskState.TaskConfig
+	d.logger.Warn("session of recovered task lost", "task_id", cfg.ID, "session_id", taskState.SessionId, "execution_id", taskState.ExecutionId, "reason", reason)
+	if !recreated {
+		d.forgetLostSession(taskState)
+	}
+
+	var tc TaskConfig
+	if err := cfg.DecodeDriverConfig(&tc); err != nil {
+		d.logger.Warn("failed to decode config of recovered task, marking it lost", "task_id", cfg.ID, "error", err)
+	} else if ResubmitsOnRecovery(d.config.RecoverPolicy, &tc) {
+		entry := &queueEntry{
+			TaskID:   cfg.ID,
+			AllocID:  cfg.AllocID,
+			TaskName: cfg.Name,
+			CodeHash: taskState.CodeHash,
+			QueuedAt: time.Now(),
+		}
+		if err := d.writeQueueEntry(entry); err != nil {
+			d.logger.Warn("failed to queue resubmission of recovered task, marking it lost", "task_id", cfg.ID, "error", err)
+		} else {
+			h := d.queuedHandle(cfg, entry)
+			d.tasks.Set(cfg.ID, h)
+			d.metrics.IncrCounter(metricRecoveredSessionLosses, "Recovered tasks whose session was lost, by result.", "result", "resubmitted")
+			d.emitQueueEvent(h, "Session lost while the driver was down, resubmitting idempotent task", map[string]string{
+				"lost_session_id": taskState.SessionId,
+				"recovery":        "resubmitted",
+			})
+			go d.submitQueued(h)
+			return nil
+		}
+	}
+
+	h := d.recoveredHandle(taskState)
+	h.sessionLost = true
+	h.SetCompleted(LostSessionResult(taskState.SessionId, taskState.ExecutionId, reason))
+	d.tasks.Set(cfg.ID, h)
+	d.metrics.IncrCounter(metricRecoveredSessionLosses, "Recovered tasks whose session was lost, by result.", "result", "lost")
+	d.emitQueueEvent(h, "Session lost while the driver was down, task marked lost", map[string]string{
+		"lost_session_id": taskState.SessionId,
+		"recovery":        "lost",
+	})
+	return nil
+}
+
+// resubmitted reports whether a recovered task was already resubmitted after
+// its session was lost, in which case the resubmission's queue entry, not the
+// state Nomad kept, tells how to recover it.
+func (d *ElideDriverPlugin) resubmitted(taskState *TaskState) bool {
+	if d.config.StateDir == "" {
+		return false
+	}
+	entry, err := d.readQueueEntry(taskState.TaskConfig.ID)
+	if err != nil {
+		return false
+	}
+	return entry.Submitted == nil || entry.Submitted.SessionId != taskState.SessionId
+}
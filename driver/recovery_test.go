@@ -0,0 +1,144 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/nomad/plugins/drivers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/elide-dev/elide-task-driver/proto/gen/go/elide/daemon/v1alpha1"
+)
+
+// sessionLosingClient is a restarted daemon that lost session-1 and its
+// executions, and records the executions submitted to it since.
+type sessionLosingClient struct {
+	flakyDaemonClient
+
+	submitMu  sync.Mutex
+	submitted []ExecuteSnippetRequest
+}
+
+func (c *sessionLosingClient) GetExecutionStatus(ctx context.Context, sessionID string, executionID string, output OutputRequest) (*pb.GetExecutionStatusResponse, error) {
+	if sessionID == "session-1" {
+		return nil, status.Error(codes.NotFound, "session not found")
+	}
+	return c.flakyDaemonClient.GetExecutionStatus(ctx, sessionID, executionID, output)
+}
+
+func (c *sessionLosingClient) WatchExecution(ctx context.Context, sessionID string, executionID string, order bool) (StatusStream, error) {
+	return nil, status.Error(codes.Unimplemented, "unknown method WatchExecution")
+}
+
+func (c *sessionLosingClient) GetSession(ctx context.Context, sessionID string) (*pb.GetSessionResponse, error) {
+	if sessionID == "session-1" {
+		return nil, status.Error(codes.NotFound, "session not found")
+	}
+	return &pb.GetSessionResponse{SessionId: sessionID, Status: pb.SessionStatus_SESSION_STATUS_ACTIVE}, nil
+}
+
+func (c *sessionLosingClient) ExecuteSnippet(ctx context.Context, req ExecuteSnippetRequest) (*pb.ExecuteSnippetResponse, error) {
+	c.submitMu.Lock()
+	defer c.submitMu.Unlock()
+	c.submitted = append(c.submitted, req)
+	return &pb.ExecuteSnippetResponse{ExecutionId: req.ExecutionID, Status: pb.ExecutionStatus_EXECUTION_STATUS_RUNNING}, nil
+}
+
+func (c *sessionLosingClient) submissions() []ExecuteSnippetRequest {
+	c.submitMu.Lock()
+	defer c.submitMu.Unlock()
+	return append([]ExecuteSnippetRequest(nil), c.submitted...)
+}
+
+// lostSessionHandle returns the handle Nomad kept of a task started in
+// session-1 before the daemon restarted.
+func lostSessionHandle(t *testing.T, idempotent bool) *drivers.TaskHandle {
+	cfg := &drivers.TaskConfig{ID: "task-1", JobID: "job", Namespace: "default", AllocDir: t.TempDir()}
+	require.NoError(t, cfg.EncodeConcreteDriverConfig(&TaskConfig{Language: "python", Code: "print(1)", Idempotent: idempotent}))
+
+	handle := drivers.NewTaskHandle(taskHandleVersion)
+	handle.Config = cfg
+	var err error
+	handle.DriverState, err = EncodeTaskState(&TaskState{
+		TaskConfig:   cfg,
+		ExecutionId:  "exec-1",
+		SessionId:    "session-1",
+		StartedAt:    time.Now().Add(-time.Minute),
+		PollInterval: 10 * time.Millisecond,
+	})
+	require.NoError(t, err)
+	return handle
+}
+
+func TestRecoverTask_SessionLost(t *testing.T) {
+	tests := []struct {
+		name        string
+		policy      string
+		idempotent  bool
+		resubmitted bool
+	}{
+		{name: "lost", policy: recoverPolicyLost, idempotent: true},
+		{name: "resubmit idempotent", policy: recoverPolicyResubmit, idempotent: true, resubmitted: true},
+		{name: "resubmit non-idempotent", policy: recoverPolicyResubmit},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewPlugin(hclog.NewNullLogger()).(*ElideDriverPlugin)
+			t.Cleanup(d.Shutdown)
+			client := &sessionLosingClient{}
+			d.daemonClient = client
+			d.sessionID = "session-1"
+			d.config.RecoverPolicy = tt.policy
+			d.config.StateDir = t.TempDir()
+
+			require.NoError(t, d.RecoverTask(lostSessionHandle(t, tt.idempotent)))
+
+			// The default session was lost with the task's execution, and is
+			// re-created for the tasks that follow
+			assert.NotEmpty(t, d.sessionID)
+			assert.NotEqual(t, "session-1", d.sessionID)
+
+			ch, err := d.WaitTask(context.Background(), "task-1")
+			require.NoError(t, err)
+			if !tt.resubmitted {
+				select {
+				case result := <-ch:
+					require.NotNil(t, result)
+					assert.ErrorContains(t, result.Err, "session session-1 was lost")
+				case <-time.After(5 * time.Second):
+					t.Fatal("lost task did not exit")
+				}
+				assert.Empty(t, client.submissions(), "a lost task is not submitted again")
+				assert.Contains(t, scrape(t, d), `elide_driver_recovered_session_losses_total{result="lost"} 1`)
+				return
+			}
+
+			// The task is submitted again in the new session, then followed
+			// there to completion
+			select {
+			case result := <-ch:
+				require.NotNil(t, result)
+				assert.NoError(t, result.Err)
+			case <-time.After(5 * time.Second):
+				t.Fatal("resubmitted task did not exit")
+			}
+			submitted := client.submissions()
+			require.Len(t, submitted, 1)
+			assert.Equal(t, d.sessionID, submitted[0].SessionID)
+
+			h, ok := d.tasks.Get("task-1")
+			require.True(t, ok)
+			assert.Equal(t, d.sessionID, h.sessionId)
+			assert.Contains(t, scrape(t, d), `elide_driver_recovered_session_losses_total{result="resubmitted"} 1`)
+		})
+	}
+}
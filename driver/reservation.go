@@ -0,0 +1,86 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"github.com/hashicorp/nomad/plugins/drivers"
+	"github.com/hashicorp/nomad/plugins/shared/structs"
+)
+
+const (
+	// sessionMemoryFreeAttribute advertises the memory of the default session
+	// not reserved by running executions, in MB
+	sessionMemoryFreeAttribute = "driver.elide.session_memory_free_mb"
+
+	// sessionProfileMemoryFreeSuffix follows a session profile's attribute
+	// to advertise the memory left in its session, in MB
+	sessionProfileMemoryFreeSuffix = ".memory_free_mb"
+)
+
+// MemoryReservationMB returns the memory an execution of a task reserves in
+// its session: its elide_opts.memory_limit, else its sandbox profile's
+// memory limit, else the memory Nomad allocated to the task.
+func MemoryReservationMB(cfg *drivers.TaskConfig, opts ElideOptions, profile *ProfileConfig) int {
+	if opts.MemoryLimit > 0 {
+		return opts.MemoryLimit
+	}
+	if profile != nil && profile.MemoryLimitMB > 0 {
+		return profile.MemoryLimitMB
+	}
+	if cfg.Resources != nil && cfg.Resources.NomadResources != nil {
+		return int(cfg.Resources.NomadResources.Memory.MemoryMB)
+	}
+	return 0
+}
+
+// SessionMemoryFree returns the memory of a session with the given limit
+// that is not reserved, never below zero.
+func SessionMemoryFree(limitMB int, reservedMB int) int {
+	return max(limitMB-reservedMB, 0)
+}
+
+// reservedMemory returns the memory reserved by the running executions of
+// the task, i.e. its reservation for each execution still running. Queued
+// and exited tasks reserve nothing.
+func (h *taskHandle) reservedMemory() int {
+	h.stateLock.RLock()
+	defer h.stateLock.RUnlock()
+
+	if h.exitResult != nil || h.queue != nil {
+		return 0
+	}
+	if h.matrix == nil {
+		return h.memoryReservation
+	}
+	reserved := 0
+	for _, entry := range h.matrix {
+		if !entry.Complete {
+			reserved += h.memoryReservation
+		}
+	}
+	return reserved
+}
+
+// sessionMemoryAttributes advertises the memory left in the default session
+// and in each session profile's session, so jobs can avoid nodes whose
+// sessions are already committed. Executions in per-alloc or per-task
+// sessions are not counted, as they have sessions of their own.
+func (d *ElideDriverPlugin) sessionMemoryAttributes(fp *drivers.Fingerprint) {
+	reserved := map[string]int{}
+	for _, h := range d.tasks.List() {
+		h.stateLock.RLock()
+		profile, scope := h.sessionProfile, h.sessionScope
+		h.stateLock.RUnlock()
+		if scope == "" {
+			reserved[profile] += h.reservedMemory()
+		}
+	}
+
+	free := SessionMemoryFree(int(d.buildSessionConfig().MemoryLimitMb), reserved[""])
+	fp.Attributes[sessionMemoryFreeAttribute] = structs.NewIntAttribute(int64(free), "")
+	for name, profile := range d.config.SessionProfiles {
+		free := SessionMemoryFree(profile.MemoryLimitMB, reserved[name])
+		fp.Attributes[sessionProfileAttributePrefix+name+sessionProfileMemoryFreeSuffix] = structs.NewIntAttribute(int64(free), "")
+	}
+}
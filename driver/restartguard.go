@@ -0,0 +1,207 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/nomad/plugins/drivers"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// What the restart guard does once a task fails too often in a session
+const (
+	// restartGuardRecycle replaces the session's interpreter contexts
+	restartGuardRecycle = "recycle"
+
+	// restartGuardRotate moves new tasks to new sessions; running tasks
+	// finish in the old ones
+	restartGuardRotate = "rotate"
+)
+
+const (
+	// defaultRestartGuardWindow is the window failures are counted in if the
+	// restart_guard block sets none
+	defaultRestartGuardWindow = 5 * time.Minute
+
+	// metricRestartGuardActions counts context recycles and session
+	// rotations forced by the restart guard
+	metricRestartGuardActions = "restart_guard_actions_total"
+)
+
+// RestartGuardConfig is the restart_guard block of the plugin config: how
+// often a task may fail in one session before the driver suspects a corrupted
+// interpreter context and replaces it.
+type RestartGuardConfig struct {
+	// MaxRestarts is the number of failed runs of the same task in one
+	// session, within Window, that triggers the guard (0 = disabled)
+	MaxRestarts int `codec:"max_restarts"`
+	// Window is the period failures are counted in (duration string)
+	Window string `codec:"window"`
+	// Action is "recycle" (replace the session's contexts) or "rotate"
+	// (move new tasks to new sessions)
+	Action string `codec:"action"`
+}
+
+// Validate checks the restart_guard block.
+func (c *RestartGuardConfig) Validate() error {
+	if c.MaxRestarts < 0 {
+		return fmt.Errorf("max_restarts cannot be negative")
+	}
+	if _, err := ParseDuration("window", c.Window); err != nil {
+		return err
+	}
+	switch c.Action {
+	case "", restartGuardRecycle, restartGuardRotate:
+	default:
+		return fmt.Errorf("invalid action %q (must be %q or %q)", c.Action, restartGuardRecycle, restartGuardRotate)
+	}
+	return nil
+}
+
+// restartGuard counts recent failures of each task per session.
+type restartGuard struct {
+	mu       sync.Mutex
+	failures map[restartGuardKey][]time.Time
+}
+
+// restartGuardKey identifies a task of an alloc in a session.
+type restartGuardKey struct {
+	allocID   string
+	taskName  string
+	sessionID string
+}
+
+func newRestartGuard() *restartGuard {
+	return &restartGuard{failures: map[restartGuardKey][]time.Time{}}
+}
+
+// failed records a failure and returns how many failures of the task in the
+// session fall within the window. The count is reset when it reaches max, so
+// the guard acts once per max failures.
+func (g *restartGuard) failed(key restartGuardKey, window time.Duration, max int) int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := time.Now()
+	recent := g.failures[key][:0]
+	for _, at := range g.failures[key] {
+		if now.Sub(at) < window {
+			recent = append(recent, at)
+		}
+	}
+	recent = append(recent, now)
+	if len(recent) >= max {
+		delete(g.failures, key)
+		return len(recent)
+	}
+	g.failures[key] = recent
+	return len(recent)
+}
+
+// forgetSession drops the failures recorded in a session.
+func (g *restartGuard) forgetSession(sessionID string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for key := range g.failures {
+		if key.sessionID == sessionID {
+			delete(g.failures, key)
+		}
+	}
+}
+
+// guardRestarts counts a failed run of a task and, once the task failed
+// max_restarts times within the window in its session, recycles the
+// session's contexts or rotates the sessions. Runs stopped by Nomad do not
+// count.
+func (d *ElideDriverPlugin) guardRestarts(handle *taskHandle, result *drivers.ExitResult) {
+	guard := d.config.RestartGuard
+	if guard.MaxRestarts <= 0 || result.Successful() {
+		return
+	}
+	handle.stateLock.RLock()
+	stopping, sessionID := handle.stopping, handle.sessionId
+	handle.stateLock.RUnlock()
+	if stopping {
+		return
+	}
+
+	key := restartGuardKey{allocID: handle.taskConfig.AllocID, taskName: handle.taskConfig.Name, sessionID: sessionID}
+	window := durationOr(defaultRestartGuardWindow, guard.Window)
+	failures := d.restarts.failed(key, window, guard.MaxRestarts)
+	if failures < guard.MaxRestarts {
+		return
+	}
+
+	action := guard.Action
+	if action == "" {
+		action = restartGuardRecycle
+	}
+	handle.logger.Warn("task keeps failing in its session, replacing the session's contexts",
+		"session_id", sessionID, "failures", failures, "window", window, "action", action)
+	if action == restartGuardRecycle && d.recycleContexts(sessionID) {
+		d.metrics.IncrCounter(metricRestartGuardActions, "Context recycles and session rotations forced by the restart guard.", "action", restartGuardRecycle)
+	} else {
+		d.rotateSessions(sessionID, fmt.Sprintf("task %s failed %d times", handle.taskConfig.Name, failures))
+		d.metrics.IncrCounter(metricRestartGuardActions, "Context recycles and session rotations forced by the restart guard.", "action", restartGuardRotate)
+	}
+
+	d.eventer.EmitEvent(&drivers.TaskEvent{
+		TaskID:    handle.taskConfig.ID,
+		AllocID:   handle.taskConfig.AllocID,
+		TaskName:  handle.taskConfig.Name,
+		Timestamp: time.Now(),
+		Message:   fmt.Sprintf("Task failed %d times within %s in session %s; its contexts were replaced", failures, window, sessionID),
+		Annotations: map[string]string{
+			"session_id": sessionID,
+			"action":     action,
+		},
+	})
+}
+
+// recycleContexts asks the daemon to replace the interpreter contexts of a
+// session. It returns false if that failed, e.g. because the daemon cannot
+// recycle contexts, so the caller rotates the sessions instead.
+func (d *ElideDriverPlugin) recycleContexts(sessionID string) bool {
+	ctx, cancel := d.withTimeout(d.ctx, d.statusTimeout())
+	defer cancel()
+
+	resp, err := d.daemonClient.RecycleContexts(ctx, sessionID)
+	if err != nil {
+		if status.Code(err) == codes.Unimplemented {
+			d.logger.Warn("daemon cannot recycle contexts, rotating sessions instead", "session_id", sessionID)
+		} else {
+			d.logger.Warn("failed to recycle contexts, rotating sessions instead", "session_id", sessionID, "error", err)
+		}
+		return false
+	}
+	d.logger.Info("recycled session contexts", "session_id", sessionID, "recycled", resp.Recycled, "pending", resp.Pending)
+	d.restarts.forgetSession(sessionID)
+	return true
+}
+
+// rotateSessions retires the default and profile sessions and opens a new
+// default session; profile sessions are re-created on next use. Nothing is
+// done if the session to replace was already retired.
+func (d *ElideDriverPlugin) rotateSessions(sessionID string, reason string) {
+	d.sessionLock.Lock()
+	defer d.sessionLock.Unlock()
+
+	if sessionID != d.sessionID && !slices.Contains(slices.Collect(maps.Values(d.profileSessions)), sessionID) {
+		return
+	}
+	d.retireSessions(reason)
+	d.sessionGeneration++
+	if d.daemonClient != nil {
+		if err := d.createSession(d.ctx); err != nil {
+			d.logger.Warn("failed to create session after rotation; the next task retries", "error", err)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const (
+	// defaultMaxResultBytes is the largest result kept inline in the task's
+	// DriverAttributes when max_result_bytes is not configured
+	defaultMaxResultBytes = 4096
+
+	// resultFileName is where oversized results are written, relative to the
+	// task directory
+	resultFileName = "local/elide-result"
+)
+
+// recordResult stores the execution result on the handle. Results larger than
+// maxBytes are written in full to a file in the task directory and only a
+// pointer to that file is kept.
+func (h *taskHandle) recordResult(result string, maxBytes int) error {
+	if result == "" {
+		return nil
+	}
+	if maxBytes <= 0 {
+		maxBytes = defaultMaxResultBytes
+	}
+
+	if len(result) <= maxBytes {
+		h.stateLock.Lock()
+		h.result = result
+		h.resultSize = len(result)
+		h.stateLock.Unlock()
+		return nil
+	}
+
+	path := filepath.Join(h.taskConfig.TaskDir().Dir, resultFileName)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create result directory: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(result), 0644); err != nil {
+		return fmt.Errorf("failed to write result file: %w", err)
+	}
+
+	h.stateLock.Lock()
+	h.resultFile = path
+	h.resultSize = len(result)
+	h.stateLock.Unlock()
+	return nil
+}
@@ -0,0 +1,56 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/nomad/plugins/drivers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newResultHandle(t *testing.T) *taskHandle {
+	return &taskHandle{taskConfig: &drivers.TaskConfig{ID: "task-1", AllocDir: t.TempDir(), Name: "web"}}
+}
+
+func TestRecordResult_Inline(t *testing.T) {
+	h := newResultHandle(t)
+	require.NoError(t, h.recordResult(`{"count":3}`, 16))
+
+	attrs := h.TaskStatus().DriverAttributes
+	assert.Equal(t, `{"count":3}`, attrs["result"])
+	assert.Equal(t, "11", attrs["result_bytes"])
+	assert.NotContains(t, attrs, "result_file")
+	assert.NoFileExists(t, filepath.Join(h.taskConfig.TaskDir().Dir, resultFileName))
+}
+
+func TestRecordResult_Overflow(t *testing.T) {
+	h := newResultHandle(t)
+	result := strings.Repeat("x", 17)
+	require.NoError(t, h.recordResult(result, 16))
+
+	path := filepath.Join(h.taskConfig.TaskDir().Dir, resultFileName)
+	attrs := h.TaskStatus().DriverAttributes
+	assert.Equal(t, path, attrs["result_file"])
+	assert.Equal(t, "17", attrs["result_bytes"])
+	assert.NotContains(t, attrs, "result")
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, result, string(data), "the file holds the full result")
+}
+
+func TestRecordResult_DefaultLimit(t *testing.T) {
+	h := newResultHandle(t)
+	require.NoError(t, h.recordResult(strings.Repeat("x", defaultMaxResultBytes), 0))
+	assert.Contains(t, h.TaskStatus().DriverAttributes, "result")
+
+	h = newResultHandle(t)
+	require.NoError(t, h.recordResult(strings.Repeat("x", defaultMaxResultBytes+1), 0))
+	assert.Contains(t, h.TaskStatus().DriverAttributes, "result_file")
+}
@@ -0,0 +1,468 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/hashicorp/nomad/plugins/drivers"
+)
+
+// Values of result_schema_policy
+const (
+	// resultSchemaFail fails tasks whose result does not match result_schema
+	resultSchemaFail = "fail"
+
+	// resultSchemaWarn only warns about results not matching result_schema
+	resultSchemaWarn = "warn"
+)
+
+// metricResultSchemaMismatches counts results not matching their schema
+const metricResultSchemaMismatches = "result_schema_mismatches_total"
+
+// schemaAnnotations are JSON Schema keywords that do not constrain values
+var schemaAnnotations = []string{"$schema", "$id", "$comment", "title", "description", "default", "examples", "deprecated", "readOnly", "writeOnly", "format"}
+
+// schemaTypes are the values of the JSON Schema type keyword
+var schemaTypes = []string{"null", "boolean", "object", "array", "number", "integer", "string"}
+
+// ResultSchema is a compiled result_schema. It supports the subset of JSON
+// Schema that describes plain data: type, enum, const, the object, array,
+// string and number constraints, and allOf, anyOf, oneOf and not. References
+// and conditionals are rejected when the schema is compiled.
+type ResultSchema struct {
+	// Source is the schema as given, kept for recovery
+	Source string
+
+	root any
+}
+
+// validateResultSchema checks the result_schema options.
+func (tc *TaskConfig) validateResultSchema() error {
+	switch tc.ResultSchemaPolicy {
+	case "", resultSchemaFail, resultSchemaWarn:
+	default:
+		return &FieldError{Field: "result_schema_policy", Err: fmt.Errorf("invalid result_schema_policy %q (must be %q or %q)", tc.ResultSchemaPolicy, resultSchemaFail, resultSchemaWarn)}
+	}
+	if tc.ResultSchema == "" {
+		return nil
+	}
+	if isInlineSchema(tc.ResultSchema) {
+		if _, err := CompileResultSchema(tc.ResultSchema); err != nil {
+			return fmt.Errorf("invalid result_schema: %w", err)
+		}
+		return nil
+	}
+	return validateWorkspacePath("result_schema", tc.ResultSchema)
+}
+
+// isInlineSchema reports whether result_schema holds the schema itself rather
+// than the path of a file holding it.
+func isInlineSchema(schema string) bool {
+	trimmed := strings.TrimSpace(schema)
+	return strings.HasPrefix(trimmed, "{") || trimmed == "true" || trimmed == "false"
+}
+
+// LoadResultSchema compiles a task's result_schema, reading it from the task
+// directory unless it is inline. It returns nil for tasks without one.
+func LoadResultSchema(tc *TaskConfig, taskDir string, followSymlinks bool) (*ResultSchema, error) {
+	if tc.ResultSchema == "" {
+		return nil, nil
+	}
+	source := tc.ResultSchema
+	if !isInlineSchema(source) {
+		path, err := resolveTaskFile(taskDir, "result_schema", source, followSymlinks)
+		if err != nil {
+			return nil, err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read result_schema: %w", err)
+		}
+		source = string(data)
+	}
+	schema, err := CompileResultSchema(source)
+	if err != nil {
+		return nil, fmt.Errorf("invalid result_schema: %w", err)
+	}
+	return schema, nil
+}
+
+// CompileResultSchema parses a JSON Schema and checks it only uses supported
+// keywords.
+func CompileResultSchema(source string) (*ResultSchema, error) {
+	var root any
+	if err := json.Unmarshal([]byte(source), &root); err != nil {
+		return nil, fmt.Errorf("schema is not valid JSON: %w", err)
+	}
+	if err := checkSchema(root, ""); err != nil {
+		return nil, err
+	}
+	return &ResultSchema{Source: source, root: root}, nil
+}
+
+// checkSchema checks a (sub)schema at the given JSON pointer.
+func checkSchema(schema any, path string) error {
+	if _, ok := schema.(bool); ok {
+		return nil
+	}
+	obj, ok := schema.(map[string]any)
+	if !ok {
+		return fmt.Errorf("%s: schema must be an object or a boolean", schemaPath(path))
+	}
+
+	for keyword, value := range obj {
+		at := path + "/" + keyword
+		var err error
+		switch keyword {
+		case "type":
+			err = checkSchemaType(value, at)
+		case "enum":
+			if _, ok := value.([]any); !ok {
+				err = fmt.Errorf("%s: must be an array", at)
+			}
+		case "const":
+		case "properties":
+			props, ok := value.(map[string]any)
+			if !ok {
+				err = fmt.Errorf("%s: must be an object", at)
+				break
+			}
+			for name, sub := range props {
+				if err = checkSchema(sub, at+"/"+escapePointer(name)); err != nil {
+					break
+				}
+			}
+		case "additionalProperties", "items", "not":
+			err = checkSchema(value, at)
+		case "required":
+			names, ok := value.([]any)
+			if !ok {
+				err = fmt.Errorf("%s: must be an array of strings", at)
+				break
+			}
+			for _, name := range names {
+				if _, ok := name.(string); !ok {
+					err = fmt.Errorf("%s: must be an array of strings", at)
+				}
+			}
+		case "allOf", "anyOf", "oneOf":
+			subs, ok := value.([]any)
+			if !ok || len(subs) == 0 {
+				err = fmt.Errorf("%s: must be a non-empty array of schemas", at)
+				break
+			}
+			for i, sub := range subs {
+				if err = checkSchema(sub, fmt.Sprintf("%s/%d", at, i)); err != nil {
+					break
+				}
+			}
+		case "minimum", "maximum", "exclusiveMinimum", "exclusiveMaximum":
+			if _, ok := value.(float64); !ok {
+				err = fmt.Errorf("%s: must be a number", at)
+			}
+		case "minLength", "maxLength", "minItems", "maxItems", "minProperties", "maxProperties":
+			if n, ok := value.(float64); !ok || n < 0 || n != math.Trunc(n) {
+				err = fmt.Errorf("%s: must be a non-negative integer", at)
+			}
+		case "pattern":
+			pattern, ok := value.(string)
+			if !ok {
+				err = fmt.Errorf("%s: must be a string", at)
+			} else if _, compileErr := regexp.Compile(pattern); compileErr != nil {
+				err = fmt.Errorf("%s: %w", at, compileErr)
+			}
+		default:
+			if !slices.Contains(schemaAnnotations, keyword) {
+				err = fmt.Errorf("%s: unsupported keyword %q", schemaPath(path), keyword)
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkSchemaType checks the value of a type keyword.
+func checkSchemaType(value any, at string) error {
+	names := []any{value}
+	if list, ok := value.([]any); ok {
+		names = list
+	}
+	for _, name := range names {
+		if s, ok := name.(string); !ok || !slices.Contains(schemaTypes, s) {
+			return fmt.Errorf("%s: unknown type %v", at, name)
+		}
+	}
+	return nil
+}
+
+// Validate checks that a result is a JSON document matching the schema. The
+// error names the first mismatch found and where it is in the result.
+func (s *ResultSchema) Validate(result string) error {
+	var value any
+	if err := json.Unmarshal([]byte(result), &value); err != nil {
+		return fmt.Errorf("result is not valid JSON: %w", err)
+	}
+	return validateValue(s.root, value, "")
+}
+
+// validateValue checks a value at the given JSON pointer of the result
+// against a schema compiled by CompileResultSchema.
+func validateValue(schema any, value any, path string) error {
+	if allowed, ok := schema.(bool); ok {
+		if !allowed {
+			return fmt.Errorf("%s: no value is allowed", schemaPath(path))
+		}
+		return nil
+	}
+	obj := schema.(map[string]any)
+
+	if types, ok := obj["type"]; ok && !matchesType(types, value) {
+		return fmt.Errorf("%s: expected %s, got %s", schemaPath(path), typeNames(types), jsonType(value))
+	}
+	if enum, ok := obj["enum"].([]any); ok && !slices.ContainsFunc(enum, func(v any) bool { return reflect.DeepEqual(v, value) }) {
+		return fmt.Errorf("%s: value is not one of the allowed values", schemaPath(path))
+	}
+	if constant, ok := obj["const"]; ok && !reflect.DeepEqual(constant, value) {
+		return fmt.Errorf("%s: value does not equal the constant %s", schemaPath(path), encodeJSON(constant))
+	}
+
+	var err error
+	switch v := value.(type) {
+	case map[string]any:
+		err = validateObject(obj, v, path)
+	case []any:
+		err = validateArray(obj, v, path)
+	case string:
+		err = validateString(obj, v, path)
+	case float64:
+		err = validateNumber(obj, v, path)
+	}
+	if err != nil {
+		return err
+	}
+
+	if allOf, ok := obj["allOf"].([]any); ok {
+		for _, sub := range allOf {
+			if err := validateValue(sub, value, path); err != nil {
+				return err
+			}
+		}
+	}
+	if anyOf, ok := obj["anyOf"].([]any); ok {
+		if !slices.ContainsFunc(anyOf, func(sub any) bool { return validateValue(sub, value, path) == nil }) {
+			return fmt.Errorf("%s: value matches none of the anyOf schemas", schemaPath(path))
+		}
+	}
+	if oneOf, ok := obj["oneOf"].([]any); ok {
+		matches := 0
+		for _, sub := range oneOf {
+			if validateValue(sub, value, path) == nil {
+				matches++
+			}
+		}
+		if matches != 1 {
+			return fmt.Errorf("%s: value matches %d of the oneOf schemas instead of exactly one", schemaPath(path), matches)
+		}
+	}
+	if not, ok := obj["not"]; ok && validateValue(not, value, path) == nil {
+		return fmt.Errorf("%s: value matches the schema it must not match", schemaPath(path))
+	}
+	return nil
+}
+
+func validateObject(schema map[string]any, value map[string]any, path string) error {
+	if required, ok := schema["required"].([]any); ok {
+		for _, name := range required {
+			if _, ok := value[name.(string)]; !ok {
+				return fmt.Errorf("%s: missing required property %q", schemaPath(path), name)
+			}
+		}
+	}
+	if n, ok := schema["minProperties"].(float64); ok && len(value) < int(n) {
+		return fmt.Errorf("%s: has %d properties, fewer than %d", schemaPath(path), len(value), int(n))
+	}
+	if n, ok := schema["maxProperties"].(float64); ok && len(value) > int(n) {
+		return fmt.Errorf("%s: has %d properties, more than %d", schemaPath(path), len(value), int(n))
+	}
+
+	props, _ := schema["properties"].(map[string]any)
+	additional, hasAdditional := schema["additionalProperties"]
+	names := make([]string, 0, len(value))
+	for name := range value {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		at := path + "/" + escapePointer(name)
+		if sub, ok := props[name]; ok {
+			if err := validateValue(sub, value[name], at); err != nil {
+				return err
+			}
+		} else if hasAdditional {
+			if allowed, ok := additional.(bool); ok && !allowed {
+				return fmt.Errorf("%s: property %q is not allowed", schemaPath(path), name)
+			}
+			if err := validateValue(additional, value[name], at); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func validateArray(schema map[string]any, value []any, path string) error {
+	if n, ok := schema["minItems"].(float64); ok && len(value) < int(n) {
+		return fmt.Errorf("%s: has %d items, fewer than %d", schemaPath(path), len(value), int(n))
+	}
+	if n, ok := schema["maxItems"].(float64); ok && len(value) > int(n) {
+		return fmt.Errorf("%s: has %d items, more than %d", schemaPath(path), len(value), int(n))
+	}
+	if items, ok := schema["items"]; ok {
+		for i, item := range value {
+			if err := validateValue(items, item, fmt.Sprintf("%s/%d", path, i)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func validateString(schema map[string]any, value string, path string) error {
+	length := utf8.RuneCountInString(value)
+	if n, ok := schema["minLength"].(float64); ok && length < int(n) {
+		return fmt.Errorf("%s: string shorter than %d characters", schemaPath(path), int(n))
+	}
+	if n, ok := schema["maxLength"].(float64); ok && length > int(n) {
+		return fmt.Errorf("%s: string longer than %d characters", schemaPath(path), int(n))
+	}
+	if pattern, ok := schema["pattern"].(string); ok && !regexp.MustCompile(pattern).MatchString(value) {
+		return fmt.Errorf("%s: string does not match pattern %q", schemaPath(path), pattern)
+	}
+	return nil
+}
+
+func validateNumber(schema map[string]any, value float64, path string) error {
+	if n, ok := schema["minimum"].(float64); ok && value < n {
+		return fmt.Errorf("%s: %v is less than the minimum %v", schemaPath(path), value, n)
+	}
+	if n, ok := schema["maximum"].(float64); ok && value > n {
+		return fmt.Errorf("%s: %v is greater than the maximum %v", schemaPath(path), value, n)
+	}
+	if n, ok := schema["exclusiveMinimum"].(float64); ok && value <= n {
+		return fmt.Errorf("%s: %v is not greater than %v", schemaPath(path), value, n)
+	}
+	if n, ok := schema["exclusiveMaximum"].(float64); ok && value >= n {
+		return fmt.Errorf("%s: %v is not less than %v", schemaPath(path), value, n)
+	}
+	return nil
+}
+
+// matchesType reports whether a value is of one of the types of a type
+// keyword. Integers are numbers without a fractional part.
+func matchesType(types any, value any) bool {
+	names := []any{types}
+	if list, ok := types.([]any); ok {
+		names = list
+	}
+	actual := jsonType(value)
+	for _, name := range names {
+		if name == actual || (name == "integer" && actual == "number" && value.(float64) == math.Trunc(value.(float64))) {
+			return true
+		}
+	}
+	return false
+}
+
+// typeNames describes the types of a type keyword.
+func typeNames(types any) string {
+	list, ok := types.([]any)
+	if !ok {
+		return fmt.Sprint(types)
+	}
+	names := make([]string, len(list))
+	for i, name := range list {
+		names[i] = fmt.Sprint(name)
+	}
+	return strings.Join(names, " or ")
+}
+
+// jsonType returns the JSON Schema type of a decoded JSON value.
+func jsonType(value any) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// schemaPath names a JSON pointer in errors, "/" for the document root.
+func schemaPath(path string) string {
+	if path == "" {
+		return "/"
+	}
+	return path
+}
+
+// escapePointer escapes a property name as a JSON pointer token.
+func escapePointer(name string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(name)
+}
+
+func encodeJSON(value any) string {
+	data, _ := json.Marshal(value)
+	return string(data)
+}
+
+// checkResultSchema validates the result of a successful execution against
+// its task's result_schema. A mismatch fails the execution, returning the
+// error to report, unless result_schema_policy is "warn", in which case it is
+// only logged and emitted as a task event.
+func (d *ElideDriverPlugin) checkResultSchema(h *taskHandle, executionID string, result string) error {
+	if h.resultSchema == nil {
+		return nil
+	}
+	err := h.resultSchema.Validate(result)
+	if err == nil {
+		return nil
+	}
+
+	d.metrics.IncrCounter(metricResultSchemaMismatches, "Execution results not matching their result_schema, by policy.", "policy", h.resultSchemaPolicy)
+	if h.resultSchemaPolicy != resultSchemaWarn {
+		return fmt.Errorf("result does not match result_schema: %w", err)
+	}
+	h.logger.Warn("result does not match result_schema", "execution_id", executionID, "error", err)
+	d.eventer.EmitEvent(&drivers.TaskEvent{
+		TaskID:    h.taskConfig.ID,
+		AllocID:   h.taskConfig.AllocID,
+		TaskName:  h.taskConfig.Name,
+		Timestamp: time.Now(),
+		Message:   fmt.Sprintf("Result does not match result_schema: %v", err),
+		Annotations: map[string]string{
+			"execution_id": executionID,
+		},
+	})
+	return nil
+}
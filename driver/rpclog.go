@@ -0,0 +1,57 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// rpcLogger logs daemon RPCs: a sample of all calls at debug level, and every
+// call slower than the threshold as a warning, giving visibility into daemon
+// latency without tracing infrastructure.
+type rpcLogger struct {
+	// sampleRate is the fraction of calls logged at debug level (0 to 1)
+	sampleRate float64
+
+	// slowThreshold is the duration above which calls are logged as
+	// warnings (0 = disabled)
+	slowThreshold time.Duration
+
+	logger hclog.Logger
+}
+
+func newRPCLogger(sampleRate float64, slowThreshold time.Duration, logger hclog.Logger) *rpcLogger {
+	return &rpcLogger{
+		sampleRate:    sampleRate,
+		slowThreshold: slowThreshold,
+		logger:        logger.Named("rpc"),
+	}
+}
+
+// dialOption returns the dial option installing the logging interceptor.
+func (l *rpcLogger) dialOption() grpc.DialOption {
+	return grpc.WithUnaryInterceptor(l.intercept)
+}
+
+// intercept is a unary client interceptor logging the method, duration and
+// status code of each sampled or slow call.
+func (l *rpcLogger) intercept(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	start := time.Now()
+	err := invoker(ctx, method, req, reply, cc, opts...)
+	duration := time.Since(start)
+
+	switch {
+	case l.slowThreshold > 0 && duration >= l.slowThreshold:
+		l.logger.Warn("slow daemon RPC", "method", method, "duration", duration, "code", status.Code(err), "threshold", l.slowThreshold)
+	case l.sampleRate > 0 && rand.Float64() < l.sampleRate:
+		l.logger.Debug("daemon RPC", "method", method, "duration", duration, "code", status.Code(err))
+	}
+	return err
+}
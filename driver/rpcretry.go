@@ -0,0 +1,227 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/elide-dev/elide-task-driver/proto/gen/go/elide/daemon/v1alpha1"
+)
+
+const (
+	// defaultRPCRetryName is the rpc_retry block applying to every RPC
+	// without a block of its own
+	defaultRPCRetryName = "default"
+
+	// metricRPCRetries counts daemon RPCs retried after a transient error,
+	// by method and status code
+	metricRPCRetries = "rpc_retries_total"
+)
+
+// RPCRetryConfig is an rpc_retry block of the plugin config, labeled with the
+// RPC it applies to, or "default". Unset options take the value of the
+// "default" block, then the built-in default.
+type RPCRetryConfig struct {
+	// MaxAttempts is the number of calls made before giving up, including
+	// the first (1 = no retries)
+	MaxAttempts int `codec:"max_attempts"`
+	// InitialBackoff is the delay before the first retry; the delay doubles
+	// with each retry (duration string)
+	InitialBackoff string `codec:"initial_backoff"`
+	// MaxBackoff caps the delay between retries (duration string)
+	MaxBackoff string `codec:"max_backoff"`
+	// RetryableCodes are the gRPC status codes retried, e.g. "UNAVAILABLE"
+	RetryableCodes []string `codec:"retryable_codes"`
+}
+
+// RPCRetryPolicy controls how a daemon RPC failing with a transient error is
+// retried. Retries never outlive the deadline of the call.
+type RPCRetryPolicy struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	RetryableCodes []codes.Code
+}
+
+// DefaultRPCRetryPolicy is used for read-only RPCs no rpc_retry block
+// configures: a daemon that is restarting or dropped the connection is tried
+// again twice.
+var DefaultRPCRetryPolicy = RPCRetryPolicy{
+	MaxAttempts:    3,
+	InitialBackoff: 100 * time.Millisecond,
+	MaxBackoff:     2 * time.Second,
+	RetryableCodes: []codes.Code{codes.Unavailable, codes.DeadlineExceeded},
+}
+
+// readOnlyRPCs are the RPCs retried by default. The others change the
+// daemon's state, e.g. ExecuteSnippet starts an execution, and a call that
+// failed with UNAVAILABLE or DEADLINE_EXCEEDED may still have reached the
+// daemon: they are only retried if an rpc_retry block naming them sets
+// max_attempts.
+var readOnlyRPCs = []string{"GetExecutionStatus", "GetSession", "GetSessionValue", "Health"}
+
+// unaryRPCs returns the names of the daemon's unary RPCs, the ones retried;
+// streams are resumed by their callers instead.
+func unaryRPCs() []string {
+	names := make([]string, 0, len(pb.ExecutionApi_ServiceDesc.Methods))
+	for _, method := range pb.ExecutionApi_ServiceDesc.Methods {
+		names = append(names, method.MethodName)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// RPCRetryPolicies returns the retry policy of every unary daemon RPC, keyed
+// by RPC name, from the rpc_retry blocks of the config.
+func (c *Config) RPCRetryPolicies() (map[string]RPCRetryPolicy, error) {
+	rpcs := unaryRPCs()
+	for name := range c.RPCRetry {
+		if name != defaultRPCRetryName && !slices.Contains(rpcs, name) {
+			return nil, fmt.Errorf("rpc_retry %q: unknown RPC (must be %q or one of %s)", name, defaultRPCRetryName, strings.Join(rpcs, ", "))
+		}
+	}
+
+	fallback := c.RPCRetry[defaultRPCRetryName]
+	policies := make(map[string]RPCRetryPolicy, len(rpcs))
+	for _, name := range rpcs {
+		policy, err := fallback.apply(DefaultRPCRetryPolicy)
+		if err != nil {
+			return nil, fmt.Errorf("rpc_retry %q: %w", defaultRPCRetryName, err)
+		}
+		if !slices.Contains(readOnlyRPCs, name) {
+			policy.MaxAttempts = 1
+		}
+		if config, ok := c.RPCRetry[name]; ok {
+			if policy, err = config.apply(policy); err != nil {
+				return nil, fmt.Errorf("rpc_retry %q: %w", name, err)
+			}
+		}
+		policies[name] = policy
+	}
+	return policies, nil
+}
+
+// apply returns policy with the options set in the block.
+func (c RPCRetryConfig) apply(policy RPCRetryPolicy) (RPCRetryPolicy, error) {
+	if c.MaxAttempts < 0 {
+		return policy, fmt.Errorf("max_attempts cannot be negative")
+	}
+	if c.MaxAttempts > 0 {
+		policy.MaxAttempts = c.MaxAttempts
+	}
+	for _, option := range []struct {
+		name  string
+		value string
+		dst   *time.Duration
+	}{
+		{"initial_backoff", c.InitialBackoff, &policy.InitialBackoff},
+		{"max_backoff", c.MaxBackoff, &policy.MaxBackoff},
+	} {
+		if option.value == "" {
+			continue
+		}
+		backoff, err := ParseDuration(option.name, option.value)
+		if err != nil {
+			return policy, err
+		}
+		if backoff == 0 {
+			return policy, fmt.Errorf("invalid %s %q: must be positive", option.name, option.value)
+		}
+		*option.dst = backoff
+	}
+	if policy.MaxBackoff < policy.InitialBackoff {
+		return policy, fmt.Errorf("max_backoff %s is below initial_backoff %s", policy.MaxBackoff, policy.InitialBackoff)
+	}
+	if c.RetryableCodes != nil {
+		policy.RetryableCodes = make([]codes.Code, 0, len(c.RetryableCodes))
+		for _, name := range c.RetryableCodes {
+			var code codes.Code
+			if err := code.UnmarshalJSON([]byte(strconv.Quote(strings.ToUpper(name)))); err != nil || code == codes.OK {
+				return policy, fmt.Errorf("invalid retryable_codes entry %q: must be a gRPC status code such as \"UNAVAILABLE\"", name)
+			}
+			policy.RetryableCodes = append(policy.RetryableCodes, code)
+		}
+	}
+	return policy, nil
+}
+
+// Retryable reports whether a call failing with err may be retried.
+func (p RPCRetryPolicy) Retryable(err error) bool {
+	return err != nil && slices.Contains(p.RetryableCodes, status.Code(err))
+}
+
+// Backoff returns how long to wait before the given retry (1-based): the
+// initial backoff doubled with each retry up to the maximum, jittered so
+// callers failing together do not retry in lockstep.
+func (p RPCRetryPolicy) Backoff(retry int) time.Duration {
+	backoff := p.InitialBackoff
+	for i := 1; i < retry && backoff < p.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	return jitter(min(backoff, p.MaxBackoff))
+}
+
+// rpcRetrier retries daemon RPCs that fail with a transient error, per the
+// policy of each RPC.
+type rpcRetrier struct {
+	policies map[string]RPCRetryPolicy
+	metrics  *metricsRegistry
+	logger   hclog.Logger
+}
+
+func newRPCRetrier(policies map[string]RPCRetryPolicy, metrics *metricsRegistry, logger hclog.Logger) *rpcRetrier {
+	return &rpcRetrier{
+		policies: policies,
+		metrics:  metrics,
+		logger:   logger.Named("rpc"),
+	}
+}
+
+// dialOption returns the dial option installing the retrying interceptor.
+// Interceptors chained after it see each attempt as a call of its own.
+func (r *rpcRetrier) dialOption() grpc.DialOption {
+	return grpc.WithChainUnaryInterceptor(r.intercept)
+}
+
+// intercept is a unary client interceptor retrying failed calls while their
+// policy allows and their context has time left for the backoff.
+func (r *rpcRetrier) intercept(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	name := path.Base(method)
+	policy := r.policies[name]
+	for attempt := 1; ; attempt++ {
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		if err == nil || attempt >= policy.MaxAttempts || !policy.Retryable(err) || ctx.Err() != nil {
+			if err == nil && attempt > 1 {
+				r.logger.Debug("daemon RPC succeeded after retrying", "method", name, "attempts", attempt)
+			}
+			return err
+		}
+
+		backoff := policy.Backoff(attempt)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < backoff {
+			return err
+		}
+		code := status.Code(err)
+		r.metrics.IncrCounter(metricRPCRetries, "Daemon RPCs retried after a transient error, by method and status code.", "method", name, "code", code.String())
+		r.logger.Debug("retrying daemon RPC", "method", name, "attempt", attempt, "code", code, "backoff", backoff)
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+	}
+}
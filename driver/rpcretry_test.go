@@ -0,0 +1,45 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/elide-dev/elide-task-driver/proto/gen/go/elide/daemon/v1alpha1"
+)
+
+func TestRPCRetrier_DefaultPolicies(t *testing.T) {
+	policies, err := (&Config{}).RPCRetryPolicies()
+	require.NoError(t, err)
+	logger := hclog.NewNullLogger()
+	retrier := newRPCRetrier(policies, newMetricsRegistry(logger), logger)
+
+	for _, tc := range []struct {
+		method string
+		calls  int
+	}{
+		{pb.ExecutionApi_ExecuteSnippet_FullMethodName, 1},
+		{pb.ExecutionApi_CreateSession_FullMethodName, 1},
+		{pb.ExecutionApi_PutSessionValue_FullMethodName, 1},
+		{pb.ExecutionApi_GetExecutionStatus_FullMethodName, DefaultRPCRetryPolicy.MaxAttempts},
+		{pb.ExecutionApi_Health_FullMethodName, DefaultRPCRetryPolicy.MaxAttempts},
+	} {
+		calls := 0
+		invoker := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+			calls++
+			return status.Error(codes.Unavailable, "connection reset")
+		}
+		err := retrier.intercept(context.Background(), tc.method, nil, nil, nil, invoker)
+		assert.Equal(t, codes.Unavailable, status.Code(err), tc.method)
+		assert.Equal(t, tc.calls, calls, tc.method)
+	}
+}
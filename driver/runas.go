@@ -0,0 +1,37 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"fmt"
+	"slices"
+
+	pb "github.com/elide-dev/elide-task-driver/proto/gen/go/elide/daemon/v1alpha1"
+)
+
+// checkRunAs checks that a task may run as the user set by Nomad's user
+// stanza: the user must be in allowed_users and the daemon must be able to
+// run executions as another user. An empty user runs as the daemon's.
+func (d *ElideDriverPlugin) checkRunAs(user string) error {
+	if user == "" {
+		return nil
+	}
+	if !slices.Contains(d.config.AllowedUsers, user) {
+		return fmt.Errorf("user %q is not in the plugin's allowed_users", user)
+	}
+	if !d.supportsRunAs.Load() {
+		return fmt.Errorf("user %q: the daemon does not support running executions as another user", user)
+	}
+	return nil
+}
+
+// negotiateRunAs records whether the daemon can run executions as another
+// user. Daemons that predate run_as ignore the field, so it is only sent to
+// daemons reporting support.
+func (d *ElideDriverPlugin) negotiateRunAs(health *pb.HealthResponse) {
+	supported := health.GetSupportsRunAs()
+	if old := d.supportsRunAs.Swap(supported); old != supported {
+		d.logger.Debug("negotiated run-as support", "supported", supported)
+	}
+}
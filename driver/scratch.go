@@ -0,0 +1,127 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	pb "github.com/elide-dev/elide-task-driver/proto/gen/go/elide/daemon/v1alpha1"
+)
+
+const (
+	// scratchDirName is the scratch directory created under the task dir
+	scratchDirName = "scratch"
+
+	// scratchEnvVar passes the scratch directory path to the execution
+	scratchEnvVar = "ELIDE_SCRATCH_DIR"
+
+	// scratchCheckInterval is how often the scratch quota is enforced
+	scratchCheckInterval = 5 * time.Second
+)
+
+// createScratchDir creates an empty, per-execution scratch directory under
+// the task directory and returns its path. The directory is only writable by
+// its owner: the task's user if set, the driver's otherwise.
+func createScratchDir(taskDir string, runAs string) (string, error) {
+	dir := filepath.Join(taskDir, scratchDirName)
+	if err := os.RemoveAll(dir); err != nil {
+		return "", fmt.Errorf("failed to clear scratch dir: %w", err)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create scratch dir: %w", err)
+	}
+	if runAs != "" {
+		if err := chownToUser(dir, runAs); err != nil {
+			os.RemoveAll(dir)
+			return "", fmt.Errorf("failed to give scratch dir to user %q: %w", runAs, err)
+		}
+	}
+	return dir, nil
+}
+
+// chownToUser makes the named OS user the owner of path.
+func chownToUser(path string, name string) error {
+	u, err := user.Lookup(name)
+	if err != nil {
+		return err
+	}
+	uid, err := strconv.Atoi(u.Uid)
+	if err != nil {
+		return fmt.Errorf("user %q has non-numeric uid %q", name, u.Uid)
+	}
+	gid, err := strconv.Atoi(u.Gid)
+	if err != nil {
+		return fmt.Errorf("user %q has non-numeric gid %q", name, u.Gid)
+	}
+	return os.Chown(path, uid, gid)
+}
+
+// dirSize returns the total size of the regular files under dir.
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(dir, func(_ string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			// Files may disappear while the execution is running
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		size += info.Size()
+		return nil
+	})
+	return size, err
+}
+
+// enforceScratchQuota cancels the execution if its scratch dir grew beyond
+// the configured quota.
+func (d *ElideDriverPlugin) enforceScratchQuota(handle *taskHandle) {
+	handle.stateLock.RLock()
+	dir, quota, cancelled := handle.scratchDir, handle.scratchQuota, handle.cancelErr != nil
+	handle.stateLock.RUnlock()
+	if dir == "" || quota <= 0 || cancelled {
+		return
+	}
+
+	size, err := dirSize(dir)
+	if err != nil {
+		handle.logger.Warn("failed to measure scratch dir", "path", dir, "error", err)
+		return
+	}
+	if size <= quota {
+		return
+	}
+
+	ctx, cancel := d.withTimeout(d.ctx, d.cancelTimeout())
+	defer cancel()
+	_ = d.cancelExecution(ctx, handle, pb.CancellationReason_CANCELLATION_REASON_QUOTA, initiatorDriver,
+		fmt.Errorf("scratch dir uses %d bytes, exceeding its quota of %d bytes", size, quota))
+}
+
+// removeScratchDir deletes the task's scratch directory, if any.
+func (h *taskHandle) removeScratchDir() error {
+	h.stateLock.RLock()
+	dir := h.scratchDir
+	h.stateLock.RUnlock()
+	if dir == "" {
+		return nil
+	}
+	return os.RemoveAll(dir)
+}
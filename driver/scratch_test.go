@@ -0,0 +1,134 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/nomad/plugins/drivers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	pb "github.com/elide-dev/elide-task-driver/proto/gen/go/elide/daemon/v1alpha1"
+)
+
+// cancelRecordingClient records the cancel requests sent through it.
+type cancelRecordingClient struct {
+	sessionDeletingClient
+
+	cancelMu sync.Mutex
+	reasons  []pb.CancellationReason
+}
+
+func (c *cancelRecordingClient) CancelExecution(ctx context.Context, sessionID string, executionID string, reason pb.CancellationReason, initiator string, token string) (bool, error) {
+	c.cancelMu.Lock()
+	defer c.cancelMu.Unlock()
+	c.reasons = append(c.reasons, reason)
+	return true, nil
+}
+
+func writeScratchFile(t *testing.T, path string, size int) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(t, os.WriteFile(path, []byte(strings.Repeat("x", size)), 0644))
+}
+
+func TestCreateScratchDir(t *testing.T) {
+	taskDir := t.TempDir()
+	writeScratchFile(t, filepath.Join(taskDir, scratchDirName, "stale"), 10)
+
+	dir, err := createScratchDir(taskDir, "")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(taskDir, scratchDirName), dir)
+
+	info, err := os.Stat(dir)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0755), info.Mode().Perm())
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, entries, "files of an earlier execution are cleared")
+}
+
+func TestDirSize(t *testing.T) {
+	dir := t.TempDir()
+	writeScratchFile(t, filepath.Join(dir, "a"), 100)
+	writeScratchFile(t, filepath.Join(dir, "nested", "deeper", "b"), 50)
+
+	// Links are not followed, so files outside the dir are not counted
+	outside := filepath.Join(t.TempDir(), "big")
+	writeScratchFile(t, outside, 1000)
+	require.NoError(t, os.Symlink(outside, filepath.Join(dir, "link")))
+
+	size, err := dirSize(dir)
+	require.NoError(t, err)
+	assert.Equal(t, int64(150), size)
+
+	size, err = dirSize(filepath.Join(dir, "missing"))
+	require.NoError(t, err)
+	assert.Zero(t, size)
+}
+
+func TestEnforceScratchQuota(t *testing.T) {
+	tests := []struct {
+		name      string
+		used      int
+		quota     int64
+		cancelled bool
+		wantStop  bool
+	}{
+		{name: "within quota", used: 100, quota: 100},
+		{name: "over quota", used: 101, quota: 100, wantStop: true},
+		{name: "unlimited", used: 1000, quota: 0},
+		{name: "already cancelled", used: 101, quota: 100, cancelled: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewPlugin(hclog.NewNullLogger()).(*ElideDriverPlugin)
+			t.Cleanup(d.Shutdown)
+			client := &cancelRecordingClient{}
+			d.daemonClient = client
+
+			dir := t.TempDir()
+			writeScratchFile(t, filepath.Join(dir, "out"), tt.used)
+			h := &taskHandle{
+				taskConfig:   &drivers.TaskConfig{ID: "task-1"},
+				executionId:  "exec-1",
+				sessionId:    "session-1",
+				logger:       hclog.NewNullLogger(),
+				scratchDir:   dir,
+				scratchQuota: tt.quota,
+			}
+			if tt.cancelled {
+				h.markCancelled(pb.CancellationReason_CANCELLATION_REASON_USER_STOP, initiatorNomad, nil)
+			}
+
+			d.enforceScratchQuota(h)
+			if !tt.wantStop {
+				assert.Empty(t, client.reasons)
+				return
+			}
+			assert.Equal(t, []pb.CancellationReason{pb.CancellationReason_CANCELLATION_REASON_QUOTA}, client.reasons)
+			assert.ErrorContains(t, h.cancelErr, "exceeding its quota of 100 bytes")
+		})
+	}
+}
+
+func TestStartTask_RemovesScratchDirWhenSubmitFails(t *testing.T) {
+	d := NewPlugin(hclog.NewNullLogger()).(*ElideDriverPlugin)
+	t.Cleanup(d.Shutdown)
+	d.daemonClient = &failingSubmitClient{}
+
+	cfg := &drivers.TaskConfig{ID: "task-1", JobID: "job", Namespace: "default", AllocDir: t.TempDir()}
+	require.NoError(t, cfg.EncodeConcreteDriverConfig(&TaskConfig{Language: "python", Code: "print(1)", ScratchDir: true}))
+
+	_, _, err := d.StartTask(cfg)
+	require.ErrorContains(t, err, "syntax error")
+	assert.NoDirExists(t, filepath.Join(cfg.TaskDir().Dir, scratchDirName))
+}
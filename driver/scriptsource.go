@@ -0,0 +1,93 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// scriptSourceTask resolves script relative to the task directory
+	scriptSourceTask = "task"
+
+	// scriptSourceArtifact resolves script relative to the directories
+	// Nomad artifact stanzas download into
+	scriptSourceArtifact = "artifact"
+
+	// maxListedArtifactFiles caps the files named when an artifact script is
+	// not found
+	maxListedArtifactFiles = 20
+)
+
+// artifactDirs are the directories, relative to the task directory, searched
+// in order for a script with script_source = "artifact": local/ is the
+// default destination of an artifact stanza.
+var artifactDirs = []string{"local", "downloads"}
+
+// validateScriptSource checks the script_source option of a task config.
+func (tc *TaskConfig) validateScriptSource() error {
+	switch tc.ScriptSource {
+	case "", scriptSourceTask:
+	case scriptSourceArtifact:
+		if tc.Script == "" {
+			return fmt.Errorf("script_source %q requires 'script'", tc.ScriptSource)
+		}
+	default:
+		return fmt.Errorf("invalid script_source %q (must be %q or %q)", tc.ScriptSource, scriptSourceTask, scriptSourceArtifact)
+	}
+	return nil
+}
+
+// artifactScriptPath resolves the script in the first artifact directory
+// holding it, with the containment rules of ScriptPath. If none does, the
+// error lists the files the artifact directories hold.
+func (tc *TaskConfig) artifactScriptPath(taskDir string, followSymlinks bool) (string, error) {
+	for _, dir := range artifactDirs {
+		name := filepath.Join(dir, tc.Script)
+		if _, err := os.Lstat(filepath.Join(taskDir, name)); errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		return resolveTaskFile(taskDir, "script", name, followSymlinks)
+	}
+
+	dirs := make([]string, len(artifactDirs))
+	for i, dir := range artifactDirs {
+		dirs[i] = dir + "/"
+	}
+	return "", fmt.Errorf("script %q not found in the task's artifact directories %s (%s)", tc.Script, strings.Join(dirs, ", "), listArtifactFiles(taskDir))
+}
+
+// listArtifactFiles describes the files in the artifact directories, by the
+// path a script option would name them with.
+func listArtifactFiles(taskDir string) string {
+	var files []string
+	total := 0
+	for _, dir := range artifactDirs {
+		root := filepath.Join(taskDir, dir)
+		_ = filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil || entry.IsDir() {
+				return nil
+			}
+			total++
+			if len(files) < maxListedArtifactFiles {
+				rel, _ := filepath.Rel(root, path)
+				files = append(files, filepath.ToSlash(rel))
+			}
+			return nil
+		})
+	}
+	switch {
+	case total == 0:
+		return "they hold no files; check the artifact's destination"
+	case total > len(files):
+		return fmt.Sprintf("available: %s and %d more", strings.Join(files, ", "), total-len(files))
+	default:
+		return "available: " + strings.Join(files, ", ")
+	}
+}
@@ -0,0 +1,151 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/nomad/plugins/drivers"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/elide-dev/elide-task-driver/proto/gen/go/elide/daemon/v1alpha1"
+)
+
+// metricSessionEvents counts the session events received from the daemon
+const metricSessionEvents = "session_events_total"
+
+// followSessionEvents consumes the daemon's session event stream for the
+// lifetime of the driver, turning events about tracked tasks into task
+// events. The stream is re-opened after daemon outages and other breaks; it
+// is abandoned if the daemon does not support session events.
+func (d *ElideDriverPlugin) followSessionEvents() {
+	delay := reconnectBaseDelay
+	for {
+		if err := d.reconnect.wait(d.ctx); err != nil {
+			return
+		}
+
+		opened, err := d.consumeSessionEvents()
+		if opened {
+			delay = reconnectBaseDelay
+		}
+		switch {
+		case d.ctx.Err() != nil:
+			return
+		case status.Code(err) == codes.Unimplemented:
+			d.logger.Info("daemon does not support session events, task events are not enriched")
+			return
+		case isUnavailable(err):
+			d.reconnect.markDown(err)
+		case err != nil:
+			d.logger.Warn("session event stream broke, re-opening it", "error", err, "delay", delay)
+		}
+
+		select {
+		case <-d.ctx.Done():
+			return
+		case <-time.After(jitter(delay)):
+		}
+		delay = min(delay*2, reconnectMaxDelay)
+	}
+}
+
+// consumeSessionEvents handles the events of one session event stream until
+// it breaks. opened reports whether the stream was established.
+func (d *ElideDriverPlugin) consumeSessionEvents() (opened bool, err error) {
+	ctx, cancel := context.WithCancel(d.ctx)
+	defer cancel()
+
+	stream, err := d.daemonClient.WatchSessionEvents(ctx, "")
+	if err != nil {
+		return false, err
+	}
+	for {
+		event, err := stream.Recv()
+		if err != nil {
+			if errors.Is(err, context.Canceled) {
+				return true, nil
+			}
+			return true, err
+		}
+		d.handleSessionEvent(event)
+	}
+}
+
+// handleSessionEvent emits a session event as a task event of each tracked
+// task it concerns: the task running the execution for execution events, or
+// every task in the session for session events.
+func (d *ElideDriverPlugin) handleSessionEvent(event *pb.SessionEvent) {
+	d.metrics.IncrCounter(metricSessionEvents, "Session events received from the daemon.", "type", sessionEventTypeName(event.Type))
+
+	for _, h := range d.tasks.List() {
+		h.stateLock.RLock()
+		concerned := h.sessionId == event.SessionId && (event.ExecutionId == "" || h.runsExecution(event.ExecutionId))
+		h.stateLock.RUnlock()
+		if !concerned {
+			continue
+		}
+		d.eventer.EmitEvent(sessionTaskEvent(h.taskConfig, event))
+	}
+}
+
+// runsExecution reports whether an execution is the task's, or one of its
+// args_matrix executions. Callers must hold stateLock.
+func (h *taskHandle) runsExecution(executionID string) bool {
+	if h.executionId == executionID {
+		return true
+	}
+	for _, entry := range h.matrix {
+		if entry.ExecutionId == executionID {
+			return true
+		}
+	}
+	return false
+}
+
+// sessionTaskEvent returns the task event for a session event. The event's
+// details become annotations, next to its type, session and execution, which
+// details cannot override.
+func sessionTaskEvent(cfg *drivers.TaskConfig, event *pb.SessionEvent) *drivers.TaskEvent {
+	annotations := maps.Clone(event.Details)
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations["daemon_event"] = sessionEventTypeName(event.Type)
+	annotations["session_id"] = event.SessionId
+	if event.ExecutionId != "" {
+		annotations["execution_id"] = event.ExecutionId
+	} else {
+		delete(annotations, "execution_id")
+	}
+
+	message := event.Message
+	if message == "" {
+		message = strings.ReplaceAll(sessionEventTypeName(event.Type), "_", " ")
+	}
+	timestamp := time.Now()
+	if event.Timestamp > 0 {
+		timestamp = time.UnixMilli(event.Timestamp)
+	}
+
+	return &drivers.TaskEvent{
+		TaskID:      cfg.ID,
+		AllocID:     cfg.AllocID,
+		TaskName:    cfg.Name,
+		Timestamp:   timestamp,
+		Message:     "Daemon: " + message,
+		Annotations: annotations,
+	}
+}
+
+// sessionEventTypeName returns the short name of an event type, e.g.
+// "execution_started".
+func sessionEventTypeName(eventType pb.SessionEventType) string {
+	return strings.ToLower(strings.TrimPrefix(eventType.String(), "SESSION_EVENT_TYPE_"))
+}
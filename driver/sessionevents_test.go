@@ -0,0 +1,261 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"context"
+	"sort"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/nomad/plugins/drivers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/elide-dev/elide-task-driver/proto/gen/go/elide/daemon/v1alpha1"
+)
+
+// scriptedEventStream is a session event stream returning its events, then
+// failing with err, or blocking until its context ends if err is nil.
+type scriptedEventStream struct {
+	ctx    context.Context
+	events []*pb.SessionEvent
+	err    error
+}
+
+func (s *scriptedEventStream) Recv() (*pb.SessionEvent, error) {
+	if len(s.events) > 0 {
+		event := s.events[0]
+		s.events = s.events[1:]
+		return event, nil
+	}
+	if s.err != nil {
+		return nil, s.err
+	}
+	<-s.ctx.Done()
+	return nil, s.ctx.Err()
+}
+
+// eventStreamScript is a stream opened by eventStreamClient: openErr fails
+// to open it, others return events then fail with err.
+type eventStreamScript struct {
+	openErr error
+	events  []*pb.SessionEvent
+	err     error
+}
+
+// eventStreamClient opens the streams of its script in turn. Calls past the
+// script get a stream that blocks.
+type eventStreamClient struct {
+	flakyDaemonClient
+
+	streams []eventStreamScript
+	opens   atomic.Int32
+}
+
+func (c *eventStreamClient) WatchSessionEvents(ctx context.Context, sessionID string) (SessionEventStream, error) {
+	n := int(c.opens.Add(1))
+	if n > len(c.streams) {
+		return &scriptedEventStream{ctx: ctx}, nil
+	}
+	script := c.streams[n-1]
+	if script.openErr != nil {
+		return nil, script.openErr
+	}
+	return &scriptedEventStream{ctx: ctx, events: script.events, err: script.err}, nil
+}
+
+// newSessionEventPlugin returns a driver tracking task-1 running exec-1 and
+// task-2 running exec-2a and exec-2b as an args_matrix in session-1, and
+// task-3 running another exec-1 in session-2. Its task events are sent on
+// the returned channel.
+func newSessionEventPlugin(t *testing.T, client DaemonClient) (*ElideDriverPlugin, <-chan *drivers.TaskEvent) {
+	d := NewPlugin(hclog.NewNullLogger()).(*ElideDriverPlugin)
+	t.Cleanup(d.Shutdown)
+	d.daemonClient = client
+	d.sessionID = "session-1"
+
+	for _, state := range []*TaskState{
+		{TaskConfig: &drivers.TaskConfig{ID: "task-1"}, ExecutionId: "exec-1", SessionId: "session-1"},
+		{
+			TaskConfig:  &drivers.TaskConfig{ID: "task-2"},
+			ExecutionId: "exec-2a",
+			SessionId:   "session-1",
+			Matrix:      []*MatrixEntry{{Index: 0, ExecutionId: "exec-2a"}, {Index: 1, ExecutionId: "exec-2b"}},
+		},
+		{TaskConfig: &drivers.TaskConfig{ID: "task-3"}, ExecutionId: "exec-1", SessionId: "session-2"},
+	} {
+		state.StartedAt = time.Now()
+		d.tasks.Set(state.TaskConfig.ID, d.recoveredHandle(state))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	events, err := d.TaskEvents(ctx)
+	require.NoError(t, err)
+	return d, events
+}
+
+// receiveEvents collects the task events received in the background; the
+// returned function returns them, by task ID, once none arrived for a while.
+// The eventer drops events no one is receiving.
+func receiveEvents(t *testing.T, events <-chan *drivers.TaskEvent) func() map[string]*drivers.TaskEvent {
+	t.Helper()
+	done := make(chan map[string]*drivers.TaskEvent, 1)
+	go func() {
+		received := map[string]*drivers.TaskEvent{}
+		for {
+			select {
+			case event := <-events:
+				assert.NotContains(t, received, event.TaskID, "one event per task")
+				received[event.TaskID] = event
+			case <-time.After(100 * time.Millisecond):
+				done <- received
+				return
+			}
+		}
+	}()
+	return func() map[string]*drivers.TaskEvent { return <-done }
+}
+
+func taskIDs(events map[string]*drivers.TaskEvent) []string {
+	var ids []string
+	for id := range events {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func TestHandleSessionEvent_MatchesTasks(t *testing.T) {
+	tests := []struct {
+		name  string
+		event *pb.SessionEvent
+		tasks []string
+	}{
+		{
+			name:  "execution",
+			event: &pb.SessionEvent{Type: pb.SessionEventType_SESSION_EVENT_TYPE_EXECUTION_STARTED, SessionId: "session-1", ExecutionId: "exec-1"},
+			tasks: []string{"task-1"},
+		},
+		{
+			name:  "args_matrix execution",
+			event: &pb.SessionEvent{Type: pb.SessionEventType_SESSION_EVENT_TYPE_EXECUTION_SIGNALLED, SessionId: "session-1", ExecutionId: "exec-2b"},
+			tasks: []string{"task-2"},
+		},
+		{
+			name:  "session",
+			event: &pb.SessionEvent{Type: pb.SessionEventType_SESSION_EVENT_TYPE_CONTEXTS_RECYCLED, SessionId: "session-1"},
+			tasks: []string{"task-1", "task-2"},
+		},
+		{
+			name:  "untracked execution",
+			event: &pb.SessionEvent{Type: pb.SessionEventType_SESSION_EVENT_TYPE_EXECUTION_STARTED, SessionId: "session-1", ExecutionId: "exec-9"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, events := newSessionEventPlugin(t, &flakyDaemonClient{})
+			received := receiveEvents(t, events)
+			d.handleSessionEvent(tt.event)
+			assert.Equal(t, tt.tasks, taskIDs(received()))
+		})
+	}
+}
+
+func TestHandleSessionEvent_Annotations(t *testing.T) {
+	d, events := newSessionEventPlugin(t, &flakyDaemonClient{})
+	receive := receiveEvents(t, events)
+	d.handleSessionEvent(&pb.SessionEvent{
+		Type:      pb.SessionEventType_SESSION_EVENT_TYPE_EXECUTION_FAILED,
+		SessionId: "session-1",
+		Timestamp: 1760000000000,
+		Details: map[string]string{
+			"reason":       "oom",
+			"daemon_event": "forged",
+			"session_id":   "session-9",
+			"execution_id": "exec-9",
+		},
+		ExecutionId: "exec-1",
+	})
+	d.handleSessionEvent(&pb.SessionEvent{
+		Type:      pb.SessionEventType_SESSION_EVENT_TYPE_CONTEXTS_RECYCLED,
+		SessionId: "session-2",
+		Message:   "recycled 4 contexts",
+		Details:   map[string]string{"execution_id": "exec-9"},
+	})
+
+	received := receive()
+	require.Equal(t, []string{"task-1", "task-3"}, taskIDs(received))
+
+	// The daemon's details cannot override the reserved annotations
+	assert.Equal(t, map[string]string{
+		"reason":       "oom",
+		"daemon_event": "execution_failed",
+		"session_id":   "session-1",
+		"execution_id": "exec-1",
+	}, received["task-1"].Annotations)
+	assert.Equal(t, "Daemon: execution failed", received["task-1"].Message)
+	assert.Equal(t, time.UnixMilli(1760000000000), received["task-1"].Timestamp)
+
+	assert.Equal(t, map[string]string{
+		"daemon_event": "contexts_recycled",
+		"session_id":   "session-2",
+	}, received["task-3"].Annotations)
+	assert.Equal(t, "Daemon: recycled 4 contexts", received["task-3"].Message)
+	assert.Contains(t, scrape(t, d), `elide_driver_session_events_total{type="execution_failed"} 1`)
+}
+
+func TestFollowSessionEvents_GivesUpOnUnimplemented(t *testing.T) {
+	client := &eventStreamClient{streams: []eventStreamScript{
+		{openErr: status.Error(codes.Unimplemented, "unknown method WatchSessionEvents")},
+	}}
+	d, _ := newSessionEventPlugin(t, client)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		d.followSessionEvents()
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("session events were followed from a daemon not supporting them")
+	}
+	assert.Equal(t, int32(1), client.opens.Load())
+}
+
+func TestFollowSessionEvents_ReopensBrokenStream(t *testing.T) {
+	started := &pb.SessionEvent{Type: pb.SessionEventType_SESSION_EVENT_TYPE_EXECUTION_STARTED, SessionId: "session-1", ExecutionId: "exec-1"}
+	completed := &pb.SessionEvent{Type: pb.SessionEventType_SESSION_EVENT_TYPE_EXECUTION_COMPLETED, SessionId: "session-1", ExecutionId: "exec-1"}
+	client := &eventStreamClient{streams: []eventStreamScript{
+		{events: []*pb.SessionEvent{started}, err: status.Error(codes.Internal, "stream reset")},
+		{openErr: status.Error(codes.Unavailable, "connection refused")},
+		{events: []*pb.SessionEvent{completed}},
+	}}
+	d, events := newSessionEventPlugin(t, client)
+	go d.followSessionEvents()
+
+	// The outage itself is reported in events of its own
+	for _, want := range []string{"execution_started", "execution_completed"} {
+		for received := false; !received; {
+			select {
+			case event := <-events:
+				if event.Annotations["daemon_event"] == "" {
+					continue
+				}
+				assert.Equal(t, "task-1", event.TaskID)
+				assert.Equal(t, want, event.Annotations["daemon_event"])
+				received = true
+			case <-time.After(10 * time.Second):
+				t.Fatalf("no %s event after the stream broke", want)
+			}
+		}
+	}
+	assert.Equal(t, int32(3), client.opens.Load())
+}
@@ -0,0 +1,211 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	pb "github.com/elide-dev/elide-task-driver/proto/gen/go/elide/daemon/v1alpha1"
+)
+
+const (
+	// adminSessionPlanPath is the admin API path reporting how the default
+	// session differs from the plugin config
+	adminSessionPlanPath = "/v1/session/plan"
+
+	// adminSessionApplyPath is the admin API path reconciling the default
+	// session with the plugin config
+	adminSessionApplyPath = "/v1/session/apply"
+
+	// sessionPlanTimeout bounds the daemon calls of a plan or apply
+	sessionPlanTimeout = 10 * time.Second
+)
+
+// Actions of a session plan
+const (
+	// PlanNoop means the session matches the plugin config
+	PlanNoop = "noop"
+
+	// PlanCreate means there is no session yet; one is created
+	PlanCreate = "create"
+
+	// PlanReplace means the session differs from the plugin config; it is
+	// retired and a new one created, as sessions cannot be changed in place
+	PlanReplace = "replace"
+)
+
+// Change operations of a session plan
+const (
+	ChangeUpdate = "update"
+	ChangeAdd    = "add"
+	ChangeRemove = "remove"
+)
+
+// SessionPlan describes what reconciling the default session with the plugin
+// config (and session manifest) would change.
+type SessionPlan struct {
+	// SessionID is the live session, if there is one
+	SessionID string `json:"session_id,omitempty"`
+
+	// Action is PlanNoop, PlanCreate or PlanReplace
+	Action string `json:"action"`
+
+	// Changes are the differences between the live and the desired session
+	// configuration
+	Changes []SessionChange `json:"changes,omitempty"`
+
+	// NewSessionID is the session created by an applied plan
+	NewSessionID string `json:"new_session_id,omitempty"`
+}
+
+// SessionChange is one difference between the live and the desired session
+// configuration. Scalar fields are updated; list fields add or remove single
+// elements.
+type SessionChange struct {
+	Field   string `json:"field"`
+	Op      string `json:"op"`
+	Current string `json:"current,omitempty"`
+	Desired string `json:"desired,omitempty"`
+}
+
+// DiffSessionConfig returns the changes turning the live session
+// configuration into the desired one. Tags and init snippets are not
+// compared: tags carry node details the driver learns over time, and init
+// snippets are covered by the session manifest revision.
+func DiffSessionConfig(live, desired *pb.SessionConfiguration) []SessionChange {
+	var changes []SessionChange
+	scalar := func(field, current, wanted string) {
+		if current != wanted {
+			changes = append(changes, SessionChange{Field: field, Op: ChangeUpdate, Current: current, Desired: wanted})
+		}
+	}
+	list := func(field string, current, wanted []string) {
+		for _, v := range current {
+			if !slices.Contains(wanted, v) {
+				changes = append(changes, SessionChange{Field: field, Op: ChangeRemove, Current: v})
+			}
+		}
+		for _, v := range wanted {
+			if !slices.Contains(current, v) {
+				changes = append(changes, SessionChange{Field: field, Op: ChangeAdd, Desired: v})
+			}
+		}
+	}
+
+	scalar("context_pool_size", strconv.FormatUint(uint64(live.GetContextPoolSize()), 10), strconv.FormatUint(uint64(desired.GetContextPoolSize()), 10))
+	list("enabled_languages", live.GetEnabledLanguages(), desired.GetEnabledLanguages())
+	list("enabled_intrinsics", live.GetEnabledIntrinsics(), desired.GetEnabledIntrinsics())
+	scalar("memory_limit_mb", strconv.FormatUint(live.GetMemoryLimitMb(), 10), strconv.FormatUint(desired.GetMemoryLimitMb(), 10))
+	scalar("enable_ai", strconv.FormatBool(live.GetEnableAi()), strconv.FormatBool(desired.GetEnableAi()))
+	return changes
+}
+
+// PlanSession compares the live default session, as reported by GetSession,
+// with the session configuration the driver would create it with.
+func (d *ElideDriverPlugin) PlanSession(ctx context.Context) (*SessionPlan, error) {
+	d.sessionLock.Lock()
+	defer d.sessionLock.Unlock()
+	return d.planSession(ctx)
+}
+
+// planSession computes the session plan. Callers must hold sessionLock.
+func (d *ElideDriverPlugin) planSession(ctx context.Context) (*SessionPlan, error) {
+	if d.daemonClient == nil {
+		return nil, errors.New("daemon client not initialized")
+	}
+	if d.sessionID == "" {
+		return &SessionPlan{Action: PlanCreate}, nil
+	}
+
+	resp, err := d.daemonClient.GetSession(ctx, d.sessionID)
+	if lost, _ := sessionLost(d.sessionID, resp, err); lost {
+		current := "not found"
+		if err == nil {
+			current = resp.Status.String()
+		}
+		return &SessionPlan{SessionID: d.sessionID, Action: PlanCreate, Changes: []SessionChange{
+			{Field: "status", Op: ChangeUpdate, Current: current, Desired: pb.SessionStatus_SESSION_STATUS_ACTIVE.String()},
+		}}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session %s: %w", d.sessionID, err)
+	}
+
+	plan := &SessionPlan{
+		SessionID: d.sessionID,
+		Action:    PlanNoop,
+		Changes:   DiffSessionConfig(resp.Config, d.buildSessionConfig()),
+	}
+	if len(plan.Changes) > 0 {
+		plan.Action = PlanReplace
+	}
+	return plan, nil
+}
+
+// ApplySession reconciles the default session with the plugin config. A
+// session that differs is retired like on a session manifest change: new
+// tasks run in a new session while running tasks finish in the old one. It
+// returns the plan that was applied.
+func (d *ElideDriverPlugin) ApplySession(ctx context.Context) (*SessionPlan, error) {
+	d.sessionLock.Lock()
+	defer d.sessionLock.Unlock()
+
+	plan, err := d.planSession(ctx)
+	if err != nil {
+		return nil, err
+	}
+	switch plan.Action {
+	case PlanNoop:
+		return plan, nil
+	case PlanReplace:
+		d.retireSessions("session apply")
+	case PlanCreate:
+		d.sessionID = ""
+	}
+
+	if err := d.createSession(ctx); err != nil {
+		return plan, fmt.Errorf("failed to create session: %w", err)
+	}
+	plan.NewSessionID = d.sessionID
+	d.logger.Info("applied session plan", "action", plan.Action, "changes", len(plan.Changes), "session_id", d.sessionID)
+	return plan, nil
+}
+
+// handleAdminSessionPlan reports the session plan.
+func (d *ElideDriverPlugin) handleAdminSessionPlan(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), sessionPlanTimeout)
+	defer cancel()
+
+	plan, err := d.PlanSession(ctx)
+	if err != nil {
+		writeAdminJSON(w, http.StatusBadGateway, map[string]string{"error": err.Error()})
+		return
+	}
+	writeAdminJSON(w, http.StatusOK, plan)
+}
+
+// handleAdminSessionApply applies the session plan on POST.
+func (d *ElideDriverPlugin) handleAdminSessionApply(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		writeAdminJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), sessionPlanTimeout)
+	defer cancel()
+
+	plan, err := d.ApplySession(ctx)
+	if err != nil {
+		writeAdminJSON(w, http.StatusBadGateway, map[string]string{"error": err.Error()})
+		return
+	}
+	writeAdminJSON(w, http.StatusOK, plan)
+}
@@ -0,0 +1,154 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/elide-dev/elide-task-driver/proto/gen/go/elide/daemon/v1alpha1"
+)
+
+const (
+	// sessionCreateTimeout bounds a single CreateSession call
+	sessionCreateTimeout = 5 * time.Second
+
+	// sessionGetTimeout bounds a single GetSession call
+	sessionGetTimeout = 3 * time.Second
+)
+
+// SessionRetryConfig is the session_retry block of the plugin config.
+type SessionRetryConfig struct {
+	// Attempts is the number of create/get rounds before giving up
+	Attempts int `codec:"attempts"`
+	// BaseDelay is the delay after the first failed round; the delay grows
+	// linearly with each round (duration string)
+	BaseDelay string `codec:"base_delay"`
+	// Jitter is the largest random delay added to each wait (duration string)
+	Jitter string `codec:"jitter"`
+}
+
+// SessionRetryPolicy controls how the driver retries opening a session while
+// the daemon is starting up or another instance races to create it.
+type SessionRetryPolicy struct {
+	Attempts  int
+	BaseDelay time.Duration
+	Jitter    time.Duration
+}
+
+// DefaultSessionRetryPolicy is used when the plugin config has no
+// session_retry block.
+var DefaultSessionRetryPolicy = SessionRetryPolicy{
+	Attempts:  5,
+	BaseDelay: 200 * time.Millisecond,
+}
+
+// Policy returns the retry policy of the config; unset options take their
+// default.
+func (c *SessionRetryConfig) Policy() (SessionRetryPolicy, error) {
+	policy := DefaultSessionRetryPolicy
+	if c.Attempts < 0 {
+		return policy, fmt.Errorf("attempts cannot be negative")
+	}
+	if c.Attempts > 0 {
+		policy.Attempts = c.Attempts
+	}
+	if c.BaseDelay != "" {
+		delay, err := ParseDuration("base_delay", c.BaseDelay)
+		if err != nil {
+			return policy, err
+		}
+		policy.BaseDelay = delay
+	}
+	jitter, err := ParseDuration("jitter", c.Jitter)
+	if err != nil {
+		return policy, err
+	}
+	policy.Jitter = jitter
+	return policy, nil
+}
+
+// delay returns how long to wait after the given failed attempt (0-based).
+func (p SessionRetryPolicy) delay(attempt int) time.Duration {
+	delay := time.Duration(attempt+1) * p.BaseDelay
+	if p.Jitter > 0 {
+		delay += time.Duration(rand.Int63n(int64(p.Jitter)))
+	}
+	return delay
+}
+
+// OpenedSession is a session opened by OpenSession.
+type OpenedSession struct {
+	ID        string
+	CreatedAt time.Time
+
+	// Created is false if an existing session was reused
+	Created bool
+}
+
+// OpenSession creates the session on the daemon, or reuses it if it already
+// exists (e.g. created by a previous driver instance, or by another caller
+// racing this one), retrying per policy. Each attempt tries GetSession when
+// CreateSession fails, so a conflict resolves without waiting.
+func OpenSession(ctx context.Context, client DaemonClient, sessionID string, config *pb.SessionConfiguration, policy SessionRetryPolicy, logger hclog.Logger) (*OpenedSession, error) {
+	if client == nil {
+		return nil, errors.New("daemon client not initialized")
+	}
+	attempts := policy.Attempts
+	if attempts <= 0 {
+		attempts = 1
+	}
+
+	var lastErr error
+	for i := 0; i < attempts; i++ {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		createCtx, cancel := context.WithTimeout(ctx, sessionCreateTimeout)
+		resp, err := client.CreateSession(createCtx, sessionID, config)
+		cancel()
+		if err == nil && resp != nil {
+			logger.Info("created session", "session_id", resp.SessionId, "attempt", i+1)
+			return &OpenedSession{ID: resp.SessionId, CreatedAt: time.Now(), Created: true}, nil
+		}
+		if err != nil {
+			lastErr = err
+		}
+
+		getCtx, getCancel := context.WithTimeout(ctx, sessionGetTimeout)
+		getResp, getErr := client.GetSession(getCtx, sessionID)
+		getCancel()
+		if getErr == nil && getResp != nil {
+			logger.Info("reusing existing session", "session_id", getResp.SessionId)
+			return &OpenedSession{ID: getResp.SessionId, CreatedAt: time.Unix(getResp.CreatedAt, 0)}, nil
+		}
+		if status.Code(err) == codes.AlreadyExists {
+			// The session exists but could not be read back; report that
+			// rather than the conflict
+			lastErr = getErr
+		}
+
+		if i == attempts-1 {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(policy.delay(i)):
+		}
+	}
+
+	if lastErr != nil {
+		return nil, fmt.Errorf("failed to create session after %d attempts: %w", attempts, lastErr)
+	}
+	return nil, errors.New("failed to create or reuse session: unknown error")
+}
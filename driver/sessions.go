@@ -0,0 +1,228 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/nomad/plugins/drivers"
+	"github.com/hashicorp/nomad/plugins/shared/structs"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/elide-dev/elide-task-driver/proto/gen/go/elide/daemon/v1alpha1"
+)
+
+// sessionProfileAttributePrefix prefixes the node attributes advertising each
+// session profile, so jobs can constrain on the memory class they need.
+const sessionProfileAttributePrefix = "driver.elide.session_profile."
+
+// Validate checks the session profile configuration.
+func (p *SessionProfileConfig) Validate(name string) error {
+	if !isProfileName(name) {
+		return fmt.Errorf("name may only contain letters, digits, '-' and '_'")
+	}
+	if p.MemoryLimitMB <= 0 {
+		return fmt.Errorf("memory_limit_mb must be positive")
+	}
+	if p.ContextPoolSize < 0 {
+		return fmt.Errorf("context_pool_size cannot be negative")
+	}
+	return nil
+}
+
+// isProfileName reports whether name can be used in session IDs and node
+// attribute names.
+func isProfileName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// buildProfileSessionConfig returns the session configuration of a session
+// profile: session_config with the profile's resources.
+func (d *ElideDriverPlugin) buildProfileSessionConfig(name string, profile SessionProfileConfig) *pb.SessionConfiguration {
+	config := d.buildSessionConfig()
+	config.Tags[tagSessionProfile] = name
+	config.MemoryLimitMb = uint64(profile.MemoryLimitMB)
+	if profile.ContextPoolSize > 0 {
+		config.ContextPoolSize = uint32(profile.ContextPoolSize)
+	}
+	return config
+}
+
+// sessionFor returns the session a task runs in: the session of its alloc or
+// task when session_per says so, else the session of its session profile,
+// created on first use, or the default session if it selects none. The
+// default session must already exist.
+func (d *ElideDriverPlugin) sessionFor(ctx context.Context, cfg *drivers.TaskConfig, name string) (string, error) {
+	d.sessionLock.Lock()
+	defer d.sessionLock.Unlock()
+
+	if scope := d.sessionScope(cfg, name); scope != "" {
+		return d.scopedSessionFor(ctx, cfg, scope, name)
+	}
+	if name == "" {
+		return d.sessionID, nil
+	}
+
+	profile, ok := d.config.SessionProfiles[name]
+	if !ok {
+		return "", fmt.Errorf("unknown session profile %q", name)
+	}
+	if sessionID, ok := d.profileSessions[name]; ok {
+		return sessionID, nil
+	}
+
+	session, err := d.openSession(ctx, d.generateSessionID()+"-"+name, d.buildProfileSessionConfig(name, profile))
+	if err != nil {
+		return "", fmt.Errorf("session profile %q: %w", name, err)
+	}
+	if session.Created {
+		d.recordOtherSessionCreated()
+		go d.warmSession(session.ID)
+	}
+	d.profileSessions[name] = session.ID
+	return session.ID, nil
+}
+
+// adoptProfileSession records the session of a recovered task as its
+// profile's session, unless the profile already has one.
+func (d *ElideDriverPlugin) adoptProfileSession(name string, sessionID string) {
+	d.sessionLock.Lock()
+	defer d.sessionLock.Unlock()
+
+	if _, ok := d.profileSessions[name]; !ok {
+		d.profileSessions[name] = sessionID
+	}
+}
+
+// checkProfileSessions forgets profile sessions the daemon no longer reports
+// as active, so the next task using the profile re-creates its session.
+// Callers must hold sessionLock.
+func (d *ElideDriverPlugin) checkProfileSessions() {
+	for name, sessionID := range d.profileSessions {
+		ctx, cancel := context.WithTimeout(d.ctx, 3*time.Second)
+		resp, err := d.daemonClient.GetSession(ctx, sessionID)
+		cancel()
+		if lost, reason := sessionLost(sessionID, resp, err); lost {
+			err = reason
+			d.logger.Warn("profile session lost, it will be re-created", "session_profile", name, "session_id", sessionID, "reason", reason)
+			d.sessionLostForensics(sessionID, reason)
+			delete(d.profileSessions, name)
+		}
+		if err != nil {
+			d.recordSessionKeepaliveFailure(err)
+		}
+	}
+}
+
+// sessionLost reports whether a GetSession check found a session gone: closed
+// or errored, or unknown to the daemon as after a daemon restart. reason
+// describes the loss.
+func sessionLost(sessionID string, resp *pb.GetSessionResponse, err error) (lost bool, reason error) {
+	if err != nil {
+		return status.Code(err) == codes.NotFound, err
+	}
+	if resp.Status != pb.SessionStatus_SESSION_STATUS_ACTIVE {
+		return true, fmt.Errorf("session %s is %s", sessionID, resp.Status)
+	}
+	return false, nil
+}
+
+// sessionNotFoundError is a submission the daemon rejected as it does not
+// know the task's session, e.g. after a daemon restart the keepalive check
+// did not notice yet.
+type sessionNotFoundError struct {
+	sessionID string
+	err       error
+}
+
+func (e *sessionNotFoundError) Error() string { return e.err.Error() }
+func (e *sessionNotFoundError) Unwrap() error { return e.err }
+
+// sessionNotFound returns a sessionNotFoundError for a NotFound error of a
+// call in the session, and any other error unchanged.
+func sessionNotFound(sessionID string, err error) error {
+	if sessionID == "" || status.Code(err) != codes.NotFound {
+		return err
+	}
+	return &sessionNotFoundError{sessionID: sessionID, err: err}
+}
+
+// dropUnknownSession forgets a session a call found unknown to the daemon,
+// so that the next task using it re-creates it. The daemon reports more than
+// sessions not found, so the loss is confirmed with GetSession first. It
+// reports whether the session was forgotten.
+func (d *ElideDriverPlugin) dropUnknownSession(ctx context.Context, sessionID string) bool {
+	getCtx, cancel := d.withTimeout(ctx, d.statusTimeout())
+	resp, err := d.daemonClient.GetSession(getCtx, sessionID)
+	cancel()
+	lost, reason := sessionLost(sessionID, resp, err)
+	if !lost {
+		return false
+	}
+
+	d.sessionLock.Lock()
+	defer d.sessionLock.Unlock()
+
+	d.logger.Warn("session lost, it will be re-created", "session_id", sessionID, "reason", reason)
+	d.sessionLostForensics(sessionID, reason)
+	d.recordSessionError(reason)
+	if d.sessionID == sessionID {
+		d.sessionID = ""
+	}
+	for name, id := range d.profileSessions {
+		if id == sessionID {
+			delete(d.profileSessions, name)
+		}
+	}
+	for scope, id := range d.scoped.sessions {
+		if id == sessionID {
+			delete(d.scoped.sessions, scope)
+		}
+	}
+	d.restarts.forgetSession(sessionID)
+	return true
+}
+
+// deleteProfileSessions deletes the sessions of all session profiles, but
+// those in keep.
+func (d *ElideDriverPlugin) deleteProfileSessions(ctx context.Context, keep map[string]bool) {
+	d.sessionLock.Lock()
+	defer d.sessionLock.Unlock()
+
+	for name, sessionID := range d.profileSessions {
+		if keep[sessionID] {
+			continue
+		}
+		d.logger.Info("deleting session", "session_profile", name, "session_id", sessionID)
+		if err := d.daemonClient.DeleteSession(ctx, sessionID); err != nil {
+			d.logger.Warn("failed to delete session on shutdown", "error", err, "session_id", sessionID)
+			d.recordSessionError(err)
+			continue
+		}
+		d.recordOtherSessionDeleted()
+		delete(d.profileSessions, name)
+	}
+}
+
+// sessionProfileAttributes advertises the configured session profiles as node
+// attributes, e.g. driver.elide.session_profile.large = 4096 (its memory
+// limit in MB).
+func (d *ElideDriverPlugin) sessionProfileAttributes(fp *drivers.Fingerprint) {
+	for name, profile := range d.config.SessionProfiles {
+		fp.Attributes[sessionProfileAttributePrefix+name] = structs.NewIntAttribute(int64(profile.MemoryLimitMB), structs.UnitMiB)
+	}
+}
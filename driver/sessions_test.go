@@ -0,0 +1,94 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/nomad/plugins/drivers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/elide-dev/elide-task-driver/proto/gen/go/elide/daemon/v1alpha1"
+)
+
+// sessionForgettingClient is a daemon that lost the sessions its first
+// notFound submissions went to, as after a restart the keepalive check did
+// not notice yet. It records the sessions created and the submissions.
+type sessionForgettingClient struct {
+	sessionDeletingClient
+
+	notFound  int
+	lost      map[string]bool
+	created   []string
+	submitted []string
+}
+
+func (c *sessionForgettingClient) CreateSession(ctx context.Context, sessionID string, config *pb.SessionConfiguration) (*pb.CreateSessionResponse, error) {
+	c.created = append(c.created, sessionID)
+	delete(c.lost, sessionID)
+	return &pb.CreateSessionResponse{SessionId: sessionID, CreatedAt: time.Now().Unix()}, nil
+}
+
+func (c *sessionForgettingClient) GetSession(ctx context.Context, sessionID string) (*pb.GetSessionResponse, error) {
+	if c.lost[sessionID] {
+		return nil, status.Error(codes.NotFound, "session not found")
+	}
+	return &pb.GetSessionResponse{SessionId: sessionID, Status: pb.SessionStatus_SESSION_STATUS_ACTIVE}, nil
+}
+
+func (c *sessionForgettingClient) ExecuteSnippet(ctx context.Context, req ExecuteSnippetRequest) (*pb.ExecuteSnippetResponse, error) {
+	c.submitted = append(c.submitted, req.SessionID)
+	if len(c.submitted) <= c.notFound {
+		c.lost[req.SessionID] = true
+		return nil, status.Error(codes.NotFound, "session not found")
+	}
+	return &pb.ExecuteSnippetResponse{ExecutionId: req.ExecutionID, Status: pb.ExecutionStatus_EXECUTION_STATUS_RUNNING}, nil
+}
+
+func TestStartTask_RecreatesLostSession(t *testing.T) {
+	tests := []struct {
+		name     string
+		notFound int
+		err      bool
+	}{
+		{name: "resubmitted once", notFound: 1},
+		{name: "lost again", notFound: 2, err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewPlugin(hclog.NewNullLogger()).(*ElideDriverPlugin)
+			t.Cleanup(d.Shutdown)
+			client := &sessionForgettingClient{notFound: tt.notFound, lost: map[string]bool{}}
+			d.daemonClient = client
+			d.sessionID = "session-1"
+
+			cfg := &drivers.TaskConfig{ID: "task-1", JobID: "job", Namespace: "default", AllocDir: t.TempDir()}
+			require.NoError(t, cfg.EncodeConcreteDriverConfig(&TaskConfig{Language: "python", Code: "print(1)"}))
+			h, _, err := d.StartTask(cfg)
+
+			// The lost session is re-created and the task submitted there,
+			// once only
+			require.Len(t, client.created, 1)
+			assert.NotEqual(t, "session-1", client.created[0])
+			assert.Equal(t, []string{"session-1", client.created[0]}, client.submitted)
+			if tt.err {
+				assert.Equal(t, codes.NotFound, status.Code(err), "%v", err)
+				_, ok := d.tasks.Get("task-1")
+				assert.False(t, ok)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, client.created[0], d.sessionID)
+			state, err := DecodeTaskState(h.Version, h.DriverState)
+			require.NoError(t, err)
+			assert.Equal(t, client.created[0], state.SessionId)
+		})
+	}
+}
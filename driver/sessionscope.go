@@ -0,0 +1,193 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"time"
+
+	"github.com/hashicorp/nomad/plugins/drivers"
+)
+
+// Session granularity (session_per)
+const (
+	// sessionPerClient runs all tasks of the client in shared sessions
+	sessionPerClient = "client"
+
+	// sessionPerAlloc gives each alloc its own sessions
+	sessionPerAlloc = "alloc"
+
+	// sessionPerTask gives each task its own sessions
+	sessionPerTask = "task"
+)
+
+// scopedSessions tracks the sessions created per alloc or per task and the
+// tasks running in them, so a session is deleted once its last task is
+// destroyed. It is guarded by the driver's sessionLock.
+type scopedSessions struct {
+	// sessions maps scopes to their session IDs
+	sessions map[string]string
+
+	// tasks maps task IDs to the scope of their session
+	tasks map[string]string
+}
+
+func newScopedSessions() *scopedSessions {
+	return &scopedSessions{sessions: map[string]string{}, tasks: map[string]string{}}
+}
+
+// users returns the number of tasks using the session of a scope.
+func (s *scopedSessions) users(scope string) int {
+	n := 0
+	for _, taskScope := range s.tasks {
+		if taskScope == scope {
+			n++
+		}
+	}
+	return n
+}
+
+// sessionPer returns the configured session granularity.
+func (d *ElideDriverPlugin) sessionPer() string {
+	if d.config.SessionPer == "" {
+		return sessionPerClient
+	}
+	return d.config.SessionPer
+}
+
+// sessionScope returns the scope of the session a task runs in when sessions
+// are per alloc or per task: the alloc ID, plus the task name and session
+// profile if any. It is empty when sessions are per client.
+func (d *ElideDriverPlugin) sessionScope(cfg *drivers.TaskConfig, profile string) string {
+	var scope string
+	switch d.sessionPer() {
+	case sessionPerAlloc:
+		scope = cfg.AllocID
+	case sessionPerTask:
+		scope = cfg.AllocID + "-" + cfg.Name
+	default:
+		return ""
+	}
+	if profile != "" {
+		scope += "-" + profile
+	}
+	return scope
+}
+
+// scopedSessionFor returns the session of a scope, creating it on first use,
+// and records the task as one of its users. The session is configured like the
+// default session, or like the task's session profile, and tagged with the
+// task's alloc. Callers must hold sessionLock.
+func (d *ElideDriverPlugin) scopedSessionFor(ctx context.Context, cfg *drivers.TaskConfig, scope string, profile string) (string, error) {
+	if sessionID, ok := d.scoped.sessions[scope]; ok {
+		d.scoped.tasks[cfg.ID] = scope
+		return sessionID, nil
+	}
+
+	sessionConfig := d.buildSessionConfig()
+	if profile != "" {
+		sessionConfig = d.buildProfileSessionConfig(profile, d.config.SessionProfiles[profile])
+	}
+	maps.Copy(sessionConfig.Tags, executionTags(cfg))
+
+	session, err := d.openSession(ctx, d.generateSessionID()+"-"+scope, sessionConfig)
+	if err != nil {
+		return "", fmt.Errorf("session for %s %q: %w", d.sessionPer(), scope, err)
+	}
+	if session.Created {
+		d.recordOtherSessionCreated()
+		go d.warmSession(session.ID)
+	}
+	d.scoped.sessions[scope] = session.ID
+	d.scoped.tasks[cfg.ID] = scope
+	return session.ID, nil
+}
+
+// adoptScopedSession records the session of a recovered task as its scope's
+// session, unless the scope already has one.
+func (d *ElideDriverPlugin) adoptScopedSession(scope string, sessionID string, taskID string) {
+	d.sessionLock.Lock()
+	defer d.sessionLock.Unlock()
+
+	if _, ok := d.scoped.sessions[scope]; !ok {
+		d.scoped.sessions[scope] = sessionID
+	}
+	d.scoped.tasks[taskID] = scope
+}
+
+// releaseScopedSession forgets a task's use of its scope's session and
+// deletes the session once no task uses it. Tasks in shared sessions are
+// ignored.
+func (d *ElideDriverPlugin) releaseScopedSession(taskID string) {
+	d.sessionLock.Lock()
+	defer d.sessionLock.Unlock()
+
+	scope, ok := d.scoped.tasks[taskID]
+	if !ok {
+		return
+	}
+	delete(d.scoped.tasks, taskID)
+	sessionID, ok := d.scoped.sessions[scope]
+	if !ok || d.scoped.users(scope) > 0 {
+		return
+	}
+	delete(d.scoped.sessions, scope)
+	d.restarts.forgetSession(sessionID)
+	if d.daemonClient == nil {
+		return
+	}
+
+	ctx, cancel := d.withTimeout(d.ctx, d.statusTimeout())
+	defer cancel()
+	if err := d.daemonClient.DeleteSession(ctx, sessionID); err != nil {
+		d.logger.Warn("failed to delete session of finished tasks", "session_id", sessionID, "error", err)
+		d.recordSessionError(err)
+		return
+	}
+	d.logger.Info("deleted session of finished tasks", "session_id", sessionID)
+	d.recordOtherSessionDeleted()
+}
+
+// checkScopedSessions forgets per-alloc and per-task sessions the daemon no
+// longer reports as active, so the next task of the scope re-creates its
+// session. Callers must hold sessionLock.
+func (d *ElideDriverPlugin) checkScopedSessions() {
+	for scope, sessionID := range d.scoped.sessions {
+		ctx, cancel := context.WithTimeout(d.ctx, 3*time.Second)
+		resp, err := d.daemonClient.GetSession(ctx, sessionID)
+		cancel()
+		if lost, reason := sessionLost(sessionID, resp, err); lost {
+			err = reason
+			d.logger.Warn("session lost, it will be re-created", "session_id", sessionID, "reason", reason)
+			d.sessionLostForensics(sessionID, reason)
+			delete(d.scoped.sessions, scope)
+		}
+		if err != nil {
+			d.recordSessionKeepaliveFailure(err)
+		}
+	}
+}
+
+// deleteScopedSessions deletes all per-alloc and per-task sessions, but those
+// in keep.
+func (d *ElideDriverPlugin) deleteScopedSessions(ctx context.Context, keep map[string]bool) {
+	d.sessionLock.Lock()
+	defer d.sessionLock.Unlock()
+
+	for scope, sessionID := range d.scoped.sessions {
+		if keep[sessionID] {
+			continue
+		}
+		d.logger.Info("deleting session", "session_id", sessionID)
+		if err := d.daemonClient.DeleteSession(ctx, sessionID); err != nil {
+			d.logger.Warn("failed to delete session on shutdown", "error", err, "session_id", sessionID)
+			d.recordSessionError(err)
+			continue
+		}
+		d.recordOtherSessionDeleted()
+		delete(d.scoped.sessions, scope)
+	}
+}
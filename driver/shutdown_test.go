@@ -0,0 +1,65 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/nomad/plugins/drivers"
+	"github.com/stretchr/testify/assert"
+)
+
+// sessionDeletingClient records the sessions deleted through it.
+type sessionDeletingClient struct {
+	DaemonClient
+
+	mu      sync.Mutex
+	deleted []string
+}
+
+func (c *sessionDeletingClient) DeleteSession(ctx context.Context, sessionID string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.deleted = append(c.deleted, sessionID)
+	return nil
+}
+
+func (c *sessionDeletingClient) Close() error {
+	return nil
+}
+
+// newShutdownPlugin returns a driver with a live task in the default
+// session, and an idle session profile session.
+func newShutdownPlugin(client DaemonClient) *ElideDriverPlugin {
+	d := NewPlugin(hclog.NewNullLogger()).(*ElideDriverPlugin)
+	d.daemonClient = client
+	d.sessionID = "session-live"
+	d.profileSessions["large"] = "session-idle"
+	d.tasks.Set("task-1", &taskHandle{
+		taskConfig: &drivers.TaskConfig{ID: "task-1"},
+		sessionId:  "session-live",
+		status:     "running",
+	})
+	return d
+}
+
+func TestShutdown_DeletesAllSessions(t *testing.T) {
+	client := &sessionDeletingClient{}
+	newShutdownPlugin(client).Shutdown()
+	assert.ElementsMatch(t, []string{"session-live", "session-idle"}, client.deleted)
+}
+
+func TestDetach_KeepsSessionsOfLiveTasks(t *testing.T) {
+	client := &sessionDeletingClient{}
+	d := newShutdownPlugin(client)
+	d.Detach()
+	assert.Equal(t, []string{"session-idle"}, client.deleted, "the next plugin instance recovers the live task")
+
+	// Detaching is a shutdown: a later Shutdown is a no-op
+	d.Shutdown()
+	assert.Equal(t, []string{"session-idle"}, client.deleted)
+}
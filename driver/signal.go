@@ -0,0 +1,177 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/nomad/plugins/drivers"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// maxNamedSignalLength bounds the length of a named application signal
+const maxNamedSignalLength = 64
+
+const (
+	// defaultKillSignal is sent to stop a task whose kill_signal is unset,
+	// as with Nomad's other drivers
+	defaultKillSignal = "SIGINT"
+
+	// stopCheckInterval is how often StopTask checks whether a signalled
+	// task has exited
+	stopCheckInterval = 100 * time.Millisecond
+)
+
+// posixSignals are the signal names forwarded to executions as POSIX
+// signals; any other name is delivered as a named application signal.
+var posixSignals = map[string]bool{
+	"SIGHUP":   true,
+	"SIGINT":   true,
+	"SIGQUIT":  true,
+	"SIGKILL":  true,
+	"SIGTERM":  true,
+	"SIGUSR1":  true,
+	"SIGUSR2":  true,
+	"SIGCONT":  true,
+	"SIGSTOP":  true,
+	"SIGTSTP":  true,
+	"SIGWINCH": true,
+}
+
+// ParseSignal classifies a signal sent to a task. Upper-case POSIX signal
+// names, with or without the SIG prefix, are returned in their canonical form;
+// anything else is a named application signal such as "reload-config", made
+// of letters, digits, '-', '_' and '.'.
+func ParseSignal(signal string) (name string, named bool, err error) {
+	signal = strings.TrimSpace(signal)
+	if signal == "" {
+		return "", false, errors.New("signal name is empty")
+	}
+
+	if posixSignals[signal] {
+		return signal, false, nil
+	}
+	if posixSignals["SIG"+signal] {
+		return "SIG" + signal, false, nil
+	}
+
+	if len(signal) > maxNamedSignalLength {
+		return "", false, fmt.Errorf("signal name %q is longer than %d characters", signal, maxNamedSignalLength)
+	}
+	for _, r := range signal {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+		default:
+			return "", false, fmt.Errorf("invalid signal name %q: only letters, digits, '-', '_' and '.' are allowed", signal)
+		}
+	}
+	return signal, true, nil
+}
+
+// Results of task stops, for metricTaskStops
+const (
+	stopResultExited    = "exited"
+	stopResultCancelled = "cancelled"
+)
+
+// metricTaskStops counts tasks stopped by Nomad, by whether they exited
+// within the kill timeout or had to be cancelled
+const metricTaskStops = "task_stops_total"
+
+// stopWithSignal sends the kill signal to a task's executions and waits up to
+// the timeout for the task to complete. It returns nil if the task exited, or
+// why it must be cancelled, e.g. because the daemon cannot signal executions
+// or they ignored the signal.
+func (d *ElideDriverPlugin) stopWithSignal(handle *taskHandle, timeout time.Duration, signal string) error {
+	if timeout <= 0 {
+		return errors.New("kill_timeout is zero")
+	}
+	if signal == "" {
+		signal = defaultKillSignal
+	}
+	if err := d.SignalTask(handle.taskConfig.ID, signal); err != nil {
+		handle.logger.Debug("failed to send kill signal, cancelling execution", "signal", signal, "error", err)
+		return fmt.Errorf("failed to send %s: %w", signal, err)
+	}
+
+	d.eventer.EmitEvent(&drivers.TaskEvent{
+		TaskID:    handle.taskConfig.ID,
+		AllocID:   handle.taskConfig.AllocID,
+		TaskName:  handle.taskConfig.Name,
+		Timestamp: time.Now(),
+		Message:   fmt.Sprintf("Sent %s, waiting up to %s for the task to exit", signal, timeout),
+		Annotations: map[string]string{
+			"kill_signal":  signal,
+			"kill_timeout": timeout.String(),
+		},
+	})
+
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	ticker := time.NewTicker(stopCheckInterval)
+	defer ticker.Stop()
+	for handle.IsRunning() {
+		select {
+		case <-deadline.C:
+			handle.logger.Debug("execution did not exit after kill signal, cancelling it", "signal", signal, "timeout", timeout)
+			return fmt.Errorf("still running %s after %s", timeout, signal)
+		case <-d.ctx.Done():
+			return errors.New("driver shutting down")
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+// SignalTask forwards a signal to a task's running executions. Signals Nomad
+// knows as POSIX signals are delivered as such; other names (e.g. from
+// `nomad alloc signal -s reload-config`) are delivered as named application
+// signals.
+func (d *ElideDriverPlugin) SignalTask(taskID string, signal string) error {
+	handle, ok := d.tasks.Get(taskID)
+	if !ok {
+		return drivers.ErrTaskNotFound
+	}
+	if handle.queue != nil {
+		return errTaskQueued
+	}
+
+	name, named, err := ParseSignal(signal)
+	if err != nil {
+		return err
+	}
+	if d.daemonClient == nil {
+		return errors.New("daemon client not initialized")
+	}
+	if !d.supportsSignals.Load() {
+		return errors.New("daemon does not support signalling executions")
+	}
+
+	ctx, cancel := d.withTimeout(d.ctx, d.statusTimeout())
+	defer cancel()
+
+	delivered := 0
+	for _, executionID := range handle.executionIDs() {
+		ok, err := d.daemonClient.SignalExecution(ctx, handle.sessionId, executionID, name, named)
+		if status.Code(err) == codes.Unimplemented {
+			return fmt.Errorf("daemon does not support signalling executions")
+		}
+		if err != nil {
+			return err
+		}
+		if ok {
+			delivered++
+		}
+	}
+	if delivered == 0 {
+		return fmt.Errorf("signal %s not delivered: no running execution", name)
+	}
+
+	handle.logger.Debug("signalled execution", "signal", name, "named", named, "executions", delivered)
+	return nil
+}
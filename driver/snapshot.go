@@ -0,0 +1,150 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+const (
+	// snapshotVersion is the version of the snapshot format written by this
+	// plugin
+	snapshotVersion = 1
+)
+
+// Snapshot is an export of the driver's in-memory state. It allows a newly
+// started plugin instance to adopt the tasks and session of a previous one,
+// e.g. during a blue-green plugin rollout.
+type Snapshot struct {
+	Version   int         `json:"version"`
+	CreatedAt time.Time   `json:"created_at"`
+	SessionID string      `json:"session_id"`
+	Tasks     []TaskState `json:"tasks"`
+}
+
+// Snapshot returns the current task store and session state.
+func (d *ElideDriverPlugin) Snapshot() *Snapshot {
+	d.sessionLock.Lock()
+	sessionID := d.sessionID
+	d.sessionLock.Unlock()
+
+	snap := &Snapshot{
+		Version:   snapshotVersion,
+		CreatedAt: time.Now(),
+		SessionID: sessionID,
+		Tasks:     []TaskState{},
+	}
+
+	for _, h := range d.tasks.List() {
+		h.stateLock.RLock()
+		snap.Tasks = append(snap.Tasks, *h.taskState())
+		h.stateLock.RUnlock()
+	}
+
+	return snap
+}
+
+// RestoreSnapshot adopts the session and tasks from a snapshot. Tasks that are
+// already known are left untouched. It returns the number of restored tasks;
+// tasks the daemon no longer knows about are skipped and reported in the error.
+func (d *ElideDriverPlugin) RestoreSnapshot(snap *Snapshot) (int, error) {
+	if snap == nil {
+		return 0, fmt.Errorf("snapshot cannot be nil")
+	}
+	if snap.Version != snapshotVersion {
+		return 0, fmt.Errorf("unsupported snapshot version %d (expected %d)", snap.Version, snapshotVersion)
+	}
+
+	if snap.SessionID != "" {
+		if err := d.adoptSession(snap.SessionID); err != nil {
+			return 0, err
+		}
+	}
+
+	restored := 0
+	var failed []string
+	for i := range snap.Tasks {
+		taskState := snap.Tasks[i]
+		if taskState.TaskConfig == nil {
+			continue
+		}
+		if _, ok := d.tasks.Get(taskState.TaskConfig.ID); ok {
+			continue
+		}
+		restore := d.recoverTaskState
+		if taskState.Queued {
+			restore = d.recoverQueuedTask
+		}
+		if err := restore(&taskState); err != nil {
+			d.logger.Warn("failed to restore task from snapshot", "task_id", taskState.TaskConfig.ID, "error", err)
+			failed = append(failed, taskState.TaskConfig.ID)
+			continue
+		}
+		restored++
+	}
+
+	d.logger.Info("restored snapshot", "session_id", snap.SessionID, "tasks", restored, "failed", len(failed))
+	if len(failed) > 0 {
+		return restored, fmt.Errorf("failed to restore %d task(s): %v", len(failed), failed)
+	}
+	return restored, nil
+}
+
+// adoptSession switches the driver to an existing daemon session, provided
+// the driver has not created its own session yet.
+func (d *ElideDriverPlugin) adoptSession(sessionID string) error {
+	if d.daemonClient == nil {
+		return fmt.Errorf("daemon client not initialized")
+	}
+
+	d.sessionLock.Lock()
+	defer d.sessionLock.Unlock()
+
+	if d.sessionID == sessionID {
+		return nil
+	}
+	if d.sessionID != "" && d.tasks.Len() > 0 {
+		return fmt.Errorf("cannot adopt session %q: session %q already has tasks", sessionID, d.sessionID)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	resp, err := d.daemonClient.GetSession(ctx, sessionID)
+	if err != nil {
+		return fmt.Errorf("failed to adopt session %q: %w", sessionID, err)
+	}
+
+	d.sessionID = sessionID
+	d.recordSessionAdopted(sessionID, time.Unix(resp.CreatedAt, 0))
+	return nil
+}
+
+// WriteSnapshotFile writes a snapshot as JSON to the given path.
+func WriteSnapshotFile(path string, snap *Snapshot) error {
+	data, err := json.MarshalIndent(snap, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode snapshot: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	return nil
+}
+
+// ReadSnapshotFile reads a JSON snapshot from the given path.
+func ReadSnapshotFile(path string) (*Snapshot, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot: %w", err)
+	}
+	var snap Snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return nil, fmt.Errorf("failed to decode snapshot: %w", err)
+	}
+	return &snap, nil
+}
@@ -0,0 +1,106 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/nomad/plugins/drivers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fullTaskState returns a task state with every field but Queued set.
+func fullTaskState() *TaskState {
+	return &TaskState{
+		TaskConfig:  &drivers.TaskConfig{ID: "task-1", AllocID: "alloc-1", Name: "web"},
+		StartedAt:   time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		ExecutionId: "exec-1",
+		SessionId:   "session-1",
+		TraceParent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+
+		Language:       "python",
+		Profile:        "strict",
+		SessionProfile: "analytics",
+		SessionScope:   "alloc-1",
+		Exports:        []string{"count"},
+		OutputMode:     outputModeBoth,
+		BinaryOutput:   binaryOutputBase64,
+		CombineOutput:  true,
+		CodeHash:       "abc123",
+		Matrix: []*MatrixEntry{
+			{Index: 0, Args: []string{"a"}, ExecutionId: "exec-1"},
+			{Index: 1, Args: []string{"b"}, ExecutionId: "exec-2", Complete: true, Status: "completed", ExitCode: 1},
+		},
+		MatrixPolicy: "any",
+		Deadline:     time.Date(2026, 1, 2, 4, 4, 5, 0, time.UTC),
+		PollInterval: 250 * time.Millisecond,
+
+		ResultSchema:       `{"type": "object", "required": ["count"]}`,
+		ResultSchemaPolicy: "warn",
+
+		ScratchDir:     "/tmp/scratch/task-1",
+		ScratchQuotaMB: 64,
+
+		MemoryReservationMB: 512,
+	}
+}
+
+func TestTaskState_SnapshotRoundTrip(t *testing.T) {
+	state := fullTaskState()
+	value := reflect.ValueOf(state).Elem()
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		if field.Name == "Queued" {
+			continue
+		}
+		require.False(t, value.Field(i).IsZero(), "fullTaskState must set %s", field.Name)
+	}
+
+	d := NewPlugin(hclog.NewNullLogger()).(*ElideDriverPlugin)
+	d.tasks.Set(state.TaskConfig.ID, d.recoveredHandle(state))
+
+	snap := d.Snapshot()
+	require.Len(t, snap.Tasks, 1)
+	assert.Equal(t, *state, snap.Tasks[0])
+}
+
+func TestTaskState_SnapshotQueuedTask(t *testing.T) {
+	d := NewPlugin(hclog.NewNullLogger()).(*ElideDriverPlugin)
+	d.config.StateDir = t.TempDir()
+
+	cfg := &drivers.TaskConfig{ID: "task-1", AllocID: "alloc-1", Name: "web"}
+	entry := &queueEntry{
+		TaskID:   cfg.ID,
+		AllocID:  cfg.AllocID,
+		TaskName: cfg.Name,
+		CodeHash: "abc123",
+		QueuedAt: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		Failed:   "daemon did not return within 5m0s",
+	}
+	require.NoError(t, d.writeQueueEntry(entry))
+	d.tasks.Set(cfg.ID, d.queuedHandle(cfg, entry))
+
+	snap := d.Snapshot()
+	require.Len(t, snap.Tasks, 1)
+	assert.True(t, snap.Tasks[0].Queued)
+	assert.Equal(t, entry.CodeHash, snap.Tasks[0].CodeHash)
+	assert.Equal(t, entry.QueuedAt, snap.Tasks[0].StartedAt)
+
+	// A queued task has no execution yet; it is restored from its queue
+	// entry rather than the daemon's status of an execution
+	restorer := NewPlugin(hclog.NewNullLogger()).(*ElideDriverPlugin)
+	restorer.config.StateDir = d.config.StateDir
+	restored, err := restorer.RestoreSnapshot(snap)
+	require.NoError(t, err)
+	assert.Equal(t, 1, restored)
+
+	h, ok := restorer.tasks.Get(cfg.ID)
+	require.True(t, ok)
+	assert.NotNil(t, h.queue)
+	assert.ErrorContains(t, h.TaskStatus().ExitResult.Err, entry.Failed)
+}
@@ -20,6 +20,68 @@ type TaskState struct {
 	// Execution tracking
 	ExecutionId string // Execution ID from Elide daemon (for recovery)
 	SessionId   string // Session ID (for recovery)
+
+	// W3C traceparent of the task's StartTask span, so spans of the
+	// recovered task join its trace (empty without tracing)
+	TraceParent string
+
+	// Language the execution runs (empty in states written before it was
+	// recorded)
+	Language string
+
+	// Sandbox profile the execution runs under (empty if none)
+	Profile string
+
+	// Session profile whose session runs the execution (empty for the
+	// default session)
+	SessionProfile string
+
+	// Scope of the per-alloc or per-task session running the execution
+	// (empty for shared sessions)
+	SessionScope string
+
+	// SHA-256 of the code the execution was submitted with, so restarts of
+	// the task can detect code drift
+	CodeHash string
+
+	// Session KV keys set from the result on completion
+	Exports []string
+
+	// Where output is shipped on completion (output_mode)
+	OutputMode string
+
+	// How log streams ship binary output (binary_output)
+	BinaryOutput string
+
+	// Whether stdout and stderr are interleaved in the stdout log
+	// (combine_output, if the daemon reports the order of output)
+	CombineOutput bool
+
+	// Executions of an args_matrix task and how their exit codes aggregate
+	// (Matrix is nil for single-execution tasks)
+	Matrix       []*MatrixEntry
+	MatrixPolicy string
+
+	// Driver-enforced execution deadline and polling interval
+	Deadline     time.Time
+	PollInterval time.Duration
+
+	// result_schema the result is validated against, as compiled, and
+	// result_schema_policy
+	ResultSchema       string
+	ResultSchemaPolicy string
+
+	// Scratch directory (for quota enforcement and cleanup after recovery)
+	ScratchDir     string
+	ScratchQuotaMB int
+
+	// Memory each execution reserves in its session, in MB (zero in states
+	// written before reservations were tracked)
+	MemoryReservationMB int
+
+	// Queued is set for a task queued while the daemon was unreachable; its
+	// execution, once submitted, is recorded in the queue entry in state_dir
+	Queued bool
 }
 
 // taskStore provides a mechanism to store and retrieve task handles
@@ -52,3 +114,19 @@ func (ts *taskStore) Delete(id string) {
 	delete(ts.store, id)
 }
 
+func (ts *taskStore) List() []*taskHandle {
+	ts.lock.RLock()
+	defer ts.lock.RUnlock()
+	handles := make([]*taskHandle, 0, len(ts.store))
+	for _, h := range ts.store {
+		handles = append(handles, h)
+	}
+	return handles
+}
+
+func (ts *taskStore) Len() int {
+	ts.lock.RLock()
+	defer ts.lock.RUnlock()
+	return len(ts.store)
+}
+
@@ -0,0 +1,81 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/hashicorp/nomad/plugins/base"
+)
+
+// taskStateVersion is the schema version of the TaskState written into task
+// handles. Bump it, and register a migration from the previous version in
+// taskStateMigrations, whenever a change to TaskState would make older
+// handles decode wrongly (a renamed field, a changed type or meaning).
+//
+// Version 1 is the bare msgpack TaskState written before handles carried a
+// version envelope (task handle version 1).
+const taskStateVersion = 2
+
+// legacyTaskHandleVersion is the task handle version whose driver state is a
+// bare version 1 TaskState, without an envelope
+const legacyTaskHandleVersion = 1
+
+// taskStateMigrations upgrade a decoded TaskState from the version it is keyed
+// by to the next version. Decoding applies them in order up to
+// taskStateVersion.
+var taskStateMigrations = map[byte]func(*TaskState) error{
+	1: migrateTaskStateV1,
+}
+
+// EncodeTaskState encodes a task state for a task handle: the schema version
+// byte followed by the msgpack-encoded state.
+func EncodeTaskState(state *TaskState) ([]byte, error) {
+	var payload []byte
+	if err := base.MsgPackEncode(&payload, state); err != nil {
+		return nil, err
+	}
+	return append([]byte{taskStateVersion}, payload...), nil
+}
+
+// DecodeTaskState decodes the driver state of a task handle of the given
+// handle version, migrating states written by older driver versions.
+func DecodeTaskState(handleVersion int, data []byte) (*TaskState, error) {
+	version := byte(1)
+	payload := data
+	if handleVersion > legacyTaskHandleVersion {
+		if len(data) == 0 {
+			return nil, errors.New("empty task state")
+		}
+		version, payload = data[0], data[1:]
+	}
+	if version == 0 || version > taskStateVersion {
+		return nil, fmt.Errorf("unsupported task state version %d (this driver supports up to %d)", version, taskStateVersion)
+	}
+
+	var state TaskState
+	if err := base.MsgPackDecode(payload, &state); err != nil {
+		return nil, fmt.Errorf("failed to decode version %d task state: %w", version, err)
+	}
+	for ; version < taskStateVersion; version++ {
+		if err := taskStateMigrations[version](&state); err != nil {
+			return nil, fmt.Errorf("failed to migrate task state from version %d: %w", version, err)
+		}
+	}
+	return &state, nil
+}
+
+// migrateTaskStateV1 upgrades a bare, unversioned task state. Fields added
+// over its lifetime decode as zero values; only those whose zero value is
+// not the default need filling in.
+func migrateTaskStateV1(state *TaskState) error {
+	if state.TaskConfig == nil {
+		return errors.New("task state has no task config")
+	}
+	if state.OutputMode == "" {
+		state.OutputMode = outputModeLog
+	}
+	return nil
+}
@@ -0,0 +1,440 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/elide-dev/elide-task-driver/proto/gen/go/elide/daemon/v1alpha1"
+)
+
+// Values of orphan_policy
+const (
+	// orphanPolicyCancel cancels orphaned executions
+	orphanPolicyCancel = "cancel"
+
+	// orphanPolicyAdopt leaves orphaned executions running to completion,
+	// holding their execution slots
+	orphanPolicyAdopt = "adopt"
+)
+
+const (
+	// stateFileName is the file of state_dir recording the driver's sessions
+	// and executions
+	stateFileName = "driver-state.json"
+
+	// defaultOrphanGrace is how long after startup Nomad has to recover
+	// tasks before their executions are orphans, if orphan_grace is unset
+	defaultOrphanGrace = time.Minute
+
+	// workerOrphanReconciler names the worker reconciling orphans
+	workerOrphanReconciler = "orphan_reconciler"
+
+	// metricOrphanedTasks counts tasks Nomad did not recover whose
+	// executions were reconciled, by result
+	metricOrphanedTasks = "orphaned_tasks_total"
+)
+
+// ExecutionRecord is an execution recorded in the state store.
+type ExecutionRecord struct {
+	TaskID       string    `json:"task_id"`
+	AllocID      string    `json:"alloc_id"`
+	TaskName     string    `json:"task_name"`
+	SessionID    string    `json:"session_id"`
+	ExecutionIDs []string  `json:"execution_ids"`
+	StartedAt    time.Time `json:"started_at"`
+}
+
+// persistedState is the content of the state file.
+type persistedState struct {
+	// Session is the driver's default session
+	Session string `json:"session,omitempty"`
+	// Executions are the executions of tasks not yet destroyed, by task ID
+	Executions map[string]*ExecutionRecord `json:"executions"`
+}
+
+// StateStore records the driver's default session and the executions of its
+// tasks in state_dir, so that a plugin restarted after a crash can find the
+// executions of tasks Nomad never asks it to recover. Every change rewrites
+// the file atomically.
+type StateStore struct {
+	mu    sync.Mutex
+	path  string
+	state persistedState
+
+	// loaded is the state found when the store was opened
+	loaded persistedState
+}
+
+// OpenStateStore opens the state store in dir, loading the state a previous
+// plugin instance left.
+func OpenStateStore(dir string) (*StateStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create state directory: %w", err)
+	}
+	s := &StateStore{path: filepath.Join(dir, stateFileName)}
+	s.state.Executions = map[string]*ExecutionRecord{}
+
+	data, err := os.ReadFile(s.path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, fmt.Errorf("failed to read state file: %w", err)
+	default:
+		if err := json.Unmarshal(data, &s.state); err != nil {
+			return nil, fmt.Errorf("failed to decode state file %s: %w", s.path, err)
+		}
+		if s.state.Executions == nil {
+			s.state.Executions = map[string]*ExecutionRecord{}
+		}
+	}
+
+	s.loaded.Session = s.state.Session
+	s.loaded.Executions = make(map[string]*ExecutionRecord, len(s.state.Executions))
+	for taskID, record := range s.state.Executions {
+		s.loaded.Executions[taskID] = record
+	}
+	return s, nil
+}
+
+// Loaded returns the default session and the executions recorded when the
+// store was opened.
+func (s *StateStore) Loaded() (session string, executions []*ExecutionRecord) {
+	for _, record := range s.loaded.Executions {
+		executions = append(executions, record)
+	}
+	return s.loaded.Session, executions
+}
+
+// Executions returns the executions currently recorded.
+func (s *StateStore) Executions() []*ExecutionRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	records := make([]*ExecutionRecord, 0, len(s.state.Executions))
+	for _, record := range s.state.Executions {
+		records = append(records, record)
+	}
+	return records
+}
+
+// SetSession records the driver's default session.
+func (s *StateStore) SetSession(sessionID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.state.Session == sessionID {
+		return nil
+	}
+	s.state.Session = sessionID
+	return s.writeLocked()
+}
+
+// PutExecution records the executions of a task, replacing its previous
+// record.
+func (s *StateStore) PutExecution(record *ExecutionRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state.Executions[record.TaskID] = record
+	return s.writeLocked()
+}
+
+// RemoveExecution forgets the executions of a task.
+func (s *StateStore) RemoveExecution(taskID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.state.Executions[taskID]; !ok {
+		return nil
+	}
+	delete(s.state.Executions, taskID)
+	return s.writeLocked()
+}
+
+// writeLocked replaces the state file atomically. Callers must hold mu.
+func (s *StateStore) writeLocked() error {
+	data, err := json.MarshalIndent(&s.state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode state: %w", err)
+	}
+	if err := os.WriteFile(s.path+".tmp", data, 0600); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	if err := os.Rename(s.path+".tmp", s.path); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	return nil
+}
+
+// validateOrphanPolicy checks orphan_policy and orphan_grace.
+func (c *Config) validateOrphanPolicy() error {
+	switch c.OrphanPolicy {
+	case "", orphanPolicyCancel, orphanPolicyAdopt:
+	default:
+		return fmt.Errorf("invalid orphan_policy %q (must be %q or %q)", c.OrphanPolicy, orphanPolicyCancel, orphanPolicyAdopt)
+	}
+	grace, err := ParseDuration("orphan_grace", c.OrphanGrace)
+	if err != nil {
+		return err
+	}
+	if c.OrphanGrace != "" && grace == 0 {
+		return fmt.Errorf("invalid orphan_grace %q: must be positive", c.OrphanGrace)
+	}
+	return nil
+}
+
+// openStateStore opens the state store if state_dir is set. A store that
+// cannot be opened is logged and left out, as the driver works without it.
+func (d *ElideDriverPlugin) openStateStore() {
+	if d.config.StateDir == "" || d.state != nil {
+		return
+	}
+	store, err := OpenStateStore(d.config.StateDir)
+	if err != nil {
+		d.logger.Error("failed to open state store, orphaned executions will not be reconciled", "error", err)
+		return
+	}
+	d.state = store
+}
+
+// recordExecutions records the executions of a started or recovered task.
+func (d *ElideDriverPlugin) recordExecutions(h *taskHandle) {
+	if d.state == nil {
+		return
+	}
+	h.stateLock.RLock()
+	startedAt := h.startedAt
+	h.stateLock.RUnlock()
+	err := d.state.PutExecution(&ExecutionRecord{
+		TaskID:       h.taskConfig.ID,
+		AllocID:      h.taskConfig.AllocID,
+		TaskName:     h.taskConfig.Name,
+		SessionID:    h.sessionId,
+		ExecutionIDs: h.executionIDs(),
+		StartedAt:    startedAt,
+	})
+	if err != nil {
+		h.logger.Warn("failed to record execution, a crashed driver will not reconcile it", "error", err)
+	}
+}
+
+// forgetExecutions forgets the executions of a destroyed task.
+func (d *ElideDriverPlugin) forgetExecutions(taskID string) {
+	if d.state == nil {
+		return
+	}
+	if err := d.state.RemoveExecution(taskID); err != nil {
+		d.logger.Warn("failed to forget execution", "task_id", taskID, "error", err)
+	}
+}
+
+// recordSession records the driver's default session.
+func (d *ElideDriverPlugin) recordSession(sessionID string) {
+	if d.state == nil {
+		return
+	}
+	if err := d.state.SetSession(sessionID); err != nil {
+		d.logger.Warn("failed to record session", "session_id", sessionID, "error", err)
+	}
+}
+
+// reconcileOrphans reconciles the executions recorded by a previous plugin
+// instance with the daemon, once Nomad had orphan_grace to recover its
+// tasks. Executions of tasks Nomad did not recover are orphans: they are
+// cancelled, or adopted as orphan_policy says. A previous default session
+// left without executions is retired. Orphans whose status cannot be fetched
+// are retried every poll interval.
+func (d *ElideDriverPlugin) reconcileOrphans() {
+	select {
+	case <-d.ctx.Done():
+		return
+	case <-time.After(durationOr(defaultOrphanGrace, d.config.OrphanGrace)):
+	}
+	if err := d.reconnect.wait(d.ctx); err != nil {
+		return
+	}
+
+	// Records of tasks destroyed since are gone from the store
+	current := map[string]bool{}
+	for _, record := range d.state.Executions() {
+		current[record.TaskID] = true
+	}
+
+	session, records := d.state.Loaded()
+	inUse := map[string]bool{}
+	var orphans []*ExecutionRecord
+	for _, record := range records {
+		if !current[record.TaskID] {
+			continue
+		}
+		if _, ok := d.tasks.Get(record.TaskID); ok {
+			inUse[record.SessionID] = true
+			continue
+		}
+		orphans = append(orphans, record)
+	}
+
+	// Orphans whose status could not be fetched keep their session in use
+	// until they are reconciled
+	adopted, pending := d.reconcileOrphanRecords(orphans)
+	for _, record := range append(adopted, pending...) {
+		inUse[record.SessionID] = true
+	}
+
+	d.sessionLock.Lock()
+	if session != "" && session != d.sessionID && !inUse[session] {
+		d.logger.Info("retiring session of the previous driver instance", "session_id", session)
+		d.retiredSessions = append(d.retiredSessions, session)
+	}
+	d.sessionLock.Unlock()
+
+	for _, record := range adopted {
+		go d.followOrphan(record)
+	}
+
+	ticker := time.NewTicker(defaultPollInterval)
+	defer ticker.Stop()
+	for len(pending) > 0 {
+		select {
+		case <-d.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if d.reconnect.isDown() {
+			continue
+		}
+		adopted, pending = d.reconcileOrphanRecords(pending)
+		for _, record := range adopted {
+			go d.followOrphan(record)
+		}
+	}
+}
+
+// reconcileOrphanRecords reconciles orphans, returning those adopted and
+// those whose status could not be fetched, to retry.
+func (d *ElideDriverPlugin) reconcileOrphanRecords(records []*ExecutionRecord) (adopted []*ExecutionRecord, pending []*ExecutionRecord) {
+	for _, record := range records {
+		ok, err := d.reconcileOrphan(record)
+		switch {
+		case err != nil:
+			d.logger.Warn("failed to fetch status of orphaned execution, retrying", "task_id", record.TaskID, "session_id", record.SessionID, "error", err)
+			pending = append(pending, record)
+		case ok:
+			adopted = append(adopted, record)
+		}
+	}
+	return adopted, pending
+}
+
+// reconcileOrphan cancels or adopts the executions of a task Nomad did not
+// recover. It reports whether any was adopted and still runs, or an error
+// if the status of an execution could not be fetched, leaving the task's
+// record as it is.
+func (d *ElideDriverPlugin) reconcileOrphan(record *ExecutionRecord) (adopted bool, err error) {
+	logger := d.logger.With("task_id", record.TaskID, "task_name", record.TaskName, "session_id", record.SessionID)
+	running := 0
+	for _, executionID := range record.ExecutionIDs {
+		ok, err := d.orphanRunning(record.SessionID, executionID)
+		if err != nil {
+			return false, err
+		}
+		if ok {
+			running++
+		}
+	}
+	if running == 0 {
+		logger.Debug("forgetting completed executions of a task that was not recovered")
+		d.metrics.IncrCounter(metricOrphanedTasks, "Tasks Nomad did not recover after a driver restart whose executions were reconciled, by result.", "result", "completed")
+		d.forgetExecutions(record.TaskID)
+		return false, nil
+	}
+
+	if d.config.OrphanPolicy == orphanPolicyAdopt {
+		logger.Info("adopting orphaned executions", "executions", running)
+		d.metrics.IncrCounter(metricOrphanedTasks, "Tasks Nomad did not recover after a driver restart whose executions were reconciled, by result.", "result", "adopted")
+		return true, nil
+	}
+
+	reason, initiator := pb.CancellationReason_CANCELLATION_REASON_USER_STOP, initiatorDriver
+	result := "cancelled"
+	for _, executionID := range record.ExecutionIDs {
+		ctx, cancel := d.withTimeout(d.ctx, d.statusTimeout())
+		_, err := d.daemonClient.CancelExecution(ctx, record.SessionID, executionID, reason, initiator, CancelToken(executionID, reason, initiator))
+		cancel()
+		if err != nil {
+			logger.Warn("failed to cancel orphaned execution", "execution_id", executionID, "error", err)
+			result = "failed"
+		}
+	}
+	logger.Info("cancelled orphaned executions", "executions", running)
+	d.metrics.IncrCounter(metricOrphanedTasks, "Tasks Nomad did not recover after a driver restart whose executions were reconciled, by result.", "result", result)
+	if result == "cancelled" {
+		d.forgetExecutions(record.TaskID)
+	}
+	return false, nil
+}
+
+// orphanRunning reports whether an orphaned execution still runs. One the
+// daemon does not know, as its session is gone, is done; any other error
+// leaves it unknown.
+func (d *ElideDriverPlugin) orphanRunning(sessionID string, executionID string) (bool, error) {
+	ctx, cancel := d.withTimeout(d.ctx, d.statusTimeout())
+	defer cancel()
+	resp, err := d.daemonClient.GetExecutionStatus(ctx, sessionID, executionID, OutputRequest{Omit: true})
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return false, nil
+		}
+		return false, err
+	}
+	return !resp.Complete, nil
+}
+
+// followOrphan holds execution slots for an adopted task's executions until
+// they all completed, then forgets them.
+func (d *ElideDriverPlugin) followOrphan(record *ExecutionRecord) {
+	release := d.admission.Adopt(record.SessionID)
+	defer release()
+
+	ticker := time.NewTicker(defaultPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-d.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if d.reconnect.isDown() {
+			continue
+		}
+		running, err := d.orphansRunning(record)
+		if err != nil {
+			d.logger.Warn("failed to fetch status of adopted execution, retrying", "task_id", record.TaskID, "session_id", record.SessionID, "error", err)
+			continue
+		}
+		if !running {
+			d.logger.Info("adopted orphaned executions completed", "task_id", record.TaskID, "session_id", record.SessionID)
+			d.forgetExecutions(record.TaskID)
+			return
+		}
+	}
+}
+
+// orphansRunning reports whether any execution of an adopted task still
+// runs.
+func (d *ElideDriverPlugin) orphansRunning(record *ExecutionRecord) (bool, error) {
+	for _, executionID := range record.ExecutionIDs {
+		running, err := d.orphanRunning(record.SessionID, executionID)
+		if err != nil || running {
+			return running, err
+		}
+	}
+	return false, nil
+}
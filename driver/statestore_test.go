@@ -0,0 +1,154 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/elide-dev/elide-task-driver/proto/gen/go/elide/daemon/v1alpha1"
+)
+
+// orphanClient reports the status of each execution as set in states:
+// "running", "completed", "gone" or "unavailable". It records the
+// executions cancelled through it.
+type orphanClient struct {
+	sessionDeletingClient
+
+	orphanMu  sync.Mutex
+	states    map[string]string
+	cancelled []string
+}
+
+func (c *orphanClient) GetExecutionStatus(ctx context.Context, sessionID string, executionID string, output OutputRequest) (*pb.GetExecutionStatusResponse, error) {
+	c.orphanMu.Lock()
+	defer c.orphanMu.Unlock()
+	switch c.states[executionID] {
+	case "running":
+		return &pb.GetExecutionStatusResponse{ExecutionId: executionID, Status: pb.ExecutionStatus_EXECUTION_STATUS_RUNNING}, nil
+	case "completed":
+		return &pb.GetExecutionStatusResponse{ExecutionId: executionID, Status: pb.ExecutionStatus_EXECUTION_STATUS_COMPLETED, Complete: true}, nil
+	case "unavailable":
+		return nil, status.Error(codes.Unavailable, "connection reset")
+	default:
+		return nil, status.Error(codes.NotFound, "execution not found")
+	}
+}
+
+func (c *orphanClient) CancelExecution(ctx context.Context, sessionID string, executionID string, reason pb.CancellationReason, initiator string, token string) (bool, error) {
+	c.orphanMu.Lock()
+	defer c.orphanMu.Unlock()
+	c.cancelled = append(c.cancelled, executionID)
+	return true, nil
+}
+
+func (c *orphanClient) set(executionID string, state string) {
+	c.orphanMu.Lock()
+	defer c.orphanMu.Unlock()
+	c.states[executionID] = state
+}
+
+func (c *orphanClient) cancels() []string {
+	c.orphanMu.Lock()
+	defer c.orphanMu.Unlock()
+	return append([]string(nil), c.cancelled...)
+}
+
+// newOrphanPlugin returns a driver restarted after a crash that left task-1
+// running exec-1 and exec-2 in session-old, with one execution slot.
+func newOrphanPlugin(t *testing.T, client *orphanClient, policy string) *ElideDriverPlugin {
+	dir := t.TempDir()
+	previous, err := OpenStateStore(dir)
+	require.NoError(t, err)
+	require.NoError(t, previous.SetSession("session-old"))
+	require.NoError(t, previous.PutExecution(&ExecutionRecord{TaskID: "task-1", SessionID: "session-old", ExecutionIDs: []string{"exec-1", "exec-2"}}))
+
+	d := NewPlugin(hclog.NewNullLogger()).(*ElideDriverPlugin)
+	t.Cleanup(d.Shutdown)
+	d.daemonClient = client
+	d.sessionID = "session-new"
+	d.config.OrphanPolicy = policy
+	d.config.OrphanGrace = "1ms"
+	d.admission = newExecutionSlots(1, 0)
+	d.state, err = OpenStateStore(dir)
+	require.NoError(t, err)
+	return d
+}
+
+// slotFree reports whether the driver's only execution slot is free.
+func slotFree(d *ElideDriverPlugin) bool {
+	release, ok := tryAcquire(d.admission, "session-new", "probe")
+	if ok {
+		release()
+	}
+	return ok
+}
+
+func TestReconcileOrphans_Cancel(t *testing.T) {
+	client := &orphanClient{states: map[string]string{"exec-1": "running", "exec-2": "gone"}}
+	d := newOrphanPlugin(t, client, orphanPolicyCancel)
+
+	d.reconcileOrphans()
+	assert.Equal(t, []string{"exec-1", "exec-2"}, client.cancels())
+	assert.Empty(t, d.state.Executions())
+	assert.Equal(t, []string{"session-old"}, d.retiredSessions)
+	assert.Contains(t, scrape(t, d), `elide_driver_orphaned_tasks_total{result="cancelled"} 1`)
+}
+
+func TestReconcileOrphans_Adopt(t *testing.T) {
+	client := &orphanClient{states: map[string]string{"exec-1": "running", "exec-2": "completed"}}
+	d := newOrphanPlugin(t, client, orphanPolicyAdopt)
+
+	d.reconcileOrphans()
+	assert.Empty(t, client.cancels())
+	assert.Empty(t, d.retiredSessions, "the session of adopted executions is in use")
+	assert.Contains(t, scrape(t, d), `elide_driver_orphaned_tasks_total{result="adopted"} 1`)
+	require.Eventually(t, func() bool { return !slotFree(d) }, time.Second, 10*time.Millisecond)
+
+	// An adopted execution whose status cannot be fetched still holds its
+	// slot
+	client.set("exec-1", "unavailable")
+	time.Sleep(2 * defaultPollInterval)
+	assert.False(t, slotFree(d))
+	assert.Len(t, d.state.Executions(), 1)
+
+	client.set("exec-1", "completed")
+	require.Eventually(t, func() bool { return slotFree(d) }, 3*defaultPollInterval, 10*time.Millisecond)
+	assert.Empty(t, d.state.Executions())
+}
+
+func TestReconcileOrphans_RetriesUnknownStatus(t *testing.T) {
+	client := &orphanClient{states: map[string]string{"exec-1": "unavailable", "exec-2": "running"}}
+	d := newOrphanPlugin(t, client, orphanPolicyCancel)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		d.reconcileOrphans()
+	}()
+
+	// The execution is neither taken as done nor cancelled while its
+	// status is unknown
+	time.Sleep(defaultPollInterval + defaultPollInterval/2)
+	assert.Empty(t, client.cancels())
+	assert.Len(t, d.state.Executions(), 1)
+	assert.Empty(t, d.retiredSessions)
+
+	client.set("exec-1", "running")
+	select {
+	case <-done:
+	case <-time.After(3 * defaultPollInterval):
+		t.Fatal("orphan was not reconciled once its status could be fetched")
+	}
+	assert.Equal(t, []string{"exec-1", "exec-2"}, client.cancels())
+	assert.Empty(t, d.state.Executions())
+}
@@ -0,0 +1,133 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	pb "github.com/elide-dev/elide-task-driver/proto/gen/go/elide/daemon/v1alpha1"
+)
+
+const (
+	// stdinAllocPrefix selects the shared alloc dir for stdin_path, so a
+	// task can read what an earlier task of the allocation wrote there
+	stdinAllocPrefix = "alloc/"
+
+	// maxStdinBytes bounds the stdin streamed to an execution
+	maxStdinBytes = 64 << 20
+
+	// stdinChunkSize is the largest chunk sent per OpenStdin message
+	stdinChunkSize = 256 << 10
+)
+
+// Stdin is the input streamed to each execution of a task: the file at Path,
+// or Data.
+type Stdin struct {
+	Path string
+	Data string
+}
+
+// HasStdin reports whether the task's executions read a stdin.
+func (tc *TaskConfig) HasStdin() bool {
+	return tc.StdinPath != "" || tc.StdinData != ""
+}
+
+// ResolveStdinPath resolves stdin_path: paths under "alloc/" are in the
+// shared alloc dir, others in the task directory, with the same containment
+// rules as ScriptPath.
+func (tc *TaskConfig) ResolveStdinPath(taskDir string, allocDir string, followSymlinks bool) (string, error) {
+	if rest, ok := strings.CutPrefix(filepath.ToSlash(tc.StdinPath), stdinAllocPrefix); ok {
+		return resolveTaskFile(allocDir, "stdin_path", filepath.FromSlash(rest), followSymlinks)
+	}
+	return resolveTaskFile(taskDir, "stdin_path", tc.StdinPath, followSymlinks)
+}
+
+// TaskStdin returns the stdin of a task's executions, or nil if they read an
+// empty stdin. A stdin_path file must be a regular file of at most
+// maxStdinBytes.
+func TaskStdin(tc *TaskConfig, taskDir string, allocDir string, followSymlinks bool) (*Stdin, error) {
+	if tc.StdinData != "" {
+		if len(tc.StdinData) > maxStdinBytes {
+			return nil, fmt.Errorf("stdin_data exceeds %d bytes", maxStdinBytes)
+		}
+		return &Stdin{Data: tc.StdinData}, nil
+	}
+	if tc.StdinPath == "" {
+		return nil, nil
+	}
+
+	path, err := tc.ResolveStdinPath(taskDir, allocDir, followSymlinks)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read stdin_path: %w", err)
+	}
+	if !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("stdin_path %q is not a regular file", tc.StdinPath)
+	}
+	if info.Size() > maxStdinBytes {
+		return nil, fmt.Errorf("stdin_path %q exceeds %d bytes", tc.StdinPath, maxStdinBytes)
+	}
+	return &Stdin{Path: path}, nil
+}
+
+// open returns a reader of the stdin's content.
+func (s *Stdin) open() (io.ReadCloser, error) {
+	if s.Path == "" {
+		return io.NopCloser(strings.NewReader(s.Data)), nil
+	}
+	return os.Open(s.Path)
+}
+
+// streamStdin streams the stdin of a submitted execution. If it fails, the
+// caller must cancel the execution, as it would otherwise wait for input
+// forever.
+func (d *ElideDriverPlugin) streamStdin(ctx context.Context, sessionID string, executionID string, stdin *Stdin) error {
+	r, err := stdin.open()
+	if err != nil {
+		return fmt.Errorf("failed to stream stdin: %w", err)
+	}
+	defer r.Close()
+
+	resp, err := d.daemonClient.OpenStdin(ctx, sessionID, executionID, r)
+	if err != nil {
+		return fmt.Errorf("failed to stream stdin: %w", err)
+	}
+	d.logger.Debug("streamed stdin", "execution_id", executionID, "bytes", resp.BytesReceived)
+	return nil
+}
+
+// sendStdin streams r in chunks of stdinChunkSize. The first message names
+// the session and execution and is sent even if r is empty.
+func sendStdin(send func(*pb.OpenStdinRequest) error, sessionID string, executionID string, r io.Reader) error {
+	buf := make([]byte, stdinChunkSize)
+	first := true
+	for {
+		n, readErr := io.ReadFull(r, buf)
+		if n > 0 || first {
+			req := &pb.OpenStdinRequest{Data: buf[:n]}
+			if first {
+				req.SessionId, req.ExecutionId = sessionID, executionID
+				first = false
+			}
+			if err := send(req); err != nil {
+				return err
+			}
+		}
+		if errors.Is(readErr, io.EOF) || errors.Is(readErr, io.ErrUnexpectedEOF) {
+			return nil
+		}
+		if readErr != nil {
+			return fmt.Errorf("failed to read stdin: %w", readErr)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/nomad/plugins/drivers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/elide-dev/elide-task-driver/proto/gen/go/elide/daemon/v1alpha1"
+)
+
+// stdinFailingClient accepts executions but fails to stream their stdin, and
+// records the executions cancelled through it.
+type stdinFailingClient struct {
+	failingSubmitClient
+
+	cancelled []string
+}
+
+func (c *stdinFailingClient) ExecuteSnippet(ctx context.Context, req ExecuteSnippetRequest) (*pb.ExecuteSnippetResponse, error) {
+	return &pb.ExecuteSnippetResponse{ExecutionId: req.ExecutionID, Status: pb.ExecutionStatus_EXECUTION_STATUS_RUNNING}, nil
+}
+
+func (c *stdinFailingClient) OpenStdin(ctx context.Context, sessionID string, executionID string, stdin io.Reader) (*pb.OpenStdinResponse, error) {
+	return nil, status.Error(codes.Unavailable, "stream reset")
+}
+
+func (c *stdinFailingClient) CancelExecution(ctx context.Context, sessionID string, executionID string, reason pb.CancellationReason, initiator string, token string) (bool, error) {
+	c.cancelled = append(c.cancelled, executionID)
+	return true, nil
+}
+
+func TestStartTask_CancelsExecutionWhenStdinFails(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    TaskConfig
+		cancelled []string
+	}{
+		{
+			name:      "single execution",
+			config:    TaskConfig{Language: "python", Code: "print(input())", StdinData: "hello"},
+			cancelled: []string{"task-1"},
+		},
+		{
+			// Submitting stops at the first entry, whose stdin failed
+			name:      "args_matrix",
+			config:    TaskConfig{Language: "python", Code: "print(input())", StdinData: "hello", ArgsMatrix: [][]string{{"a"}, {"b"}}},
+			cancelled: []string{matrixExecutionID("task-1", 0)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewPlugin(hclog.NewNullLogger()).(*ElideDriverPlugin)
+			t.Cleanup(d.Shutdown)
+			client := &stdinFailingClient{}
+			d.daemonClient = client
+			d.supportsStdin.Store(true)
+
+			cfg := &drivers.TaskConfig{ID: "task-1", JobID: "job", Namespace: "default", AllocDir: t.TempDir()}
+			require.NoError(t, cfg.EncodeConcreteDriverConfig(&tt.config))
+
+			_, _, err := d.StartTask(cfg)
+			require.ErrorContains(t, err, "failed to stream stdin")
+			assert.Equal(t, tt.cancelled, client.cancelled)
+			_, ok := d.tasks.Get("task-1")
+			assert.False(t, ok)
+		})
+	}
+}
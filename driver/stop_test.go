@@ -0,0 +1,181 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/nomad/plugins/drivers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/elide-dev/elide-task-driver/proto/gen/go/elide/daemon/v1alpha1"
+)
+
+// stopCall is a signal or cancel request received by stoppableDaemonClient.
+type stopCall struct {
+	name string
+	at   time.Time
+}
+
+// stoppableDaemonClient runs one execution until it is cancelled, or
+// signalled if exitOnSignal is set, and records the signal and cancel
+// requests it receives.
+type stoppableDaemonClient struct {
+	sessionDeletingClient
+
+	exitOnSignal bool
+
+	stopMu    sync.Mutex
+	stopped   bool
+	signals   []stopCall
+	cancels   []stopCall
+	initiator string
+}
+
+func (c *stoppableDaemonClient) SignalExecution(ctx context.Context, sessionID string, executionID string, signal string, named bool) (bool, error) {
+	c.stopMu.Lock()
+	defer c.stopMu.Unlock()
+	c.signals = append(c.signals, stopCall{name: signal, at: time.Now()})
+	if c.exitOnSignal {
+		c.stopped = true
+	}
+	return true, nil
+}
+
+func (c *stoppableDaemonClient) CancelExecution(ctx context.Context, sessionID string, executionID string, reason pb.CancellationReason, initiator string, token string) (bool, error) {
+	c.stopMu.Lock()
+	defer c.stopMu.Unlock()
+	c.cancels = append(c.cancels, stopCall{name: cancellationReasonName(reason), at: time.Now()})
+	c.initiator = initiator
+	c.stopped = true
+	return true, nil
+}
+
+func (c *stoppableDaemonClient) WatchExecution(ctx context.Context, sessionID string, executionID string, order bool) (StatusStream, error) {
+	return nil, status.Error(codes.Unimplemented, "unknown method WatchExecution")
+}
+
+func (c *stoppableDaemonClient) GetExecutionStatus(ctx context.Context, sessionID string, executionID string, output OutputRequest) (*pb.GetExecutionStatusResponse, error) {
+	c.stopMu.Lock()
+	defer c.stopMu.Unlock()
+	if c.stopped {
+		return &pb.GetExecutionStatusResponse{ExecutionId: executionID, Status: pb.ExecutionStatus_EXECUTION_STATUS_COMPLETED, Complete: true}, nil
+	}
+	return &pb.GetExecutionStatusResponse{ExecutionId: executionID, Status: pb.ExecutionStatus_EXECUTION_STATUS_RUNNING}, nil
+}
+
+// calls returns the signal and cancel requests received so far.
+func (c *stoppableDaemonClient) calls() ([]stopCall, []stopCall) {
+	c.stopMu.Lock()
+	defer c.stopMu.Unlock()
+	return append([]stopCall(nil), c.signals...), append([]stopCall(nil), c.cancels...)
+}
+
+// newStopPlugin returns a driver running task-1, whose status is polled as
+// WaitTask does.
+func newStopPlugin(t *testing.T, client DaemonClient, signals bool) (*ElideDriverPlugin, *taskHandle) {
+	d := NewPlugin(hclog.NewNullLogger()).(*ElideDriverPlugin)
+	t.Cleanup(d.Shutdown)
+	d.daemonClient = client
+	d.sessionID = "session-1"
+	d.supportsSignals.Store(signals)
+
+	h := d.recoveredHandle(&TaskState{
+		TaskConfig:   &drivers.TaskConfig{ID: "task-1"},
+		ExecutionId:  "exec-1",
+		SessionId:    "session-1",
+		StartedAt:    time.Now(),
+		PollInterval: 10 * time.Millisecond,
+	})
+	d.tasks.Set("task-1", h)
+	ch, err := d.WaitTask(context.Background(), "task-1")
+	require.NoError(t, err)
+	go func() {
+		for range ch {
+		}
+	}()
+	return d, h
+}
+
+// scrape returns the driver's metrics in the Prometheus text format.
+func scrape(t *testing.T, d *ElideDriverPlugin) string {
+	var buf strings.Builder
+	require.NoError(t, d.metrics.WritePrometheus(&buf))
+	return buf.String()
+}
+
+func TestStopTask_ExitsOnKillSignal(t *testing.T) {
+	client := &stoppableDaemonClient{exitOnSignal: true}
+	d, h := newStopPlugin(t, client, true)
+
+	start := time.Now()
+	require.NoError(t, d.StopTask("task-1", 5*time.Second, "SIGTERM"))
+	assert.Less(t, time.Since(start), 5*time.Second, "the stop ends as the task exits")
+
+	signals, cancels := client.calls()
+	require.Len(t, signals, 1)
+	assert.Equal(t, "SIGTERM", signals[0].name)
+	assert.Empty(t, cancels)
+	assert.False(t, h.isCancelled())
+	assert.Contains(t, scrape(t, d), `elide_driver_task_stops_total{result="exited"} 1`)
+}
+
+func TestStopTask_CancelsAfterKillTimeout(t *testing.T) {
+	client := &stoppableDaemonClient{}
+	d, h := newStopPlugin(t, client, true)
+
+	timeout := 300 * time.Millisecond
+	require.NoError(t, d.StopTask("task-1", timeout, ""))
+
+	signals, cancels := client.calls()
+	require.Len(t, signals, 1)
+	assert.Equal(t, defaultKillSignal, signals[0].name)
+	require.Len(t, cancels, 1)
+	assert.Equal(t, "user_stop", cancels[0].name)
+	assert.Equal(t, initiatorNomad, client.initiator)
+	assert.GreaterOrEqual(t, cancels[0].at.Sub(signals[0].at), timeout, "the kill_timeout window is waited out")
+
+	// The exit error says why the task was force-cancelled
+	assert.ErrorContains(t, h.cancelErr, "still running 300ms after SIGINT")
+	assert.Contains(t, scrape(t, d), `elide_driver_task_stops_total{result="cancelled"} 1`)
+}
+
+func TestStopTask_CancelsRightAway(t *testing.T) {
+	tests := []struct {
+		name    string
+		signals bool
+		timeout time.Duration
+		cause   string
+	}{
+		{
+			name:    "daemon cannot signal",
+			timeout: time.Minute,
+			cause:   "failed to send SIGINT: daemon does not support signalling executions",
+		},
+		{name: "zero kill_timeout", signals: true, cause: "kill_timeout is zero"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &stoppableDaemonClient{}
+			d, h := newStopPlugin(t, client, tt.signals)
+
+			start := time.Now()
+			require.NoError(t, d.StopTask("task-1", tt.timeout, ""))
+			assert.Less(t, time.Since(start), tt.timeout+time.Second)
+
+			signals, cancels := client.calls()
+			assert.Empty(t, signals)
+			assert.Len(t, cancels, 1)
+			assert.ErrorContains(t, h.cancelErr, tt.cause)
+		})
+	}
+}
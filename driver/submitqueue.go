@@ -0,0 +1,349 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/nomad/plugins/drivers"
+)
+
+// Values of daemon_loss_policy
+const (
+	// daemonLossFail fails tasks started while the daemon is unreachable
+	daemonLossFail = "fail"
+
+	// daemonLossWait queues tasks started while the daemon is unreachable
+	// and submits them once it returns
+	daemonLossWait = "wait"
+)
+
+const (
+	// defaultDaemonLossTimeout bounds how long a queued submission waits for
+	// the daemon if daemon_loss_timeout is unset
+	defaultDaemonLossTimeout = 15 * time.Minute
+
+	// queueDirName is the directory of state_dir holding queued submissions
+	queueDirName = "queue"
+
+	// taskStatusQueued is the status reported for a queued task
+	taskStatusQueued = "waiting_for_daemon"
+
+	// metricQueuedSubmissions counts queued submissions by result
+	metricQueuedSubmissions = "queued_submissions_total"
+)
+
+// errTaskQueued is returned for operations on a task that has no execution
+// yet because it is queued.
+var errTaskQueued = errors.New("task is queued until the daemon returns")
+
+// daemonLostError is returned by startTask for a task that may be queued
+// when the daemon could not be reached.
+type daemonLostError struct {
+	// codeHash is the SHA-256 of the code the task was to be submitted with
+	codeHash string
+	err      error
+}
+
+func (e *daemonLostError) Error() string { return e.err.Error() }
+func (e *daemonLostError) Unwrap() error { return e.err }
+
+// queuedSubmission tracks a task queued while the daemon is unreachable.
+type queuedSubmission struct {
+	codeHash string
+	queuedAt time.Time
+
+	// ctx ends when the task is stopped before it was submitted
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// done is closed once the task was submitted or given up on
+	done chan struct{}
+}
+
+// queueEntry is a queued submission as persisted in <state_dir>/queue, so a
+// restarted plugin resumes waiting for the daemon, or recovers the execution
+// the submission started.
+type queueEntry struct {
+	TaskID   string    `json:"task_id"`
+	AllocID  string    `json:"alloc_id"`
+	TaskName string    `json:"task_name"`
+	CodeHash string    `json:"code_hash"`
+	QueuedAt time.Time `json:"queued_at"`
+
+	// Submitted is the state of the task once its execution was submitted
+	Submitted *TaskState `json:"submitted,omitempty"`
+
+	// Failed is why the submission was given up on
+	Failed string `json:"failed,omitempty"`
+}
+
+// waitsOnDaemonLoss reports whether tasks started while the daemon is
+// unreachable are queued.
+func (c *Config) waitsOnDaemonLoss() bool {
+	return c.DaemonLossPolicy == daemonLossWait
+}
+
+// validateDaemonLoss checks daemon_loss_policy and the options it uses.
+func (c *Config) validateDaemonLoss() error {
+	switch c.DaemonLossPolicy {
+	case "", daemonLossFail, daemonLossWait:
+	default:
+		return fmt.Errorf("invalid daemon_loss_policy %q (must be %q or %q)", c.DaemonLossPolicy, daemonLossFail, daemonLossWait)
+	}
+	timeout, err := ParseDuration("daemon_loss_timeout", c.DaemonLossTimeout)
+	if err != nil {
+		return err
+	}
+	if c.DaemonLossTimeout != "" && timeout == 0 {
+		return fmt.Errorf("invalid daemon_loss_timeout %q: must be positive", c.DaemonLossTimeout)
+	}
+	if c.waitsOnDaemonLoss() && c.StateDir == "" {
+		return fmt.Errorf("daemon_loss_policy %q requires 'state_dir'", daemonLossWait)
+	}
+	return nil
+}
+
+// queueTask queues a task the daemon could not be reached for: the submission
+// is persisted in state_dir and the task reported running until submitQueued
+// submits it.
+func (d *ElideDriverPlugin) queueTask(cfg *drivers.TaskConfig, lost *daemonLostError) (*taskHandle, *TaskState, error) {
+	entry := &queueEntry{
+		TaskID:   cfg.ID,
+		AllocID:  cfg.AllocID,
+		TaskName: cfg.Name,
+		CodeHash: lost.codeHash,
+		QueuedAt: time.Now(),
+	}
+	if err := d.writeQueueEntry(entry); err != nil {
+		return nil, nil, fmt.Errorf("failed to queue task while the daemon is unreachable (%v): %w", lost.err, err)
+	}
+
+	h := d.queuedHandle(cfg, entry)
+	d.logger.Warn("daemon unreachable, queued task until it returns", "task_id", cfg.ID, "code_hash", entry.CodeHash, "error", lost.err)
+	d.metrics.IncrCounter(metricQueuedSubmissions, "Tasks queued while the daemon was unreachable, by result.", "result", "queued")
+	d.emitQueueEvent(h, "Daemon unreachable, task queued until it returns", map[string]string{
+		"code_hash": entry.CodeHash,
+	})
+
+	return h, h.taskState(), nil
+}
+
+// queuedHandle returns the handle of a queued task.
+func (d *ElideDriverPlugin) queuedHandle(cfg *drivers.TaskConfig, entry *queueEntry) *taskHandle {
+	ctx, cancel := context.WithCancel(d.ctx)
+	return &taskHandle{
+		taskConfig: cfg,
+		startedAt:  entry.QueuedAt,
+		logger:     d.logger.With("task_id", cfg.ID),
+		status:     taskStatusQueued,
+		codeHash:   entry.CodeHash,
+		queue: &queuedSubmission{
+			codeHash: entry.CodeHash,
+			queuedAt: entry.QueuedAt,
+			ctx:      ctx,
+			cancel:   cancel,
+			done:     make(chan struct{}),
+		},
+	}
+}
+
+// submitQueued waits for the daemon to return and submits a queued task, then
+// replaces its handle with the submitted task's. The task fails if the daemon
+// does not return within daemon_loss_timeout of the task being queued, or if
+// its code changed meanwhile. A driver shutting down leaves the submission
+// queued for the next plugin run.
+func (d *ElideDriverPlugin) submitQueued(h *taskHandle) {
+	queue := h.queue
+	defer close(queue.done)
+
+	timeout := durationOr(defaultDaemonLossTimeout, d.config.DaemonLossTimeout)
+	ctx, cancel := context.WithDeadline(queue.ctx, queue.queuedAt.Add(timeout))
+	defer cancel()
+
+	for {
+		if err := d.reconnect.wait(ctx); err != nil {
+			switch {
+			case d.ctx.Err() != nil:
+			case queue.ctx.Err() != nil:
+				d.failQueued(h, errors.New("task stopped while waiting for the daemon"))
+			default:
+				d.failQueued(h, fmt.Errorf("daemon did not return within %s", timeout))
+			}
+			return
+		}
+
+		if !d.lifecycle.enter(&d.lifecycle.starts) {
+			return
+		}
+		submitted, state, err := d.startTask(h.taskConfig, queue.codeHash)
+		d.lifecycle.starts.Done()
+		if isUnavailable(err) && ctx.Err() == nil {
+			d.reconnect.markDown(err)
+			continue
+		}
+		if err != nil {
+			d.failQueued(h, err)
+			return
+		}
+
+		entry := d.newQueueEntry(h)
+		entry.Submitted = state
+		if err := d.writeQueueEntry(entry); err != nil {
+			h.logger.Warn("failed to record submission of queued task, a restarted driver will not recover it", "error", err)
+		}
+		d.tasks.Set(h.taskConfig.ID, submitted)
+
+		waited := time.Since(queue.queuedAt).Round(time.Millisecond)
+		h.logger.Info("submitted queued task", "execution_id", state.ExecutionId, "waited", waited)
+		d.metrics.IncrCounter(metricQueuedSubmissions, "Tasks queued while the daemon was unreachable, by result.", "result", "submitted")
+		d.emitQueueEvent(h, fmt.Sprintf("Daemon returned, submitted queued task after %s", waited), map[string]string{
+			"execution_id": state.ExecutionId,
+			"queued_for":   waited.String(),
+		})
+		return
+	}
+}
+
+// failQueued gives up on a queued task.
+func (d *ElideDriverPlugin) failQueued(h *taskHandle, err error) {
+	entry := d.newQueueEntry(h)
+	entry.Failed = err.Error()
+	if err := d.writeQueueEntry(entry); err != nil {
+		h.logger.Warn("failed to record failure of queued task", "error", err)
+	}
+
+	h.logger.Warn("giving up on queued task", "error", err)
+	d.metrics.IncrCounter(metricQueuedSubmissions, "Tasks queued while the daemon was unreachable, by result.", "result", "failed")
+	d.emitQueueEvent(h, fmt.Sprintf("Giving up on queued task: %v", err), nil)
+	h.SetCompleted(&drivers.ExitResult{Err: fmt.Errorf("queued task not submitted: %w", err)})
+}
+
+// awaitQueued waits until a queued task was submitted and returns its new
+// handle, or nil if the task was given up on or ctx ended first.
+func (d *ElideDriverPlugin) awaitQueued(ctx context.Context, h *taskHandle) *taskHandle {
+	select {
+	case <-h.queue.done:
+	case <-ctx.Done():
+		return nil
+	case <-d.ctx.Done():
+		return nil
+	}
+	submitted, ok := d.tasks.Get(h.taskConfig.ID)
+	if !ok || submitted == h {
+		return nil
+	}
+	return submitted
+}
+
+// stopQueued stops a queued task: it is no longer submitted, unless that is
+// already underway. It returns the submitted task's handle in that case.
+func (d *ElideDriverPlugin) stopQueued(h *taskHandle) *taskHandle {
+	h.queue.cancel()
+	ctx, cancel := d.withTimeout(d.ctx, d.submitTimeout())
+	defer cancel()
+	return d.awaitQueued(ctx, h)
+}
+
+// recoverQueuedTask recovers a task that was queued: the execution its
+// submission started if the queue entry recorded one, otherwise the queued
+// task, which resumes waiting for the daemon.
+func (d *ElideDriverPlugin) recoverQueuedTask(taskState *TaskState) error {
+	entry, err := d.readQueueEntry(taskState.TaskConfig.ID)
+	if err != nil {
+		return fmt.Errorf("failed to recover queued task: %w", err)
+	}
+	if entry.Submitted != nil {
+		entry.Submitted.TaskConfig = taskState.TaskConfig
+		return d.recoverTaskState(entry.Submitted)
+	}
+
+	h := d.queuedHandle(taskState.TaskConfig, entry)
+	d.tasks.Set(taskState.TaskConfig.ID, h)
+	if entry.Failed != "" {
+		h.SetCompleted(&drivers.ExitResult{Err: fmt.Errorf("queued task not submitted: %s", entry.Failed)})
+		close(h.queue.done)
+		return nil
+	}
+	go d.submitQueued(h)
+	return nil
+}
+
+// emitQueueEvent emits a task event about a queued task.
+func (d *ElideDriverPlugin) emitQueueEvent(h *taskHandle, message string, annotations map[string]string) {
+	d.eventer.EmitEvent(&drivers.TaskEvent{
+		TaskID:      h.taskConfig.ID,
+		AllocID:     h.taskConfig.AllocID,
+		TaskName:    h.taskConfig.Name,
+		Timestamp:   time.Now(),
+		Message:     message,
+		Annotations: annotations,
+	})
+}
+
+// newQueueEntry returns the queue entry of a queued task.
+func (d *ElideDriverPlugin) newQueueEntry(h *taskHandle) *queueEntry {
+	return &queueEntry{
+		TaskID:   h.taskConfig.ID,
+		AllocID:  h.taskConfig.AllocID,
+		TaskName: h.taskConfig.Name,
+		CodeHash: h.queue.codeHash,
+		QueuedAt: h.queue.queuedAt,
+	}
+}
+
+// queueEntryPath returns the file of a task's queue entry.
+func (d *ElideDriverPlugin) queueEntryPath(taskID string) string {
+	return filepath.Join(d.config.StateDir, queueDirName, strings.ReplaceAll(taskID, "/", "_")+".json")
+}
+
+// writeQueueEntry persists a queue entry, replacing the previous one of the
+// task atomically.
+func (d *ElideDriverPlugin) writeQueueEntry(entry *queueEntry) error {
+	path := d.queueEntryPath(entry.TaskID)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create queue directory: %w", err)
+	}
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode queue entry: %w", err)
+	}
+	if err := os.WriteFile(path+".tmp", data, 0600); err != nil {
+		return fmt.Errorf("failed to write queue entry: %w", err)
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return fmt.Errorf("failed to write queue entry: %w", err)
+	}
+	return nil
+}
+
+// readQueueEntry reads the queue entry of a task.
+func (d *ElideDriverPlugin) readQueueEntry(taskID string) (*queueEntry, error) {
+	data, err := os.ReadFile(d.queueEntryPath(taskID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read queue entry: %w", err)
+	}
+	var entry queueEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, fmt.Errorf("failed to decode queue entry: %w", err)
+	}
+	return &entry, nil
+}
+
+// removeQueueEntry removes the queue entry of a task, if it has one.
+func (d *ElideDriverPlugin) removeQueueEntry(taskID string) {
+	if d.config.StateDir == "" {
+		return
+	}
+	if err := os.Remove(d.queueEntryPath(taskID)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		d.logger.Warn("failed to remove queue entry", "task_id", taskID, "error", err)
+	}
+}
@@ -0,0 +1,426 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/nomad/plugins/drivers"
+	"github.com/hashicorp/nomad/plugins/shared/structs"
+)
+
+const (
+	// defaultDaemonSocket is the daemon socket used if daemon_socket is unset
+	defaultDaemonSocket = "/tmp/elide-daemon.sock"
+
+	// supervisorCheckInterval is how often the supervisor checks on a daemon
+	// it did not start (or adopted from a previous driver run)
+	supervisorCheckInterval = 2 * time.Second
+
+	// supervisorStablePeriod is how long a daemon must run before its exit
+	// no longer counts towards the restart backoff
+	supervisorStablePeriod = time.Minute
+
+	// metricDaemonRestarts counts daemon restarts by the supervisor
+	metricDaemonRestarts = "daemon_restarts_total"
+)
+
+// Supervision states, reported as the driver.elide.daemon_state attribute
+const (
+	// daemonStateStarting: the daemon was started and its socket is awaited
+	daemonStateStarting = "starting"
+
+	// daemonStateRunning: the daemon the supervisor started is running
+	daemonStateRunning = "running"
+
+	// daemonStateExternal: a daemon the supervisor did not start serves the
+	// socket; it is started once the socket disappears
+	daemonStateExternal = "external"
+
+	// daemonStateBackoff: the daemon exited or failed to start and is
+	// restarted after a delay
+	daemonStateBackoff = "backoff"
+)
+
+// DaemonSupervisorConfig is the daemon_supervisor block of the plugin config:
+// whether the driver starts the daemon itself and how it restarts it.
+type DaemonSupervisorConfig struct {
+	// Enabled starts the daemon from elide_binary when its socket is absent
+	Enabled bool `codec:"enabled"`
+	// Args are the daemon's arguments (default: daemon --socket <socket>)
+	Args []string `codec:"args"`
+	// LogFile receives the daemon's output (default: <socket>.log)
+	LogFile string `codec:"log_file"`
+	// StartupTimeout bounds the wait for the daemon's socket (duration string)
+	StartupTimeout string `codec:"startup_timeout"`
+	// MaxRestartDelay caps the backoff between restarts (duration string)
+	MaxRestartDelay string `codec:"max_restart_delay"`
+}
+
+// Validate checks the daemon_supervisor block.
+func (c *DaemonSupervisorConfig) Validate() error {
+	if _, err := ParseDuration("startup_timeout", c.StartupTimeout); err != nil {
+		return err
+	}
+	if _, err := ParseDuration("max_restart_delay", c.MaxRestartDelay); err != nil {
+		return err
+	}
+	return nil
+}
+
+// socketPath returns the daemon socket, with its default applied.
+func (c *Config) socketPath() string {
+	if c.DaemonSocket == "" {
+		return defaultDaemonSocket
+	}
+	return c.DaemonSocket
+}
+
+// daemonSupervisor starts the daemon when its socket is absent, and restarts
+// it with jittered exponential backoff whenever it exits. The daemon is left
+// running when the driver stops, so tasks survive driver restarts; its PID is
+// kept in <socket>.pid so the next driver run adopts it.
+type daemonSupervisor struct {
+	binary  string
+	args    []string
+	socket  string
+	logFile string
+
+	startupTimeout  time.Duration
+	maxRestartDelay time.Duration
+
+	// mu guards the supervision state below
+	mu       sync.Mutex
+	state    string
+	pid      int
+	restarts int
+	lastErr  error
+
+	metrics *metricsRegistry
+	logger  hclog.Logger
+}
+
+// daemonProcess is a running daemon: one the supervisor started (cmd is set)
+// or one adopted from a previous driver run.
+type daemonProcess struct {
+	pid       int
+	startedAt time.Time
+
+	// exited is closed with err set once a started daemon exits
+	cmd    *exec.Cmd
+	exited chan struct{}
+	err    error
+}
+
+func newDaemonSupervisor(config *Config, metrics *metricsRegistry, logger hclog.Logger) *daemonSupervisor {
+	cfg := config.DaemonSupervisor
+	socket := config.socketPath()
+
+	s := &daemonSupervisor{
+		binary:          config.ElideBinary,
+		args:            cfg.Args,
+		socket:          socket,
+		logFile:         cfg.LogFile,
+		startupTimeout:  durationOr(10*time.Second, cfg.StartupTimeout),
+		maxRestartDelay: durationOr(time.Minute, cfg.MaxRestartDelay),
+		metrics:         metrics,
+		logger:          logger.Named("supervisor"),
+	}
+	if len(s.args) == 0 {
+		s.args = []string{"daemon", "--socket", socket}
+	}
+	if s.logFile == "" {
+		s.logFile = socket + ".log"
+	}
+	return s
+}
+
+// start makes sure a daemon serves the socket, adopting the daemon of a
+// previous driver run or starting one, then supervises it until ctx ends.
+// A daemon that fails to start is retried in the background.
+func (s *daemonSupervisor) start(ctx context.Context) {
+	proc := s.adopt()
+	if proc == nil && !socketExists(s.socket) {
+		var err error
+		if proc, err = s.launch(ctx); err != nil {
+			s.failed(err)
+		}
+	}
+	go s.supervise(ctx, proc)
+}
+
+// supervise waits for the daemon to exit and restarts it, backing off while
+// it keeps exiting or failing to start. While a daemon the supervisor did
+// not start serves the socket, it only watches for the socket to disappear.
+func (s *daemonSupervisor) supervise(ctx context.Context, proc *daemonProcess) {
+	delay := reconnectBaseDelay
+	for {
+		switch {
+		case proc != nil:
+			err := s.wait(ctx, proc)
+			if ctx.Err() != nil {
+				return
+			}
+			if time.Since(proc.startedAt) >= supervisorStablePeriod {
+				delay = reconnectBaseDelay
+			}
+			s.exited(proc, err)
+
+		case socketExists(s.socket):
+			s.setState(daemonStateExternal, 0)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(supervisorCheckInterval):
+			}
+			continue
+		}
+
+		s.logger.Info("restarting daemon", "delay", delay)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(jitter(delay)):
+		}
+		delay = min(delay*2, s.maxRestartDelay)
+
+		var err error
+		if proc, err = s.launch(ctx); err != nil {
+			s.failed(err)
+			continue
+		}
+		s.mu.Lock()
+		s.restarts++
+		s.mu.Unlock()
+		s.metrics.IncrCounter(metricDaemonRestarts, "Daemon restarts by the driver's supervisor.")
+	}
+}
+
+// launch starts the daemon and waits for it to create its socket.
+func (s *daemonSupervisor) launch(ctx context.Context) (*daemonProcess, error) {
+	logFile, err := os.OpenFile(s.logFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open daemon log file: %w", err)
+	}
+	defer logFile.Close()
+
+	// The daemon is not tied to ctx and runs in its own process group, so it
+	// outlives the driver and signals sent to the driver's group
+	cmd := exec.Command(s.binary, s.args...)
+	cmd.Env = append(os.Environ(), "ELIDE_DAEMON_SOCKET="+s.socket)
+	cmd.Stdout, cmd.Stderr = logFile, logFile
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start daemon: %w", err)
+	}
+
+	proc := &daemonProcess{pid: cmd.Process.Pid, startedAt: time.Now(), cmd: cmd, exited: make(chan struct{})}
+	go func() {
+		proc.err = cmd.Wait()
+		close(proc.exited)
+	}()
+	s.setState(daemonStateStarting, proc.pid)
+	s.logger.Info("started daemon", "binary", s.binary, "pid", proc.pid, "log_file", s.logFile)
+
+	if err := s.awaitSocket(ctx, proc); err != nil {
+		_ = cmd.Process.Kill()
+		<-proc.exited
+		return nil, err
+	}
+	if err := s.writePIDFile(proc.pid); err != nil {
+		s.logger.Warn("failed to write daemon PID file, a restarted driver will not adopt the daemon", "error", err)
+	}
+	s.setState(daemonStateRunning, proc.pid)
+	return proc, nil
+}
+
+// awaitSocket waits for a started daemon to create its socket.
+func (s *daemonSupervisor) awaitSocket(ctx context.Context, proc *daemonProcess) error {
+	deadline := time.NewTimer(s.startupTimeout)
+	defer deadline.Stop()
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for !socketExists(s.socket) {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-proc.exited:
+			return fmt.Errorf("daemon exited on startup: %v", proc.err)
+		case <-deadline.C:
+			return fmt.Errorf("daemon did not create its socket %s within %s", s.socket, s.startupTimeout)
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+// adopt returns the daemon started by a previous driver run if it still
+// serves the socket. The PID file records the daemon's start time next to its
+// PID, so that another process that reused the PID is not taken for it.
+func (s *daemonSupervisor) adopt() *daemonProcess {
+	data, err := os.ReadFile(s.pidFile())
+	if err != nil {
+		return nil
+	}
+	pidField, startTime, _ := strings.Cut(strings.TrimSpace(string(data)), " ")
+	pid, err := strconv.Atoi(pidField)
+	if err != nil || !processAlive(pid) || !socketExists(s.socket) {
+		return nil
+	}
+	if current, err := processStartTime(pid); err != nil || current != startTime {
+		s.logger.Warn("process in the daemon PID file is not the daemon started by a previous driver run, not adopting it", "pid", pid)
+		return nil
+	}
+
+	s.logger.Info("adopted daemon started by a previous driver run", "pid", pid)
+	s.setState(daemonStateRunning, pid)
+	return &daemonProcess{pid: pid, startedAt: time.Now()}
+}
+
+// writePIDFile records a started daemon's PID and start time.
+func (s *daemonSupervisor) writePIDFile(pid int) error {
+	startTime, err := processStartTime(pid)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.pidFile(), []byte(strconv.Itoa(pid)+" "+startTime), 0o640)
+}
+
+// wait blocks until the daemon exits or ctx ends. An adopted daemon is not a
+// child of the driver, so it counts as exited once its process is gone or
+// its socket disappeared.
+func (s *daemonSupervisor) wait(ctx context.Context, proc *daemonProcess) error {
+	if proc.cmd != nil {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-proc.exited:
+			return proc.err
+		}
+	}
+
+	ticker := time.NewTicker(supervisorCheckInterval)
+	defer ticker.Stop()
+	for processAlive(proc.pid) && socketExists(s.socket) {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return errors.New("adopted daemon is gone")
+}
+
+// exited records the exit of the supervised daemon and removes the socket
+// and PID file it left behind.
+func (s *daemonSupervisor) exited(proc *daemonProcess, err error) {
+	s.logger.Warn("daemon exited", "pid", proc.pid, "uptime", time.Since(proc.startedAt).Round(time.Second), "error", err)
+	if err := os.Remove(s.socket); err != nil && !errors.Is(err, os.ErrNotExist) {
+		s.logger.Warn("failed to remove stale daemon socket", "error", err)
+	}
+	_ = os.Remove(s.pidFile())
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state = daemonStateBackoff
+	s.pid = 0
+	s.lastErr = err
+}
+
+// failed records a failed daemon start.
+func (s *daemonSupervisor) failed(err error) {
+	s.logger.Error("failed to start daemon", "error", err)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state = daemonStateBackoff
+	s.pid = 0
+	s.lastErr = err
+}
+
+func (s *daemonSupervisor) setState(state string, pid int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state = state
+	s.pid = pid
+}
+
+// pidFile returns the path of the file holding the PID of the daemon.
+func (s *daemonSupervisor) pidFile() string {
+	return s.socket + ".pid"
+}
+
+// fingerprintAttributes reports the supervision state as node attributes.
+func (s *daemonSupervisor) fingerprintAttributes(fp *drivers.Fingerprint) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fp.Attributes["driver.elide.daemon_state"] = structs.NewStringAttribute(s.state)
+	fp.Attributes["driver.elide.daemon_restarts"] = structs.NewIntAttribute(int64(s.restarts), "")
+	if s.pid != 0 {
+		fp.Attributes["driver.elide.daemon_pid"] = structs.NewIntAttribute(int64(s.pid), "")
+	}
+}
+
+// starting reports whether the supervisor started the daemon and awaits its
+// socket.
+func (s *daemonSupervisor) starting() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state == daemonStateStarting
+}
+
+// describe returns a short description of the supervision state for the
+// driver's health description.
+func (s *daemonSupervisor) describe() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.state == daemonStateBackoff && s.lastErr != nil {
+		return fmt.Sprintf("supervised daemon %s after: %v", s.state, s.lastErr)
+	}
+	return "supervised daemon " + s.state
+}
+
+// socketExists reports whether the daemon socket exists.
+func socketExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// processAlive reports whether a process exists.
+func processAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+// processStartTime returns the start time of a process, in clock ticks since
+// boot, which tells it from a later process reusing its PID.
+func processStartTime(pid int) (string, error) {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return "", err
+	}
+	// The command name in parentheses may contain spaces; the start time is
+	// the 20th field after it
+	i := strings.LastIndex(string(data), ") ")
+	if i < 0 {
+		return "", fmt.Errorf("malformed stat of process %d", pid)
+	}
+	fields := strings.Fields(string(data)[i+2:])
+	if len(fields) < 20 {
+		return "", fmt.Errorf("malformed stat of process %d", pid)
+	}
+	return fields[19], nil
+}
@@ -0,0 +1,183 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubDaemon writes a daemon binary that records its start time in a file,
+// creates its socket, then runs body. It returns the supervisor of the stub
+// and the file of start times.
+func stubDaemon(t *testing.T, body string) (*daemonSupervisor, string) {
+	t.Helper()
+	dir := t.TempDir()
+	starts := filepath.Join(dir, "starts")
+	binary := filepath.Join(dir, "elide")
+	script := "#!/bin/sh\ndate +%s%N >> " + starts + "\ntouch \"$ELIDE_DAEMON_SOCKET\"\n" + body + "\n"
+	require.NoError(t, os.WriteFile(binary, []byte(script), 0755))
+
+	config := &Config{ElideBinary: binary, DaemonSocket: filepath.Join(dir, "daemon.sock")}
+	config.DaemonSupervisor.MaxRestartDelay = "1s"
+	s := newDaemonSupervisor(config, newMetricsRegistry(hclog.NewNullLogger()), hclog.NewNullLogger())
+	t.Cleanup(func() {
+		if pid := s.currentPID(); pid != 0 {
+			_ = syscall.Kill(pid, syscall.SIGKILL)
+		}
+	})
+	return s, starts
+}
+
+// startTimes returns the times the stub daemon started at.
+func startTimes(t *testing.T, starts string) []time.Time {
+	t.Helper()
+	data, err := os.ReadFile(starts)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	require.NoError(t, err)
+	var times []time.Time
+	for _, line := range strings.Fields(string(data)) {
+		ns, err := strconv.ParseInt(line, 10, 64)
+		require.NoError(t, err)
+		times = append(times, time.Unix(0, ns))
+	}
+	return times
+}
+
+// currentPID returns the PID of the supervised daemon, or 0.
+func (s *daemonSupervisor) currentPID() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.pid
+}
+
+func (s *daemonSupervisor) currentState() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state
+}
+
+func TestDaemonSupervisor_RestartsWithBackoff(t *testing.T) {
+	s, starts := stubDaemon(t, "sleep 0.2\nexit 1")
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	s.start(ctx)
+	require.Eventually(t, func() bool {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return s.restarts >= 3
+	}, 10*time.Second, 50*time.Millisecond)
+	cancel()
+
+	// Each restart waits a jittered delay of half to all of the backoff,
+	// which doubles from 0.5s up to max_restart_delay
+	times := startTimes(t, starts)
+	for i, minDelay := range []time.Duration{250 * time.Millisecond, 500 * time.Millisecond, 500 * time.Millisecond} {
+		assert.GreaterOrEqual(t, times[i+1].Sub(times[i]), minDelay+200*time.Millisecond, "restart %d", i+1)
+	}
+	assert.Less(t, times[3].Sub(times[2]), 2*time.Second, "the backoff is capped by max_restart_delay")
+
+	s.mu.Lock()
+	lastErr := s.lastErr
+	s.mu.Unlock()
+	assert.ErrorContains(t, lastErr, "exit status 1")
+	var buf strings.Builder
+	require.NoError(t, s.metrics.WritePrometheus(&buf))
+	assert.Contains(t, buf.String(), "elide_driver_daemon_restarts_total ")
+}
+
+func TestDaemonSupervisor_External(t *testing.T) {
+	s, starts := stubDaemon(t, "exec sleep 30")
+	require.NoError(t, os.WriteFile(s.socket, nil, 0600))
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	// A daemon the supervisor did not start is left alone
+	s.start(ctx)
+	require.Eventually(t, func() bool { return s.currentState() == daemonStateExternal }, time.Second, 10*time.Millisecond)
+	assert.Empty(t, startTimes(t, starts))
+	assert.Zero(t, s.currentPID())
+
+	// Once its socket is gone, the supervisor starts its own
+	require.NoError(t, os.Remove(s.socket))
+	require.Eventually(t, func() bool { return s.currentState() == daemonStateRunning }, 5*time.Second, 50*time.Millisecond)
+	assert.Len(t, startTimes(t, starts), 1)
+	pid := s.currentPID()
+	require.NotZero(t, pid)
+
+	// Its PID file lets the next driver run adopt it
+	startTime, err := processStartTime(pid)
+	require.NoError(t, err)
+	data, err := os.ReadFile(s.pidFile())
+	require.NoError(t, err)
+	assert.Equal(t, strconv.Itoa(pid)+" "+startTime, string(data))
+}
+
+func TestDaemonSupervisor_AdoptsFromPIDFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		pidFile func(pid int, startTime string) string
+		adopted bool
+	}{
+		{
+			name:    "same daemon",
+			pidFile: func(pid int, startTime string) string { return strconv.Itoa(pid) + " " + startTime },
+			adopted: true,
+		},
+		{
+			name:    "reused PID",
+			pidFile: func(pid int, startTime string) string { return strconv.Itoa(pid) + " 1" },
+		},
+		{
+			name:    "PID without start time",
+			pidFile: func(pid int, startTime string) string { return strconv.Itoa(pid) },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, starts := stubDaemon(t, "exec sleep 30")
+
+			// The daemon of a previous driver run, or a process that
+			// reused its PID
+			daemon := exec.Command("sleep", "30")
+			require.NoError(t, daemon.Start())
+			t.Cleanup(func() {
+				_ = daemon.Process.Kill()
+				_ = daemon.Wait()
+			})
+			pid := daemon.Process.Pid
+			startTime, err := processStartTime(pid)
+			require.NoError(t, err)
+			require.NoError(t, os.WriteFile(s.socket, nil, 0600))
+			require.NoError(t, os.WriteFile(s.pidFile(), []byte(tt.pidFile(pid, startTime)), 0640))
+
+			ctx, cancel := context.WithCancel(context.Background())
+			t.Cleanup(cancel)
+			s.start(ctx)
+			if tt.adopted {
+				assert.Equal(t, daemonStateRunning, s.currentState())
+				assert.Equal(t, pid, s.currentPID())
+			} else {
+				// Whatever serves the socket is not the supervisor's daemon
+				require.Eventually(t, func() bool { return s.currentState() == daemonStateExternal }, time.Second, 10*time.Millisecond)
+				assert.Zero(t, s.currentPID())
+			}
+			assert.Empty(t, startTimes(t, starts))
+		})
+	}
+}
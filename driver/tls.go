@@ -0,0 +1,192 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+const (
+	// defaultTLSReloadInterval is how often the TLS files are checked for
+	// rotation if reload_interval is unset
+	defaultTLSReloadInterval = time.Minute
+
+	// metricTLSReloads counts TLS credential reloads by result
+	metricTLSReloads = "daemon_tls_reloads_total"
+)
+
+// tlsDrainPeriod is how long the connection replaced by a rotation stays open
+// for the calls and streams in flight on it; tests shorten it
+var tlsDrainPeriod = 2 * time.Minute
+
+// DaemonTLSConfig is the daemon_tls block of the plugin config: TLS, or
+// mutual TLS with a client certificate, for the connection to the daemon.
+// The files are watched, so certificates rotated on disk are picked up
+// without restarting the driver.
+type DaemonTLSConfig struct {
+	// CAFile is the PEM bundle the daemon's certificate is verified against
+	// (default: the system roots)
+	CAFile string `codec:"ca_file"`
+	// CertFile and KeyFile are the driver's PEM client certificate and key
+	CertFile string `codec:"cert_file"`
+	KeyFile  string `codec:"key_file"`
+	// ServerName overrides the name the daemon's certificate is verified for
+	ServerName string `codec:"server_name"`
+	// ReloadInterval is how often the files are checked for rotation
+	// (duration string)
+	ReloadInterval string `codec:"reload_interval"`
+}
+
+// Enabled reports whether the connection to the daemon uses TLS.
+func (c *DaemonTLSConfig) Enabled() bool {
+	return c.CAFile != "" || c.CertFile != ""
+}
+
+// Validate checks the daemon_tls block.
+func (c *DaemonTLSConfig) Validate() error {
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		return errors.New("'cert_file' and 'key_file' must be specified together")
+	}
+	if c.ServerName != "" && !c.Enabled() {
+		return errors.New("'server_name' requires 'ca_file' or 'cert_file'")
+	}
+	interval, err := ParseDuration("reload_interval", c.ReloadInterval)
+	if err != nil {
+		return err
+	}
+	if c.ReloadInterval != "" && interval == 0 {
+		return fmt.Errorf("invalid reload_interval %q: must be positive", c.ReloadInterval)
+	}
+	return nil
+}
+
+// files returns the configured TLS files.
+func (c *DaemonTLSConfig) files() []string {
+	var files []string
+	for _, file := range []string{c.CAFile, c.CertFile, c.KeyFile} {
+		if file != "" {
+			files = append(files, file)
+		}
+	}
+	return files
+}
+
+// load reads the TLS files into transport credentials, returning as well the
+// checksum of their contents that identifies this rotation.
+func (c *DaemonTLSConfig) load() (credentials.TransportCredentials, [sha256.Size]byte, error) {
+	hash := sha256.New()
+	contents := map[string][]byte{}
+	for _, file := range c.files() {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, [sha256.Size]byte{}, err
+		}
+		hash.Write(data)
+		contents[file] = data
+	}
+	var sum [sha256.Size]byte
+	hash.Sum(sum[:0])
+
+	config := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: c.ServerName,
+	}
+	if c.CAFile != "" {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(contents[c.CAFile]) {
+			return nil, sum, fmt.Errorf("no certificates found in %s", c.CAFile)
+		}
+		config.RootCAs = pool
+	}
+	if c.CertFile != "" {
+		cert, err := tls.X509KeyPair(contents[c.CertFile], contents[c.KeyFile])
+		if err != nil {
+			return nil, sum, fmt.Errorf("invalid client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return credentials.NewTLS(config), sum, nil
+}
+
+// tlsDialOption returns the dial option applying daemon_tls, if configured,
+// along with the checksum of the TLS files it was loaded from.
+func (d *ElideDriverPlugin) tlsDialOption() (grpc.DialOption, [sha256.Size]byte, error) {
+	creds, sum, err := d.config.DaemonTLS.load()
+	if err != nil {
+		return nil, sum, fmt.Errorf("daemon_tls: %w", err)
+	}
+	return grpc.WithTransportCredentials(creds), sum, nil
+}
+
+// watchTLS reloads the TLS credentials whenever the TLS files change, e.g.
+// when certificates are rotated on disk. A new connection is dialled with the
+// new credentials and used once it answered a health check; the previous
+// connection is closed after tlsDrainPeriod, so calls and streams in flight
+// finish and no task fails because of the rotation. Until the files form
+// valid credentials again (e.g. a certificate written before its key) and the
+// daemon accepts them, the current connection stays in use; connections the
+// daemon refused are retried at the next check.
+func (d *ElideDriverPlugin) watchTLS() {
+	// last is the checksum of the files in use and invalid that of the files
+	// that last failed to load, whose failure is only reported once
+	last := d.tlsLoaded
+	var invalid *[sha256.Size]byte
+	ticker := time.NewTicker(durationOr(defaultTLSReloadInterval, d.config.DaemonTLS.ReloadInterval))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-d.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		creds, sum, err := d.config.DaemonTLS.load()
+		if sum == last || (invalid != nil && sum == *invalid) {
+			continue
+		}
+		if err != nil {
+			invalid = &sum
+			d.logger.Warn("failed to reload daemon TLS credentials, keeping the current ones", "error", err)
+			d.metrics.IncrCounter(metricTLSReloads, "Reloads of the daemon TLS credentials.", "result", "failure")
+			continue
+		}
+
+		client, ok := d.daemonClient.(*elideDaemonClient)
+		if !ok {
+			return
+		}
+		ctx, cancel := context.WithTimeout(d.ctx, reconnectProbeTimeout)
+		old, err := client.redial(ctx, grpc.WithTransportCredentials(creds))
+		cancel()
+		if err != nil {
+			d.logger.Warn("failed to connect with rotated daemon TLS credentials, keeping the current connection", "error", err)
+			d.metrics.IncrCounter(metricTLSReloads, "Reloads of the daemon TLS credentials.", "result", "failure")
+			continue
+		}
+		last = sum
+
+		d.logger.Info("daemon TLS credentials rotated", "drain_period", tlsDrainPeriod)
+		d.metrics.IncrCounter(metricTLSReloads, "Reloads of the daemon TLS credentials.", "result", "success")
+		go func() {
+			select {
+			case <-d.ctx.Done():
+			case <-time.After(tlsDrainPeriod):
+			}
+			if err := old.Close(); err != nil {
+				d.logger.Debug("failed to close connection replaced by TLS rotation", "error", err)
+			}
+		}()
+	}
+}
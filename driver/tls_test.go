@@ -0,0 +1,298 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/stats"
+
+	pb "github.com/elide-dev/elide-task-driver/proto/gen/go/elide/daemon/v1alpha1"
+)
+
+// testCA issues certificates for the TLS tests
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+var testSerial atomic.Int64
+
+func newTestCA(t *testing.T, name string) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(testSerial.Add(1)),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue returns a PEM certificate and key for name, valid for 127.0.0.1
+func (ca *testCA) issue(t *testing.T, name string, usage x509.ExtKeyUsage) ([]byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(testSerial.Add(1)),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+// replaceFile rewrites path in one step, as certificate rotation tools do
+func replaceFile(t *testing.T, path string, data []byte) {
+	tmp := path + ".tmp"
+	require.NoError(t, os.WriteFile(tmp, data, 0o600))
+	require.NoError(t, os.Rename(tmp, path))
+}
+
+// tlsDaemon is a daemon requiring client certificates from the CAs it
+// trusts. Health reports the client certificate's common name as the version,
+// and GetExecutionStatus blocks until release is closed.
+type tlsDaemon struct {
+	pb.UnimplementedExecutionApiServer
+
+	trusted    atomic.Pointer[x509.CertPool]
+	rejections atomic.Int32
+	conns      atomic.Int32
+	release    chan struct{}
+
+	mu    sync.Mutex
+	slow  []string
+	calls chan struct{}
+}
+
+func clientName(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(info.State.PeerCertificates) == 0 {
+		return ""
+	}
+	return info.State.PeerCertificates[0].Subject.CommonName
+}
+
+func (s *tlsDaemon) Health(ctx context.Context, _ *pb.HealthRequest) (*pb.HealthResponse, error) {
+	return &pb.HealthResponse{Healthy: true, Version: clientName(ctx)}, nil
+}
+
+func (s *tlsDaemon) GetExecutionStatus(ctx context.Context, _ *pb.GetExecutionStatusRequest) (*pb.GetExecutionStatusResponse, error) {
+	s.mu.Lock()
+	s.slow = append(s.slow, clientName(ctx))
+	s.mu.Unlock()
+	s.calls <- struct{}{}
+	select {
+	case <-s.release:
+		return &pb.GetExecutionStatusResponse{}, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (s *tlsDaemon) TagRPC(ctx context.Context, _ *stats.RPCTagInfo) context.Context {
+	return ctx
+}
+
+func (s *tlsDaemon) HandleRPC(context.Context, stats.RPCStats) {}
+
+func (s *tlsDaemon) TagConn(ctx context.Context, _ *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+// HandleConn counts the open connections
+func (s *tlsDaemon) HandleConn(_ context.Context, st stats.ConnStats) {
+	switch st.(type) {
+	case *stats.ConnBegin:
+		s.conns.Add(1)
+	case *stats.ConnEnd:
+		s.conns.Add(-1)
+	}
+}
+
+// verify accepts client certificates issued by a trusted CA
+func (s *tlsDaemon) verify(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+	if len(rawCerts) == 0 {
+		return errors.New("no client certificate")
+	}
+	cert, err := x509.ParseCertificate(rawCerts[0])
+	if err == nil {
+		_, err = cert.Verify(x509.VerifyOptions{Roots: s.trusted.Load(), KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}})
+	}
+	if err != nil {
+		s.rejections.Add(1)
+	}
+	return err
+}
+
+func startTLSDaemon(t *testing.T, ca *testCA) (*tlsDaemon, string) {
+	certPEM, keyPEM := ca.issue(t, "daemon", x509.ExtKeyUsageServerAuth)
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	require.NoError(t, err)
+
+	s := &tlsDaemon{release: make(chan struct{}), calls: make(chan struct{}, 1)}
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	s.trusted.Store(pool)
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server := grpc.NewServer(grpc.Creds(credentials.NewTLS(&tls.Config{
+		Certificates:          []tls.Certificate{cert},
+		ClientAuth:            tls.RequireAnyClientCert,
+		VerifyPeerCertificate: s.verify,
+	})), grpc.StatsHandler(s))
+	pb.RegisterExecutionApiServer(server, s)
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+	return s, lis.Addr().String()
+}
+
+func (s *tlsDaemon) trust(ca *testCA) {
+	pool := s.trusted.Load().Clone()
+	pool.AddCert(ca.cert)
+	s.trusted.Store(pool)
+}
+
+func TestWatchTLS_RotatesCertificates(t *testing.T) {
+	// Restored last, once the watcher stopped
+	t.Cleanup(func(period time.Duration) func() {
+		return func() { tlsDrainPeriod = period }
+	}(tlsDrainPeriod))
+	tlsDrainPeriod = 2 * time.Second
+
+	ca := newTestCA(t, "ca")
+	daemon, addr := startTLSDaemon(t, ca)
+
+	dir := t.TempDir()
+	tlsConfig := DaemonTLSConfig{
+		CAFile:         filepath.Join(dir, "ca.pem"),
+		CertFile:       filepath.Join(dir, "cert.pem"),
+		KeyFile:        filepath.Join(dir, "key.pem"),
+		ReloadInterval: "20ms",
+	}
+	write := func(cert, key []byte) {
+		replaceFile(t, tlsConfig.CertFile, cert)
+		replaceFile(t, tlsConfig.KeyFile, key)
+	}
+	replaceFile(t, tlsConfig.CAFile, ca.pem)
+	write(ca.issue(t, "client-1", x509.ExtKeyUsageClientAuth))
+
+	d := NewPlugin(hclog.NewNullLogger()).(*ElideDriverPlugin)
+	t.Cleanup(d.Shutdown)
+	d.config.DaemonAddress = addr
+	d.config.DaemonTLS = tlsConfig
+	client, err := d.newDaemonClient()
+	require.NoError(t, err)
+	t.Cleanup(func() { client.Close() })
+	d.daemonClient = client
+
+	caller := func() string {
+		resp, err := client.Health(context.Background())
+		if !assert.NoError(t, err) {
+			return ""
+		}
+		return resp.Version
+	}
+	require.Equal(t, "client-1", caller())
+
+	watching := make(chan struct{})
+	go func() {
+		defer close(watching)
+		d.watchTLS()
+	}()
+	t.Cleanup(func() {
+		d.signalShutdown()
+		<-watching
+	})
+
+	// A certificate without its key is skipped and reported once
+	cert2, key2 := ca.issue(t, "client-2", x509.ExtKeyUsageClientAuth)
+	replaceFile(t, tlsConfig.CertFile, cert2)
+	assert.Eventually(t, func() bool {
+		return strings.Contains(scrape(t, d), `elide_driver_daemon_tls_reloads_total{result="failure"}`)
+	}, 5*time.Second, 10*time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, "client-1", caller(), "the current connection stays in use")
+	assert.Contains(t, scrape(t, d), `elide_driver_daemon_tls_reloads_total{result="failure"} 1`)
+
+	// A call in flight on the old connection outlives the rotation
+	slow := make(chan error, 1)
+	go func() {
+		_, err := client.GetExecutionStatus(context.Background(), "session-1", "exec-1", OutputRequest{})
+		slow <- err
+	}()
+	<-daemon.calls
+
+	replaceFile(t, tlsConfig.KeyFile, key2)
+	require.Eventually(t, func() bool { return caller() == "client-2" }, 5*time.Second, 10*time.Millisecond)
+	assert.Contains(t, scrape(t, d), `elide_driver_daemon_tls_reloads_total{result="success"} 1`)
+	assert.EqualValues(t, 2, daemon.conns.Load(), "the old connection drains")
+	select {
+	case err := <-slow:
+		t.Fatalf("in-flight call ended by the rotation: %v", err)
+	default:
+	}
+	close(daemon.release)
+	require.NoError(t, <-slow)
+	daemon.mu.Lock()
+	assert.Equal(t, []string{"client-1"}, daemon.slow)
+	daemon.mu.Unlock()
+	assert.Eventually(t, func() bool { return daemon.conns.Load() == 1 }, tlsDrainPeriod+5*time.Second, 50*time.Millisecond,
+		"the old connection is closed after the drain period")
+	assert.Equal(t, "client-2", caller())
+
+	// Credentials the daemon refuses keep the current connection, and are
+	// re-dialled until it accepts them
+	other := newTestCA(t, "other-ca")
+	write(other.issue(t, "client-3", x509.ExtKeyUsageClientAuth))
+	require.Eventually(t, func() bool { return daemon.rejections.Load() > 0 }, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, "client-2", caller())
+	daemon.trust(other)
+	assert.Eventually(t, func() bool { return caller() == "client-3" }, 15*time.Second, 20*time.Millisecond)
+}
@@ -0,0 +1,332 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/nomad/plugins/drivers"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// tracerName is the instrumentation scope of the driver's spans
+	tracerName = "github.com/elide-dev/elide-task-driver/driver"
+
+	// tracingServiceName is the service.name of the driver's spans
+	tracingServiceName = "elide-task-driver"
+
+	// tracingShutdownTimeout bounds flushing buffered spans on shutdown
+	tracingShutdownTimeout = 5 * time.Second
+)
+
+// traceContextPropagator carries trace context to the daemon in W3C
+// traceparent/tracestate gRPC metadata, and into recovered task state
+var traceContextPropagator = propagation.TraceContext{}
+
+// validateOtelEndpoint checks otel_endpoint.
+func (c *Config) validateOtelEndpoint() error {
+	if c.OtelEndpoint == "" {
+		return nil
+	}
+	u, err := url.Parse(c.OtelEndpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid otel_endpoint %q (must be an http:// or https:// URL of an OTLP/gRPC collector)", c.OtelEndpoint)
+	}
+	return nil
+}
+
+// tracer creates the driver's spans and exports them to an OTLP collector.
+// Without otel_endpoint it is a no-op and installs no interceptors.
+type tracer struct {
+	tracer trace.Tracer
+
+	// shutdown flushes and stops the exporter (nil when disabled)
+	shutdown func(context.Context) error
+}
+
+// newTracer returns a tracer exporting to the OTLP/gRPC collector at
+// endpoint, or a no-op tracer if endpoint is empty. The exporter connects
+// lazily, so a collector that is down only loses spans.
+func newTracer(endpoint string, logger hclog.Logger) (*tracer, error) {
+	if endpoint == "" {
+		return &tracer{tracer: noop.NewTracerProvider().Tracer(tracerName)}, nil
+	}
+	exporter, err := otlptracegrpc.New(context.Background(), otlptracegrpc.WithEndpointURL(endpoint))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP trace exporter: %w", err)
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(
+			attribute.String("service.name", tracingServiceName),
+			attribute.String("service.version", pluginVersion),
+			attribute.String("host.name", hostname()),
+		)),
+	)
+	logger.Info("exporting traces", "otel_endpoint", endpoint)
+	return &tracer{tracer: provider.Tracer(tracerName), shutdown: provider.Shutdown}, nil
+}
+
+// enabled reports whether spans are exported.
+func (t *tracer) enabled() bool {
+	return t.shutdown != nil
+}
+
+// close flushes buffered spans and stops the exporter.
+func (t *tracer) close() error {
+	if !t.enabled() {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), tracingShutdownTimeout)
+	defer cancel()
+	return t.shutdown(ctx)
+}
+
+// start starts a span of the driver, a child of the span in ctx if any.
+func (t *tracer) start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return t.tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// dialOptions returns the dial options tracing each daemon RPC as a client
+// span and propagating its context to the daemon, none when disabled.
+func (t *tracer) dialOptions() []grpc.DialOption {
+	if !t.enabled() {
+		return nil
+	}
+	return []grpc.DialOption{
+		grpc.WithChainUnaryInterceptor(t.interceptUnary),
+		grpc.WithChainStreamInterceptor(t.interceptStream),
+	}
+}
+
+func (t *tracer) interceptUnary(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	ctx, span := t.startRPC(ctx, method)
+	err := invoker(ctx, method, req, reply, cc, opts...)
+	endRPCSpan(span, err)
+	return err
+}
+
+func (t *tracer) interceptStream(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	ctx, span := t.startRPC(ctx, method)
+	stream, err := streamer(ctx, desc, cc, method, opts...)
+	if err != nil {
+		endRPCSpan(span, err)
+		return nil, err
+	}
+	return &tracedStream{ClientStream: stream, span: span}, nil
+}
+
+// startRPC starts the client span of a daemon RPC and adds its context to
+// the call's outgoing metadata.
+func (t *tracer) startRPC(ctx context.Context, method string) (context.Context, trace.Span) {
+	name := strings.TrimPrefix(method, "/")
+	service, rpcMethod, _ := strings.Cut(name, "/")
+	ctx, span := t.tracer.Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("rpc.system", "grpc"),
+			attribute.String("rpc.service", service),
+			attribute.String("rpc.method", rpcMethod),
+		),
+	)
+	md, _ := metadata.FromOutgoingContext(ctx)
+	md = md.Copy()
+	traceContextPropagator.Inject(ctx, metadataCarrier(md))
+	return metadata.NewOutgoingContext(ctx, md), span
+}
+
+// endRPCSpan ends the span of a daemon RPC with the call's status code.
+func endRPCSpan(span trace.Span, err error) {
+	code := status.Code(err)
+	span.SetAttributes(attribute.Int64("rpc.grpc.status_code", int64(code)))
+	if err != nil {
+		span.SetStatus(codes.Error, code.String())
+		span.RecordError(err)
+	}
+	span.End()
+}
+
+// tracedStream ends the span of a streaming RPC once the stream ends.
+type tracedStream struct {
+	grpc.ClientStream
+	span trace.Span
+}
+
+func (s *tracedStream) RecvMsg(m any) error {
+	err := s.ClientStream.RecvMsg(m)
+	switch {
+	case err == nil:
+		s.span.AddEvent("message received")
+	case errors.Is(err, io.EOF):
+		endRPCSpan(s.span, nil)
+	default:
+		endRPCSpan(s.span, err)
+	}
+	return err
+}
+
+// metadataCarrier adapts gRPC metadata to a propagation.TextMapCarrier.
+type metadataCarrier metadata.MD
+
+func (c metadataCarrier) Get(key string) string {
+	if values := metadata.MD(c).Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+func (c metadataCarrier) Set(key, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// TraceParent encodes a span context as a W3C traceparent, empty if the
+// context is invalid (as when tracing is disabled).
+func TraceParent(sc trace.SpanContext) string {
+	carrier := propagation.MapCarrier{}
+	traceContextPropagator.Inject(trace.ContextWithSpanContext(context.Background(), sc), carrier)
+	return carrier.Get("traceparent")
+}
+
+// ParseTraceParent decodes a W3C traceparent, returning an invalid span
+// context if it is empty or malformed.
+func ParseTraceParent(traceParent string) trace.SpanContext {
+	if traceParent == "" {
+		return trace.SpanContext{}
+	}
+	ctx := traceContextPropagator.Extract(context.Background(), propagation.MapCarrier{"traceparent": traceParent})
+	return trace.SpanContextFromContext(ctx)
+}
+
+// traceParentEnvVars are the task env vars a W3C traceparent is taken from
+// when elide_opts.trace_parent is not set: the OpenTelemetry convention, and
+// the meta of a dispatched job (nomad job dispatch -meta traceparent=...).
+var traceParentEnvVars = []string{"TRACEPARENT", "NOMAD_META_TRACEPARENT"}
+
+// validateTraceParent checks elide_opts.trace_parent.
+func (tc *TaskConfig) validateTraceParent() error {
+	if traceParent := tc.ElideOpts.TraceParent; traceParent != "" && !ParseTraceParent(traceParent).IsValid() {
+		return fmt.Errorf("invalid elide_opts.trace_parent %q (must be a W3C traceparent, e.g. \"00-<32 hex trace ID>-<16 hex span ID>-01\")", traceParent)
+	}
+	return nil
+}
+
+// TaskTraceParent returns the span context of the distributed trace a task
+// joins, such as one started by the job's dispatcher: its
+// elide_opts.trace_parent, else the first valid traceparent in its env. It is
+// invalid if the task joins none.
+func TaskTraceParent(tc *TaskConfig, env map[string]string) trace.SpanContext {
+	if tc.ElideOpts.TraceParent != "" {
+		return ParseTraceParent(tc.ElideOpts.TraceParent)
+	}
+	for _, name := range traceParentEnvVars {
+		if sc := ParseTraceParent(env[name]); sc.IsValid() {
+			return sc
+		}
+	}
+	return trace.SpanContext{}
+}
+
+// taskTraceContext returns ctx carrying the span context of the trace the
+// task joins, if any, so the task's spans are part of it. A config that does
+// not decode joins none; submitting the task reports the error.
+func taskTraceContext(ctx context.Context, cfg *drivers.TaskConfig) context.Context {
+	var taskConfig TaskConfig
+	if err := cfg.DecodeDriverConfig(&taskConfig); err != nil {
+		return ctx
+	}
+	if sc := TaskTraceParent(&taskConfig, cfg.Env); sc.IsValid() {
+		return trace.ContextWithRemoteSpanContext(ctx, sc)
+	}
+	return ctx
+}
+
+// withOutgoingTraceContext adds the span context of ctx to the outgoing
+// metadata of calls made with it. When tracing is enabled the RPC's own span
+// replaces it; otherwise the daemon still sees the trace the task joined.
+func withOutgoingTraceContext(ctx context.Context) context.Context {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return ctx
+	}
+	md, _ := metadata.FromOutgoingContext(ctx)
+	md = md.Copy()
+	traceContextPropagator.Inject(ctx, metadataCarrier(md))
+	return metadata.NewOutgoingContext(ctx, md)
+}
+
+// taskAttributes are the attributes of a task's spans.
+func taskAttributes(cfg *drivers.TaskConfig) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("nomad.task_id", cfg.ID),
+		attribute.String("nomad.alloc_id", cfg.AllocID),
+		attribute.String("nomad.task_name", cfg.Name),
+	}
+}
+
+// taskContext returns ctx carrying the span context of a task's StartTask
+// span, so spans started from it join the task's trace.
+func taskContext(ctx context.Context, h *taskHandle) context.Context {
+	if !h.traceContext.IsValid() {
+		return ctx
+	}
+	return trace.ContextWithRemoteSpanContext(ctx, h.traceContext)
+}
+
+// withSpan returns parent carrying the span of ctx, for calls bound to a
+// different context than the span's, such as the driver's.
+func withSpan(parent context.Context, ctx context.Context) context.Context {
+	return trace.ContextWithSpan(parent, trace.SpanFromContext(ctx))
+}
+
+// endSpan ends a span, marking it failed with err if not nil.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		span.RecordError(err)
+	}
+	span.End()
+}
+
+// endWaitSpan ends the WaitTask span of a task with its exit result, nil if
+// the wait ended without one.
+func endWaitSpan(span trace.Span, result *drivers.ExitResult) {
+	if result == nil {
+		span.End()
+		return
+	}
+	span.SetAttributes(
+		attribute.Int("elide.exit_code", result.ExitCode),
+		attribute.Bool("elide.oom_killed", result.OOMKilled),
+	)
+	err := result.Err
+	if err == nil && !result.Successful() {
+		err = fmt.Errorf("exited with code %d", result.ExitCode)
+	}
+	endSpan(span, err)
+}
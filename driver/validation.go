@@ -0,0 +1,98 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+// FieldError is a problem with a task config option, named by its HCL path
+// (e.g. "elide_opts.timeout").
+type FieldError struct {
+	Field string
+	Err   error
+}
+
+func (e *FieldError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// validationErrors collects every problem with a config rather than stopping
+// at the first, so all of them can be fixed in one pass.
+type validationErrors struct {
+	errs *multierror.Error
+}
+
+// add records a problem with a field, if err is not nil. A FieldError keeps
+// the more specific field it names.
+func (v *validationErrors) add(field string, err error) {
+	if err == nil {
+		return
+	}
+	var fieldErr *FieldError
+	if !errors.As(err, &fieldErr) {
+		fieldErr = &FieldError{Field: field, Err: err}
+	}
+	v.errs = multierror.Append(v.errs, fieldErr)
+}
+
+// addf records a problem with a field.
+func (v *validationErrors) addf(field string, format string, args ...any) {
+	v.add(field, fmt.Errorf(format, args...))
+}
+
+// err returns the problems recorded, or nil if there are none. A single
+// problem reads as before; several are listed on one line, each naming its
+// option.
+func (v *validationErrors) err() error {
+	if v.errs == nil {
+		return nil
+	}
+	v.errs.ErrorFormat = formatValidationErrors
+	return v.errs
+}
+
+func formatValidationErrors(errs []error) string {
+	if len(errs) == 1 {
+		return errs[0].Error()
+	}
+	problems := make([]string, len(errs))
+	for i, err := range errs {
+		problems[i] = err.Error()
+		var field *FieldError
+		if errors.As(err, &field) && !strings.Contains(problems[i], field.Field) {
+			problems[i] = field.Field + ": " + problems[i]
+		}
+	}
+	return fmt.Sprintf("%d problems: %s", len(errs), strings.Join(problems, "; "))
+}
+
+// InvalidFields returns the HCL paths of the options a validation error
+// reports problems with, in the order they were found.
+func InvalidFields(err error) []string {
+	var merr *multierror.Error
+	if !errors.As(err, &merr) {
+		var field *FieldError
+		if errors.As(err, &field) {
+			return []string{field.Field}
+		}
+		return nil
+	}
+	fields := make([]string, 0, len(merr.Errors))
+	for _, err := range merr.Errors {
+		var field *FieldError
+		if errors.As(err, &field) {
+			fields = append(fields, field.Field)
+		}
+	}
+	return fields
+}
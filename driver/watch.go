@@ -0,0 +1,112 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"context"
+	"time"
+
+	"github.com/hashicorp/nomad/plugins/drivers"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/elide-dev/elide-task-driver/proto/gen/go/elide/daemon/v1alpha1"
+)
+
+// statusUpdate is a message received on a status stream.
+type statusUpdate struct {
+	status *pb.GetExecutionStatusResponse
+	err    error
+}
+
+// watchExecution follows the status of an execution over a WatchExecution
+// stream, so the daemon pushes changes instead of the driver polling for
+// them. The stream is re-opened after daemon outages. It returns the task's
+// exit result and true once the task is done (a nil result if ctx ended), or
+// false if the caller should fall back to polling: the daemon does not
+// support streaming, or the stream ended before the execution completed.
+func (d *ElideDriverPlugin) watchExecution(ctx context.Context, handle *taskHandle, w *WorkerHeartbeat, lastScratchCheck *time.Time, limitInterval time.Duration) (*drivers.ExitResult, bool) {
+	// Deadlines and quotas are enforced between updates too
+	ticker := time.NewTicker(limitInterval)
+	defer ticker.Stop()
+
+	for {
+		d.beatPoller(w)
+		if err := d.reconnect.wait(ctx); err != nil {
+			return nil, true
+		}
+		result, done, reopen := d.followStatusStream(ctx, handle, w, lastScratchCheck, ticker.C)
+		if !reopen {
+			return result, done
+		}
+	}
+}
+
+// followStatusStream consumes one status stream. reopen is set if the stream
+// broke because the daemon became unreachable.
+func (d *ElideDriverPlugin) followStatusStream(ctx context.Context, handle *taskHandle, w *WorkerHeartbeat, lastScratchCheck *time.Time, tick <-chan time.Time) (result *drivers.ExitResult, done bool, reopen bool) {
+	streamCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream, err := d.daemonClient.WatchExecution(streamCtx, handle.sessionId, handle.executionId, handle.logs.combined)
+	if err != nil {
+		return d.statusStreamFailed(handle, err)
+	}
+
+	updates := make(chan statusUpdate)
+	go func() {
+		for {
+			status, err := stream.Recv()
+			select {
+			case updates <- statusUpdate{status: status, err: err}:
+			case <-streamCtx.Done():
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, true, false
+		case <-d.ctx.Done():
+			return nil, true, false
+		case <-tick:
+			d.beatPoller(w)
+			d.enforceLimits(handle, lastScratchCheck)
+		case update := <-updates:
+			if update.err != nil {
+				return d.statusStreamFailed(handle, update.err)
+			}
+			if d.faults.shouldDropStatus() {
+				handle.logger.Debug("dropping status update (fault injection)")
+				continue
+			}
+			if result, done := d.applyStatus(handle, update.status, OutputRequest{Order: handle.logs.combined}, lastScratchCheck); done {
+				return result, true, false
+			}
+		}
+	}
+}
+
+// statusStreamFailed decides how to go on after a status stream failed.
+func (d *ElideDriverPlugin) statusStreamFailed(handle *taskHandle, err error) (*drivers.ExitResult, bool, bool) {
+	switch {
+	case status.Code(err) == codes.Unimplemented:
+		if d.watchUnsupported.CompareAndSwap(false, true) {
+			d.logger.Info("daemon does not support WatchExecution, polling execution status instead")
+		}
+		return nil, false, false
+	case isUnavailable(err):
+		d.reconnect.markDown(err)
+		return nil, false, true
+	default:
+		// Includes the stream ending before the execution completed
+		handle.logger.Debug("execution status stream ended, polling instead", "error", err)
+		return nil, false, false
+	}
+}
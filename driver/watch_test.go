@@ -0,0 +1,186 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"context"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/nomad/plugins/drivers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/elide-dev/elide-task-driver/proto/gen/go/elide/daemon/v1alpha1"
+)
+
+// streamingDaemonClient fails the first WatchExecution calls with watchErrs,
+// then streams statuses. Polls report the execution completed.
+type streamingDaemonClient struct {
+	flakyDaemonClient
+
+	watchErrs  []error
+	statuses   []*pb.GetExecutionStatusResponse
+	watchCalls atomic.Int32
+}
+
+func (c *streamingDaemonClient) WatchExecution(ctx context.Context, sessionID string, executionID string, order bool) (StatusStream, error) {
+	n := int(c.watchCalls.Add(1))
+	if n <= len(c.watchErrs) {
+		return nil, c.watchErrs[n-1]
+	}
+	return &scriptedStatusStream{statuses: c.statuses}, nil
+}
+
+// scriptedStatusStream returns its statuses, then io.EOF.
+type scriptedStatusStream struct {
+	statuses []*pb.GetExecutionStatusResponse
+}
+
+func (s *scriptedStatusStream) Recv() (*pb.GetExecutionStatusResponse, error) {
+	if len(s.statuses) == 0 {
+		return nil, io.EOF
+	}
+	status := s.statuses[0]
+	s.statuses = s.statuses[1:]
+	return status, nil
+}
+
+func newWatchPlugin(t *testing.T, client DaemonClient) *ElideDriverPlugin {
+	d := NewPlugin(hclog.NewNullLogger()).(*ElideDriverPlugin)
+	t.Cleanup(d.Shutdown)
+	d.daemonClient = client
+	d.sessionID = "session-1"
+	return d
+}
+
+// waitTask starts a task with a running execution and waits for its result.
+func waitTask(t *testing.T, d *ElideDriverPlugin, id string) *drivers.ExitResult {
+	t.Helper()
+	d.tasks.Set(id, d.recoveredHandle(&TaskState{
+		TaskConfig:   &drivers.TaskConfig{ID: id},
+		ExecutionId:  "exec-" + id,
+		SessionId:    "session-1",
+		StartedAt:    time.Now(),
+		PollInterval: 10 * time.Millisecond,
+	}))
+
+	ch, err := d.WaitTask(context.Background(), id)
+	require.NoError(t, err)
+	select {
+	case result := <-ch:
+		require.NotNil(t, result)
+		return result
+	case <-time.After(5 * time.Second):
+		t.Fatal("task did not complete")
+		return nil
+	}
+}
+
+func TestWaitTask_TerminalStatusOnStream(t *testing.T) {
+	client := &streamingDaemonClient{statuses: []*pb.GetExecutionStatusResponse{
+		{Status: pb.ExecutionStatus_EXECUTION_STATUS_RUNNING},
+		{Status: pb.ExecutionStatus_EXECUTION_STATUS_COMPLETED, Complete: true, ExitCode: 3},
+	}}
+	d := newWatchPlugin(t, client)
+
+	result := waitTask(t, d, "task-1")
+	assert.Equal(t, 3, result.ExitCode)
+	assert.Equal(t, int32(1), client.watchCalls.Load())
+	assert.Zero(t, client.statusCalls.Load(), "a streamed execution is not polled")
+}
+
+func TestWaitTask_PollsWhenWatchUnimplemented(t *testing.T) {
+	client := &streamingDaemonClient{watchErrs: []error{status.Error(codes.Unimplemented, "unknown method WatchExecution")}}
+	d := newWatchPlugin(t, client)
+
+	result := waitTask(t, d, "task-1")
+	assert.True(t, result.Successful())
+	assert.Positive(t, client.statusCalls.Load())
+	assert.True(t, d.watchUnsupported.Load())
+
+	// Later tasks poll right away
+	waitTask(t, d, "task-2")
+	assert.Equal(t, int32(1), client.watchCalls.Load())
+}
+
+func TestWaitTask_ReopensStreamAfterOutage(t *testing.T) {
+	client := &streamingDaemonClient{
+		watchErrs: []error{status.Error(codes.Unavailable, "connection refused")},
+		statuses: []*pb.GetExecutionStatusResponse{
+			{Status: pb.ExecutionStatus_EXECUTION_STATUS_COMPLETED, Complete: true},
+		},
+	}
+	d := newWatchPlugin(t, client)
+
+	result := waitTask(t, d, "task-1")
+	assert.True(t, result.Successful())
+	assert.Equal(t, int32(2), client.watchCalls.Load(), "the stream is opened again once the daemon is back")
+	assert.Positive(t, client.healthProbes.Load())
+	assert.Zero(t, client.statusCalls.Load())
+	assert.False(t, d.watchUnsupported.Load())
+}
+
+func TestWaitTask_PollsWhenStreamEndsEarly(t *testing.T) {
+	client := &streamingDaemonClient{statuses: []*pb.GetExecutionStatusResponse{
+		{Status: pb.ExecutionStatus_EXECUTION_STATUS_RUNNING},
+	}}
+	d := newWatchPlugin(t, client)
+
+	result := waitTask(t, d, "task-1")
+	assert.True(t, result.Successful())
+	assert.Positive(t, client.statusCalls.Load())
+	assert.False(t, d.watchUnsupported.Load(), "only this task falls back to polling")
+}
+
+// completingClient reports every execution completed in its ExecuteSnippet
+// response, and records the completion_wait it was given.
+type completingClient struct {
+	streamingDaemonClient
+
+	completionWait time.Duration
+}
+
+func (c *completingClient) ExecuteSnippet(ctx context.Context, req ExecuteSnippetRequest) (*pb.ExecuteSnippetResponse, error) {
+	c.completionWait = req.CompletionWait
+	return &pb.ExecuteSnippetResponse{
+		ExecutionId: req.ExecutionID,
+		Status:      pb.ExecutionStatus_EXECUTION_STATUS_COMPLETED,
+		Completion: &pb.GetExecutionStatusResponse{
+			ExecutionId: req.ExecutionID,
+			Status:      pb.ExecutionStatus_EXECUTION_STATUS_COMPLETED,
+			Complete:    true,
+			ExitCode:    3,
+		},
+	}, nil
+}
+
+func TestWaitTask_CompletedAtSubmit(t *testing.T) {
+	client := &completingClient{}
+	d := newWatchPlugin(t, client)
+	d.config.CompletionWait = "2s"
+
+	cfg := &drivers.TaskConfig{ID: "task-1", JobID: "job", Namespace: "default", AllocDir: t.TempDir()}
+	require.NoError(t, cfg.EncodeConcreteDriverConfig(&TaskConfig{Language: "python", Code: "print(1)"}))
+	_, _, err := d.StartTask(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, 2*time.Second, client.completionWait)
+
+	ch, err := d.WaitTask(context.Background(), "task-1")
+	require.NoError(t, err)
+	select {
+	case result := <-ch:
+		require.NotNil(t, result)
+		assert.Equal(t, 3, result.ExitCode)
+	case <-time.After(5 * time.Second):
+		t.Fatal("task did not complete")
+	}
+	assert.Zero(t, client.watchCalls.Load(), "an execution completed at submit is not followed")
+	assert.Zero(t, client.statusCalls.Load(), "an execution completed at submit is not polled")
+}
@@ -0,0 +1,251 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/nomad/plugins/drivers"
+	"github.com/hashicorp/nomad/plugins/shared/structs"
+)
+
+const (
+	// workerStallFactor is how many heartbeat intervals a worker may miss
+	// before it is reported as stalled
+	workerStallFactor = 3
+
+	// healthzPath serves the health of the plugin and its workers
+	healthzPath = "/healthz"
+
+	// Names of the plugin's background workers. Per-task workers are named
+	// <kind>/<task ID>.
+	workerSessionKeepalive = "session_keepalive"
+	workerLogThrottle      = "log_throttle"
+	workerManifestWatcher  = "manifest_watcher"
+	workerSessionEvents    = "session_events"
+	workerStatusPoller     = "status_poller"
+)
+
+// States of a background worker
+const (
+	WorkerRunning = "running"
+	WorkerIdle    = "idle"
+	WorkerStalled = "stalled"
+	WorkerCrashed = "crashed"
+)
+
+// WorkerMonitor tracks the liveness of the plugin's background goroutines:
+// the session keepalive, the log throttle, the manifest watcher, the session
+// event follower and each task's status poller, which also ships its
+// output. Each beats at least once per interval; one that stops beating is
+// stalled, one that panicked is crashed. Either degrades the fingerprint
+// and /healthz.
+type WorkerMonitor struct {
+	mu      sync.Mutex
+	workers map[string]*WorkerHeartbeat
+	logger  hclog.Logger
+}
+
+// NewWorkerMonitor returns a monitor with no workers.
+func NewWorkerMonitor(logger hclog.Logger) *WorkerMonitor {
+	return &WorkerMonitor{
+		workers: map[string]*WorkerHeartbeat{},
+		logger:  logger.Named("workers"),
+	}
+}
+
+// WorkerHeartbeat is a worker's liveness, updated by the worker itself. A
+// nil heartbeat ignores all calls.
+type WorkerHeartbeat struct {
+	monitor  *WorkerMonitor
+	name     string
+	interval time.Duration
+
+	// guarded by monitor.mu
+	lastBeat time.Time
+	idle     bool
+	crash    string
+}
+
+// Start registers a worker expected to beat every interval (0 = the worker
+// is only checked for crashes), replacing any worker of the same name.
+func (m *WorkerMonitor) Start(name string, interval time.Duration) *WorkerHeartbeat {
+	w := &WorkerHeartbeat{monitor: m, name: name, interval: interval, lastBeat: time.Now()}
+	m.mu.Lock()
+	m.workers[name] = w
+	m.mu.Unlock()
+	return w
+}
+
+// Run runs fn as a worker until it returns. A panic is recovered and
+// reported as a crash rather than taking the plugin down.
+func (m *WorkerMonitor) Run(name string, interval time.Duration, fn func(w *WorkerHeartbeat)) {
+	w := m.Start(name, interval)
+	defer w.Done()
+	fn(w)
+}
+
+// Remove forgets a worker, including a crashed one.
+func (m *WorkerMonitor) Remove(name string) {
+	m.mu.Lock()
+	delete(m.workers, name)
+	m.mu.Unlock()
+}
+
+// Beat records that the worker is making progress.
+func (w *WorkerHeartbeat) Beat() {
+	if w == nil {
+		return
+	}
+	w.monitor.mu.Lock()
+	w.lastBeat = time.Now()
+	w.idle = false
+	w.monitor.mu.Unlock()
+}
+
+// Idle records that the worker is waiting on something outside the plugin,
+// such as a daemon outage, and is not stalled until its next beat.
+func (w *WorkerHeartbeat) Idle() {
+	if w == nil {
+		return
+	}
+	w.monitor.mu.Lock()
+	w.idle = true
+	w.monitor.mu.Unlock()
+}
+
+// Done unregisters the worker once it returned, or records its crash if it
+// panicked. It must be deferred by the worker's goroutine.
+func (w *WorkerHeartbeat) Done() {
+	if w == nil {
+		return
+	}
+	r := recover()
+	m := w.monitor
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if r == nil {
+		// A worker replaced under the same name is not removed
+		if m.workers[w.name] == w {
+			delete(m.workers, w.name)
+		}
+		return
+	}
+	w.crash = fmt.Sprint(r)
+	m.workers[w.name] = w
+	m.logger.Error("background worker crashed", "worker", w.name, "panic", w.crash, "stack", string(debug.Stack()))
+}
+
+// WorkerStatus is the health of one background worker.
+type WorkerStatus struct {
+	Name          string    `json:"name"`
+	State         string    `json:"state"`
+	LastHeartbeat time.Time `json:"last_heartbeat"`
+	Interval      string    `json:"interval,omitempty"`
+	Error         string    `json:"error,omitempty"`
+}
+
+// WorkersHealth is the health of the plugin's background workers.
+type WorkersHealth struct {
+	// Status is "ok", or "degraded" if any worker stalled or crashed
+	Status string `json:"status"`
+	// Workers lists the plugin-wide workers, and the per-task workers that
+	// stalled or crashed
+	Workers []WorkerStatus `json:"workers"`
+	// Tasks counts the per-task workers by kind
+	Tasks map[string]int `json:"tasks,omitempty"`
+}
+
+// Degraded returns the names of the workers that stalled or crashed.
+func (h *WorkersHealth) Degraded() []string {
+	var names []string
+	for _, w := range h.Workers {
+		if w.State == WorkerStalled || w.State == WorkerCrashed {
+			names = append(names, w.Name)
+		}
+	}
+	return names
+}
+
+// Health reports the state of every worker as of now.
+func (m *WorkerMonitor) Health(now time.Time) WorkersHealth {
+	health := WorkersHealth{Status: "ok", Workers: []WorkerStatus{}}
+
+	m.mu.Lock()
+	for name, w := range m.workers {
+		status := WorkerStatus{Name: name, State: WorkerRunning, LastHeartbeat: w.lastBeat.UTC(), Error: w.crash}
+		if w.interval > 0 {
+			status.Interval = w.interval.String()
+		}
+		switch {
+		case w.crash != "":
+			status.State = WorkerCrashed
+		case w.idle:
+			status.State = WorkerIdle
+		case w.interval > 0 && now.Sub(w.lastBeat) > workerStallFactor*w.interval:
+			status.State = WorkerStalled
+		}
+
+		if kind, _, perTask := strings.Cut(name, "/"); perTask {
+			if health.Tasks == nil {
+				health.Tasks = map[string]int{}
+			}
+			health.Tasks[kind]++
+			if status.State != WorkerStalled && status.State != WorkerCrashed {
+				continue
+			}
+		}
+		health.Workers = append(health.Workers, status)
+	}
+	m.mu.Unlock()
+
+	sort.Slice(health.Workers, func(i, j int) bool { return health.Workers[i].Name < health.Workers[j].Name })
+	if len(health.Degraded()) > 0 {
+		health.Status = "degraded"
+	}
+	return health
+}
+
+// workerAttributes reports stalled or crashed workers on the fingerprint.
+// The driver stays healthy, since tasks still run, but the description and
+// driver.elide.degraded_workers name the workers.
+func workerAttributes(fp *drivers.Fingerprint, health WorkersHealth) {
+	degraded := health.Degraded()
+	fp.Attributes["driver.elide.workers_healthy"] = structs.NewBoolAttribute(len(degraded) == 0)
+	if len(degraded) == 0 {
+		return
+	}
+	fp.Attributes["driver.elide.degraded_workers"] = structs.NewStringAttribute(strings.Join(degraded, ","))
+	if fp.Health == drivers.HealthStateHealthy {
+		fp.HealthDescription = fmt.Sprintf("%s (degraded: %s)", fp.HealthDescription, strings.Join(degraded, ", "))
+	}
+}
+
+// beatPoller records a status poller's progress, or that it is waiting out
+// a daemon outage.
+func (d *ElideDriverPlugin) beatPoller(w *WorkerHeartbeat) {
+	if d.reconnect.isDown() {
+		w.Idle()
+		return
+	}
+	w.Beat()
+}
+
+// handleHealthz serves the health of the plugin's background workers: 200
+// if all are live, 503 if any stalled or crashed.
+func (d *ElideDriverPlugin) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	health := d.workers.Health(time.Now())
+	status := http.StatusOK
+	if health.Status != "ok" {
+		status = http.StatusServiceUnavailable
+	}
+	writeAdminJSON(w, status, health)
+}
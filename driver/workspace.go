@@ -0,0 +1,237 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	pb "github.com/elide-dev/elide-task-driver/proto/gen/go/elide/daemon/v1alpha1"
+)
+
+const (
+	// defaultWorkspaceDir is the directory uploaded for entrypoint tasks
+	// without files, relative to the task directory: the task's local dir,
+	// where Nomad places artifacts and templates
+	defaultWorkspaceDir = "local"
+
+	// maxWorkspaceFiles and maxWorkspaceBytes bound what a task uploads.
+	// Workspaces are meant for a project's source files, not for data.
+	maxWorkspaceFiles = 4096
+	maxWorkspaceBytes = 64 << 20
+
+	// workspaceChunkSize is the largest file chunk sent per UploadWorkspace
+	// message, well below the daemon's message size limit
+	workspaceChunkSize = 256 << 10
+)
+
+// languageExtensions are the source file extensions of each language, whose
+// imports are checked against the denied modules and from which a task's
+// language is inferred. Languages the daemon gains (e.g. ruby with .rb,
+// kotlin with .kt) are added here.
+var languageExtensions = map[string][]string{
+	"python":     {".py"},
+	"javascript": {".js", ".mjs", ".cjs"},
+	"typescript": {".ts", ".mts", ".cts"},
+}
+
+// WorkspaceFile is a file of a workspace to upload.
+type WorkspaceFile struct {
+	// Path is the file's path relative to the workspace root, '/'-separated
+	Path string
+
+	// HostPath is where the file is read from
+	HostPath string
+
+	Mode fs.FileMode
+	Size int64
+}
+
+// Workspace is the directory a multi-file task uploads to the daemon.
+type Workspace struct {
+	Files []WorkspaceFile
+
+	// Hash is the SHA-256 of the workspace's paths, modes and contents; it
+	// doubles as the workspace ID, so identical uploads share a workspace
+	Hash string
+
+	// Sources are the contents of the files in the task's language, for the
+	// denied modules check
+	Sources string
+}
+
+// validateWorkspacePath checks a path inside the workspace, given by the task
+// option: it must be relative and may not leave the workspace.
+func validateWorkspacePath(option string, name string) error {
+	if filepath.IsAbs(name) {
+		return fmt.Errorf("%s %q must be relative", option, name)
+	}
+	clean := filepath.Clean(name)
+	if clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("%s %q escapes the task directory", option, name)
+	}
+	return nil
+}
+
+// WorkspaceDir returns the directory an entrypoint task uploads, relative to
+// the task directory.
+func (tc *TaskConfig) WorkspaceDir() string {
+	if tc.Files != "" {
+		return tc.Files
+	}
+	return defaultWorkspaceDir
+}
+
+// CollectWorkspace lists the regular files under dir, relative to the task
+// directory, in lexical order and checks that entrypoint is one of them.
+// Symlinks are followed under the same containment rules as script; symlinks
+// to directories are skipped.
+func CollectWorkspace(taskDir string, dir string, entrypoint string, language string, followSymlinks bool) (*Workspace, error) {
+	root, err := resolveTaskFile(taskDir, "files", dir, followSymlinks)
+	if err != nil {
+		return nil, err
+	}
+	if info, err := os.Stat(root); err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("files %q is not a directory", dir)
+	}
+
+	ws := &Workspace{}
+	var total int64
+	err = filepath.WalkDir(root, func(hostPath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, hostPath)
+		if err != nil {
+			return err
+		}
+		if entry.Type()&fs.ModeSymlink != 0 {
+			hostPath, err = resolveTaskFile(taskDir, "files", filepath.Join(dir, rel), followSymlinks)
+			if err != nil {
+				return err
+			}
+		}
+		info, err := os.Stat(hostPath)
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		if len(ws.Files) == maxWorkspaceFiles {
+			return fmt.Errorf("files %q holds more than %d files", dir, maxWorkspaceFiles)
+		}
+		total += info.Size()
+		if total > maxWorkspaceBytes {
+			return fmt.Errorf("files %q holds more than %d bytes", dir, maxWorkspaceBytes)
+		}
+		ws.Files = append(ws.Files, WorkspaceFile{
+			Path:     filepath.ToSlash(rel),
+			HostPath: hostPath,
+			Mode:     info.Mode().Perm(),
+			Size:     info.Size(),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect files: %w", err)
+	}
+
+	entrypoint = path.Clean(filepath.ToSlash(entrypoint))
+	if !slices.ContainsFunc(ws.Files, func(f WorkspaceFile) bool { return f.Path == entrypoint }) {
+		return nil, fmt.Errorf("entrypoint %q not found in files %q", entrypoint, dir)
+	}
+
+	// The hash covers every file, so a change anywhere in the workspace
+	// counts as code drift
+	hash := sha256.New()
+	var sources strings.Builder
+	for _, file := range ws.Files {
+		data, err := os.ReadFile(file.HostPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", file.Path, err)
+		}
+		fmt.Fprintf(hash, "%s\x00%o\x00%d\x00", file.Path, file.Mode, len(data))
+		hash.Write(data)
+		if slices.Contains(languageExtensions[language], path.Ext(file.Path)) {
+			sources.Write(data)
+			sources.WriteByte('\n')
+		}
+	}
+	ws.Hash = hex.EncodeToString(hash.Sum(nil))
+	ws.Sources = sources.String()
+	return ws, nil
+}
+
+// uploadWorkspace uploads a task's workspace to a session and returns the
+// reference its executions run the entrypoint from.
+func (d *ElideDriverPlugin) uploadWorkspace(ctx context.Context, sessionID string, ws *Workspace, entrypoint string) (*pb.WorkspaceRef, error) {
+	resp, err := d.daemonClient.UploadWorkspace(ctx, sessionID, ws.Hash, ws.Files)
+	if err != nil {
+		return nil, err
+	}
+	d.logger.Debug("uploaded workspace", "session_id", sessionID, "workspace_id", ws.Hash, "files", resp.FileCount, "bytes", resp.TotalBytes)
+	return &pb.WorkspaceRef{
+		WorkspaceId: ws.Hash,
+		Entrypoint:  path.Clean(filepath.ToSlash(entrypoint)),
+	}, nil
+}
+
+// sendWorkspace streams files in chunks of workspaceChunkSize; empty files
+// are sent as a single empty chunk. The first message names the session and
+// workspace.
+func sendWorkspace(send func(*pb.UploadWorkspaceRequest) error, sessionID string, workspaceID string, files []WorkspaceFile) error {
+	buf := make([]byte, workspaceChunkSize)
+	first := true
+	for _, file := range files {
+		f, err := os.Open(file.HostPath)
+		if err != nil {
+			return fmt.Errorf("failed to open %s: %w", file.Path, err)
+		}
+		sent := false
+		for {
+			n, readErr := io.ReadFull(f, buf)
+			if n > 0 || !sent {
+				req := &pb.UploadWorkspaceRequest{
+					Path: file.Path,
+					Data: buf[:n],
+					Mode: uint32(file.Mode),
+				}
+				if first {
+					req.SessionId, req.WorkspaceId = sessionID, workspaceID
+					first = false
+				}
+				if err := send(req); err != nil {
+					f.Close()
+					return err
+				}
+				sent = true
+			}
+			if errors.Is(readErr, io.EOF) || errors.Is(readErr, io.ErrUnexpectedEOF) {
+				break
+			}
+			if readErr != nil {
+				f.Close()
+				return fmt.Errorf("failed to read %s: %w", file.Path, readErr)
+			}
+		}
+		f.Close()
+	}
+	return nil
+}
@@ -4,18 +4,117 @@
 package main
 
 import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"syscall"
+
 	"github.com/elide-dev/elide-task-driver/driver"
 	"github.com/hashicorp/go-hclog"
 	"github.com/hashicorp/nomad/plugins"
 )
 
 func main() {
-	// Serve the plugin
+	server := flag.Bool("server", false, "serve the standalone execution API instead of the Nomad plugin")
+	listen := flag.String("listen", "/tmp/elide-driver-api.sock", "address of the standalone API: a Unix socket path, or a host:port on loopback unless -token-file is set")
+	configPath := flag.String("config", "", "plugin config file (HCL, or JSON with a .json extension) for the standalone API")
+	dataDir := flag.String("data-dir", filepath.Join(os.TempDir(), "elide-driver"), "directory holding the task directories of standalone API executions")
+	tokenFile := flag.String("token-file", "", "file holding the bearer token standalone API requests must carry (required to listen beyond loopback)")
+	retain := flag.Int("retain", 0, "finished standalone API executions kept for inspection, the oldest being forgotten beyond it (default 1000)")
+	flag.Parse()
+
+	if *server {
+		if err := serveAPI(*listen, *configPath, *dataDir, *tokenFile, *retain); err != nil {
+			log.Fatalf("server: %v", err)
+		}
+		return
+	}
+
+	// Serve the plugin. Serve returns once the Nomad client stopped the
+	// plugin, as it does when restarting; the tasks keep running and the next
+	// plugin instance recovers them
+	go detachOnSignal()
 	plugins.Serve(factory)
+	detach()
 }
 
+// served is the plugin instance being served
+var served atomic.Pointer[driver.ElideDriverPlugin]
+
 // factory returns a new instance of the Elide task driver plugin
 func factory(log hclog.Logger) interface{} {
-	return driver.NewPlugin(log)
+	p := driver.NewPlugin(log)
+	served.Store(p.(*driver.ElideDriverPlugin))
+	return p
+}
+
+// detach shuts the served plugin down, leaving the sessions of live tasks in
+// place for the next plugin instance.
+func detach() {
+	if p := served.Load(); p != nil {
+		p.Detach()
+	}
+}
+
+// detachOnSignal detaches the plugin when the process receives SIGTERM, then
+// lets the signal terminate the process as it would have.
+func detachOnSignal() {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGTERM)
+	<-sigCh
+
+	detach()
+	signal.Stop(sigCh)
+	_ = syscall.Kill(os.Getpid(), syscall.SIGTERM)
 }
 
+// serveAPI runs the driver outside of Nomad, exposing its execution logic
+// over HTTP until the process is interrupted.
+func serveAPI(address string, configPath string, dataDir string, tokenFile string, retain int) error {
+	logger := hclog.New(&hclog.LoggerOptions{Name: "elide", Level: hclog.LevelFromString(os.Getenv("ELIDE_LOG_LEVEL"))})
+
+	opts := driver.APIServerOptions{MaxRetained: retain}
+	if tokenFile != "" {
+		token, err := os.ReadFile(tokenFile)
+		if err != nil {
+			return fmt.Errorf("failed to read token: %w", err)
+		}
+		if opts.Token = strings.TrimSpace(string(token)); opts.Token == "" {
+			return fmt.Errorf("token file %s is empty", tokenFile)
+		}
+	}
+
+	cfg, err := driver.LoadConfigFile(configPath)
+	if err != nil {
+		return err
+	}
+	plugin := driver.NewPlugin(logger).(*driver.ElideDriverPlugin)
+	if err := plugin.SetConfig(cfg); err != nil {
+		return err
+	}
+	defer plugin.Shutdown()
+
+	api, err := driver.NewAPIServer(plugin, dataDir, opts)
+	if err != nil {
+		return err
+	}
+
+	lis, err := driver.ListenAPI(address, opts.Token != "")
+	if err != nil {
+		return err
+	}
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sigCh
+		api.Close()
+	}()
+
+	return api.Serve(lis)
+}
@@ -0,0 +1,722 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package conformance exercises the full ExecutionApi surface against an
+// arbitrary daemon endpoint (the stubbed server or a real Elide daemon) and
+// reports whether it behaves the way the driver relies on.
+package conformance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/elide-dev/elide-task-driver/proto/gen/go/elide/daemon/v1alpha1"
+)
+
+// Result is the outcome of a single conformance check
+type Result string
+
+const (
+	ResultPass Result = "PASS"
+	ResultFail Result = "FAIL"
+	ResultSkip Result = "SKIP"
+)
+
+// Level says how a failing check affects the driver
+type Level string
+
+const (
+	// LevelRequired checks cover semantics the driver depends on
+	LevelRequired Level = "required"
+
+	// LevelRecommended checks cover behavior the driver tolerates missing
+	LevelRecommended Level = "recommended"
+)
+
+// CheckResult is the outcome of one check
+type CheckResult struct {
+	Name     string
+	Level    Level
+	Result   Result
+	Detail   string
+	Duration time.Duration
+}
+
+// Report is the outcome of a conformance run
+type Report struct {
+	Checks []CheckResult
+}
+
+// Passed reports whether all required checks passed.
+func (r *Report) Passed() bool {
+	for _, c := range r.Checks {
+		if c.Level == LevelRequired && c.Result == ResultFail {
+			return false
+		}
+	}
+	return true
+}
+
+// Print writes a human readable summary of the report.
+func (r *Report) Print(w io.Writer) {
+	var pass, fail, skip int
+	for _, c := range r.Checks {
+		switch c.Result {
+		case ResultPass:
+			pass++
+		case ResultFail:
+			fail++
+		case ResultSkip:
+			skip++
+		}
+		fmt.Fprintf(w, "%-4s  %-11s  %-52s %8s", c.Result, c.Level, c.Name, c.Duration.Round(time.Millisecond))
+		if c.Detail != "" {
+			fmt.Fprintf(w, "  %s", c.Detail)
+		}
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintf(w, "\n%d passed, %d failed, %d skipped\n", pass, fail, skip)
+}
+
+// Options configures a conformance run
+type Options struct {
+	// Language used for test snippets (must be supported by the daemon)
+	Language string
+
+	// Code is a snippet that completes successfully and prints to stdout
+	Code string
+
+	// LongRunningCode is a snippet that runs long enough to be cancelled
+	LongRunningCode string
+
+	// CompletionTimeout bounds how long to wait for an execution to finish
+	CompletionTimeout time.Duration
+
+	// RPCTimeout bounds each individual RPC
+	RPCTimeout time.Duration
+}
+
+// DefaultOptions returns options suitable for the stubbed server and a daemon
+// with Python enabled.
+func DefaultOptions() Options {
+	return Options{
+		Language:          "python",
+		Code:              "print('conformance')",
+		LongRunningCode:   "import time\ntime.sleep(30)",
+		CompletionTimeout: 30 * time.Second,
+		RPCTimeout:        5 * time.Second,
+	}
+}
+
+// Dial connects to a daemon over a Unix socket or, if socketPath is empty,
+// over TCP.
+func Dial(socketPath string, address string) (*grpc.ClientConn, error) {
+	if socketPath != "" {
+		return grpc.Dial(
+			socketPath,
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+			grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", addr)
+			}),
+		)
+	}
+	if address != "" {
+		return grpc.Dial(address, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	}
+	return nil, errors.New("either a socket path or an address must be specified")
+}
+
+// suite holds the state shared by the checks of one run
+type suite struct {
+	client    pb.ExecutionApiClient
+	opts      Options
+	report    *Report
+	sessionID string
+	config    *pb.SessionConfiguration
+	sessionOK bool
+}
+
+// Run executes all conformance checks against the given client. Checks that
+// depend on an earlier failed check are skipped.
+func Run(ctx context.Context, client pb.ExecutionApiClient, opts Options) *Report {
+	s := &suite{
+		client:    client,
+		opts:      opts,
+		report:    &Report{},
+		sessionID: fmt.Sprintf("conformance-%d", time.Now().UnixNano()),
+		config: &pb.SessionConfiguration{
+			ContextPoolSize:   2,
+			EnabledLanguages:  []string{opts.Language},
+			EnabledIntrinsics: []string{"io", "env"},
+			MemoryLimitMb:     256,
+		},
+	}
+
+	s.check(ctx, "Health reports healthy", LevelRequired, s.checkHealth)
+	s.check(ctx, "CreateSession creates an active session", LevelRequired, s.checkCreateSession)
+	s.check(ctx, "GetSession returns the session configuration", LevelRequired, s.needsSession(s.checkGetSession))
+	s.check(ctx, "GetSession fails for an unknown session", LevelRecommended, s.checkGetUnknownSession)
+	s.check(ctx, "ExecuteSnippet runs to completion with output", LevelRequired, s.needsSession(s.checkExecute))
+	s.check(ctx, "ExecuteSnippet fails for an unknown session", LevelRequired, s.checkExecuteUnknownSession)
+	s.check(ctx, "WatchExecution streams status until completion", LevelRecommended, s.needsSession(s.checkWatch))
+	s.check(ctx, "GetExecutionStatus fails for an unknown execution", LevelRequired, s.needsSession(s.checkUnknownExecution))
+	s.check(ctx, "GetExecutionStatus honours include_output and output offsets", LevelRecommended, s.needsSession(s.checkOutputOffsets))
+	s.check(ctx, "CancelExecution stops a running execution", LevelRequired, s.needsSession(s.checkCancel))
+	s.check(ctx, "CancelExecution of a completed execution is safe", LevelRecommended, s.needsSession(s.checkCancelCompleted))
+	s.check(ctx, "CancelExecution is idempotent by cancel token", LevelRecommended, s.needsSession(s.checkCancelToken))
+	s.check(ctx, "CancelExecution fails for an unknown execution", LevelRecommended, s.needsSession(s.checkCancelUnknown))
+	s.check(ctx, "KillExecution stops a running execution", LevelRecommended, s.needsSession(s.checkKill))
+	s.check(ctx, "SignalExecution delivers a named signal", LevelRecommended, s.needsSession(s.checkSignal))
+	s.check(ctx, "ExecInSession returns the output of a snippet", LevelRecommended, s.needsSession(s.checkExecInSession))
+	s.check(ctx, "RecycleContexts keeps the session usable", LevelRecommended, s.needsSession(s.checkRecycle))
+	s.check(ctx, "WatchSessionEvents reports execution completion", LevelRecommended, s.needsSession(s.checkSessionEvents))
+	s.check(ctx, "DeleteSession removes the session", LevelRequired, s.needsSession(s.checkDeleteSession))
+
+	return s.report
+}
+
+// errSkip marks a check as skipped; its message is reported as the detail
+type errSkip struct{ reason string }
+
+func (e errSkip) Error() string { return e.reason }
+
+func (s *suite) check(ctx context.Context, name string, level Level, fn func(ctx context.Context) error) {
+	start := time.Now()
+	err := fn(ctx)
+	result := CheckResult{Name: name, Level: level, Result: ResultPass, Duration: time.Since(start)}
+
+	var skip errSkip
+	switch {
+	case errors.As(err, &skip):
+		result.Result = ResultSkip
+		result.Detail = skip.reason
+	case err != nil:
+		result.Result = ResultFail
+		result.Detail = err.Error()
+	}
+	s.report.Checks = append(s.report.Checks, result)
+}
+
+func (s *suite) needsSession(fn func(ctx context.Context) error) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		if !s.sessionOK {
+			return errSkip{"no session available"}
+		}
+		return fn(ctx)
+	}
+}
+
+func (s *suite) rpcCtx(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, s.opts.RPCTimeout)
+}
+
+func (s *suite) checkHealth(ctx context.Context) error {
+	ctx, cancel := s.rpcCtx(ctx)
+	defer cancel()
+	resp, err := s.client.Health(ctx, &pb.HealthRequest{})
+	if err != nil {
+		return err
+	}
+	if !resp.Healthy {
+		return errors.New("daemon reports unhealthy")
+	}
+	if resp.Version == "" {
+		return errors.New("daemon does not report a version")
+	}
+	return nil
+}
+
+func (s *suite) checkCreateSession(ctx context.Context) error {
+	ctx, cancel := s.rpcCtx(ctx)
+	defer cancel()
+	resp, err := s.client.CreateSession(ctx, &pb.CreateSessionRequest{SessionId: s.sessionID, Config: s.config})
+	if err != nil {
+		return err
+	}
+	if resp.SessionId != s.sessionID {
+		return fmt.Errorf("session ID %q does not match requested %q", resp.SessionId, s.sessionID)
+	}
+	if resp.Status != pb.SessionStatus_SESSION_STATUS_ACTIVE {
+		return fmt.Errorf("session status is %s, expected ACTIVE", resp.Status)
+	}
+	s.sessionOK = true
+	return nil
+}
+
+func (s *suite) checkGetSession(ctx context.Context) error {
+	ctx, cancel := s.rpcCtx(ctx)
+	defer cancel()
+	resp, err := s.client.GetSession(ctx, &pb.GetSessionRequest{SessionId: s.sessionID})
+	if err != nil {
+		return err
+	}
+	if resp.Status != pb.SessionStatus_SESSION_STATUS_ACTIVE {
+		return fmt.Errorf("session status is %s, expected ACTIVE", resp.Status)
+	}
+	if resp.Config == nil {
+		return errors.New("session configuration not returned")
+	}
+	if strings.Join(resp.Config.EnabledLanguages, ",") != strings.Join(s.config.EnabledLanguages, ",") {
+		return fmt.Errorf("enabled languages %v do not match requested %v", resp.Config.EnabledLanguages, s.config.EnabledLanguages)
+	}
+	return nil
+}
+
+func (s *suite) checkGetUnknownSession(ctx context.Context) error {
+	ctx, cancel := s.rpcCtx(ctx)
+	defer cancel()
+	if _, err := s.client.GetSession(ctx, &pb.GetSessionRequest{SessionId: s.sessionID + "-unknown"}); err == nil {
+		return errors.New("expected an error for an unknown session")
+	}
+	return nil
+}
+
+func (s *suite) checkExecute(ctx context.Context) error {
+	executionID := s.sessionID + "-exec"
+	if err := s.execute(ctx, executionID, s.opts.Code); err != nil {
+		return err
+	}
+
+	status, err := s.waitComplete(ctx, executionID)
+	if err != nil {
+		return err
+	}
+	if status.Status != pb.ExecutionStatus_EXECUTION_STATUS_COMPLETED {
+		return fmt.Errorf("execution finished as %s, expected COMPLETED", status.Status)
+	}
+	if status.ExitCode != 0 {
+		return fmt.Errorf("exit code %d, expected 0", status.ExitCode)
+	}
+	if stdoutOf(status) == "" {
+		return errors.New("no stdout captured")
+	}
+	return nil
+}
+
+func (s *suite) checkWatch(ctx context.Context) error {
+	executionID := s.sessionID + "-watch"
+	if err := s.execute(ctx, executionID, s.opts.Code); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.opts.CompletionTimeout)
+	defer cancel()
+	stream, err := s.client.WatchExecution(ctx, &pb.WatchExecutionRequest{SessionId: s.sessionID, ExecutionId: executionID})
+	if err != nil {
+		return err
+	}
+
+	var last *pb.GetExecutionStatusResponse
+	for {
+		update, err := stream.Recv()
+		if status.Code(err) == codes.Unimplemented {
+			return errSkip{"WatchExecution not implemented"}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if update.ExecutionId != executionID {
+			return fmt.Errorf("update for execution %q, expected %q", update.ExecutionId, executionID)
+		}
+		last = update
+	}
+	if last == nil {
+		return errors.New("stream ended without a status")
+	}
+	if !last.Complete {
+		return fmt.Errorf("stream ended with status %s before the execution completed", last.Status)
+	}
+	return nil
+}
+
+func (s *suite) checkExecuteUnknownSession(ctx context.Context) error {
+	ctx, cancel := s.rpcCtx(ctx)
+	defer cancel()
+	_, err := s.client.ExecuteSnippet(ctx, &pb.ExecuteSnippetRequest{
+		SessionId:   s.sessionID + "-unknown",
+		ExecutionId: s.sessionID + "-orphan",
+		Code:        s.opts.Code,
+		Language:    s.opts.Language,
+	})
+	if err == nil {
+		return errors.New("expected an error for an unknown session")
+	}
+	return nil
+}
+
+func (s *suite) checkUnknownExecution(ctx context.Context) error {
+	ctx, cancel := s.rpcCtx(ctx)
+	defer cancel()
+	_, err := s.client.GetExecutionStatus(ctx, &pb.GetExecutionStatusRequest{
+		SessionId:   s.sessionID,
+		ExecutionId: s.sessionID + "-unknown",
+	})
+	if err == nil {
+		return errors.New("expected an error for an unknown execution")
+	}
+	return nil
+}
+
+func (s *suite) checkOutputOffsets(ctx context.Context) error {
+	healthCtx, healthCancel := s.rpcCtx(ctx)
+	health, err := s.client.Health(healthCtx, &pb.HealthRequest{})
+	healthCancel()
+	if err != nil {
+		return err
+	}
+	if !health.SupportsOutputOffsets {
+		return errSkip{"daemon does not report supports_output_offsets"}
+	}
+
+	executionID := s.sessionID + "-offsets"
+	if err := s.execute(ctx, executionID, s.opts.Code); err != nil {
+		return err
+	}
+	full, err := s.waitComplete(ctx, executionID)
+	if err != nil {
+		return err
+	}
+	fullStdout := stdoutOf(full)
+	if len(fullStdout) < 2 {
+		return fmt.Errorf("stdout %q too short to check offsets", fullStdout)
+	}
+
+	includeOutput := false
+	omitCtx, omitCancel := s.rpcCtx(ctx)
+	omitted, err := s.client.GetExecutionStatus(omitCtx, &pb.GetExecutionStatusRequest{SessionId: s.sessionID, ExecutionId: executionID, IncludeOutput: &includeOutput})
+	omitCancel()
+	if err != nil {
+		return err
+	}
+	if omitted.Stdout != "" || omitted.Stderr != "" || len(omitted.StdoutRaw) > 0 || len(omitted.StderrRaw) > 0 {
+		return errors.New("output returned with include_output = false")
+	}
+
+	offsetCtx, offsetCancel := s.rpcCtx(ctx)
+	defer offsetCancel()
+	tail, err := s.client.GetExecutionStatus(offsetCtx, &pb.GetExecutionStatusRequest{SessionId: s.sessionID, ExecutionId: executionID, StdoutOffset: 1})
+	if err != nil {
+		return err
+	}
+	if stdoutOf(tail) != fullStdout[1:] {
+		return fmt.Errorf("stdout from offset 1 is %q, expected %q", stdoutOf(tail), fullStdout[1:])
+	}
+	return nil
+}
+
+// stdoutOf returns the stdout of a status, sent as text or, if it is not
+// valid UTF-8, as raw bytes.
+func stdoutOf(status *pb.GetExecutionStatusResponse) string {
+	if len(status.StdoutRaw) > 0 {
+		return string(status.StdoutRaw)
+	}
+	return status.Stdout
+}
+
+func (s *suite) checkCancel(ctx context.Context) error {
+	executionID := s.sessionID + "-cancel"
+	if err := s.execute(ctx, executionID, s.opts.LongRunningCode); err != nil {
+		return err
+	}
+
+	cancelCtx, cancel := s.rpcCtx(ctx)
+	resp, err := s.client.CancelExecution(cancelCtx, &pb.CancelExecutionRequest{SessionId: s.sessionID, ExecutionId: executionID})
+	cancel()
+	if err != nil {
+		return err
+	}
+	if !resp.Success {
+		return errors.New("cancellation of a running execution was not acknowledged")
+	}
+
+	status, err := s.waitComplete(ctx, executionID)
+	if err != nil {
+		return err
+	}
+	if status.Status != pb.ExecutionStatus_EXECUTION_STATUS_CANCELLED {
+		return fmt.Errorf("execution finished as %s, expected CANCELLED", status.Status)
+	}
+	return nil
+}
+
+func (s *suite) checkCancelCompleted(ctx context.Context) error {
+	executionID := s.sessionID + "-exec"
+	cancelCtx, cancel := s.rpcCtx(ctx)
+	defer cancel()
+	if _, err := s.client.CancelExecution(cancelCtx, &pb.CancelExecutionRequest{SessionId: s.sessionID, ExecutionId: executionID}); err != nil {
+		return fmt.Errorf("cancelling a completed execution returned an error: %w", err)
+	}
+
+	statusCtx, statusCancel := s.rpcCtx(ctx)
+	defer statusCancel()
+	status, err := s.client.GetExecutionStatus(statusCtx, &pb.GetExecutionStatusRequest{SessionId: s.sessionID, ExecutionId: executionID})
+	if err != nil {
+		return err
+	}
+	if status.Status != pb.ExecutionStatus_EXECUTION_STATUS_COMPLETED {
+		return fmt.Errorf("completed execution changed to %s after cancellation", status.Status)
+	}
+	return nil
+}
+
+func (s *suite) checkCancelToken(ctx context.Context) error {
+	executionID := s.sessionID + "-cancel-token"
+	if err := s.execute(ctx, executionID, s.opts.LongRunningCode); err != nil {
+		return err
+	}
+
+	req := &pb.CancelExecutionRequest{SessionId: s.sessionID, ExecutionId: executionID, CancelToken: executionID + "/conformance"}
+	for attempt := 1; attempt <= 2; attempt++ {
+		cancelCtx, cancel := s.rpcCtx(ctx)
+		resp, err := s.client.CancelExecution(cancelCtx, req)
+		cancel()
+		if err != nil {
+			return fmt.Errorf("attempt %d: %w", attempt, err)
+		}
+		if !resp.Success {
+			return fmt.Errorf("attempt %d with the same cancel token was not acknowledged", attempt)
+		}
+	}
+
+	cancelCtx, cancel := s.rpcCtx(ctx)
+	defer cancel()
+	resp, err := s.client.CancelExecution(cancelCtx, &pb.CancelExecutionRequest{SessionId: s.sessionID, ExecutionId: executionID})
+	if err != nil {
+		return err
+	}
+	if resp.Success {
+		return errors.New("cancellation without the token reported the cancelled execution as cancelled again")
+	}
+	return nil
+}
+
+func (s *suite) checkCancelUnknown(ctx context.Context) error {
+	cancelCtx, cancel := s.rpcCtx(ctx)
+	defer cancel()
+	_, err := s.client.CancelExecution(cancelCtx, &pb.CancelExecutionRequest{SessionId: s.sessionID, ExecutionId: s.sessionID + "-unknown"})
+	if code := status.Code(err); code != codes.NotFound {
+		return fmt.Errorf("expected NOT_FOUND, got %s", code)
+	}
+	return nil
+}
+
+func (s *suite) checkKill(ctx context.Context) error {
+	executionID := s.sessionID + "-kill"
+	if err := s.execute(ctx, executionID, s.opts.LongRunningCode); err != nil {
+		return err
+	}
+
+	killCtx, cancel := s.rpcCtx(ctx)
+	resp, err := s.client.KillExecution(killCtx, &pb.KillExecutionRequest{SessionId: s.sessionID, ExecutionId: executionID, Initiator: "conformance"})
+	cancel()
+	if status.Code(err) == codes.Unimplemented {
+		cancelCtx, cancel := s.rpcCtx(ctx)
+		defer cancel()
+		_, _ = s.client.CancelExecution(cancelCtx, &pb.CancelExecutionRequest{SessionId: s.sessionID, ExecutionId: executionID})
+		return errSkip{"KillExecution not implemented"}
+	}
+	if err != nil {
+		return err
+	}
+	if !resp.Success {
+		return errors.New("kill of a running execution was not acknowledged")
+	}
+
+	status, err := s.waitComplete(ctx, executionID)
+	if err != nil {
+		return err
+	}
+	if status.Status != pb.ExecutionStatus_EXECUTION_STATUS_CANCELLED {
+		return fmt.Errorf("execution finished as %s, expected CANCELLED", status.Status)
+	}
+	return nil
+}
+
+func (s *suite) checkExecInSession(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, s.opts.CompletionTimeout)
+	defer cancel()
+	resp, err := s.client.ExecInSession(ctx, &pb.ExecInSessionRequest{
+		SessionId: s.sessionID,
+		Code:      s.opts.Code,
+		Language:  s.opts.Language,
+		TimeoutMs: uint64(s.opts.CompletionTimeout.Milliseconds()),
+	})
+	if status.Code(err) == codes.Unimplemented {
+		return errSkip{"ExecInSession not implemented"}
+	}
+	if err != nil {
+		return err
+	}
+	if resp.Error != "" || resp.TimedOut {
+		return fmt.Errorf("snippet failed to run (timed out: %t): %s", resp.TimedOut, resp.Error)
+	}
+	if resp.ExitCode != 0 {
+		return fmt.Errorf("exit code %d, expected 0", resp.ExitCode)
+	}
+	if resp.Stdout == "" && len(resp.StdoutRaw) == 0 {
+		return errors.New("no stdout returned")
+	}
+	return nil
+}
+
+func (s *suite) checkSignal(ctx context.Context) error {
+	executionID := s.sessionID + "-signal"
+	if err := s.execute(ctx, executionID, s.opts.LongRunningCode); err != nil {
+		return err
+	}
+	defer func() {
+		cancelCtx, cancel := s.rpcCtx(ctx)
+		defer cancel()
+		_, _ = s.client.CancelExecution(cancelCtx, &pb.CancelExecutionRequest{SessionId: s.sessionID, ExecutionId: executionID})
+	}()
+
+	signalCtx, cancel := s.rpcCtx(ctx)
+	defer cancel()
+	resp, err := s.client.SignalExecution(signalCtx, &pb.SignalExecutionRequest{SessionId: s.sessionID, ExecutionId: executionID, NamedSignal: "conformance-check"})
+	if status.Code(err) == codes.Unimplemented {
+		return errSkip{"SignalExecution not implemented"}
+	}
+	if err != nil {
+		return err
+	}
+	if !resp.Delivered {
+		return errors.New("signal to a running execution was not delivered")
+	}
+	return nil
+}
+
+func (s *suite) checkRecycle(ctx context.Context) error {
+	recycleCtx, cancel := s.rpcCtx(ctx)
+	defer cancel()
+	_, err := s.client.RecycleContexts(recycleCtx, &pb.RecycleContextsRequest{SessionId: s.sessionID})
+	if status.Code(err) == codes.Unimplemented {
+		return errSkip{"RecycleContexts not implemented"}
+	}
+	if err != nil {
+		return err
+	}
+
+	getCtx, cancel := s.rpcCtx(ctx)
+	defer cancel()
+	resp, err := s.client.GetSession(getCtx, &pb.GetSessionRequest{SessionId: s.sessionID})
+	if err != nil {
+		return err
+	}
+	if resp.Status != pb.SessionStatus_SESSION_STATUS_ACTIVE {
+		return fmt.Errorf("session is %s after recycling its contexts", resp.Status)
+	}
+	return nil
+}
+
+func (s *suite) checkSessionEvents(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, s.opts.CompletionTimeout)
+	defer cancel()
+	stream, err := s.client.WatchSessionEvents(ctx, &pb.WatchSessionEventsRequest{SessionId: s.sessionID})
+	if err != nil {
+		return err
+	}
+
+	executionID := s.sessionID + "-events"
+	if err := s.execute(ctx, executionID, s.opts.Code); err != nil {
+		return err
+	}
+
+	for {
+		event, err := stream.Recv()
+		if status.Code(err) == codes.Unimplemented {
+			return errSkip{"WatchSessionEvents not implemented"}
+		}
+		if err != nil {
+			return fmt.Errorf("no completion event for the execution: %w", err)
+		}
+		if event.SessionId != s.sessionID {
+			return fmt.Errorf("event for session %q, expected %q", event.SessionId, s.sessionID)
+		}
+		if event.ExecutionId != executionID {
+			continue
+		}
+		switch event.Type {
+		case pb.SessionEventType_SESSION_EVENT_TYPE_EXECUTION_COMPLETED,
+			pb.SessionEventType_SESSION_EVENT_TYPE_EXECUTION_FAILED,
+			pb.SessionEventType_SESSION_EVENT_TYPE_EXECUTION_CANCELLED:
+			return nil
+		}
+	}
+}
+
+func (s *suite) checkDeleteSession(ctx context.Context) error {
+	deleteCtx, cancel := s.rpcCtx(ctx)
+	defer cancel()
+	resp, err := s.client.DeleteSession(deleteCtx, &pb.DeleteSessionRequest{SessionId: s.sessionID})
+	if err != nil {
+		return err
+	}
+	if !resp.Success {
+		return errors.New("deletion not acknowledged")
+	}
+
+	getCtx, getCancel := s.rpcCtx(ctx)
+	defer getCancel()
+	if got, err := s.client.GetSession(getCtx, &pb.GetSessionRequest{SessionId: s.sessionID}); err == nil && got.Status == pb.SessionStatus_SESSION_STATUS_ACTIVE {
+		return errors.New("session is still active after deletion")
+	}
+	s.sessionOK = false
+	return nil
+}
+
+func (s *suite) execute(ctx context.Context, executionID string, code string) error {
+	ctx, cancel := s.rpcCtx(ctx)
+	defer cancel()
+	resp, err := s.client.ExecuteSnippet(ctx, &pb.ExecuteSnippetRequest{
+		SessionId:   s.sessionID,
+		ExecutionId: executionID,
+		Code:        code,
+		Language:    s.opts.Language,
+		Env:         map[string]string{"CONFORMANCE": "1"},
+	})
+	if err != nil {
+		return err
+	}
+	if resp.ExecutionId != executionID {
+		return fmt.Errorf("execution ID %q does not match requested %q", resp.ExecutionId, executionID)
+	}
+	return nil
+}
+
+func (s *suite) waitComplete(ctx context.Context, executionID string) (*pb.GetExecutionStatusResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.opts.CompletionTimeout)
+	defer cancel()
+
+	ticker := time.NewTicker(250 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		statusCtx, statusCancel := s.rpcCtx(ctx)
+		status, err := s.client.GetExecutionStatus(statusCtx, &pb.GetExecutionStatusRequest{SessionId: s.sessionID, ExecutionId: executionID})
+		statusCancel()
+		if err != nil {
+			return nil, err
+		}
+		if status.Complete {
+			return status, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("execution did not complete within %s (last status %s)", s.opts.CompletionTimeout, status.Status)
+		case <-ticker.C:
+		}
+	}
+}
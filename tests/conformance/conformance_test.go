@@ -0,0 +1,41 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package conformance
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	pb "github.com/elide-dev/elide-task-driver/proto/gen/go/elide/daemon/v1alpha1"
+)
+
+// TestConformance runs the suite against the daemon given by
+// ELIDE_CONFORMANCE_SOCKET or ELIDE_CONFORMANCE_ADDRESS, e.g.
+//
+//	ELIDE_CONFORMANCE_SOCKET=/tmp/elide-daemon.sock go test ./tests/conformance/...
+func TestConformance(t *testing.T) {
+	socketPath := os.Getenv("ELIDE_CONFORMANCE_SOCKET")
+	address := os.Getenv("ELIDE_CONFORMANCE_ADDRESS")
+	if socketPath == "" && address == "" {
+		t.Skip("set ELIDE_CONFORMANCE_SOCKET or ELIDE_CONFORMANCE_ADDRESS to run the conformance suite")
+	}
+
+	conn, err := Dial(socketPath, address)
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer conn.Close()
+
+	report := Run(context.Background(), pb.NewExecutionApiClient(conn), DefaultOptions())
+
+	var out strings.Builder
+	report.Print(&out)
+	t.Log("\n" + out.String())
+
+	if !report.Passed() {
+		t.Fatal("daemon failed required conformance checks")
+	}
+}
@@ -0,0 +1,169 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package helpers
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/nomad/helper/pluginutils/hclutils"
+	"github.com/hashicorp/nomad/plugins/shared/hclspec"
+	"github.com/stretchr/testify/require"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+
+	"github.com/elide-dev/elide-task-driver/driver"
+)
+
+// TaskConfigSpec returns the driver's task config schema.
+func TaskConfigSpec(t *testing.T) *hclspec.Spec {
+	t.Helper()
+	spec, err := driver.NewPlugin(hclog.NewNullLogger()).TaskConfigSchema()
+	require.NoError(t, err)
+	return spec
+}
+
+// ParseTaskConfig decodes the config block of a task stanza the way Nomad
+// does: the stanza is parsed as HCL2 into the task's config map, as the job
+// is submitted, which is evaluated against the driver's task config schema,
+// defaults included, encoded to MsgPack and decoded into a TaskConfig. The
+// config block may be nested in task, group or job blocks:
+//
+//	task "hello" {
+//	  driver = "elide"
+//	  config {
+//	    code = "print('hello')"
+//	  }
+//	}
+func ParseTaskConfig(t *testing.T, stanza string) *driver.TaskConfig {
+	t.Helper()
+	file, diags := hclsyntax.ParseConfig([]byte(stanza), "stanza.hcl", hcl.InitialPos)
+	require.False(t, diags.HasErrors(), diags.Error())
+
+	config := findConfigBlock(file.Body.(*hclsyntax.Body))
+	require.NotNil(t, config, "stanza has no config block")
+	task, err := json.Marshal(map[string]any{"Config": bodyToMap(t, config.Body)})
+	require.NoError(t, err)
+	return ParseTaskConfigJSON(t, string(task))
+}
+
+// ParseTaskConfigJSON is ParseTaskConfig for a task in JSON job format,
+// holding the config under "Config".
+func ParseTaskConfigJSON(t *testing.T, task string) *driver.TaskConfig {
+	t.Helper()
+	var tc driver.TaskConfig
+	hclutils.NewConfigParser(TaskConfigSpec(t)).ParseJson(t, task, &tc)
+	return &tc
+}
+
+// findConfigBlock returns the first config block in body or its blocks.
+func findConfigBlock(body *hclsyntax.Body) *hclsyntax.Block {
+	for _, block := range body.Blocks {
+		if block.Type == "config" {
+			return block
+		}
+		if config := findConfigBlock(block.Body); config != nil {
+			return config
+		}
+	}
+	return nil
+}
+
+// bodyToMap converts an HCL2 body to the config map of a submitted job:
+// attributes by name, and blocks as lists of maps by type.
+func bodyToMap(t *testing.T, body *hclsyntax.Body) map[string]any {
+	t.Helper()
+	m := map[string]any{}
+	for name, attr := range body.Attributes {
+		value, diags := attr.Expr.Value(nil)
+		require.False(t, diags.HasErrors(), diags.Error())
+		data, err := ctyjson.Marshal(value, value.Type())
+		require.NoError(t, err)
+		var v any
+		require.NoError(t, json.Unmarshal(data, &v))
+		m[name] = v
+	}
+	for _, block := range body.Blocks {
+		blocks, _ := m[block.Type].([]map[string]any)
+		m[block.Type] = append(blocks, bodyToMap(t, block.Body))
+	}
+	return m
+}
+
+// SpecFields returns the dotted names of the attributes and blocks of an
+// hclspec object, including those nested in blocks, sorted.
+func SpecFields(spec *hclspec.Spec) []string {
+	var fields []string
+	specFields("", spec, &fields)
+	sort.Strings(fields)
+	return fields
+}
+
+func specFields(prefix string, spec *hclspec.Spec, fields *[]string) {
+	for name, child := range spec.GetObject().GetAttributes() {
+		specField(prefix+name, child, fields)
+	}
+}
+
+func specField(name string, spec *hclspec.Spec, fields *[]string) {
+	if spec.GetDefault() != nil {
+		specField(name, spec.GetDefault().GetPrimary(), fields)
+		return
+	}
+	*fields = append(*fields, name)
+	for _, nested := range []*hclspec.Spec{
+		spec.GetBlockValue().GetNested(),
+		spec.GetBlockList().GetNested(),
+		spec.GetBlockSet().GetNested(),
+		spec.GetBlockMap().GetNested(),
+	} {
+		if nested != nil {
+			specFields(name+".", nested, fields)
+		}
+	}
+}
+
+// CodecFields returns the dotted names a struct is decoded from: the codec
+// tags of its fields, including those of nested structs, sorted. Fields
+// without a codec tag are listed by their Go name, which no spec attribute
+// matches.
+func CodecFields(v any) []string {
+	var fields []string
+	codecFields("", reflect.TypeOf(v), &fields)
+	sort.Strings(fields)
+	return fields
+}
+
+func codecFields(prefix string, typ reflect.Type, fields *[]string) {
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	for i := range typ.NumField() {
+		field := typ.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("codec"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		*fields = append(*fields, prefix+name)
+
+		nested := field.Type
+		for nested.Kind() == reflect.Pointer {
+			nested = nested.Elem()
+		}
+		if nested.Kind() == reflect.Struct {
+			codecFields(prefix+name+".", nested, fields)
+		}
+	}
+}
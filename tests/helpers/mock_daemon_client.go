@@ -6,8 +6,14 @@ package helpers
 import (
 	"context"
 	"errors"
+	"fmt"
+	"io"
+	"os"
 	"time"
 
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
 	"github.com/elide-dev/elide-task-driver/driver"
 	pb "github.com/elide-dev/elide-task-driver/proto/gen/go/elide/daemon/v1alpha1"
 )
@@ -16,11 +22,28 @@ import (
 type MockDaemonClient struct {
 	sessions      map[string]*pb.SessionConfiguration
 	executions    map[string]*MockExecution
+	values        map[string]string
 	createErr     error
+	createFails   int  // number of upcoming CreateSession calls failing with createFailErr
+	createFailErr error
+	conflicts     bool // CreateSession of an existing session fails with AlreadyExists
+	createCalls   int
+	getCalls      int
 	executeErr    error
 	statusErr     error
 	cancelErr     error
+	watchErr      error
+	signalErr     error
+	execErr       error
+	recycleErr    error
+	recycles      map[string]int
+	eventsErr     error
+	events        chan *pb.SessionEvent
 	healthErr     error
+	workspaces    map[string]map[string]string
+
+	// Reconnects counts the requests to re-dial the daemon
+	Reconnects int
 }
 
 // MockExecution represents a mock execution
@@ -32,6 +55,54 @@ type MockExecution struct {
 	ExitCode    int32
 	Error       string
 	StartedAt   time.Time
+	Limits      *pb.ExecutionLimits
+
+	// Overrides records the per-task overrides the driver sent
+	Overrides *pb.ExecutionOverrides
+
+	// Topology records the placement hints the driver sent
+	Topology *pb.TopologyHints
+
+	// RunAs records the OS user the driver asked to run the execution as
+	RunAs string
+
+	// Mounts records the mounts the driver sent
+	Mounts []*pb.Mount
+
+	// Workspace records the workspace the driver asked to run from
+	Workspace *pb.WorkspaceRef
+
+	// StdinOpen records whether the driver asked to stream stdin, and
+	// Stdin what it streamed
+	StdinOpen bool
+	Stdin     []byte
+
+	// Ports records the port bindings the driver requested
+	Ports []*pb.PortBinding
+
+	// DiscardOutput records whether the driver asked not to capture output
+	DiscardOutput bool
+
+	// Tags records the tags the driver sent with the execution
+	Tags map[string]string
+
+	// CompletionWait records how long the driver let the daemon wait for
+	// the execution to complete before responding
+	CompletionWait time.Duration
+
+	CancellationReason pb.CancellationReason
+	CancelledBy        string
+	CancelToken        string
+
+	// Killed records whether the execution was force-killed
+	Killed bool
+
+	// Signals records the signals delivered to the execution; named
+	// signals are prefixed with "named:"
+	Signals []string
+
+	// StatusOutputs records the output requested with each status poll
+	StatusOutputs []driver.OutputRequest
 }
 
 // NewMockDaemonClient creates a new mock daemon client
@@ -39,14 +110,25 @@ func NewMockDaemonClient() *MockDaemonClient {
 	return &MockDaemonClient{
 		sessions:   make(map[string]*pb.SessionConfiguration),
 		executions: make(map[string]*MockExecution),
+		values:     make(map[string]string),
+		workspaces: make(map[string]map[string]string),
+		events:     make(chan *pb.SessionEvent, 16),
 	}
 }
 
 // CreateSession creates a mock session
 func (m *MockDaemonClient) CreateSession(ctx context.Context, sessionID string, config *pb.SessionConfiguration) (*pb.CreateSessionResponse, error) {
+	m.createCalls++
+	if m.createFails > 0 {
+		m.createFails--
+		return nil, m.createFailErr
+	}
 	if m.createErr != nil {
 		return nil, m.createErr
 	}
+	if _, ok := m.sessions[sessionID]; ok && m.conflicts {
+		return nil, fmt.Errorf("failed to create session: %w", status.Errorf(codes.AlreadyExists, "session %s already exists", sessionID))
+	}
 
 	m.sessions[sessionID] = config
 	return &pb.CreateSessionResponse{
@@ -57,6 +139,7 @@ func (m *MockDaemonClient) CreateSession(ctx context.Context, sessionID string,
 
 // GetSession gets a mock session
 func (m *MockDaemonClient) GetSession(ctx context.Context, sessionID string) (*pb.GetSessionResponse, error) {
+	m.getCalls++
 	config, ok := m.sessions[sessionID]
 	if !ok {
 		return nil, errors.New("session not found")
@@ -75,30 +158,102 @@ func (m *MockDaemonClient) DeleteSession(ctx context.Context, sessionID string)
 	return nil
 }
 
+// RecycleContexts counts a mock context recycle of the session
+func (m *MockDaemonClient) RecycleContexts(ctx context.Context, sessionID string) (*pb.RecycleContextsResponse, error) {
+	if m.recycleErr != nil {
+		return nil, m.recycleErr
+	}
+	if _, ok := m.sessions[sessionID]; !ok {
+		return nil, errors.New("session not found")
+	}
+	if m.recycles == nil {
+		m.recycles = make(map[string]int)
+	}
+	m.recycles[sessionID]++
+	return &pb.RecycleContextsResponse{Recycled: m.sessions[sessionID].GetContextPoolSize()}, nil
+}
+
 // ExecuteSnippet executes a mock snippet
-func (m *MockDaemonClient) ExecuteSnippet(ctx context.Context, sessionID string, executionID string, code string, language string, env map[string]string, args []string) (*pb.ExecuteSnippetResponse, error) {
+func (m *MockDaemonClient) ExecuteSnippet(ctx context.Context, req driver.ExecuteSnippetRequest) (*pb.ExecuteSnippetResponse, error) {
 	if m.executeErr != nil {
 		return nil, m.executeErr
 	}
 
 	exec := &MockExecution{
-		ExecutionID: executionID,
-		SessionID:   sessionID,
+		ExecutionID: req.ExecutionID,
+		SessionID:   req.SessionID,
 		Status:      pb.ExecutionStatus_EXECUTION_STATUS_RUNNING,
 		Complete:    false,
 		StartedAt:   time.Now(),
+		Limits:      req.Limits,
+
+		Overrides:     req.Overrides,
+		Topology:      req.Topology,
+		RunAs:         req.RunAs,
+		Mounts:        req.Mounts,
+		Workspace:     req.Workspace,
+		StdinOpen:     req.Stdin,
+		Ports:         req.Ports,
+		DiscardOutput: req.DiscardOutput,
+		Tags:          req.Tags,
+
+		CompletionWait: req.CompletionWait,
 	}
 
-	m.executions[executionID] = exec
+	m.executions[req.ExecutionID] = exec
 
 	return &pb.ExecuteSnippetResponse{
-		ExecutionId: executionID,
+		ExecutionId: req.ExecutionID,
 		Status:      pb.ExecutionStatus_EXECUTION_STATUS_RUNNING,
+		Ports:       req.Ports,
 	}, nil
 }
 
+// UploadWorkspace records the contents of the uploaded files
+func (m *MockDaemonClient) UploadWorkspace(ctx context.Context, sessionID string, workspaceID string, files []driver.WorkspaceFile) (*pb.UploadWorkspaceResponse, error) {
+	if _, ok := m.sessions[sessionID]; !ok {
+		return nil, errors.New("session not found")
+	}
+
+	contents := make(map[string]string, len(files))
+	var total uint64
+	for _, file := range files {
+		data, err := os.ReadFile(file.HostPath)
+		if err != nil {
+			return nil, err
+		}
+		contents[file.Path] = string(data)
+		total += uint64(len(data))
+	}
+	m.workspaces[workspaceID] = contents
+	return &pb.UploadWorkspaceResponse{WorkspaceId: workspaceID, FileCount: uint32(len(files)), TotalBytes: total}, nil
+}
+
+// OpenStdin records the stdin streamed to an execution
+func (m *MockDaemonClient) OpenStdin(ctx context.Context, sessionID string, executionID string, stdin io.Reader) (*pb.OpenStdinResponse, error) {
+	exec, ok := m.executions[executionID]
+	if !ok {
+		return nil, errors.New("execution not found")
+	}
+	if !exec.StdinOpen {
+		return nil, errors.New("execution was not submitted with stdin")
+	}
+
+	data, err := io.ReadAll(stdin)
+	if err != nil {
+		return nil, err
+	}
+	exec.Stdin = append(exec.Stdin, data...)
+	return &pb.OpenStdinResponse{BytesReceived: uint64(len(data))}, nil
+}
+
+// Workspace returns the files uploaded to a workspace, by path
+func (m *MockDaemonClient) Workspace(workspaceID string) map[string]string {
+	return m.workspaces[workspaceID]
+}
+
 // GetExecutionStatus gets mock execution status
-func (m *MockDaemonClient) GetExecutionStatus(ctx context.Context, sessionID string, executionID string) (*pb.GetExecutionStatusResponse, error) {
+func (m *MockDaemonClient) GetExecutionStatus(ctx context.Context, sessionID string, executionID string, output driver.OutputRequest) (*pb.GetExecutionStatusResponse, error) {
 	if m.statusErr != nil {
 		return nil, m.statusErr
 	}
@@ -107,6 +262,7 @@ func (m *MockDaemonClient) GetExecutionStatus(ctx context.Context, sessionID str
 	if !ok {
 		return nil, errors.New("execution not found")
 	}
+	exec.StatusOutputs = append(exec.StatusOutputs, output)
 
 	// Simulate completion after 1 second
 	if !exec.Complete && time.Since(exec.StartedAt) > 1*time.Second {
@@ -121,28 +277,153 @@ func (m *MockDaemonClient) GetExecutionStatus(ctx context.Context, sessionID str
 		Complete:    exec.Complete,
 		ExitCode:    exec.ExitCode,
 		Error:       exec.Error,
+
+		CancellationReason: exec.CancellationReason,
+		CancelledBy:        exec.CancelledBy,
 	}, nil
 }
 
-// CancelExecution cancels a mock execution
-func (m *MockDaemonClient) CancelExecution(ctx context.Context, sessionID string, executionID string) error {
+// WatchExecution streams the status of a mock execution, polling it every
+// 10ms, until it completes
+func (m *MockDaemonClient) WatchExecution(ctx context.Context, sessionID string, executionID string, order bool) (driver.StatusStream, error) {
+	if m.watchErr != nil {
+		return nil, m.watchErr
+	}
+	return &mockStatusStream{ctx: ctx, client: m, sessionID: sessionID, executionID: executionID}, nil
+}
+
+// mockStatusStream is the status stream of a mock execution
+type mockStatusStream struct {
+	ctx         context.Context
+	client      *MockDaemonClient
+	sessionID   string
+	executionID string
+	done        bool
+	sent        bool
+}
+
+// Recv returns the current status of the execution, io.EOF after it
+// completed
+func (s *mockStatusStream) Recv() (*pb.GetExecutionStatusResponse, error) {
+	if s.done {
+		return nil, io.EOF
+	}
+	if s.sent {
+		select {
+		case <-s.ctx.Done():
+			return nil, s.ctx.Err()
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	s.sent = true
+
+	resp, err := s.client.GetExecutionStatus(s.ctx, s.sessionID, s.executionID, driver.OutputRequest{})
+	if err != nil {
+		return nil, err
+	}
+	s.done = resp.Complete
+	return resp, nil
+}
+
+// CancelExecution cancels a mock execution. Like the daemon client, it
+// reports unknown and completed executions as not cancelled, and a repeated
+// token as cancelled.
+func (m *MockDaemonClient) CancelExecution(ctx context.Context, sessionID string, executionID string, reason pb.CancellationReason, initiator string, token string) (bool, error) {
 	if m.cancelErr != nil {
-		return m.cancelErr
+		return false, m.cancelErr
 	}
 
 	exec, ok := m.executions[executionID]
 	if !ok {
-		return errors.New("execution not found")
+		return false, nil
 	}
+	if exec.Complete {
+		return token != "" && token == exec.CancelToken, nil
+	}
+
+	exec.Status = pb.ExecutionStatus_EXECUTION_STATUS_CANCELLED
+	exec.Complete = true
+	exec.CancellationReason = reason
+	exec.CancelledBy = initiator
+	exec.CancelToken = token
+	return true, nil
+}
 
+// KillExecution force-kills a mock execution, reporting unknown and
+// completed executions as not killed.
+func (m *MockDaemonClient) KillExecution(ctx context.Context, sessionID string, executionID string, initiator string) (bool, error) {
+	exec, ok := m.executions[executionID]
+	if !ok || exec.Complete {
+		return false, nil
+	}
 	exec.Status = pb.ExecutionStatus_EXECUTION_STATUS_CANCELLED
 	exec.Complete = true
+	exec.CancelledBy = initiator
+	exec.Killed = true
+	return true, nil
+}
+
+// SignalExecution records a signal delivered to a mock execution
+func (m *MockDaemonClient) SignalExecution(ctx context.Context, sessionID string, executionID string, signal string, named bool) (bool, error) {
+	if m.signalErr != nil {
+		return false, m.signalErr
+	}
+
+	exec, ok := m.executions[executionID]
+	if !ok {
+		return false, errors.New("execution not found")
+	}
+	if exec.Complete {
+		return false, nil
+	}
+
+	if named {
+		signal = "named:" + signal
+	}
+	exec.Signals = append(exec.Signals, signal)
+	return true, nil
+}
+
+// ExecInSession returns mock output for an ad-hoc snippet in a mock session
+func (m *MockDaemonClient) ExecInSession(ctx context.Context, sessionID string, code string, language string, env map[string]string, timeout time.Duration, runAs string, mounts []*pb.Mount, tags map[string]string) (*pb.ExecInSessionResponse, error) {
+	if m.execErr != nil {
+		return nil, m.execErr
+	}
+	if _, ok := m.sessions[sessionID]; !ok {
+		return nil, errors.New("session not found")
+	}
+	return &pb.ExecInSessionResponse{Stdout: fmt.Sprintf("Mocked output for %s snippet:\n%s", language, code)}, nil
+}
+
+// PutSessionValue stores a value in the mock session key-value store
+func (m *MockDaemonClient) PutSessionValue(ctx context.Context, sessionID string, key string, value string) error {
+	if _, ok := m.sessions[sessionID]; !ok {
+		return errors.New("session not found")
+	}
+	m.values[sessionID+"/"+key] = value
 	return nil
 }
 
+// GetSessionValue reads a value from the mock session key-value store
+func (m *MockDaemonClient) GetSessionValue(ctx context.Context, sessionID string, key string) (string, bool, error) {
+	if _, ok := m.sessions[sessionID]; !ok {
+		return "", false, errors.New("session not found")
+	}
+	value, ok := m.values[sessionID+"/"+key]
+	return value, ok, nil
+}
+
 // Health checks mock daemon health
-func (m *MockDaemonClient) Health(ctx context.Context) error {
-	return m.healthErr
+func (m *MockDaemonClient) Health(ctx context.Context) (*pb.HealthResponse, error) {
+	if m.healthErr != nil {
+		return nil, m.healthErr
+	}
+	return &pb.HealthResponse{Healthy: true, Version: "mock"}, nil
+}
+
+// Reconnect counts requests to re-dial the mock daemon
+func (m *MockDaemonClient) Reconnect() {
+	m.Reconnects++
 }
 
 // Close closes the mock client
@@ -155,6 +436,33 @@ func (m *MockDaemonClient) SetCreateSessionError(err error) {
 	m.createErr = err
 }
 
+// SetCreateSessionFailures makes the next n CreateSession calls fail with err
+func (m *MockDaemonClient) SetCreateSessionFailures(n int, err error) {
+	m.createFails = n
+	m.createFailErr = err
+}
+
+// SetSessionConflicts makes CreateSession of an existing session fail with
+// AlreadyExists, as a real daemon does, instead of replacing it
+func (m *MockDaemonClient) SetSessionConflicts(conflicts bool) {
+	m.conflicts = conflicts
+}
+
+// AddSession adds a session as if another client had created it
+func (m *MockDaemonClient) AddSession(sessionID string, config *pb.SessionConfiguration) {
+	m.sessions[sessionID] = config
+}
+
+// CreateSessionCalls returns the number of CreateSession calls
+func (m *MockDaemonClient) CreateSessionCalls() int {
+	return m.createCalls
+}
+
+// GetSessionCalls returns the number of GetSession calls
+func (m *MockDaemonClient) GetSessionCalls() int {
+	return m.getCalls
+}
+
 // SetExecuteError sets an error for ExecuteSnippet
 func (m *MockDaemonClient) SetExecuteError(err error) {
 	m.executeErr = err
@@ -165,6 +473,74 @@ func (m *MockDaemonClient) SetStatusError(err error) {
 	m.statusErr = err
 }
 
+// SetSignalError sets an error for SignalExecution
+func (m *MockDaemonClient) SetSignalError(err error) {
+	m.signalErr = err
+}
+
+// SetExecInSessionError sets an error for ExecInSession
+func (m *MockDaemonClient) SetExecInSessionError(err error) {
+	m.execErr = err
+}
+
+// WatchSessionEvents streams the events published with PublishSessionEvent
+// that belong to the session (or all of them if sessionID is empty)
+func (m *MockDaemonClient) WatchSessionEvents(ctx context.Context, sessionID string) (driver.SessionEventStream, error) {
+	if m.eventsErr != nil {
+		return nil, m.eventsErr
+	}
+	return &mockEventStream{ctx: ctx, events: m.events, sessionID: sessionID}, nil
+}
+
+// mockEventStream is a session event stream of the mock daemon
+type mockEventStream struct {
+	ctx       context.Context
+	events    <-chan *pb.SessionEvent
+	sessionID string
+}
+
+// Recv returns the next published event of the watched session
+func (s *mockEventStream) Recv() (*pb.SessionEvent, error) {
+	for {
+		select {
+		case <-s.ctx.Done():
+			return nil, s.ctx.Err()
+		case event := <-s.events:
+			if s.sessionID == "" || event.SessionId == s.sessionID {
+				return event, nil
+			}
+		}
+	}
+}
+
+// PublishSessionEvent delivers an event to the session event stream
+func (m *MockDaemonClient) PublishSessionEvent(event *pb.SessionEvent) {
+	m.events <- event
+}
+
+// SetSessionEventsError sets an error for WatchSessionEvents, e.g.
+// Unimplemented to simulate daemons without session events
+func (m *MockDaemonClient) SetSessionEventsError(err error) {
+	m.eventsErr = err
+}
+
+// SetWatchError sets an error for WatchExecution, e.g. Unimplemented to
+// simulate daemons without status streaming
+func (m *MockDaemonClient) SetWatchError(err error) {
+	m.watchErr = err
+}
+
+// SetRecycleError sets an error for RecycleContexts, e.g. Unimplemented to
+// simulate daemons that cannot recycle contexts
+func (m *MockDaemonClient) SetRecycleError(err error) {
+	m.recycleErr = err
+}
+
+// Recycles returns the number of RecycleContexts calls for a session
+func (m *MockDaemonClient) Recycles(sessionID string) int {
+	return m.recycles[sessionID]
+}
+
 // SetCancelError sets an error for CancelExecution
 func (m *MockDaemonClient) SetCancelError(err error) {
 	m.cancelErr = err
@@ -54,19 +54,16 @@ func TestDriver_WithMockDaemon(t *testing.T) {
 
 	// Execute snippet
 	executionID := "test-exec-123"
-	_, err = mockClient.ExecuteSnippet(
-		context.Background(),
-		sessionID,
-		executionID,
-		"print('hello')",
-		"python",
-		nil,
-		nil,
-	)
+	_, err = mockClient.ExecuteSnippet(context.Background(), driver.ExecuteSnippetRequest{
+		SessionID:   sessionID,
+		ExecutionID: executionID,
+		Code:        "print('hello')",
+		Language:    "python",
+	})
 	require.NoError(t, err)
 
 	// Get status
-	status, err := mockClient.GetExecutionStatus(context.Background(), sessionID, executionID)
+	status, err := mockClient.GetExecutionStatus(context.Background(), sessionID, executionID, driver.OutputRequest{})
 	require.NoError(t, err)
 	assert.Equal(t, pb.ExecutionStatus_EXECUTION_STATUS_RUNNING, status.Status)
 
@@ -74,7 +71,7 @@ func TestDriver_WithMockDaemon(t *testing.T) {
 	mockClient.CompleteExecution(executionID, 0)
 
 	// Get final status
-	status, err = mockClient.GetExecutionStatus(context.Background(), sessionID, executionID)
+	status, err = mockClient.GetExecutionStatus(context.Background(), sessionID, executionID, driver.OutputRequest{})
 	require.NoError(t, err)
 	assert.True(t, status.Complete)
 	assert.Equal(t, int32(0), status.ExitCode)
@@ -29,7 +29,9 @@ command -v go >/dev/null 2>&1 || { echo -e "${RED}go is required but not install
 cleanup() {
     echo -e "\n${YELLOW}Cleaning up...${NC}"
     pkill -f "nomad agent" || true
-    pkill -f "cmd/server/main.go" || true
+    if [ -n "$SERVER_PID" ]; then
+        kill "$SERVER_PID" 2>/dev/null || true
+    fi
     rm -f /tmp/elide-daemon.sock
     echo -e "${GREEN}Cleanup complete${NC}"
 }
@@ -50,10 +52,11 @@ cd build/plugins
 ln -sf elide-task-driver elide 2>/dev/null || true
 cd "$PROJECT_ROOT"
 
-# Start stubbed server
+# Build the stubbed server from source and start it
 echo -e "${YELLOW}Starting stubbed server...${NC}"
 cd "$PROJECT_ROOT"
-make server &
+make build-server
+ELIDE_DAEMON_SOCKET=/tmp/elide-daemon.sock build/elide-stub &
 SERVER_PID=$!
 sleep 2
 
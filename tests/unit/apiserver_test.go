@@ -0,0 +1,139 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package unit
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/elide-dev/elide-task-driver/driver"
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/nomad/plugins/base"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadConfigFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "elide.hcl")
+	require.NoError(t, os.WriteFile(path, []byte(`
+daemon_socket       = "/run/elide.sock"
+rpc_log_sample_rate = 0.25
+
+session_profile "large" {
+  memory_limit_mb = 4096
+}
+`), 0644))
+
+	cfg, err := driver.LoadConfigFile(path)
+	require.NoError(t, err)
+
+	var config driver.Config
+	require.NoError(t, base.MsgPackDecode(cfg.PluginConfig, &config))
+	assert.Equal(t, "/run/elide.sock", config.DaemonSocket)
+	assert.Equal(t, "0.25", config.RPCLogSampleRate)
+	assert.Equal(t, "1s", config.PollInterval, "spec defaults apply")
+	assert.Equal(t, 4096, config.SessionProfiles["large"].MemoryLimitMB)
+	assert.NoError(t, config.Validate())
+}
+
+func TestLoadConfigFile_Defaults(t *testing.T) {
+	cfg, err := driver.LoadConfigFile("")
+	require.NoError(t, err)
+
+	var config driver.Config
+	require.NoError(t, base.MsgPackDecode(cfg.PluginConfig, &config))
+	assert.Equal(t, "/tmp/elide-daemon.sock", config.DaemonSocket)
+	assert.Equal(t, "0.1", config.RPCLogSampleRate)
+}
+
+func TestAPIServer_Errors(t *testing.T) {
+	plugin := driver.NewPlugin(hclog.NewNullLogger()).(*driver.ElideDriverPlugin)
+	defer plugin.Shutdown()
+
+	api, err := driver.NewAPIServer(plugin, t.TempDir(), driver.APIServerOptions{})
+	require.NoError(t, err)
+	srv := httptest.NewServer(api.Handler())
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/v1/executions")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp, err = http.Get(srv.URL + "/v1/debug/allocs")
+	require.NoError(t, err)
+	var allocs []*driver.AllocUsage
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&allocs))
+	resp.Body.Close()
+	assert.Empty(t, allocs)
+	assert.Empty(t, plugin.AllocUsage())
+
+	resp, err = http.Get(srv.URL + "/v1/executions/missing")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	resp, err = http.Post(srv.URL+"/v1/executions", "application/json", strings.NewReader(`{"config": {"bogus": 1}}`))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp, err = http.Post(srv.URL+"/v1/executions", "application/json", strings.NewReader(`{"name": "../escape", "config": {"code": "print(1)"}}`))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestListenAPI(t *testing.T) {
+	for _, address := range []string{"0.0.0.0:0", ":0", "192.0.2.1:8080", "[::]:0"} {
+		_, err := driver.ListenAPI(address, false)
+		assert.ErrorContains(t, err, "without a token", address)
+	}
+	_, err := driver.ListenAPI("no-port", false)
+	assert.ErrorContains(t, err, "invalid listen address")
+
+	for _, address := range []string{"127.0.0.1:0", "localhost:0", filepath.Join(t.TempDir(), "api.sock")} {
+		lis, err := driver.ListenAPI(address, false)
+		require.NoError(t, err, address)
+		lis.Close()
+	}
+
+	lis, err := driver.ListenAPI(":0", true)
+	require.NoError(t, err, "a token allows listening beyond loopback")
+	lis.Close()
+}
+
+func TestAPIServer_Token(t *testing.T) {
+	plugin := driver.NewPlugin(hclog.NewNullLogger()).(*driver.ElideDriverPlugin)
+	defer plugin.Shutdown()
+
+	api, err := driver.NewAPIServer(plugin, t.TempDir(), driver.APIServerOptions{Token: "s3cret"})
+	require.NoError(t, err)
+	srv := httptest.NewServer(api.Handler())
+	defer srv.Close()
+
+	get := func(path string, token string) int {
+		req, err := http.NewRequest(http.MethodGet, srv.URL+path, nil)
+		require.NoError(t, err)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	assert.Equal(t, http.StatusUnauthorized, get("/v1/executions", ""))
+	assert.Equal(t, http.StatusUnauthorized, get("/v1/executions", "wrong"))
+	assert.Equal(t, http.StatusOK, get("/v1/executions", "s3cret"))
+	assert.Equal(t, http.StatusOK, get("/healthz", ""), "health checks need no token")
+
+	_, err = driver.NewAPIServer(plugin, t.TempDir(), driver.APIServerOptions{MaxRetained: -1})
+	assert.Error(t, err)
+}
@@ -0,0 +1,94 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package unit
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/elide-dev/elide-task-driver/driver"
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/nomad/plugins/drivers"
+	"github.com/hashicorp/nomad/plugins/shared/structs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	pb "github.com/elide-dev/elide-task-driver/proto/gen/go/elide/daemon/v1alpha1"
+)
+
+func TestCapabilities_DefaultsWithoutDaemonFeatures(t *testing.T) {
+	plugin := driver.NewPlugin(hclog.NewNullLogger()).(*driver.ElideDriverPlugin)
+
+	caps, err := plugin.Capabilities()
+	require.NoError(t, err)
+	assert.False(t, caps.SendSignals, "signals need daemon support")
+	assert.False(t, caps.Exec, "exec needs enable_exec")
+	assert.Equal(t, drivers.FSIsolationNone, caps.FSIsolation, "image isolation needs daemon mount support")
+	assert.Equal(t, []drivers.NetIsolationMode{drivers.NetIsolationModeHost, drivers.NetIsolationModeGroup}, caps.NetIsolationModes)
+}
+
+func TestDaemonAttributes(t *testing.T) {
+	fp := &drivers.Fingerprint{Attributes: map[string]*structs.Attribute{}}
+	driver.DaemonAttributes(fp, &pb.HealthResponse{
+		Healthy:     true,
+		Version:     "1.0.0-beta10",
+		Languages:   []string{"typescript", "python", "javascript"},
+		MaxMemoryMb: 8192,
+		Intrinsics:  []string{"net", "env", "io"},
+	})
+
+	assert.Equal(t, "1.0.0-beta10", fp.Attributes["driver.elide.version"].GoString())
+	assert.Equal(t, "javascript,python,typescript", fp.Attributes["driver.elide.languages"].GoString())
+	assert.Equal(t, structs.NewBoolAttribute(true), fp.Attributes["driver.elide.language.python"])
+	assert.Equal(t, structs.NewIntAttribute(8192, ""), fp.Attributes["driver.elide.max_memory_mb"])
+	assert.Equal(t, "env,io,net", fp.Attributes["driver.elide.intrinsics"].GoString())
+
+	fp = &drivers.Fingerprint{Attributes: map[string]*structs.Attribute{}}
+	driver.DaemonAttributes(fp, &pb.HealthResponse{Healthy: true})
+	assert.Empty(t, fp.Attributes, "unreported values are left out")
+}
+
+func TestExecTask_Disabled(t *testing.T) {
+	plugin := driver.NewPlugin(hclog.NewNullLogger()).(*driver.ElideDriverPlugin)
+
+	_, err := plugin.ExecTask("task-1", []string{"print('hi')"}, time.Second)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "enable_exec")
+}
+
+func TestExecTaskStreaming_Disabled(t *testing.T) {
+	plugin := driver.NewPlugin(hclog.NewNullLogger()).(*driver.ElideDriverPlugin)
+
+	var stdout, stderr bytes.Buffer
+	_, err := plugin.ExecTaskStreaming(context.Background(), "task-1", &drivers.ExecOptions{
+		Command: []string{"print('hi')"},
+		Stdout:  nopWriteCloser{&stdout},
+		Stderr:  nopWriteCloser{&stderr},
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "enable_exec")
+}
+
+// nopWriteCloser turns a writer into the io.WriteCloser of exec options
+type nopWriteCloser struct{ io.Writer }
+
+func (nopWriteCloser) Close() error { return nil }
+
+func TestCheckDaemonVersion(t *testing.T) {
+	assert.NoError(t, driver.CheckDaemonVersion("", ""), "no constraint accepts any daemon")
+	assert.NoError(t, driver.CheckDaemonVersion(">= 1.0, < 2.0", "1.4.2"))
+	assert.NoError(t, driver.CheckDaemonVersion("~> 1.0.0", "1.0.7+a1b2c3d"), "build metadata is ignored")
+
+	for reported, want := range map[string]string{
+		"2.0.0":        "does not satisfy",
+		"1.0.0-beta10": "does not satisfy",
+		"":             "no version",
+		"nightly":      "not a version number",
+	} {
+		assert.ErrorContains(t, driver.CheckDaemonVersion(">= 1.0, < 2.0", reported), want, reported)
+	}
+}
@@ -4,10 +4,15 @@
 package unit
 
 import (
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/elide-dev/elide-task-driver/driver"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestTaskConfig_Validate(t *testing.T) {
@@ -40,10 +45,17 @@ func TestTaskConfig_Validate(t *testing.T) {
 			wantErr: true,
 		},
 		{
-			name: "invalid - no language",
+			name: "valid - language inferred from script",
 			config: driver.TaskConfig{
 				Script: "test.py",
 			},
+			wantErr: false,
+		},
+		{
+			name: "invalid - language not inferable from script",
+			config: driver.TaskConfig{
+				Script: "test.rb",
+			},
 			wantErr: true,
 		},
 		{
@@ -55,6 +67,189 @@ func TestTaskConfig_Validate(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "valid with OCI artifact",
+			config: driver.TaskConfig{
+				CodeOCIRef:        "ghcr.io/example/snippets/hello:v1",
+				CodeOCIEntrypoint: "main.py",
+				Language:          "python",
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid - both code and OCI artifact",
+			config: driver.TaskConfig{
+				Code:       "print('hello')",
+				CodeOCIRef: "ghcr.io/example/snippets/hello:v1",
+				Language:   "python",
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid - OCI entrypoint without OCI artifact",
+			config: driver.TaskConfig{
+				Script:            "test.py",
+				CodeOCIEntrypoint: "main.py",
+				Language:          "python",
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid with entrypoint",
+			config: driver.TaskConfig{
+				Entrypoint: "app/main.py",
+				Files:      "local/project",
+				Language:   "python",
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid - both script and entrypoint",
+			config: driver.TaskConfig{
+				Script:     "test.py",
+				Entrypoint: "main.py",
+				Language:   "python",
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid - files without entrypoint",
+			config: driver.TaskConfig{
+				Code:     "print('hello')",
+				Files:    "local/project",
+				Language: "python",
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid - entrypoint escaping files",
+			config: driver.TaskConfig{
+				Entrypoint: "../secrets/main.py",
+				Language:   "python",
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid - absolute files",
+			config: driver.TaskConfig{
+				Entrypoint: "main.py",
+				Files:      "/etc",
+				Language:   "python",
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid with stdin_path",
+			config: driver.TaskConfig{
+				Code:      "import sys; print(sys.stdin.read())",
+				StdinPath: "alloc/input.csv",
+				Language:  "python",
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid - both stdin_path and stdin_data",
+			config: driver.TaskConfig{
+				Code:      "import sys; print(sys.stdin.read())",
+				StdinPath: "local/input.csv",
+				StdinData: "a,b\n",
+				Language:  "python",
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid - stdin_path escaping the task directory",
+			config: driver.TaskConfig{
+				Code:      "import sys; print(sys.stdin.read())",
+				StdinPath: "../other/input.csv",
+				Language:  "python",
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid with ports",
+			config: driver.TaskConfig{
+				Code:     "serve()",
+				Ports:    []string{"http", "metrics"},
+				Expose:   true,
+				Language: "javascript",
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid - port listed twice",
+			config: driver.TaskConfig{
+				Code:     "serve()",
+				Ports:    []string{"http", "http"},
+				Language: "javascript",
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid - expose without ports",
+			config: driver.TaskConfig{
+				Code:     "serve()",
+				Expose:   true,
+				Language: "javascript",
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid - ports with args_matrix",
+			config: driver.TaskConfig{
+				Code:       "serve()",
+				Ports:      []string{"http"},
+				ArgsMatrix: [][]string{{"a"}, {"b"}},
+				Language:   "javascript",
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid - combine_output with args_matrix",
+			config: driver.TaskConfig{
+				Script:        "probe.py",
+				ArgsMatrix:    [][]string{{"a"}, {"b"}},
+				CombineOutput: true,
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid with inline result_schema",
+			config: driver.TaskConfig{
+				Code:               "print('{}')",
+				ResultSchema:       `{"type": "object", "required": ["count"]}`,
+				ResultSchemaPolicy: "warn",
+				Language:           "python",
+			},
+			wantErr: false,
+		},
+		{
+			name: "valid with result_schema file",
+			config: driver.TaskConfig{
+				Code:         "print('{}')",
+				ResultSchema: "schemas/result.json",
+				Language:     "python",
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid - unsupported result_schema keyword",
+			config: driver.TaskConfig{
+				Code:         "print('{}')",
+				ResultSchema: `{"$ref": "#/definitions/result"}`,
+				Language:     "python",
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid - result_schema_policy",
+			config: driver.TaskConfig{
+				Code:               "print('{}')",
+				ResultSchemaPolicy: "ignore",
+				Language:           "python",
+			},
+			wantErr: true,
+		},
 		{
 			name: "valid - any language (validation happens in ValidateLanguage)",
 			config: driver.TaskConfig{
@@ -104,7 +299,7 @@ func TestTaskConfig_Validate(t *testing.T) {
 
 func TestSessionConfig_Defaults(t *testing.T) {
 	config := driver.SessionConfig{}
-	
+
 	// Test that defaults are reasonable
 	assert.Equal(t, 0, config.ContextPoolSize)
 	assert.Equal(t, 0, len(config.EnabledLanguages))
@@ -162,11 +357,670 @@ func TestElideOptions(t *testing.T) {
 	opts := driver.ElideOptions{
 		MemoryLimit: 256,
 		EnableAI:    true,
-		Timeout:     30,
+		Timeout:     "30s",
 	}
 
 	assert.Equal(t, 256, opts.MemoryLimit)
 	assert.Equal(t, true, opts.EnableAI)
-	assert.Equal(t, 30, opts.Timeout)
+	assert.Equal(t, "30s", opts.Timeout)
+}
+
+func TestTaskConfig_ValidateMemoryLimit(t *testing.T) {
+	tc := driver.TaskConfig{Language: "python", Code: "print(1)", ElideOpts: driver.ElideOptions{MemoryLimit: 256}}
+	assert.NoError(t, tc.Validate())
+
+	tc.ElideOpts.MemoryLimit = -1
+	assert.ErrorContains(t, tc.Validate(), "memory_limit")
 }
 
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{value: "", want: 0},
+		{value: "30s", want: 30 * time.Second},
+		{value: "1m30s", want: 90 * time.Second},
+		{value: "45", want: 45 * time.Second},
+		{value: "-5s", wantErr: true},
+		{value: "-5", wantErr: true},
+		{value: "soon", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := driver.ParseDuration("timeout", tt.value)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestTaskConfig_ValidateDurations(t *testing.T) {
+	tc := driver.TaskConfig{Code: "print(1)", Language: "python"}
+	tc.ElideOpts.Timeout = "ten minutes"
+	assert.ErrorContains(t, tc.Validate(), "elide_opts.timeout")
+
+	tc.ElideOpts.Timeout = "10m"
+	tc.PollInterval = "-1s"
+	assert.ErrorContains(t, tc.Validate(), "poll_interval")
+
+	tc.PollInterval = "250ms"
+	assert.NoError(t, tc.Validate())
+}
+
+func TestTaskConfig_ValidateOutputMode(t *testing.T) {
+	tc := driver.TaskConfig{Code: "print(1)", Language: "python"}
+	for _, mode := range []string{"", "discard", "log", "file", "both"} {
+		tc.OutputMode = mode
+		assert.NoError(t, tc.Validate(), mode)
+	}
+
+	tc.OutputMode = "stdout"
+	assert.ErrorContains(t, tc.Validate(), "output_mode")
+}
+
+func TestTaskConfig_ValidateBinaryOutput(t *testing.T) {
+	tc := driver.TaskConfig{Code: "print(1)", Language: "python"}
+	for _, mode := range []string{"", "base64", "raw", "file"} {
+		tc.BinaryOutput = mode
+		assert.NoError(t, tc.Validate(), mode)
+	}
+
+	tc.BinaryOutput = "hex"
+	assert.ErrorContains(t, tc.Validate(), "binary_output")
+}
+
+func TestTaskConfig_ValidateArgsMatrix(t *testing.T) {
+	tc := driver.TaskConfig{Code: "print(1)", Language: "python", ArgsMatrix: [][]string{{"a"}, {"b"}}}
+	for _, policy := range []string{"", "all-success", "any-success"} {
+		tc.MatrixPolicy = policy
+		assert.NoError(t, tc.Validate(), policy)
+	}
+
+	tc.MatrixPolicy = "majority"
+	assert.ErrorContains(t, tc.Validate(), "matrix_policy")
+
+	tc.MatrixPolicy = ""
+	tc.Args = []string{"x"}
+	assert.ErrorContains(t, tc.Validate(), "args_matrix")
+
+	tc.Args = nil
+	tc.Exports = []string{"answer"}
+	assert.ErrorContains(t, tc.Validate(), "exports")
+
+	tc.Exports = nil
+	tc.ArgsMatrix = make([][]string, 1001)
+	assert.ErrorContains(t, tc.Validate(), "limit 1000")
+}
+
+func TestTaskConfig_ValidateEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		envCase string
+		wantErr bool
+	}{
+		{
+			name: "valid env",
+			env:  map[string]string{"FOO": "bar", "path_extra": "/opt/bin"},
+		},
+		{
+			name:    "invalid - space in name",
+			env:     map[string]string{"MY VAR": "value"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid - equals in name",
+			env:     map[string]string{"FOO=BAR": "value"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid - empty name",
+			env:     map[string]string{"": "value"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid - payload too large",
+			env:     map[string]string{"BIG": strings.Repeat("x", 256*1024)},
+			wantErr: true,
+		},
+		{
+			name:    "invalid - unknown env_case",
+			env:     map[string]string{"FOO": "bar"},
+			envCase: "title",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := driver.TaskConfig{
+				Code:     "print('hello')",
+				Language: "python",
+				Env:      tt.env,
+				EnvCase:  tt.envCase,
+			}
+			err := config.Validate()
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestTaskConfig_NormalizedEnv(t *testing.T) {
+	config := driver.TaskConfig{
+		Env:     map[string]string{"foo": "1", "Bar": "2"},
+		EnvCase: "upper",
+	}
+	env, err := config.NormalizedEnv()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"FOO": "1", "BAR": "2"}, env)
+
+	config.Env["FOO"] = "3"
+	_, err = config.NormalizedEnv()
+	assert.Error(t, err, "names colliding after normalization should be rejected")
+
+	config.EnvCase = ""
+	env, err = config.NormalizedEnv()
+	assert.NoError(t, err)
+	assert.Equal(t, config.Env, env)
+}
+
+func TestTaskConfig_ScriptPath(t *testing.T) {
+	taskDir := t.TempDir()
+	outside := t.TempDir()
+
+	require.NoError(t, os.MkdirAll(filepath.Join(taskDir, "local"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(taskDir, "local", "main.py"), []byte("print('hi')"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(outside, "evil.py"), []byte("print('evil')"), 0644))
+	require.NoError(t, os.Symlink(filepath.Join(taskDir, "local", "main.py"), filepath.Join(taskDir, "local", "inside.py")))
+	require.NoError(t, os.Symlink(filepath.Join(outside, "evil.py"), filepath.Join(taskDir, "local", "outside.py")))
+
+	tests := []struct {
+		name           string
+		script         string
+		followSymlinks bool
+		wantErr        bool
+	}{
+		{name: "regular file", script: "local/main.py"},
+		{name: "symlink inside task dir", script: "local/inside.py"},
+		{name: "symlink outside task dir", script: "local/outside.py", wantErr: true},
+		{name: "symlink outside task dir with follow_symlinks", script: "local/outside.py", followSymlinks: true},
+		{name: "dot-dot escape", script: "../escape.py", wantErr: true},
+		{name: "dot-dot escape with follow_symlinks", script: "../escape.py", followSymlinks: true, wantErr: true},
+		{name: "missing file", script: "local/missing.py", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := driver.TaskConfig{Script: tt.script, Language: "python"}
+			path, err := config.ScriptPath(taskDir, tt.followSymlinks)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.FileExists(t, path)
+			}
+		})
+	}
+}
+
+func TestTaskConfig_ArtifactScriptPath(t *testing.T) {
+	taskDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(taskDir, "local", "bundle"), 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(taskDir, "downloads"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(taskDir, "local", "bundle", "main.py"), []byte("print('hi')"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(taskDir, "downloads", "report.py"), []byte("print('report')"), 0644))
+
+	config := driver.TaskConfig{Script: "bundle/main.py", ScriptSource: "artifact", Language: "python"}
+	path, err := config.ScriptPath(taskDir, false)
+	require.NoError(t, err)
+	assert.Equal(t, "main.py", filepath.Base(path))
+
+	config.Script = "report.py"
+	path, err = config.ScriptPath(taskDir, false)
+	require.NoError(t, err)
+	assert.Equal(t, "downloads", filepath.Base(filepath.Dir(path)), "scripts missing from local/ are looked up in downloads/")
+
+	config.Script = "main.py"
+	_, err = config.ScriptPath(taskDir, false)
+	assert.EqualError(t, err, `script "main.py" not found in the task's artifact directories local/, downloads/ (available: bundle/main.py, report.py)`)
+
+	config.Script = "../../escape.py"
+	_, err = config.ScriptPath(taskDir, false)
+	assert.Error(t, err)
+
+	config = driver.TaskConfig{Code: "print(1)", ScriptSource: "artifact", Language: "python"}
+	assert.ErrorContains(t, config.Validate(), "requires 'script'")
+	config = driver.TaskConfig{Script: "main.py", ScriptSource: "url", Language: "python"}
+	assert.ErrorContains(t, config.Validate(), `invalid script_source "url"`)
+}
+
+func TestConfig_Profile(t *testing.T) {
+	cfg := driver.Config{
+		Profiles: map[string]driver.ProfileConfig{
+			"io-allowed": {Intrinsics: []string{"io"}, MemoryLimitMB: 128},
+			"batch":      {Timeout: "10m"},
+		},
+	}
+
+	profile, ok := cfg.Profile("pure-compute")
+	require.True(t, ok)
+	assert.Empty(t, profile.Intrinsics)
+
+	profile, ok = cfg.Profile("io-allowed")
+	require.True(t, ok)
+	assert.Equal(t, []string{"io"}, profile.Intrinsics)
+	assert.Equal(t, 128, profile.MemoryLimitMB)
+
+	profile, ok = cfg.Profile("batch")
+	require.True(t, ok)
+	assert.Equal(t, "10m", profile.Timeout)
+
+	_, ok = cfg.Profile("unknown")
+	assert.False(t, ok)
+}
+
+func TestProfileConfig_Validate(t *testing.T) {
+	enabled := []string{"io", "env"}
+
+	profile := driver.ProfileConfig{Intrinsics: []string{"io", "env"}, Timeout: "30s"}
+	assert.NoError(t, profile.Validate(enabled))
+
+	profile = driver.ProfileConfig{Intrinsics: []string{"io", "net"}}
+	assert.ErrorContains(t, profile.Validate(enabled), "net")
+
+	profile = driver.ProfileConfig{MemoryLimitMB: -1}
+	assert.Error(t, profile.Validate(enabled))
+
+	profile = driver.ProfileConfig{Timeout: "forever"}
+	assert.Error(t, profile.Validate(enabled))
+}
+
+func TestTaskConfig_ValidateSessionValues(t *testing.T) {
+	tests := []struct {
+		name    string
+		imports map[string]string
+		exports []string
+		env     map[string]string
+		wantErr string
+	}{
+		{name: "none"},
+		{name: "valid", imports: map[string]string{"REPORT_ID": "report_id"}, exports: []string{"summary"}},
+		{name: "bad env name", imports: map[string]string{"REPORT ID": "report_id"}, wantErr: "whitespace"},
+		{name: "empty key", imports: map[string]string{"REPORT_ID": ""}, wantErr: "cannot be empty"},
+		{name: "conflicts with env", imports: map[string]string{"REPORT_ID": "report_id"}, env: map[string]string{"REPORT_ID": "1"}, wantErr: "also set in env"},
+		{name: "empty export", exports: []string{""}, wantErr: "cannot be empty"},
+		{name: "duplicate export", exports: []string{"summary", "summary"}, wantErr: "duplicate"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tc := driver.TaskConfig{
+				Code:     "print(1)",
+				Language: "python",
+				Env:      tt.env,
+				Imports:  tt.imports,
+				Exports:  tt.exports,
+			}
+			err := tc.Validate()
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestValidateCodeSize(t *testing.T) {
+	code := strings.Repeat("x", 2048)
+
+	assert.NoError(t, driver.ValidateCodeSize(code, 4096))
+	assert.NoError(t, driver.ValidateCodeSize(code, 2048))
+	assert.NoError(t, driver.ValidateCodeSize(code, 0))
+	assert.ErrorContains(t, driver.ValidateCodeSize(code, 1024), "limit of 1 KB")
+}
+
+func TestLanguageDefaults(t *testing.T) {
+	cfg := driver.LanguageDefaultsConfig{
+		Python: driver.LanguageDefaults{
+			InterpreterArgs: []string{"-X", "utf8"},
+			Env:             map[string]string{"PYTHONHASHSEED": "0", "LOG_LEVEL": "info"},
+		},
+	}
+
+	defaults := cfg.For("python")
+	assert.Equal(t, []string{"-X", "utf8"}, defaults.InterpreterArgs)
+	assert.Empty(t, cfg.For("javascript").InterpreterArgs)
+	assert.Empty(t, cfg.For("ruby").Env)
+
+	env := map[string]string{"LOG_LEVEL": "debug"}
+	defaults.MergeEnv(env)
+	assert.Equal(t, map[string]string{"PYTHONHASHSEED": "0", "LOG_LEVEL": "debug"}, env)
+}
+
+func TestConfig_Validate(t *testing.T) {
+	cfg := driver.Config{RPCLogSampleRate: "0.5", RPCSlowThreshold: "250ms"}
+	assert.NoError(t, cfg.Validate())
+
+	cfg.RPCLogSampleRate = "1.5"
+	assert.ErrorContains(t, cfg.Validate(), "rpc_log_sample_rate")
+
+	cfg.RPCLogSampleRate = "often"
+	assert.ErrorContains(t, cfg.Validate(), "rpc_log_sample_rate")
+
+	cfg.RPCLogSampleRate = ""
+	cfg.RPCSlowThreshold = "slow"
+	assert.ErrorContains(t, cfg.Validate(), "rpc_slow_threshold")
+}
+
+func TestConfig_ValidateTimeouts(t *testing.T) {
+	cfg := driver.Config{SubmitTimeout: "2m", StatusTimeout: "10s", CancelTimeout: "30"}
+	assert.NoError(t, cfg.Validate())
+
+	cfg.SubmitTimeout = "later"
+	assert.ErrorContains(t, cfg.Validate(), "submit_timeout")
+
+	cfg.SubmitTimeout = ""
+	cfg.StatusTimeout = "0s"
+	assert.ErrorContains(t, cfg.Validate(), "status_timeout")
+
+	cfg.StatusTimeout = ""
+	cfg.CancelTimeout = "-1s"
+	assert.ErrorContains(t, cfg.Validate(), "cancel_timeout")
+
+	cfg.CancelTimeout = ""
+	cfg.CancelGrace = "0s"
+	assert.ErrorContains(t, cfg.Validate(), "cancel_grace")
+
+	cfg.CancelGrace = ""
+	cfg.QueueTimeout = "0s"
+	assert.ErrorContains(t, cfg.Validate(), "queue_timeout")
+
+	cfg.QueueTimeout = ""
+	cfg.MaxTaskLifetime = "forever"
+	assert.ErrorContains(t, cfg.Validate(), "max_task_lifetime")
+}
+
+func TestConfig_ValidateConcurrencyLimits(t *testing.T) {
+	cfg := driver.Config{MaxConcurrentExecutions: 64, MaxConcurrentExecutionsPerSession: 8, QueueTimeout: "5m"}
+	assert.NoError(t, cfg.Validate())
+
+	cfg.MaxConcurrentExecutions = -1
+	assert.ErrorContains(t, cfg.Validate(), "max_concurrent_executions cannot be negative")
+
+	cfg.MaxConcurrentExecutions = 0
+	cfg.MaxConcurrentExecutionsPerSession = -1
+	assert.ErrorContains(t, cfg.Validate(), "max_concurrent_executions_per_session cannot be negative")
+}
+
+func TestConfig_ValidateAllowedUsers(t *testing.T) {
+	cfg := driver.Config{AllowedUsers: []string{"nobody", "svc-reports"}}
+	assert.NoError(t, cfg.Validate())
+
+	for _, user := range []string{"", "svc reports"} {
+		cfg.AllowedUsers = []string{user}
+		assert.ErrorContains(t, cfg.Validate(), "allowed_users", "user %q", user)
+	}
+}
+
+func TestConfig_ValidateCorrelationEnvPrefix(t *testing.T) {
+	for _, prefix := range []string{"", "ELIDE_", "acme2_"} {
+		cfg := driver.Config{CorrelationEnvPrefix: prefix}
+		assert.NoError(t, cfg.Validate(), "prefix %q", prefix)
+	}
+	for _, prefix := range []string{"2ELIDE_", "ELIDE-", "ELIDE "} {
+		cfg := driver.Config{CorrelationEnvPrefix: prefix}
+		assert.ErrorContains(t, cfg.Validate(), "correlation_env_prefix", "prefix %q", prefix)
+	}
+}
+
+func TestCorrelationEnv(t *testing.T) {
+	assert.Equal(t, map[string]string{
+		"ELIDE_EXECUTION_ID": "exec-1",
+		"ELIDE_SESSION_ID":   "session-1",
+		"NOMAD_ALLOC_ID":     "alloc-1",
+	}, driver.CorrelationEnv("ELIDE_", "exec-1", "session-1", "alloc-1"))
+
+	assert.Equal(t, map[string]string{
+		"ACME_EXECUTION_ID": "exec-1",
+		"ACME_SESSION_ID":   "session-1",
+	}, driver.CorrelationEnv("ACME_", "exec-1", "session-1", ""))
+}
+
+func TestRestartGuardConfig_Validate(t *testing.T) {
+	guard := driver.RestartGuardConfig{MaxRestarts: 3, Window: "5m", Action: "rotate"}
+	assert.NoError(t, guard.Validate())
+	assert.NoError(t, (&driver.RestartGuardConfig{}).Validate())
+
+	for _, bad := range []driver.RestartGuardConfig{
+		{MaxRestarts: -1},
+		{MaxRestarts: 3, Window: "soon"},
+		{MaxRestarts: 3, Action: "restart"},
+	} {
+		assert.Error(t, bad.Validate(), "restart_guard %+v", bad)
+	}
+}
+
+func TestConfig_ValidateSessionPer(t *testing.T) {
+	for _, per := range []string{"", "client", "alloc", "task"} {
+		cfg := driver.Config{SessionPer: per}
+		assert.NoError(t, cfg.Validate(), "session_per %q", per)
+	}
+
+	cfg := driver.Config{SessionPer: "job"}
+	assert.ErrorContains(t, cfg.Validate(), "session_per")
+}
+
+func TestConfig_ValidateDaemonSupervisor(t *testing.T) {
+	supervisor := driver.DaemonSupervisorConfig{Enabled: true, StartupTimeout: "10s", MaxRestartDelay: "1m"}
+	cfg := driver.Config{ElideBinary: "/usr/local/bin/elide", DaemonSocket: "/tmp/elide.sock", DaemonSupervisor: supervisor}
+	assert.NoError(t, cfg.Validate())
+
+	for name, bad := range map[string]driver.Config{
+		"no binary":     {DaemonSocket: "/tmp/elide.sock", DaemonSupervisor: supervisor},
+		"tcp daemon":    {ElideBinary: "/usr/local/bin/elide", DaemonAddress: "127.0.0.1:9000", DaemonSupervisor: supervisor},
+		"consul daemon": {ElideBinary: "/usr/local/bin/elide", DaemonConsulService: "elide", DaemonSupervisor: supervisor},
+		"bad timeout":   {ElideBinary: "/usr/local/bin/elide", DaemonSupervisor: driver.DaemonSupervisorConfig{Enabled: true, StartupTimeout: "soon"}},
+		"bad delay":     {ElideBinary: "/usr/local/bin/elide", DaemonSupervisor: driver.DaemonSupervisorConfig{Enabled: true, MaxRestartDelay: "later"}},
+	} {
+		assert.ErrorContains(t, bad.Validate(), "daemon_supervisor", name)
+	}
+}
+
+func TestConfig_ValidateDaemonTLS(t *testing.T) {
+	tls := driver.DaemonTLSConfig{CAFile: "/etc/elide/ca.pem", CertFile: "/etc/elide/cert.pem", KeyFile: "/etc/elide/key.pem", ReloadInterval: "1m"}
+	cfg := driver.Config{DaemonAddress: "127.0.0.1:9000", DaemonTLS: tls}
+	assert.NoError(t, cfg.Validate())
+
+	for name, bad := range map[string]driver.DaemonTLSConfig{
+		"cert without key":     {CAFile: "/etc/elide/ca.pem", CertFile: "/etc/elide/cert.pem"},
+		"key without cert":     {CAFile: "/etc/elide/ca.pem", KeyFile: "/etc/elide/key.pem"},
+		"server name only":     {ServerName: "elide.internal"},
+		"bad reload interval":  {CAFile: "/etc/elide/ca.pem", ReloadInterval: "often"},
+		"zero reload interval": {CAFile: "/etc/elide/ca.pem", ReloadInterval: "0s"},
+	} {
+		cfg := driver.Config{DaemonAddress: "127.0.0.1:9000", DaemonTLS: bad}
+		assert.ErrorContains(t, cfg.Validate(), "daemon_tls", name)
+	}
+}
+
+func TestConfig_ValidateRequiredDaemonVersion(t *testing.T) {
+	assert.NoError(t, (&driver.Config{RequiredDaemonVersion: ">= 1.2, < 2.0"}).Validate())
+	assert.ErrorContains(t, (&driver.Config{RequiredDaemonVersion: "newest"}).Validate(), "required_daemon_version")
+}
+
+func TestConfig_ValidateDaemonLoss(t *testing.T) {
+	cfg := driver.Config{DaemonLossPolicy: "wait", DaemonLossTimeout: "10m", StateDir: "/var/lib/elide-driver"}
+	assert.NoError(t, cfg.Validate())
+
+	for name, bad := range map[string]driver.Config{
+		"unknown policy": {DaemonLossPolicy: "retry"},
+		"wait, no state": {DaemonLossPolicy: "wait"},
+		"bad timeout":    {DaemonLossTimeout: "soon"},
+		"zero timeout":   {DaemonLossTimeout: "0s"},
+	} {
+		assert.ErrorContains(t, bad.Validate(), "daemon_loss", name)
+	}
+}
+
+func TestConfig_ValidateRecoverPolicy(t *testing.T) {
+	for _, policy := range []string{"", "lost"} {
+		cfg := driver.Config{RecoverPolicy: policy}
+		assert.NoError(t, cfg.Validate(), policy)
+	}
+	cfg := driver.Config{RecoverPolicy: "resubmit", StateDir: "/var/lib/elide-driver"}
+	assert.NoError(t, cfg.Validate())
+
+	for name, bad := range map[string]driver.Config{
+		"unknown policy":     {RecoverPolicy: "retry"},
+		"resubmit, no state": {RecoverPolicy: "resubmit"},
+	} {
+		assert.ErrorContains(t, bad.Validate(), "recover_policy", name)
+	}
+}
+
+func TestConfig_ValidateOrphanPolicy(t *testing.T) {
+	for _, policy := range []string{"", "cancel", "adopt"} {
+		cfg := driver.Config{OrphanPolicy: policy, OrphanGrace: "30s"}
+		assert.NoError(t, cfg.Validate(), policy)
+	}
+
+	for name, bad := range map[string]driver.Config{
+		"unknown policy": {OrphanPolicy: "ignore"},
+		"zero grace":     {OrphanGrace: "0s"},
+		"invalid grace":  {OrphanGrace: "soon"},
+	} {
+		assert.ErrorContains(t, bad.Validate(), "orphan_", name)
+	}
+}
+
+func TestConfig_ValidateDaemonLogDedupWindow(t *testing.T) {
+	for _, window := range []string{"", "60s", "5m"} {
+		cfg := driver.Config{DaemonLogDedupWindow: window}
+		assert.NoError(t, cfg.Validate(), window)
+	}
+	cfg := driver.Config{DaemonLogDedupWindow: "often"}
+	assert.ErrorContains(t, cfg.Validate(), "daemon_log_dedup_window")
+}
+
+func TestConfig_ValidateOtelEndpoint(t *testing.T) {
+	for _, endpoint := range []string{"", "http://localhost:4317", "https://otel.example.com:4317"} {
+		cfg := driver.Config{OtelEndpoint: endpoint}
+		assert.NoError(t, cfg.Validate(), endpoint)
+	}
+	for _, endpoint := range []string{"localhost:4317", "grpc://localhost:4317", "http://"} {
+		cfg := driver.Config{OtelEndpoint: endpoint}
+		assert.ErrorContains(t, cfg.Validate(), "otel_endpoint", endpoint)
+	}
+}
+
+func TestConfig_ValidateForensics(t *testing.T) {
+	for _, forensics := range []driver.ForensicsConfig{{}, {History: 50, MaxBundles: 3}, {History: -1}} {
+		cfg := driver.Config{Forensics: forensics}
+		assert.NoError(t, cfg.Validate())
+	}
+
+	for name, bad := range map[string]driver.ForensicsConfig{
+		"negative history": {History: -2},
+		"negative bundles": {MaxBundles: -1},
+	} {
+		cfg := driver.Config{Forensics: bad}
+		assert.ErrorContains(t, cfg.Validate(), "forensics", name)
+	}
+}
+
+func TestConfig_ValidateDaemonEndpoints(t *testing.T) {
+	cfg := driver.Config{DaemonEndpoints: map[string]driver.DaemonEndpointConfig{
+		"gpu":    {Socket: "/run/elide-gpu.sock"},
+		"remote": {Address: "10.0.0.5:9000"},
+	}}
+	assert.NoError(t, cfg.Validate())
+
+	for name, bad := range map[string]driver.DaemonEndpointConfig{
+		"gpu":     {},
+		"both":    {Socket: "/run/elide.sock", Address: "10.0.0.5:9000"},
+		"default": {Socket: "/run/elide.sock"},
+		"gpu.a":   {Socket: "/run/elide.sock"},
+	} {
+		cfg := driver.Config{DaemonEndpoints: map[string]driver.DaemonEndpointConfig{name: bad}}
+		assert.ErrorContains(t, cfg.Validate(), "daemon_endpoint", name)
+	}
+}
+
+func TestConfig_ValidateSessionProfiles(t *testing.T) {
+	cfg := driver.Config{SessionProfiles: map[string]driver.SessionProfileConfig{
+		"small": {MemoryLimitMB: 256},
+		"large": {MemoryLimitMB: 4096, ContextPoolSize: 2},
+	}}
+	assert.NoError(t, cfg.Validate())
+
+	cfg.SessionProfiles["huge"] = driver.SessionProfileConfig{}
+	assert.ErrorContains(t, cfg.Validate(), "memory_limit_mb")
+
+	cfg.SessionProfiles["huge"] = driver.SessionProfileConfig{MemoryLimitMB: 8192, ContextPoolSize: -1}
+	assert.ErrorContains(t, cfg.Validate(), "context_pool_size")
+
+	delete(cfg.SessionProfiles, "huge")
+	cfg.SessionProfiles["x large"] = driver.SessionProfileConfig{MemoryLimitMB: 8192}
+	assert.ErrorContains(t, cfg.Validate(), "name may only contain")
+}
+
+func TestParseEnvFile(t *testing.T) {
+	input := `# database settings
+DB_HOST=localhost
+export DB_PORT = 5432
+EMPTY=
+COMMENTED=value # trailing comment
+SINGLE='literal $HOME \n'
+DOUBLE="line1\nline2 \"quoted\""
+HASH="a # b"
+DB_HOST=override
+`
+	env, err := driver.ParseEnvFile(strings.NewReader(input))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"DB_HOST":   "override",
+		"DB_PORT":   "5432",
+		"EMPTY":     "",
+		"COMMENTED": "value",
+		"SINGLE":    `literal $HOME \n`,
+		"DOUBLE":    "line1\nline2 \"quoted\"",
+		"HASH":      "a # b",
+	}, env)
+}
+
+func TestParseEnvFile_Errors(t *testing.T) {
+	tests := map[string]string{
+		"missing equals":      "JUST_A_NAME\n",
+		"bad name":            "BAD NAME=1\n",
+		"unterminated double": "A=\"open\n",
+		"unterminated single": "A='open\n",
+		"trailing garbage":    "A=\"x\" y\n",
+	}
+	for name, input := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := driver.ParseEnvFile(strings.NewReader(input))
+			assert.ErrorContains(t, err, "line 1")
+		})
+	}
+}
+
+func TestConfig_ValidateCompletionWait(t *testing.T) {
+	for _, wait := range []string{"", "0s", "250ms"} {
+		assert.NoError(t, (&driver.Config{CompletionWait: wait}).Validate(), wait)
+	}
+	assert.ErrorContains(t, (&driver.Config{CompletionWait: "soon"}).Validate(), "completion_wait")
+	assert.ErrorContains(t, (&driver.Config{CompletionWait: "10s"}).Validate(), "shorter than submit_timeout")
+	assert.NoError(t, (&driver.Config{CompletionWait: "10s", SubmitTimeout: "30s"}).Validate())
+}
@@ -0,0 +1,98 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package unit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/elide-dev/elide-task-driver/driver"
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/nomad/plugins/drivers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig_ValidateCredentialExchange(t *testing.T) {
+	for _, exchange := range []driver.CredentialExchangeConfig{
+		{},
+		{Endpoint: "https://sts.internal/token"},
+		{Endpoint: "http://127.0.0.1:8200/token", Identity: "internal_api", Env: "API_TOKEN", Timeout: "5s"},
+	} {
+		cfg := driver.Config{CredentialExchange: exchange}
+		assert.NoError(t, cfg.Validate(), exchange.Endpoint)
+	}
+
+	for name, bad := range map[string]driver.CredentialExchangeConfig{
+		"relative endpoint": {Endpoint: "sts.internal/token"},
+		"bad identity":      {Endpoint: "https://sts.internal/token", Identity: "a/b"},
+		"bad env":           {Endpoint: "https://sts.internal/token", Env: "API TOKEN"},
+		"zero timeout":      {Endpoint: "https://sts.internal/token", Timeout: "0s"},
+	} {
+		cfg := driver.Config{CredentialExchange: bad}
+		assert.ErrorContains(t, cfg.Validate(), "credential_exchange", name)
+	}
+}
+
+func TestWorkloadIdentityToken(t *testing.T) {
+	allocDir := t.TempDir()
+	cfg := &drivers.TaskConfig{AllocDir: allocDir, Name: "web", Env: map[string]string{"NOMAD_TOKEN": "env-jwt"}}
+	secrets := cfg.TaskDir().SecretsDir
+	require.NoError(t, os.MkdirAll(secrets, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(secrets, "nomad_internal.jwt"), []byte("file-jwt\n"), 0o600))
+
+	token, err := driver.WorkloadIdentityToken(cfg, "")
+	require.NoError(t, err)
+	assert.Equal(t, "env-jwt", token)
+
+	token, err = driver.WorkloadIdentityToken(cfg, "internal")
+	require.NoError(t, err)
+	assert.Equal(t, "file-jwt", token)
+
+	token, err = driver.WorkloadIdentityToken(cfg, "missing")
+	require.NoError(t, err)
+	assert.Empty(t, token)
+}
+
+func TestCredentialMinter_Mint(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		assert.Equal(t, "urn:ietf:params:oauth:grant-type:token-exchange", r.PostForm.Get("grant_type"))
+		assert.Equal(t, "urn:ietf:params:oauth:token-type:jwt", r.PostForm.Get("subject_token_type"))
+		assert.Equal(t, "internal-api", r.PostForm.Get("audience"))
+		if r.PostForm.Get("subject_token") != "task-jwt" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": "invalid_grant", "error_description": "token expired"}`))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token": "short-lived", "token_type": "Bearer", "expires_in": 900}`))
+	}))
+	defer server.Close()
+
+	minter := driver.NewCredentialMinter(driver.CredentialExchangeConfig{Endpoint: server.URL, Audience: "internal-api"}, hclog.NewNullLogger())
+
+	credential, err := minter.Mint(context.Background(), &drivers.TaskConfig{ID: "t1", Env: map[string]string{"NOMAD_TOKEN": "task-jwt"}})
+	require.NoError(t, err)
+	require.NotNil(t, credential)
+	assert.Equal(t, "short-lived", credential.Token)
+	assert.WithinDuration(t, time.Now().Add(15*time.Minute), credential.ExpiresAt, time.Minute)
+
+	env := map[string]string{}
+	minter.Apply(env, credential)
+	assert.Equal(t, "short-lived", env["ELIDE_CREDENTIAL"])
+	assert.Equal(t, credential.ExpiresAt.Format(time.RFC3339), env["ELIDE_CREDENTIAL_EXPIRES_AT"])
+
+	_, err = minter.Mint(context.Background(), &drivers.TaskConfig{ID: "t2", Env: map[string]string{"NOMAD_TOKEN": "stale-jwt"}})
+	assert.EqualError(t, err, "token exchange returned 400 Bad Request: invalid_grant token expired")
+
+	credential, err = minter.Mint(context.Background(), &drivers.TaskConfig{ID: "t3"})
+	require.NoError(t, err)
+	assert.Nil(t, credential, "no credential without a workload identity")
+}
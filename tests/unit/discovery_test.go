@@ -0,0 +1,71 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package unit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/elide-dev/elide-task-driver/driver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDaemonConsulConfig_Resolve(t *testing.T) {
+	var query, token string
+	consul := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/health/service/elide-daemon", r.URL.Path)
+		query, token = r.URL.RawQuery, r.Header.Get("X-Consul-Token")
+		w.Write([]byte(`[
+			{"Node": {"Address": "10.0.0.1"}, "Service": {"Address": "", "Port": 7000}},
+			{"Node": {"Address": "10.0.0.2"}, "Service": {"Address": "192.168.1.2", "Port": 7001}}
+		]`))
+	}))
+	defer consul.Close()
+
+	cfg := driver.DaemonConsulConfig{Address: consul.URL, Datacenter: "dc2", Tag: "grpc", Token: "secret"}
+	addrs, err := cfg.Resolve(context.Background(), "elide-daemon")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.1:7000", "192.168.1.2:7001"}, addrs)
+	assert.Contains(t, query, "passing=true")
+	assert.Contains(t, query, "dc=dc2")
+	assert.Contains(t, query, "tag=grpc")
+	assert.Equal(t, "secret", token)
+}
+
+func TestDaemonConsulConfig_ResolveErrors(t *testing.T) {
+	var body string
+	status := http.StatusOK
+	consul := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	defer consul.Close()
+	cfg := driver.DaemonConsulConfig{Address: consul.URL}
+
+	body = `[]`
+	_, err := cfg.Resolve(context.Background(), "elide-daemon")
+	assert.ErrorContains(t, err, "no healthy instances")
+
+	status, body = http.StatusForbidden, "ACL not found"
+	_, err = cfg.Resolve(context.Background(), "elide-daemon")
+	assert.ErrorContains(t, err, "ACL not found")
+}
+
+func TestConfig_ValidateDaemonConsul(t *testing.T) {
+	cfg := driver.Config{DaemonConsulService: "elide-daemon", DaemonConsul: driver.DaemonConsulConfig{Lookup: "dns", DNSServer: "127.0.0.1:8600"}}
+	assert.NoError(t, cfg.Validate())
+
+	cfg.DaemonConsul.Lookup = "mdns"
+	assert.ErrorContains(t, cfg.Validate(), "invalid lookup")
+
+	cfg.DaemonConsul = driver.DaemonConsulConfig{DNSServer: "127.0.0.1"}
+	assert.ErrorContains(t, cfg.Validate(), "dns_server")
+
+	cfg.DaemonConsul = driver.DaemonConsulConfig{}
+	cfg.DaemonAddress = "127.0.0.1:9000"
+	assert.ErrorContains(t, cfg.Validate(), "daemon_consul_service")
+}
@@ -0,0 +1,45 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package unit
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/elide-dev/elide-task-driver/driver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestForensicBundleName(t *testing.T) {
+	at := time.Date(2024, 5, 1, 12, 30, 45, 123_000_000, time.UTC)
+	assert.Equal(t, "20240501T123045.123Z-session_lost.tar.gz", driver.ForensicBundleName("session_lost", at))
+
+	// Names sort by capture time, whatever the trigger
+	later := driver.ForensicBundleName("daemon_unreachable", at.Add(time.Second))
+	assert.Less(t, driver.ForensicBundleName("session_lost", at), later)
+}
+
+func TestPruneForensicBundles(t *testing.T) {
+	dir := t.TempDir()
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	var names []string
+	for i := range 4 {
+		name := driver.ForensicBundleName("session_lost", at.Add(time.Duration(i)*time.Minute))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), nil, 0600))
+		names = append(names, name)
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "notes.txt"), nil, 0600))
+
+	require.NoError(t, driver.PruneForensicBundles(dir, 2))
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	var left []string
+	for _, entry := range entries {
+		left = append(left, entry.Name())
+	}
+	assert.ElementsMatch(t, []string{names[2], names[3], "notes.txt"}, left)
+}
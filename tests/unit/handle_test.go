@@ -4,7 +4,12 @@
 package unit
 
 import (
+	"errors"
 	"testing"
+
+	"github.com/elide-dev/elide-task-driver/driver"
+	pb "github.com/elide-dev/elide-task-driver/proto/gen/go/elide/daemon/v1alpha1"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestTaskHandle_IsRunning(t *testing.T) {
@@ -17,3 +22,45 @@ func TestTaskHandle_IsRunning(t *testing.T) {
 // These tests will be expanded when we add driver-level integration tests
 // that test the handle through the driver's public API
 
+
+func TestExitResultFor(t *testing.T) {
+	// Completed executions report their exit code as is
+	result := driver.ExitResultFor(pb.ExecutionStatus_EXECUTION_STATUS_COMPLETED, 0, nil)
+	assert.True(t, result.Successful())
+	result = driver.ExitResultFor(pb.ExecutionStatus_EXECUTION_STATUS_COMPLETED, 3, nil)
+	assert.Equal(t, 3, result.ExitCode)
+	assert.NoError(t, result.Err)
+
+	// Failed executions never succeed
+	result = driver.ExitResultFor(pb.ExecutionStatus_EXECUTION_STATUS_FAILED, 0, nil)
+	assert.Equal(t, 1, result.ExitCode)
+	assert.EqualError(t, result.Err, "execution failed")
+	result = driver.ExitResultFor(pb.ExecutionStatus_EXECUTION_STATUS_FAILED, 130, errors.New("terminated by SIGINT"))
+	assert.Equal(t, 130, result.ExitCode)
+	assert.Equal(t, 2, result.Signal)
+	assert.EqualError(t, result.Err, "terminated by SIGINT")
+
+	// Cancelled executions keep the recorded cancellation
+	cancelled := errors.New("execution cancelled (user_stop, initiated by nomad)")
+	result = driver.ExitResultFor(pb.ExecutionStatus_EXECUTION_STATUS_CANCELLED, 0, cancelled)
+	assert.False(t, result.Successful())
+	assert.Equal(t, cancelled, result.Err)
+	result = driver.ExitResultFor(pb.ExecutionStatus_EXECUTION_STATUS_CANCELLED, 0, nil)
+	assert.EqualError(t, result.Err, "execution cancelled")
+
+	// A daemon claiming completion without a terminal status is not trusted
+	result = driver.ExitResultFor(pb.ExecutionStatus_EXECUTION_STATUS_RUNNING, 0, nil)
+	assert.EqualError(t, result.Err, "execution completed with status running")
+	assert.Zero(t, result.Signal)
+}
+
+func TestCancelToken(t *testing.T) {
+	stop := driver.CancelToken("exec-1", pb.CancellationReason_CANCELLATION_REASON_USER_STOP, "nomad")
+	assert.Equal(t, "exec-1/user_stop/nomad", stop)
+
+	// Retries of a cancellation carry the same token
+	assert.Equal(t, stop, driver.CancelToken("exec-1", pb.CancellationReason_CANCELLATION_REASON_USER_STOP, "nomad"))
+
+	assert.NotEqual(t, stop, driver.CancelToken("exec-2", pb.CancellationReason_CANCELLATION_REASON_USER_STOP, "nomad"))
+	assert.NotEqual(t, stop, driver.CancelToken("exec-1", pb.CancellationReason_CANCELLATION_REASON_TIMEOUT, "driver"))
+}
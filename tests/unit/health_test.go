@@ -0,0 +1,61 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package unit
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/elide-dev/elide-task-driver/driver"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealthEvaluator(t *testing.T) {
+	e := driver.NewHealthEvaluator(3)
+	assert.Equal(t, driver.DaemonStarting, e.Current().Phase, "the daemon is starting until its first check")
+	assert.False(t, e.Current().Ready())
+
+	blip := errors.New("connection refused")
+	health := e.Failed(blip, false)
+	assert.Equal(t, driver.DaemonStarting, health.Phase, "a daemon that never passed a check is starting")
+	assert.Equal(t, 1, health.ConsecutiveFailures)
+
+	health = e.Passed()
+	assert.Equal(t, driver.DaemonHealthy, health.Phase)
+	assert.Zero(t, health.ConsecutiveFailures)
+	assert.Equal(t, "connection refused", health.LastError, "the last error is kept after recovering")
+	assert.True(t, health.Ready())
+
+	health = e.Failed(blip, false)
+	assert.Equal(t, driver.DaemonFlapping, health.Phase)
+	assert.True(t, health.Ready(), "failures below the threshold are tolerated")
+	e.Failed(blip, false)
+	health = e.Failed(errors.New("socket not found"), false)
+	assert.Equal(t, driver.DaemonDead, health.Phase)
+	assert.Equal(t, 3, health.ConsecutiveFailures)
+	assert.Equal(t, "socket not found", health.LastError)
+	assert.False(t, health.Ready())
+
+	health = e.Failed(blip, true)
+	assert.Equal(t, driver.DaemonStarting, health.Phase, "a daemon its supervisor is starting is not dead")
+
+	health = e.Incompatible(errors.New("daemon version 0.9.0 does not satisfy >= 1.0"))
+	assert.Equal(t, driver.DaemonIncompatible, health.Phase)
+	assert.Zero(t, health.ConsecutiveFailures)
+	assert.False(t, health.Ready())
+}
+
+func TestHealthEvaluator_NoFlapProtection(t *testing.T) {
+	e := driver.NewHealthEvaluator(1)
+	e.Passed()
+	assert.Equal(t, driver.DaemonDead, e.Failed(errors.New("connection refused"), false).Phase)
+}
+
+func TestConfig_ValidateHealthFailureThreshold(t *testing.T) {
+	cfg := driver.Config{HealthFailureThreshold: 5}
+	assert.NoError(t, cfg.Validate())
+
+	cfg.HealthFailureThreshold = -1
+	assert.ErrorContains(t, cfg.Validate(), "health_failure_threshold cannot be negative")
+}
@@ -0,0 +1,41 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package unit
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/elide-dev/elide-task-driver/driver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInterpolateTask(t *testing.T) {
+	env := map[string]string{
+		"NOMAD_ALLOC_DIR": "/alloc",
+		"API_TOKEN":       "s3cret",
+	}
+
+	tc := &driver.TaskConfig{
+		Code:       "read('${NOMAD_ALLOC_DIR}/in'); auth('${env.API_TOKEN}'); log(`${name}`); log('$${env.API_TOKEN}')",
+		Args:       []string{"--dir=${env.NOMAD_ALLOC_DIR}", "plain"},
+		ArgsMatrix: [][]string{{"--token", "${env.API_TOKEN}"}},
+	}
+	require.NoError(t, driver.InterpolateTask(tc, env))
+	assert.Equal(t, "read('/alloc/in'); auth('s3cret'); log(`${name}`); log('${env.API_TOKEN}')", tc.Code,
+		"other ${...} forms are left alone and $${...} is escaped")
+	assert.Equal(t, []string{"--dir=/alloc", "plain"}, tc.Args)
+	assert.Equal(t, [][]string{{"--token", "s3cret"}}, tc.ArgsMatrix)
+
+	err := driver.InterpolateTask(&driver.TaskConfig{Args: []string{"${env.MISSING}"}}, env)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "args[0]")
+	assert.Contains(t, err.Error(), "${env.MISSING} is not set")
+
+	huge := map[string]string{"BIG": strings.Repeat("x", 100<<10)}
+	err = driver.InterpolateTask(&driver.TaskConfig{Code: "${env.BIG}${env.BIG}"}, huge)
+	require.Error(t, err, "the substituted bytes are capped")
+	assert.Contains(t, err.Error(), "substituted variables exceed")
+}
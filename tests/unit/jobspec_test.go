@@ -0,0 +1,113 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package unit
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/elide-dev/elide-task-driver/driver"
+	"github.com/elide-dev/elide-task-driver/tests/helpers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite the golden files of the job spec fixtures")
+
+// Each fixture in testdata/jobspecs is a task stanza whose config block is
+// decoded through the task config schema and MsgPack as Nomad does, and
+// compared with its .golden.json. Run with -update after an intended change.
+func TestJobSpec_GoldenFixtures(t *testing.T) {
+	fixtures, err := filepath.Glob("testdata/jobspecs/*.hcl")
+	require.NoError(t, err)
+	require.NotEmpty(t, fixtures)
+
+	for _, fixture := range fixtures {
+		name := strings.TrimSuffix(filepath.Base(fixture), ".hcl")
+		t.Run(name, func(t *testing.T) {
+			stanza, err := os.ReadFile(fixture)
+			require.NoError(t, err)
+			tc := helpers.ParseTaskConfig(t, string(stanza))
+
+			actual, err := json.MarshalIndent(tc, "", "  ")
+			require.NoError(t, err)
+			actual = append(actual, '\n')
+
+			golden := strings.TrimSuffix(fixture, ".hcl") + ".golden.json"
+			if *updateGolden {
+				require.NoError(t, os.WriteFile(golden, actual, 0o644))
+			}
+			expected, err := os.ReadFile(golden)
+			require.NoError(t, err, "run with -update to create the golden file")
+			assert.JSONEq(t, string(expected), string(actual))
+		})
+	}
+}
+
+// The fixtures of valid tasks pass validation; all_options combines
+// exclusive options on purpose.
+func TestJobSpec_FixturesValidate(t *testing.T) {
+	for _, name := range []string{"inline_code", "script", "args_matrix", "workspace", "elide_opts"} {
+		stanza, err := os.ReadFile(filepath.Join("testdata/jobspecs", name+".hcl"))
+		require.NoError(t, err)
+		assert.NoError(t, helpers.ParseTaskConfig(t, string(stanza)).Validate(), name)
+	}
+}
+
+func TestJobSpec_SchemaMatchesTaskConfig(t *testing.T) {
+	spec := helpers.TaskConfigSpec(t)
+	assert.Equal(t, helpers.SpecFields(spec), helpers.CodecFields(driver.TaskConfig{}),
+		"every task config option needs a TaskConfig field with a matching codec tag, and vice versa")
+}
+
+// An option the schema accepts but the struct does not decode, such as a
+// field missing its codec tag, is silently dropped; all_options sets every
+// option, so each field must come out set.
+func TestJobSpec_EveryOptionDecodes(t *testing.T) {
+	stanza, err := os.ReadFile("testdata/jobspecs/all_options.hcl")
+	require.NoError(t, err)
+	tc := helpers.ParseTaskConfig(t, string(stanza))
+
+	var unset []string
+	var walk func(prefix string, v reflect.Value)
+	walk = func(prefix string, v reflect.Value) {
+		for i := range v.NumField() {
+			field, value := v.Type().Field(i), v.Field(i)
+			if value.Kind() == reflect.Struct {
+				walk(prefix+field.Name+".", value)
+				continue
+			}
+			if value.IsZero() {
+				unset = append(unset, prefix+field.Name)
+			}
+		}
+	}
+	walk("", reflect.ValueOf(*tc))
+	assert.Empty(t, unset, "options set in all_options.hcl that did not decode")
+}
+
+func TestJobSpec_JSONMatchesHCL(t *testing.T) {
+	fromHCL := helpers.ParseTaskConfig(t, `
+config {
+  script = "local/main.py"
+  args   = ["--verbose"]
+  elide_opts {
+    timeout = "30s"
+  }
+}`)
+	fromJSON := helpers.ParseTaskConfigJSON(t, `{
+  "Config": {
+    "script": "local/main.py",
+    "args": ["--verbose"],
+    "elide_opts": [{"timeout": "30s"}]
+  }
+}`)
+	assert.Equal(t, fromHCL, fromJSON)
+	assert.Equal(t, "log", fromJSON.OutputMode, "schema defaults apply")
+}
@@ -0,0 +1,63 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package unit
+
+import (
+	"testing"
+
+	"github.com/elide-dev/elide-task-driver/driver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTaskConfig_InferLanguage(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  driver.TaskConfig
+		want    string
+		wantErr string
+	}{
+		{name: "python script", config: driver.TaskConfig{Script: "local/main.py"}, want: "python"},
+		{name: "javascript script", config: driver.TaskConfig{Script: "local/main.js"}, want: "javascript"},
+		{name: "javascript module", config: driver.TaskConfig{Script: "local/main.mjs"}, want: "javascript"},
+		{name: "typescript script", config: driver.TaskConfig{Script: "local/main.ts"}, want: "typescript"},
+		{name: "entrypoint", config: driver.TaskConfig{Entrypoint: "src/index.ts"}, want: "typescript"},
+		{name: "oci entrypoint", config: driver.TaskConfig{CodeOCIRef: "ghcr.io/acme/app:1", CodeOCIEntrypoint: "app.js"}, want: "javascript"},
+		{name: "oci without entrypoint", config: driver.TaskConfig{CodeOCIRef: "ghcr.io/acme/app:1"}, want: "python"},
+		{name: "inline code", config: driver.TaskConfig{Code: "print(1)"}, want: "python"},
+		{name: "explicit override", config: driver.TaskConfig{Script: "local/main.py", Language: "javascript"}, want: "javascript"},
+		{name: "override of unknown extension", config: driver.TaskConfig{Script: "local/main.txt", Language: "python"}, want: "python"},
+		{
+			name:    "unknown extension",
+			config:  driver.TaskConfig{Script: "local/main.rb"},
+			wantErr: `cannot infer language of script "local/main.rb": unknown extension ".rb"; set language`,
+		},
+		{
+			name:    "no extension",
+			config:  driver.TaskConfig{Script: "local/run"},
+			wantErr: `cannot infer language of script "local/run" without a file extension; set language`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.config.InferLanguage()
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestTaskConfig_ValidateForInferredLanguage(t *testing.T) {
+	tc := driver.TaskConfig{Script: "local/main.ts"}
+	assert.NoError(t, tc.ValidateFor([]string{"typescript"}))
+
+	err := tc.ValidateFor([]string{"python"})
+	assert.EqualError(t, err, `language "typescript" not enabled in session (enabled: [python])`)
+	assert.Equal(t, []string{"language"}, driver.InvalidFields(err))
+}
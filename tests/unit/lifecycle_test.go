@@ -0,0 +1,28 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package unit
+
+import (
+	"testing"
+
+	"github.com/elide-dev/elide-task-driver/driver"
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/nomad/nomad/structs"
+	"github.com/hashicorp/nomad/plugins/drivers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShutdown_RejectsNewTasks(t *testing.T) {
+	plugin := driver.NewPlugin(hclog.NewNullLogger()).(*driver.ElideDriverPlugin)
+	plugin.Shutdown()
+
+	_, _, err := plugin.StartTask(&drivers.TaskConfig{ID: "task-1"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "driver shutting down")
+	assert.True(t, structs.IsRecoverable(err))
+
+	// A second shutdown is a no-op
+	plugin.Shutdown()
+}
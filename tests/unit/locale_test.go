@@ -0,0 +1,70 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package unit
+
+import (
+	"testing"
+
+	"github.com/elide-dev/elide-task-driver/driver"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateTimezone(t *testing.T) {
+	for _, tz := range []string{"", "UTC", "Europe/Berlin", "America/Argentina/Buenos_Aires", "Etc/GMT+5"} {
+		assert.NoError(t, driver.ValidateTimezone("timezone", tz), tz)
+	}
+	for _, tz := range []string{"Local", "Mars/Olympus_Mons", "../etc/passwd", "CEST"} {
+		assert.ErrorContains(t, driver.ValidateTimezone("timezone", tz), "invalid timezone", tz)
+	}
+}
+
+func TestValidateLocale(t *testing.T) {
+	for _, locale := range []string{"", "C", "POSIX", "C.UTF-8", "en_US.UTF-8", "de_DE", "sr_RS@latin", "ja_JP.eucJP"} {
+		assert.NoError(t, driver.ValidateLocale("locale", locale), locale)
+	}
+	for _, locale := range []string{"en-US", "english please", "de_DE.UTF-8; rm -rf /", "_US"} {
+		assert.ErrorContains(t, driver.ValidateLocale("locale", locale), "invalid locale", locale)
+	}
+}
+
+func TestApplyLocale(t *testing.T) {
+	env := map[string]string{}
+	timezone, locale := driver.ApplyLocale(env, driver.ElideOptions{}, "", "")
+	assert.Empty(t, timezone)
+	assert.Empty(t, locale)
+	assert.Empty(t, env, "no defaults")
+
+	env = map[string]string{}
+	timezone, locale = driver.ApplyLocale(env, driver.ElideOptions{}, "UTC", "C.UTF-8")
+	assert.Equal(t, "UTC", timezone)
+	assert.Equal(t, "C.UTF-8", locale)
+	assert.Equal(t, map[string]string{"TZ": "UTC", "LANG": "C.UTF-8"}, env)
+
+	env = map[string]string{"TZ": "Asia/Tokyo", "LANG": "ja_JP.UTF-8"}
+	timezone, locale = driver.ApplyLocale(env, driver.ElideOptions{}, "UTC", "C.UTF-8")
+	assert.Equal(t, "Asia/Tokyo", timezone, "env beats the plugin default")
+	assert.Equal(t, "ja_JP.UTF-8", locale)
+
+	opts := driver.ElideOptions{Timezone: "Europe/Berlin", Locale: "de_DE.UTF-8"}
+	timezone, locale = driver.ApplyLocale(env, opts, "UTC", "C.UTF-8")
+	assert.Equal(t, "Europe/Berlin", timezone, "elide_opts beat env")
+	assert.Equal(t, "de_DE.UTF-8", locale)
+	assert.Equal(t, map[string]string{"TZ": "Europe/Berlin", "LANG": "de_DE.UTF-8"}, env)
+}
+
+func TestTaskConfig_ValidateLocale(t *testing.T) {
+	tc := driver.TaskConfig{Code: "print(1)", Language: "python", ElideOpts: driver.ElideOptions{Timezone: "Europe/Berlin", Locale: "de_DE.UTF-8"}}
+	assert.NoError(t, tc.Validate())
+
+	tc.ElideOpts.Timezone = "Berlin"
+	assert.ErrorContains(t, tc.Validate(), "elide_opts.timezone")
+
+	tc.ElideOpts.Timezone, tc.ElideOpts.Locale = "", "de-DE"
+	assert.ErrorContains(t, tc.Validate(), "elide_opts.locale")
+
+	cfg := driver.Config{DefaultTimezone: "UTC", DefaultLocale: "C.UTF-8"}
+	assert.NoError(t, cfg.Validate())
+	cfg.DefaultTimezone = "utc+1"
+	assert.ErrorContains(t, cfg.Validate(), "default_timezone")
+}
@@ -0,0 +1,76 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package unit
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/elide-dev/elide-task-driver/driver"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestErrorClass(t *testing.T) {
+	unavailable := status.Error(codes.Unavailable, "connection refused")
+	assert.Equal(t, "Unavailable", driver.ErrorClass(unavailable))
+	assert.Equal(t, "Unavailable", driver.ErrorClass(fmt.Errorf("failed to get execution status: %w", unavailable)))
+	assert.Empty(t, driver.ErrorClass(errors.New("scratch quota exceeded")))
+	assert.Empty(t, driver.ErrorClass("connection refused"))
+	assert.Empty(t, driver.ErrorClass(nil))
+}
+
+func TestLogThrottle(t *testing.T) {
+	var out bytes.Buffer
+	base := hclog.New(&hclog.LoggerOptions{Output: &out, Level: hclog.Debug})
+	throttle := driver.NewLogThrottle(base, time.Minute)
+	logger := throttle.Wrap(base)
+
+	unavailable := status.Error(codes.Unavailable, "connection refused")
+	for i := 0; i < 100; i++ {
+		logger.With("task_id", fmt.Sprintf("task-%d", i)).Warn("failed to get execution status", "error", unavailable)
+	}
+	logger.Warn("failed to get execution status", "error", status.Error(codes.NotFound, "execution not found"))
+	logger.Warn("scratch directory over quota", "error", errors.New("quota exceeded"))
+	logger.Warn("scratch directory over quota", "error", errors.New("quota exceeded"))
+	logger.Debug("daemon RPC", "error", unavailable)
+	logger.Debug("daemon RPC", "error", unavailable)
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Len(t, lines, 6, "first of each class, other errors and debug logs")
+	assert.Contains(t, lines[0], "task_id=task-0")
+	assert.Contains(t, lines[1], "code = NotFound")
+
+	out.Reset()
+	throttle.Flush(time.Now())
+	assert.Empty(t, out.String(), "window not over")
+
+	throttle.Flush(time.Now().Add(time.Minute))
+	assert.Contains(t, out.String(), "suppressed 99 similar errors in last 1m0s")
+	assert.Contains(t, out.String(), `message="failed to get execution status" code=Unavailable`)
+	assert.NotContains(t, out.String(), "task_id")
+
+	out.Reset()
+	logger.Warn("failed to get execution status", "error", unavailable)
+	assert.Contains(t, out.String(), "failed to get execution status", "new window")
+}
+
+func TestLogThrottle_Disabled(t *testing.T) {
+	var out bytes.Buffer
+	base := hclog.New(&hclog.LoggerOptions{Output: &out})
+	throttle := driver.NewLogThrottle(base, time.Minute)
+	throttle.SetWindow(0)
+	logger := throttle.Wrap(base)
+
+	for i := 0; i < 3; i++ {
+		logger.Named("rpc").Error("daemon RPC failed", "error", status.Error(codes.Internal, "boom"))
+	}
+	assert.Equal(t, 3, strings.Count(out.String(), "daemon RPC failed"))
+}
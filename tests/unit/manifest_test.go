@@ -0,0 +1,92 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package unit
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/elide-dev/elide-task-driver/driver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testManifestYAML = `
+session:
+  context_pool_size: 4
+  enabled_languages: [python, javascript]
+  memory_limit_mb: 1024
+init:
+  - language: python
+    code: import json
+warm:
+  - language: javascript
+    code: "1 + 1"
+language_defaults:
+  python:
+    interpreter_args: ["-X", "utf8"]
+    env:
+      PYTHONHASHSEED: "0"
+`
+
+func TestLoadSessionManifest(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "session.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(testManifestYAML), 0o644))
+
+	m, err := driver.LoadSessionManifest(path)
+	require.NoError(t, err)
+	require.NotNil(t, m.Session)
+	assert.Equal(t, 4, m.Session.ContextPoolSize)
+	assert.Equal(t, []string{"python", "javascript"}, m.Session.EnabledLanguages)
+	assert.Equal(t, []driver.ManifestSnippet{{Language: "python", Code: "import json"}}, m.Init)
+	assert.Len(t, m.Warm, 1)
+	assert.Equal(t, "0", m.LanguageDefaults["python"].Env["PYTHONHASHSEED"])
+	assert.NotEmpty(t, m.Revision())
+
+	jsonPath := filepath.Join(dir, "session.json")
+	require.NoError(t, os.WriteFile(jsonPath, []byte(`{"session": {"context_pool_size": 4, "enabled_languages": ["python", "javascript"], "memory_limit_mb": 1024}, "init": [{"language": "python", "code": "import json"}]}`), 0o644))
+	fromJSON, err := driver.LoadSessionManifest(jsonPath)
+	require.NoError(t, err)
+	assert.Equal(t, m.Revision(), fromJSON.Revision(), "same session and init code")
+}
+
+func TestParseSessionManifest_Revision(t *testing.T) {
+	base, err := driver.ParseSessionManifest([]byte(`init: [{language: python, code: "import os"}]`), false)
+	require.NoError(t, err)
+
+	// Warm scripts and language defaults do not require a new session
+	warm, err := driver.ParseSessionManifest([]byte(`
+init: [{language: python, code: "import os"}]
+warm: [{language: python, code: "pass"}]
+language_defaults: {python: {env: {A: "1"}}}
+`), false)
+	require.NoError(t, err)
+	assert.Equal(t, base.Revision(), warm.Revision())
+
+	changed, err := driver.ParseSessionManifest([]byte(`init: [{language: python, code: "import sys"}]`), false)
+	require.NoError(t, err)
+	assert.NotEqual(t, base.Revision(), changed.Revision())
+}
+
+func TestParseSessionManifest_Invalid(t *testing.T) {
+	tests := map[string]string{
+		"unknown field":    `sesion: {}`,
+		"unknown language": `init: [{language: ruby, code: "puts 1"}]`,
+		"empty code":       `warm: [{language: python, code: "  "}]`,
+		"negative pool":    `session: {context_pool_size: -1}`,
+		"bad env name":     `language_defaults: {python: {env: {"A=B": x}}}`,
+		"bad defaults key": `language_defaults: {ruby: {}}`,
+	}
+	for name, manifest := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := driver.ParseSessionManifest([]byte(manifest), false)
+			assert.Error(t, err)
+		})
+	}
+
+	_, err := driver.ParseSessionManifest([]byte(`{"session": {}, "extra": 1}`), true)
+	assert.Error(t, err)
+}
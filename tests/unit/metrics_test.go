@@ -0,0 +1,39 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package unit
+
+import (
+	"testing"
+
+	"github.com/elide-dev/elide-task-driver/driver"
+	"github.com/hashicorp/nomad/plugins/drivers"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTaskMetricLabels(t *testing.T) {
+	cfg := &drivers.TaskConfig{Namespace: "prod", JobName: "etl", TaskGroupName: "workers"}
+
+	assert.Equal(t, []string{"language", "python"}, driver.TaskMetricLabels(cfg, "python", []string{"language"}))
+	assert.Equal(t,
+		[]string{"job", "etl", "namespace", "prod", "task_group", "workers"},
+		driver.TaskMetricLabels(cfg, "python", []string{"job", "namespace", "task_group"}))
+	assert.Empty(t, driver.TaskMetricLabels(cfg, "python", nil))
+}
+
+func TestConfig_ValidateMetricsLabels(t *testing.T) {
+	for _, cfg := range []driver.Config{
+		{},
+		{MetricsTaskLabels: []string{"namespace", "job", "task_group", "language"}, MetricsMaxSeries: 500},
+	} {
+		assert.NoError(t, cfg.Validate())
+	}
+
+	for name, cfg := range map[string]driver.Config{
+		"unknown label":   {MetricsTaskLabels: []string{"alloc_id"}},
+		"duplicate label": {MetricsTaskLabels: []string{"job", "job"}},
+		"negative cap":    {MetricsMaxSeries: -1},
+	} {
+		assert.ErrorContains(t, cfg.Validate(), "metrics_", name)
+	}
+}
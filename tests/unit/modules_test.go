@@ -0,0 +1,95 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package unit
+
+import (
+	"testing"
+
+	"github.com/elide-dev/elide-task-driver/driver"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScanImports_Python(t *testing.T) {
+	code := `import json
+import numpy as np, os.path
+from subprocess import run
+# import socket
+mod = __import__("ctypes")
+other = importlib.import_module('shutil')
+`
+	assert.ElementsMatch(t,
+		[]string{"json", "numpy", "os.path", "subprocess", "ctypes", "shutil"},
+		driver.ScanImports("python", code))
+}
+
+func TestScanImports_JavaScript(t *testing.T) {
+	code := `const cp = require('child_process');
+import fs from "node:fs/promises";
+import { a,
+  b } from './local.js';
+import "polyfill";
+const net = await import("net");
+export { x } from 'reexported';
+`
+	assert.ElementsMatch(t,
+		[]string{"child_process", "fs/promises", "./local.js", "polyfill", "net", "reexported"},
+		driver.ScanImports("javascript", code))
+}
+
+func TestDeniedImports(t *testing.T) {
+	tests := []struct {
+		name     string
+		language string
+		code     string
+		denied   []string
+		want     []string
+	}{
+		{
+			name:     "python submodule",
+			language: "python",
+			code:     "import os.path\nimport osmium\n",
+			denied:   []string{"os"},
+			want:     []string{"os.path"},
+		},
+		{
+			name:     "javascript node prefix",
+			language: "javascript",
+			code:     `const { exec } = require("node:child_process")`,
+			denied:   []string{"child_process"},
+			want:     []string{"child_process"},
+		},
+		{
+			name:     "typescript subpath",
+			language: "typescript",
+			code:     `import { readFile } from "fs/promises"`,
+			denied:   []string{"fs"},
+			want:     []string{"fs/promises"},
+		},
+		{
+			name:     "nothing denied",
+			language: "python",
+			code:     "import os\n",
+		},
+		{
+			name:     "clean code",
+			language: "python",
+			code:     "print(1)\n",
+			denied:   []string{"os"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, driver.DeniedImports(tt.language, tt.code, tt.denied))
+		})
+	}
+}
+
+func TestDeniedModulesConfig_Validate(t *testing.T) {
+	cfg := driver.Config{DeniedModules: driver.DeniedModulesConfig{Policy: "warn"}}
+	assert.NoError(t, cfg.Validate())
+
+	cfg.DeniedModules.Policy = "block"
+	assert.ErrorContains(t, cfg.Validate(), "denied_modules")
+}
@@ -0,0 +1,42 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package unit
+
+import (
+	"testing"
+
+	"github.com/elide-dev/elide-task-driver/driver"
+	"github.com/hashicorp/nomad/plugins/drivers"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNetworkEnv(t *testing.T) {
+	cfg := &drivers.TaskConfig{
+		Env: map[string]string{
+			"NOMAD_ALLOC_IP_http":        "172.26.64.12",
+			"NOMAD_ALLOC_PORT_http":      "8080",
+			"NOMAD_ALLOC_ADDR_http":      "172.26.64.12:8080",
+			"NOMAD_ALLOC_INTERFACE_http": "eth0",
+			"NOMAD_HOST_PORT_http":       "25431",
+			"NOMAD_PORT_http":            "8080",
+			"NOMAD_ALLOC_ID":             "alloc-1",
+			"PATH":                       "/usr/bin",
+		},
+	}
+
+	// Host networking passes nothing
+	assert.False(t, driver.IsNetworked(cfg))
+	assert.Nil(t, driver.NetworkEnv(cfg))
+
+	cfg.NetworkIsolation = &drivers.NetworkIsolationSpec{Mode: drivers.NetIsolationModeGroup, Path: "/var/run/netns/alloc-1"}
+	assert.True(t, driver.IsNetworked(cfg))
+	assert.Equal(t, map[string]string{
+		"NOMAD_ALLOC_IP_http":        "172.26.64.12",
+		"NOMAD_ALLOC_PORT_http":      "8080",
+		"NOMAD_ALLOC_ADDR_http":      "172.26.64.12:8080",
+		"NOMAD_ALLOC_INTERFACE_http": "eth0",
+		"NOMAD_HOST_PORT_http":       "25431",
+		"NOMAD_PORT_http":            "8080",
+	}, driver.NetworkEnv(cfg))
+}
@@ -0,0 +1,55 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package unit
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/elide-dev/elide-task-driver/driver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCPUList(t *testing.T) {
+	cpus, err := driver.ParseCPUList("0-3,8,10-11\n")
+	require.NoError(t, err)
+	assert.Equal(t, []int{0, 1, 2, 3, 8, 10, 11}, cpus)
+
+	cpus, err = driver.ParseCPUList("")
+	require.NoError(t, err)
+	assert.Empty(t, cpus)
+
+	for _, cpulist := range []string{"a", "3-1", "-1", "0-"} {
+		_, err := driver.ParseCPUList(cpulist)
+		assert.Error(t, err, "cpulist %q", cpulist)
+	}
+}
+
+func TestNUMANodes(t *testing.T) {
+	root := t.TempDir()
+	for node, cpulist := range map[string]string{"node0": "0-3\n", "node1": "4-7\n"} {
+		require.NoError(t, os.Mkdir(filepath.Join(root, node), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(root, node, "cpulist"), []byte(cpulist), 0o644))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(root, "online"), []byte("0-1\n"), 0o644))
+
+	nodes, err := driver.NUMANodes(root)
+	require.NoError(t, err)
+	assert.Equal(t, map[int]string{0: "0-3", 1: "4-7"}, nodes)
+
+	nodes, err = driver.NUMANodes(filepath.Join(root, "missing"))
+	require.NoError(t, err)
+	assert.Empty(t, nodes)
+}
+
+func TestTaskConfig_ValidateNumaNode(t *testing.T) {
+	node := 1
+	tc := driver.TaskConfig{Language: "python", Code: "print(1)", ElideOpts: driver.ElideOptions{NumaNode: &node}}
+	assert.NoError(t, tc.Validate())
+
+	node = -1
+	assert.ErrorContains(t, tc.Validate(), "numa_node")
+}
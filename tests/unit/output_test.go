@@ -0,0 +1,66 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package unit
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elide-dev/elide-task-driver/driver"
+	pb "github.com/elide-dev/elide-task-driver/proto/gen/go/elide/daemon/v1alpha1"
+)
+
+const (
+	stdoutStream = pb.OutputStream_OUTPUT_STREAM_STDOUT
+	stderrStream = pb.OutputStream_OUTPUT_STREAM_STDERR
+)
+
+func TestOrderOutput_InterleavesBySequence(t *testing.T) {
+	segments := []*pb.OutputSegment{
+		{Sequence: 3, Stream: stdoutStream, Offset: 8, Length: 5},
+		{Sequence: 1, Stream: stdoutStream, Offset: 0, Length: 8},
+		{Sequence: 2, Stream: stderrStream, Offset: 0, Length: 11},
+	}
+
+	writes := driver.OrderOutput(0, "loading\ndone\n", 0, "warning: x\n", segments, false)
+	assert.Equal(t, []driver.OutputWrite{
+		{Stream: stdoutStream, Offset: 0, Data: "loading\n"},
+		{Stream: stderrStream, Offset: 0, Data: "warning: x\n"},
+		{Stream: stdoutStream, Offset: 8, Data: "done\n"},
+	}, writes)
+}
+
+// A status requested from offsets carries the output past them, and only the
+// part of a segment it carries is written.
+func TestOrderOutput_ClipsSegmentsToOffsets(t *testing.T) {
+	segments := []*pb.OutputSegment{
+		{Sequence: 4, Stream: stdoutStream, Offset: 4, Length: 6},
+		{Sequence: 5, Stream: stderrStream, Offset: 3, Length: 4},
+		{Sequence: 6, Stream: stdoutStream, Offset: 10, Length: 10},
+	}
+
+	writes := driver.OrderOutput(7, "89abc", 3, "err\n", segments, false)
+	assert.Equal(t, []driver.OutputWrite{
+		{Stream: stdoutStream, Offset: 7, Data: "89a"},
+		{Stream: stderrStream, Offset: 3, Data: "err\n"},
+		{Stream: stdoutStream, Offset: 10, Data: "bc"},
+	}, writes, "the part of the last segment not carried yet is left for the next status")
+}
+
+// Output no segment covers is held back until the output is final, and
+// then follows the segments.
+func TestOrderOutput_FinalShipsUncoveredOutput(t *testing.T) {
+	segments := []*pb.OutputSegment{{Sequence: 1, Stream: stderrStream, Offset: 0, Length: 4}}
+
+	assert.Equal(t, []driver.OutputWrite{
+		{Stream: stderrStream, Offset: 0, Data: "err\n"},
+	}, driver.OrderOutput(0, "out\n", 0, "err\n", segments, false))
+
+	assert.Equal(t, []driver.OutputWrite{
+		{Stream: stderrStream, Offset: 0, Data: "err\n"},
+		{Stream: stdoutStream, Offset: 0, Data: "out\n"},
+		{Stream: stderrStream, Offset: 0, Data: "err\n"},
+	}, driver.OrderOutput(0, "out\n", 0, "err\n", segments, true))
+}
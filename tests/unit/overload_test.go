@@ -0,0 +1,47 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package unit
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/elide-dev/elide-task-driver/driver"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	cases := []struct {
+		name string
+		md   metadata.MD
+		want time.Duration
+	}{
+		{"seconds", metadata.Pairs("retry-after", "3"), 3 * time.Second},
+		{"missing", metadata.MD{}, 0},
+		{"nil", nil, 0},
+		{"malformed", metadata.Pairs("retry-after", "soon"), 0},
+		{"zero", metadata.Pairs("retry-after", "0"), 0},
+		{"negative", metadata.Pairs("retry-after", "-1"), 0},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, driver.ParseRetryAfter(tc.md))
+		})
+	}
+}
+
+func TestDaemonOverloadedError(t *testing.T) {
+	cause := status.Error(codes.ResourceExhausted, "too many executions")
+	err := error(&driver.DaemonOverloadedError{RetryAfter: 2 * time.Second, Err: cause})
+
+	var overloaded *driver.DaemonOverloadedError
+	assert.True(t, errors.As(err, &overloaded))
+	assert.Equal(t, 2*time.Second, overloaded.RetryAfter)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	assert.Contains(t, err.Error(), "retry after 2s")
+}
@@ -0,0 +1,68 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package unit
+
+import (
+	"testing"
+
+	"github.com/elide-dev/elide-task-driver/driver"
+	pb "github.com/elide-dev/elide-task-driver/proto/gen/go/elide/daemon/v1alpha1"
+	nstructs "github.com/hashicorp/nomad/nomad/structs"
+	"github.com/hashicorp/nomad/plugins/drivers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPortBindings(t *testing.T) {
+	cfg := &drivers.TaskConfig{
+		Resources: &drivers.Resources{
+			Ports: &nstructs.AllocatedPorts{
+				{Label: "http", Value: 25431, To: 8080, HostIP: "10.0.0.5"},
+				{Label: "metrics", Value: 25432, HostIP: "10.0.0.5"},
+			},
+		},
+	}
+
+	bindings, err := driver.PortBindings(cfg, []string{"http", "metrics"})
+	require.NoError(t, err)
+	require.Len(t, bindings, 2)
+	assert.Equal(t, "http", bindings[0].Label)
+	assert.Equal(t, "10.0.0.5", bindings[0].HostIp)
+	assert.EqualValues(t, 25431, bindings[0].HostPort)
+	assert.EqualValues(t, 8080, bindings[0].Port)
+	assert.EqualValues(t, 25432, bindings[1].Port, "without to, the execution listens on the allocated port")
+
+	_, err = driver.PortBindings(cfg, []string{"grpc"})
+	assert.ErrorContains(t, err, `port "grpc" is not allocated`)
+
+	_, err = driver.PortBindings(&drivers.TaskConfig{}, []string{"http"})
+	assert.Error(t, err, "tasks without allocated ports cannot bind any")
+
+	bindings, err = driver.PortBindings(cfg, nil)
+	require.NoError(t, err)
+	assert.Nil(t, bindings)
+}
+
+func TestDriverNetworkFor(t *testing.T) {
+	bindings := []*pb.PortBinding{{Label: "http", HostPort: 25431, Port: 8080}}
+
+	assert.Nil(t, driver.DriverNetworkFor(nil, &pb.ExecuteSnippetResponse{}, true), "tasks without ports have no network")
+
+	// Served on the host: nothing to advertise
+	network := driver.DriverNetworkFor(bindings, &pb.ExecuteSnippetResponse{}, true)
+	assert.Equal(t, map[string]int{"http": 8080}, network.PortMap)
+	assert.False(t, network.AutoAdvertise)
+
+	// The daemon's address and remapped ports take precedence
+	resp := &pb.ExecuteSnippetResponse{
+		Ip:    "172.17.0.9",
+		Ports: []*pb.PortBinding{{Label: "http", HostPort: 25431, Port: 9090}},
+	}
+	network = driver.DriverNetworkFor(bindings, resp, true)
+	assert.Equal(t, "172.17.0.9", network.IP)
+	assert.Equal(t, map[string]int{"http": 9090}, network.PortMap)
+	assert.True(t, network.AutoAdvertise)
+
+	assert.False(t, driver.DriverNetworkFor(bindings, resp, false).AutoAdvertise, "the host's address is advertised without expose")
+}
@@ -0,0 +1,37 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package unit
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/elide-dev/elide-task-driver/driver"
+	pb "github.com/elide-dev/elide-task-driver/proto/gen/go/elide/daemon/v1alpha1"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResubmitsOnRecovery(t *testing.T) {
+	idempotent := &driver.TaskConfig{Idempotent: true}
+	assert.True(t, driver.ResubmitsOnRecovery("resubmit", idempotent))
+	assert.False(t, driver.ResubmitsOnRecovery("resubmit", &driver.TaskConfig{}))
+	assert.False(t, driver.ResubmitsOnRecovery("lost", idempotent))
+	assert.False(t, driver.ResubmitsOnRecovery("", idempotent))
+}
+
+func TestLostSessionResult(t *testing.T) {
+	result := driver.LostSessionResult("session-1", "task-1", errors.New("session not found"))
+	assert.False(t, result.Successful())
+	assert.ErrorContains(t, result.Err, "session session-1 was lost (session not found)")
+	assert.ErrorContains(t, result.Err, "execution task-1 cannot be recovered")
+}
+
+func TestSessionRecreated(t *testing.T) {
+	startedAt := time.Unix(1700000000, 500_000_000)
+	assert.False(t, driver.SessionRecreated(&pb.GetSessionResponse{CreatedAt: 1699990000}, startedAt))
+	assert.False(t, driver.SessionRecreated(&pb.GetSessionResponse{CreatedAt: 1700000000}, startedAt), "same second")
+	assert.True(t, driver.SessionRecreated(&pb.GetSessionResponse{CreatedAt: 1700000060}, startedAt))
+	assert.False(t, driver.SessionRecreated(&pb.GetSessionResponse{}, startedAt), "unknown creation time")
+}
@@ -0,0 +1,113 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package unit
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/elide-dev/elide-task-driver/driver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompileResultSchema_Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+		want   string
+	}{
+		{"not JSON", `{"type": `, "not valid JSON"},
+		{"not a schema", `[1, 2]`, "must be an object or a boolean"},
+		{"unknown type", `{"type": "decimal"}`, "/type: unknown type decimal"},
+		{"reference", `{"properties": {"a": {"$ref": "#"}}}`, `/properties/a: unsupported keyword "$ref"`},
+		{"bad pattern", `{"pattern": "("}`, "/pattern"},
+		{"negative length", `{"maxLength": -1}`, "/maxLength: must be a non-negative integer"},
+		{"empty anyOf", `{"anyOf": []}`, "/anyOf: must be a non-empty array"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := driver.CompileResultSchema(tt.schema)
+			assert.ErrorContains(t, err, tt.want)
+		})
+	}
+
+	_, err := driver.CompileResultSchema(`{"title": "Result", "description": "annotations are ignored", "format": "uri"}`)
+	assert.NoError(t, err)
+}
+
+func TestResultSchema_Validate(t *testing.T) {
+	schema, err := driver.CompileResultSchema(`{
+		"type": "object",
+		"required": ["status", "items"],
+		"properties": {
+			"status": {"enum": ["ok", "partial"]},
+			"count": {"type": "integer", "minimum": 0},
+			"items": {"type": "array", "maxItems": 2, "items": {"type": "string", "pattern": "^[a-z]+$"}},
+			"a/b": {"type": "null"}
+		},
+		"additionalProperties": false
+	}`)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		result string
+		want   string
+	}{
+		{"matches", `{"status": "ok", "count": 3, "items": ["a", "b"]}`, ""},
+		{"not JSON", `done`, "result is not valid JSON"},
+		{"wrong type", `[]`, "/: expected object, got array"},
+		{"missing property", `{"status": "ok"}`, `/: missing required property "items"`},
+		{"not in enum", `{"status": "failed", "items": []}`, "/status: value is not one of the allowed values"},
+		{"not an integer", `{"status": "ok", "count": 1.5, "items": []}`, "/count: expected integer, got number"},
+		{"below minimum", `{"status": "ok", "count": -1, "items": []}`, "/count: -1 is less than the minimum 0"},
+		{"too many items", `{"status": "ok", "items": ["a", "b", "c"]}`, "/items: has 3 items, more than 2"},
+		{"item mismatch", `{"status": "ok", "items": ["a", "B"]}`, `/items/1: string does not match pattern "^[a-z]+$"`},
+		{"escaped name", `{"status": "ok", "items": [], "a/b": 1}`, "/a~1b: expected null, got number"},
+		{"additional property", `{"status": "ok", "items": [], "extra": true}`, `/: property "extra" is not allowed`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := schema.Validate(tt.result)
+			if tt.want == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestResultSchema_ValidateCombinators(t *testing.T) {
+	schema, err := driver.CompileResultSchema(`{"oneOf": [{"type": "string"}, {"type": "number"}], "not": {"const": 0}}`)
+	require.NoError(t, err)
+
+	assert.NoError(t, schema.Validate(`"done"`))
+	assert.NoError(t, schema.Validate(`42`))
+	assert.ErrorContains(t, schema.Validate(`true`), "matches 0 of the oneOf schemas")
+	assert.ErrorContains(t, schema.Validate(`0`), "matches the schema it must not match")
+}
+
+func TestLoadResultSchema(t *testing.T) {
+	schema, err := driver.LoadResultSchema(&driver.TaskConfig{}, t.TempDir(), false)
+	require.NoError(t, err)
+	assert.Nil(t, schema)
+
+	schema, err = driver.LoadResultSchema(&driver.TaskConfig{ResultSchema: `{"type": "string"}`}, t.TempDir(), false)
+	require.NoError(t, err)
+	assert.NoError(t, schema.Validate(`"done"`))
+
+	taskDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(taskDir, "local"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(taskDir, "local", "schema.json"), []byte(`{"type": "number"}`), 0o644))
+	schema, err = driver.LoadResultSchema(&driver.TaskConfig{ResultSchema: "local/schema.json"}, taskDir, false)
+	require.NoError(t, err)
+	assert.NoError(t, schema.Validate(`1`))
+	assert.Error(t, schema.Validate(`"1"`))
+
+	_, err = driver.LoadResultSchema(&driver.TaskConfig{ResultSchema: "local/missing.json"}, taskDir, false)
+	assert.Error(t, err)
+}
@@ -0,0 +1,95 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package unit
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/elide-dev/elide-task-driver/driver"
+	"github.com/hashicorp/nomad/plugins/base"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestRPCRetryPolicies_Defaults(t *testing.T) {
+	policies, err := (&driver.Config{}).RPCRetryPolicies()
+	require.NoError(t, err)
+	assert.Equal(t, driver.DefaultRPCRetryPolicy, policies["GetExecutionStatus"])
+	assert.Equal(t, driver.DefaultRPCRetryPolicy, policies["Health"])
+	for _, rpc := range []string{"ExecuteSnippet", "CreateSession", "PutSessionValue", "ExecInSession"} {
+		assert.Equal(t, 1, policies[rpc].MaxAttempts, "%s changes the daemon's state and is not retried", rpc)
+	}
+	assert.NotContains(t, policies, "WatchExecution", "streams are not retried")
+}
+
+func TestRPCRetryPolicies_FromConfigFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "elide.hcl")
+	require.NoError(t, os.WriteFile(path, []byte(`
+rpc_retry "default" {
+  max_attempts = 5
+  max_backoff  = "5s"
+}
+
+rpc_retry "ExecuteSnippet" {
+  max_attempts    = 2
+  initial_backoff = "250ms"
+  retryable_codes = ["UNAVAILABLE", "resource_exhausted"]
+}
+`), 0644))
+	cfg, err := driver.LoadConfigFile(path)
+	require.NoError(t, err)
+	var config driver.Config
+	require.NoError(t, base.MsgPackDecode(cfg.PluginConfig, &config))
+	require.NoError(t, config.Validate())
+
+	policies, err := config.RPCRetryPolicies()
+	require.NoError(t, err)
+	assert.Equal(t, driver.RPCRetryPolicy{
+		MaxAttempts:    2,
+		InitialBackoff: 250 * time.Millisecond,
+		MaxBackoff:     5 * time.Second,
+		RetryableCodes: []codes.Code{codes.Unavailable, codes.ResourceExhausted},
+	}, policies["ExecuteSnippet"], "a block overrides the default block")
+	assert.Equal(t, 5, policies["GetExecutionStatus"].MaxAttempts)
+	assert.Equal(t, 1, policies["CreateSession"].MaxAttempts, "the default block does not retry RPCs changing the daemon's state")
+	assert.Equal(t, 5*time.Second, policies["CreateSession"].MaxBackoff)
+	assert.Equal(t, driver.DefaultRPCRetryPolicy.RetryableCodes, policies["GetExecutionStatus"].RetryableCodes)
+}
+
+func TestRPCRetryPolicies_Invalid(t *testing.T) {
+	for name, bad := range map[string]map[string]driver.RPCRetryConfig{
+		"unknown RPC":       {"Execute": {MaxAttempts: 2}},
+		"stream":            {"WatchExecution": {MaxAttempts: 2}},
+		"negative attempts": {"default": {MaxAttempts: -1}},
+		"bad backoff":       {"Health": {InitialBackoff: "soon"}},
+		"zero backoff":      {"Health": {MaxBackoff: "0s"}},
+		"inverted backoffs": {"Health": {InitialBackoff: "3s", MaxBackoff: "1s"}},
+		"unknown code":      {"Health": {RetryableCodes: []string{"FLAKY"}}},
+		"OK code":           {"Health": {RetryableCodes: []string{"OK"}}},
+	} {
+		assert.ErrorContains(t, (&driver.Config{RPCRetry: bad}).Validate(), "rpc_retry", name)
+	}
+}
+
+func TestRPCRetryPolicy_Backoff(t *testing.T) {
+	policy := driver.RPCRetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+	for retry, limit := range map[int]time.Duration{1: 100 * time.Millisecond, 2: 200 * time.Millisecond, 4: 800 * time.Millisecond, 10: time.Second} {
+		backoff := policy.Backoff(retry)
+		assert.LessOrEqual(t, backoff, limit, retry)
+		assert.GreaterOrEqual(t, backoff, limit/2, retry, "jitter takes at most half")
+	}
+}
+
+func TestRPCRetryPolicy_Retryable(t *testing.T) {
+	policy := driver.DefaultRPCRetryPolicy
+	assert.True(t, policy.Retryable(status.Error(codes.Unavailable, "connection refused")))
+	assert.True(t, policy.Retryable(status.Error(codes.DeadlineExceeded, "slow")))
+	assert.False(t, policy.Retryable(status.Error(codes.NotFound, "session not found")))
+	assert.False(t, policy.Retryable(nil))
+}
@@ -0,0 +1,141 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package unit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/elide-dev/elide-task-driver/driver"
+	pb "github.com/elide-dev/elide-task-driver/proto/gen/go/elide/daemon/v1alpha1"
+	"github.com/elide-dev/elide-task-driver/tests/helpers"
+	"github.com/hashicorp/go-hclog"
+	nstructs "github.com/hashicorp/nomad/nomad/structs"
+	"github.com/hashicorp/nomad/plugins/drivers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// noDelay retries immediately so tests are fast and deterministic
+var noDelay = driver.SessionRetryPolicy{Attempts: 3}
+
+func TestOpenSession_Creates(t *testing.T) {
+	client := helpers.NewMockDaemonClient()
+
+	session, err := driver.OpenSession(context.Background(), client, "nomad-test", &pb.SessionConfiguration{}, noDelay, hclog.NewNullLogger())
+	require.NoError(t, err)
+	assert.Equal(t, "nomad-test", session.ID)
+	assert.True(t, session.Created)
+	assert.Equal(t, 1, client.CreateSessionCalls())
+	assert.Equal(t, 0, client.GetSessionCalls())
+}
+
+func TestOpenSession_ReusesOnConflict(t *testing.T) {
+	// Another driver instance created the session first
+	client := helpers.NewMockDaemonClient()
+	client.SetSessionConflicts(true)
+	client.AddSession("nomad-test", &pb.SessionConfiguration{})
+
+	session, err := driver.OpenSession(context.Background(), client, "nomad-test", &pb.SessionConfiguration{}, noDelay, hclog.NewNullLogger())
+	require.NoError(t, err)
+	assert.Equal(t, "nomad-test", session.ID)
+	assert.False(t, session.Created)
+	assert.Equal(t, 1, client.CreateSessionCalls(), "a conflict resolves without retrying")
+	assert.Equal(t, 1, client.GetSessionCalls())
+}
+
+func TestOpenSession_RetriesUntilDaemonIsUp(t *testing.T) {
+	client := helpers.NewMockDaemonClient()
+	client.SetCreateSessionFailures(2, errors.New("connection refused"))
+
+	session, err := driver.OpenSession(context.Background(), client, "nomad-test", &pb.SessionConfiguration{}, noDelay, hclog.NewNullLogger())
+	require.NoError(t, err)
+	assert.True(t, session.Created)
+	assert.Equal(t, 3, client.CreateSessionCalls())
+	assert.Equal(t, 2, client.GetSessionCalls())
+}
+
+func TestOpenSession_GivesUp(t *testing.T) {
+	client := helpers.NewMockDaemonClient()
+	client.SetCreateSessionError(errors.New("connection refused"))
+
+	_, err := driver.OpenSession(context.Background(), client, "nomad-test", &pb.SessionConfiguration{}, noDelay, hclog.NewNullLogger())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "after 3 attempts")
+	assert.Contains(t, err.Error(), "connection refused")
+	assert.Equal(t, 3, client.CreateSessionCalls())
+}
+
+func TestOpenSession_StopsOnCancel(t *testing.T) {
+	client := helpers.NewMockDaemonClient()
+	client.SetCreateSessionError(errors.New("connection refused"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	policy := driver.SessionRetryPolicy{Attempts: 100, BaseDelay: time.Hour}
+
+	_, err := driver.OpenSession(ctx, client, "nomad-test", &pb.SessionConfiguration{}, policy, hclog.NewNullLogger())
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, 1, client.CreateSessionCalls())
+}
+
+func TestSessionRetryConfig_Policy(t *testing.T) {
+	policy, err := (&driver.SessionRetryConfig{}).Policy()
+	require.NoError(t, err)
+	assert.Equal(t, driver.DefaultSessionRetryPolicy, policy)
+
+	policy, err = (&driver.SessionRetryConfig{Attempts: 20, BaseDelay: "1s", Jitter: "250ms"}).Policy()
+	require.NoError(t, err)
+	assert.Equal(t, driver.SessionRetryPolicy{Attempts: 20, BaseDelay: time.Second, Jitter: 250 * time.Millisecond}, policy)
+
+	_, err = (&driver.SessionRetryConfig{Attempts: -1}).Policy()
+	assert.Error(t, err)
+	_, err = (&driver.SessionRetryConfig{BaseDelay: "soon"}).Policy()
+	assert.Error(t, err)
+}
+
+func TestDiffSessionConfig(t *testing.T) {
+	live := &pb.SessionConfiguration{
+		ContextPoolSize:   10,
+		EnabledLanguages:  []string{"python", "javascript"},
+		EnabledIntrinsics: []string{"io", "env"},
+		MemoryLimitMb:     512,
+	}
+	assert.Empty(t, driver.DiffSessionConfig(live, live))
+
+	desired := &pb.SessionConfiguration{
+		ContextPoolSize:   20,
+		EnabledLanguages:  []string{"python", "typescript"},
+		EnabledIntrinsics: []string{"env", "io"},
+		MemoryLimitMb:     512,
+		EnableAi:          true,
+	}
+	assert.Equal(t, []driver.SessionChange{
+		{Field: "context_pool_size", Op: driver.ChangeUpdate, Current: "10", Desired: "20"},
+		{Field: "enabled_languages", Op: driver.ChangeRemove, Current: "javascript"},
+		{Field: "enabled_languages", Op: driver.ChangeAdd, Desired: "typescript"},
+		{Field: "enable_ai", Op: driver.ChangeUpdate, Current: "false", Desired: "true"},
+	}, driver.DiffSessionConfig(live, desired))
+}
+
+func TestMemoryReservationMB(t *testing.T) {
+	cfg := &drivers.TaskConfig{Resources: &drivers.Resources{NomadResources: &nstructs.AllocatedTaskResources{
+		Memory: nstructs.AllocatedMemoryResources{MemoryMB: 300},
+	}}}
+
+	// The task's own limit, then its sandbox profile's, then Nomad's allocation
+	assert.Equal(t, 128, driver.MemoryReservationMB(cfg, driver.ElideOptions{MemoryLimit: 128}, &driver.ProfileConfig{MemoryLimitMB: 64}))
+	assert.Equal(t, 64, driver.MemoryReservationMB(cfg, driver.ElideOptions{}, &driver.ProfileConfig{MemoryLimitMB: 64}))
+	assert.Equal(t, 300, driver.MemoryReservationMB(cfg, driver.ElideOptions{}, &driver.ProfileConfig{}))
+	assert.Equal(t, 300, driver.MemoryReservationMB(cfg, driver.ElideOptions{}, nil))
+	assert.Zero(t, driver.MemoryReservationMB(&drivers.TaskConfig{}, driver.ElideOptions{}, nil))
+}
+
+func TestSessionMemoryFree(t *testing.T) {
+	assert.Equal(t, 212, driver.SessionMemoryFree(512, 300))
+	assert.Equal(t, 512, driver.SessionMemoryFree(512, 0))
+	assert.Zero(t, driver.SessionMemoryFree(512, 600), "over-committed sessions have nothing left")
+}
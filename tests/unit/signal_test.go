@@ -0,0 +1,44 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package unit
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/elide-dev/elide-task-driver/driver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSignal(t *testing.T) {
+	tests := []struct {
+		signal string
+		name   string
+		named  bool
+	}{
+		{"SIGHUP", "SIGHUP", false},
+		{"SIGTERM", "SIGTERM", false},
+		{"USR1", "SIGUSR1", false},
+		{"reload-config", "reload-config", true},
+		{"flush", "flush", true},
+		{"hup", "hup", true},
+		{"cache.evict_all", "cache.evict_all", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.signal, func(t *testing.T) {
+			name, named, err := driver.ParseSignal(tt.signal)
+			require.NoError(t, err)
+			assert.Equal(t, tt.name, name)
+			assert.Equal(t, tt.named, named)
+		})
+	}
+}
+
+func TestParseSignal_Invalid(t *testing.T) {
+	for _, signal := range []string{"", "  ", "reload config", "flush;rm", strings.Repeat("x", 65)} {
+		_, _, err := driver.ParseSignal(signal)
+		assert.Error(t, err, "signal %q", signal)
+	}
+}
@@ -0,0 +1,67 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package unit
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/elide-dev/elide-task-driver/driver"
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/nomad/plugins/drivers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshot_FileRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+	snap := &driver.Snapshot{
+		Version:   1,
+		CreatedAt: time.Now().UTC().Truncate(time.Second),
+		SessionID: "session-123",
+		Tasks: []driver.TaskState{
+			{
+				TaskConfig: &drivers.TaskConfig{
+					ID:   "test-123",
+					Name: "test-task",
+				},
+				StartedAt:   time.Now().UTC().Truncate(time.Second),
+				ExecutionId: "exec-123",
+				SessionId:   "session-123",
+			},
+		},
+	}
+
+	require.NoError(t, driver.WriteSnapshotFile(path, snap))
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	got, err := driver.ReadSnapshotFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, snap.SessionID, got.SessionID)
+	require.Len(t, got.Tasks, 1)
+	assert.Equal(t, "test-123", got.Tasks[0].TaskConfig.ID)
+	assert.Equal(t, "exec-123", got.Tasks[0].ExecutionId)
+	assert.True(t, snap.Tasks[0].StartedAt.Equal(got.Tasks[0].StartedAt))
+}
+
+func TestSnapshot_EmptyDriver(t *testing.T) {
+	plugin := driver.NewPlugin(hclog.NewNullLogger()).(*driver.ElideDriverPlugin)
+
+	snap := plugin.Snapshot()
+	assert.Equal(t, 1, snap.Version)
+	assert.Empty(t, snap.SessionID)
+	assert.Empty(t, snap.Tasks)
+}
+
+func TestRestoreSnapshot_RejectsUnknownVersion(t *testing.T) {
+	plugin := driver.NewPlugin(hclog.NewNullLogger()).(*driver.ElideDriverPlugin)
+
+	_, err := plugin.RestoreSnapshot(&driver.Snapshot{Version: 99})
+	assert.Error(t, err)
+}
@@ -4,12 +4,14 @@
 package unit
 
 import (
+	"os"
 	"testing"
 	"time"
 
 	"github.com/elide-dev/elide-task-driver/driver"
 	"github.com/hashicorp/nomad/plugins/drivers"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestTaskState_Serialization(t *testing.T) {
@@ -50,3 +52,77 @@ func TestTaskState_Recovery(t *testing.T) {
 	assert.False(t, taskState.StartedAt.IsZero(), "StartedAt required for recovery")
 }
 
+
+func TestTaskStateCodec_RoundTrip(t *testing.T) {
+	taskState := &driver.TaskState{
+		TaskConfig: &drivers.TaskConfig{
+			ID:   "test-123",
+			Name: "test-task",
+		},
+		StartedAt:    time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC),
+		ExecutionId:  "exec-123",
+		SessionId:    "session-123",
+		OutputMode:   "discard",
+		CodeHash:     "9a3e2e6f0c",
+		PollInterval: 2 * time.Second,
+	}
+
+	data, err := driver.EncodeTaskState(taskState)
+	require.NoError(t, err)
+	assert.Equal(t, byte(2), data[0], "state is wrapped in a version envelope")
+
+	decoded, err := driver.DecodeTaskState(2, data)
+	require.NoError(t, err)
+	assert.Equal(t, taskState.TaskConfig.ID, decoded.TaskConfig.ID)
+	assert.Equal(t, taskState.ExecutionId, decoded.ExecutionId)
+	assert.Equal(t, "discard", decoded.OutputMode)
+	assert.Equal(t, "9a3e2e6f0c", decoded.CodeHash)
+	assert.Equal(t, 2*time.Second, decoded.PollInterval)
+	assert.True(t, taskState.StartedAt.Equal(decoded.StartedAt))
+}
+
+// The fixtures are the driver state of handles written by earlier driver
+// versions (task handle version 1, no envelope): the original TaskState, and
+// the last version 1 TaskState with every field set.
+func TestTaskStateCodec_DecodesVersion1Handles(t *testing.T) {
+	data, err := os.ReadFile("testdata/taskstate_v1_baseline.msgpack")
+	require.NoError(t, err)
+
+	state, err := driver.DecodeTaskState(1, data)
+	require.NoError(t, err)
+	assert.Equal(t, "alloc-1/hello/0001", state.TaskConfig.ID)
+	assert.Equal(t, "alloc-1", state.TaskConfig.AllocID)
+	assert.Equal(t, "exec-1", state.ExecutionId)
+	assert.Equal(t, "nomad-node-1", state.SessionId)
+	assert.True(t, time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC).Equal(state.StartedAt))
+	assert.Equal(t, "log", state.OutputMode, "migration fills in the default output mode")
+	assert.Empty(t, state.SessionProfile)
+
+	data, err = os.ReadFile("testdata/taskstate_v1.msgpack")
+	require.NoError(t, err)
+
+	state, err = driver.DecodeTaskState(1, data)
+	require.NoError(t, err)
+	assert.Equal(t, "nomad-node-1-large", state.SessionId)
+	assert.Equal(t, "strict", state.Profile)
+	assert.Equal(t, "large", state.SessionProfile)
+	assert.Equal(t, []string{"answer"}, state.Exports)
+	assert.Equal(t, "file", state.OutputMode)
+	assert.True(t, time.Date(2025, 6, 1, 12, 5, 0, 0, time.UTC).Equal(state.Deadline))
+	assert.Equal(t, 2*time.Second, state.PollInterval)
+	assert.Equal(t, "/alloc/scratch", state.ScratchDir)
+	assert.Equal(t, 64, state.ScratchQuotaMB)
+}
+
+func TestTaskStateCodec_RejectsUnknownVersions(t *testing.T) {
+	data, err := driver.EncodeTaskState(&driver.TaskState{TaskConfig: &drivers.TaskConfig{ID: "test-123"}})
+	require.NoError(t, err)
+
+	data[0] = 99
+	_, err = driver.DecodeTaskState(2, data)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unsupported task state version 99")
+
+	_, err = driver.DecodeTaskState(2, nil)
+	assert.Error(t, err)
+}
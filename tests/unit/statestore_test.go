@@ -0,0 +1,56 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package unit
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/elide-dev/elide-task-driver/driver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStateStore_SurvivesRestart(t *testing.T) {
+	dir := t.TempDir()
+	store, err := driver.OpenStateStore(dir)
+	require.NoError(t, err)
+
+	session, loaded := store.Loaded()
+	assert.Empty(t, session)
+	assert.Empty(t, loaded)
+
+	startedAt := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, store.SetSession("nomad-node-1"))
+	require.NoError(t, store.PutExecution(&driver.ExecutionRecord{TaskID: "a", SessionID: "nomad-node-1", ExecutionIDs: []string{"exec-a"}, StartedAt: startedAt}))
+	require.NoError(t, store.PutExecution(&driver.ExecutionRecord{TaskID: "b", SessionID: "nomad-node-1", ExecutionIDs: []string{"exec-b1", "exec-b2"}}))
+	require.NoError(t, store.RemoveExecution("b"))
+	require.NoError(t, store.RemoveExecution("unknown"))
+
+	// A restarted plugin sees what the previous instance recorded
+	reopened, err := driver.OpenStateStore(dir)
+	require.NoError(t, err)
+	session, loaded = reopened.Loaded()
+	assert.Equal(t, "nomad-node-1", session)
+	require.Len(t, loaded, 1)
+	assert.Equal(t, "a", loaded[0].TaskID)
+	assert.Equal(t, []string{"exec-a"}, loaded[0].ExecutionIDs)
+	assert.True(t, startedAt.Equal(loaded[0].StartedAt))
+
+	// Later changes do not alter what was loaded
+	require.NoError(t, reopened.RemoveExecution("a"))
+	assert.Empty(t, reopened.Executions())
+	_, loaded = reopened.Loaded()
+	assert.Len(t, loaded, 1)
+}
+
+func TestStateStore_CorruptFile(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "driver-state.json"), []byte("{"), 0600))
+
+	_, err := driver.OpenStateStore(dir)
+	assert.ErrorContains(t, err, "failed to decode state file")
+}
@@ -0,0 +1,48 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package unit
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/elide-dev/elide-task-driver/driver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTaskStdin(t *testing.T) {
+	taskDir := t.TempDir()
+	allocDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(taskDir, "local"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(taskDir, "local", "input.csv"), []byte("a,b\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(allocDir, "shared.csv"), []byte("c,d\n"), 0644))
+
+	stdin, err := driver.TaskStdin(&driver.TaskConfig{}, taskDir, allocDir, false)
+	require.NoError(t, err)
+	assert.Nil(t, stdin, "tasks without stdin read an empty one")
+
+	stdin, err = driver.TaskStdin(&driver.TaskConfig{StdinData: "inline"}, taskDir, allocDir, false)
+	require.NoError(t, err)
+	assert.Equal(t, &driver.Stdin{Data: "inline"}, stdin)
+
+	stdin, err = driver.TaskStdin(&driver.TaskConfig{StdinPath: "local/input.csv"}, taskDir, allocDir, false)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(taskDir, "local", "input.csv"), stdin.Path)
+
+	// alloc/ paths are in the shared alloc dir
+	stdin, err = driver.TaskStdin(&driver.TaskConfig{StdinPath: "alloc/shared.csv"}, taskDir, allocDir, false)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(allocDir, "shared.csv"), stdin.Path)
+
+	_, err = driver.TaskStdin(&driver.TaskConfig{StdinPath: "local/missing.csv"}, taskDir, allocDir, false)
+	assert.ErrorContains(t, err, "not found")
+
+	_, err = driver.TaskStdin(&driver.TaskConfig{StdinPath: "local"}, taskDir, allocDir, false)
+	assert.ErrorContains(t, err, "not a regular file")
+
+	_, err = driver.TaskStdin(&driver.TaskConfig{StdinPath: "alloc/../secret"}, taskDir, allocDir, false)
+	assert.ErrorContains(t, err, "escapes")
+}
@@ -0,0 +1,51 @@
+# Every option set to a non-default value, so that an option the schema
+# declares but the TaskConfig struct does not decode shows up as unset. The
+# combination is not a valid task.
+task "all-options" {
+  driver = "elide"
+
+  config {
+    script               = "local/main.py"
+    code                 = "print('inline')"
+    code_oci_ref         = "ghcr.io/example/snippets:1.0"
+    code_oci_entrypoint  = "main.py"
+    entrypoint           = "main.py"
+    files                = "local/app"
+    stdin_path           = "local/stdin.txt"
+    stdin_data           = "hello"
+    ports                = ["http"]
+    expose               = true
+    language             = "python"
+    args                 = ["--verbose"]
+    args_matrix          = [["--shard", "1"], ["--shard", "2"]]
+    interpolate          = true
+    matrix_policy        = "any-success"
+    env                  = { GREETING = "hello" }
+    env_case             = "upper"
+    env_file             = "local/app.env"
+    scratch_dir          = true
+    scratch_quota_mb     = 64
+    poll_interval        = "500ms"
+    imports              = { API_URL = "api_url" }
+    exports              = ["answer"]
+    output_mode          = "file"
+    combine_output       = true
+    binary_output        = "raw"
+    result_schema        = "local/result.schema.json"
+    result_schema_policy = "warn"
+    immutable_code       = true
+    idempotent           = true
+
+    elide_opts {
+      memory_limit    = 512
+      enable_ai       = true
+      timeout         = "5m"
+      profile         = "pure-compute"
+      session_profile = "large"
+      numa_node       = 2
+      timezone        = "UTC"
+      locale          = "C.UTF-8"
+      trace_parent    = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+    }
+  }
+}
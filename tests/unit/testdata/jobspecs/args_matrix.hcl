@@ -0,0 +1,10 @@
+# One execution per arg set, succeeding if any does
+task "args-matrix" {
+  driver = "elide"
+
+  config {
+    script        = "local/probe.py"
+    args_matrix   = [["--region", "us-east-1"], ["--region", "eu-west-1"]]
+    matrix_policy = "any-success"
+  }
+}
@@ -0,0 +1,19 @@
+# Per-execution overrides of the session
+task "elide-opts" {
+  driver = "elide"
+
+  config {
+    code = "console.log(new Date().toString())"
+
+    elide_opts {
+      memory_limit    = 256
+      enable_ai       = true
+      timeout         = "30s"
+      profile         = "network-allowed"
+      session_profile = "large"
+      numa_node       = 1
+      timezone        = "Europe/Berlin"
+      locale          = "de_DE.UTF-8"
+    }
+  }
+}
@@ -0,0 +1,8 @@
+# The smallest task: inline code, everything else defaulted
+task "inline-code" {
+  driver = "elide"
+
+  config {
+    code = "print('hello from elide')"
+  }
+}
@@ -0,0 +1,18 @@
+# A script from an artifact, with args and env
+task "script" {
+  driver = "elide"
+
+  config {
+    script        = "report.ts"
+    script_source = "artifact"
+    language      = "typescript"
+    args          = ["--since", "24h"]
+
+    env = {
+      REPORT_BUCKET = "reports"
+      LOG_LEVEL     = "debug"
+    }
+    env_file    = "local/report.env"
+    output_mode = "both"
+  }
+}
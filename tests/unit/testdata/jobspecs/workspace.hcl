@@ -0,0 +1,12 @@
+# A multi-file snippet serving on a group port
+task "workspace" {
+  driver = "elide"
+
+  config {
+    entrypoint = "server.js"
+    files      = "local/app"
+    stdin_path = "alloc/input.json"
+    ports      = ["http"]
+    expose     = true
+  }
+}
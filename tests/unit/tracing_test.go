@@ -0,0 +1,57 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package unit
+
+import (
+	"testing"
+
+	"github.com/elide-dev/elide-task-driver/driver"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTraceParent(t *testing.T) {
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36},
+		SpanID:     trace.SpanID{0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7},
+		TraceFlags: trace.FlagsSampled,
+	})
+
+	traceParent := driver.TraceParent(sc)
+	assert.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", traceParent)
+
+	parsed := driver.ParseTraceParent(traceParent)
+	assert.True(t, parsed.IsRemote())
+	assert.Equal(t, sc.TraceID(), parsed.TraceID())
+	assert.Equal(t, sc.SpanID(), parsed.SpanID())
+	assert.True(t, parsed.IsSampled())
+}
+
+func TestTraceParent_Invalid(t *testing.T) {
+	assert.Empty(t, driver.TraceParent(trace.SpanContext{}), "tracing disabled")
+	assert.False(t, driver.ParseTraceParent("").IsValid())
+	assert.False(t, driver.ParseTraceParent("00-not-a-trace-01").IsValid())
+}
+
+func TestTaskTraceParent(t *testing.T) {
+	const dispatcher = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	const meta = "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"
+
+	tc := &driver.TaskConfig{}
+	assert.False(t, driver.TaskTraceParent(tc, nil).IsValid(), "tasks join no trace by default")
+
+	env := map[string]string{"TRACEPARENT": "garbage", "NOMAD_META_TRACEPARENT": meta}
+	assert.Equal(t, meta, driver.TraceParent(driver.TaskTraceParent(tc, env)), "malformed env vars are skipped")
+
+	env["TRACEPARENT"] = dispatcher
+	assert.Equal(t, dispatcher, driver.TraceParent(driver.TaskTraceParent(tc, env)))
+
+	tc.ElideOpts.TraceParent = meta
+	assert.Equal(t, meta, driver.TraceParent(driver.TaskTraceParent(tc, env)), "elide_opts.trace_parent takes precedence")
+
+	tc = &driver.TaskConfig{Code: "print(1)", ElideOpts: driver.ElideOptions{TraceParent: "00-abc"}}
+	assert.ErrorContains(t, tc.Validate(), "invalid elide_opts.trace_parent")
+	tc.ElideOpts.TraceParent = dispatcher
+	assert.NoError(t, tc.Validate())
+}
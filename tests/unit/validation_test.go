@@ -0,0 +1,55 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package unit
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/elide-dev/elide-task-driver/driver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTaskConfig_ValidateAggregatesProblems(t *testing.T) {
+	tc := driver.TaskConfig{
+		Script:    "main.rb",
+		Code:      "print(1)",
+		ElideOpts: driver.ElideOptions{Timeout: "soon", MemoryLimit: -1},
+		Exports:   []string{"out", "out"},
+	}
+	err := tc.Validate()
+	require.Error(t, err)
+
+	assert.Equal(t, []string{"script, code", "elide_opts.timeout", "elide_opts.memory_limit", "exports", "language"}, driver.InvalidFields(err))
+	assert.EqualError(t, err, `5 problems: `+
+		`script, code: only one of 'script', 'code', 'code_oci_ref' or 'entrypoint' may be specified; `+
+		`invalid elide_opts.timeout "soon": must be a duration such as "30s" or "5m"; `+
+		`elide_opts.memory_limit cannot be negative; `+
+		`invalid exports: duplicate key "out"; `+
+		`cannot infer language of script "main.rb": unknown extension ".rb"; set language`)
+
+	var fieldErr *driver.FieldError
+	require.True(t, errors.As(err, &fieldErr))
+	assert.Equal(t, "script, code", fieldErr.Field)
+}
+
+func TestTaskConfig_ValidateSingleProblem(t *testing.T) {
+	tc := driver.TaskConfig{Code: "print(1)", Language: "python", MatrixPolicy: "most-success"}
+	err := tc.Validate()
+	assert.EqualError(t, err, `invalid matrix_policy "most-success" (must be "all-success" or "any-success")`)
+	assert.Equal(t, []string{"matrix_policy"}, driver.InvalidFields(err))
+}
+
+func TestTaskConfig_ValidateFor(t *testing.T) {
+	tc := driver.TaskConfig{Code: "print(1)", Language: "ruby", PollInterval: "-1s"}
+	assert.NoError(t, (&driver.TaskConfig{Code: "print(1)", Language: "python"}).ValidateFor([]string{"python"}))
+
+	err := tc.ValidateFor([]string{"python", "javascript"})
+	assert.Equal(t, []string{"poll_interval", "language"}, driver.InvalidFields(err))
+	assert.ErrorContains(t, err, `language "ruby" not enabled in session`)
+
+	assert.Equal(t, []string{"poll_interval"}, driver.InvalidFields(tc.Validate()), "language not checked against the session")
+	assert.Nil(t, driver.InvalidFields(nil))
+}
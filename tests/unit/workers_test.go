@@ -0,0 +1,81 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package unit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/elide-dev/elide-task-driver/driver"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkerMonitor_StalledWorker(t *testing.T) {
+	monitor := driver.NewWorkerMonitor(hclog.NewNullLogger())
+	w := monitor.Start("log_throttle", time.Second)
+	w.Beat()
+
+	health := monitor.Health(time.Now())
+	assert.Equal(t, "ok", health.Status)
+	require.Len(t, health.Workers, 1)
+	assert.Equal(t, driver.WorkerRunning, health.Workers[0].State)
+	assert.Equal(t, "1s", health.Workers[0].Interval)
+
+	health = monitor.Health(time.Now().Add(time.Minute))
+	assert.Equal(t, "degraded", health.Status)
+	assert.Equal(t, driver.WorkerStalled, health.Workers[0].State)
+	assert.Equal(t, []string{"log_throttle"}, health.Degraded())
+
+	// A worker waiting on the daemon is not stalled
+	w.Idle()
+	health = monitor.Health(time.Now().Add(time.Minute))
+	assert.Equal(t, "ok", health.Status)
+	assert.Equal(t, driver.WorkerIdle, health.Workers[0].State)
+}
+
+func TestWorkerMonitor_CrashedWorker(t *testing.T) {
+	monitor := driver.NewWorkerMonitor(hclog.NewNullLogger())
+
+	require.NotPanics(t, func() {
+		monitor.Run("manifest_watcher", time.Second, func(*driver.WorkerHeartbeat) { panic("boom") })
+	})
+	monitor.Run("session_events", 0, func(*driver.WorkerHeartbeat) {})
+
+	health := monitor.Health(time.Now())
+	assert.Equal(t, "degraded", health.Status)
+	require.Len(t, health.Workers, 1, "a worker that returned is unregistered")
+	assert.Equal(t, driver.WorkerCrashed, health.Workers[0].State)
+	assert.Equal(t, "boom", health.Workers[0].Error)
+
+	monitor.Remove("manifest_watcher")
+	assert.Equal(t, "ok", monitor.Health(time.Now()).Status)
+}
+
+func TestWorkerMonitor_PerTaskWorkers(t *testing.T) {
+	monitor := driver.NewWorkerMonitor(hclog.NewNullLogger())
+	monitor.Start("session_keepalive", 0)
+	monitor.Start("status_poller/a", time.Second).Beat()
+	monitor.Start("status_poller/b", time.Hour).Beat()
+
+	// Healthy per-task workers are only counted
+	health := monitor.Health(time.Now())
+	assert.Equal(t, map[string]int{"status_poller": 2}, health.Tasks)
+	require.Len(t, health.Workers, 1)
+	assert.Equal(t, "session_keepalive", health.Workers[0].Name)
+
+	health = monitor.Health(time.Now().Add(time.Minute))
+	assert.Equal(t, []string{"status_poller/a"}, health.Degraded())
+}
+
+func TestWorkerMonitor_ReplacedWorker(t *testing.T) {
+	monitor := driver.NewWorkerMonitor(hclog.NewNullLogger())
+	old := monitor.Start("session_keepalive", time.Second)
+	monitor.Start("session_keepalive", time.Second)
+
+	// The replaced worker exiting does not unregister its successor
+	old.Done()
+	assert.Len(t, monitor.Health(time.Now()).Workers, 1)
+}
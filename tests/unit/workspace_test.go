@@ -0,0 +1,59 @@
+// Copyright (c) Elide Dev, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package unit
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/elide-dev/elide-task-driver/driver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollectWorkspace(t *testing.T) {
+	taskDir := t.TempDir()
+	outside := t.TempDir()
+	project := filepath.Join(taskDir, "local", "project")
+
+	require.NoError(t, os.MkdirAll(filepath.Join(project, "pkg"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(project, "main.py"), []byte("from pkg import util\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(project, "pkg", "__init__.py"), nil, 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(project, "pkg", "util.py"), []byte("import os\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(project, "README.md"), []byte("import sys\n"), 0644))
+
+	ws, err := driver.CollectWorkspace(taskDir, "local/project", "main.py", "python", false)
+	require.NoError(t, err)
+
+	var paths []string
+	for _, file := range ws.Files {
+		paths = append(paths, file.Path)
+	}
+	assert.Equal(t, []string{"README.md", "main.py", "pkg/__init__.py", "pkg/util.py"}, paths)
+	assert.Equal(t, []string{"os", "pkg"}, driver.ScanImports("python", ws.Sources), "only python files are scanned")
+	assert.Len(t, ws.Hash, 64)
+
+	// The hash covers every file of the workspace
+	require.NoError(t, os.WriteFile(filepath.Join(project, "pkg", "util.py"), []byte("import json\n"), 0644))
+	changed, err := driver.CollectWorkspace(taskDir, "local/project", "main.py", "python", false)
+	require.NoError(t, err)
+	assert.NotEqual(t, ws.Hash, changed.Hash)
+
+	_, err = driver.CollectWorkspace(taskDir, "local/project", "missing.py", "python", false)
+	assert.ErrorContains(t, err, "entrypoint")
+
+	_, err = driver.CollectWorkspace(taskDir, "local/missing", "main.py", "python", false)
+	assert.Error(t, err)
+
+	// Symlinks leaving the task directory need follow_symlinks
+	require.NoError(t, os.WriteFile(filepath.Join(outside, "evil.py"), []byte("print('evil')"), 0644))
+	require.NoError(t, os.Symlink(filepath.Join(outside, "evil.py"), filepath.Join(project, "evil.py")))
+	_, err = driver.CollectWorkspace(taskDir, "local/project", "main.py", "python", false)
+	assert.ErrorContains(t, err, "outside the task directory")
+
+	ws, err = driver.CollectWorkspace(taskDir, "local/project", "main.py", "python", true)
+	require.NoError(t, err)
+	assert.Len(t, ws.Files, 5)
+}